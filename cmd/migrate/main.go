@@ -0,0 +1,119 @@
+// Command migrate applies, rolls back and reports the wallet schema
+// migrations.
+//
+//	migrate -driver sqlite -dsn "file:wallets.db" up
+//	migrate -driver postgres -dsn "postgres://..." status
+//
+// Postgres connections use the "postgres" database/sql driver, which must be
+// linked into the binary (for example with a blank import of
+// github.com/jackc/pgx/v5/stdlib or github.com/lib/pq).
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/otyang/waas-go/migrations"
+	"github.com/otyang/waas-go/zrandom"
+	"github.com/uptrace/bun"
+)
+
+func main() {
+	driver := flag.String("driver", "postgres", "database driver: postgres or sqlite")
+	dsn := flag.String("dsn", os.Getenv("DATABASE_URL"), "data source name (defaults to $DATABASE_URL)")
+	verbose := flag.Bool("v", false, "print the queries run")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	if err := run(context.Background(), flag.Arg(0), *driver, *dsn, *verbose); err != nil {
+		fmt.Fprintln(os.Stderr, "migrate:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: migrate [-driver postgres|sqlite] [-dsn DSN] [-v] up|down|status")
+	flag.PrintDefaults()
+}
+
+func run(ctx context.Context, command, driver, dsn string, verbose bool) error {
+	if dsn == "" {
+		return errors.New("no data source name: pass -dsn or set DATABASE_URL")
+	}
+
+	sqlDB, db, err := zrandom.NewDBConnection(zrandom.DBConfig{
+		Driver:         driver,
+		DataSourceName: dsn,
+		PoolMax:        1,
+		PrintQueries:   verbose,
+	})
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	switch command {
+	case "up":
+		applied, err := migrations.Up(ctx, db)
+		printNames("applied", applied)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	case "down":
+		rolledBack, err := migrations.Down(ctx, db)
+		printNames("rolled back", rolledBack)
+		if err != nil {
+			return err
+		}
+	case "status":
+		return printStatus(ctx, db)
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+
+	return nil
+}
+
+func printNames(verb string, names []string) {
+	for _, name := range names {
+		fmt.Println(verb, name)
+	}
+}
+
+func printStatus(ctx context.Context, db *bun.DB) error {
+	statuses, err := migrations.Status(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tGROUP\tMIGRATED AT")
+	pending := 0
+	for _, s := range statuses {
+		if !s.Applied {
+			pending++
+			fmt.Fprintf(w, "%s\t%s\tpending\t-\t-\n", s.Name, s.Comment)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\tapplied\t%d\t%s\n", s.Name, s.Comment, s.GroupID, s.MigratedAt.UTC().Format(time.RFC3339))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d applied, %d pending\n", len(statuses)-pending, pending)
+	return nil
+}
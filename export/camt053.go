@@ -0,0 +1,333 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+)
+
+// camt053Namespace is the ISO 20022 BankToCustomerStatement schema version produced
+const camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+
+// ISO 20022 date formats
+const (
+	isoDate     = "2006-01-02"
+	isoDateTime = "2006-01-02T15:04:05"
+)
+
+// Camt053Exporter renders a statement as an ISO 20022 camt.053 BankToCustomerStatement
+type Camt053Exporter struct {
+	ServicerName string    // Account servicer name placed in the message (optional)
+	CreatedAt    time.Time // Message creation time (now when zero)
+}
+
+// NewCamt053Exporter creates a Camt053Exporter
+func NewCamt053Exporter(servicerName string) *Camt053Exporter {
+	return &Camt053Exporter{ServicerName: servicerName}
+}
+
+// camt.053 document structure (only the elements we populate)
+type camtDocument struct {
+	XMLName xml.Name      `xml:"Document"`
+	Xmlns   string        `xml:"xmlns,attr"`
+	Stmt    camtBkToCstmr `xml:"BkToCstmrStmt"`
+}
+
+type camtBkToCstmr struct {
+	GrpHdr camtGrpHdr  `xml:"GrpHdr"`
+	Stmt   camtStmtDoc `xml:"Stmt"`
+}
+
+type camtGrpHdr struct {
+	MsgID   string `xml:"MsgId"`
+	CreDtTm string `xml:"CreDtTm"`
+}
+
+type camtStmtDoc struct {
+	ID        string        `xml:"Id"`
+	CreDtTm   string        `xml:"CreDtTm"`
+	FrToDt    camtFrToDt    `xml:"FrToDt"`
+	Acct      camtAcct      `xml:"Acct"`
+	Bal       []camtBal     `xml:"Bal"`
+	TxsSummry camtTxsSummry `xml:"TxsSummry"`
+	Ntry      []camtEntry   `xml:"Ntry"`
+	AddtlInf  string        `xml:"AddtlStmtInf,omitempty"`
+}
+
+type camtFrToDt struct {
+	FrDtTm string `xml:"FrDtTm"`
+	ToDtTm string `xml:"ToDtTm"`
+}
+
+type camtAcct struct {
+	ID   camtAcctID    `xml:"Id"`
+	Ccy  string        `xml:"Ccy"`
+	Nm   string        `xml:"Nm,omitempty"`
+	Ownr *camtParty    `xml:"Ownr,omitempty"`
+	Svcr *camtServicer `xml:"Svcr,omitempty"`
+}
+
+type camtAcctID struct {
+	Othr struct {
+		ID string `xml:"Id"`
+	} `xml:"Othr"`
+}
+
+type camtParty struct {
+	PstlAdr struct {
+		AdrLine string `xml:"AdrLine"`
+	} `xml:"PstlAdr"`
+}
+
+type camtServicer struct {
+	FinInstnID struct {
+		Nm string `xml:"Nm"`
+	} `xml:"FinInstnId"`
+}
+
+type camtAmount struct {
+	Ccy   string `xml:"Ccy,attr"`
+	Value string `xml:",chardata"`
+}
+
+type camtBal struct {
+	Tp struct {
+		CdOrPrtry struct {
+			Cd string `xml:"Cd"`
+		} `xml:"CdOrPrtry"`
+	} `xml:"Tp"`
+	Amt       camtAmount `xml:"Amt"`
+	CdtDbtInd string     `xml:"CdtDbtInd"`
+	Dt        camtDate   `xml:"Dt"`
+}
+
+type camtDate struct {
+	Dt string `xml:"Dt"`
+}
+
+type camtTxsSummry struct {
+	TtlNtries    camtTotals `xml:"TtlNtries"`
+	TtlCdtNtries camtTotals `xml:"TtlCdtNtries"`
+	TtlDbtNtries camtTotals `xml:"TtlDbtNtries"`
+}
+
+type camtTotals struct {
+	NbOfNtries int    `xml:"NbOfNtries"`
+	Sum        string `xml:"Sum"`
+}
+
+type camtEntry struct {
+	NtryRef     string       `xml:"NtryRef,omitempty"`
+	Amt         camtAmount   `xml:"Amt"`
+	CdtDbtInd   string       `xml:"CdtDbtInd"`
+	Sts         string       `xml:"Sts"`
+	BookgDt     *camtDate    `xml:"BookgDt,omitempty"`
+	ValDt       camtDate     `xml:"ValDt"`
+	AcctSvcrRef string       `xml:"AcctSvcrRef,omitempty"`
+	BkTxCd      camtBkTxCd   `xml:"BkTxCd"`
+	Chrgs       *camtCharges `xml:"Chrgs,omitempty"`
+	NtryDtls    camtNtryDtls `xml:"NtryDtls"`
+	AddtlInf    string       `xml:"AddtlNtryInf,omitempty"`
+}
+
+type camtBkTxCd struct {
+	Prtry struct {
+		Cd string `xml:"Cd"`
+	} `xml:"Prtry"`
+}
+
+type camtCharges struct {
+	Amt camtAmount `xml:"Amt"`
+}
+
+type camtNtryDtls struct {
+	TxDtls struct {
+		Refs struct {
+			AcctSvcrRef string `xml:"AcctSvcrRef,omitempty"`
+			EndToEndID  string `xml:"EndToEndId"`
+		} `xml:"Refs"`
+	} `xml:"TxDtls"`
+}
+
+// Export writes the statement as camt.053 XML to w
+func (e *Camt053Exporter) Export(w io.Writer, stmt *types.AccountStatement) error {
+	if stmt == nil {
+		return ErrNilStatement
+	}
+
+	entries, err := parseEntries(stmt)
+	if err != nil {
+		return err
+	}
+
+	createdAt := e.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now().UTC()
+	}
+	ccy := stmt.AccountCurrency
+	precision := statementPrecision(stmt)
+	stmtID := fmt.Sprintf("%s-%s-%s", stmt.AccountNumber, stmt.StartDate.Format("20060102"), stmt.EndDate.Format("20060102"))
+
+	doc := camtDocument{Xmlns: camt053Namespace}
+	doc.Stmt.GrpHdr = camtGrpHdr{
+		MsgID:   "STMT-" + stmtID,
+		CreDtTm: createdAt.Format(isoDateTime),
+	}
+
+	s := &doc.Stmt.Stmt
+	s.ID = stmtID
+	s.CreDtTm = createdAt.Format(isoDateTime)
+	s.FrToDt = camtFrToDt{FrDtTm: stmt.StartDate.Format(isoDateTime), ToDtTm: stmt.EndDate.Format(isoDateTime)}
+	s.Acct.ID.Othr.ID = stmt.AccountNumber
+	s.Acct.Ccy = ccy
+	s.Acct.Nm = stmt.AccountName
+	if stmt.AccountHolderAddress != "" {
+		s.Acct.Ownr = &camtParty{}
+		s.Acct.Ownr.PstlAdr.AdrLine = stmt.AccountHolderAddress
+	}
+	if e.ServicerName != "" {
+		s.Acct.Svcr = &camtServicer{}
+		s.Acct.Svcr.FinInstnID.Nm = e.ServicerName
+	}
+
+	s.Bal = []camtBal{
+		camtBalance("OPBD", stmt.Summary.OpeningBalance, ccy, precision, stmt.StartDate),
+		camtBalance("CLBD", stmt.Summary.ClosingBalance, ccy, precision, stmt.EndDate),
+	}
+
+	credits, debits := decimal.Zero, decimal.Zero
+	var creditCount, debitCount int
+	for _, en := range entries {
+		indicator := "DBIT"
+		if en.isCredit {
+			indicator = "CRDT"
+			credits = credits.Add(en.bookedAmount)
+			creditCount++
+		} else {
+			debits = debits.Add(en.bookedAmount)
+			debitCount++
+		}
+
+		ntry := camtEntry{
+			NtryRef:     en.line.Reference,
+			Amt:         camtAmount{Ccy: ccy, Value: en.bookedAmount.StringFixed(precision)},
+			CdtDbtInd:   indicator,
+			Sts:         camtStatus(en.line.Status),
+			BookgDt:     &camtDate{Dt: en.bookingDate.Format(isoDate)},
+			ValDt:       camtDate{Dt: en.bookingDate.Format(isoDate)},
+			AcctSvcrRef: en.line.Reference,
+			AddtlInf:    en.line.Description,
+		}
+		ntry.BkTxCd.Prtry.Cd = "WALLET"
+		if en.fee.IsPositive() {
+			ntry.Chrgs = &camtCharges{Amt: camtAmount{Ccy: ccy, Value: en.fee.StringFixed(precision)}}
+		}
+		ntry.NtryDtls.TxDtls.Refs.AcctSvcrRef = en.line.Reference
+		ntry.NtryDtls.TxDtls.Refs.EndToEndID = endToEndID(en.line.ExternalRef)
+
+		s.Ntry = append(s.Ntry, ntry)
+	}
+
+	// Movements held for review go out as pending, without a booking date,
+	// and stay out of the booked totals
+	for _, line := range stmt.PendingTransactions {
+		ntry, err := camtPendingEntry(line, ccy, precision)
+		if err != nil {
+			return err
+		}
+		s.Ntry = append(s.Ntry, ntry)
+	}
+
+	s.TxsSummry = camtTxsSummry{
+		TtlNtries:    camtTotals{NbOfNtries: len(entries), Sum: credits.Add(debits).StringFixed(precision)},
+		TtlCdtNtries: camtTotals{NbOfNtries: creditCount, Sum: credits.StringFixed(precision)},
+		TtlDbtNtries: camtTotals{NbOfNtries: debitCount, Sum: debits.StringFixed(precision)},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// ContentType returns the MIME type of the output
+func (e *Camt053Exporter) ContentType() string { return "application/xml" }
+
+// FileExtension returns the file extension of the output
+func (e *Camt053Exporter) FileExtension() string { return "xml" }
+
+// camtBalance builds a balance element; negative balances are reported as debits
+func camtBalance(code string, amount decimal.Decimal, ccy string, precision int32, at time.Time) camtBal {
+	bal := camtBal{
+		Amt:       camtAmount{Ccy: ccy, Value: amount.Abs().StringFixed(precision)},
+		CdtDbtInd: "CRDT",
+		Dt:        camtDate{Dt: at.Format(isoDate)},
+	}
+	if amount.IsNegative() {
+		bal.CdtDbtInd = "DBIT"
+	}
+	bal.Tp.CdOrPrtry.Cd = code
+	return bal
+}
+
+// camtStatus maps a statement line's transaction status to an entry status.
+// Only rows held for review are pending; completed and reversed rows, and
+// lines without a status, are booked.
+func camtStatus(status string) string {
+	if status == string(types.StatusPending) {
+		return "PDNG"
+	}
+	return "BOOK"
+}
+
+// camtPendingEntry builds the entry of a line held for review
+func camtPendingEntry(line types.TransactionStatement, ccy string, precision int32) (camtEntry, error) {
+	date, err := time.Parse(types.StatementDateLayout, line.Date)
+	if err != nil {
+		return camtEntry{}, fmt.Errorf("%w %s: date: %v", ErrInvalidLineRow, line.Reference, err)
+	}
+	credit, err := parseAmount(line.Credit)
+	if err != nil {
+		return camtEntry{}, fmt.Errorf("%w %s: credit: %v", ErrInvalidLineRow, line.Reference, err)
+	}
+	debit, err := parseAmount(line.Debit)
+	if err != nil {
+		return camtEntry{}, fmt.Errorf("%w %s: debit: %v", ErrInvalidLineRow, line.Reference, err)
+	}
+
+	amount, indicator := debit, "DBIT"
+	if credit.IsPositive() {
+		amount, indicator = credit, "CRDT"
+	}
+	ntry := camtEntry{
+		NtryRef:     line.Reference,
+		Amt:         camtAmount{Ccy: ccy, Value: amount.StringFixed(precision)},
+		CdtDbtInd:   indicator,
+		Sts:         "PDNG",
+		ValDt:       camtDate{Dt: date.Format(isoDate)},
+		AcctSvcrRef: line.Reference,
+		AddtlInf:    line.Description,
+	}
+	ntry.BkTxCd.Prtry.Cd = "WALLET"
+	ntry.NtryDtls.TxDtls.Refs.AcctSvcrRef = line.Reference
+	ntry.NtryDtls.TxDtls.Refs.EndToEndID = endToEndID(line.ExternalRef)
+	return ntry, nil
+}
+
+// endToEndID returns the external reference or the ISO placeholder when absent
+func endToEndID(ref string) string {
+	if ref == "" {
+		return "NOTPROVIDED"
+	}
+	return ref
+}
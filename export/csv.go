@@ -0,0 +1,115 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/otyang/waas-go/types"
+)
+
+// CSV column names
+const (
+	ColumnDate        = "date"
+	ColumnReference   = "reference"
+	ColumnExternalRef = "externalRef"
+	ColumnDescription = "description"
+	ColumnCredit      = "credit"
+	ColumnDebit       = "debit"
+	ColumnFee         = "fee"
+	ColumnBalance     = "balance"
+	ColumnCurrency    = "currency"
+)
+
+// DefaultCSVColumns is the column set used when none is configured
+var DefaultCSVColumns = []string{
+	ColumnDate, ColumnReference, ColumnDescription, ColumnCredit, ColumnDebit, ColumnFee, ColumnBalance,
+}
+
+// csvColumnTitles holds the header title and value accessor for each column
+var csvColumnTitles = map[string]struct {
+	title string
+	value func(stmt *types.AccountStatement, line types.TransactionStatement) string
+}{
+	ColumnDate:        {"Date", func(_ *types.AccountStatement, l types.TransactionStatement) string { return l.Date }},
+	ColumnReference:   {"Reference", func(_ *types.AccountStatement, l types.TransactionStatement) string { return l.Reference }},
+	ColumnExternalRef: {"External Reference", func(_ *types.AccountStatement, l types.TransactionStatement) string { return l.ExternalRef }},
+	ColumnDescription: {"Description", func(_ *types.AccountStatement, l types.TransactionStatement) string { return l.Description }},
+	ColumnCredit:      {"Credit", func(_ *types.AccountStatement, l types.TransactionStatement) string { return l.Credit }},
+	ColumnDebit:       {"Debit", func(_ *types.AccountStatement, l types.TransactionStatement) string { return l.Debit }},
+	ColumnFee:         {"Fee", func(_ *types.AccountStatement, l types.TransactionStatement) string { return l.Fee }},
+	ColumnBalance:     {"Balance", func(_ *types.AccountStatement, l types.TransactionStatement) string { return l.Balance }},
+	ColumnCurrency:    {"Currency", func(s *types.AccountStatement, _ types.TransactionStatement) string { return s.AccountCurrency }},
+}
+
+// CSVExporter writes statement lines as CSV with a configurable column set
+type CSVExporter struct {
+	Columns   []string // Columns to write, in order (DefaultCSVColumns when empty)
+	Delimiter rune     // Field delimiter (comma when zero)
+	NoHeader  bool     // Omit the header row
+}
+
+// NewCSVExporter creates a CSVExporter for the given columns
+func NewCSVExporter(columns ...string) (*CSVExporter, error) {
+	for _, column := range columns {
+		if _, ok := csvColumnTitles[column]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownColumn, column)
+		}
+	}
+	return &CSVExporter{Columns: columns}, nil
+}
+
+// Export writes the statement lines to w
+func (e *CSVExporter) Export(w io.Writer, stmt *types.AccountStatement) error {
+	if stmt == nil {
+		return ErrNilStatement
+	}
+
+	columns := e.Columns
+	if len(columns) == 0 {
+		columns = DefaultCSVColumns
+	}
+
+	writer := csv.NewWriter(w)
+	if e.Delimiter != 0 {
+		writer.Comma = e.Delimiter
+	}
+
+	if !e.NoHeader {
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			def, ok := csvColumnTitles[column]
+			if !ok {
+				return fmt.Errorf("%w: %s", ErrUnknownColumn, column)
+			}
+			header[i] = def.title
+		}
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+	}
+
+	for _, line := range stmt.Transactions {
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			def, ok := csvColumnTitles[column]
+			if !ok {
+				return fmt.Errorf("%w: %s", ErrUnknownColumn, column)
+			}
+			record[i] = strings.TrimSpace(def.value(stmt, line))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// ContentType returns the MIME type of the output
+func (e *CSVExporter) ContentType() string { return "text/csv" }
+
+// FileExtension returns the file extension of the output
+func (e *CSVExporter) FileExtension() string { return "csv" }
@@ -0,0 +1,116 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+)
+
+// Error definitions for statement export
+var (
+	ErrNilStatement   = errors.New("statement cannot be nil")
+	ErrUnknownColumn  = errors.New("unknown statement column")
+	ErrInvalidLineRow = errors.New("invalid statement line")
+)
+
+// StatementExporter renders an AccountStatement into a downloadable format
+type StatementExporter interface {
+	// Export writes the statement to w
+	Export(w io.Writer, stmt *types.AccountStatement) error
+	// ContentType returns the MIME type of the output
+	ContentType() string
+	// FileExtension returns the file extension of the output, without a dot
+	FileExtension() string
+}
+
+// entry is a statement line with its amounts parsed back into decimals
+type entry struct {
+	line          types.TransactionStatement
+	bookingDate   time.Time
+	credit        decimal.Decimal
+	debit         decimal.Decimal
+	fee           decimal.Decimal
+	balance       decimal.Decimal
+	bookedAmount  decimal.Decimal // Absolute change of the running balance
+	amount        decimal.Decimal // Principal amount of the line
+	chargedFee    decimal.Decimal // Fee actually reflected in the running balance
+	isCredit      bool
+	balanceBefore decimal.Decimal
+}
+
+// parseEntries converts formatted statement lines into entries. The booked amount
+// is derived from the running balance so fees are reflected exactly as posted.
+func parseEntries(stmt *types.AccountStatement) ([]entry, error) {
+	entries := make([]entry, 0, len(stmt.Transactions))
+	previous := stmt.Summary.OpeningBalance
+
+	for i, line := range stmt.Transactions {
+		e := entry{line: line, balanceBefore: previous}
+		var err error
+
+		if e.bookingDate, err = time.Parse(types.StatementDateLayout, line.Date); err != nil {
+			return nil, fmt.Errorf("%w %d: date: %v", ErrInvalidLineRow, i+1, err)
+		}
+		if e.credit, err = parseAmount(line.Credit); err != nil {
+			return nil, fmt.Errorf("%w %d: credit: %v", ErrInvalidLineRow, i+1, err)
+		}
+		if e.debit, err = parseAmount(line.Debit); err != nil {
+			return nil, fmt.Errorf("%w %d: debit: %v", ErrInvalidLineRow, i+1, err)
+		}
+		if e.fee, err = parseAmount(line.Fee); err != nil {
+			return nil, fmt.Errorf("%w %d: fee: %v", ErrInvalidLineRow, i+1, err)
+		}
+		if e.balance, err = parseAmount(line.Balance); err != nil {
+			return nil, fmt.Errorf("%w %d: balance: %v", ErrInvalidLineRow, i+1, err)
+		}
+
+		e.isCredit = e.credit.IsPositive()
+		e.bookedAmount = e.balance.Sub(previous).Abs()
+		previous = e.balance
+
+		// Credits are booked net of fees and debits gross of fees; a fee larger
+		// than a credit is not charged, so derive the fee from the balance change
+		e.amount = e.debit
+		e.chargedFee = e.bookedAmount.Sub(e.debit)
+		if e.isCredit {
+			e.amount = e.credit
+			e.chargedFee = e.credit.Sub(e.bookedAmount)
+		}
+		if e.chargedFee.IsNegative() {
+			e.chargedFee = decimal.Zero
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// parseAmount parses a formatted amount, treating empty strings as zero
+func parseAmount(s string) (decimal.Decimal, error) {
+	if s == "" {
+		return decimal.Zero, nil
+	}
+	return decimal.NewFromString(s)
+}
+
+// statementPrecision infers the number of decimal places used on the statement
+// from its formatted balances, defaulting to 2
+func statementPrecision(stmt *types.AccountStatement) int32 {
+	formatted := stmt.CurrentBalance
+	if len(stmt.Transactions) > 0 {
+		formatted = stmt.Transactions[0].Balance
+	}
+	if i := strings.IndexByte(formatted, '.'); i >= 0 {
+		return int32(len(formatted) - i - 1)
+	}
+	if formatted != "" {
+		return 0
+	}
+	return 2
+}
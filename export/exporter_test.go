@@ -0,0 +1,151 @@
+package export
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// testStatement builds a deterministic statement with credits, debits and fees
+func testStatement(rows int) *types.AccountStatement {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 23, 59, 59, 0, time.UTC)
+
+	wallet := &types.Wallet{
+		ID:               "wt_test0001",
+		CustomerID:       "cus_1",
+		CurrencyCode:     "EUR",
+		AvailableBalance: decimal.NewFromInt(1000),
+		LienBalance:      decimal.NewFromInt(25),
+		CreatedAt:        time.Date(2023, 6, 15, 0, 0, 0, 0, time.UTC),
+	}
+
+	balance := decimal.NewFromInt(1000)
+	builder := types.NewStatementBuilder(wallet, balance, start, end, 2)
+	builder.SetAccountHolderAddress("1 Canal Street, Amsterdam")
+
+	for i := 0; i < rows; i++ {
+		tx := types.TransactionHistory{
+			ID:                "txn_" + string(rune('a'+i%26)) + string(rune('a'+i/26)),
+			ExternalReference: "ext-" + string(rune('a'+i%26)),
+			Description:       "Payment (invoice) #" + string(rune('A'+i%26)),
+			Status:            types.StatusCompleted,
+			Amount:            decimal.NewFromFloat(12.5).Add(decimal.NewFromInt(int64(i))),
+			Fee:               decimal.Zero,
+			BalanceBefore:     balance,
+			CreatedAt:         start.Add(time.Duration(i+1) * 6 * time.Hour),
+		}
+		if i%3 == 2 {
+			tx.Type = types.TypeDebit
+			tx.Fee = decimal.NewFromFloat(0.5)
+			balance = balance.Sub(tx.Amount).Sub(tx.Fee)
+		} else {
+			tx.Type = types.TypeCredit
+			balance = balance.Add(tx.Amount)
+		}
+		tx.BalanceAfter = balance
+		builder.Add(tx)
+	}
+
+	stmt := builder.Build()
+	stmt.DatePrinted = "01-Feb-2024"
+	return &stmt
+}
+
+// assertGolden compares output against testdata/<name>, rewriting it with -update
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+
+	if *update {
+		assert.NoError(t, os.MkdirAll("testdata", 0o755))
+		assert.NoError(t, os.WriteFile(path, got, 0o644))
+	}
+
+	want, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+func TestExporters(t *testing.T) {
+	stmt := testStatement(5)
+
+	tests := []struct {
+		name     string
+		exporter StatementExporter
+		golden   string
+	}{
+		{"csv default columns", &CSVExporter{}, "statement.csv.golden"},
+		{"pdf", NewPDFExporter(), "statement.pdf.golden"},
+		{"camt053", &Camt053Exporter{ServicerName: "WaaS", CreatedAt: time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)}, "statement.camt053.golden"},
+		{"mt940", NewMT940Exporter("WAASNL2A"), "statement.mt940.golden"},
+		{"ofx", &OFXExporter{BankID: "WAAS", CreatedAt: time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)}, "statement.ofx.golden"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NoError(t, tt.exporter.Export(&buf, stmt))
+			assertGolden(t, tt.golden, buf.Bytes())
+		})
+	}
+
+	t.Run("nil statement", func(t *testing.T) {
+		for _, tt := range tests {
+			assert.ErrorIs(t, tt.exporter.Export(&bytes.Buffer{}, nil), ErrNilStatement)
+		}
+	})
+}
+
+func TestCSVExporterColumns(t *testing.T) {
+	stmt := testStatement(2)
+
+	exporter, err := NewCSVExporter(ColumnDate, ColumnCurrency, ColumnBalance)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, exporter.Export(&buf, stmt))
+	assert.Equal(t, "Date,Currency,Balance\n01-Jan-2024,EUR,1012.50\n01-Jan-2024,EUR,1026.00\n", buf.String())
+
+	_, err = NewCSVExporter("iban")
+	assert.ErrorIs(t, err, ErrUnknownColumn)
+}
+
+func TestPDFExporterPagination(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, NewPDFExporter().Export(&buf, testStatement(120)))
+
+	out := buf.String()
+	assert.True(t, strings.HasPrefix(out, "%PDF-1.4"))
+	assert.Equal(t, 3, strings.Count(out, "/Type /Page "))
+	assert.Contains(t, out, "(Page 3 of 3)")
+	assert.True(t, strings.HasSuffix(out, "%%EOF\n"))
+}
+
+func TestCamt053PendingEntries(t *testing.T) {
+	stmt := testStatement(2)
+	stmt.PendingTransactions = []types.TransactionStatement{{
+		Date: "20-Jan-2024", Reference: "txn_held", Description: "Held for review",
+		Credit: "500.00", Debit: "0.00", Fee: "0.00", Status: string(types.StatusPending),
+	}}
+
+	var buf bytes.Buffer
+	assert.NoError(t, (&Camt053Exporter{CreatedAt: time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)}).Export(&buf, stmt))
+	out := buf.String()
+
+	assert.Equal(t, 2, strings.Count(out, "<Sts>BOOK</Sts>"))
+	assert.Equal(t, 1, strings.Count(out, "<Sts>PDNG</Sts>"))
+	assert.Equal(t, 2, strings.Count(out, "<BookgDt>"), "pending entries have no booking date")
+	assert.Contains(t, out, "<NtryRef>txn_held</NtryRef>")
+	assert.Contains(t, out, "<NbOfNtries>2</NbOfNtries>", "pending entries stay out of the booked totals")
+}
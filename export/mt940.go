@@ -0,0 +1,339 @@
+package export
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+)
+
+// ErrInvalidMT940 is returned when an MT940 file cannot be parsed
+var ErrInvalidMT940 = errors.New("invalid MT940 statement")
+
+// MT940 field limits
+const (
+	mt940RefLength  = 16 // Length of :20: and :61: references
+	mt940InfoLength = 65 // Length of each :86: line
+	mt940InfoLines  = 6  // Maximum :86: lines
+	mt940DateLayout = "060102"
+)
+
+// MT940Exporter renders statements as SWIFT MT940 customer statement messages.
+// Each statement becomes one message, so wallets in different currencies can be
+// written to the same file with ExportMany.
+type MT940Exporter struct {
+	BankID string // Optional bank identifier prefixed to the :25: account field
+}
+
+// NewMT940Exporter creates an MT940Exporter
+func NewMT940Exporter(bankID string) *MT940Exporter {
+	return &MT940Exporter{BankID: bankID}
+}
+
+// Export writes a single statement as an MT940 message
+func (e *MT940Exporter) Export(w io.Writer, stmt *types.AccountStatement) error {
+	return e.ExportMany(w, []*types.AccountStatement{stmt})
+}
+
+// ExportMany writes one MT940 message per statement
+func (e *MT940Exporter) ExportMany(w io.Writer, stmts []*types.AccountStatement) error {
+	bw := bufio.NewWriter(w)
+
+	for i, stmt := range stmts {
+		if stmt == nil {
+			return ErrNilStatement
+		}
+		if err := e.writeMessage(bw, stmt, i+1); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// writeMessage writes a single MT940 message
+func (e *MT940Exporter) writeMessage(w *bufio.Writer, stmt *types.AccountStatement, sequence int) error {
+	entries, err := parseEntries(stmt)
+	if err != nil {
+		return err
+	}
+
+	precision := statementPrecision(stmt)
+	ccy := stmt.AccountCurrency
+
+	account := stmt.AccountNumber
+	if e.BankID != "" {
+		account = e.BankID + "/" + account
+	}
+
+	fmt.Fprintf(w, ":20:%s\r\n", truncate("STMT"+stmt.EndDate.Format(mt940DateLayout), mt940RefLength))
+	fmt.Fprintf(w, ":25:%s\r\n", truncate(swiftText(account), 35))
+	fmt.Fprintf(w, ":28C:%05d/001\r\n", sequence)
+	fmt.Fprintf(w, ":60F:%s\r\n", mt940Balance(stmt.Summary.OpeningBalance, stmt.StartDate, ccy, precision))
+
+	for _, en := range entries {
+		mark := "D"
+		if en.isCredit {
+			mark = "C"
+		}
+		date := en.bookingDate.Format(mt940DateLayout)
+		entryDate := en.bookingDate.Format("0102")
+		bankRef := truncate(swiftText(en.line.Reference), mt940RefLength)
+
+		// Principal movement
+		fmt.Fprintf(w, ":61:%s%s%s%sNTRF%s//%s\r\n",
+			date, entryDate, mark, mt940Amount(en.amount, precision), customerRef(en.line.ExternalRef), bankRef)
+		info := wrap(swiftText(en.line.Description), mt940InfoLength, mt940InfoLines)
+		fmt.Fprintf(w, ":86:%s\r\n", info[0])
+		for _, line := range info[1:] {
+			fmt.Fprintf(w, "%s\r\n", line)
+		}
+
+		// Fees are booked as a separate charge entry
+		if en.chargedFee.IsPositive() {
+			fmt.Fprintf(w, ":61:%s%sD%sNCHG%s//%s\r\n",
+				date, entryDate, mt940Amount(en.chargedFee, precision), customerRef(en.line.ExternalRef), bankRef)
+			fmt.Fprintf(w, ":86:FEE\r\n")
+		}
+	}
+
+	closing := mt940Balance(stmt.Summary.ClosingBalance, stmt.EndDate, ccy, precision)
+	fmt.Fprintf(w, ":62F:%s\r\n", closing)
+	fmt.Fprintf(w, ":64:%s\r\n", closing)
+	_, err = w.WriteString("-\r\n")
+	return err
+}
+
+// ContentType returns the MIME type of the output
+func (e *MT940Exporter) ContentType() string { return "text/plain" }
+
+// FileExtension returns the file extension of the output
+func (e *MT940Exporter) FileExtension() string { return "sta" }
+
+// mt940Balance formats a balance field value (e.g. C240131EUR1043,00)
+func mt940Balance(amount decimal.Decimal, at time.Time, ccy string, precision int32) string {
+	mark := "C"
+	if amount.IsNegative() {
+		mark = "D"
+	}
+	return mark + at.Format(mt940DateLayout) + ccy + mt940Amount(amount.Abs(), precision)
+}
+
+// mt940Amount formats an amount with a decimal comma
+func mt940Amount(amount decimal.Decimal, precision int32) string {
+	return strings.Replace(amount.StringFixed(precision), ".", ",", 1)
+}
+
+// customerRef returns the :61: customer reference, NONREF when absent
+func customerRef(ref string) string {
+	ref = truncate(swiftText(ref), mt940RefLength)
+	if ref == "" {
+		return "NONREF"
+	}
+	return ref
+}
+
+// swiftText replaces characters outside the SWIFT X character set with spaces
+func swiftText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case strings.ContainsRune("/-?:().,'+ ", r):
+			b.WriteRune(r)
+		default:
+			b.WriteByte(' ')
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// wrap splits s into at most maxLines chunks of width characters
+func wrap(s string, width, maxLines int) []string {
+	var lines []string
+	for len(s) > 0 && len(lines) < maxLines {
+		n := width
+		if len(s) < n {
+			n = len(s)
+		}
+		lines = append(lines, s[:n])
+		s = s[n:]
+	}
+	if len(lines) == 0 {
+		return []string{""}
+	}
+	return lines
+}
+
+// MT940Statement is a parsed MT940 message
+type MT940Statement struct {
+	Reference      string             // :20: transaction reference
+	Account        string             // :25: account identification
+	Sequence       string             // :28C: statement/sequence number
+	Currency       string             // Currency from the opening balance
+	OpeningBalance decimal.Decimal    // :60F: signed opening balance
+	OpeningDate    time.Time          // :60F: date
+	ClosingBalance decimal.Decimal    // :62F: signed closing balance
+	ClosingDate    time.Time          // :62F: date
+	Entries        []MT940Transaction // :61:/:86: pairs
+}
+
+// MT940Transaction is a parsed :61: statement line with its :86: information
+type MT940Transaction struct {
+	ValueDate         time.Time       // Value date
+	Credit            bool            // True for C, false for D
+	Amount            decimal.Decimal // Unsigned amount
+	TypeCode          string          // Transaction type (e.g. NTRF, NCHG)
+	CustomerReference string          // Reference for the account owner
+	BankReference     string          // Reference of the account servicer
+	Information       string          // :86: information to account owner
+}
+
+var (
+	mt940TagPattern     = regexp.MustCompile(`^:(\d{2}[A-Z]?):(.*)$`)
+	mt940BalancePattern = regexp.MustCompile(`^([CD])(\d{6})([A-Z]{3})(\d+,\d*)$`)
+	mt940LinePattern    = regexp.MustCompile(`^(\d{6})(\d{4})?(R?[CD])([A-Z])?(\d+,\d*)([NSF][A-Z0-9]{3})(.*?)(?://(.*))?$`)
+)
+
+// ParseMT940 parses MT940 messages produced by MT940Exporter or a bank
+func ParseMT940(r io.Reader) ([]MT940Statement, error) {
+	var (
+		statements []MT940Statement
+		current    *MT940Statement
+		lastTag    string
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		if line == "-" {
+			if current != nil {
+				statements = append(statements, *current)
+			}
+			current, lastTag = nil, ""
+			continue
+		}
+
+		match := mt940TagPattern.FindStringSubmatch(line)
+		if match == nil {
+			// Continuation of the previous field
+			if lastTag == "86" && current != nil && len(current.Entries) > 0 {
+				current.Entries[len(current.Entries)-1].Information += line
+				continue
+			}
+			return nil, fmt.Errorf("%w: unexpected line %q", ErrInvalidMT940, line)
+		}
+
+		tag, value := match[1], match[2]
+		if current == nil {
+			current = &MT940Statement{}
+		}
+
+		switch tag {
+		case "20":
+			current.Reference = value
+		case "25":
+			current.Account = value
+		case "28C":
+			current.Sequence = value
+		case "60F", "60M":
+			amount, date, ccy, err := parseMT940Balance(value)
+			if err != nil {
+				return nil, err
+			}
+			current.OpeningBalance, current.OpeningDate, current.Currency = amount, date, ccy
+		case "62F", "62M":
+			amount, date, _, err := parseMT940Balance(value)
+			if err != nil {
+				return nil, err
+			}
+			current.ClosingBalance, current.ClosingDate = amount, date
+		case "61":
+			entry, err := parseMT940Line(value)
+			if err != nil {
+				return nil, err
+			}
+			current.Entries = append(current.Entries, entry)
+		case "86":
+			if len(current.Entries) > 0 {
+				current.Entries[len(current.Entries)-1].Information = value
+			}
+		}
+		lastTag = tag
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if current != nil {
+		return nil, fmt.Errorf("%w: missing message terminator", ErrInvalidMT940)
+	}
+
+	return statements, nil
+}
+
+// parseMT940Balance parses a balance field into a signed amount, date and currency
+func parseMT940Balance(value string) (decimal.Decimal, time.Time, string, error) {
+	match := mt940BalancePattern.FindStringSubmatch(value)
+	if match == nil {
+		return decimal.Zero, time.Time{}, "", fmt.Errorf("%w: balance %q", ErrInvalidMT940, value)
+	}
+
+	date, err := time.Parse(mt940DateLayout, match[2])
+	if err != nil {
+		return decimal.Zero, time.Time{}, "", fmt.Errorf("%w: balance date %q", ErrInvalidMT940, match[2])
+	}
+	amount, err := decimal.NewFromString(strings.Replace(match[4], ",", ".", 1))
+	if err != nil {
+		return decimal.Zero, time.Time{}, "", fmt.Errorf("%w: balance amount %q", ErrInvalidMT940, match[4])
+	}
+	if match[1] == "D" {
+		amount = amount.Neg()
+	}
+
+	return amount, date, match[3], nil
+}
+
+// parseMT940Line parses a :61: statement line
+func parseMT940Line(value string) (MT940Transaction, error) {
+	match := mt940LinePattern.FindStringSubmatch(value)
+	if match == nil {
+		return MT940Transaction{}, fmt.Errorf("%w: statement line %q", ErrInvalidMT940, value)
+	}
+
+	date, err := time.Parse(mt940DateLayout, match[1])
+	if err != nil {
+		return MT940Transaction{}, fmt.Errorf("%w: value date %q", ErrInvalidMT940, match[1])
+	}
+	amount, err := decimal.NewFromString(strings.Replace(match[5], ",", ".", 1))
+	if err != nil {
+		return MT940Transaction{}, fmt.Errorf("%w: amount %q", ErrInvalidMT940, match[5])
+	}
+
+	return MT940Transaction{
+		ValueDate:         date,
+		Credit:            strings.HasSuffix(match[3], "C"),
+		Amount:            amount,
+		TypeCode:          match[6],
+		CustomerReference: match[7],
+		BankReference:     match[8],
+	}, nil
+}
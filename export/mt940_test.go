@@ -0,0 +1,90 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// usdStatement returns a second statement in another currency for multi-currency files
+func usdStatement() *types.AccountStatement {
+	stmt := testStatement(2)
+	stmt.AccountNumber = "wt_test0002"
+	stmt.AccountCurrency = "USD"
+	return stmt
+}
+
+func TestMT940RoundTrip(t *testing.T) {
+	eur := testStatement(5)
+	usd := usdStatement()
+
+	var buf bytes.Buffer
+	assert.NoError(t, NewMT940Exporter("WAASNL2A").ExportMany(&buf, []*types.AccountStatement{eur, usd}))
+
+	parsed, err := ParseMT940(&buf)
+	assert.NoError(t, err)
+	assert.Len(t, parsed, 2)
+
+	got := parsed[0]
+	assert.Equal(t, "WAASNL2A/wt test0001", got.Account)
+	assert.Equal(t, "00001/001", got.Sequence)
+	assert.Equal(t, "EUR", got.Currency)
+	assert.True(t, got.OpeningBalance.Equal(eur.Summary.OpeningBalance))
+	assert.True(t, got.ClosingBalance.Equal(eur.Summary.ClosingBalance))
+
+	// 5 movements plus one charge line for the debit with a fee
+	assert.Len(t, got.Entries, 6)
+
+	// Entries sum to the balance movement
+	net := decimal.Zero
+	for _, entry := range got.Entries {
+		if entry.Credit {
+			net = net.Add(entry.Amount)
+		} else {
+			net = net.Sub(entry.Amount)
+		}
+	}
+	assert.True(t, got.OpeningBalance.Add(net).Equal(got.ClosingBalance))
+
+	first := got.Entries[0]
+	assert.True(t, first.Credit)
+	assert.Equal(t, "12.5", first.Amount.String())
+	assert.Equal(t, "NTRF", first.TypeCode)
+	assert.Equal(t, "ext-a", first.CustomerReference)
+	assert.Equal(t, "txn aa", first.BankReference)
+	assert.Equal(t, "Payment (invoice)  A", first.Information)
+
+	debit, fee := got.Entries[2], got.Entries[3]
+	assert.False(t, debit.Credit)
+	assert.Equal(t, "14.5", debit.Amount.String())
+	assert.Equal(t, "NCHG", fee.TypeCode)
+	assert.Equal(t, "0.5", fee.Amount.String())
+
+	assert.Equal(t, "USD", parsed[1].Currency)
+	assert.Equal(t, "00002/001", parsed[1].Sequence)
+	assert.Len(t, parsed[1].Entries, 2)
+}
+
+func TestMT940LongDescription(t *testing.T) {
+	stmt := testStatement(1)
+	stmt.Transactions[0].Description = strings.Repeat("x", 500)
+
+	var buf bytes.Buffer
+	assert.NoError(t, NewMT940Exporter("").Export(&buf, stmt))
+
+	parsed, err := ParseMT940(&buf)
+	assert.NoError(t, err)
+	assert.Len(t, parsed[0].Entries[0].Information, mt940InfoLength*mt940InfoLines)
+}
+
+func TestParseMT940Invalid(t *testing.T) {
+	_, err := ParseMT940(strings.NewReader(":20:STMT\r\n:60F:X240101EUR1,00\r\n-\r\n"))
+	assert.ErrorIs(t, err, ErrInvalidMT940)
+
+	_, err = ParseMT940(strings.NewReader(":20:STMT\r\n"))
+	assert.ErrorIs(t, err, ErrInvalidMT940)
+}
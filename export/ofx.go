@@ -0,0 +1,295 @@
+package export
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+)
+
+// ErrInvalidOFX is returned when an OFX document cannot be parsed
+var ErrInvalidOFX = errors.New("invalid OFX document")
+
+// OFX header and formats
+const (
+	ofxHeader         = `<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>`
+	ofxDateLayout     = "20060102"
+	ofxDateTimeLayout = "20060102150405"
+	ofxNameLength     = 32
+)
+
+// OFX transaction types
+const (
+	OFXTypeCredit = "CREDIT"
+	OFXTypeDebit  = "DEBIT"
+	OFXTypeFee    = "FEE"
+)
+
+// OFXExporter renders statements as OFX 2.x bank statement responses.
+// Each statement becomes its own STMTTRNRS with its own currency, so wallets
+// in different currencies can be written to the same file with ExportMany.
+type OFXExporter struct {
+	BankID    string    // Bank identifier placed in BANKACCTFROM
+	CreatedAt time.Time // Server time placed in SONRS (now when zero)
+}
+
+// NewOFXExporter creates an OFXExporter
+func NewOFXExporter(bankID string) *OFXExporter {
+	return &OFXExporter{BankID: bankID}
+}
+
+// OFX document structure, shared by the exporter and ParseOFX
+type ofxDocument struct {
+	XMLName xml.Name `xml:"OFX"`
+	Signon  struct {
+		SONRS struct {
+			Status   ofxStatus `xml:"STATUS"`
+			DTServer string    `xml:"DTSERVER"`
+			Language string    `xml:"LANGUAGE"`
+		} `xml:"SONRS"`
+	} `xml:"SIGNONMSGSRSV1"`
+	Bank struct {
+		Responses []ofxStmtTrnRs `xml:"STMTTRNRS"`
+	} `xml:"BANKMSGSRSV1"`
+}
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxStmtTrnRs struct {
+	TrnUID string    `xml:"TRNUID"`
+	Status ofxStatus `xml:"STATUS"`
+	StmtRs struct {
+		CurDef  string `xml:"CURDEF"`
+		Account struct {
+			BankID   string `xml:"BANKID"`
+			AcctID   string `xml:"ACCTID"`
+			AcctType string `xml:"ACCTTYPE"`
+		} `xml:"BANKACCTFROM"`
+		TranList struct {
+			DTStart      string       `xml:"DTSTART"`
+			DTEnd        string       `xml:"DTEND"`
+			Transactions []ofxStmtTrn `xml:"STMTTRN"`
+		} `xml:"BANKTRANLIST"`
+		LedgerBal ofxBalance `xml:"LEDGERBAL"`
+		AvailBal  ofxBalance `xml:"AVAILBAL"`
+	} `xml:"STMTRS"`
+}
+
+type ofxStmtTrn struct {
+	TrnType  string `xml:"TRNTYPE"`
+	DTPosted string `xml:"DTPOSTED"`
+	TrnAmt   string `xml:"TRNAMT"`
+	FITID    string `xml:"FITID"`
+	RefNum   string `xml:"REFNUM,omitempty"`
+	Name     string `xml:"NAME,omitempty"`
+	Memo     string `xml:"MEMO,omitempty"`
+}
+
+type ofxBalance struct {
+	BalAmt string `xml:"BALAMT"`
+	DTAsOf string `xml:"DTASOF"`
+}
+
+// Export writes a single statement as an OFX document
+func (e *OFXExporter) Export(w io.Writer, stmt *types.AccountStatement) error {
+	return e.ExportMany(w, []*types.AccountStatement{stmt})
+}
+
+// ExportMany writes all statements into one OFX document
+func (e *OFXExporter) ExportMany(w io.Writer, stmts []*types.AccountStatement) error {
+	createdAt := e.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now().UTC()
+	}
+
+	var doc ofxDocument
+	doc.Signon.SONRS.Status = ofxStatus{Code: 0, Severity: "INFO"}
+	doc.Signon.SONRS.DTServer = createdAt.Format(ofxDateTimeLayout)
+	doc.Signon.SONRS.Language = "ENG"
+
+	for _, stmt := range stmts {
+		if stmt == nil {
+			return ErrNilStatement
+		}
+		rs, err := e.statementResponse(stmt)
+		if err != nil {
+			return err
+		}
+		doc.Bank.Responses = append(doc.Bank.Responses, rs)
+	}
+
+	if _, err := io.WriteString(w, xml.Header+ofxHeader+"\n"); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// statementResponse maps one statement to an OFX STMTTRNRS aggregate
+func (e *OFXExporter) statementResponse(stmt *types.AccountStatement) (ofxStmtTrnRs, error) {
+	entries, err := parseEntries(stmt)
+	if err != nil {
+		return ofxStmtTrnRs{}, err
+	}
+	precision := statementPrecision(stmt)
+
+	var rs ofxStmtTrnRs
+	rs.TrnUID = stmt.AccountNumber + "-" + stmt.EndDate.Format(ofxDateLayout)
+	rs.Status = ofxStatus{Code: 0, Severity: "INFO"}
+	rs.StmtRs.CurDef = stmt.AccountCurrency
+	rs.StmtRs.Account.BankID = e.BankID
+	rs.StmtRs.Account.AcctID = stmt.AccountNumber
+	rs.StmtRs.Account.AcctType = "CHECKING"
+	rs.StmtRs.TranList.DTStart = stmt.StartDate.Format(ofxDateTimeLayout)
+	rs.StmtRs.TranList.DTEnd = stmt.EndDate.Format(ofxDateTimeLayout)
+
+	for _, en := range entries {
+		trn := ofxStmtTrn{
+			TrnType:  OFXTypeDebit,
+			DTPosted: en.bookingDate.Format(ofxDateLayout),
+			TrnAmt:   en.amount.Neg().StringFixed(precision),
+			FITID:    en.line.Reference,
+			RefNum:   en.line.ExternalRef,
+			Name:     truncate(en.line.Description, ofxNameLength),
+			Memo:     en.line.Description,
+		}
+		if en.isCredit {
+			trn.TrnType = OFXTypeCredit
+			trn.TrnAmt = en.amount.StringFixed(precision)
+		}
+		rs.StmtRs.TranList.Transactions = append(rs.StmtRs.TranList.Transactions, trn)
+
+		// Fees are reported as their own transaction so amounts sum to the balance change
+		if en.chargedFee.IsPositive() {
+			rs.StmtRs.TranList.Transactions = append(rs.StmtRs.TranList.Transactions, ofxStmtTrn{
+				TrnType:  OFXTypeFee,
+				DTPosted: en.bookingDate.Format(ofxDateLayout),
+				TrnAmt:   en.chargedFee.Neg().StringFixed(precision),
+				FITID:    en.line.Reference + "-FEE",
+				RefNum:   en.line.ExternalRef,
+				Name:     "Fee",
+				Memo:     "Fee: " + en.line.Description,
+			})
+		}
+	}
+
+	closing := stmt.Summary.ClosingBalance.StringFixed(precision)
+	asOf := stmt.EndDate.Format(ofxDateTimeLayout)
+	rs.StmtRs.LedgerBal = ofxBalance{BalAmt: closing, DTAsOf: asOf}
+	rs.StmtRs.AvailBal = ofxBalance{BalAmt: closing, DTAsOf: asOf}
+
+	return rs, nil
+}
+
+// ContentType returns the MIME type of the output
+func (e *OFXExporter) ContentType() string { return "application/x-ofx" }
+
+// FileExtension returns the file extension of the output
+func (e *OFXExporter) FileExtension() string { return "ofx" }
+
+// OFXStatement is a parsed OFX bank statement response
+type OFXStatement struct {
+	Currency       string           // CURDEF
+	BankID         string           // BANKID
+	AccountID      string           // ACCTID
+	Start          time.Time        // DTSTART
+	End            time.Time        // DTEND
+	LedgerBalance  decimal.Decimal  // LEDGERBAL/BALAMT
+	Transactions   []OFXTransaction // STMTTRN entries
+	TransactionUID string           // TRNUID
+}
+
+// OFXTransaction is a parsed OFX STMTTRN
+type OFXTransaction struct {
+	Type      string          // TRNTYPE
+	Posted    time.Time       // DTPOSTED
+	Amount    decimal.Decimal // Signed TRNAMT
+	FITID     string          // Financial institution transaction ID
+	Reference string          // REFNUM
+	Name      string          // NAME
+	Memo      string          // MEMO
+}
+
+// ParseOFX parses an OFX 2.x document produced by OFXExporter or a bank
+func ParseOFX(r io.Reader) ([]OFXStatement, error) {
+	var doc ofxDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOFX, err)
+	}
+
+	statements := make([]OFXStatement, 0, len(doc.Bank.Responses))
+	for _, rs := range doc.Bank.Responses {
+		st := OFXStatement{
+			Currency:       rs.StmtRs.CurDef,
+			BankID:         rs.StmtRs.Account.BankID,
+			AccountID:      rs.StmtRs.Account.AcctID,
+			TransactionUID: rs.TrnUID,
+		}
+
+		var err error
+		if st.Start, err = parseOFXDate(rs.StmtRs.TranList.DTStart); err != nil {
+			return nil, err
+		}
+		if st.End, err = parseOFXDate(rs.StmtRs.TranList.DTEnd); err != nil {
+			return nil, err
+		}
+		if st.LedgerBalance, err = decimal.NewFromString(rs.StmtRs.LedgerBal.BalAmt); err != nil {
+			return nil, fmt.Errorf("%w: ledger balance %q", ErrInvalidOFX, rs.StmtRs.LedgerBal.BalAmt)
+		}
+
+		for _, trn := range rs.StmtRs.TranList.Transactions {
+			posted, err := parseOFXDate(trn.DTPosted)
+			if err != nil {
+				return nil, err
+			}
+			amount, err := decimal.NewFromString(trn.TrnAmt)
+			if err != nil {
+				return nil, fmt.Errorf("%w: amount %q", ErrInvalidOFX, trn.TrnAmt)
+			}
+			st.Transactions = append(st.Transactions, OFXTransaction{
+				Type:      trn.TrnType,
+				Posted:    posted,
+				Amount:    amount,
+				FITID:     trn.FITID,
+				Reference: trn.RefNum,
+				Name:      trn.Name,
+				Memo:      trn.Memo,
+			})
+		}
+
+		statements = append(statements, st)
+	}
+
+	return statements, nil
+}
+
+// parseOFXDate parses OFX dates with or without a time part, ignoring any
+// bracketed timezone suffix
+func parseOFXDate(s string) (time.Time, error) {
+	for i, r := range s {
+		if r == '[' || r == '.' {
+			s = s[:i]
+			break
+		}
+	}
+	switch len(s) {
+	case len(ofxDateLayout):
+		return time.Parse(ofxDateLayout, s)
+	case len(ofxDateTimeLayout):
+		return time.Parse(ofxDateTimeLayout, s)
+	default:
+		return time.Time{}, fmt.Errorf("%w: date %q", ErrInvalidOFX, s)
+	}
+}
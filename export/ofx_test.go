@@ -0,0 +1,71 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOFXRoundTrip(t *testing.T) {
+	eur := testStatement(5)
+	usd := usdStatement()
+
+	var buf bytes.Buffer
+	exporter := &OFXExporter{BankID: "WAAS", CreatedAt: time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)}
+	assert.NoError(t, exporter.ExportMany(&buf, []*types.AccountStatement{eur, usd}))
+
+	parsed, err := ParseOFX(&buf)
+	assert.NoError(t, err)
+	assert.Len(t, parsed, 2)
+
+	got := parsed[0]
+	assert.Equal(t, "EUR", got.Currency)
+	assert.Equal(t, "WAAS", got.BankID)
+	assert.Equal(t, "wt_test0001", got.AccountID)
+	assert.True(t, got.Start.Equal(eur.StartDate))
+	assert.True(t, got.End.Equal(eur.EndDate))
+	assert.True(t, got.LedgerBalance.Equal(eur.Summary.ClosingBalance))
+
+	// 5 movements plus one fee transaction
+	assert.Len(t, got.Transactions, 6)
+
+	net := decimal.Zero
+	for _, trn := range got.Transactions {
+		net = net.Add(trn.Amount)
+	}
+	assert.True(t, eur.Summary.OpeningBalance.Add(net).Equal(got.LedgerBalance))
+
+	first := got.Transactions[0]
+	assert.Equal(t, OFXTypeCredit, first.Type)
+	assert.Equal(t, "12.5", first.Amount.String())
+	assert.Equal(t, "txn_aa", first.FITID)
+	assert.Equal(t, "ext-a", first.Reference)
+	assert.Equal(t, "Payment (invoice) #A", first.Memo)
+
+	debit, fee := got.Transactions[2], got.Transactions[3]
+	assert.Equal(t, OFXTypeDebit, debit.Type)
+	assert.Equal(t, "-14.5", debit.Amount.String())
+	assert.Equal(t, OFXTypeFee, fee.Type)
+	assert.Equal(t, "-0.5", fee.Amount.String())
+	assert.Equal(t, "txn_ca-FEE", fee.FITID)
+
+	assert.Equal(t, "USD", parsed[1].Currency)
+	assert.Len(t, parsed[1].Transactions, 2)
+}
+
+func TestParseOFXInvalid(t *testing.T) {
+	_, err := ParseOFX(strings.NewReader("not ofx"))
+	assert.ErrorIs(t, err, ErrInvalidOFX)
+
+	_, err = parseOFXDate("2024")
+	assert.ErrorIs(t, err, ErrInvalidOFX)
+
+	at, err := parseOFXDate("20240131120000.000[-5:EST]")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC), at)
+}
@@ -0,0 +1,242 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+)
+
+// PDF page geometry in points (A4 portrait)
+const (
+	pdfPageWidth   = 595
+	pdfPageHeight  = 842
+	pdfMarginLeft  = 40
+	pdfMarginTop   = 50
+	pdfMarginBase  = 50
+	pdfFontSize    = 8
+	pdfTitleSize   = 14
+	pdfLineHeight  = 11
+	pdfLineWidth   = 100 // Characters per line at pdfFontSize in Courier
+	pdfDescription = 34  // Characters kept from descriptions in the table
+)
+
+// PDFExporter renders a paginated, text-only PDF statement using the built-in
+// Courier fonts, so no font files or external services are needed
+type PDFExporter struct {
+	Title string // Document title (defaults to "Account Statement")
+}
+
+// NewPDFExporter creates a PDFExporter
+func NewPDFExporter() *PDFExporter {
+	return &PDFExporter{Title: "Account Statement"}
+}
+
+// pdfLine is a single line of text placed on a page
+type pdfLine struct {
+	text string
+	bold bool
+	size int
+}
+
+// Export writes the statement as a PDF document to w
+func (e *PDFExporter) Export(w io.Writer, stmt *types.AccountStatement) error {
+	if stmt == nil {
+		return ErrNilStatement
+	}
+
+	title := e.Title
+	if title == "" {
+		title = "Account Statement"
+	}
+
+	precision := statementPrecision(stmt)
+	amount := func(d decimal.Decimal) string { return d.StringFixed(precision) }
+
+	// Header and summary appear on the first page only
+	header := []pdfLine{
+		{text: title, bold: true, size: pdfTitleSize},
+		{},
+		{text: labelled("Account Name", stmt.AccountName)},
+		{text: labelled("Account Number", stmt.AccountNumber)},
+		{text: labelled("Account Holder", stmt.AccountHolderAddress)},
+		{text: labelled("Currency", stmt.AccountCurrency)},
+		{text: labelled("Account Opened", stmt.AccountOpened)},
+		{text: labelled("Period", fmt.Sprintf("%s to %s",
+			stmt.StartDate.Format(types.StatementDateLayout), stmt.EndDate.Format(types.StatementDateLayout)))},
+		{text: labelled("Date Printed", stmt.DatePrinted)},
+		{text: labelled("Status", accountStatus(stmt))},
+		{},
+		{text: "Summary", bold: true},
+		{text: labelled("Opening Balance", amount(stmt.Summary.OpeningBalance))},
+		{text: labelled("Total Credits", fmt.Sprintf("%s (%d)", amount(stmt.Summary.TotalCreditAmount), stmt.Summary.TotalCreditCount))},
+		{text: labelled("Total Debits", fmt.Sprintf("%s (%d)", amount(stmt.Summary.TotalDebitAmount), stmt.Summary.TotalDebitCount))},
+		{text: labelled("Total Fees", amount(stmt.Summary.TotalFee))},
+		{text: labelled("Closing Balance", amount(stmt.Summary.ClosingBalance))},
+		{text: labelled("Available Balance", stmt.CurrentBalance)},
+		{text: labelled("Lien Balance", stmt.CurrentLienBalance)},
+		{},
+	}
+
+	tableHeader := []pdfLine{
+		{text: tableRow("Date", "Description", "Credit", "Debit", "Fee", "Balance"), bold: true},
+		{text: strings.Repeat("-", pdfLineWidth)},
+	}
+
+	rows := make([]pdfLine, 0, len(stmt.Transactions))
+	for _, line := range stmt.Transactions {
+		rows = append(rows, pdfLine{text: tableRow(line.Date, line.Description, line.Credit, line.Debit, line.Fee, line.Balance)})
+	}
+	if len(rows) == 0 {
+		rows = append(rows, pdfLine{text: "No transactions in this period"})
+	}
+
+	// Lay rows out over pages, repeating the table header on each page
+	linesPerPage := (pdfPageHeight - pdfMarginTop - pdfMarginBase) / pdfLineHeight
+	var pages [][]pdfLine
+	current := append(append([]pdfLine{}, header...), tableHeader...)
+	for _, row := range rows {
+		if len(current) >= linesPerPage {
+			pages = append(pages, current)
+			current = append([]pdfLine{}, tableHeader...)
+		}
+		current = append(current, row)
+	}
+	pages = append(pages, current)
+
+	return writePDF(w, pages)
+}
+
+// ContentType returns the MIME type of the output
+func (e *PDFExporter) ContentType() string { return "application/pdf" }
+
+// FileExtension returns the file extension of the output
+func (e *PDFExporter) FileExtension() string { return "pdf" }
+
+// labelled formats a "label: value" header line
+func labelled(label, value string) string {
+	return fmt.Sprintf("%-18s %s", label+":", value)
+}
+
+// tableRow formats a fixed-width statement table row
+func tableRow(date, description, credit, debit, fee, balance string) string {
+	if len(description) > pdfDescription {
+		description = description[:pdfDescription-3] + "..."
+	}
+	return fmt.Sprintf("%-11s  %-*s %13s %13s %10s %14s",
+		date, pdfDescription, description, credit, debit, fee, balance)
+}
+
+// accountStatus describes the account state for the header
+func accountStatus(stmt *types.AccountStatement) string {
+	switch {
+	case stmt.IsAccountClosed:
+		return "Closed"
+	case stmt.IsAccountFrozen:
+		return "Frozen"
+	default:
+		return "Active"
+	}
+}
+
+// writePDF serialises text pages into a minimal PDF 1.4 document
+func writePDF(w io.Writer, pages [][]pdfLine) error {
+	var buf bytes.Buffer
+	var offsets []int
+
+	// Objects: 1 catalog, 2 pages, 3 regular font, 4 bold font,
+	// then a page object and a content stream per page
+	startObj := func() int {
+		offsets = append(offsets, buf.Len())
+		id := len(offsets)
+		fmt.Fprintf(&buf, "%d 0 obj\n", id)
+		return id
+	}
+	endObj := func() { buf.WriteString("endobj\n") }
+
+	buf.WriteString("%PDF-1.4\n")
+
+	startObj()
+	buf.WriteString("<< /Type /Catalog /Pages 2 0 R >>\n")
+	endObj()
+
+	startObj()
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+i*2)
+	}
+	fmt.Fprintf(&buf, "<< /Type /Pages /Kids [%s] /Count %d >>\n", strings.Join(kids, " "), len(pages))
+	endObj()
+
+	startObj()
+	buf.WriteString("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>\n")
+	endObj()
+
+	startObj()
+	buf.WriteString("<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>\n")
+	endObj()
+
+	for i, lines := range pages {
+		pageID := startObj()
+		fmt.Fprintf(&buf, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>\n",
+			pdfPageWidth, pdfPageHeight, pageID+1)
+		endObj()
+
+		var content bytes.Buffer
+		y := pdfPageHeight - pdfMarginTop
+		for _, line := range lines {
+			if line.text != "" {
+				font, size := "F1", pdfFontSize
+				if line.bold {
+					font = "F2"
+				}
+				if line.size > 0 {
+					size = line.size
+				}
+				fmt.Fprintf(&content, "BT /%s %d Tf %d %d Td (%s) Tj ET\n", font, size, pdfMarginLeft, y, pdfEscape(line.text))
+			}
+			y -= pdfLineHeight
+		}
+		footer := fmt.Sprintf("Page %d of %d", i+1, len(pages))
+		fmt.Fprintf(&content, "BT /F1 %d Tf %d %d Td (%s) Tj ET\n", pdfFontSize, pdfMarginLeft, pdfMarginBase/2, footer)
+
+		startObj()
+		fmt.Fprintf(&buf, "<< /Length %d >>\nstream\n", content.Len())
+		buf.Write(content.Bytes())
+		buf.WriteString("endstream\n")
+		endObj()
+	}
+
+	// Cross-reference table and trailer
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// pdfEscape escapes text for a PDF string literal and replaces characters
+// outside printable ASCII, which the built-in fonts cannot render reliably
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
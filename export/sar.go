@@ -0,0 +1,97 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+)
+
+// ErrNilReport is returned when a SAR report is missing
+var ErrNilReport = errors.New("report cannot be nil")
+
+// sarHeader is the header row of the CSV SAR report; one row per supporting transaction
+var sarHeader = []string{
+	"Report ID", "Institution", "Case ID", "Pattern", "Customer ID", "Case Currency", "Case Amount",
+	"Summary", "Period Start", "Period End", "Case Status", "Transaction ID", "Wallet ID", "Date",
+	"Type", "Category", "Amount", "Currency", "External Reference", "Description",
+}
+
+// WriteSARJSON writes the report as indented JSON
+func WriteSARJSON(w io.Writer, report *types.SARReport) error {
+	if report == nil {
+		return ErrNilReport
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// WriteSARCSV writes the report as CSV. Each supporting transaction is a row
+// carrying its case's details; a case without transactions gets a single row.
+func WriteSARCSV(w io.Writer, report *types.SARReport) error {
+	if report == nil {
+		return ErrNilReport
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(sarHeader); err != nil {
+		return err
+	}
+
+	date := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	for _, c := range report.Cases {
+		caseColumns := []string{
+			report.ReportID,
+			report.Institution,
+			c.ID,
+			string(c.Pattern),
+			c.CustomerID,
+			c.CurrencyCode,
+			c.Amount.String(),
+			c.Summary,
+			date(c.PeriodStart),
+			date(c.PeriodEnd),
+			string(c.Status),
+		}
+
+		if len(c.Transactions) == 0 {
+			row := append(caseColumns, strings.Join(c.TransactionIDs, " "), strings.Join(c.WalletIDs, " "), "", "", "", "", "", "", "")
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, tx := range c.Transactions {
+			row := append(append([]string(nil), caseColumns...),
+				tx.ID,
+				tx.WalletID,
+				date(tx.CreatedAt),
+				string(tx.Type),
+				string(tx.Category),
+				tx.Amount.String(),
+				tx.CurrencyCode,
+				tx.ExternalReference,
+				tx.Description,
+			)
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,88 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sarReport() *types.SARReport {
+	at := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	return &types.SARReport{
+		ReportID:    "sar_1",
+		Institution: "Example Bank",
+		GeneratedAt: at,
+		Cases: []*types.SARCase{
+			{
+				AMLCase: &types.AMLCase{
+					ID:             "aml_1",
+					Pattern:        types.PatternStructuring,
+					CustomerID:     "cus_1",
+					CurrencyCode:   "USD",
+					Amount:         decimal.NewFromInt(19400),
+					Summary:        "2 transactions, just below 10000",
+					WalletIDs:      []string{"wt_1"},
+					TransactionIDs: []string{"txn_1", "txn_2"},
+					PeriodStart:    at.AddDate(0, 0, -7),
+					PeriodEnd:      at,
+					Status:         types.AMLCaseOpen,
+				},
+				Transactions: []*types.TransactionHistory{
+					{ID: "txn_1", WalletID: "wt_1", CurrencyCode: "USD", Type: types.TypeCredit, Category: types.CategoryDeposit, Amount: decimal.NewFromInt(9500), CreatedAt: at.Add(-time.Hour)},
+					{ID: "txn_2", WalletID: "wt_1", CurrencyCode: "USD", Type: types.TypeCredit, Category: types.CategoryDeposit, Amount: decimal.NewFromInt(9900), CreatedAt: at},
+				},
+			},
+			{
+				AMLCase: &types.AMLCase{
+					ID:           "aml_2",
+					Pattern:      types.PatternDormantReactivation,
+					CustomerID:   "cus_2",
+					CurrencyCode: "USD",
+					Amount:       decimal.Zero,
+					Status:       types.AMLCaseReported,
+				},
+			},
+		},
+	}
+}
+
+func TestWriteSARCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteSARCSV(&buf, sarReport()))
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	assert.Equal(t, sarHeader, records[0])
+
+	assert.Equal(t, "aml_1", records[1][2])
+	assert.Equal(t, "txn_1", records[1][11])
+	assert.Equal(t, "9500", records[1][16])
+	assert.Equal(t, "txn_2", records[2][11])
+	assert.Equal(t, "aml_2", records[3][2])
+	assert.Equal(t, "REPORTED", records[3][10])
+
+	assert.ErrorIs(t, WriteSARCSV(&buf, nil), ErrNilReport)
+}
+
+func TestWriteSARJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteSARJSON(&buf, sarReport()))
+
+	var decoded types.SARReport
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	require.Len(t, decoded.Cases, 2)
+	assert.Equal(t, "Example Bank", decoded.Institution)
+	assert.Equal(t, types.PatternStructuring, decoded.Cases[0].Pattern)
+	assert.Len(t, decoded.Cases[0].Transactions, 2)
+	assert.True(t, decoded.Cases[0].Amount.Equal(decimal.NewFromInt(19400)))
+
+	assert.ErrorIs(t, WriteSARJSON(&buf, nil), ErrNilReport)
+}
@@ -0,0 +1,187 @@
+// Package migrations holds the versioned database schema for the wallet
+// tables, as up and down SQL files for Postgres and SQLite.
+//
+// The first migration creates every table the library uses if it does not
+// exist yet, so databases created from bun models by earlier releases can
+// adopt the migrations as they are. Later migrations add the constraints and
+// indexes those databases lacked.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/migrate"
+)
+
+// Tables recording which migrations have run
+const (
+	MigrationsTable = "waas_migrations"
+	LocksTable      = "waas_migration_locks"
+)
+
+var (
+	ErrUnsupportedDialect = errors.New("migrations: unsupported database dialect")
+	ErrNothingToRollback  = errors.New("migrations: no applied migrations to roll back")
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// MigrationStatus reports whether a single migration has been applied
+type MigrationStatus struct {
+	Name       string    `json:"name"`       // Version, from the file name
+	Comment    string    `json:"comment"`    // Description, from the file name
+	Applied    bool      `json:"applied"`    // Whether it has run
+	GroupID    int64     `json:"groupId"`    // Run it was applied in; a rollback undoes the last group
+	MigratedAt time.Time `json:"migratedAt"` // When it was applied
+}
+
+// Migrations returns the migrations for a database dialect
+func Migrations(name dialect.Name) (*migrate.Migrations, error) {
+	var dir string
+	switch name {
+	case dialect.PG:
+		dir = "postgres"
+	case dialect.SQLite:
+		dir = "sqlite"
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedDialect, name)
+	}
+
+	sub, err := fs.Sub(files, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := migrate.NewMigrations()
+	if err := migrations.Discover(sub); err != nil {
+		return nil, fmt.Errorf("failed to load %s migrations: %w", dir, err)
+	}
+	return migrations, nil
+}
+
+// NewMigrator returns a migrator for db that only records a migration as
+// applied once it has succeeded
+func NewMigrator(ctx context.Context, db *bun.DB) (*migrate.Migrator, error) {
+	migrations, err := Migrations(db.Dialect().Name())
+	if err != nil {
+		return nil, err
+	}
+
+	migrator := migrate.NewMigrator(db, migrations,
+		migrate.WithTableName(MigrationsTable),
+		migrate.WithLocksTableName(LocksTable),
+		migrate.WithMarkAppliedOnSuccess(true),
+	)
+	if err := migrator.Init(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create migration tables: %w", err)
+	}
+	return migrator, nil
+}
+
+// Up applies every pending migration as one group. It returns the names of
+// the migrations applied, which is empty when the schema is current.
+func Up(ctx context.Context, db *bun.DB) ([]string, error) {
+	migrator, err := NewMigrator(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var group *migrate.MigrationGroup
+	err = withLock(ctx, migrator, func() error {
+		group, err = migrator.Migrate(ctx)
+		return err
+	})
+	if err != nil {
+		return groupNames(group), fmt.Errorf("migration failed: %w", err)
+	}
+
+	return groupNames(group), nil
+}
+
+// Down rolls back the most recently applied group of migrations and returns
+// the names of the migrations rolled back
+func Down(ctx context.Context, db *bun.DB) ([]string, error) {
+	migrator, err := NewMigrator(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var group *migrate.MigrationGroup
+	err = withLock(ctx, migrator, func() error {
+		applied, err := migrator.AppliedMigrations(ctx)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			return ErrNothingToRollback
+		}
+
+		group, err = migrator.Rollback(ctx)
+		return err
+	})
+	if err != nil {
+		return groupNames(group), fmt.Errorf("rollback failed: %w", err)
+	}
+
+	return groupNames(group), nil
+}
+
+// Status lists every migration in order with whether it has been applied
+func Status(ctx context.Context, db *bun.DB) ([]MigrationStatus, error) {
+	migrator, err := NewMigrator(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := migrator.MigrationsWithStatus(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration status: %w", err)
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		statuses = append(statuses, MigrationStatus{
+			Name:       m.Name,
+			Comment:    m.Comment,
+			Applied:    m.IsApplied(),
+			GroupID:    m.GroupID,
+			MigratedAt: m.MigratedAt,
+		})
+	}
+	return statuses, nil
+}
+
+// withLock runs fn holding the migration lock, so two processes cannot
+// migrate the same database at once
+func withLock(ctx context.Context, migrator *migrate.Migrator, fn func() error) error {
+	if err := migrator.Lock(ctx); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	err := fn()
+	if unlockErr := migrator.Unlock(ctx); err == nil && unlockErr != nil {
+		err = fmt.Errorf("failed to release migration lock: %w", unlockErr)
+	}
+	return err
+}
+
+// groupNames returns the names of the migrations in a group
+func groupNames(group *migrate.MigrationGroup) []string {
+	if group == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(group.Migrations))
+	for _, m := range group.Migrations {
+		names = append(names, m.Name+"_"+m.Comment)
+	}
+	return names
+}
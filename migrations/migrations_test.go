@@ -0,0 +1,192 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+)
+
+func newSQLiteDB(t *testing.T) *bun.DB {
+	t.Helper()
+
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	sqldb, err := sql.Open(sqliteshim.ShimName, fmt.Sprintf("file:%s?mode=memory&cache=shared", name))
+	require.NoError(t, err)
+	sqldb.SetMaxOpenConns(1)
+
+	db := bun.NewDB(sqldb, sqlitedialect.New())
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func indexNames(t *testing.T, db *bun.DB) []string {
+	t.Helper()
+
+	var names []string
+	err := db.NewSelect().
+		ColumnExpr("name").
+		TableExpr("sqlite_master").
+		Where("type = 'index'").
+		Where("name NOT LIKE 'sqlite_%'").
+		Scan(context.Background(), &names)
+	require.NoError(t, err)
+	return names
+}
+
+func TestMigrations(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("up applies every migration", func(t *testing.T) {
+		db := newSQLiteDB(t)
+
+		applied, err := Up(ctx, db)
+		require.NoError(t, err)
+		require.Len(t, applied, 6)
+
+		statuses, err := Status(ctx, db)
+		require.NoError(t, err)
+		require.Len(t, statuses, 6)
+		for _, s := range statuses {
+			require.True(t, s.Applied, s.Name)
+			require.Equal(t, int64(1), s.GroupID)
+			require.False(t, s.MigratedAt.IsZero())
+		}
+
+		applied, err = Up(ctx, db)
+		require.NoError(t, err)
+		require.Empty(t, applied)
+
+		require.Contains(t, indexNames(t, db), "transaction_histories_wallet_id_created_at_idx")
+		require.Contains(t, indexNames(t, db), "payout_items_batch_id_seq_idx")
+		require.Contains(t, indexNames(t, db), "transaction_histories_group_id_idx")
+		require.Contains(t, indexNames(t, db), "transaction_status_changes_wallet_id_idx")
+		require.Contains(t, indexNames(t, db), "outbox_events_publish_sequence_idx")
+	})
+
+	t.Run("constraints", func(t *testing.T) {
+		db := newSQLiteDB(t)
+		_, err := Up(ctx, db)
+		require.NoError(t, err)
+
+		wallet, err := types.NewWallet("cus_1", "USD")
+		require.NoError(t, err)
+		_, err = db.NewInsert().Model(wallet).Exec(ctx)
+		require.NoError(t, err)
+
+		duplicate, err := types.NewWallet("cus_1", "usd")
+		require.NoError(t, err)
+		_, err = db.NewInsert().Model(duplicate).Exec(ctx)
+		require.Error(t, err, "second wallet for the same customer and currency")
+
+		negative, err := types.NewWallet("cus_2", "USD")
+		require.NoError(t, err)
+		negative.AvailableBalance = decimal.NewFromInt(-1)
+		_, err = db.NewInsert().Model(negative).Exec(ctx)
+		require.Error(t, err, "negative available balance")
+
+		negative.AvailableBalance = decimal.Zero
+		negative.LienBalance = decimal.NewFromInt(-1)
+		_, err = db.NewInsert().Model(negative).Exec(ctx)
+		require.Error(t, err, "negative lien balance")
+	})
+
+	t.Run("down and up again", func(t *testing.T) {
+		db := newSQLiteDB(t)
+		_, err := Up(ctx, db)
+		require.NoError(t, err)
+
+		rolledBack, err := Down(ctx, db)
+		require.NoError(t, err)
+		require.Len(t, rolledBack, 6)
+		require.Empty(t, indexNames(t, db))
+
+		_, err = Down(ctx, db)
+		require.ErrorIs(t, err, ErrNothingToRollback)
+
+		statuses, err := Status(ctx, db)
+		require.NoError(t, err)
+		for _, s := range statuses {
+			require.False(t, s.Applied, s.Name)
+		}
+
+		applied, err := Up(ctx, db)
+		require.NoError(t, err)
+		require.Len(t, applied, 6)
+	})
+
+	t.Run("adopts a schema created from the models", func(t *testing.T) {
+		db := newSQLiteDB(t)
+
+		models := []any{
+			(*types.Wallet)(nil),
+			(*types.TransactionHistory)(nil),
+			(*types.LienRecord)(nil),
+		}
+		for _, model := range models {
+			_, err := db.NewCreateTable().Model(model).Exec(ctx)
+			require.NoError(t, err)
+		}
+
+		wallet, err := types.NewWallet("cus_1", "USD")
+		require.NoError(t, err)
+		wallet.AvailableBalance = decimal.NewFromInt(90)
+		wallet.LienBalance = decimal.NewFromInt(10)
+		_, err = db.NewInsert().Model(wallet).Exec(ctx)
+		require.NoError(t, err)
+
+		lien := &types.LienRecord{
+			ID:        types.GenerateID("lien_", 10),
+			WalletID:  wallet.ID,
+			Amount:    decimal.NewFromInt(10),
+			CreatedAt: time.Now().UTC(),
+		}
+		_, err = db.NewInsert().Model(lien).Exec(ctx)
+		require.NoError(t, err)
+
+		applied, err := Up(ctx, db)
+		require.NoError(t, err)
+		require.Len(t, applied, 6)
+
+		var got types.Wallet
+		err = db.NewSelect().Model(&got).Where("id = ?", wallet.ID).Scan(ctx)
+		require.NoError(t, err)
+		require.True(t, got.AvailableBalance.Equal(wallet.AvailableBalance))
+		require.True(t, got.LienBalance.Equal(wallet.LienBalance))
+		require.Equal(t, wallet.VersionId, got.VersionId)
+
+		var liens []types.LienRecord
+		err = db.NewSelect().Model(&liens).Where("wallet_id = ?", wallet.ID).Scan(ctx)
+		require.NoError(t, err)
+		require.Len(t, liens, 1)
+		require.True(t, liens[0].ReleasedAt.IsZero())
+		require.True(t, liens[0].Amount.Equal(lien.Amount))
+	})
+}
+
+func TestMigrationsUnsupportedDialect(t *testing.T) {
+	_, err := Migrations(dialect.MSSQL)
+	require.ErrorIs(t, err, ErrUnsupportedDialect)
+}
+
+func TestPostgresMigrationsDiscovered(t *testing.T) {
+	migrations, err := Migrations(dialect.PG)
+	require.NoError(t, err)
+
+	sorted := migrations.Sorted()
+	require.Len(t, sorted, 6)
+	for _, m := range sorted {
+		require.NotNil(t, m.Up, m.Name)
+		require.NotNil(t, m.Down, m.Name)
+	}
+}
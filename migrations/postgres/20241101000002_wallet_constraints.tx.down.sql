@@ -0,0 +1,93 @@
+DROP INDEX IF EXISTS "webhook_attempts_delivery_id_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "webhook_deliveries_status_next_attempt_at_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "webhook_subscriptions_customer_id_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "outbox_events_aggregate_id_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "outbox_events_unpublished_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "reconciliation_items_run_id_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "fx_trades_traded_at_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "aml_cases_status_created_at_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "screening_cases_wallet_id_status_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "risk_decisions_movement_id_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "risk_decisions_wallet_id_created_at_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "approval_events_request_id_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "approval_requests_status_created_at_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "wallet_status_changes_wallet_id_created_at_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "lien_records_lien_id_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "lien_records_wallet_id_created_at_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "transaction_histories_created_at_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "transaction_histories_external_reference_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "transaction_histories_wallet_id_created_at_idx";
+
+--bun:split
+
+-- Column types and NULL release times are left as they are
+ALTER TABLE "lien_records"
+    DROP CONSTRAINT IF EXISTS "lien_records_amount_check",
+    DROP CONSTRAINT IF EXISTS "lien_records_pkey";
+
+--bun:split
+
+ALTER TABLE "transaction_histories"
+    DROP CONSTRAINT IF EXISTS "transaction_histories_fee_check",
+    DROP CONSTRAINT IF EXISTS "transaction_histories_amount_check";
+
+--bun:split
+
+ALTER TABLE "wallets"
+    DROP CONSTRAINT IF EXISTS "wallets_lien_balance_check",
+    DROP CONSTRAINT IF EXISTS "wallets_available_balance_check",
+    DROP CONSTRAINT IF EXISTS "wallets_customer_currency_key";
@@ -0,0 +1,118 @@
+-- One wallet per customer and currency, and balances that can never go
+-- negative. Fails if existing rows break either rule; merge duplicate
+-- wallets and fix negative balances first.
+ALTER TABLE "wallets"
+    ADD CONSTRAINT "wallets_customer_currency_key" UNIQUE ("customer_id", "currency_code"),
+    ADD CONSTRAINT "wallets_available_balance_check" CHECK ("available_balance" >= 0),
+    ADD CONSTRAINT "wallets_lien_balance_check" CHECK ("lien_balance" >= 0);
+
+--bun:split
+
+ALTER TABLE "transaction_histories"
+    ADD CONSTRAINT "transaction_histories_amount_check" CHECK ("amount" > 0),
+    ADD CONSTRAINT "transaction_histories_fee_check" CHECK ("fee" >= 0);
+
+--bun:split
+
+-- Older lien tables stored amounts as JSON and unreleased liens with a zero
+-- release time
+ALTER TABLE "lien_records"
+    ALTER COLUMN "amount" TYPE decimal(24,8) USING "amount"::text::decimal(24,8);
+
+--bun:split
+
+UPDATE "lien_records" SET "released_at" = NULL WHERE "released_at" < '1000-01-01';
+
+--bun:split
+
+ALTER TABLE "lien_records"
+    ALTER COLUMN "id" SET NOT NULL,
+    ALTER COLUMN "wallet_id" SET NOT NULL,
+    ALTER COLUMN "amount" SET NOT NULL,
+    ALTER COLUMN "created_at" SET NOT NULL,
+    ADD CONSTRAINT "lien_records_pkey" PRIMARY KEY ("id"),
+    ADD CONSTRAINT "lien_records_amount_check" CHECK ("amount" > 0);
+
+--bun:split
+
+ALTER TABLE "currency_infos"
+    ALTER COLUMN "fee_deposit" TYPE decimal(24,8) USING "fee_deposit"::text::decimal(24,8),
+    ALTER COLUMN "fee_withdrawal" TYPE decimal(24,8) USING "fee_withdrawal"::text::decimal(24,8),
+    ALTER COLUMN "spread_margin_buy" TYPE decimal(24,8) USING "spread_margin_buy"::text::decimal(24,8),
+    ALTER COLUMN "spread_margin_sell" TYPE decimal(24,8) USING "spread_margin_sell"::text::decimal(24,8);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "transaction_histories_wallet_id_created_at_idx" ON "transaction_histories" ("wallet_id", "created_at");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "transaction_histories_external_reference_idx" ON "transaction_histories" ("external_reference");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "transaction_histories_created_at_idx" ON "transaction_histories" ("created_at");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "lien_records_wallet_id_created_at_idx" ON "lien_records" ("wallet_id", "created_at");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "lien_records_lien_id_idx" ON "lien_records" ("lien_id") WHERE "lien_id" IS NOT NULL;
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "wallet_status_changes_wallet_id_created_at_idx" ON "wallet_status_changes" ("wallet_id", "created_at");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "approval_requests_status_created_at_idx" ON "approval_requests" ("status", "created_at");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "approval_events_request_id_idx" ON "approval_events" ("request_id");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "risk_decisions_wallet_id_created_at_idx" ON "risk_decisions" ("wallet_id", "created_at");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "risk_decisions_movement_id_idx" ON "risk_decisions" ("movement_id");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "screening_cases_wallet_id_status_idx" ON "screening_cases" ("wallet_id", "status");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "aml_cases_status_created_at_idx" ON "aml_cases" ("status", "created_at");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "fx_trades_traded_at_idx" ON "fx_trades" ("traded_at");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "reconciliation_items_run_id_idx" ON "reconciliation_items" ("run_id");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "outbox_events_unpublished_idx" ON "outbox_events" ("id") WHERE "published_at" IS NULL;
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "outbox_events_aggregate_id_idx" ON "outbox_events" ("aggregate_id", "id");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "webhook_subscriptions_customer_id_idx" ON "webhook_subscriptions" ("customer_id");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "webhook_deliveries_status_next_attempt_at_idx" ON "webhook_deliveries" ("status", "next_attempt_at");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "webhook_attempts_delivery_id_idx" ON "webhook_attempts" ("delivery_id", "attempted_at");
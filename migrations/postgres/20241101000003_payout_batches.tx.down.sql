@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "payout_items";
+
+--bun:split
+
+DROP TABLE IF EXISTS "payout_batches";
@@ -0,0 +1,18 @@
+-- Transaction status changes: each move out of pending is sealed into the
+-- wallet's hash chain, so a status edited in place fails verification
+
+CREATE TABLE IF NOT EXISTS "transaction_status_changes" (
+    "id" VARCHAR NOT NULL,
+    "transaction_id" VARCHAR NOT NULL,
+    "wallet_id" VARCHAR NOT NULL,
+    "from_status" VARCHAR NOT NULL,
+    "to_status" VARCHAR NOT NULL,
+    "created_at" TIMESTAMPTZ NOT NULL,
+    "prev_hash" VARCHAR,
+    "hash" VARCHAR NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "transaction_status_changes_wallet_id_idx" ON "transaction_status_changes" ("wallet_id");
@@ -0,0 +1,13 @@
+-- Outbox publish sequence: the relay numbers events as it publishes them, in
+-- commit order, so readers can resume without skipping late commits. Events
+-- published before this migration keep their insertion order.
+
+ALTER TABLE "outbox_events" ADD COLUMN IF NOT EXISTS "publish_sequence" BIGINT;
+
+--bun:split
+
+UPDATE "outbox_events" SET "publish_sequence" = "id" WHERE "published_at" IS NOT NULL;
+
+--bun:split
+
+CREATE UNIQUE INDEX IF NOT EXISTS "outbox_events_publish_sequence_idx" ON "outbox_events" ("publish_sequence");
@@ -0,0 +1,85 @@
+DROP TABLE IF EXISTS "webhook_attempts";
+
+--bun:split
+
+DROP TABLE IF EXISTS "webhook_deliveries";
+
+--bun:split
+
+DROP TABLE IF EXISTS "webhook_subscriptions";
+
+--bun:split
+
+DROP TABLE IF EXISTS "outbox_events";
+
+--bun:split
+
+DROP TABLE IF EXISTS "reconciliation_items";
+
+--bun:split
+
+DROP TABLE IF EXISTS "reconciliation_runs";
+
+--bun:split
+
+DROP TABLE IF EXISTS "fx_trades";
+
+--bun:split
+
+DROP TABLE IF EXISTS "aml_cases";
+
+--bun:split
+
+DROP TABLE IF EXISTS "screening_cases";
+
+--bun:split
+
+DROP TABLE IF EXISTS "risk_decisions";
+
+--bun:split
+
+DROP TABLE IF EXISTS "request_nonces";
+
+--bun:split
+
+DROP TABLE IF EXISTS "initiator_keys";
+
+--bun:split
+
+DROP TABLE IF EXISTS "customer_kyc_tiers";
+
+--bun:split
+
+DROP TABLE IF EXISTS "approval_events";
+
+--bun:split
+
+DROP TABLE IF EXISTS "approval_requests";
+
+--bun:split
+
+DROP TABLE IF EXISTS "wallet_status_changes";
+
+--bun:split
+
+DROP TABLE IF EXISTS "chain_checkpoints";
+
+--bun:split
+
+DROP TABLE IF EXISTS "ledger_chain_heads";
+
+--bun:split
+
+DROP TABLE IF EXISTS "currency_infos";
+
+--bun:split
+
+DROP TABLE IF EXISTS "lien_records";
+
+--bun:split
+
+DROP TABLE IF EXISTS "transaction_histories";
+
+--bun:split
+
+DROP TABLE IF EXISTS "wallets";
@@ -0,0 +1,363 @@
+-- Tables as created by earlier releases through bun models. IF NOT EXISTS
+-- lets a database created that way adopt the migrations unchanged.
+
+CREATE TABLE IF NOT EXISTS "wallets" (
+    "id" VARCHAR NOT NULL,
+    "customer_id" VARCHAR NOT NULL,
+    "available_balance" decimal(24,8) NOT NULL,
+    "lien_balance" decimal(24,8) NOT NULL,
+    "currency_code" VARCHAR NOT NULL,
+    "is_closed" BOOLEAN DEFAULT false,
+    "frozen" BOOLEAN DEFAULT false,
+    "freeze_reason" VARCHAR,
+    "freeze_initiated_by" VARCHAR,
+    "frozen_at" TIMESTAMP NOT NULL,
+    "created_at" TIMESTAMP NOT NULL,
+    "updated_at" TIMESTAMP NOT NULL,
+    "version_id" VARCHAR NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "transaction_histories" (
+    "id" VARCHAR NOT NULL,
+    "wallet_id" VARCHAR NOT NULL,
+    "currency_code" VARCHAR NOT NULL,
+    "initiator_id" VARCHAR NOT NULL,
+    "external_reference" VARCHAR NOT NULL,
+    "category" VARCHAR NOT NULL,
+    "description" VARCHAR NOT NULL,
+    "amount" decimal(24,8) NOT NULL,
+    "fee" decimal(24,8) NOT NULL,
+    "type" VARCHAR NOT NULL,
+    "balance_before" decimal(24,8) NOT NULL,
+    "balance_after" decimal(24,8) NOT NULL,
+    "created_at" TIMESTAMP NOT NULL,
+    "updated_at" TIMESTAMP NOT NULL,
+    "status" VARCHAR NOT NULL,
+    "prev_hash" VARCHAR,
+    "hash" VARCHAR,
+    PRIMARY KEY ("id")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "lien_records" (
+    "id" VARCHAR,
+    "wallet_id" VARCHAR,
+    "amount" decimal(24,8),
+    "description" VARCHAR,
+    "external_transaction_id" VARCHAR,
+    "created_at" TIMESTAMP,
+    "released_at" TIMESTAMP,
+    "lien_id" VARCHAR
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "currency_infos" (
+    "code" VARCHAR NOT NULL,
+    "name" VARCHAR,
+    "symbol" VARCHAR,
+    "is_fiat" BOOLEAN,
+    "is_stable_coin" BOOLEAN,
+    "icon_url" VARCHAR,
+    "precision" INTEGER,
+    "disabled" BOOLEAN,
+    "can_sell" BOOLEAN,
+    "can_buy" BOOLEAN,
+    "can_swap" BOOLEAN,
+    "can_deposit" BOOLEAN,
+    "can_withdraw" BOOLEAN,
+    "fee_deposit" decimal(24,8),
+    "fee_withdrawal" decimal(24,8),
+    "spread_margin_buy" decimal(24,8),
+    "spread_margin_sell" decimal(24,8),
+    "automatic_update" BOOLEAN,
+    "created_at" TIMESTAMP,
+    "updated_at" TIMESTAMP,
+    PRIMARY KEY ("code")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "ledger_chain_heads" (
+    "wallet_id" VARCHAR NOT NULL,
+    "head_hash" VARCHAR NOT NULL,
+    "length" INTEGER NOT NULL DEFAULT 0,
+    "updated_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("wallet_id")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "chain_checkpoints" (
+    "id" VARCHAR NOT NULL,
+    "heads" VARCHAR NOT NULL,
+    "digest" VARCHAR NOT NULL,
+    "key_id" VARCHAR NOT NULL,
+    "signature" VARCHAR NOT NULL,
+    "created_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "wallet_status_changes" (
+    "id" VARCHAR NOT NULL,
+    "wallet_id" VARCHAR NOT NULL,
+    "kind" VARCHAR NOT NULL,
+    "reason" VARCHAR,
+    "initiated_by" VARCHAR,
+    "created_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "approval_requests" (
+    "id" VARCHAR NOT NULL,
+    "operation" VARCHAR NOT NULL,
+    "wallet_id" VARCHAR NOT NULL,
+    "destination_wallet_id" VARCHAR,
+    "currency_code" VARCHAR,
+    "amount" decimal(24,8) NOT NULL,
+    "payload" JSON,
+    "maker_id" VARCHAR NOT NULL,
+    "checker_id" VARCHAR,
+    "status" VARCHAR NOT NULL,
+    "reason" VARCHAR,
+    "decision_note" VARCHAR,
+    "transaction_id" VARCHAR,
+    "expires_at" TIMESTAMP NOT NULL,
+    "created_at" TIMESTAMP NOT NULL,
+    "decided_at" TIMESTAMP,
+    "version_id" VARCHAR NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "approval_events" (
+    "id" VARCHAR NOT NULL,
+    "request_id" VARCHAR NOT NULL,
+    "type" VARCHAR NOT NULL,
+    "actor_id" VARCHAR NOT NULL,
+    "status" VARCHAR NOT NULL,
+    "note" VARCHAR,
+    "created_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "customer_kyc_tiers" (
+    "customer_id" VARCHAR NOT NULL,
+    "tier" VARCHAR NOT NULL,
+    "updated_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("customer_id")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "initiator_keys" (
+    "id" VARCHAR NOT NULL,
+    "initiator_id" VARCHAR NOT NULL,
+    "algorithm" VARCHAR NOT NULL,
+    "secret" BLOB,
+    "public_key" BLOB,
+    "valid_from" TIMESTAMP NOT NULL,
+    "valid_until" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "request_nonces" (
+    "key_id" VARCHAR NOT NULL,
+    "nonce" VARCHAR NOT NULL,
+    "expires_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("key_id", "nonce")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "risk_decisions" (
+    "id" VARCHAR NOT NULL,
+    "movement_id" VARCHAR NOT NULL,
+    "wallet_id" VARCHAR NOT NULL,
+    "operation" VARCHAR NOT NULL,
+    "type" VARCHAR NOT NULL,
+    "amount" decimal(24,8) NOT NULL,
+    "currency_code" VARCHAR NOT NULL,
+    "outcome" VARCHAR NOT NULL,
+    "rule" VARCHAR,
+    "hits" VARCHAR,
+    "transaction_i_ds" VARCHAR,
+    "review_status" VARCHAR,
+    "reviewer_id" VARCHAR,
+    "reviewed_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "screening_cases" (
+    "id" VARCHAR NOT NULL,
+    "customer_id" VARCHAR NOT NULL,
+    "wallet_id" VARCHAR NOT NULL,
+    "subject_name" VARCHAR NOT NULL,
+    "trigger" VARCHAR NOT NULL,
+    "matches" VARCHAR NOT NULL,
+    "status" VARCHAR NOT NULL,
+    "reviewer_id" VARCHAR,
+    "review_note" VARCHAR,
+    "created_at" TIMESTAMP NOT NULL,
+    "reviewed_at" TIMESTAMP,
+    PRIMARY KEY ("id")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "aml_cases" (
+    "id" VARCHAR NOT NULL,
+    "fingerprint" VARCHAR NOT NULL,
+    "pattern" VARCHAR NOT NULL,
+    "customer_id" VARCHAR NOT NULL,
+    "currency_code" VARCHAR NOT NULL,
+    "amount" decimal(24,8) NOT NULL,
+    "summary" VARCHAR NOT NULL,
+    "wallet_i_ds" VARCHAR NOT NULL,
+    "transaction_i_ds" VARCHAR NOT NULL,
+    "period_start" TIMESTAMP NOT NULL,
+    "period_end" TIMESTAMP NOT NULL,
+    "status" VARCHAR NOT NULL,
+    "reviewer_id" VARCHAR,
+    "review_note" VARCHAR,
+    "created_at" TIMESTAMP NOT NULL,
+    "reviewed_at" TIMESTAMP,
+    PRIMARY KEY ("id"),
+    UNIQUE ("fingerprint")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "fx_trades" (
+    "id" VARCHAR NOT NULL,
+    "customer_id" VARCHAR NOT NULL,
+    "source_wallet_id" VARCHAR NOT NULL,
+    "dest_wallet_id" VARCHAR NOT NULL,
+    "source_transaction_id" VARCHAR NOT NULL,
+    "dest_transaction_id" VARCHAR NOT NULL,
+    "from_currency" VARCHAR NOT NULL,
+    "to_currency" VARCHAR NOT NULL,
+    "from_amount" decimal(24,8) NOT NULL,
+    "to_amount" decimal(24,8) NOT NULL,
+    "fee" decimal(24,8) NOT NULL,
+    "applied_rate" decimal(24,12) NOT NULL,
+    "mid_rate" decimal(24,12) NOT NULL,
+    "source_base_rate" decimal(24,12) NOT NULL,
+    "traded_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "reconciliation_runs" (
+    "id" VARCHAR NOT NULL,
+    "source" VARCHAR NOT NULL,
+    "period_start" TIMESTAMP NOT NULL,
+    "period_end" TIMESTAMP NOT NULL,
+    "external_count" INTEGER NOT NULL DEFAULT 0,
+    "internal_count" INTEGER NOT NULL DEFAULT 0,
+    "matched_count" INTEGER NOT NULL DEFAULT 0,
+    "missing_internal_count" INTEGER NOT NULL DEFAULT 0,
+    "missing_external_count" INTEGER NOT NULL DEFAULT 0,
+    "amount_mismatch_count" INTEGER NOT NULL DEFAULT 0,
+    "created_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "reconciliation_items" (
+    "id" VARCHAR NOT NULL,
+    "run_id" VARCHAR NOT NULL,
+    "status" VARCHAR NOT NULL,
+    "external_reference" VARCHAR NOT NULL,
+    "transaction_id" VARCHAR,
+    "wallet_id" VARCHAR,
+    "currency_code" VARCHAR NOT NULL,
+    "external_amount" decimal(24,8) NOT NULL,
+    "internal_amount" decimal(24,8) NOT NULL,
+    "difference" decimal(24,8) NOT NULL,
+    "external_date" TIMESTAMP,
+    "internal_date" TIMESTAMP,
+    "source_line" INTEGER NOT NULL DEFAULT 0,
+    "reason" VARCHAR,
+    PRIMARY KEY ("id")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "outbox_events" (
+    "id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    "event_id" VARCHAR NOT NULL,
+    "type" VARCHAR NOT NULL,
+    "aggregate_id" VARCHAR NOT NULL,
+    "payload" json NOT NULL,
+    "occurred_at" TIMESTAMP NOT NULL,
+    "published_at" TIMESTAMP,
+    "attempts" INTEGER NOT NULL DEFAULT 0,
+    "last_error" VARCHAR,
+    UNIQUE ("event_id")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "webhook_subscriptions" (
+    "id" VARCHAR NOT NULL,
+    "customer_id" VARCHAR NOT NULL,
+    "url" VARCHAR NOT NULL,
+    "secret" VARCHAR NOT NULL,
+    "event_types" VARCHAR NOT NULL,
+    "active" BOOLEAN NOT NULL,
+    "created_at" TIMESTAMP NOT NULL,
+    "updated_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+    "id" VARCHAR NOT NULL,
+    "subscription_id" VARCHAR NOT NULL,
+    "event_id" VARCHAR NOT NULL,
+    "event_type" VARCHAR NOT NULL,
+    "body" json NOT NULL,
+    "status" VARCHAR NOT NULL,
+    "attempts" INTEGER NOT NULL DEFAULT 0,
+    "next_attempt_at" TIMESTAMP,
+    "last_status_code" INTEGER,
+    "last_error" VARCHAR,
+    "created_at" TIMESTAMP NOT NULL,
+    "delivered_at" TIMESTAMP,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "webhook_event" UNIQUE ("subscription_id", "event_id")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "webhook_attempts" (
+    "id" VARCHAR NOT NULL,
+    "delivery_id" VARCHAR NOT NULL,
+    "status_code" INTEGER,
+    "error" VARCHAR,
+    "response" VARCHAR,
+    "duration_ms" INTEGER NOT NULL,
+    "attempted_at" TIMESTAMP NOT NULL,
+    PRIMARY KEY ("id")
+);
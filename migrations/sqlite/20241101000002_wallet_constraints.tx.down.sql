@@ -0,0 +1,168 @@
+DROP INDEX IF EXISTS "webhook_attempts_delivery_id_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "webhook_deliveries_status_next_attempt_at_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "webhook_subscriptions_customer_id_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "outbox_events_aggregate_id_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "outbox_events_unpublished_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "reconciliation_items_run_id_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "fx_trades_traded_at_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "aml_cases_status_created_at_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "screening_cases_wallet_id_status_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "risk_decisions_movement_id_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "risk_decisions_wallet_id_created_at_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "approval_events_request_id_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "approval_requests_status_created_at_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "wallet_status_changes_wallet_id_created_at_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "lien_records_lien_id_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "lien_records_wallet_id_created_at_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "transaction_histories_created_at_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "transaction_histories_external_reference_idx";
+
+--bun:split
+
+DROP INDEX IF EXISTS "transaction_histories_wallet_id_created_at_idx";
+
+--bun:split
+
+-- Release times set to NULL stay NULL
+CREATE TABLE "lien_records_new" (
+    "id" VARCHAR,
+    "wallet_id" VARCHAR,
+    "amount" decimal(24,8),
+    "description" VARCHAR,
+    "external_transaction_id" VARCHAR,
+    "created_at" TIMESTAMP,
+    "released_at" TIMESTAMP,
+    "lien_id" VARCHAR
+);
+
+--bun:split
+
+INSERT INTO "lien_records_new" ("id", "wallet_id", "amount", "description", "external_transaction_id", "created_at", "released_at", "lien_id")
+SELECT "id", "wallet_id", "amount", "description", "external_transaction_id", "created_at", "released_at", "lien_id" FROM "lien_records";
+
+--bun:split
+
+DROP TABLE "lien_records";
+
+--bun:split
+
+ALTER TABLE "lien_records_new" RENAME TO "lien_records";
+
+--bun:split
+
+CREATE TABLE "transaction_histories_new" (
+    "id" VARCHAR NOT NULL,
+    "wallet_id" VARCHAR NOT NULL,
+    "currency_code" VARCHAR NOT NULL,
+    "initiator_id" VARCHAR NOT NULL,
+    "external_reference" VARCHAR NOT NULL,
+    "category" VARCHAR NOT NULL,
+    "description" VARCHAR NOT NULL,
+    "amount" decimal(24,8) NOT NULL,
+    "fee" decimal(24,8) NOT NULL,
+    "type" VARCHAR NOT NULL,
+    "balance_before" decimal(24,8) NOT NULL,
+    "balance_after" decimal(24,8) NOT NULL,
+    "created_at" TIMESTAMP NOT NULL,
+    "updated_at" TIMESTAMP NOT NULL,
+    "status" VARCHAR NOT NULL,
+    "prev_hash" VARCHAR,
+    "hash" VARCHAR,
+    PRIMARY KEY ("id")
+);
+
+--bun:split
+
+INSERT INTO "transaction_histories_new" ("id", "wallet_id", "currency_code", "initiator_id", "external_reference", "category", "description", "amount", "fee", "type", "balance_before", "balance_after", "created_at", "updated_at", "status", "prev_hash", "hash")
+SELECT "id", "wallet_id", "currency_code", "initiator_id", "external_reference", "category", "description", "amount", "fee", "type", "balance_before", "balance_after", "created_at", "updated_at", "status", "prev_hash", "hash" FROM "transaction_histories";
+
+--bun:split
+
+DROP TABLE "transaction_histories";
+
+--bun:split
+
+ALTER TABLE "transaction_histories_new" RENAME TO "transaction_histories";
+
+--bun:split
+
+CREATE TABLE "wallets_new" (
+    "id" VARCHAR NOT NULL,
+    "customer_id" VARCHAR NOT NULL,
+    "available_balance" decimal(24,8) NOT NULL,
+    "lien_balance" decimal(24,8) NOT NULL,
+    "currency_code" VARCHAR NOT NULL,
+    "is_closed" BOOLEAN DEFAULT false,
+    "frozen" BOOLEAN DEFAULT false,
+    "freeze_reason" VARCHAR,
+    "freeze_initiated_by" VARCHAR,
+    "frozen_at" TIMESTAMP NOT NULL,
+    "created_at" TIMESTAMP NOT NULL,
+    "updated_at" TIMESTAMP NOT NULL,
+    "version_id" VARCHAR NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+--bun:split
+
+INSERT INTO "wallets_new" ("id", "customer_id", "available_balance", "lien_balance", "currency_code", "is_closed", "frozen", "freeze_reason", "freeze_initiated_by", "frozen_at", "created_at", "updated_at", "version_id")
+SELECT "id", "customer_id", "available_balance", "lien_balance", "currency_code", "is_closed", "frozen", "freeze_reason", "freeze_initiated_by", "frozen_at", "created_at", "updated_at", "version_id" FROM "wallets";
+
+--bun:split
+
+DROP TABLE "wallets";
+
+--bun:split
+
+ALTER TABLE "wallets_new" RENAME TO "wallets";
@@ -0,0 +1,182 @@
+-- SQLite cannot add constraints to an existing table, so each constrained
+-- table is rebuilt. Fails if existing rows break the new rules; merge
+-- duplicate wallets and fix negative balances first.
+CREATE TABLE "wallets_new" (
+    "id" VARCHAR NOT NULL,
+    "customer_id" VARCHAR NOT NULL,
+    "available_balance" decimal(24,8) NOT NULL,
+    "lien_balance" decimal(24,8) NOT NULL,
+    "currency_code" VARCHAR NOT NULL,
+    "is_closed" BOOLEAN DEFAULT false,
+    "frozen" BOOLEAN DEFAULT false,
+    "freeze_reason" VARCHAR,
+    "freeze_initiated_by" VARCHAR,
+    "frozen_at" TIMESTAMP NOT NULL,
+    "created_at" TIMESTAMP NOT NULL,
+    "updated_at" TIMESTAMP NOT NULL,
+    "version_id" VARCHAR NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "wallets_customer_currency_key" UNIQUE ("customer_id", "currency_code"),
+    CONSTRAINT "wallets_available_balance_check" CHECK ("available_balance" >= 0),
+    CONSTRAINT "wallets_lien_balance_check" CHECK ("lien_balance" >= 0)
+);
+
+--bun:split
+
+INSERT INTO "wallets_new" ("id", "customer_id", "available_balance", "lien_balance", "currency_code", "is_closed", "frozen", "freeze_reason", "freeze_initiated_by", "frozen_at", "created_at", "updated_at", "version_id")
+SELECT "id", "customer_id", "available_balance", "lien_balance", "currency_code", "is_closed", "frozen", "freeze_reason", "freeze_initiated_by", "frozen_at", "created_at", "updated_at", "version_id" FROM "wallets";
+
+--bun:split
+
+DROP TABLE "wallets";
+
+--bun:split
+
+ALTER TABLE "wallets_new" RENAME TO "wallets";
+
+--bun:split
+
+CREATE TABLE "transaction_histories_new" (
+    "id" VARCHAR NOT NULL,
+    "wallet_id" VARCHAR NOT NULL,
+    "currency_code" VARCHAR NOT NULL,
+    "initiator_id" VARCHAR NOT NULL,
+    "external_reference" VARCHAR NOT NULL,
+    "category" VARCHAR NOT NULL,
+    "description" VARCHAR NOT NULL,
+    "amount" decimal(24,8) NOT NULL,
+    "fee" decimal(24,8) NOT NULL,
+    "type" VARCHAR NOT NULL,
+    "balance_before" decimal(24,8) NOT NULL,
+    "balance_after" decimal(24,8) NOT NULL,
+    "created_at" TIMESTAMP NOT NULL,
+    "updated_at" TIMESTAMP NOT NULL,
+    "status" VARCHAR NOT NULL,
+    "prev_hash" VARCHAR,
+    "hash" VARCHAR,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "transaction_histories_amount_check" CHECK ("amount" > 0),
+    CONSTRAINT "transaction_histories_fee_check" CHECK ("fee" >= 0)
+);
+
+--bun:split
+
+INSERT INTO "transaction_histories_new" ("id", "wallet_id", "currency_code", "initiator_id", "external_reference", "category", "description", "amount", "fee", "type", "balance_before", "balance_after", "created_at", "updated_at", "status", "prev_hash", "hash")
+SELECT "id", "wallet_id", "currency_code", "initiator_id", "external_reference", "category", "description", "amount", "fee", "type", "balance_before", "balance_after", "created_at", "updated_at", "status", "prev_hash", "hash" FROM "transaction_histories";
+
+--bun:split
+
+DROP TABLE "transaction_histories";
+
+--bun:split
+
+ALTER TABLE "transaction_histories_new" RENAME TO "transaction_histories";
+
+--bun:split
+
+-- Unreleased liens used to be stored with a zero release time
+UPDATE "lien_records" SET "released_at" = NULL WHERE "released_at" < '1000-01-01';
+
+--bun:split
+
+CREATE TABLE "lien_records_new" (
+    "id" VARCHAR NOT NULL,
+    "wallet_id" VARCHAR NOT NULL,
+    "amount" decimal(24,8) NOT NULL,
+    "description" VARCHAR,
+    "external_transaction_id" VARCHAR,
+    "created_at" TIMESTAMP NOT NULL,
+    "released_at" TIMESTAMP,
+    "lien_id" VARCHAR,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "lien_records_amount_check" CHECK ("amount" > 0)
+);
+
+--bun:split
+
+INSERT INTO "lien_records_new" ("id", "wallet_id", "amount", "description", "external_transaction_id", "created_at", "released_at", "lien_id")
+SELECT "id", "wallet_id", "amount", "description", "external_transaction_id", "created_at", "released_at", "lien_id" FROM "lien_records";
+
+--bun:split
+
+DROP TABLE "lien_records";
+
+--bun:split
+
+ALTER TABLE "lien_records_new" RENAME TO "lien_records";
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "transaction_histories_wallet_id_created_at_idx" ON "transaction_histories" ("wallet_id", "created_at");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "transaction_histories_external_reference_idx" ON "transaction_histories" ("external_reference");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "transaction_histories_created_at_idx" ON "transaction_histories" ("created_at");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "lien_records_wallet_id_created_at_idx" ON "lien_records" ("wallet_id", "created_at");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "lien_records_lien_id_idx" ON "lien_records" ("lien_id") WHERE "lien_id" IS NOT NULL;
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "wallet_status_changes_wallet_id_created_at_idx" ON "wallet_status_changes" ("wallet_id", "created_at");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "approval_requests_status_created_at_idx" ON "approval_requests" ("status", "created_at");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "approval_events_request_id_idx" ON "approval_events" ("request_id");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "risk_decisions_wallet_id_created_at_idx" ON "risk_decisions" ("wallet_id", "created_at");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "risk_decisions_movement_id_idx" ON "risk_decisions" ("movement_id");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "screening_cases_wallet_id_status_idx" ON "screening_cases" ("wallet_id", "status");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "aml_cases_status_created_at_idx" ON "aml_cases" ("status", "created_at");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "fx_trades_traded_at_idx" ON "fx_trades" ("traded_at");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "reconciliation_items_run_id_idx" ON "reconciliation_items" ("run_id");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "outbox_events_unpublished_idx" ON "outbox_events" ("id") WHERE "published_at" IS NULL;
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "outbox_events_aggregate_id_idx" ON "outbox_events" ("aggregate_id", "id");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "webhook_subscriptions_customer_id_idx" ON "webhook_subscriptions" ("customer_id");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "webhook_deliveries_status_next_attempt_at_idx" ON "webhook_deliveries" ("status", "next_attempt_at");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "webhook_attempts_delivery_id_idx" ON "webhook_attempts" ("delivery_id", "attempted_at");
@@ -0,0 +1,52 @@
+-- Batch payouts: one funding wallet paying many recipients, with each
+-- item's result kept so an interrupted batch can be resumed
+
+CREATE TABLE IF NOT EXISTS "payout_batches" (
+    "id" VARCHAR NOT NULL,
+    "reference" VARCHAR,
+    "source_wallet_id" VARCHAR NOT NULL,
+    "currency_code" VARCHAR NOT NULL,
+    "mode" VARCHAR NOT NULL,
+    "status" VARCHAR NOT NULL,
+    "description" VARCHAR,
+    "initiator_id" VARCHAR,
+    "transaction_category" VARCHAR NOT NULL,
+    "item_count" INTEGER NOT NULL,
+    "total_amount" decimal(24,8) NOT NULL,
+    "total_fee" decimal(24,8) NOT NULL,
+    "completed_count" INTEGER NOT NULL,
+    "failed_count" INTEGER NOT NULL,
+    "error" VARCHAR,
+    "created_at" TIMESTAMP NOT NULL,
+    "updated_at" TIMESTAMP NOT NULL,
+    "finished_at" TIMESTAMP,
+    PRIMARY KEY ("id"),
+    UNIQUE ("reference")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "payout_items" (
+    "id" VARCHAR NOT NULL,
+    "batch_id" VARCHAR NOT NULL,
+    "seq" INTEGER NOT NULL,
+    "destination_wallet_id" VARCHAR NOT NULL,
+    "amount" decimal(24,8) NOT NULL,
+    "fee" decimal(24,8) NOT NULL,
+    "reference" VARCHAR,
+    "description" VARCHAR,
+    "status" VARCHAR NOT NULL,
+    "error" VARCHAR,
+    "source_transaction_id" VARCHAR,
+    "destination_transaction_id" VARCHAR,
+    "processed_at" TIMESTAMP,
+    PRIMARY KEY ("id")
+);
+
+--bun:split
+
+CREATE UNIQUE INDEX IF NOT EXISTS "payout_items_batch_id_seq_idx" ON "payout_items" ("batch_id", "seq");
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "payout_batches_status_created_at_idx" ON "payout_batches" ("status", "created_at");
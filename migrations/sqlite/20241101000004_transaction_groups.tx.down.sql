@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "transaction_histories_group_id_idx";
+
+--bun:split
+
+ALTER TABLE "transaction_histories" DROP COLUMN "group_id";
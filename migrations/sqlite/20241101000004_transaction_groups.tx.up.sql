@@ -0,0 +1,8 @@
+-- Split payments: rows written together by one multi-leg movement share a
+-- group ID
+
+ALTER TABLE "transaction_histories" ADD COLUMN "group_id" VARCHAR;
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "transaction_histories_group_id_idx" ON "transaction_histories" ("group_id");
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "outbox_events_publish_sequence_idx";
+
+--bun:split
+
+ALTER TABLE "outbox_events" DROP COLUMN "publish_sequence";
@@ -0,0 +1,254 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is an outbox table held in memory
+type memoryStore struct {
+	mu       sync.Mutex
+	events   []*types.OutboxEvent
+	markFail bool
+}
+
+func (s *memoryStore) add(t *testing.T, eventType types.EventType, walletID string) {
+	event, err := types.NewOutboxEvent(eventType, walletID, &types.WalletEvent{Wallet: &types.Wallet{ID: walletID}})
+	require.NoError(t, err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	event.ID = int64(len(s.events) + 1)
+	s.events = append(s.events, event)
+}
+
+func (s *memoryStore) ListUnpublishedEvents(_ context.Context, limit int) ([]*types.OutboxEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pending []*types.OutboxEvent
+	for _, event := range s.events {
+		if event.PublishedAt.IsZero() && len(pending) < limit {
+			pending = append(pending, event)
+		}
+	}
+	return pending, nil
+}
+
+func (s *memoryStore) SequenceEvent(_ context.Context, event *types.OutboxEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if event.PublishSequence == 0 {
+		for _, e := range s.events {
+			event.PublishSequence = max(event.PublishSequence, e.PublishSequence)
+		}
+		event.PublishSequence++
+	}
+	return nil
+}
+
+func (s *memoryStore) MarkEventPublished(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.markFail {
+		return errors.New("database unavailable")
+	}
+	s.events[id-1].PublishedAt = time.Now().UTC()
+	return nil
+}
+
+func (s *memoryStore) MarkEventFailed(_ context.Context, id int64, cause error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events[id-1].Attempts++
+	s.events[id-1].LastError = cause.Error()
+	return nil
+}
+
+func TestRelayOnce(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStore{}
+	for i := 0; i < 5; i++ {
+		store.add(t, types.EventWalletCredited, "wt_1")
+	}
+
+	publisher := NewMemoryPublisher()
+	relay := NewRelay(store, publisher)
+	relay.BatchSize = 3
+
+	published, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, published)
+
+	published, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+
+	published, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, published)
+
+	events := publisher.Events()
+	require.Len(t, events, 5)
+	for i, event := range events {
+		assert.Equal(t, int64(i+1), event.ID)
+		assert.Equal(t, int64(i+1), event.PublishSequence)
+	}
+}
+
+func TestRelayStopsAtFailure(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStore{}
+	store.add(t, types.EventWalletCreated, "wt_1")
+	store.add(t, types.EventWalletCredited, "wt_1")
+	store.add(t, types.EventWalletDebited, "wt_1")
+
+	publisher := NewMemoryPublisher()
+	down := true
+	publisher.Fail = func(event *types.OutboxEvent) error {
+		if down && event.Type == types.EventWalletCredited {
+			return errors.New("broker unavailable")
+		}
+		return nil
+	}
+	relay := NewRelay(store, publisher)
+
+	published, err := relay.RelayOnce(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, 1, store.events[1].Attempts)
+	assert.Equal(t, "broker unavailable", store.events[1].LastError)
+	assert.True(t, store.events[2].PublishedAt.IsZero(), "later events wait for the failed one")
+
+	down = false
+	published, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+
+	var order []types.EventType
+	for _, event := range publisher.Events() {
+		order = append(order, event.Type)
+	}
+	assert.Equal(t, []types.EventType{types.EventWalletCreated, types.EventWalletCredited, types.EventWalletDebited}, order)
+}
+
+func TestRelayRedeliversUnmarkedEvents(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStore{markFail: true}
+	store.add(t, types.EventWalletCreated, "wt_1")
+
+	publisher := NewMemoryPublisher()
+	relay := NewRelay(store, publisher)
+
+	_, err := relay.RelayOnce(ctx)
+	assert.Error(t, err)
+
+	store.markFail = false
+	_, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+
+	// At-least-once: the event went out twice with the same ID
+	events := publisher.Events()
+	require.Len(t, events, 2)
+	assert.Equal(t, events[0].EventID, events[1].EventID)
+}
+
+func TestRelayRun(t *testing.T) {
+	store := &memoryStore{}
+	for i := 0; i < 7; i++ {
+		store.add(t, types.EventWalletCredited, "wt_1")
+	}
+
+	publisher := NewMemoryPublisher()
+	relay := NewRelay(store, publisher)
+	relay.BatchSize = 2
+	relay.Interval = 5 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- relay.Run(ctx, nil) }()
+
+	require.Eventually(t, func() bool { return len(publisher.Events()) == 7 }, time.Second, time.Millisecond)
+	store.add(t, types.EventWalletDebited, "wt_1")
+	require.Eventually(t, func() bool { return len(publisher.Events()) == 8 }, time.Second, time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestJSONLPublisher(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStore{}
+	store.add(t, types.EventWalletCreated, "wt_1")
+	store.add(t, types.EventLienPlaced, "wt_2")
+
+	var buf bytes.Buffer
+	_, err := NewRelay(store, NewJSONLPublisher(&buf)).RelayOnce(ctx)
+	require.NoError(t, err)
+
+	events, err := ReadJSONL(&buf)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, store.events[0].EventID, events[0].EventID)
+	assert.Equal(t, types.EventLienPlaced, events[1].Type)
+	assert.Equal(t, "wt_2", events[1].AggregateID)
+
+	payload, err := events[1].DecodeWalletEvent()
+	require.NoError(t, err)
+	assert.Equal(t, "wt_2", payload.Wallet.ID)
+}
+
+func TestOpenJSONLPublisherAppends(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	for _, walletID := range []string{"wt_1", "wt_2"} {
+		store := &memoryStore{}
+		store.add(t, types.EventWalletCreated, walletID)
+
+		publisher, err := OpenJSONLPublisher(path)
+		require.NoError(t, err)
+		_, err = NewRelay(store, publisher).RelayOnce(ctx)
+		require.NoError(t, err)
+		require.NoError(t, publisher.Close())
+	}
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	events, err := ReadJSONL(file)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "wt_1", events[0].AggregateID)
+	assert.Equal(t, "wt_2", events[1].AggregateID)
+}
+
+func TestFanout(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStore{}
+	store.add(t, types.EventWalletCreated, "wt_1")
+
+	first, second := NewMemoryPublisher(), NewMemoryPublisher()
+	second.Fail = func(*types.OutboxEvent) error { return errors.New("down") }
+	relay := NewRelay(store, Fanout{first, second})
+
+	_, err := relay.RelayOnce(ctx)
+	assert.Error(t, err)
+
+	second.Fail = nil
+	_, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Len(t, first.Events(), 2)
+	assert.Len(t, second.Events(), 1)
+}
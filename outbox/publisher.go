@@ -0,0 +1,135 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/otyang/waas-go/types"
+)
+
+// Publisher delivers outbox events to downstream consumers. Delivery is
+// at-least-once: an event may be published again if marking it published
+// fails, so consumers should deduplicate on EventID.
+type Publisher interface {
+	Publish(ctx context.Context, event *types.OutboxEvent) error
+}
+
+// MemoryPublisher keeps published events in memory
+type MemoryPublisher struct {
+	mu     sync.Mutex
+	events []*types.OutboxEvent
+	Fail   func(event *types.OutboxEvent) error // Fails delivery of an event when it returns an error (optional)
+}
+
+// NewMemoryPublisher creates an empty MemoryPublisher
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+// Publish records the event
+func (p *MemoryPublisher) Publish(_ context.Context, event *types.OutboxEvent) error {
+	if p.Fail != nil {
+		if err := p.Fail(event); err != nil {
+			return err
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+// Events returns the events published so far, in order
+func (p *MemoryPublisher) Events() []*types.OutboxEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*types.OutboxEvent(nil), p.events...)
+}
+
+// JSONLPublisher writes each event as a line of JSON
+type JSONLPublisher struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewJSONLPublisher creates a JSONLPublisher writing to w
+func NewJSONLPublisher(w io.Writer) *JSONLPublisher {
+	return &JSONLPublisher{w: w}
+}
+
+// OpenJSONLPublisher creates a JSONLPublisher appending to the file at path
+func OpenJSONLPublisher(path string) (*JSONLPublisher, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event log: %w", err)
+	}
+	return &JSONLPublisher{w: file, closer: file}, nil
+}
+
+// Publish appends the event as one line
+func (p *JSONLPublisher) Publish(_ context.Context, event *types.OutboxEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	return nil
+}
+
+// Close closes the underlying file when the publisher opened it
+func (p *JSONLPublisher) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+	return p.closer.Close()
+}
+
+// ReadJSONL reads events written by a JSONLPublisher
+func ReadJSONL(r io.Reader) ([]*types.OutboxEvent, error) {
+	var events []*types.OutboxEvent
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		event := new(types.OutboxEvent)
+		if err := json.Unmarshal(scanner.Bytes(), event); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Fanout publishes each event to several publishers in turn, stopping at the
+// first failure. The relay then retries the event on every publisher, so the
+// ones that succeeded see it again.
+type Fanout []Publisher
+
+// Publish sends the event to every publisher
+func (f Fanout) Publish(ctx context.Context, event *types.OutboxEvent) error {
+	for _, publisher := range f {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
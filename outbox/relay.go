@@ -0,0 +1,98 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+)
+
+// Relay defaults
+const (
+	DefaultBatchSize = 100
+	DefaultInterval  = time.Second
+)
+
+// Store is the outbox table the relay drains. store.WalletRepository implements it.
+type Store interface {
+	ListUnpublishedEvents(ctx context.Context, limit int) ([]*types.OutboxEvent, error)
+	SequenceEvent(ctx context.Context, event *types.OutboxEvent) error
+	MarkEventPublished(ctx context.Context, id int64) error
+	MarkEventFailed(ctx context.Context, id int64, cause error) error
+}
+
+// Relay publishes outbox events in the order they were written, giving each
+// its publish sequence just before it is published. Run a single relay per
+// outbox table; a second one would publish events twice and race for
+// sequences.
+type Relay struct {
+	store     Store
+	publisher Publisher
+	BatchSize int           // Events loaded per batch (DefaultBatchSize when zero)
+	Interval  time.Duration // Wait between polls of an empty outbox (DefaultInterval when zero)
+}
+
+// NewRelay creates a Relay publishing events from store through publisher
+func NewRelay(store Store, publisher Publisher) *Relay {
+	return &Relay{store: store, publisher: publisher}
+}
+
+// RelayOnce publishes one batch of events and returns how many were
+// published. It stops at the first event that fails to publish, so events are
+// never delivered out of order; that event is retried on the next call.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	batch := r.BatchSize
+	if batch <= 0 {
+		batch = DefaultBatchSize
+	}
+
+	events, err := r.store.ListUnpublishedEvents(ctx, batch)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, event := range events {
+		if err := r.store.SequenceEvent(ctx, event); err != nil {
+			return i, err
+		}
+		if err := r.publisher.Publish(ctx, event); err != nil {
+			return i, errors.Join(err, r.store.MarkEventFailed(ctx, event.ID, err))
+		}
+		if err := r.store.MarkEventPublished(ctx, event.ID); err != nil {
+			return i, err
+		}
+	}
+
+	return len(events), nil
+}
+
+// Run relays events until ctx is cancelled, draining full batches back to
+// back and polling at Interval once the outbox is empty. Publish failures are
+// retried on the next poll; onError, if set, is told about each one.
+func (r *Relay) Run(ctx context.Context, onError func(error)) error {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	batch := r.BatchSize
+	if batch <= 0 {
+		batch = DefaultBatchSize
+	}
+
+	for {
+		published, err := r.RelayOnce(ctx)
+		if err != nil && onError != nil {
+			onError(err)
+		}
+		if err == nil && published == batch {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Package provision keeps every customer's wallets in step with the currency
+// catalogue: when a currency is enabled, the Backfiller creates a wallet in
+// it for each existing customer.
+package provision
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+)
+
+// Backfiller defaults
+const (
+	DefaultBatchSize = 100
+	DefaultInterval  = time.Minute
+)
+
+// Store lists the catalogue and creates the missing wallets.
+// store.WalletRepository implements it.
+type Store interface {
+	ListCurrencies(ctx context.Context) ([]*types.CurrencyInfo, error)
+	ListCustomersWithoutWallet(ctx context.Context, currencyCode string, limit int) ([]string, error)
+	BackfillWallets(ctx context.Context, currencyCode string, customerIDs []string) ([]*types.Wallet, error)
+}
+
+// Backfiller creates a wallet in every enabled currency for each customer that
+// has a wallet at all. Customers are processed in batches, each in its own
+// database transaction, so a large backfill holds no long locks and resumes
+// where it stopped after a failure. Running more than one is safe; wallets
+// are only ever created once.
+type Backfiller struct {
+	store     Store
+	BatchSize int           // Customers per transaction (DefaultBatchSize when zero)
+	Interval  time.Duration // Wait between checks of the catalogue (DefaultInterval when zero)
+}
+
+// BackfillReport summarizes one pass over the catalogue
+type BackfillReport struct {
+	Created map[string]int // Wallets created per currency code
+}
+
+// Total returns the number of wallets created
+func (r *BackfillReport) Total() int {
+	total := 0
+	for _, n := range r.Created {
+		total += n
+	}
+	return total
+}
+
+// NewBackfiller creates a Backfiller creating wallets through store
+func NewBackfiller(store Store) *Backfiller {
+	return &Backfiller{store: store}
+}
+
+// BackfillOnce creates every missing wallet in the enabled currencies and
+// reports how many it created. A currency that fails is reported and the
+// rest are still backfilled.
+func (b *Backfiller) BackfillOnce(ctx context.Context) (*BackfillReport, error) {
+	currencies, err := b.store.ListCurrencies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &BackfillReport{Created: map[string]int{}}
+	var errs []error
+	for _, currency := range currencies {
+		if currency.Disabled {
+			continue
+		}
+
+		created, err := b.BackfillCurrency(ctx, currency.Code)
+		if created > 0 {
+			report.Created[currency.Code] = created
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return report, errors.Join(errs...)
+}
+
+// BackfillCurrency creates a wallet in currencyCode for every customer without
+// one, a batch at a time, and returns how many it created
+func (b *Backfiller) BackfillCurrency(ctx context.Context, currencyCode string) (int, error) {
+	batch := b.BatchSize
+	if batch <= 0 {
+		batch = DefaultBatchSize
+	}
+
+	total := 0
+	for {
+		customerIDs, err := b.store.ListCustomersWithoutWallet(ctx, currencyCode, batch)
+		if err != nil {
+			return total, err
+		}
+		if len(customerIDs) == 0 {
+			return total, nil
+		}
+
+		created, err := b.store.BackfillWallets(ctx, currencyCode, customerIDs)
+		total += len(created)
+		if err != nil {
+			return total, err
+		}
+		if len(customerIDs) < batch {
+			return total, nil
+		}
+	}
+}
+
+// Run backfills until ctx is cancelled, checking the catalogue at Interval,
+// so a newly enabled currency is picked up on the next check. Failures are
+// retried on the next check; onError, if set, is told about each one.
+func (b *Backfiller) Run(ctx context.Context, onError func(error)) error {
+	interval := b.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	for {
+		if _, err := b.BackfillOnce(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package provision
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/otyang/waas-go/migrations"
+	"github.com/otyang/waas-go/store"
+	"github.com/otyang/waas-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+)
+
+func newRepository(t *testing.T) *store.WalletRepository {
+	t.Helper()
+
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	sqldb, err := sql.Open(sqliteshim.ShimName, fmt.Sprintf("file:%s?mode=memory&cache=shared", name))
+	require.NoError(t, err)
+	sqldb.SetMaxOpenConns(1)
+
+	db := bun.NewDB(sqldb, sqlitedialect.New())
+	t.Cleanup(func() { db.Close() })
+
+	_, err = migrations.Up(context.Background(), db)
+	require.NoError(t, err)
+
+	repo := store.NewWalletRepository(db)
+	repo.EnableOutbox(true)
+	return repo
+}
+
+func addCurrency(t *testing.T, repo *store.WalletRepository, code string, disabled bool) {
+	t.Helper()
+	_, err := repo.CreateCurrency(context.Background(), &types.CurrencyInfo{Code: code, Disabled: disabled})
+	require.NoError(t, err)
+}
+
+func walletCreatedEvents(t *testing.T, repo *store.WalletRepository) int {
+	t.Helper()
+	events, err := repo.ListUnpublishedEvents(context.Background(), 1000)
+	require.NoError(t, err)
+
+	n := 0
+	for _, event := range events {
+		if event.Type == types.EventWalletCreated {
+			n++
+		}
+	}
+	return n
+}
+
+func TestProvisionWallets(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t)
+	addCurrency(t, repo, "USD", false)
+	addCurrency(t, repo, "EUR", false)
+	addCurrency(t, repo, "GBP", true)
+
+	existing, err := repo.CreateSimplified(ctx, "cus_1", "EUR")
+	require.NoError(t, err)
+
+	// Every enabled currency, keeping the wallet the customer already had
+	result, err := repo.ProvisionWallets(ctx, "cus_1", nil)
+	require.NoError(t, err)
+	require.Len(t, result.Wallets, 2)
+	assert.Equal(t, "EUR", result.Wallets[0].CurrencyCode)
+	assert.Equal(t, existing.ID, result.Wallets[0].ID)
+	assert.Equal(t, "USD", result.Wallets[1].CurrencyCode)
+	assert.Equal(t, []string{"USD"}, result.Created)
+	assert.Equal(t, 2, walletCreatedEvents(t, repo))
+
+	// Again changes nothing
+	again, err := repo.ProvisionWallets(ctx, "cus_1", nil)
+	require.NoError(t, err)
+	assert.Empty(t, again.Created)
+	assert.Equal(t, result.Wallets[1].ID, again.Wallets[1].ID)
+	assert.Equal(t, 2, walletCreatedEvents(t, repo))
+
+	// An explicit list is used as given, in order and without duplicates
+	listed, err := repo.ProvisionWallets(ctx, "cus_2", []string{"gbp", "USD", "GBP"})
+	require.NoError(t, err)
+	require.Len(t, listed.Wallets, 2)
+	assert.Equal(t, "GBP", listed.Wallets[0].CurrencyCode)
+	assert.Equal(t, []string{"GBP", "USD"}, listed.Created)
+
+	_, err = repo.ProvisionWallets(ctx, " ", nil)
+	assert.ErrorIs(t, err, store.ErrCustomerIDRequired)
+	_, err = repo.ProvisionWallets(ctx, "cus_3", []string{"USD", ""})
+	assert.ErrorIs(t, err, store.ErrInvalidCurrencyCode)
+}
+
+func TestProvisionWalletsConcurrent(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t)
+	addCurrency(t, repo, "USD", false)
+	addCurrency(t, repo, "EUR", false)
+
+	const callers = 6
+	var wg sync.WaitGroup
+	results := make([]*store.ProvisionResult, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = repo.ProvisionWallets(ctx, "cus_1", nil)
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for i := range results {
+		require.NoError(t, errs[i])
+		created += len(results[i].Created)
+		assert.Equal(t, results[0].Wallets[0].ID, results[i].Wallets[0].ID)
+		assert.Equal(t, results[0].Wallets[1].ID, results[i].Wallets[1].ID)
+	}
+	assert.Equal(t, 2, created)
+	assert.Equal(t, 2, walletCreatedEvents(t, repo))
+}
+
+func TestProvisionWalletsEmptyCatalogue(t *testing.T) {
+	repo := newRepository(t)
+	addCurrency(t, repo, "USD", true)
+
+	_, err := repo.ProvisionWallets(context.Background(), "cus_1", nil)
+	assert.ErrorIs(t, err, store.ErrNoEnabledCurrencies)
+}
+
+func TestBackfiller(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t)
+	addCurrency(t, repo, "USD", false)
+
+	for i := 1; i <= 7; i++ {
+		_, err := repo.CreateSimplified(ctx, fmt.Sprintf("cus_%d", i), "USD")
+		require.NoError(t, err)
+	}
+	_, err := repo.CreateSimplified(ctx, "cus_3", "NGN")
+	require.NoError(t, err)
+
+	backfiller := NewBackfiller(repo)
+	backfiller.BatchSize = 3
+
+	// Nothing is missing yet
+	report, err := backfiller.BackfillOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, report.Total())
+
+	// Enabling a currency gives every customer a wallet in it
+	addCurrency(t, repo, "EUR", false)
+	addCurrency(t, repo, "GBP", true)
+	report, err = backfiller.BackfillOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"EUR": 7}, report.Created)
+	assert.Equal(t, 7, report.Total())
+
+	for i := 1; i <= 7; i++ {
+		_, err := repo.FindWalletByCurrency(ctx, fmt.Sprintf("cus_%d", i), "EUR")
+		assert.NoError(t, err)
+	}
+	_, err = repo.FindWalletByCurrency(ctx, "cus_1", "GBP")
+	assert.ErrorIs(t, err, store.ErrWalletNotFound)
+
+	// A second pass finds nothing to do
+	report, err = backfiller.BackfillOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, report.Total())
+	assert.Equal(t, 7+1+7, walletCreatedEvents(t, repo))
+}
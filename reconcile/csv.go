@@ -0,0 +1,85 @@
+package reconcile
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/otyang/waas-go/types"
+)
+
+// CSVParser reads settlement reports with a header row
+type CSVParser struct {
+	Mapping   FieldMapping // Header names of the mapped fields
+	Delimiter rune         // Field delimiter (comma when zero)
+}
+
+// NewCSVParser creates a CSVParser for the given mapping
+func NewCSVParser(mapping FieldMapping) (*CSVParser, error) {
+	if err := mapping.Validate(); err != nil {
+		return nil, err
+	}
+	return &CSVParser{Mapping: mapping}, nil
+}
+
+// Parse reads all records from r. Header names are matched case-insensitively.
+func (p *CSVParser) Parse(r io.Reader) ([]types.SettlementRecord, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	if p.Delimiter != 0 {
+		reader.Comma = p.Delimiter
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settlement header: %w", err)
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	column := func(name string) (int, error) {
+		if name == "" {
+			return -1, nil
+		}
+		i, ok := index[strings.ToLower(name)]
+		if !ok {
+			return 0, fmt.Errorf("%w: %s", ErrMissingColumn, name)
+		}
+		return i, nil
+	}
+
+	var cols [4]int
+	for i, name := range []string{p.Mapping.Reference, p.Mapping.Amount, p.Mapping.Currency, p.Mapping.Date} {
+		if cols[i], err = column(name); err != nil {
+			return nil, err
+		}
+	}
+	value := func(row []string, i int) string {
+		if i < 0 || i >= len(row) {
+			return ""
+		}
+		return row[i]
+	}
+
+	var records []types.SettlementRecord
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read settlement line %d: %w", line, err)
+		}
+
+		rec, err := p.Mapping.record(line, value(row, cols[0]), value(row, cols[1]), value(row, cols[2]), value(row, cols[3]))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+
+	return records, nil
+}
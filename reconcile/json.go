@@ -0,0 +1,75 @@
+package reconcile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/otyang/waas-go/types"
+)
+
+// JSONParser reads settlement reports shaped as an array of objects, either
+// at the top level or under RecordsKey
+type JSONParser struct {
+	Mapping    FieldMapping // Object keys of the mapped fields
+	RecordsKey string       // Key of the array in a wrapping object (optional)
+}
+
+// NewJSONParser creates a JSONParser for the given mapping
+func NewJSONParser(mapping FieldMapping, recordsKey string) (*JSONParser, error) {
+	if err := mapping.Validate(); err != nil {
+		return nil, err
+	}
+	return &JSONParser{Mapping: mapping, RecordsKey: recordsKey}, nil
+}
+
+// Parse reads all records from r. Amounts may be JSON numbers or strings.
+func (p *JSONParser) Parse(r io.Reader) ([]types.SettlementRecord, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	var rows []map[string]any
+	if p.RecordsKey == "" {
+		if err := decoder.Decode(&rows); err != nil {
+			return nil, fmt.Errorf("failed to decode settlement file: %w", err)
+		}
+	} else {
+		var wrapper map[string]json.RawMessage
+		if err := decoder.Decode(&wrapper); err != nil {
+			return nil, fmt.Errorf("failed to decode settlement file: %w", err)
+		}
+		raw, ok := wrapper[p.RecordsKey]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingColumn, p.RecordsKey)
+		}
+		inner := json.NewDecoder(bytes.NewReader(raw))
+		inner.UseNumber()
+		if err := inner.Decode(&rows); err != nil {
+			return nil, fmt.Errorf("failed to decode settlement records: %w", err)
+		}
+	}
+
+	records := make([]types.SettlementRecord, 0, len(rows))
+	for i, row := range rows {
+		field := func(key string) string {
+			if key == "" {
+				return ""
+			}
+			v, ok := row[key]
+			if !ok || v == nil {
+				return ""
+			}
+			return fmt.Sprint(v)
+		}
+
+		rec, err := p.Mapping.record(i+1, field(p.Mapping.Reference), field(p.Mapping.Amount),
+			field(p.Mapping.Currency), field(p.Mapping.Date))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+
+	return records, nil
+}
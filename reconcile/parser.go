@@ -0,0 +1,104 @@
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+)
+
+// Error definitions for settlement parsing
+var (
+	ErrUnknownSource  = errors.New("no settlement parser registered for source")
+	ErrMissingColumn  = errors.New("settlement file is missing a mapped column")
+	ErrInvalidMapping = errors.New("settlement field mapping is incomplete")
+)
+
+// SettlementParser turns a processor or bank settlement report into records
+type SettlementParser interface {
+	Parse(r io.Reader) ([]types.SettlementRecord, error)
+}
+
+// FieldMapping names the fields of a settlement report that feed matching
+type FieldMapping struct {
+	Reference       string // Field holding the external reference
+	Amount          string // Field holding the settled amount
+	Currency        string // Field holding the currency (optional with DefaultCurrency)
+	Date            string // Field holding the settlement date
+	DateLayout      string // time.Parse layout for Date (RFC 3339 when empty)
+	DefaultCurrency string // Currency used when the report has no currency field
+}
+
+// Validate checks that the mapping names every required field
+func (m FieldMapping) Validate() error {
+	switch {
+	case m.Reference == "", m.Amount == "", m.Date == "":
+		return fmt.Errorf("%w: reference, amount and date fields are required", ErrInvalidMapping)
+	case m.Currency == "" && m.DefaultCurrency == "":
+		return fmt.Errorf("%w: currency field or default currency is required", ErrInvalidMapping)
+	}
+	return nil
+}
+
+// record builds a settlement record from raw field values
+func (m FieldMapping) record(line int, reference, amount, currency, date string) (types.SettlementRecord, error) {
+	rec := types.SettlementRecord{
+		ExternalReference: strings.TrimSpace(reference),
+		CurrencyCode:      strings.ToUpper(strings.TrimSpace(currency)),
+		Line:              line,
+	}
+	if rec.CurrencyCode == "" {
+		rec.CurrencyCode = m.DefaultCurrency
+	}
+
+	value, err := decimal.NewFromString(strings.ReplaceAll(strings.TrimSpace(amount), ",", ""))
+	if err != nil {
+		return rec, fmt.Errorf("%w: line %d: amount %q", types.ErrInvalidSettlementRecord, line, amount)
+	}
+	rec.Amount = value.Abs() // Processors sign refunds and payouts differently
+
+	layout := m.DateLayout
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	rec.SettledAt, err = time.Parse(layout, strings.TrimSpace(date))
+	if err != nil {
+		return rec, fmt.Errorf("%w: line %d: date %q", types.ErrInvalidSettlementRecord, line, date)
+	}
+
+	return rec, rec.Validate()
+}
+
+// Registry holds the settlement parser configured for each source
+type Registry struct {
+	mu      sync.RWMutex
+	parsers map[string]SettlementParser
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{parsers: make(map[string]SettlementParser)}
+}
+
+// Register sets the parser used for a source, replacing any previous one
+func (r *Registry) Register(source string, parser SettlementParser) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.parsers[source] = parser
+}
+
+// Parse parses a settlement report with the parser registered for source
+func (r *Registry) Parse(source string, rd io.Reader) ([]types.SettlementRecord, error) {
+	r.mu.RLock()
+	parser, ok := r.parsers[source]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSource, source)
+	}
+	return parser.Parse(rd)
+}
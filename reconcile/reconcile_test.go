@@ -0,0 +1,100 @@
+package reconcile
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSVParser(t *testing.T) {
+	parser, err := NewCSVParser(FieldMapping{
+		Reference:  "Payment Ref",
+		Amount:     "Net",
+		Currency:   "CCY",
+		Date:       "Value Date",
+		DateLayout: "2006-01-02",
+	})
+	assert.NoError(t, err)
+
+	input := "payment ref,Net,CCY,Value Date,Ignored\n" +
+		"ref-1,\"1,250.50\",usd,2024-03-01,x\n" +
+		"ref-2,-40,EUR,2024-03-02,y\n"
+
+	records, err := parser.Parse(strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "ref-1", records[0].ExternalReference)
+	assert.Equal(t, "1250.5", records[0].Amount.String())
+	assert.Equal(t, "USD", records[0].CurrencyCode)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), records[0].SettledAt)
+	assert.Equal(t, 2, records[0].Line)
+	assert.Equal(t, "40", records[1].Amount.String())
+
+	_, err = parser.Parse(strings.NewReader("Payment Ref,Net,Value Date\nref,1,2024-03-01\n"))
+	assert.ErrorIs(t, err, ErrMissingColumn)
+
+	_, err = parser.Parse(strings.NewReader(input + "ref-3,abc,USD,2024-03-02,z\n"))
+	assert.ErrorIs(t, err, types.ErrInvalidSettlementRecord)
+
+	_, err = NewCSVParser(FieldMapping{Reference: "ref", Amount: "amount", Date: "date"})
+	assert.ErrorIs(t, err, ErrInvalidMapping)
+}
+
+func TestJSONParser(t *testing.T) {
+	mapping := FieldMapping{Reference: "id", Amount: "amount", Date: "settled_at", DefaultCurrency: "NGN"}
+
+	parser, err := NewJSONParser(mapping, "data")
+	assert.NoError(t, err)
+
+	records, err := parser.Parse(strings.NewReader(`{"data":[
+		{"id":"ref-1","amount":1500.25,"settled_at":"2024-03-01T10:00:00Z"},
+		{"id":"ref-2","amount":"20","settled_at":"2024-03-01T11:00:00Z"}
+	]}`))
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "1500.25", records[0].Amount.String())
+	assert.Equal(t, "NGN", records[0].CurrencyCode)
+	assert.Equal(t, 2, records[1].Line)
+
+	parser.RecordsKey = ""
+	records, err = parser.Parse(strings.NewReader(`[{"id":"ref-3","amount":1,"settled_at":"2024-03-01T10:00:00Z"}]`))
+	assert.NoError(t, err)
+	assert.Equal(t, "ref-3", records[0].ExternalReference)
+}
+
+func TestRegistryAndExceptionsReport(t *testing.T) {
+	registry := NewRegistry()
+	parser, err := NewCSVParser(FieldMapping{Reference: "ref", Amount: "amount", Currency: "ccy", Date: "date", DateLayout: "2006-01-02"})
+	assert.NoError(t, err)
+	registry.Register("acme", parser)
+
+	_, err = registry.Parse("unknown", strings.NewReader(""))
+	assert.ErrorIs(t, err, ErrUnknownSource)
+
+	records, err := registry.Parse("acme", strings.NewReader("ref,amount,ccy,date\nref-1,10,USD,2024-03-01\nref-2,5,USD,2024-03-01\n"))
+	assert.NoError(t, err)
+
+	ledger := []*types.TransactionHistory{{
+		ID:                "txn_1",
+		WalletID:          "wt_1",
+		ExternalReference: "ref-1",
+		CurrencyCode:      "USD",
+		Amount:            decimal.NewFromInt(10),
+		Status:            types.StatusCompleted,
+		CreatedAt:         time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+	}}
+	run, err := types.Reconcile("acme", records, ledger, types.ReconciliationOptions{DateTolerance: 24 * time.Hour})
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteExceptionsReport(&buf, run))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "MISSING_INTERNAL,ref-2,,,USD,5,0,5,2024-03-01T00:00:00Z,,3,no transaction with this reference", lines[1])
+}
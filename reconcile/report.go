@@ -0,0 +1,58 @@
+package reconcile
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+)
+
+// exceptionsHeader is the header row of the exceptions report
+var exceptionsHeader = []string{
+	"Status", "External Reference", "Transaction ID", "Wallet ID", "Currency",
+	"External Amount", "Internal Amount", "Difference", "External Date", "Internal Date", "Source Line", "Reason",
+}
+
+// WriteExceptionsReport writes the unmatched items of a run as CSV
+func WriteExceptionsReport(w io.Writer, run *types.ReconciliationRun) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exceptionsHeader); err != nil {
+		return err
+	}
+
+	date := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	for _, item := range run.Exceptions() {
+		line := ""
+		if item.SourceLine > 0 {
+			line = strconv.Itoa(item.SourceLine)
+		}
+		err := writer.Write([]string{
+			string(item.Status),
+			item.ExternalReference,
+			item.TransactionID,
+			item.WalletID,
+			item.CurrencyCode,
+			item.ExternalAmount.String(),
+			item.InternalAmount.String(),
+			item.Difference.String(),
+			date(item.ExternalDate),
+			date(item.InternalDate),
+			line,
+			item.Reason,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,63 @@
+package screening
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseCSV reads a list with a header row. The id and name columns are
+// required; aliases and programs (;-separated) and type are optional.
+func ParseCSV(r io.Reader, listName string) (*Watchlist, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read header: %v", ErrInvalidList, err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"id", "name"} {
+		if _, ok := index[required]; !ok {
+			return nil, fmt.Errorf("%w: missing %q column", ErrInvalidList, required)
+		}
+	}
+
+	field := func(row []string, name string) string {
+		i, ok := index[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	list := &Watchlist{Name: listName}
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidList, line, err)
+		}
+
+		entry := &Entry{
+			ID:       field(row, "id"),
+			Name:     field(row, "name"),
+			Aliases:  splitList(field(row, "aliases")),
+			Type:     field(row, "type"),
+			Programs: splitList(field(row, "programs")),
+		}
+		if entry.ID == "" || entry.Name == "" {
+			return nil, fmt.Errorf("%w: line %d: id and name are required", ErrInvalidList, line)
+		}
+		list.Entries = append(list.Entries, entry)
+	}
+
+	return list, nil
+}
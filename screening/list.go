@@ -0,0 +1,139 @@
+package screening
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Error definitions for list loading
+var (
+	ErrUnsupportedList = errors.New("unsupported watch list format")
+	ErrInvalidList     = errors.New("invalid watch list file")
+)
+
+// Entry is a sanctioned person or organisation
+type Entry struct {
+	ID       string   // Identifier within the list
+	Name     string   // Primary name
+	Aliases  []string // Also-known-as names
+	Type     string   // Individual, Entity, Vessel, ...
+	Programs []string // Sanctions programmes the entry is listed under
+}
+
+// Names returns the primary name followed by the aliases
+func (e *Entry) Names() []string {
+	return append([]string{e.Name}, e.Aliases...)
+}
+
+// Watchlist is a named collection of entries loaded from one source
+type Watchlist struct {
+	Name    string   // List name recorded on matches (e.g. OFAC-SDN)
+	Entries []*Entry // Listed parties
+}
+
+// LoadFile loads a list file, choosing the format from its extension and,
+// for XML, its root element. Supported formats are CSV, the OFAC SDN XML and
+// the UN consolidated list XML. The list name defaults to the file name.
+func LoadFile(path, listName string) (*Watchlist, error) {
+	if listName == "" {
+		listName = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open watch list: %w", err)
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return ParseCSV(f, listName)
+	case ".xml":
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read watch list: %w", err)
+		}
+		switch root, err := rootElement(data); {
+		case err != nil:
+			return nil, fmt.Errorf("%w: %v", ErrInvalidList, err)
+		case root == "sdnList":
+			return ParseOFAC(bytes.NewReader(data), listName)
+		case root == "CONSOLIDATED_LIST":
+			return ParseUN(bytes.NewReader(data), listName)
+		default:
+			return nil, fmt.Errorf("%w: XML root <%s>", ErrUnsupportedList, root)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedList, path)
+	}
+}
+
+// LoadDir loads every .csv and .xml file in dir, in name order
+func LoadDir(dir string) ([]*Watchlist, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watch list directory: %w", err)
+	}
+
+	var names []string
+	for _, f := range files {
+		ext := strings.ToLower(filepath.Ext(f.Name()))
+		if !f.IsDir() && (ext == ".csv" || ext == ".xml") {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+
+	lists := make([]*Watchlist, 0, len(names))
+	for _, name := range names {
+		list, err := LoadFile(filepath.Join(dir, name), "")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		lists = append(lists, list)
+	}
+	return lists, nil
+}
+
+// rootElement returns the local name of the first XML element
+func rootElement(data []byte) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// splitList splits a ;-separated field, dropping blanks
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ";") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// joinName joins name parts with single spaces, skipping blanks
+func joinName(parts ...string) string {
+	var kept []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, " ")
+}
@@ -0,0 +1,201 @@
+package screening
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/otyang/waas-go/types"
+)
+
+// DefaultThreshold is the similarity at or above which a name is a hit
+const DefaultThreshold = 0.9
+
+// Screener fuzzy-matches names against watch lists.
+// It implements types.NameScreener.
+type Screener struct {
+	Threshold float64 // Minimum similarity for a match (DefaultThreshold when zero)
+	lists     []*Watchlist
+	names     []indexedName
+}
+
+// indexedName is a pre-normalised entry name
+type indexedName struct {
+	list   string
+	entry  *Entry
+	name   string // Name as listed
+	norm   string // Normalised name
+	sorted string // Normalised tokens in sorted order
+}
+
+// NewScreener creates a Screener over the given lists
+func NewScreener(threshold float64, lists ...*Watchlist) *Screener {
+	s := &Screener{Threshold: threshold}
+	for _, list := range lists {
+		s.Add(list)
+	}
+	return s
+}
+
+// Add indexes another list
+func (s *Screener) Add(list *Watchlist) {
+	s.lists = append(s.lists, list)
+	for _, entry := range list.Entries {
+		for _, name := range entry.Names() {
+			norm := normalizeName(name)
+			if norm == "" {
+				continue
+			}
+			s.names = append(s.names, indexedName{
+				list: list.Name, entry: entry, name: name, norm: norm, sorted: sortTokens(norm),
+			})
+		}
+	}
+}
+
+// ScreenName returns the entries whose best-matching name scores at least the
+// threshold, strongest first
+func (s *Screener) ScreenName(name string) []types.ScreeningMatch {
+	norm := normalizeName(name)
+	if norm == "" {
+		return nil
+	}
+	sorted := sortTokens(norm)
+
+	threshold := s.Threshold
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+
+	best := make(map[string]types.ScreeningMatch)
+	for _, candidate := range s.names {
+		score := NameSimilarity(norm, sorted, candidate.norm, candidate.sorted)
+		if score < threshold {
+			continue
+		}
+		match := types.ScreeningMatch{
+			ListName:    candidate.list,
+			EntryID:     candidate.entry.ID,
+			EntryName:   candidate.entry.Name,
+			MatchedName: candidate.name,
+			Score:       score,
+		}
+		if prev, ok := best[match.Key()]; !ok || score > prev.Score {
+			best[match.Key()] = match
+		}
+	}
+
+	matches := make([]types.ScreeningMatch, 0, len(best))
+	for _, m := range best {
+		matches = append(matches, m)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Key() < matches[j].Key()
+	})
+
+	return matches
+}
+
+// NameSimilarity scores two normalised names between 0 and 1, taking the
+// better of a direct comparison and one that ignores word order
+func NameSimilarity(a, aSorted, b, bSorted string) float64 {
+	direct := jaroWinkler(a, b)
+	if reordered := jaroWinkler(aSorted, bSorted); reordered > direct {
+		return reordered
+	}
+	return direct
+}
+
+// latinFold maps common accented letters to their ASCII base
+var latinFold = map[rune]rune{
+	'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a', 'ç': 'c',
+	'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e', 'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i',
+	'ñ': 'n', 'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o', 'ø': 'o',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u', 'ý': 'y', 'ÿ': 'y', 'ß': 's',
+}
+
+// normalizeName lowercases, folds accents, turns punctuation into spaces and
+// collapses whitespace
+func normalizeName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if folded, ok := latinFold[r]; ok {
+			r = folded
+		}
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == '\'':
+			// O'Brien and OBrien are the same name
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// sortTokens returns the words of a normalised name in sorted order
+func sortTokens(norm string) string {
+	tokens := strings.Fields(norm)
+	sort.Strings(tokens)
+	return strings.Join(tokens, " ")
+}
+
+// jaroWinkler returns the Jaro-Winkler similarity of two strings
+func jaroWinkler(a, b string) float64 {
+	s1, s2 := []rune(a), []rune(b)
+	if len(s1) == 0 || len(s2) == 0 {
+		if len(s1) == len(s2) {
+			return 1
+		}
+		return 0
+	}
+
+	window := max(len(s1), len(s2))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+
+	matched1 := make([]bool, len(s1))
+	matched2 := make([]bool, len(s2))
+	matches := 0
+	for i := range s1 {
+		lo, hi := max(0, i-window), min(len(s2), i+window+1)
+		for j := lo; j < hi; j++ {
+			if !matched2[j] && s1[i] == s2[j] {
+				matched1[i], matched2[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions, k := 0, 0
+	for i := range s1 {
+		if !matched1[i] {
+			continue
+		}
+		for !matched2[k] {
+			k++
+		}
+		if s1[i] != s2[k] {
+			transpositions++
+		}
+		k++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(s1)) + m/float64(len(s2)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < 4 && prefix < len(s1) && prefix < len(s2) && s1[prefix] == s2[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
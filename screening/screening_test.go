@@ -0,0 +1,111 @@
+package screening
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		file    string
+		entries int
+		id      string
+		name    string
+		aliases []string
+	}{
+		{"local.csv", 2, "L-1", "Viktor Petrovich Bout", []string{"Victor Bout", "Viktor Butt"}},
+		{"sdn.xml", 2, "2674", "Abu ABBAS", []string{"Mohammed ZIDAN"}},
+		{"un.xml", 2, "6908555", "RI WON HO", []string{"Ri Won-ho"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			list, err := LoadFile(filepath.Join("testdata", tt.file), "")
+			require.NoError(t, err)
+			assert.Equal(t, strings.TrimSuffix(tt.file, filepath.Ext(tt.file)), list.Name)
+			require.Len(t, list.Entries, tt.entries)
+
+			var found *Entry
+			for _, e := range list.Entries {
+				if e.ID == tt.id {
+					found = e
+				}
+			}
+			require.NotNil(t, found)
+			assert.Equal(t, tt.name, found.Name)
+			assert.Equal(t, tt.aliases, found.Aliases)
+		})
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	lists, err := LoadDir("testdata")
+	require.NoError(t, err)
+	require.Len(t, lists, 3)
+	assert.Equal(t, "local", lists[0].Name)
+	assert.Equal(t, "sdn", lists[1].Name)
+	assert.Equal(t, "un", lists[2].Name)
+}
+
+func TestParseCSVInvalid(t *testing.T) {
+	_, err := ParseCSV(strings.NewReader("name\nsomeone\n"), "x")
+	assert.ErrorIs(t, err, ErrInvalidList)
+
+	_, err = ParseCSV(strings.NewReader("id,name\n1,\n"), "x")
+	assert.ErrorIs(t, err, ErrInvalidList)
+
+	_, err = LoadFile(filepath.Join("testdata", "missing.json"), "")
+	assert.Error(t, err)
+}
+
+func TestScreenName(t *testing.T) {
+	lists, err := LoadDir("testdata")
+	require.NoError(t, err)
+	screener := NewScreener(0.9, lists...)
+
+	tests := []struct {
+		name  string
+		entry string
+	}{
+		{"Viktor Bout", "local:L-1"},
+		{"Viktor Petrovich Bout", "local:L-1"},
+		{"victor bout", "local:L-1"},
+		{"Bout, Viktor Petrovich", "local:L-1"},
+		{"ABBAS Abu", "sdn:2674"},
+		{"Mohamed Zidan", "sdn:2674"},
+		{"Aero Caribbean", "sdn:36"},
+		{"Ri Wonho", "un:6908555"},
+		{"Jane Smith", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := screener.ScreenName(tt.name)
+			if tt.entry == "" {
+				for _, m := range matches {
+					t.Errorf("unexpected match %s (%s, %.3f)", m.Key(), m.MatchedName, m.Score)
+				}
+				return
+			}
+			require.NotEmpty(t, matches)
+			assert.Equal(t, tt.entry, matches[0].Key())
+			assert.GreaterOrEqual(t, matches[0].Score, 0.9)
+		})
+	}
+}
+
+func TestScreenerThreshold(t *testing.T) {
+	list := &Watchlist{Name: "local", Entries: []*Entry{{ID: "1", Name: "Jonathan Castellano"}}}
+
+	assert.Empty(t, NewScreener(0.9, list).ScreenName("Jonas Castell"))
+	assert.NotEmpty(t, NewScreener(0.85, list).ScreenName("Jonas Castell"))
+}
+
+func TestNormalizeName(t *testing.T) {
+	assert.Equal(t, "jose muller obrien", normalizeName("  José MÜLLER-O'Brien "))
+	assert.Equal(t, "bout petrovich viktor", sortTokens(normalizeName("Viktor Petrovich, BOUT")))
+}
@@ -0,0 +1,104 @@
+package screening
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// ofacList mirrors the parts of the OFAC SDN XML used for screening
+type ofacList struct {
+	Entries []struct {
+		UID       string   `xml:"uid"`
+		FirstName string   `xml:"firstName"`
+		LastName  string   `xml:"lastName"`
+		Type      string   `xml:"sdnType"`
+		Programs  []string `xml:"programList>program"`
+		Akas      []struct {
+			FirstName string `xml:"firstName"`
+			LastName  string `xml:"lastName"`
+		} `xml:"akaList>aka"`
+	} `xml:"sdnEntry"`
+}
+
+// ParseOFAC reads the OFAC SDN list XML (sdn.xml)
+func ParseOFAC(r io.Reader, listName string) (*Watchlist, error) {
+	var doc ofacList
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidList, err)
+	}
+
+	list := &Watchlist{Name: listName}
+	for _, e := range doc.Entries {
+		entry := &Entry{
+			ID:       e.UID,
+			Name:     joinName(e.FirstName, e.LastName),
+			Type:     e.Type,
+			Programs: e.Programs,
+		}
+		for _, aka := range e.Akas {
+			if name := joinName(aka.FirstName, aka.LastName); name != "" {
+				entry.Aliases = append(entry.Aliases, name)
+			}
+		}
+		if entry.ID != "" && entry.Name != "" {
+			list.Entries = append(list.Entries, entry)
+		}
+	}
+
+	return list, nil
+}
+
+// unParty is an individual or entity in the UN consolidated list XML
+type unParty struct {
+	DataID     string   `xml:"DATAID"`
+	FirstName  string   `xml:"FIRST_NAME"`
+	SecondName string   `xml:"SECOND_NAME"`
+	ThirdName  string   `xml:"THIRD_NAME"`
+	FourthName string   `xml:"FOURTH_NAME"`
+	ListType   string   `xml:"UN_LIST_TYPE"`
+	Aliases    []string `xml:"INDIVIDUAL_ALIAS>ALIAS_NAME"`
+	EntAliases []string `xml:"ENTITY_ALIAS>ALIAS_NAME"`
+}
+
+// unList mirrors the parts of the UN consolidated list XML used for screening
+type unList struct {
+	Individuals []unParty `xml:"INDIVIDUALS>INDIVIDUAL"`
+	Entities    []unParty `xml:"ENTITIES>ENTITY"`
+}
+
+// ParseUN reads the UN Security Council consolidated list XML
+func ParseUN(r io.Reader, listName string) (*Watchlist, error) {
+	var doc unList
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidList, err)
+	}
+
+	list := &Watchlist{Name: listName}
+	add := func(p unParty, partyType string) {
+		entry := &Entry{
+			ID:   p.DataID,
+			Name: joinName(p.FirstName, p.SecondName, p.ThirdName, p.FourthName),
+			Type: partyType,
+		}
+		if p.ListType != "" {
+			entry.Programs = []string{p.ListType}
+		}
+		for _, alias := range append(p.Aliases, p.EntAliases...) {
+			if alias = joinName(alias); alias != "" {
+				entry.Aliases = append(entry.Aliases, alias)
+			}
+		}
+		if entry.ID != "" && entry.Name != "" {
+			list.Entries = append(list.Entries, entry)
+		}
+	}
+	for _, p := range doc.Individuals {
+		add(p, "Individual")
+	}
+	for _, p := range doc.Entities {
+		add(p, "Entity")
+	}
+
+	return list, nil
+}
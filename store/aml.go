@@ -0,0 +1,387 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/uptrace/bun"
+)
+
+// amlChunkSize bounds the number of IDs placed in a single IN clause
+const amlChunkSize = 500
+
+// amlPageSize is the number of ledger rows loaded per query
+const amlPageSize = 1000
+
+// RunAMLMonitoring runs batch monitoring over the transactions created in
+// [from, to) and stores a case for each new finding. Findings already stored by
+// an earlier run over an overlapping period are not duplicated.
+//
+// Parameters:
+//   - ctx: Context for cancellation
+//   - from, to: The monitored period
+//   - rules: Which patterns to look for and their thresholds
+//
+// Returns:
+//   - The newly opened cases
+//   - Error if loading history or storing cases fails
+func (r *WalletRepository) RunAMLMonitoring(ctx context.Context, from, to time.Time, rules types.AMLRules) ([]*types.AMLCase, error) {
+	from, to = from.UTC(), to.UTC()
+	if !to.After(from) {
+		return nil, fmt.Errorf("invalid monitoring period: %s to %s", from, to)
+	}
+
+	rows, err := r.listAMLTransactions(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	walletIDs := make(map[string]bool)
+	for _, tx := range rows {
+		walletIDs[tx.WalletID] = true
+	}
+	owners, err := r.amlWalletOwners(ctx, walletIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	input := types.AMLInput{
+		PeriodStart:  from,
+		PeriodEnd:    to,
+		Transactions: rows,
+		WalletOwners: owners,
+	}
+	if input.Baseline, err = r.amlBaseline(ctx, owners, from.Add(-rules.BaselineWindow), from); err != nil {
+		return nil, err
+	}
+	if input.LastActivity, err = r.amlLastActivity(ctx, owners, from); err != nil {
+		return nil, err
+	}
+
+	var opened []*types.AMLCase
+	for _, found := range types.DetectSuspiciousActivity(rules, input) {
+		res, err := r.db.NewInsert().
+			Model(found).
+			On("CONFLICT (fingerprint) DO NOTHING").
+			Exec(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store aml case: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			opened = append(opened, found)
+		}
+	}
+
+	return opened, nil
+}
+
+// listAMLTransactions loads completed transactions created in [from, to)
+func (r *WalletRepository) listAMLTransactions(ctx context.Context, from, to time.Time) ([]*types.TransactionHistory, error) {
+	var (
+		all        []*types.TransactionHistory
+		cursorTime time.Time
+		cursorID   string
+	)
+
+	for {
+		query := r.db.NewSelect().
+			Model((*types.TransactionHistory)(nil)).
+			Where("status = ?", types.StatusCompleted).
+			Where("created_at >= ?", from).
+			Where("created_at < ?", to).
+			OrderExpr("created_at ASC, id ASC").
+			Limit(amlPageSize)
+
+		// Keyset pagination on (created_at, id)
+		if cursorID != "" {
+			query = query.Where("(created_at > ? OR (created_at = ? AND id > ?))", cursorTime, cursorTime, cursorID)
+		}
+
+		var page []*types.TransactionHistory
+		if err := query.Scan(ctx, &page); err != nil {
+			return nil, fmt.Errorf("failed to load transactions for monitoring: %w", err)
+		}
+		all = append(all, page...)
+
+		if len(page) < amlPageSize {
+			return all, nil
+		}
+
+		last := page[len(page)-1]
+		cursorTime, cursorID = last.CreatedAt, last.ID
+	}
+}
+
+// amlWalletOwners maps the given wallets, and every other wallet held by
+// their owners, to customer IDs
+func (r *WalletRepository) amlWalletOwners(ctx context.Context, walletIDs map[string]bool) (map[string]string, error) {
+	ids := make([]string, 0, len(walletIDs))
+	for id := range walletIDs {
+		ids = append(ids, id)
+	}
+
+	owners := make(map[string]string)
+	customers := make(map[string]bool)
+	for _, chunk := range chunkIDs(ids) {
+		var wallets []*types.Wallet
+		err := r.db.NewSelect().
+			Model(&wallets).
+			Column("id", "customer_id").
+			Where("id IN (?)", bun.In(chunk)).
+			Scan(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load wallet owners: %w", err)
+		}
+		for _, w := range wallets {
+			owners[w.ID] = w.CustomerID
+			customers[w.CustomerID] = true
+		}
+	}
+
+	customerIDs := make([]string, 0, len(customers))
+	for id := range customers {
+		customerIDs = append(customerIDs, id)
+	}
+	for _, chunk := range chunkIDs(customerIDs) {
+		var wallets []*types.Wallet
+		err := r.db.NewSelect().
+			Model(&wallets).
+			Column("id", "customer_id").
+			Where("customer_id IN (?)", bun.In(chunk)).
+			Scan(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load customer wallets: %w", err)
+		}
+		for _, w := range wallets {
+			owners[w.ID] = w.CustomerID
+		}
+	}
+
+	return owners, nil
+}
+
+// amlBaseline sums completed volume per customer and currency in [from, to)
+func (r *WalletRepository) amlBaseline(ctx context.Context, owners map[string]string, from, to time.Time) (map[string]decimal.Decimal, error) {
+	baseline := make(map[string]decimal.Decimal)
+	if !to.After(from) {
+		return baseline, nil
+	}
+
+	for _, chunk := range chunkIDs(ownedWallets(owners)) {
+		var sums []struct {
+			WalletID     string          `bun:"wallet_id"`
+			CurrencyCode string          `bun:"currency_code"`
+			Volume       decimal.Decimal `bun:"volume"`
+		}
+		err := r.db.NewSelect().
+			Model((*types.TransactionHistory)(nil)).
+			Column("wallet_id", "currency_code").
+			ColumnExpr("SUM(amount) AS volume").
+			Where("status = ?", types.StatusCompleted).
+			Where("wallet_id IN (?)", bun.In(chunk)).
+			Where("created_at >= ?", from).
+			Where("created_at < ?", to).
+			Group("wallet_id", "currency_code").
+			Scan(ctx, &sums)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load baseline volume: %w", err)
+		}
+		for _, s := range sums {
+			key := types.AMLKey(owners[s.WalletID], s.CurrencyCode)
+			baseline[key] = baseline[key].Add(s.Volume)
+		}
+	}
+
+	return baseline, nil
+}
+
+// amlLastActivity finds each customer's last completed transaction before the given time
+func (r *WalletRepository) amlLastActivity(ctx context.Context, owners map[string]string, before time.Time) (map[string]time.Time, error) {
+	last := make(map[string]time.Time)
+
+	for _, chunk := range chunkIDs(ownedWallets(owners)) {
+		var rows []struct {
+			WalletID string    `bun:"wallet_id"`
+			LastAt   time.Time `bun:"last_at"`
+		}
+		err := r.db.NewSelect().
+			Model((*types.TransactionHistory)(nil)).
+			Column("wallet_id").
+			ColumnExpr("MAX(created_at) AS last_at").
+			Where("status = ?", types.StatusCompleted).
+			Where("wallet_id IN (?)", bun.In(chunk)).
+			Where("created_at < ?", before).
+			Group("wallet_id").
+			Scan(ctx, &rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load last activity: %w", err)
+		}
+		for _, row := range rows {
+			customer := owners[row.WalletID]
+			if row.LastAt.After(last[customer]) {
+				last[customer] = row.LastAt
+			}
+		}
+	}
+
+	return last, nil
+}
+
+// FindAMLCase retrieves an AML case by ID
+func (r *WalletRepository) FindAMLCase(ctx context.Context, id string) (*types.AMLCase, error) {
+	found := &types.AMLCase{ID: id}
+
+	err := r.db.NewSelect().
+		Model(found).
+		WherePK().
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.ErrAMLCaseNotFound
+		}
+		return nil, err
+	}
+
+	return found, nil
+}
+
+// ListAMLCases returns cases with the given status (all when empty), oldest first
+func (r *WalletRepository) ListAMLCases(ctx context.Context, status types.AMLCaseStatus) ([]*types.AMLCase, error) {
+	var cases []*types.AMLCase
+
+	query := r.db.NewSelect().
+		Model(&cases).
+		OrderExpr("created_at ASC, id ASC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	return cases, nil
+}
+
+// ReportAMLCase closes a case as filed in a suspicious activity report. The
+// reviewer is the caller stored in ctx (see types.WithPrincipal), who must be
+// allowed types.ActionReviewRisk on every wallet of the case.
+func (r *WalletRepository) ReportAMLCase(ctx context.Context, caseID, note string) (*types.AMLCase, error) {
+	return r.closeAMLCase(ctx, caseID, note, types.AMLCaseReported)
+}
+
+// DismissAMLCase closes a case as legitimate activity. The reviewer is the
+// caller stored in ctx, as for ReportAMLCase.
+func (r *WalletRepository) DismissAMLCase(ctx context.Context, caseID, note string) (*types.AMLCase, error) {
+	return r.closeAMLCase(ctx, caseID, note, types.AMLCaseDismissed)
+}
+
+func (r *WalletRepository) closeAMLCase(
+	ctx context.Context,
+	caseID string,
+	note string,
+	status types.AMLCaseStatus,
+) (*types.AMLCase, error) {
+	reviewer, ok := types.PrincipalFromContext(ctx)
+	if !ok || reviewer.ID == "" {
+		return nil, fmt.Errorf("%w: aml reviews need an authenticated reviewer", types.ErrUnauthorizedAccess)
+	}
+
+	found, err := r.FindAMLCase(ctx, caseID)
+	if err != nil {
+		return nil, err
+	}
+	if found.Status != types.AMLCaseOpen {
+		return nil, fmt.Errorf("%w: case is %s", types.ErrInvalidStatusTransition, found.Status)
+	}
+	for _, walletID := range found.WalletIDs {
+		wallet, err := r.FindWalletByID(ctx, walletID)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.authorize(ctx, types.ActionReviewRisk, "", wallet); err != nil {
+			return nil, err
+		}
+	}
+
+	found.Status = status
+	found.ReviewerID = reviewer.ID
+	found.ReviewNote = note
+	found.ReviewedAt = time.Now().UTC()
+
+	res, err := r.db.NewUpdate().
+		Model(found).
+		WherePK().
+		Where("status = ?", types.AMLCaseOpen).
+		Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to close aml case: %w", err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return nil, fmt.Errorf("%w: case was closed concurrently", types.ErrInvalidStatusTransition)
+	}
+
+	return found, nil
+}
+
+// BuildSARReport assembles a suspicious activity report for the given cases,
+// loading each case's supporting transactions
+func (r *WalletRepository) BuildSARReport(ctx context.Context, institution string, caseIDs ...string) (*types.SARReport, error) {
+	report := &types.SARReport{
+		ReportID:    types.GenerateID("sar_", 15),
+		Institution: institution,
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	for _, id := range caseIDs {
+		found, err := r.FindAMLCase(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		var rows []*types.TransactionHistory
+		if len(found.TransactionIDs) > 0 {
+			err = r.db.NewSelect().
+				Model(&rows).
+				Where("id IN (?)", bun.In(found.TransactionIDs)).
+				OrderExpr("created_at ASC, id ASC").
+				Scan(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load supporting transactions: %w", err)
+			}
+		}
+
+		report.Cases = append(report.Cases, &types.SARCase{AMLCase: found, Transactions: rows})
+	}
+
+	return report, nil
+}
+
+// ownedWallets lists the wallet IDs in an owner map
+func ownedWallets(owners map[string]string) []string {
+	ids := make([]string, 0, len(owners))
+	for id := range owners {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// chunkIDs splits IDs into slices of at most amlChunkSize
+func chunkIDs(ids []string) [][]string {
+	var chunks [][]string
+	for len(ids) > amlChunkSize {
+		chunks = append(chunks, ids[:amlChunkSize])
+		ids = ids[amlChunkSize:]
+	}
+	if len(ids) > 0 {
+		chunks = append(chunks, ids)
+	}
+	return chunks
+}
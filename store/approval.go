@@ -0,0 +1,465 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/uptrace/bun"
+)
+
+// RequireApprovals gates the operations selected by policy behind a second
+// person: they fail with types.ErrApprovalRequired unless executed through
+// ApproveRequest. Pass nil to turn approvals off.
+func (r *WalletRepository) RequireApprovals(policy *types.ApprovalPolicy) {
+	r.approval = policy
+}
+
+type approvedKey struct{}
+
+// withApproval marks ctx as executing an approved request
+func withApproval(ctx context.Context) context.Context {
+	return context.WithValue(ctx, approvedKey{}, true)
+}
+
+// isApproved reports whether ctx is executing an approved request
+func isApproved(ctx context.Context) bool {
+	approved, _ := ctx.Value(approvedKey{}).(bool)
+	return approved
+}
+
+// requireApproval fails when the operation needs a checker and is not being
+// executed through an approved request
+func (r *WalletRepository) requireApproval(
+	ctx context.Context,
+	op types.ApprovalOperation,
+	category types.TransactionCategory,
+	currencyCode string,
+	amount decimal.Decimal,
+) error {
+	if r.approval == nil || isApproved(ctx) {
+		return nil
+	}
+	if r.approval.RequiresApproval(op, category, currencyCode, amount) {
+		return fmt.Errorf("%w: submit %s for approval", types.ErrApprovalRequired, op)
+	}
+	return nil
+}
+
+// SubmitApproval records an operation as pending approval. The maker is the
+// caller in ctx and must be allowed to request the operation.
+//
+// Parameters:
+//   - op: Repository call to execute once approved
+//   - walletID: Wallet operated on (source wallet for transfers)
+//   - destinationWalletID: Destination wallet for transfers, empty otherwise
+//   - payload: CreditTransaction, DebitTransaction, TransferRequest,
+//     CloseOrOpenRequest or nil, matching op
+//   - reason: Maker's justification
+//
+// Returns:
+//   - The pending *types.ApprovalRequest
+func (r *WalletRepository) SubmitApproval(
+	ctx context.Context,
+	op types.ApprovalOperation,
+	walletID string,
+	destinationWalletID string,
+	payload any,
+	reason string,
+) (*types.ApprovalRequest, error) {
+	maker, ok := types.PrincipalFromContext(ctx)
+	if !ok || maker.ID == "" {
+		return nil, fmt.Errorf("%w: approvals need an authenticated maker", types.ErrUnauthorizedAccess)
+	}
+
+	wallet, err := r.FindWalletByID(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.authorizeApprovalPayload(ctx, op, wallet, destinationWalletID, payload); err != nil {
+		return nil, err
+	}
+
+	expiry := 24 * time.Hour
+	if r.approval != nil && r.approval.Expiry > 0 {
+		expiry = r.approval.Expiry
+	}
+	req, err := types.NewApprovalRequest(op, maker.ID, walletID, destinationWalletID, payload, reason, expiry)
+	if err != nil {
+		return nil, err
+	}
+	req.CurrencyCode = wallet.CurrencyCode
+
+	err = r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(req).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to save approval request: %w", err)
+		}
+		return r.NewWithTx(tx).recordApprovalEvent(ctx, req, types.ApprovalEventSubmitted, maker.ID, reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// authorizeApprovalPayload checks the payload matches op and that the maker
+// may request it. Signed requests are verified now, since their timestamps
+// will have gone stale by the time a checker approves them.
+func (r *WalletRepository) authorizeApprovalPayload(
+	ctx context.Context,
+	op types.ApprovalOperation,
+	wallet *types.Wallet,
+	destinationWalletID string,
+	payload any,
+) error {
+	switch op {
+	case types.ApprovalCredit:
+		p, ok := payload.(types.CreditTransaction)
+		if !ok {
+			return fmt.Errorf("%w: %s needs a CreditTransaction", types.ErrUnsupportedApproval, op)
+		}
+		return r.authorize(ctx, types.ActionForCategory(p.TransactionCategory, types.ActionCredit), p.InitiatorID, wallet)
+
+	case types.ApprovalDebit:
+		p, ok := payload.(types.DebitTransaction)
+		if !ok {
+			return fmt.Errorf("%w: %s needs a DebitTransaction", types.ErrUnsupportedApproval, op)
+		}
+		if err := r.authorize(ctx, types.ActionForCategory(p.TransactionCategory, types.ActionDebit), p.InitiatorID, wallet); err != nil {
+			return err
+		}
+		return r.verifyRequest(ctx, p.InitiatorID, p.Signature, func(sig *types.RequestSignature) []byte {
+			return p.SigningPayload(wallet.ID, sig)
+		})
+
+	case types.ApprovalTransfer:
+		p, ok := payload.(types.TransferRequest)
+		if !ok || destinationWalletID == "" {
+			return fmt.Errorf("%w: %s needs a TransferRequest and destination wallet", types.ErrUnsupportedApproval, op)
+		}
+		if err := r.authorize(ctx, types.ActionTransfer, p.InitiatorID, wallet); err != nil {
+			return err
+		}
+		return r.verifyRequest(ctx, p.InitiatorID, p.Signature, func(sig *types.RequestSignature) []byte {
+			return p.SigningPayload(wallet.ID, destinationWalletID, sig)
+		})
+
+	case types.ApprovalUnfreeze:
+		return r.authorize(ctx, types.ActionUnfreeze, "", wallet)
+
+	case types.ApprovalReopen:
+		p, ok := payload.(types.CloseOrOpenRequest)
+		if !ok {
+			return fmt.Errorf("%w: %s needs a CloseOrOpenRequest", types.ErrUnsupportedApproval, op)
+		}
+		return r.authorize(ctx, types.ActionReopen, p.InitiatedBy, wallet)
+
+	default:
+		return fmt.Errorf("%w: %s", types.ErrUnsupportedApproval, op)
+	}
+}
+
+// ApproveRequest lets a checker approve a pending request and executes it.
+// The status change, audit entries and the operation commit together; if the
+// operation fails the request is marked FAILED with the error recorded.
+func (r *WalletRepository) ApproveRequest(ctx context.Context, requestID, note string) (*types.ApprovalRequest, error) {
+	req, checker, err := r.loadForDecision(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	version := req.VersionId
+
+	err = r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		repo := r.NewWithTx(tx)
+
+		txID, err := repo.executeApproval(withApproval(ctx), req)
+		if err != nil {
+			return err
+		}
+
+		req.Status = types.ApprovalExecuted
+		req.CheckerID = checker.ID
+		req.DecisionNote = note
+		req.TransactionID = txID
+		req.DecidedAt = time.Now().UTC()
+		if err := repo.updateApprovalRequest(ctx, req, version); err != nil {
+			return err
+		}
+		if err := repo.recordApprovalEvent(ctx, req, types.ApprovalEventApproved, checker.ID, note); err != nil {
+			return err
+		}
+		return repo.recordApprovalEvent(ctx, req, types.ApprovalEventExecuted, checker.ID, txID)
+	})
+	if err == nil {
+		return req, nil
+	}
+	if errors.Is(err, types.ErrApprovalNotPending) {
+		return nil, err
+	}
+
+	// The operation was rolled back; keep the approval and its failure on
+	// record, along with any screening hit that blocked it
+	execErr := err
+	if err := r.screeningBlocked(ctx, execErr); err != nil {
+		execErr = errors.Join(execErr, err)
+	}
+	req.VersionId = version
+	req.Status = types.ApprovalFailed
+	req.CheckerID = checker.ID
+	req.DecisionNote = execErr.Error()
+	req.TransactionID = ""
+	req.DecidedAt = time.Now().UTC()
+
+	err = r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		repo := r.NewWithTx(tx)
+		if err := repo.updateApprovalRequest(ctx, req, version); err != nil {
+			return err
+		}
+		if err := repo.recordApprovalEvent(ctx, req, types.ApprovalEventApproved, checker.ID, note); err != nil {
+			return err
+		}
+		return repo.recordApprovalEvent(ctx, req, types.ApprovalEventFailed, checker.ID, execErr.Error())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return req, fmt.Errorf("approved operation failed: %w", execErr)
+}
+
+// RejectRequest lets a checker decline a pending request
+func (r *WalletRepository) RejectRequest(ctx context.Context, requestID, note string) (*types.ApprovalRequest, error) {
+	req, checker, err := r.loadForDecision(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	version := req.VersionId
+
+	req.Status = types.ApprovalRejected
+	req.CheckerID = checker.ID
+	req.DecisionNote = note
+	req.DecidedAt = time.Now().UTC()
+
+	err = r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		repo := r.NewWithTx(tx)
+		if err := repo.updateApprovalRequest(ctx, req, version); err != nil {
+			return err
+		}
+		return repo.recordApprovalEvent(ctx, req, types.ApprovalEventRejected, checker.ID, note)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// loadForDecision loads a request and checks the caller in ctx may decide it
+func (r *WalletRepository) loadForDecision(ctx context.Context, requestID string) (*types.ApprovalRequest, *types.Principal, error) {
+	checker, ok := types.PrincipalFromContext(ctx)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: approvals need an authenticated checker", types.ErrUnauthorizedAccess)
+	}
+
+	req, err := r.FindApprovalRequest(ctx, requestID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := req.CanBeDecidedBy(checker.ID, time.Now().UTC()); err != nil {
+		return nil, nil, err
+	}
+
+	policy := r.approval
+	if policy == nil {
+		policy = types.DefaultApprovalPolicy()
+	}
+	if !policy.CanCheck(checker, req.Operation) {
+		return nil, nil, fmt.Errorf("%w: %s may not decide %s requests", types.ErrUnauthorizedAccess, checker.ID, req.Operation)
+	}
+
+	return req, checker, nil
+}
+
+// executeApproval runs the request's repository call and returns the ID of the
+// transaction it wrote, if any
+func (r *WalletRepository) executeApproval(ctx context.Context, req *types.ApprovalRequest) (string, error) {
+	switch req.Operation {
+	case types.ApprovalCredit:
+		var p types.CreditTransaction
+		if err := req.DecodePayload(&p); err != nil {
+			return "", err
+		}
+		tx, _, err := r.CreditWallet(ctx, req.WalletID, p)
+		if err != nil {
+			return "", err
+		}
+		return tx.ID, nil
+
+	case types.ApprovalDebit:
+		var p types.DebitTransaction
+		if err := req.DecodePayload(&p); err != nil {
+			return "", err
+		}
+		tx, _, err := r.DebitWallet(ctx, req.WalletID, p)
+		if err != nil {
+			return "", err
+		}
+		return tx.ID, nil
+
+	case types.ApprovalTransfer:
+		var p types.TransferRequest
+		if err := req.DecodePayload(&p); err != nil {
+			return "", err
+		}
+		tx, _, err := r.TransferFunds(ctx, req.WalletID, req.DestinationWalletID, p)
+		if err != nil {
+			return "", err
+		}
+		return tx.ID, nil
+
+	case types.ApprovalUnfreeze:
+		_, err := r.UnfreezeWallet(ctx, req.WalletID)
+		return "", err
+
+	case types.ApprovalReopen:
+		var p types.CloseOrOpenRequest
+		if err := req.DecodePayload(&p); err != nil {
+			return "", err
+		}
+		_, _, err := r.ReopenWallet(ctx, req.WalletID, p)
+		return "", err
+
+	default:
+		return "", fmt.Errorf("%w: %s", types.ErrUnsupportedApproval, req.Operation)
+	}
+}
+
+// updateApprovalRequest saves a decision if the request is still at version
+func (r *WalletRepository) updateApprovalRequest(ctx context.Context, req *types.ApprovalRequest, version string) error {
+	req.VersionId = types.GenerateID("ver_", 8)
+
+	res, err := r.db.NewUpdate().
+		Model(req).
+		WherePK().
+		Where("version_id = ?", version).
+		Where("status = ?", types.ApprovalPending).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update approval request: %w", err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return types.ErrApprovalNotPending
+	}
+
+	return nil
+}
+
+// recordApprovalEvent appends an audit entry for the request
+func (r *WalletRepository) recordApprovalEvent(
+	ctx context.Context,
+	req *types.ApprovalRequest,
+	eventType types.ApprovalEventType,
+	actorID string,
+	note string,
+) error {
+	event := types.NewApprovalEvent(req, eventType, actorID, note)
+	if _, err := r.db.NewInsert().Model(event).Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record approval event: %w", err)
+	}
+	return nil
+}
+
+// ExpireApprovals marks pending requests past their expiry as EXPIRED and
+// returns how many were expired
+func (r *WalletRepository) ExpireApprovals(ctx context.Context) (int, error) {
+	var pending []*types.ApprovalRequest
+	err := r.db.NewSelect().
+		Model(&pending).
+		Where("status = ?", types.ApprovalPending).
+		Where("expires_at <= ?", time.Now().UTC()).
+		Scan(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load expired approvals: %w", err)
+	}
+
+	expired := 0
+	for _, req := range pending {
+		version := req.VersionId
+		req.Status = types.ApprovalExpired
+		req.DecidedAt = time.Now().UTC()
+
+		err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			repo := r.NewWithTx(tx)
+			if err := repo.updateApprovalRequest(ctx, req, version); err != nil {
+				return err
+			}
+			return repo.recordApprovalEvent(ctx, req, types.ApprovalEventExpired, "system", "")
+		})
+		if errors.Is(err, types.ErrApprovalNotPending) {
+			continue // Decided while we were expiring
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+
+	return expired, nil
+}
+
+// FindApprovalRequest retrieves an approval request by ID
+func (r *WalletRepository) FindApprovalRequest(ctx context.Context, id string) (*types.ApprovalRequest, error) {
+	req := &types.ApprovalRequest{ID: id}
+
+	err := r.db.NewSelect().
+		Model(req).
+		WherePK().
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.ErrApprovalNotFound
+		}
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// ListApprovalRequests returns requests in the given status, oldest first.
+// An empty status lists every request.
+func (r *WalletRepository) ListApprovalRequests(ctx context.Context, status types.ApprovalStatus) ([]*types.ApprovalRequest, error) {
+	var requests []*types.ApprovalRequest
+
+	q := r.db.NewSelect().
+		Model(&requests).
+		OrderExpr("created_at ASC, id ASC")
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if err := q.Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+// ListApprovalEvents returns the audit history of a request, oldest first
+func (r *WalletRepository) ListApprovalEvents(ctx context.Context, requestID string) ([]*types.ApprovalEvent, error) {
+	var events []*types.ApprovalEvent
+
+	err := r.db.NewSelect().
+		Model(&events).
+		Where("request_id = ?", requestID).
+		OrderExpr("created_at ASC, id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
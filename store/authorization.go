@@ -0,0 +1,33 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/otyang/waas-go/types"
+)
+
+// EnforceAuthorization makes wallet operations check the caller stored in the
+// context (see types.WithPrincipal) against policy. Pass nil to turn it off.
+func (r *WalletRepository) EnforceAuthorization(policy *types.Policy) {
+	r.policy = policy
+}
+
+// authorize checks the context's caller may perform action on wallet. When the
+// caller is only allowed to act on their own wallets, the request's initiator
+// must be the caller too, so customers cannot act in someone else's name.
+func (r *WalletRepository) authorize(ctx context.Context, action types.Action, initiatorID string, wallet *types.Wallet) error {
+	if r.policy == nil {
+		return nil
+	}
+
+	principal, _ := types.PrincipalFromContext(ctx)
+	if err := r.policy.Authorize(principal, action, wallet); err != nil {
+		return err
+	}
+	if r.policy.ScopeFor(principal, action) == types.ScopeOwn && initiatorID != "" && initiatorID != principal.ID {
+		return fmt.Errorf("%w: %s may not act for initiator %s", types.ErrUnauthorizedAccess, principal.ID, initiatorID)
+	}
+
+	return nil
+}
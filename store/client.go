@@ -1,17 +1,31 @@
 package store
 
 import (
+	"github.com/otyang/waas-go/types"
 	"github.com/uptrace/bun"
 )
 
 type WalletRepository struct {
-	db bun.IDB
+	db        bun.IDB
+	verifier  *types.RequestVerifier  // Enforces request signatures when set
+	policy    *types.Policy           // Enforces caller authorization when set
+	approval  *types.ApprovalPolicy   // Gates sensitive operations behind a checker when set
+	limits    *types.LimitSchedule    // Enforces KYC tier limits when set
+	risk      *types.RiskEngine       // Screens movements for fraud when set
+	screener  types.NameScreener      // Screens customers against watch lists when set
+	directory types.CustomerDirectory // Resolves customer names for screening
+	outbox    bool                    // Writes domain events to the outbox when set
+
+	payoutChunkSize int // Payout items paid per transaction
 }
 
 func NewWalletRepository(db *bun.DB) *WalletRepository {
 	return &WalletRepository{db: db}
 }
 
+// NewWithTx returns a copy of the repository bound to tx, keeping its settings
 func (a *WalletRepository) NewWithTx(tx bun.Tx) *WalletRepository {
-	return &WalletRepository{db: tx}
+	repo := *a
+	repo.db = tx
+	return &repo
 }
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+)
+
+// CloseWallet closes an empty wallet
+func (r *WalletRepository) CloseWallet(ctx context.Context, walletID string, req types.CloseOrOpenRequest) (*types.CloseOrOpenResult, *types.Wallet, error) {
+	wallet, err := r.FindWalletByID(ctx, walletID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := r.authorize(ctx, types.ActionClose, req.InitiatedBy, wallet); err != nil {
+		return nil, nil, err
+	}
+
+	result, err := wallet.CloseWallet(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("close failed: %w", err)
+	}
+	if _, err := r.updateStatus(ctx, wallet, types.StatusChangeClose, req.Reason, req.InitiatedBy); err != nil {
+		return nil, nil, err
+	}
+
+	return result, wallet, nil
+}
+
+// ReopenWallet reopens a closed wallet
+func (r *WalletRepository) ReopenWallet(ctx context.Context, walletID string, req types.CloseOrOpenRequest) (*types.CloseOrOpenResult, *types.Wallet, error) {
+	wallet, err := r.FindWalletByID(ctx, walletID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := r.authorize(ctx, types.ActionReopen, req.InitiatedBy, wallet); err != nil {
+		return nil, nil, err
+	}
+	if err := r.requireApproval(ctx, types.ApprovalReopen, "", wallet.CurrencyCode, decimal.Zero); err != nil {
+		return nil, nil, err
+	}
+
+	result, err := wallet.ReopenWallet(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reopen failed: %w", err)
+	}
+	if _, err := r.updateStatus(ctx, wallet, types.StatusChangeReopen, req.Reason, req.InitiatedBy); err != nil {
+		return nil, nil, err
+	}
+
+	return result, wallet, nil
+}
@@ -19,6 +19,13 @@ func (r *WalletRepository) CreditWallet(
 	if err != nil {
 		return nil, nil, err
 	}
+	action := types.ActionForCategory(creditTx.TransactionCategory, types.ActionCredit)
+	if err := r.authorize(ctx, action, creditTx.InitiatorID, wallet); err != nil {
+		return nil, nil, err
+	}
+	if err := r.requireApproval(ctx, types.ApprovalCredit, creditTx.TransactionCategory, wallet.CurrencyCode, creditTx.Amount); err != nil {
+		return nil, nil, err
+	}
 
 	// 2. Perform the credit operation
 	txHistory, err := wallet.Credit(creditTx)
@@ -26,16 +33,49 @@ func (r *WalletRepository) CreditWallet(
 		return txHistory, wallet, err
 	}
 
-	// 3. Update wallet and record transaction atomically
+	// 3. Screen, update wallet and record transaction atomically
+	var blocked []*types.RiskDecision
 	err = r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := r.NewWithTx(tx).checkCreditLimits(ctx, wallet, txHistory); err != nil {
+			return err
+		}
+		decisions, outcome, err := r.NewWithTx(tx).screenRisk(ctx, types.RiskInput{
+			Operation: types.OperationCredit,
+			Wallet:    wallet,
+			Type:      types.TypeCredit,
+			Amount:    creditTx.Amount,
+			Category:  creditTx.TransactionCategory,
+		})
+		if err != nil {
+			return err
+		}
+		if outcome == types.RiskBlock {
+			blocked = decisions
+			return types.ErrTransactionBlocked
+		}
+		if outcome == types.RiskReview {
+			txHistory.Status = types.StatusPending
+		}
 		if _, err := r.NewWithTx(tx).UpdateWallet(ctx, wallet); err != nil {
 			return fmt.Errorf("failed to update wallet balance: %w", err)
 		}
 		if _, err := r.NewWithTx(tx).CreateTransaction(ctx, txHistory); err != nil {
 			return fmt.Errorf("failed to record transaction: %w", err)
 		}
-		return nil
+		if err := r.NewWithTx(tx).holdForReview(ctx, wallet, txHistory); err != nil {
+			return err
+		}
+		if err := r.NewWithTx(tx).recordRiskDecisions(ctx, decisions, txHistory.ID); err != nil {
+			return err
+		}
+		return r.NewWithTx(tx).emit(ctx, types.EventWalletCredited, &types.WalletEvent{
+			Wallet:       wallet,
+			Transactions: []*types.TransactionHistory{txHistory},
+		})
 	})
+	if blocked != nil {
+		return nil, nil, r.riskBlocked(ctx, blocked)
+	}
 	if err != nil {
 		return nil, nil, err
 	}
@@ -49,11 +89,26 @@ func (r *WalletRepository) DebitWallet(
 	walletID string,
 	debitTx types.DebitTransaction,
 ) (*types.TransactionHistory, *types.Wallet, error) {
+	// 0. Authenticate the request when signatures are enforced
+	err := r.verifyRequest(ctx, debitTx.InitiatorID, debitTx.Signature, func(sig *types.RequestSignature) []byte {
+		return debitTx.SigningPayload(walletID, sig)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// 1. Retrieve the wallet with lock to prevent concurrent modifications
 	wallet, err := r.FindWalletByID(ctx, walletID)
 	if err != nil {
 		return nil, nil, err
 	}
+	action := types.ActionForCategory(debitTx.TransactionCategory, types.ActionDebit)
+	if err := r.authorize(ctx, action, debitTx.InitiatorID, wallet); err != nil {
+		return nil, nil, err
+	}
+	if err := r.requireApproval(ctx, types.ApprovalDebit, debitTx.TransactionCategory, wallet.CurrencyCode, debitTx.Amount); err != nil {
+		return nil, nil, err
+	}
 
 	// 2. Perform the debit operation
 	txHistory, err := wallet.Debit(debitTx)
@@ -61,16 +116,46 @@ func (r *WalletRepository) DebitWallet(
 		return txHistory, wallet, fmt.Errorf("debit failed: %w", err)
 	}
 
-	// 3. Update wallet and record transaction atomically
+	// 3. Screen, update wallet and record transaction atomically
+	var blocked []*types.RiskDecision
 	err = r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := r.NewWithTx(tx).checkDebitLimits(ctx, wallet, debitTx.Amount); err != nil {
+			return err
+		}
+		decisions, outcome, err := r.NewWithTx(tx).screenRisk(ctx, types.RiskInput{
+			Operation: types.OperationDebit,
+			Wallet:    wallet,
+			Type:      types.TypeDebit,
+			Amount:    debitTx.Amount,
+			Category:  debitTx.TransactionCategory,
+		})
+		if err != nil {
+			return err
+		}
+		if outcome == types.RiskBlock {
+			blocked = decisions
+			return types.ErrTransactionBlocked
+		}
+		if outcome == types.RiskReview {
+			txHistory.Status = types.StatusPending
+		}
 		if _, err := r.NewWithTx(tx).UpdateWallet(ctx, wallet); err != nil {
 			return fmt.Errorf("failed to update wallet balance: %w", err)
 		}
 		if _, err := r.NewWithTx(tx).CreateTransaction(ctx, txHistory); err != nil {
 			return fmt.Errorf("failed to record transaction: %w", err)
 		}
-		return nil
+		if err := r.NewWithTx(tx).recordRiskDecisions(ctx, decisions, txHistory.ID); err != nil {
+			return err
+		}
+		return r.NewWithTx(tx).emit(ctx, types.EventWalletDebited, &types.WalletEvent{
+			Wallet:       wallet,
+			Transactions: []*types.TransactionHistory{txHistory},
+		})
 	})
+	if blocked != nil {
+		return nil, nil, r.riskBlocked(ctx, blocked)
+	}
 	if err != nil {
 		return nil, nil, err
 	}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+)
+
+// ErrCurrencyExists is returned when creating a currency whose code is taken
+var ErrCurrencyExists = errors.New("currency already exists")
+
+// CreateCurrency adds a supported currency
+func (r *WalletRepository) CreateCurrency(ctx context.Context, currency *types.CurrencyInfo) (*types.CurrencyInfo, error) {
+	if err := normalizeCurrency(currency); err != nil {
+		return nil, err
+	}
+	if currency.CreatedAt.IsZero() {
+		currency.CreatedAt = time.Now().UTC()
+	}
+	currency.UpdatedAt = currency.CreatedAt
+
+	res, err := r.db.NewInsert().
+		Model(currency).
+		On("CONFLICT (code) DO NOTHING").
+		Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create currency: %w", err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrCurrencyExists, currency.Code)
+	}
+
+	return currency, nil
+}
+
+// UpdateCurrency saves changes to a supported currency
+func (r *WalletRepository) UpdateCurrency(ctx context.Context, currency *types.CurrencyInfo) (*types.CurrencyInfo, error) {
+	if err := normalizeCurrency(currency); err != nil {
+		return nil, err
+	}
+	currency.UpdatedAt = time.Now().UTC()
+
+	res, err := r.db.NewUpdate().
+		Model(currency).
+		WherePK().
+		ExcludeColumn("created_at").
+		Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update currency: %w", err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return nil, fmt.Errorf("%w: %s", types.ErrCurrencyNotFound, currency.Code)
+	}
+
+	return currency, nil
+}
+
+// FindCurrency retrieves a currency by its code
+func (r *WalletRepository) FindCurrency(ctx context.Context, code string) (*types.CurrencyInfo, error) {
+	currency := &types.CurrencyInfo{Code: strings.ToUpper(strings.TrimSpace(code))}
+
+	err := r.db.NewSelect().
+		Model(currency).
+		WherePK().
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", types.ErrCurrencyNotFound, currency.Code)
+		}
+		return nil, err
+	}
+
+	return currency, nil
+}
+
+// ListCurrencies returns all supported currencies ordered by code
+func (r *WalletRepository) ListCurrencies(ctx context.Context) ([]*types.CurrencyInfo, error) {
+	var currencies []*types.CurrencyInfo
+
+	err := r.db.NewSelect().
+		Model(&currencies).
+		Order("code ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list currencies: %w", err)
+	}
+
+	return currencies, nil
+}
+
+// normalizeCurrency upper-cases the currency code and rejects an empty one
+func normalizeCurrency(currency *types.CurrencyInfo) error {
+	if currency == nil {
+		return errors.New("currency cannot be nil")
+	}
+	currency.Code = strings.ToUpper(strings.TrimSpace(currency.Code))
+	if currency.Code == "" {
+		return ErrInvalidCurrencyCode
+	}
+	return nil
+}
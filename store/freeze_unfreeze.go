@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+)
+
+// FreezeWallet blocks debits on a wallet
+func (r *WalletRepository) FreezeWallet(ctx context.Context, walletID string, req types.FreezeRequest) (*types.Wallet, error) {
+	wallet, err := r.FindWalletByID(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.authorize(ctx, types.ActionFreeze, req.InitiatedBy, wallet); err != nil {
+		return nil, err
+	}
+
+	if req.FrozenAt.IsZero() {
+		req.FrozenAt = time.Now().UTC()
+	}
+	if err := wallet.Freeze(req); err != nil {
+		return nil, fmt.Errorf("freeze failed: %w", err)
+	}
+
+	return r.updateStatus(ctx, wallet, types.StatusChangeFreeze, req.Reason, req.InitiatedBy)
+}
+
+// UnfreezeWallet lifts a freeze from a wallet
+func (r *WalletRepository) UnfreezeWallet(ctx context.Context, walletID string) (*types.Wallet, error) {
+	wallet, err := r.FindWalletByID(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.authorize(ctx, types.ActionUnfreeze, "", wallet); err != nil {
+		return nil, err
+	}
+	if err := r.requireApproval(ctx, types.ApprovalUnfreeze, "", wallet.CurrencyCode, decimal.Zero); err != nil {
+		return nil, err
+	}
+
+	if err := wallet.Unfreeze(); err != nil {
+		return nil, fmt.Errorf("unfreeze failed: %w", err)
+	}
+
+	var initiatedBy string
+	if principal, ok := types.PrincipalFromContext(ctx); ok {
+		initiatedBy = principal.ID
+	}
+
+	return r.updateStatus(ctx, wallet, types.StatusChangeUnfreeze, "", initiatedBy)
+}
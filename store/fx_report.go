@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+)
+
+// CreateFXTrade records the house side of a completed swap
+func (r *WalletRepository) CreateFXTrade(ctx context.Context, trade *types.FXTrade) (*types.FXTrade, error) {
+	if trade == nil {
+		return nil, errors.New("fx trade cannot be nil")
+	}
+
+	_, err := r.db.NewInsert().
+		Model(trade).
+		Exec(ctx)
+
+	return trade, err
+}
+
+// ListFXTrades retrieves swap trades executed within [from, to), oldest first
+func (r *WalletRepository) ListFXTrades(ctx context.Context, from, to time.Time) ([]*types.FXTrade, error) {
+	var trades []*types.FXTrade
+
+	err := r.db.NewSelect().
+		Model(&trades).
+		Where("traded_at >= ?", from).
+		Where("traded_at < ?", to).
+		Order("traded_at ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fx trades: %w", err)
+	}
+
+	return trades, nil
+}
+
+// FXPositionReport builds net positions and P&L for swaps executed within [from, to).
+// currentRates uses the RateCalculator convention (base currency -> currency).
+func (r *WalletRepository) FXPositionReport(
+	ctx context.Context,
+	baseCurrency string,
+	currentRates map[string]float64,
+	from, to time.Time,
+) (*types.FXPositionReport, error) {
+	if !to.After(from) {
+		return nil, types.ErrInvalidReportWindow
+	}
+
+	trades, err := r.ListFXTrades(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return types.GenerateFXPositionReport(trades, baseCurrency, currentRates, from, to)
+}
+
+// DailyFXPositionReport builds the FX position report for a single UTC calendar day
+func (r *WalletRepository) DailyFXPositionReport(
+	ctx context.Context,
+	baseCurrency string,
+	currentRates map[string]float64,
+	day time.Time,
+) (*types.FXPositionReport, error) {
+	day = day.UTC()
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	return r.FXPositionReport(ctx, baseCurrency, currentRates, start, start.AddDate(0, 0, 1))
+}
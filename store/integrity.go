@@ -0,0 +1,242 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/uptrace/bun"
+)
+
+// integrityReplayAttempts is how often a wallet is re-read when it changes mid-replay
+const integrityReplayAttempts = 3
+
+// IntegrityOptions controls a balance integrity run
+type IntegrityOptions struct {
+	BatchSize   int    // Wallets loaded per query (default 100)
+	StartAfter  string // Resume after this wallet ID (optional)
+	MaxWallets  int    // Stop after this many wallets, 0 for all
+	Repair      bool   // Write CategoryAdjustment rows for available balance drift
+	InitiatorID string // Initiator recorded on adjustment rows (default "integrity-checker")
+}
+
+// IntegrityReport summarises a balance integrity run
+type IntegrityReport struct {
+	StartedAt           time.Time                   `json:"startedAt"`           // When the run began
+	FinishedAt          time.Time                   `json:"finishedAt"`          // When the run ended
+	WalletsChecked      int                         `json:"walletsChecked"`      // Wallets replayed
+	TransactionsChecked int                         `json:"transactionsChecked"` // Rows replayed
+	WalletsSkipped      []string                    `json:"walletsSkipped"`      // Wallets that kept changing during replay
+	LastWalletID        string                      `json:"lastWalletId"`        // Cursor to resume from
+	Complete            bool                        `json:"complete"`            // All wallets were visited
+	Issues              []*types.IntegrityIssue     `json:"issues"`              // Problems found
+	Adjustments         []*types.TransactionHistory `json:"adjustments"`         // Rows written in repair mode
+}
+
+// CheckBalanceIntegrity replays every wallet's ledger in batches and reports
+// drift, broken balance chains, negative balances and orphan transactions.
+//
+// The check runs online: wallets are read without locks and a wallet whose
+// version changes while it is replayed is re-read, then skipped if it keeps
+// changing. In repair mode an adjustment row is written only if the wallet
+// version is still the one that was replayed.
+func (r *WalletRepository) CheckBalanceIntegrity(ctx context.Context, opts IntegrityOptions) (*IntegrityReport, error) {
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 100
+	}
+	if opts.InitiatorID == "" {
+		opts.InitiatorID = "integrity-checker"
+	}
+
+	report := &IntegrityReport{StartedAt: time.Now().UTC(), LastWalletID: opts.StartAfter}
+
+	for {
+		query := r.db.NewSelect().
+			Model((*types.Wallet)(nil)).
+			Order("id ASC").
+			Limit(opts.BatchSize)
+		if report.LastWalletID != "" {
+			query = query.Where("id > ?", report.LastWalletID)
+		}
+
+		var wallets []*types.Wallet
+		if err := query.Scan(ctx, &wallets); err != nil {
+			return nil, fmt.Errorf("failed to load wallets: %w", err)
+		}
+
+		for _, wallet := range wallets {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			if err := r.checkWallet(ctx, wallet, opts, report); err != nil {
+				return report, err
+			}
+			report.LastWalletID = wallet.ID
+			report.WalletsChecked++
+
+			if opts.MaxWallets > 0 && report.WalletsChecked >= opts.MaxWallets {
+				report.FinishedAt = time.Now().UTC()
+				return report, nil
+			}
+		}
+
+		if len(wallets) < opts.BatchSize {
+			break
+		}
+	}
+
+	orphans, err := r.FindOrphanTransactions(ctx, opts.BatchSize)
+	if err != nil {
+		return report, err
+	}
+	report.Issues = append(report.Issues, orphans...)
+
+	report.Complete = true
+	report.FinishedAt = time.Now().UTC()
+	return report, nil
+}
+
+// checkWallet replays a single wallet and records its issues in the report
+func (r *WalletRepository) checkWallet(ctx context.Context, wallet *types.Wallet, opts IntegrityOptions, report *IntegrityReport) error {
+	for attempt := 0; attempt < integrityReplayAttempts; attempt++ {
+		transactions, err := r.listWalletLedger(ctx, wallet.ID)
+		if err != nil {
+			return err
+		}
+
+		var liens []*types.LienRecord
+		if err := r.db.NewSelect().Model(&liens).Where("wallet_id = ?", wallet.ID).Scan(ctx); err != nil {
+			return fmt.Errorf("failed to load liens: %w", err)
+		}
+
+		// A wallet that moved while we read its ledger cannot be compared reliably
+		current, err := r.FindWalletByID(ctx, wallet.ID)
+		if err != nil {
+			return err
+		}
+		if current.VersionId != wallet.VersionId {
+			wallet = current
+			continue
+		}
+
+		replay := types.ReplayWallet(wallet, transactions, liens)
+		report.TransactionsChecked += replay.TransactionCount
+		report.Issues = append(report.Issues, replay.Issues...)
+
+		if opts.Repair && !replay.AvailableDrift.IsZero() {
+			adjustment, err := r.repairDrift(ctx, wallet, replay, opts.InitiatorID)
+			if errors.Is(err, ErrConcurrentModification) {
+				report.WalletsSkipped = append(report.WalletsSkipped, wallet.ID)
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			report.Adjustments = append(report.Adjustments, adjustment)
+		}
+		return nil
+	}
+
+	report.WalletsSkipped = append(report.WalletsSkipped, wallet.ID)
+	return nil
+}
+
+// repairDrift records an adjustment row for the wallet's available balance
+// drift, provided the wallet has not changed since it was replayed
+func (r *WalletRepository) repairDrift(
+	ctx context.Context,
+	wallet *types.Wallet,
+	replay *types.WalletReplay,
+	initiatorID string,
+) (*types.TransactionHistory, error) {
+	adjustment := types.NewAdjustmentTransaction(wallet, replay, initiatorID)
+
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		repo := r.NewWithTx(tx)
+
+		current, err := repo.FindWalletByID(ctx, wallet.ID)
+		if err != nil {
+			return err
+		}
+		if current.VersionId != wallet.VersionId {
+			return ErrConcurrentModification
+		}
+
+		// Bump the version so concurrent writers notice the ledger changed
+		if _, err := repo.UpdateWallet(ctx, current); err != nil {
+			return err
+		}
+		if _, err := repo.CreateTransaction(ctx, adjustment); err != nil {
+			return fmt.Errorf("failed to record adjustment: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return adjustment, nil
+}
+
+// listWalletLedger loads every transaction that moved a wallet's balance, oldest first
+func (r *WalletRepository) listWalletLedger(ctx context.Context, walletID string) ([]*types.TransactionHistory, error) {
+	var transactions []*types.TransactionHistory
+
+	err := r.streamLedgerTransactions(ctx, walletID, nil, 500,
+		func(tx *types.TransactionHistory) error {
+			transactions = append(transactions, tx)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+// FindOrphanTransactions reports transactions whose wallet does not exist,
+// reading batchSize rows per query
+func (r *WalletRepository) FindOrphanTransactions(ctx context.Context, batchSize int) ([]*types.IntegrityIssue, error) {
+	if batchSize < 1 {
+		batchSize = 100
+	}
+
+	var (
+		issues []*types.IntegrityIssue
+		cursor string
+	)
+
+	for {
+		query := r.db.NewSelect().
+			Model((*types.TransactionHistory)(nil)).
+			Where("NOT EXISTS (SELECT 1 FROM wallets AS w WHERE w.id = transaction_history.wallet_id)").
+			Order("id ASC").
+			Limit(batchSize)
+		if cursor != "" {
+			query = query.Where("transaction_history.id > ?", cursor)
+		}
+
+		var page []*types.TransactionHistory
+		if err := query.Scan(ctx, &page); err != nil {
+			return nil, fmt.Errorf("failed to find orphan transactions: %w", err)
+		}
+
+		for _, tx := range page {
+			issues = append(issues, &types.IntegrityIssue{
+				Kind:          types.IssueOrphanTransaction,
+				WalletID:      tx.WalletID,
+				TransactionID: tx.ID,
+				Expected:      tx.Amount,
+				Actual:        tx.Amount,
+				Detail:        "transaction references a wallet that does not exist",
+			})
+		}
+
+		if len(page) < batchSize {
+			return issues, nil
+		}
+		cursor = page[len(page)-1].ID
+	}
+}
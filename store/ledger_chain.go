@@ -0,0 +1,192 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+)
+
+// findChainHead returns the wallet's chain head, or an empty head for a wallet
+// whose chain has not started
+func (r *WalletRepository) findChainHead(ctx context.Context, walletID string) (*types.LedgerChainHead, error) {
+	head := &types.LedgerChainHead{WalletID: walletID}
+
+	err := r.db.NewSelect().
+		Model(head).
+		WherePK().
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &types.LedgerChainHead{WalletID: walletID}, nil
+		}
+		return nil, fmt.Errorf("failed to load chain head: %w", err)
+	}
+
+	return head, nil
+}
+
+// advanceChainHead moves the wallet's chain head to a newly sealed entry. The
+// update only applies if the head is still the one the entry was sealed
+// against, so two writers can never extend the chain from the same predecessor.
+func (r *WalletRepository) advanceChainHead(ctx context.Context, head *types.LedgerChainHead, walletID, hash string) error {
+	next := &types.LedgerChainHead{
+		WalletID:  walletID,
+		HeadHash:  hash,
+		Length:    head.Length + 1,
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	if head.HeadHash == "" {
+		if _, err := r.db.NewInsert().Model(next).Exec(ctx); err != nil {
+			return fmt.Errorf("%w: %v", types.ErrChainHeadMismatch, err)
+		}
+		return nil
+	}
+
+	res, err := r.db.NewUpdate().
+		Model(next).
+		WherePK().
+		Where("head_hash = ?", head.HeadHash).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to advance chain head: %w", err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return types.ErrChainHeadMismatch
+	}
+
+	return nil
+}
+
+// VerifyLedgerChain checks every row and status change of a wallet against its
+// hash and links, and the chain against the recorded head
+func (r *WalletRepository) VerifyLedgerChain(ctx context.Context, walletID string) (*types.ChainVerification, error) {
+	var transactions []*types.TransactionHistory
+	err := r.db.NewSelect().
+		Model(&transactions).
+		Where("wallet_id = ?", walletID).
+		OrderExpr("created_at ASC, id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load wallet transactions: %w", err)
+	}
+
+	var changes []*types.TransactionStatusChange
+	err = r.db.NewSelect().
+		Model(&changes).
+		Where("wallet_id = ?", walletID).
+		OrderExpr("created_at ASC, id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load status changes: %w", err)
+	}
+
+	head, err := r.findChainHead(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if head.HeadHash == "" {
+		head = nil
+	}
+
+	return types.VerifyWalletChain(walletID, transactions, changes, head), nil
+}
+
+// CreateChainCheckpoint signs and stores a snapshot of every wallet's chain head
+func (r *WalletRepository) CreateChainCheckpoint(ctx context.Context, signer *types.CheckpointSigner) (*types.ChainCheckpoint, error) {
+	var heads []*types.LedgerChainHead
+	if err := r.db.NewSelect().Model(&heads).Order("wallet_id ASC").Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load chain heads: %w", err)
+	}
+
+	checkpoint, err := types.NewChainCheckpoint(heads, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := r.db.NewInsert().Model(checkpoint).Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to save chain checkpoint: %w", err)
+	}
+
+	return checkpoint, nil
+}
+
+// FindChainCheckpoint retrieves a checkpoint by ID
+func (r *WalletRepository) FindChainCheckpoint(ctx context.Context, id string) (*types.ChainCheckpoint, error) {
+	checkpoint := &types.ChainCheckpoint{ID: id}
+
+	err := r.db.NewSelect().
+		Model(checkpoint).
+		WherePK().
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.ErrCheckpointNotFound
+		}
+		return nil, err
+	}
+
+	return checkpoint, nil
+}
+
+// VerifyChainCheckpoint checks the checkpoint signature, then verifies that every
+// wallet chain it covers is intact and still passes through the captured head
+func (r *WalletRepository) VerifyChainCheckpoint(
+	ctx context.Context,
+	checkpointID string,
+	publicKey []byte,
+) ([]*types.ChainViolation, error) {
+	checkpoint, err := r.FindChainCheckpoint(ctx, checkpointID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkpoint.Verify(publicKey); err != nil {
+		return nil, err
+	}
+
+	var violations []*types.ChainViolation
+	for _, captured := range checkpoint.Heads {
+		verification, err := r.VerifyLedgerChain(ctx, captured.WalletID)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, verification.Violations...)
+
+		if hash, ok := verification.HashAt(captured.Length); !ok || hash != captured.HeadHash {
+			violations = append(violations, &types.ChainViolation{
+				Kind:     types.ChainTruncated,
+				WalletID: captured.WalletID,
+				Detail:   fmt.Sprintf("chain no longer contains checkpointed entry %d (%s)", captured.Length, captured.HeadHash),
+			})
+		}
+	}
+
+	return violations, nil
+}
+
+// RunChainCheckpoints creates a signed checkpoint every interval until ctx is
+// cancelled. Failures are passed to onError (when set) and do not stop the loop.
+func (r *WalletRepository) RunChainCheckpoints(
+	ctx context.Context,
+	signer *types.CheckpointSigner,
+	interval time.Duration,
+	onError func(error),
+) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := r.CreateChainCheckpoint(ctx, signer); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
@@ -31,6 +31,9 @@ func (r *WalletRepository) ProcessLien(
 		if err != nil {
 			return fmt.Errorf("failed to get wallet: %w", err)
 		}
+		if err := r.authorize(ctx, types.ActionLien, "", wallet); err != nil {
+			return err
+		}
 
 		// 2. Perform the lien operation
 		switch strings.ToLower(operationType) {
@@ -53,22 +56,20 @@ func (r *WalletRepository) ProcessLien(
 
 		// 4. Record lien operation
 		if operationType == "lien" {
-			_, err = tx.NewInsert().
-				Model(lienRecord).
-				Exec(ctx)
+			err = repo.CreateLien(ctx, lienRecord)
 		} else {
-			_, err = tx.NewUpdate().
-				Model(lienRecord).
-				WherePK().
-				Column("released_at").
-				Exec(ctx)
+			err = repo.RecordLienRelease(ctx, lienRecord)
 		}
 
 		if err != nil {
 			return fmt.Errorf("failed to record %s operation: %w", operationType, err)
 		}
 
-		return nil
+		eventType := types.EventLienPlaced
+		if operationType != "lien" {
+			eventType = types.EventLienReleased
+		}
+		return repo.emit(ctx, eventType, &types.WalletEvent{Wallet: wallet, Lien: lienRecord})
 	})
 	if err != nil {
 		return nil, nil, fmt.Errorf("%s processing failed: %w", operationType, err)
@@ -76,3 +77,50 @@ func (r *WalletRepository) ProcessLien(
 
 	return lienRecord, wallet, nil
 }
+
+// CreateLien records a lien placement
+func (r *WalletRepository) CreateLien(ctx context.Context, lien *types.LienRecord) error {
+	_, err := r.db.NewInsert().
+		Model(lien).
+		Exec(ctx)
+	return err
+}
+
+// RecordLienRelease marks the lien released and keeps a row for the release
+// itself, so its amount and time can be replayed
+func (r *WalletRepository) RecordLienRelease(ctx context.Context, release *types.LienRecord) error {
+	res, err := r.db.NewUpdate().
+		Model(release).
+		Where("id = ?", release.ID).
+		Where("wallet_id = ?", release.WalletID).
+		Where("lien_id IS NULL").
+		Column("released_at").
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return types.ErrLienNotFound
+	}
+
+	_, err = r.db.NewInsert().
+		Model(release.ReleaseRecord()).
+		Exec(ctx)
+	return err
+}
+
+// ListLiens returns a wallet's lien placements and releases, oldest first
+func (r *WalletRepository) ListLiens(ctx context.Context, walletID string) ([]*types.LienRecord, error) {
+	var liens []*types.LienRecord
+
+	err := r.db.NewSelect().
+		Model(&liens).
+		Where("wallet_id = ?", walletID).
+		OrderExpr("created_at ASC, id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list liens: %w", err)
+	}
+
+	return liens, nil
+}
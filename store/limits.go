@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/uptrace/bun"
+)
+
+// EnforceLimits makes balance operations check the customer's KYC tier limits
+// inside the operation's DB transaction. Pass nil to turn limits off.
+func (r *WalletRepository) EnforceLimits(schedule *types.LimitSchedule) {
+	r.limits = schedule
+}
+
+// SetCustomerTier records the KYC tier a customer has been verified to
+func (r *WalletRepository) SetCustomerTier(ctx context.Context, customerID string, tier types.KYCTier) error {
+	if strings.TrimSpace(customerID) == "" {
+		return ErrCustomerIDRequired
+	}
+
+	_, err := r.db.NewInsert().
+		Model(&types.CustomerKYCTier{CustomerID: customerID, Tier: tier, UpdatedAt: time.Now().UTC()}).
+		On("CONFLICT (customer_id) DO UPDATE").
+		Set("tier = EXCLUDED.tier").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to set customer tier: %w", err)
+	}
+
+	return nil
+}
+
+// FindCustomerTier returns a customer's KYC tier, or the schedule's default
+// tier (KYCTierNone without a schedule) when none is recorded
+func (r *WalletRepository) FindCustomerTier(ctx context.Context, customerID string) (types.KYCTier, error) {
+	record := &types.CustomerKYCTier{CustomerID: customerID}
+
+	err := r.db.NewSelect().
+		Model(record).
+		WherePK().
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			if r.limits != nil && r.limits.DefaultTier != "" {
+				return r.limits.DefaultTier, nil
+			}
+			return types.KYCTierNone, nil
+		}
+		return "", err
+	}
+
+	return record.Tier, nil
+}
+
+// GetLimitUsage returns a customer's rolling debit usage, balance and the
+// limits that apply to them in a currency
+func (r *WalletRepository) GetLimitUsage(ctx context.Context, customerID, currencyCode string) (*types.LimitUsage, error) {
+	currencyCode = strings.ToUpper(strings.TrimSpace(currencyCode))
+
+	tier, err := r.FindCustomerTier(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &types.LimitUsage{
+		CustomerID:   customerID,
+		CurrencyCode: currencyCode,
+		Tier:         tier,
+		Windows:      make(map[types.LimitWindow]types.WindowUsage),
+	}
+	if r.limits != nil {
+		if rule, ok := r.limits.Rule(tier, currencyCode); ok {
+			usage.Rule = rule
+		}
+	}
+
+	now := time.Now().UTC()
+	for _, window := range types.LimitWindows {
+		w, err := r.debitUsage(ctx, customerID, currencyCode, now.Add(-window.Duration()))
+		if err != nil {
+			return nil, err
+		}
+		usage.Windows[window] = w
+	}
+
+	var balance struct {
+		Total decimal.Decimal `bun:"total"`
+	}
+	err = r.db.NewSelect().
+		Model((*types.Wallet)(nil)).
+		ColumnExpr("COALESCE(SUM(available_balance + lien_balance), 0) AS total").
+		Where("customer_id = ?", customerID).
+		Where("currency_code = ?", currencyCode).
+		Scan(ctx, &balance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum balances: %w", err)
+	}
+	usage.Balance = balance.Total
+
+	return usage, nil
+}
+
+// debitUsage sums a customer's debits in a currency since the given time.
+// Failed and reversed debits moved no funds in the end, and adjustment and
+// reversal rows are written by the system, so none of them count.
+func (r *WalletRepository) debitUsage(ctx context.Context, customerID, currencyCode string, since time.Time) (types.WindowUsage, error) {
+	var row struct {
+		Volume decimal.Decimal `bun:"volume"`
+		Count  int             `bun:"count"`
+	}
+
+	err := r.db.NewSelect().
+		Model((*types.TransactionHistory)(nil)).
+		ColumnExpr("COALESCE(SUM(amount), 0) AS volume").
+		ColumnExpr("COUNT(*) AS count").
+		Where("wallet_id IN (?)", r.db.NewSelect().
+			Model((*types.Wallet)(nil)).
+			Column("id").
+			Where("customer_id = ?", customerID)).
+		Where("currency_code = ?", currencyCode).
+		Where("type = ?", types.TypeDebit).
+		Where("status NOT IN (?)", bun.In([]types.TransactionStatus{types.StatusFailed, types.StatusReversed})).
+		Where("category NOT IN (?)", bun.In([]types.TransactionCategory{types.CategoryAdjustment, types.CategoryReversal})).
+		Where("created_at >= ?", since).
+		Scan(ctx, &row)
+	if err != nil {
+		return types.WindowUsage{}, fmt.Errorf("failed to sum debit usage: %w", err)
+	}
+
+	return types.WindowUsage{Volume: row.Volume, Count: row.Count}, nil
+}
+
+// limitRule returns the rule that applies to the wallet's owner, if any
+func (r *WalletRepository) limitRule(ctx context.Context, wallet *types.Wallet) (*types.LimitRule, error) {
+	if r.limits == nil {
+		return nil, nil
+	}
+
+	tier, err := r.FindCustomerTier(ctx, wallet.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	rule, ok := r.limits.Rule(tier, wallet.CurrencyCode)
+	if !ok {
+		return nil, nil
+	}
+	return rule, nil
+}
+
+// checkDebitLimits checks a debit of amount from wallet against its owner's
+// per-transaction and rolling window limits
+func (r *WalletRepository) checkDebitLimits(ctx context.Context, wallet *types.Wallet, amount decimal.Decimal) error {
+	rule, err := r.limitRule(ctx, wallet)
+	if err != nil || rule == nil {
+		return err
+	}
+
+	usage := make(map[types.LimitWindow]types.WindowUsage)
+	now := time.Now().UTC()
+	for window := range rule.Windows {
+		if usage[window], err = r.debitUsage(ctx, wallet.CustomerID, wallet.CurrencyCode, now.Add(-window.Duration())); err != nil {
+			return err
+		}
+	}
+
+	return rule.CheckDebit(amount, usage)
+}
+
+// checkCreditLimits checks the balance the credit recorded by row leaves on
+// wallet against its owner's maximum balance. wallet already holds the credit.
+func (r *WalletRepository) checkCreditLimits(ctx context.Context, wallet *types.Wallet, row *types.TransactionHistory) error {
+	rule, err := r.limitRule(ctx, wallet)
+	if err != nil || rule == nil {
+		return err
+	}
+
+	credited := row.BalanceAfter.Sub(row.BalanceBefore)
+	return rule.CheckBalance(wallet.TotalBalance().Sub(credited), credited)
+}
@@ -0,0 +1,580 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+)
+
+// Errors mirroring the primary key violations a database reports
+var (
+	errDuplicateWallet      = errors.New("wallet already exists")
+	errDuplicateTransaction = errors.New("transaction already exists")
+)
+
+// memoryData is the data held by a MemoryStore
+type memoryData struct {
+	wallets      map[string]*types.Wallet
+	transactions map[string]*types.TransactionHistory
+	liens        []*types.LienRecord // In insertion order
+	currencies   map[string]*types.CurrencyInfo
+	chainHeads   map[string]*types.LedgerChainHead
+	changes      []*types.TransactionStatusChange // In insertion order
+}
+
+func newMemoryData() *memoryData {
+	return &memoryData{
+		wallets:      make(map[string]*types.Wallet),
+		transactions: make(map[string]*types.TransactionHistory),
+		currencies:   make(map[string]*types.CurrencyInfo),
+		chainHeads:   make(map[string]*types.LedgerChainHead),
+	}
+}
+
+// clone copies every record so the copy can be changed independently
+func (d *memoryData) clone() *memoryData {
+	c := newMemoryData()
+	for id, wallet := range d.wallets {
+		c.wallets[id] = wallet.Clone()
+	}
+	for id, tx := range d.transactions {
+		row := *tx
+		c.transactions[id] = &row
+	}
+	c.liens = make([]*types.LienRecord, len(d.liens))
+	for i, lien := range d.liens {
+		record := *lien
+		c.liens[i] = &record
+	}
+	for code, currency := range d.currencies {
+		info := *currency
+		c.currencies[code] = &info
+	}
+	for id, head := range d.chainHeads {
+		h := *head
+		c.chainHeads[id] = &h
+	}
+	for _, change := range d.changes {
+		ch := *change
+		c.changes = append(c.changes, &ch)
+	}
+	return c
+}
+
+// MemoryStore is a thread-safe in-memory Repository for unit tests of code
+// that would otherwise need a database. It keeps the same rules as the bun
+// implementation: optimistic wallet versions, the transaction hash chain,
+// and all-or-nothing RunInTx.
+//
+// Transactions run one at a time against a private copy of the data, which
+// replaces the shared copy when they commit. Writes outside a transaction
+// wait for the running one, so code inside RunInTx must use the repository
+// it is given rather than the outer store.
+type MemoryStore struct {
+	mu     *sync.RWMutex // Guards data
+	writer *sync.Mutex   // Serialises transactions on the shared data
+	data   *memoryData
+	inTx   bool
+}
+
+// NewMemoryStore returns an empty in-memory repository
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		mu:     new(sync.RWMutex),
+		writer: new(sync.Mutex),
+		data:   newMemoryData(),
+	}
+}
+
+// RunInTx calls fn with a repository holding a private copy of the data and
+// keeps fn's changes only if it returns nil
+func (m *MemoryStore) RunInTx(ctx context.Context, fn func(ctx context.Context, repo Repository) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !m.inTx {
+		m.writer.Lock()
+		defer m.writer.Unlock()
+	}
+
+	m.mu.RLock()
+	tx := &MemoryStore{mu: new(sync.RWMutex), writer: m.writer, data: m.data.clone(), inTx: true}
+	m.mu.RUnlock()
+
+	if err := fn(ctx, tx); err != nil {
+		return err
+	}
+
+	tx.mu.RLock()
+	defer tx.mu.RUnlock()
+	m.mu.Lock()
+	m.data = tx.data
+	m.mu.Unlock()
+	return nil
+}
+
+// write applies fn under the write lock. Each operation checks everything
+// before changing data, so a failed fn leaves it untouched.
+func (m *MemoryStore) write(fn func(d *memoryData) error) error {
+	if !m.inTx {
+		m.writer.Lock()
+		defer m.writer.Unlock()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fn(m.data)
+}
+
+// read applies fn under the read lock
+func (m *MemoryStore) read(fn func(d *memoryData) error) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return fn(m.data)
+}
+
+// CreateWallet inserts a wallet, or returns the customer's existing wallet in
+// that currency
+func (m *MemoryStore) CreateWallet(ctx context.Context, wallet *types.Wallet) (*types.Wallet, error) {
+	if err := prepareWallet(wallet); err != nil {
+		return nil, err
+	}
+
+	var existing *types.Wallet
+	err := m.write(func(d *memoryData) error {
+		if existing = d.walletByCurrency(wallet.CustomerID, wallet.CurrencyCode); existing != nil {
+			return nil
+		}
+		if _, taken := d.wallets[wallet.ID]; taken {
+			return fmt.Errorf("failed to create wallet: %w: %s", errDuplicateWallet, wallet.ID)
+		}
+
+		d.wallets[wallet.ID] = wallet.Clone()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	return wallet, nil
+}
+
+// UpdateWallet saves a wallet if its version is still current
+func (m *MemoryStore) UpdateWallet(ctx context.Context, wallet *types.Wallet) (*types.Wallet, error) {
+	if wallet == nil {
+		return nil, errors.New("wallet cannot be nil")
+	}
+
+	oldVersion := wallet.VersionId
+	wallet.VersionId = types.GenerateID("ver_", 8)
+	wallet.UpdatedAt = time.Now().UTC()
+
+	err := m.write(func(d *memoryData) error {
+		stored, ok := d.wallets[wallet.ID]
+		if !ok || stored.VersionId != oldVersion {
+			return ErrConcurrentModification
+		}
+		d.wallets[wallet.ID] = wallet.Clone()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return wallet, nil
+}
+
+// FindWalletByID retrieves a wallet by its ID
+func (m *MemoryStore) FindWalletByID(ctx context.Context, walletID string) (*types.Wallet, error) {
+	var wallet *types.Wallet
+	err := m.read(func(d *memoryData) error {
+		stored, ok := d.wallets[walletID]
+		if !ok {
+			return ErrWalletNotFound
+		}
+		wallet = stored.Clone()
+		return nil
+	})
+	return wallet, err
+}
+
+// FindWalletByCurrency retrieves a wallet by customer ID and currency code
+func (m *MemoryStore) FindWalletByCurrency(ctx context.Context, customerID, currencyCode string) (*types.Wallet, error) {
+	var wallet *types.Wallet
+	err := m.read(func(d *memoryData) error {
+		if wallet = d.walletByCurrency(customerID, strings.ToUpper(currencyCode)); wallet == nil {
+			return ErrWalletNotFound
+		}
+		return nil
+	})
+	return wallet, err
+}
+
+// walletByCurrency returns a copy of the customer's wallet in the currency, or nil
+func (d *memoryData) walletByCurrency(customerID, currencyCode string) *types.Wallet {
+	for _, wallet := range d.wallets {
+		if wallet.CustomerID == customerID && wallet.CurrencyCode == currencyCode {
+			return wallet.Clone()
+		}
+	}
+	return nil
+}
+
+// CreateTransaction validates a row and links it into its wallet's hash chain
+func (m *MemoryStore) CreateTransaction(ctx context.Context, txData *types.TransactionHistory) (*types.TransactionHistory, error) {
+	if txData == nil {
+		return nil, errors.New("transaction data cannot be nil")
+	}
+
+	txData.CreatedAt = time.Now().UTC().Truncate(types.ChainTimePrecision)
+	txData.UpdatedAt = txData.CreatedAt
+
+	if err := validateTransaction(txData); err != nil {
+		return nil, err
+	}
+
+	err := m.write(func(d *memoryData) error {
+		if _, taken := d.transactions[txData.ID]; taken {
+			return fmt.Errorf("%w: %s", errDuplicateTransaction, txData.ID)
+		}
+
+		head := d.chainHeads[txData.WalletID]
+		if head == nil {
+			head = &types.LedgerChainHead{WalletID: txData.WalletID}
+		}
+		txData.SealChain(head.HeadHash)
+
+		row := *txData
+		d.transactions[row.ID] = &row
+		d.chainHeads[row.WalletID] = &types.LedgerChainHead{
+			WalletID:  row.WalletID,
+			HeadHash:  row.Hash,
+			Length:    head.Length + 1,
+			UpdatedAt: time.Now().UTC(),
+		}
+		return nil
+	})
+
+	return txData, err
+}
+
+// UpdateTransaction moves a transaction to a new status
+func (m *MemoryStore) UpdateTransaction(ctx context.Context, tx *types.TransactionHistory) (*types.TransactionHistory, error) {
+	if tx == nil {
+		return nil, errors.New("transaction cannot be nil")
+	}
+
+	err := m.write(func(d *memoryData) error {
+		existing, ok := d.transactions[tx.ID]
+		if !ok {
+			return ErrTransactionNotFound
+		}
+		if existing.Status == types.StatusCompleted {
+			return types.ErrTransactionCompleted
+		}
+		if !existing.CanTransitionTo(tx.Status) {
+			return types.ErrInvalidStatusTransition
+		}
+
+		tx.UpdatedAt = time.Now().UTC()
+		tx.PrevHash, tx.Hash = existing.PrevHash, existing.Hash
+
+		if existing.Hash != "" {
+			head := d.chainHeads[existing.WalletID]
+			if head == nil {
+				head = &types.LedgerChainHead{WalletID: existing.WalletID}
+			}
+			change := types.NewTransactionStatusChange(existing, tx.Status)
+			change.SealChain(head.HeadHash)
+
+			d.changes = append(d.changes, change)
+			d.chainHeads[change.WalletID] = &types.LedgerChainHead{
+				WalletID:  change.WalletID,
+				HeadHash:  change.Hash,
+				Length:    head.Length + 1,
+				UpdatedAt: time.Now().UTC(),
+			}
+		}
+
+		row := *tx
+		d.transactions[row.ID] = &row
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// FindTransactionByID retrieves a transaction by its ID
+func (m *MemoryStore) FindTransactionByID(ctx context.Context, id string) (*types.TransactionHistory, error) {
+	var tx *types.TransactionHistory
+	err := m.read(func(d *memoryData) error {
+		stored, ok := d.transactions[id]
+		if !ok {
+			return ErrTransactionNotFound
+		}
+		row := *stored
+		tx = &row
+		return nil
+	})
+	return tx, err
+}
+
+// ListTransactions filters, sorts and pages transactions the way the SQL
+// implementation does
+func (m *MemoryStore) ListTransactions(ctx context.Context, params ListTransactionsParams) (*ListTransactionsResult, error) {
+	if params.PageSize < 1 || params.PageSize > 100 {
+		params.PageSize = 25
+	}
+	switch params.SortBy {
+	case "id", "created_at", "amount", "updated_at":
+	default:
+		params.SortBy = "created_at"
+	}
+	params.SortOrder = strings.ToLower(params.SortOrder)
+	if params.SortOrder != "asc" && params.SortOrder != "desc" {
+		params.SortOrder = "desc"
+	}
+	desc := params.SortOrder == "desc"
+
+	// after reports whether a sorts after b in ascending order
+	after := func(a, b *types.TransactionHistory, field string) int {
+		switch field {
+		case "created_at":
+			return a.CreatedAt.Compare(b.CreatedAt)
+		case "updated_at":
+			return a.UpdatedAt.Compare(b.UpdatedAt)
+		case "amount":
+			return a.Amount.Cmp(b.Amount)
+		default:
+			return strings.Compare(a.ID, b.ID)
+		}
+	}
+
+	// The cursor is compared on the sort field, except updated_at which
+	// pages by ID, and is ignored if it does not parse
+	var cursor *types.TransactionHistory
+	cursorField := "id"
+	if params.Cursor != "" {
+		switch params.SortBy {
+		case "created_at":
+			if at, err := time.Parse(time.RFC3339Nano, params.Cursor); err == nil {
+				cursor, cursorField = &types.TransactionHistory{CreatedAt: at}, "created_at"
+			}
+		case "amount":
+			if amount, err := decimal.NewFromString(params.Cursor); err == nil {
+				cursor, cursorField = &types.TransactionHistory{Amount: amount}, "amount"
+			}
+		default:
+			cursor = &types.TransactionHistory{ID: params.Cursor}
+		}
+	}
+
+	var transactions []*types.TransactionHistory
+	err := m.read(func(d *memoryData) error {
+		for _, stored := range d.transactions {
+			switch {
+			case params.WalletID != "" && stored.WalletID != params.WalletID,
+				params.CurrencyCode != "" && stored.CurrencyCode != params.CurrencyCode,
+				params.Category != "" && stored.Category != params.Category,
+				params.Status != "" && stored.Status != params.Status,
+				params.GroupID != "" && stored.GroupID != params.GroupID,
+				!params.StartTime.IsZero() && stored.CreatedAt.Before(params.StartTime),
+				!params.EndTime.IsZero() && stored.CreatedAt.After(params.EndTime):
+				continue
+			}
+			if cursor != nil {
+				cmp := after(stored, cursor, cursorField)
+				if (desc && cmp >= 0) || (!desc && cmp <= 0) {
+					continue
+				}
+			}
+			row := *stored
+			transactions = append(transactions, &row)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(transactions, func(i, j int) bool {
+		cmp := after(transactions[i], transactions[j], params.SortBy)
+		if cmp == 0 {
+			cmp = strings.Compare(transactions[i].ID, transactions[j].ID)
+		}
+		if desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+
+	hasNext := len(transactions) > params.PageSize
+	if hasNext {
+		transactions = transactions[:params.PageSize]
+	}
+
+	var nextCursor string
+	if len(transactions) > 0 {
+		lastTx := transactions[len(transactions)-1]
+
+		switch params.SortBy {
+		case "created_at":
+			nextCursor = lastTx.CreatedAt.Format(time.RFC3339Nano)
+		case "amount":
+			nextCursor = lastTx.Amount.String()
+		default:
+			nextCursor = lastTx.ID
+		}
+	}
+
+	return &ListTransactionsResult{
+		Transactions: transactions,
+		NextCursor:   nextCursor,
+		HasNext:      hasNext,
+	}, nil
+}
+
+// CreateLien records a lien placement
+func (m *MemoryStore) CreateLien(ctx context.Context, lien *types.LienRecord) error {
+	return m.write(func(d *memoryData) error {
+		record := *lien
+		d.liens = append(d.liens, &record)
+		return nil
+	})
+}
+
+// RecordLienRelease marks the lien released and records the release
+func (m *MemoryStore) RecordLienRelease(ctx context.Context, release *types.LienRecord) error {
+	return m.write(func(d *memoryData) error {
+		var placements []*types.LienRecord
+		for _, lien := range d.liens {
+			if lien.ID == release.ID && lien.WalletID == release.WalletID && !lien.IsRelease() {
+				placements = append(placements, lien)
+			}
+		}
+		if len(placements) == 0 {
+			return types.ErrLienNotFound
+		}
+
+		for _, lien := range placements {
+			lien.ReleasedAt = release.ReleasedAt
+		}
+		d.liens = append(d.liens, release.ReleaseRecord())
+		return nil
+	})
+}
+
+// ListLiens returns a wallet's lien placements and releases, oldest first
+func (m *MemoryStore) ListLiens(ctx context.Context, walletID string) ([]*types.LienRecord, error) {
+	var liens []*types.LienRecord
+	err := m.read(func(d *memoryData) error {
+		for _, lien := range d.liens {
+			if lien.WalletID == walletID {
+				record := *lien
+				liens = append(liens, &record)
+			}
+		}
+		return nil
+	})
+
+	sort.SliceStable(liens, func(i, j int) bool {
+		if !liens[i].CreatedAt.Equal(liens[j].CreatedAt) {
+			return liens[i].CreatedAt.Before(liens[j].CreatedAt)
+		}
+		return liens[i].ID < liens[j].ID
+	})
+	return liens, err
+}
+
+// CreateCurrency adds a supported currency
+func (m *MemoryStore) CreateCurrency(ctx context.Context, currency *types.CurrencyInfo) (*types.CurrencyInfo, error) {
+	if err := normalizeCurrency(currency); err != nil {
+		return nil, err
+	}
+	if currency.CreatedAt.IsZero() {
+		currency.CreatedAt = time.Now().UTC()
+	}
+	currency.UpdatedAt = currency.CreatedAt
+
+	err := m.write(func(d *memoryData) error {
+		if _, taken := d.currencies[currency.Code]; taken {
+			return fmt.Errorf("%w: %s", ErrCurrencyExists, currency.Code)
+		}
+		info := *currency
+		d.currencies[info.Code] = &info
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return currency, nil
+}
+
+// UpdateCurrency saves changes to a supported currency, keeping its creation time
+func (m *MemoryStore) UpdateCurrency(ctx context.Context, currency *types.CurrencyInfo) (*types.CurrencyInfo, error) {
+	if err := normalizeCurrency(currency); err != nil {
+		return nil, err
+	}
+	currency.UpdatedAt = time.Now().UTC()
+
+	err := m.write(func(d *memoryData) error {
+		existing, ok := d.currencies[currency.Code]
+		if !ok {
+			return fmt.Errorf("%w: %s", types.ErrCurrencyNotFound, currency.Code)
+		}
+		info := *currency
+		info.CreatedAt = existing.CreatedAt
+		d.currencies[info.Code] = &info
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return currency, nil
+}
+
+// FindCurrency retrieves a currency by its code
+func (m *MemoryStore) FindCurrency(ctx context.Context, code string) (*types.CurrencyInfo, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+
+	var currency *types.CurrencyInfo
+	err := m.read(func(d *memoryData) error {
+		stored, ok := d.currencies[code]
+		if !ok {
+			return fmt.Errorf("%w: %s", types.ErrCurrencyNotFound, code)
+		}
+		info := *stored
+		currency = &info
+		return nil
+	})
+	return currency, err
+}
+
+// ListCurrencies returns all supported currencies ordered by code
+func (m *MemoryStore) ListCurrencies(ctx context.Context) ([]*types.CurrencyInfo, error) {
+	var currencies []*types.CurrencyInfo
+	err := m.read(func(d *memoryData) error {
+		for _, stored := range d.currencies {
+			info := *stored
+			currencies = append(currencies, &info)
+		}
+		return nil
+	})
+
+	sort.Slice(currencies, func(i, j int) bool { return currencies[i].Code < currencies[j].Code })
+	return currencies, err
+}
@@ -0,0 +1,198 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/uptrace/bun"
+)
+
+// EnableOutbox writes a domain event to the outbox table in the same database
+// transaction as every wallet change, for a relay to publish
+func (r *WalletRepository) EnableOutbox(enabled bool) {
+	r.outbox = enabled
+}
+
+// emit writes a wallet event to the outbox. Call it on a repository bound to
+// the transaction making the change.
+func (r *WalletRepository) emit(ctx context.Context, eventType types.EventType, payload *types.WalletEvent) error {
+	if !r.outbox {
+		return nil
+	}
+
+	event, err := types.NewOutboxEvent(eventType, payload.Wallet.ID, payload)
+	if err != nil {
+		return err
+	}
+	if _, err := r.db.NewInsert().Model(event).Exec(ctx); err != nil {
+		return fmt.Errorf("failed to write %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+// ListUnpublishedEvents returns up to limit events waiting to be published, in
+// the order they were written
+func (r *WalletRepository) ListUnpublishedEvents(ctx context.Context, limit int) ([]*types.OutboxEvent, error) {
+	var events []*types.OutboxEvent
+
+	err := r.db.NewSelect().
+		Model(&events).
+		Where("published_at IS NULL").
+		OrderExpr("id ASC").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unpublished events: %w", err)
+	}
+
+	return events, nil
+}
+
+// SequenceEvent assigns the event the next publish sequence unless it already
+// has one. Sequences are handed out one at a time by the relay, each committed
+// before the event is published, so a reader that has seen a sequence has seen
+// every lower one.
+func (r *WalletRepository) SequenceEvent(ctx context.Context, event *types.OutboxEvent) error {
+	if event.PublishSequence != 0 {
+		return nil
+	}
+
+	var sequence int64
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		err := tx.NewSelect().
+			Model((*types.OutboxEvent)(nil)).
+			ColumnExpr("COALESCE(MAX(publish_sequence), 0)").
+			Scan(ctx, &sequence)
+		if err != nil {
+			return err
+		}
+		sequence++
+
+		_, err = tx.NewUpdate().
+			Model((*types.OutboxEvent)(nil)).
+			Set("publish_sequence = ?", sequence).
+			Where("id = ?", event.ID).
+			Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to sequence event: %w", err)
+	}
+
+	event.PublishSequence = sequence
+	return nil
+}
+
+// MarkEventPublished records that an event was delivered
+func (r *WalletRepository) MarkEventPublished(ctx context.Context, id int64) error {
+	_, err := r.db.NewUpdate().
+		Model((*types.OutboxEvent)(nil)).
+		Set("published_at = ?", time.Now().UTC()).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to mark event published: %w", err)
+	}
+	return nil
+}
+
+// MarkEventFailed records a failed delivery attempt; the event stays unpublished
+func (r *WalletRepository) MarkEventFailed(ctx context.Context, id int64, cause error) error {
+	_, err := r.db.NewUpdate().
+		Model((*types.OutboxEvent)(nil)).
+		Set("attempts = attempts + 1").
+		Set("last_error = ?", cause.Error()).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to record event failure: %w", err)
+	}
+	return nil
+}
+
+// ListEvents returns a wallet's events published after the given publish
+// sequence, in publish order. An empty wallet ID lists events of every wallet.
+// Events the relay has not reached yet are not listed.
+func (r *WalletRepository) ListEvents(ctx context.Context, walletID string, afterSequence int64, limit int) ([]*types.OutboxEvent, error) {
+	var events []*types.OutboxEvent
+
+	query := r.db.NewSelect().
+		Model(&events).
+		Where("publish_sequence > ?", afterSequence).
+		OrderExpr("publish_sequence ASC").
+		Limit(limit)
+	if walletID != "" {
+		query = query.Where("aggregate_id = ?", walletID)
+	}
+	if err := query.Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	return events, nil
+}
+
+// emitPair writes an event for each wallet of a two-sided movement, so both
+// wallets' streams are complete
+func (r *WalletRepository) emitPair(
+	ctx context.Context,
+	eventType types.EventType,
+	source, dest *types.Wallet,
+	sourceTx, destTx *types.TransactionHistory,
+) error {
+	err := r.emit(ctx, eventType, &types.WalletEvent{
+		Wallet:       source,
+		Counterparty: dest,
+		Transactions: []*types.TransactionHistory{sourceTx, destTx},
+	})
+	if err != nil {
+		return err
+	}
+	return r.emit(ctx, eventType, &types.WalletEvent{
+		Wallet:       dest,
+		Counterparty: source,
+		Transactions: []*types.TransactionHistory{sourceTx, destTx},
+	})
+}
+
+// emitForTransaction writes an event about a single row, with its wallet's
+// current state
+func (r *WalletRepository) emitForTransaction(ctx context.Context, eventType types.EventType, row *types.TransactionHistory) error {
+	if !r.outbox {
+		return nil
+	}
+
+	wallet, err := r.FindWalletByID(ctx, row.WalletID)
+	if err != nil {
+		return err
+	}
+	return r.emit(ctx, eventType, &types.WalletEvent{
+		Wallet:       wallet,
+		Transactions: []*types.TransactionHistory{row},
+	})
+}
+
+// ListCustomerEvents returns events of a customer's wallets published after the
+// given publish sequence, in publish order
+func (r *WalletRepository) ListCustomerEvents(ctx context.Context, customerID string, afterSequence int64, limit int) ([]*types.OutboxEvent, error) {
+	var events []*types.OutboxEvent
+
+	wallets := r.db.NewSelect().
+		Model((*types.Wallet)(nil)).
+		Column("id").
+		Where("customer_id = ?", customerID)
+
+	err := r.db.NewSelect().
+		Model(&events).
+		Where("publish_sequence > ?", afterSequence).
+		Where("aggregate_id IN (?)", wallets).
+		OrderExpr("publish_sequence ASC").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list customer events: %w", err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,413 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/uptrace/bun"
+)
+
+// DefaultPayoutChunkSize is how many best-effort payout items are paid per
+// database transaction
+const DefaultPayoutChunkSize = 100
+
+// payoutConflictAttempts bounds how often an item is retried when another
+// writer changes the funding wallet between the read and the write
+const payoutConflictAttempts = 3
+
+// SetPayoutChunkSize sets how many best-effort payout items are paid per
+// database transaction (DefaultPayoutChunkSize when zero)
+func (r *WalletRepository) SetPayoutChunkSize(size int) {
+	r.payoutChunkSize = size
+}
+
+// SubmitPayoutBatch validates a batch of transfers from one funding wallet,
+// stores it and pays it.
+//
+// The whole batch is checked before anything is paid: the funding wallet
+// must be able to pay every item in full, and each recipient must exist,
+// hold the same currency, accept credits and pass screening. An all-or-nothing batch fails
+// if any item does not pass and pays every item in one database transaction.
+// A best-effort batch drops the items that do not pass and pays the rest in
+// chunks, one transaction per chunk, recording each item's result with its
+// transfer so ResumePayoutBatch can finish a batch interrupted by a crash.
+//
+// Parameters:
+//   - req: The funding wallet, mode and recipients. A signature, when
+//     enforced, covers the whole batch. A Reference seen before returns that
+//     batch instead of paying again, once the repeat is verified like any
+//     request, the caller is authorized on the batch's funding wallet and the
+//     request matches it.
+//
+// Returns:
+//   - The batch with a result for every item, even when it failed
+//   - An error wrapping types.ErrPayoutRejected if validation failed, or the
+//     reason an all-or-nothing batch was not paid
+//   - An error wrapping types.ErrPayoutReferenceUsed if the Reference belongs
+//     to a batch with a different funding wallet, mode or items
+func (r *WalletRepository) SubmitPayoutBatch(ctx context.Context, req types.PayoutRequest) (*types.PayoutBatch, error) {
+	batch, err := types.NewPayoutBatch(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// The signature covers the whole batch; items are not signed one by one
+	if err := r.verifyRequest(ctx, req.InitiatorID, req.Signature, req.SigningPayload); err != nil {
+		return nil, err
+	}
+
+	if batch.Reference != "" {
+		existing, err := r.repeatPayoutBatch(ctx, batch)
+		if !errors.Is(err, types.ErrPayoutBatchNotFound) {
+			return existing, err
+		}
+	}
+
+	source, err := r.FindWalletByID(ctx, req.SourceWalletID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source wallet: %w", err)
+	}
+	if err := r.authorize(ctx, types.ActionTransfer, req.InitiatorID, source); err != nil {
+		return nil, err
+	}
+
+	// Screen up front, outside the payout transaction: an all-or-nothing
+	// rollback would otherwise undo the freeze and case a hit opens
+	if err := r.screenTransferParties(ctx, source.ID); err != nil {
+		return nil, err
+	}
+
+	recipients, err := r.findPayoutRecipients(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+	rejected := batch.Validate(source, recipients, func(item *types.PayoutItem) error {
+		if err := r.requireApproval(ctx, types.ApprovalTransfer, batch.TransactionCategory, source.CurrencyCode, item.Amount); err != nil {
+			return err
+		}
+		return r.screenTransferParties(ctx, item.DestinationWalletID)
+	})
+
+	// Rejected batches are stored too, so their item results can be looked up
+	stored, err := r.insertPayoutBatch(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+	if !stored {
+		// Another call with the same reference got there first
+		return r.repeatPayoutBatch(ctx, batch)
+	}
+	if rejected != nil {
+		return batch, rejected
+	}
+
+	return r.payPayoutBatch(ctx, batch)
+}
+
+// repeatPayoutBatch returns the stored batch with batch's reference, provided
+// the caller may pay from its funding wallet and batch asks for the same payout
+func (r *WalletRepository) repeatPayoutBatch(ctx context.Context, batch *types.PayoutBatch) (*types.PayoutBatch, error) {
+	existing, err := r.findPayoutBatch(ctx, "reference", batch.Reference)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := r.FindWalletByID(ctx, existing.SourceWalletID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source wallet: %w", err)
+	}
+	if err := r.authorize(ctx, types.ActionTransfer, batch.InitiatorID, source); err != nil {
+		return nil, err
+	}
+	if err := existing.CheckRepeat(batch); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// ResumePayoutBatch pays the items of a batch that were not paid before it
+// was interrupted. A finished batch is returned unchanged.
+func (r *WalletRepository) ResumePayoutBatch(ctx context.Context, batchID string) (*types.PayoutBatch, error) {
+	batch, err := r.FindPayoutBatch(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if batch.Status.IsFinal() {
+		return batch, nil
+	}
+
+	return r.payPayoutBatch(ctx, batch)
+}
+
+// FindPayoutBatch retrieves a batch with its items in submission order
+func (r *WalletRepository) FindPayoutBatch(ctx context.Context, batchID string) (*types.PayoutBatch, error) {
+	return r.findPayoutBatch(ctx, "id", batchID)
+}
+
+// ListUnfinishedPayoutBatches returns the batches still waiting to be paid,
+// oldest first, for resuming after a restart
+func (r *WalletRepository) ListUnfinishedPayoutBatches(ctx context.Context) ([]*types.PayoutBatch, error) {
+	var batches []*types.PayoutBatch
+
+	err := r.db.NewSelect().
+		Model(&batches).
+		Where("status IN (?)", bun.In([]types.PayoutBatchStatus{types.PayoutBatchPending, types.PayoutBatchProcessing})).
+		OrderExpr("created_at ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unfinished payout batches: %w", err)
+	}
+
+	return batches, nil
+}
+
+// payPayoutBatch pays a validated batch's pending items. Its items were
+// verified when the batch was submitted.
+func (r *WalletRepository) payPayoutBatch(ctx context.Context, batch *types.PayoutBatch) (*types.PayoutBatch, error) {
+	ctx = withVerifiedRequest(ctx)
+
+	batch.Status = types.PayoutBatchProcessing
+	if err := r.updatePayoutBatch(ctx, batch); err != nil {
+		return batch, err
+	}
+
+	if batch.Mode == types.PayoutAllOrNothing {
+		return r.payAllOrNothing(ctx, batch)
+	}
+	return r.payBestEffort(ctx, batch)
+}
+
+// payAllOrNothing pays every pending item in one transaction. If an item
+// fails, nothing is paid and the batch records which item failed and why.
+func (r *WalletRepository) payAllOrNothing(ctx context.Context, batch *types.PayoutBatch) (*types.PayoutBatch, error) {
+	pending := pendingPayoutItems(batch)
+
+	var failed *types.PayoutItem
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		repo := r.NewWithTx(tx)
+		for _, item := range pending {
+			if err := repo.payItem(ctx, batch, item); err != nil {
+				failed = item
+				return err
+			}
+			if err := repo.updatePayoutItem(ctx, item); err != nil {
+				return err
+			}
+		}
+
+		batch.Tally()
+		return repo.updatePayoutBatch(ctx, batch)
+	})
+	if err == nil {
+		return batch, nil
+	}
+	if failed == nil || ctx.Err() != nil {
+		return r.reloadPayoutBatch(ctx, batch, err)
+	}
+
+	// The transaction rolled back, so no item was paid
+	for _, item := range pending {
+		if item != failed {
+			item.Reset()
+		}
+	}
+	cause := fmt.Errorf("item %d: %w", failed.Seq, err)
+	batch.Fail(cause)
+
+	err = r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		repo := r.NewWithTx(tx)
+		for _, item := range pending {
+			if err := repo.updatePayoutItem(ctx, item); err != nil {
+				return err
+			}
+		}
+		return repo.updatePayoutBatch(ctx, batch)
+	})
+	if err != nil {
+		return batch, fmt.Errorf("failed to record payout failure: %w", err)
+	}
+
+	return batch, fmt.Errorf("payout failed: %w", cause)
+}
+
+// payBestEffort pays pending items a chunk per transaction. A failed item is
+// recorded and the rest are still paid.
+func (r *WalletRepository) payBestEffort(ctx context.Context, batch *types.PayoutBatch) (*types.PayoutBatch, error) {
+	size := r.payoutChunkSize
+	if size <= 0 {
+		size = DefaultPayoutChunkSize
+	}
+
+	pending := pendingPayoutItems(batch)
+	for start := 0; start < len(pending); start += size {
+		chunk := pending[start:min(start+size, len(pending))]
+
+		err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			repo := r.NewWithTx(tx)
+			for _, item := range chunk {
+				// A failed transfer rolls back only its own savepoint
+				if err := repo.payItem(ctx, batch, item); err != nil && ctx.Err() != nil {
+					return err
+				}
+				if err := repo.updatePayoutItem(ctx, item); err != nil {
+					return err
+				}
+			}
+
+			batch.Tally()
+			return repo.updatePayoutBatch(ctx, batch)
+		})
+		if err != nil {
+			return r.reloadPayoutBatch(ctx, batch, err)
+		}
+	}
+
+	return batch, nil
+}
+
+// payItem transfers one item's amount and records the result on the item.
+// Call it on a repository bound to a transaction.
+func (r *WalletRepository) payItem(ctx context.Context, batch *types.PayoutBatch, item *types.PayoutItem) error {
+	var err error
+	for attempt := 0; attempt < payoutConflictAttempts; attempt++ {
+		var sourceTx, destTx *types.TransactionHistory
+		sourceTx, destTx, err = r.TransferFunds(ctx, batch.SourceWalletID, item.DestinationWalletID, batch.TransferRequest(item))
+		if err == nil {
+			item.Complete(sourceTx, destTx)
+			return nil
+		}
+		if !errors.Is(err, ErrConcurrentModification) {
+			break
+		}
+	}
+
+	item.Fail(err)
+	return err
+}
+
+// reloadPayoutBatch returns the stored state of a batch after a transaction
+// paying it rolled back, along with the cause
+func (r *WalletRepository) reloadPayoutBatch(ctx context.Context, batch *types.PayoutBatch, cause error) (*types.PayoutBatch, error) {
+	cause = fmt.Errorf("payout interrupted, resume batch %s: %w", batch.ID, cause)
+
+	stored, err := r.FindPayoutBatch(context.WithoutCancel(ctx), batch.ID)
+	if err != nil {
+		return batch, errors.Join(cause, err)
+	}
+	return stored, cause
+}
+
+// findPayoutRecipients loads the batch's recipient wallets by ID
+func (r *WalletRepository) findPayoutRecipients(ctx context.Context, batch *types.PayoutBatch) (map[string]*types.Wallet, error) {
+	ids := make([]string, 0, len(batch.Items))
+	for _, item := range batch.Items {
+		ids = append(ids, item.DestinationWalletID)
+	}
+
+	var wallets []*types.Wallet
+	err := r.db.NewSelect().
+		Model(&wallets).
+		Where("id IN (?)", bun.In(ids)).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load payout recipients: %w", err)
+	}
+
+	recipients := make(map[string]*types.Wallet, len(wallets))
+	for _, wallet := range wallets {
+		recipients[wallet.ID] = wallet
+	}
+	return recipients, nil
+}
+
+// insertPayoutBatch stores a new batch and its items. It reports false, and
+// stores nothing, if a batch with the same reference already exists.
+func (r *WalletRepository) insertPayoutBatch(ctx context.Context, batch *types.PayoutBatch) (bool, error) {
+	stored := false
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		res, err := tx.NewInsert().
+			Model(batch).
+			On("CONFLICT (reference) DO NOTHING").
+			Returning("NULL").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if rows, _ := res.RowsAffected(); rows == 0 {
+			return nil
+		}
+		stored = true
+
+		_, err = tx.NewInsert().
+			Model(&batch.Items).
+			Returning("NULL").
+			Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to store payout batch: %w", err)
+	}
+
+	return stored, nil
+}
+
+// updatePayoutBatch saves a batch's status and counters
+func (r *WalletRepository) updatePayoutBatch(ctx context.Context, batch *types.PayoutBatch) error {
+	_, err := r.db.NewUpdate().
+		Model(batch).
+		Column("status", "completed_count", "failed_count", "error", "updated_at", "finished_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update payout batch: %w", err)
+	}
+	return nil
+}
+
+// updatePayoutItem saves an item's result
+func (r *WalletRepository) updatePayoutItem(ctx context.Context, item *types.PayoutItem) error {
+	_, err := r.db.NewUpdate().
+		Model(item).
+		Column("status", "error", "source_transaction_id", "destination_transaction_id", "processed_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update payout item: %w", err)
+	}
+	return nil
+}
+
+// findPayoutBatch retrieves a batch by a unique column with its items
+func (r *WalletRepository) findPayoutBatch(ctx context.Context, column, value string) (*types.PayoutBatch, error) {
+	batch := new(types.PayoutBatch)
+
+	err := r.db.NewSelect().
+		Model(batch).
+		Relation("Items", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.OrderExpr("seq ASC")
+		}).
+		Where("?TableAlias.? = ?", bun.Ident(column), value).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", types.ErrPayoutBatchNotFound, value)
+		}
+		return nil, fmt.Errorf("failed to get payout batch: %w", err)
+	}
+
+	return batch, nil
+}
+
+// pendingPayoutItems returns the items of a batch not yet paid or failed
+func pendingPayoutItems(batch *types.PayoutBatch) []*types.PayoutItem {
+	var pending []*types.PayoutItem
+	for _, item := range batch.Items {
+		if item.Status == types.PayoutItemPending {
+			pending = append(pending, item)
+		}
+	}
+	return pending
+}
@@ -0,0 +1,277 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/uptrace/bun"
+)
+
+// ErrProjectionNotCurrent is returned when applying a point-in-time rebuild
+var ErrProjectionNotCurrent = errors.New("only a rebuild of current state can be applied")
+
+// statusEvents maps each status change to the event announcing it
+var statusEvents = map[types.WalletStatusKind]types.EventType{
+	types.StatusChangeFreeze:   types.EventWalletFrozen,
+	types.StatusChangeUnfreeze: types.EventWalletUnfrozen,
+	types.StatusChangeClose:    types.EventWalletClosed,
+	types.StatusChangeReopen:   types.EventWalletReopened,
+}
+
+// ProjectionOptions controls a projection rebuild
+type ProjectionOptions struct {
+	WalletIDs []string  // Wallets to rebuild, empty for all
+	BatchSize int       // Wallets loaded per query when rebuilding all (default 100)
+	AsOf      time.Time // Rebuild state at this time, zero for now
+	Apply     bool      // Swap rebuilt state into the wallets table; current rebuilds only
+}
+
+// ProjectionReport summarises a projection rebuild
+type ProjectionReport struct {
+	StartedAt      time.Time              `json:"startedAt"`      // When the run began
+	FinishedAt     time.Time              `json:"finishedAt"`     // When the run ended
+	AsOf           time.Time              `json:"asOf"`           // Point in time rebuilt, zero for now
+	WalletsRebuilt int                    `json:"walletsRebuilt"` // Wallets projected
+	WalletsSkipped []string               `json:"walletsSkipped"` // Wallets that kept changing during the rebuild
+	Diffs          []types.ProjectionDiff `json:"diffs"`          // Fields where the table differs from history
+	Applied        []string               `json:"applied"`        // Wallets overwritten in apply mode
+}
+
+// updateStatus saves a wallet whose frozen or closed state changed, recording
+// the change for replay and announcing it, in one transaction
+func (r *WalletRepository) updateStatus(
+	ctx context.Context,
+	wallet *types.Wallet,
+	kind types.WalletStatusKind,
+	reason, initiatedBy string,
+) (*types.Wallet, error) {
+	change := types.NewWalletStatusChange(wallet, kind, reason, initiatedBy)
+
+	var updated *types.Wallet
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		repo := r.NewWithTx(tx)
+
+		var err error
+		if updated, err = repo.UpdateWallet(ctx, wallet); err != nil {
+			return err
+		}
+		if _, err := tx.NewInsert().Model(change).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to record status change: %w", err)
+		}
+		return repo.emit(ctx, statusEvents[kind], &types.WalletEvent{Wallet: updated, Reason: change.Reason})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// ListWalletStatusChanges returns a wallet's status changes, oldest first
+func (r *WalletRepository) ListWalletStatusChanges(ctx context.Context, walletID string) ([]*types.WalletStatusChange, error) {
+	var changes []*types.WalletStatusChange
+
+	err := r.db.NewSelect().
+		Model(&changes).
+		Where("wallet_id = ?", walletID).
+		OrderExpr("created_at ASC, id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list status changes: %w", err)
+	}
+
+	return changes, nil
+}
+
+// ProjectWallet rebuilds a wallet's balances and status at asOf from its
+// transactions, lien records and status changes alone. A zero asOf rebuilds
+// current state.
+func (r *WalletRepository) ProjectWallet(ctx context.Context, walletID string, asOf time.Time) (*types.WalletProjection, error) {
+	var transactions []*types.TransactionHistory
+
+	query := r.db.NewSelect().
+		Model(&transactions).
+		Where("wallet_id = ?", walletID).
+		Where("status != ?", types.StatusFailed).
+		OrderExpr("created_at ASC, id ASC")
+	if !asOf.IsZero() {
+		query = query.Where("created_at <= ?", asOf)
+	}
+	if err := query.Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load transactions: %w", err)
+	}
+
+	liens, err := r.ListLiens(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := r.ListWalletStatusChanges(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	return types.ProjectWallet(walletID, transactions, liens, changes, asOf), nil
+}
+
+// RebuildProjections rebuilds wallets from history and reports where the
+// wallets table differs from it. Frozen and closed flags are only rebuilt for
+// wallets with status history (see types.WalletProjection.Diff).
+//
+// In apply mode every differing wallet is overwritten with its rebuilt state
+// in a single transaction. Each wallet must still be at the version that was
+// rebuilt; if any has moved on, nothing is written and ErrConcurrentModification
+// is returned so the run can be repeated.
+//
+// Parameters:
+//   - ctx: Context for cancellation
+//   - opts: Wallets, point in time and whether to apply
+//
+// Returns:
+//   - *ProjectionReport with the differences found and wallets applied
+//   - error if a query fails, the swap conflicts, or a past rebuild is applied
+func (r *WalletRepository) RebuildProjections(ctx context.Context, opts ProjectionOptions) (*ProjectionReport, error) {
+	if opts.Apply && !opts.AsOf.IsZero() {
+		return nil, ErrProjectionNotCurrent
+	}
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 100
+	}
+
+	report := &ProjectionReport{StartedAt: time.Now().UTC(), AsOf: opts.AsOf}
+	var rebuilt []rebuiltWallet
+
+	visit := func(wallet *types.Wallet) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		wallet, projection, err := r.projectStable(ctx, wallet, opts.AsOf)
+		if err != nil {
+			return err
+		}
+		if projection == nil {
+			report.WalletsSkipped = append(report.WalletsSkipped, wallet.ID)
+			return nil
+		}
+		report.WalletsRebuilt++
+
+		diffs := projection.Diff(wallet)
+		if len(diffs) > 0 {
+			report.Diffs = append(report.Diffs, diffs...)
+			rebuilt = append(rebuilt, rebuiltWallet{wallet, projection})
+		}
+		return nil
+	}
+
+	if len(opts.WalletIDs) > 0 {
+		for _, id := range opts.WalletIDs {
+			wallet, err := r.FindWalletByID(ctx, id)
+			if err != nil {
+				return report, err
+			}
+			if err := visit(wallet); err != nil {
+				return report, err
+			}
+		}
+	} else {
+		var lastID string
+		for {
+			query := r.db.NewSelect().
+				Model((*types.Wallet)(nil)).
+				Order("id ASC").
+				Limit(opts.BatchSize)
+			if lastID != "" {
+				query = query.Where("id > ?", lastID)
+			}
+
+			var wallets []*types.Wallet
+			if err := query.Scan(ctx, &wallets); err != nil {
+				return report, fmt.Errorf("failed to load wallets: %w", err)
+			}
+			for _, wallet := range wallets {
+				if err := visit(wallet); err != nil {
+					return report, err
+				}
+				lastID = wallet.ID
+			}
+			if len(wallets) < opts.BatchSize {
+				break
+			}
+		}
+	}
+
+	if opts.Apply && len(rebuilt) > 0 {
+		if err := r.applyProjections(ctx, rebuilt, report); err != nil {
+			return report, err
+		}
+	}
+
+	report.FinishedAt = time.Now().UTC()
+	return report, nil
+}
+
+// rebuiltWallet pairs a wallet as read with its rebuilt state
+type rebuiltWallet struct {
+	wallet     *types.Wallet
+	projection *types.WalletProjection
+}
+
+// projectStable rebuilds a wallet, re-reading it if it changes while its
+// history is loaded. The projection is nil if the wallet keeps changing.
+func (r *WalletRepository) projectStable(
+	ctx context.Context,
+	wallet *types.Wallet,
+	asOf time.Time,
+) (*types.Wallet, *types.WalletProjection, error) {
+	for attempt := 0; attempt < integrityReplayAttempts; attempt++ {
+		projection, err := r.ProjectWallet(ctx, wallet.ID, asOf)
+		if err != nil {
+			return wallet, nil, err
+		}
+
+		current, err := r.FindWalletByID(ctx, wallet.ID)
+		if err != nil {
+			return wallet, nil, err
+		}
+		if current.VersionId != wallet.VersionId {
+			wallet = current
+			continue
+		}
+		return wallet, projection, nil
+	}
+
+	return wallet, nil, nil
+}
+
+// applyProjections overwrites the rebuilt wallets in one transaction, failing
+// if any of them changed since it was rebuilt
+func (r *WalletRepository) applyProjections(
+	ctx context.Context,
+	rebuilt []rebuiltWallet,
+	report *ProjectionReport,
+) error {
+	var applied []string
+
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		repo := r.NewWithTx(tx)
+		applied = applied[:0]
+
+		for _, item := range rebuilt {
+			item.projection.Apply(item.wallet)
+			if _, err := repo.UpdateWallet(ctx, item.wallet); err != nil {
+				return fmt.Errorf("failed to apply projection to %s: %w", item.wallet.ID, err)
+			}
+			applied = append(applied, item.wallet.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	report.Applied = applied
+	return nil
+}
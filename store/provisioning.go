@@ -0,0 +1,238 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/uptrace/bun"
+)
+
+// ErrNoEnabledCurrencies is returned when provisioning from a catalogue with no
+// enabled currencies
+var ErrNoEnabledCurrencies = errors.New("no enabled currencies to provision")
+
+// ProvisionResult lists a customer's wallets after provisioning
+type ProvisionResult struct {
+	CustomerID string          `json:"customerId"`
+	Wallets    []*types.Wallet `json:"wallets"` // One per currency, in the order requested
+	Created    []string        `json:"created"` // Currencies whose wallet this call created
+}
+
+// ProvisionWallets creates a customer's wallets in several currencies in one
+// database transaction. Wallets the customer already has are returned as
+// they are, so calling it again is safe.
+//
+// Parameters:
+//   - customerID: The owner of the wallets
+//   - currencyCodes: The currencies to provision; when empty, every enabled
+//     currency in the catalogue
+//
+// Returns:
+//   - The customer's wallet in each currency, and which ones were created
+//   - ErrNoEnabledCurrencies if no currencies were given and none are enabled
+func (r *WalletRepository) ProvisionWallets(ctx context.Context, customerID string, currencyCodes []string) (*ProvisionResult, error) {
+	customerID = strings.TrimSpace(customerID)
+	if customerID == "" {
+		return nil, ErrCustomerIDRequired
+	}
+
+	codes, err := r.provisionCurrencies(ctx, currencyCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]*types.Wallet, 0, len(codes))
+	for _, code := range codes {
+		wallet, err := types.NewWallet(customerID, code)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, wallet)
+	}
+
+	var wallets []*types.Wallet
+	err = r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := r.NewWithTx(tx).insertWallets(ctx, candidates); err != nil {
+			return err
+		}
+
+		return tx.NewSelect().
+			Model(&wallets).
+			Where("customer_id = ?", customerID).
+			Where("currency_code IN (?)", bun.In(codes)).
+			Scan(ctx)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to provision wallets: %w", err)
+	}
+
+	byCurrency := make(map[string]*types.Wallet, len(wallets))
+	for _, wallet := range wallets {
+		byCurrency[wallet.CurrencyCode] = wallet
+	}
+	result := &ProvisionResult{CustomerID: customerID}
+	for _, code := range codes {
+		result.Wallets = append(result.Wallets, byCurrency[code])
+	}
+
+	// Screen the returned wallets, so a freeze on a hit shows in the result
+	var created []*types.Wallet
+	for _, wallet := range result.Wallets {
+		for _, candidate := range candidates {
+			if wallet.ID == candidate.ID {
+				result.Created = append(result.Created, wallet.CurrencyCode)
+				created = append(created, wallet)
+			}
+		}
+	}
+
+	if err := r.screenCreated(ctx, created); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// ListCustomersWithoutWallet returns up to limit customers, in ID order, who
+// have a wallet in some currency but none in currencyCode
+func (r *WalletRepository) ListCustomersWithoutWallet(ctx context.Context, currencyCode string, limit int) ([]string, error) {
+	var customerIDs []string
+
+	holders := r.db.NewSelect().
+		Model((*types.Wallet)(nil)).
+		Column("customer_id").
+		Where("currency_code = ?", strings.ToUpper(currencyCode))
+
+	err := r.db.NewSelect().
+		Model((*types.Wallet)(nil)).
+		ColumnExpr("DISTINCT customer_id").
+		Where("customer_id NOT IN (?)", holders).
+		OrderExpr("customer_id ASC").
+		Limit(limit).
+		Scan(ctx, &customerIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list customers without a %s wallet: %w", currencyCode, err)
+	}
+
+	return customerIDs, nil
+}
+
+// BackfillWallets creates a wallet in currencyCode for each customer in one
+// database transaction and returns the wallets it created. Customers who
+// already have one are skipped.
+func (r *WalletRepository) BackfillWallets(ctx context.Context, currencyCode string, customerIDs []string) ([]*types.Wallet, error) {
+	candidates := make([]*types.Wallet, 0, len(customerIDs))
+	for _, customerID := range customerIDs {
+		wallet, err := types.NewWallet(customerID, currencyCode)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, wallet)
+	}
+
+	var created []*types.Wallet
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) (err error) {
+		created, err = r.NewWithTx(tx).insertWallets(ctx, candidates)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to backfill %s wallets: %w", currencyCode, err)
+	}
+
+	if err := r.screenCreated(ctx, created); err != nil {
+		return created, err
+	}
+
+	return created, nil
+}
+
+// provisionCurrencies normalizes and de-duplicates the requested currency
+// codes, falling back to the catalogue's enabled currencies
+func (r *WalletRepository) provisionCurrencies(ctx context.Context, currencyCodes []string) ([]string, error) {
+	if len(currencyCodes) == 0 {
+		currencies, err := r.ListCurrencies(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, currency := range currencies {
+			if !currency.Disabled {
+				currencyCodes = append(currencyCodes, currency.Code)
+			}
+		}
+		if len(currencyCodes) == 0 {
+			return nil, ErrNoEnabledCurrencies
+		}
+	}
+
+	seen := make(map[string]bool, len(currencyCodes))
+	codes := make([]string, 0, len(currencyCodes))
+	for _, code := range currencyCodes {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code == "" {
+			return nil, ErrInvalidCurrencyCode
+		}
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	return codes, nil
+}
+
+// insertWallets inserts the candidate wallets in one statement, skipping any
+// whose customer already has a wallet in that currency, and announces the
+// ones it created. Call it on a repository bound to a transaction.
+func (r *WalletRepository) insertWallets(ctx context.Context, candidates []*types.Wallet) ([]*types.Wallet, error) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	// Candidate IDs are new, so the ones stored afterwards are the ones inserted
+	ids := make([]string, 0, len(candidates))
+	for _, wallet := range candidates {
+		ids = append(ids, wallet.ID)
+	}
+
+	// Without RETURNING, bun does not scan the inserted rows back into the
+	// slice, which it would shorten when some rows are skipped
+	_, err := r.db.NewInsert().
+		Model(&candidates).
+		On("CONFLICT (customer_id, currency_code) DO NOTHING").
+		Returning("NULL").
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var created []*types.Wallet
+	err = r.db.NewSelect().
+		Model(&created).
+		Where("id IN (?)", bun.In(ids)).
+		OrderExpr("customer_id ASC, currency_code ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, wallet := range created {
+		if err := r.emit(ctx, types.EventWalletCreated, &types.WalletEvent{Wallet: wallet}); err != nil {
+			return nil, err
+		}
+	}
+	return created, nil
+}
+
+// screenCreated screens the owners of newly created wallets, as CreateWallet
+// does for a single wallet
+func (r *WalletRepository) screenCreated(ctx context.Context, wallets []*types.Wallet) error {
+	var errs []error
+	for _, wallet := range wallets {
+		if _, err := r.screenCustomer(ctx, wallet, types.ScreeningOnWalletCreation); err != nil {
+			errs = append(errs, fmt.Errorf("wallet %s: %w", wallet.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
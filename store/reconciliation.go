@@ -0,0 +1,174 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/uptrace/bun"
+)
+
+// reconciliationPageSize is the number of ledger rows loaded per query
+const reconciliationPageSize = 1000
+
+// ReconcileSettlement matches parsed settlement records against completed
+// ledger transactions and persists the run with all of its items.
+// Ledger rows are loaded for the settlement period widened by the date
+// tolerance and restricted to the currencies present in the file. Ledgers
+// that book several processors' rows under "<source>:<reference>" set
+// ReferencePrefix to types.SourceReferencePrefix(source), so rows settled by
+// other processors are never reported as missing from this file; without it
+// every referenced row in the period takes part.
+func (r *WalletRepository) ReconcileSettlement(
+	ctx context.Context,
+	source string,
+	records []types.SettlementRecord,
+	opts types.ReconciliationOptions,
+) (*types.ReconciliationRun, error) {
+	if opts.PeriodStart.IsZero() || opts.PeriodEnd.IsZero() {
+		start, end := types.SettlementPeriod(records)
+		if opts.PeriodStart.IsZero() {
+			opts.PeriodStart = start
+		}
+		if opts.PeriodEnd.IsZero() {
+			opts.PeriodEnd = end
+		}
+	}
+
+	currencies := make(map[string]bool)
+	for _, rec := range records {
+		currencies[strings.ToUpper(rec.CurrencyCode)] = true
+	}
+	codes := make([]string, 0, len(currencies))
+	for code := range currencies {
+		codes = append(codes, code)
+	}
+
+	var transactions []*types.TransactionHistory
+	if len(codes) > 0 {
+		var err error
+		transactions, err = r.listSettlementCandidates(ctx, opts.ReferencePrefix, codes,
+			opts.PeriodStart.Add(-opts.DateTolerance), opts.PeriodEnd.Add(opts.DateTolerance))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	run, err := types.Reconcile(source, records, transactions, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(run).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to save reconciliation run: %w", err)
+		}
+		if len(run.Items) > 0 {
+			if _, err := tx.NewInsert().Model(&run.Items).Exec(ctx); err != nil {
+				return fmt.Errorf("failed to save reconciliation items: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return run, nil
+}
+
+// FindReconciliationRun retrieves a reconciliation run with its items
+func (r *WalletRepository) FindReconciliationRun(ctx context.Context, id string) (*types.ReconciliationRun, error) {
+	run := &types.ReconciliationRun{ID: id}
+
+	err := r.db.NewSelect().
+		Model(run).
+		Relation("Items", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.OrderExpr("source_line ASC, id ASC")
+		}).
+		WherePK().
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.ErrReconciliationNotFound
+		}
+		return nil, err
+	}
+
+	return run, nil
+}
+
+// ListReconciliationExceptions retrieves the unmatched items of a run
+func (r *WalletRepository) ListReconciliationExceptions(ctx context.Context, runID string) ([]*types.ReconciliationItem, error) {
+	var items []*types.ReconciliationItem
+
+	err := r.db.NewSelect().
+		Model(&items).
+		Where("run_id = ?", runID).
+		Where("status != ?", types.ReconMatched).
+		OrderExpr("status ASC, source_line ASC, id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reconciliation exceptions: %w", err)
+	}
+
+	return items, nil
+}
+
+// listSettlementCandidates loads completed transactions with an external
+// reference, starting with prefix when one is given, in the given currencies
+// and created within [from, to]
+func (r *WalletRepository) listSettlementCandidates(
+	ctx context.Context,
+	prefix string,
+	currencies []string,
+	from, to time.Time,
+) ([]*types.TransactionHistory, error) {
+	var (
+		all        []*types.TransactionHistory
+		cursorTime time.Time
+		cursorID   string
+	)
+
+	for {
+		query := r.db.NewSelect().
+			Model((*types.TransactionHistory)(nil)).
+			Where("status = ?", types.StatusCompleted).
+			Where("external_reference LIKE ? ESCAPE '\\'", escapeLike(prefix)+"_%").
+			Where("currency_code IN (?)", bun.In(currencies)).
+			Where("created_at >= ?", from).
+			Where("created_at <= ?", to).
+			OrderExpr("created_at ASC, id ASC").
+			Limit(reconciliationPageSize)
+
+		// Keyset pagination on (created_at, id)
+		if cursorID != "" {
+			query = query.Where("(created_at > ? OR (created_at = ? AND id > ?))", cursorTime, cursorTime, cursorID)
+		}
+
+		var page []*types.TransactionHistory
+		if err := query.Scan(ctx, &page); err != nil {
+			return nil, fmt.Errorf("failed to load ledger transactions: %w", err)
+		}
+		all = append(all, page...)
+
+		if len(page) < reconciliationPageSize {
+			return all, nil
+		}
+
+		last := page[len(page)-1]
+		cursorTime, cursorID = last.CreatedAt, last.ID
+	}
+}
+
+// likeEscaper escapes the LIKE wildcards and the escape character itself
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike quotes s for use as a literal in a LIKE pattern with ESCAPE '\'
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
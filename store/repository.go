@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/uptrace/bun"
+)
+
+// WalletStore persists wallets with optimistic versioning
+type WalletStore interface {
+	// CreateWallet inserts a wallet, or returns the customer's existing wallet
+	// in that currency
+	CreateWallet(ctx context.Context, wallet *types.Wallet) (*types.Wallet, error)
+	// UpdateWallet saves a wallet if its VersionId is still current, giving it
+	// a new one; otherwise it returns ErrConcurrentModification
+	UpdateWallet(ctx context.Context, wallet *types.Wallet) (*types.Wallet, error)
+	// FindWalletByID returns ErrWalletNotFound for an unknown ID
+	FindWalletByID(ctx context.Context, walletID string) (*types.Wallet, error)
+	// FindWalletByCurrency returns ErrWalletNotFound if the customer has no
+	// wallet in the currency
+	FindWalletByCurrency(ctx context.Context, customerID, currencyCode string) (*types.Wallet, error)
+}
+
+// TransactionStore persists transaction history rows and their hash chain
+type TransactionStore interface {
+	// CreateTransaction validates the row, stamps its times and links it
+	// into its wallet's hash chain
+	CreateTransaction(ctx context.Context, tx *types.TransactionHistory) (*types.TransactionHistory, error)
+	// UpdateTransaction moves a row to a new status; completed rows are final
+	UpdateTransaction(ctx context.Context, tx *types.TransactionHistory) (*types.TransactionHistory, error)
+	// FindTransactionByID returns ErrTransactionNotFound for an unknown ID
+	FindTransactionByID(ctx context.Context, id string) (*types.TransactionHistory, error)
+	// ListTransactions returns a filtered, cursor-paginated page of rows
+	ListTransactions(ctx context.Context, params ListTransactionsParams) (*ListTransactionsResult, error)
+}
+
+// LienStore persists lien placements and releases
+type LienStore interface {
+	// CreateLien records a lien placement
+	CreateLien(ctx context.Context, lien *types.LienRecord) error
+	// RecordLienRelease marks the lien released and records the release,
+	// returning types.ErrLienNotFound if the wallet has no such lien
+	RecordLienRelease(ctx context.Context, release *types.LienRecord) error
+	// ListLiens returns a wallet's placements and releases, oldest first
+	ListLiens(ctx context.Context, walletID string) ([]*types.LienRecord, error)
+}
+
+// CurrencyStore persists supported currencies
+type CurrencyStore interface {
+	// CreateCurrency returns ErrCurrencyExists if the code is taken
+	CreateCurrency(ctx context.Context, currency *types.CurrencyInfo) (*types.CurrencyInfo, error)
+	// UpdateCurrency returns types.ErrCurrencyNotFound for an unknown code
+	UpdateCurrency(ctx context.Context, currency *types.CurrencyInfo) (*types.CurrencyInfo, error)
+	// FindCurrency returns types.ErrCurrencyNotFound for an unknown code
+	FindCurrency(ctx context.Context, code string) (*types.CurrencyInfo, error)
+	// ListCurrencies returns all currencies ordered by code
+	ListCurrencies(ctx context.Context) ([]*types.CurrencyInfo, error)
+}
+
+// UnitOfWork runs work atomically across repositories
+type UnitOfWork interface {
+	// RunInTx calls fn with a repository bound to one transaction. Everything
+	// fn wrote is kept if it returns nil and discarded otherwise. Calls nest:
+	// an inner failure discards only the inner work.
+	RunInTx(ctx context.Context, fn func(ctx context.Context, repo Repository) error) error
+}
+
+// Repository is the storage a wallet service needs. WalletRepository
+// implements it over bun and MemoryStore in memory.
+type Repository interface {
+	WalletStore
+	TransactionStore
+	LienStore
+	CurrencyStore
+	UnitOfWork
+}
+
+var (
+	_ Repository = (*WalletRepository)(nil)
+	_ Repository = (*MemoryStore)(nil)
+)
+
+// RunInTx runs fn against a copy of the repository bound to a database
+// transaction, or a savepoint when the repository is already in one. A
+// screening hit that blocked a movement in fn is recorded after the rollback.
+func (r *WalletRepository) RunInTx(ctx context.Context, fn func(ctx context.Context, repo Repository) error) error {
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return fn(ctx, r.NewWithTx(tx))
+	})
+	if blockErr := r.screeningBlocked(ctx, err); blockErr != nil {
+		return errors.Join(err, blockErr)
+	}
+	return err
+}
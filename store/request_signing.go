@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/uptrace/bun"
+)
+
+// EnforceRequestSignatures makes DebitWallet, TransferFunds and SwapFunds
+// reject requests without a valid signature. Pass nil to turn enforcement off.
+func (r *WalletRepository) EnforceRequestSignatures(verifier *types.RequestVerifier) {
+	r.verifier = verifier
+}
+
+// verifiedKey marks a context executing part of a request whose signature
+// has already been checked
+type verifiedKey struct{}
+
+// withVerifiedRequest marks ctx as executing part of a verified request, such
+// as one item of a signed payout batch
+func withVerifiedRequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, verifiedKey{}, true)
+}
+
+// isVerified reports whether ctx is executing part of a verified request
+func isVerified(ctx context.Context) bool {
+	verified, _ := ctx.Value(verifiedKey{}).(bool)
+	return verified
+}
+
+// verifyRequest checks a request signature when enforcement is on
+func (r *WalletRepository) verifyRequest(ctx context.Context, initiatorID string, sig *types.RequestSignature, payload func(*types.RequestSignature) []byte) error {
+	// Approved requests were verified when they were submitted
+	if r.verifier == nil || isApproved(ctx) || isVerified(ctx) {
+		return nil
+	}
+	if sig == nil {
+		return types.ErrMissingSignature
+	}
+	return r.verifier.Verify(ctx, initiatorID, sig, payload(sig))
+}
+
+// RegisterInitiatorKey stores a verification key for an initiator
+func (r *WalletRepository) RegisterInitiatorKey(ctx context.Context, key *types.InitiatorKey) (*types.InitiatorKey, error) {
+	if key == nil || key.ID == "" || key.InitiatorID == "" {
+		return nil, errors.New("key ID and initiator ID are required")
+	}
+	switch key.Algorithm {
+	case types.SignatureHMACSHA256, types.SignatureEd25519:
+	default:
+		return nil, fmt.Errorf("unsupported signature algorithm: %s", key.Algorithm)
+	}
+
+	now := time.Now().UTC()
+	if key.ValidFrom.IsZero() {
+		key.ValidFrom = now
+	}
+	key.CreatedAt = now
+
+	_, err := r.db.NewInsert().
+		Model(key).
+		Exec(ctx)
+
+	return key, err
+}
+
+// RotateInitiatorKey registers next and retires the initiator's other active
+// keys after the overlap period, so clients can switch keys without downtime
+func (r *WalletRepository) RotateInitiatorKey(ctx context.Context, next *types.InitiatorKey, overlap time.Duration) (*types.InitiatorKey, error) {
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		repo := r.NewWithTx(tx)
+		if _, err := repo.RegisterInitiatorKey(ctx, next); err != nil {
+			return err
+		}
+
+		retireAt := time.Now().UTC().Add(overlap)
+		_, err := tx.NewUpdate().
+			Model((*types.InitiatorKey)(nil)).
+			Set("valid_until = ?", retireAt).
+			Where("initiator_id = ?", next.InitiatorID).
+			Where("id != ?", next.ID).
+			Where("(valid_until IS NULL OR valid_until > ?)", retireAt).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to retire previous keys: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return next, nil
+}
+
+// FindInitiatorKey implements types.InitiatorKeyStore
+func (r *WalletRepository) FindInitiatorKey(ctx context.Context, keyID string) (*types.InitiatorKey, error) {
+	key := &types.InitiatorKey{ID: keyID}
+
+	err := r.db.NewSelect().
+		Model(key).
+		WherePK().
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.ErrUnknownSigningKey
+		}
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// UseNonce implements types.NonceStore. The primary key on (key_id, nonce)
+// makes concurrent use of the same nonce fail for all but one caller.
+func (r *WalletRepository) UseNonce(ctx context.Context, keyID, nonce string, expiresAt time.Time) error {
+	res, err := r.db.NewInsert().
+		Model(&types.RequestNonce{KeyID: keyID, Nonce: nonce, ExpiresAt: expiresAt}).
+		On("CONFLICT DO NOTHING").
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to record nonce: %w", err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return types.ErrReplayedRequest
+	}
+	return nil
+}
+
+// PurgeExpiredNonces deletes nonces whose signatures can no longer pass the
+// timestamp check
+func (r *WalletRepository) PurgeExpiredNonces(ctx context.Context) (int64, error) {
+	res, err := r.db.NewDelete().
+		Model((*types.RequestNonce)(nil)).
+		Where("expires_at < ?", time.Now().UTC()).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
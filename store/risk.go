@@ -0,0 +1,311 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/uptrace/bun"
+)
+
+// UseRiskEngine screens every credit, debit, transfer and swap with engine
+// before it commits. Pass nil to turn screening off.
+func (r *WalletRepository) UseRiskEngine(engine *types.RiskEngine) {
+	r.risk = engine
+}
+
+// screenRisk evaluates each input against its wallet's recent history and
+// returns the decisions with the strictest outcome among them
+func (r *WalletRepository) screenRisk(ctx context.Context, inputs ...types.RiskInput) ([]*types.RiskDecision, types.RiskOutcome, error) {
+	if r.risk == nil {
+		return nil, types.RiskAllow, nil
+	}
+
+	now := time.Now().UTC()
+	decisions := make([]*types.RiskDecision, 0, len(inputs))
+	for _, in := range inputs {
+		var recent []*types.TransactionHistory
+		err := r.db.NewSelect().
+			Model(&recent).
+			Where("wallet_id = ?", in.Wallet.ID).
+			Where("created_at >= ?", now.Add(-r.risk.Lookback)).
+			Where("status != ?", types.StatusFailed).
+			OrderExpr("created_at ASC, id ASC").
+			Scan(ctx)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to load recent transactions: %w", err)
+		}
+
+		decisions = append(decisions, r.risk.Evaluate(in, recent, now))
+	}
+
+	outcome, _ := types.WorstRiskOutcome(decisions)
+	return decisions, outcome, nil
+}
+
+// recordRiskDecisions saves decisions, linking them to the rows the movement wrote
+func (r *WalletRepository) recordRiskDecisions(ctx context.Context, decisions []*types.RiskDecision, transactionIDs ...string) error {
+	if len(decisions) == 0 {
+		return nil
+	}
+	movementID := types.GenerateID("mvt_", 15)
+	for _, d := range decisions {
+		d.MovementID = movementID
+		d.TransactionIDs = transactionIDs
+	}
+
+	if _, err := r.db.NewInsert().Model(&decisions).Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record risk decisions: %w", err)
+	}
+	return nil
+}
+
+// riskBlocked records decisions of a blocked movement outside its (rolled back)
+// DB transaction and returns the blocking error
+func (r *WalletRepository) riskBlocked(ctx context.Context, decisions []*types.RiskDecision) error {
+	_, worst := types.WorstRiskOutcome(decisions)
+	if err := r.recordRiskDecisions(ctx, decisions); err != nil {
+		return errors.Join(worst.Err(), err)
+	}
+	return worst.Err()
+}
+
+// FindRiskDecision retrieves a risk decision by ID
+func (r *WalletRepository) FindRiskDecision(ctx context.Context, id string) (*types.RiskDecision, error) {
+	decision := &types.RiskDecision{ID: id}
+
+	err := r.db.NewSelect().
+		Model(decision).
+		WherePK().
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.ErrRiskReviewNotFound
+		}
+		return nil, err
+	}
+
+	return decision, nil
+}
+
+// ListRiskDecisions returns a wallet's screening history, newest first
+func (r *WalletRepository) ListRiskDecisions(ctx context.Context, walletID string) ([]*types.RiskDecision, error) {
+	var decisions []*types.RiskDecision
+
+	err := r.db.NewSelect().
+		Model(&decisions).
+		Where("wallet_id = ?", walletID).
+		OrderExpr("created_at DESC, id DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return decisions, nil
+}
+
+// ListOpenRiskReviews returns held movements waiting for a reviewer, oldest first
+func (r *WalletRepository) ListOpenRiskReviews(ctx context.Context) ([]*types.RiskDecision, error) {
+	var decisions []*types.RiskDecision
+
+	err := r.db.NewSelect().
+		Model(&decisions).
+		Where("review_status = ?", types.RiskReviewOpen).
+		OrderExpr("created_at ASC, id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return decisions, nil
+}
+
+// holdForReview keeps a credit held for review out of the available balance
+// until the review is resolved. Held debits need no hold, as their funds have
+// already left the available balance.
+func (r *WalletRepository) holdForReview(ctx context.Context, wallet *types.Wallet, row *types.TransactionHistory) error {
+	if row.Status != types.StatusPending || row.Type != types.TypeCredit {
+		return nil
+	}
+
+	hold := wallet.HoldForReview(row)
+	if _, err := r.UpdateWallet(ctx, wallet); err != nil {
+		return fmt.Errorf("failed to update wallet balance: %w", err)
+	}
+	if err := r.CreateLien(ctx, hold); err != nil {
+		return fmt.Errorf("failed to record review hold: %w", err)
+	}
+	return nil
+}
+
+// ResolveRiskReview closes a held movement. Releasing completes its pending
+// rows and frees held credits; rejecting frees them too, then undoes each
+// row's balance effect with a reversal row and marks it reversed. Every
+// decision of the movement is resolved together.
+//
+// The reviewer is the caller stored in ctx (see types.WithPrincipal), who must
+// be allowed types.ActionReviewRisk on the screened wallet.
+func (r *WalletRepository) ResolveRiskReview(ctx context.Context, decisionID string, release bool) (*types.RiskDecision, error) {
+	reviewer, ok := types.PrincipalFromContext(ctx)
+	if !ok || reviewer.ID == "" {
+		return nil, fmt.Errorf("%w: risk reviews need an authenticated reviewer", types.ErrUnauthorizedAccess)
+	}
+
+	decision, err := r.FindRiskDecision(ctx, decisionID)
+	if err != nil {
+		return nil, err
+	}
+	wallet, err := r.FindWalletByID(ctx, decision.WalletID)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.authorize(ctx, types.ActionReviewRisk, "", wallet); err != nil {
+		return nil, err
+	}
+	if decision.ReviewStatus != types.RiskReviewOpen {
+		return nil, fmt.Errorf("%w: review is %q", types.ErrInvalidStatusTransition, decision.ReviewStatus)
+	}
+
+	status := types.RiskReviewRejected
+	if release {
+		status = types.RiskReviewReleased
+	}
+
+	err = r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		repo := r.NewWithTx(tx)
+
+		for _, id := range decision.TransactionIDs {
+			row, err := repo.FindTransactionByID(ctx, id)
+			if err != nil {
+				return err
+			}
+			if release {
+				err = repo.releaseHeldTransaction(ctx, row)
+			} else {
+				err = repo.reverseHeldTransaction(ctx, row, reviewer.ID)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to resolve transaction %s: %w", id, err)
+			}
+			if _, err := repo.UpdateTransaction(ctx, row); err != nil {
+				return err
+			}
+
+			eventType := types.EventTransactionReleased
+			if !release {
+				eventType = types.EventTransactionReversed
+			}
+			if err := repo.emitForTransaction(ctx, eventType, row); err != nil {
+				return err
+			}
+		}
+
+		// Resolve the other side of a transfer or swap along with this one
+		res, err := tx.NewUpdate().
+			Model((*types.RiskDecision)(nil)).
+			Set("review_status = ?", status).
+			Set("reviewer_id = ?", reviewer.ID).
+			Set("reviewed_at = ?", time.Now().UTC()).
+			Where("movement_id = ?", decision.MovementID).
+			Where("review_status = ?", types.RiskReviewOpen).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to update risk decision: %w", err)
+		}
+		if rows, _ := res.RowsAffected(); rows == 0 {
+			return fmt.Errorf("%w: review was resolved concurrently", types.ErrInvalidStatusTransition)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.FindRiskDecision(ctx, decisionID)
+}
+
+// releaseHeldTransaction completes a pending row and frees its held credit
+func (r *WalletRepository) releaseHeldTransaction(ctx context.Context, row *types.TransactionHistory) error {
+	if err := row.MarkAsCompleted(); err != nil {
+		return err
+	}
+	_, err := r.releaseReviewHold(ctx, row)
+	return err
+}
+
+// reverseHeldTransaction marks a pending row reversed and records a reversal
+// row undoing its balance effect, after freeing its held credit
+func (r *WalletRepository) reverseHeldTransaction(ctx context.Context, row *types.TransactionHistory, reviewerID string) error {
+	if err := row.MarkAsReversed(); err != nil {
+		return err
+	}
+
+	wallet, err := r.releaseReviewHold(ctx, row)
+	if err != nil {
+		return err
+	}
+	if wallet == nil {
+		if wallet, err = r.FindWalletByID(ctx, row.WalletID); err != nil {
+			return err
+		}
+	}
+
+	reversal := types.NewReversalTransaction(wallet, row, reviewerID)
+	if reversal.BalanceAfter.IsNegative() {
+		return fmt.Errorf("%w: held funds were already spent", types.ErrInsufficientFunds)
+	}
+	wallet.AvailableBalance = reversal.BalanceAfter
+
+	if _, err := r.UpdateWallet(ctx, wallet); err != nil {
+		return err
+	}
+	if _, err := r.CreateTransaction(ctx, reversal); err != nil {
+		return fmt.Errorf("failed to record reversal: %w", err)
+	}
+	return nil
+}
+
+// releaseReviewHold returns a held credit's amount to the available balance.
+// It returns the updated wallet, or nil when the row has no hold.
+func (r *WalletRepository) releaseReviewHold(ctx context.Context, row *types.TransactionHistory) (*types.Wallet, error) {
+	hold := new(types.LienRecord)
+	err := r.db.NewSelect().
+		Model(hold).
+		Where("wallet_id = ?", row.WalletID).
+		Where("external_transaction_id = ?", row.ID).
+		Where("description = ?", types.ReviewHoldDescription).
+		Where("lien_id IS NULL").
+		Where("released_at IS NULL").
+		Limit(1).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load review hold: %w", err)
+	}
+
+	wallet, err := r.FindWalletByID(ctx, row.WalletID)
+	if err != nil {
+		return nil, err
+	}
+	release, err := wallet.ReleaseLien(types.LienOrUnlienRequest{
+		ID:                    hold.ID,
+		Amount:                hold.Amount,
+		Description:           hold.Description,
+		ExternalTransactionID: hold.ExternalTransactionID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.UpdateWallet(ctx, wallet); err != nil {
+		return nil, err
+	}
+	if err := r.RecordLienRelease(ctx, release); err != nil {
+		return nil, fmt.Errorf("failed to release review hold: %w", err)
+	}
+	return wallet, nil
+}
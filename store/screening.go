@@ -0,0 +1,329 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/uptrace/bun"
+)
+
+// UseScreening screens customers against watch lists when their wallets are
+// created and when they send or receive transfers. A hit freezes the wallet and
+// opens a case for compliance. Pass a nil screener to turn screening off.
+func (r *WalletRepository) UseScreening(screener types.NameScreener, directory types.CustomerDirectory) {
+	r.screener = screener
+	r.directory = directory
+}
+
+// screenCustomer screens the wallet owner and, on a hit, freezes the wallet and
+// opens a case. It returns the case for the hit (an already open one for the
+// wallet, if any), or nil when the customer is clear.
+func (r *WalletRepository) screenCustomer(ctx context.Context, wallet *types.Wallet, trigger types.ScreeningTrigger) (*types.ScreeningCase, error) {
+	hit, found, err := r.findScreeningHit(ctx, wallet, trigger)
+	if err != nil || hit == nil || found {
+		return hit, err
+	}
+	if err := r.recordScreeningHit(ctx, wallet, hit); err != nil {
+		return nil, err
+	}
+	return hit, nil
+}
+
+// findScreeningHit screens the wallet owner without recording anything. It
+// returns the wallet's open case with found set, a new unrecorded case, or nil
+// when the customer is clear.
+func (r *WalletRepository) findScreeningHit(
+	ctx context.Context,
+	wallet *types.Wallet,
+	trigger types.ScreeningTrigger,
+) (hit *types.ScreeningCase, found bool, err error) {
+	if r.screener == nil || r.directory == nil {
+		return nil, false, nil
+	}
+
+	subject, err := r.directory.FindScreeningSubject(ctx, wallet.CustomerID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to resolve screening subject: %w", err)
+	}
+	if subject == nil || subject.Name == "" {
+		return nil, false, nil
+	}
+
+	matches := r.screener.ScreenName(subject.Name)
+	if len(matches) == 0 {
+		return nil, false, nil
+	}
+	var cleared []*types.ScreeningCase
+	err = r.db.NewSelect().
+		Model(&cleared).
+		Where("customer_id = ?", wallet.CustomerID).
+		Where("status = ?", types.ScreeningCaseCleared).
+		Scan(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to load cleared screening cases: %w", err)
+	}
+	if matches = types.ExcludeClearedMatches(matches, cleared); len(matches) == 0 {
+		return nil, false, nil
+	}
+
+	existing := new(types.ScreeningCase)
+	err = r.db.NewSelect().
+		Model(existing).
+		Where("wallet_id = ?", wallet.ID).
+		Where("status = ?", types.ScreeningCaseOpen).
+		Limit(1).
+		Scan(ctx)
+	if err == nil {
+		return existing, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	return types.NewScreeningCase(subject, wallet.ID, trigger, matches), false, nil
+}
+
+// recordScreeningHit freezes the wallet and opens the case for a hit
+func (r *WalletRepository) recordScreeningHit(ctx context.Context, wallet *types.Wallet, hit *types.ScreeningCase) error {
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		repo := r.NewWithTx(tx)
+
+		// Screening freezes regardless of the caller's role; closed wallets
+		// cannot be frozen but still get a case
+		if !wallet.IsFrozen() && !wallet.IsClosed {
+			err := wallet.Freeze(types.FreezeRequest{
+				Reason:      "sanctions screening hit, case " + hit.ID,
+				InitiatedBy: types.FreezeInitiatedByScreening,
+				FrozenAt:    time.Now().UTC(),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to freeze wallet: %w", err)
+			}
+			if _, err := repo.updateStatus(ctx, wallet, types.StatusChangeFreeze, "", ""); err != nil {
+				return fmt.Errorf("failed to freeze wallet: %w", err)
+			}
+		}
+
+		if _, err := tx.NewInsert().Model(hit).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to open screening case: %w", err)
+		}
+		return nil
+	})
+}
+
+// screeningHitError blocks a movement whose party hit a watch list. A hit found
+// inside a caller's DB transaction is recorded in it, but the blocked movement
+// usually rolls that transaction back; the transaction's owner then records it
+// again with screeningBlocked.
+type screeningHitError struct {
+	wallet    *types.Wallet
+	hit       *types.ScreeningCase
+	committed bool // Recorded outside any caller's transaction
+}
+
+func (e *screeningHitError) Error() string {
+	return fmt.Sprintf("%s: wallet %s, case %s", types.ErrScreeningHit, e.wallet.ID, e.hit.ID)
+}
+
+func (e *screeningHitError) Unwrap() error {
+	return types.ErrScreeningHit
+}
+
+// screenTransferParties screens the owners of both wallets of a transfer
+func (r *WalletRepository) screenTransferParties(ctx context.Context, walletIDs ...string) error {
+	if r.screener == nil {
+		return nil
+	}
+
+	_, inTx := r.db.(bun.Tx)
+	for _, id := range walletIDs {
+		wallet, err := r.FindWalletByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		hit, found, err := r.findScreeningHit(ctx, wallet, types.ScreeningOnTransfer)
+		if err != nil {
+			return err
+		}
+		if hit == nil {
+			continue
+		}
+		if !found {
+			if err := r.recordScreeningHit(ctx, wallet, hit); err != nil {
+				return err
+			}
+		}
+		return &screeningHitError{wallet: wallet, hit: hit, committed: found || !inTx}
+	}
+	return nil
+}
+
+// screeningBlocked records the hit that blocked a movement inside a DB
+// transaction which has since rolled back, so the freeze and case outlive it.
+// Repositories still inside a transaction leave it to the transaction's owner.
+func (r *WalletRepository) screeningBlocked(ctx context.Context, err error) error {
+	var blocked *screeningHitError
+	if !errors.As(err, &blocked) || blocked.committed {
+		return nil
+	}
+	if _, inTx := r.db.(bun.Tx); inTx {
+		return nil
+	}
+
+	// The wallet was read inside the rolled back transaction
+	wallet, err := r.FindWalletByID(ctx, blocked.wallet.ID)
+	if err != nil {
+		return err
+	}
+	if err := r.recordScreeningHit(ctx, wallet, blocked.hit); err != nil {
+		return fmt.Errorf("failed to record screening hit: %w", err)
+	}
+	blocked.committed = true
+	return nil
+}
+
+// FindScreeningCase retrieves a screening case by ID
+func (r *WalletRepository) FindScreeningCase(ctx context.Context, id string) (*types.ScreeningCase, error) {
+	hit := &types.ScreeningCase{ID: id}
+
+	err := r.db.NewSelect().
+		Model(hit).
+		WherePK().
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.ErrScreeningCaseNotFound
+		}
+		return nil, err
+	}
+
+	return hit, nil
+}
+
+// ListScreeningCases returns cases in the given status, oldest first. An empty
+// status lists every case; ScreeningCaseOpen gives the review queue.
+func (r *WalletRepository) ListScreeningCases(ctx context.Context, status types.ScreeningCaseStatus) ([]*types.ScreeningCase, error) {
+	var cases []*types.ScreeningCase
+
+	q := r.db.NewSelect().
+		Model(&cases).
+		OrderExpr("created_at ASC, id ASC")
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if err := q.Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	return cases, nil
+}
+
+// ClearScreeningCase closes a case as a false positive. The matched entries no
+// longer hit for this customer, and the wallet is unfrozen if screening froze
+// it and no other case is open for it. When the approval policy gates
+// unfreezes, the unfreeze is submitted for approval with the reviewer as maker
+// instead and the wallet stays frozen until a second person approves it.
+//
+// The reviewer is the caller stored in ctx (see types.WithPrincipal), who must
+// be allowed types.ActionUnfreeze on the wallet.
+func (r *WalletRepository) ClearScreeningCase(ctx context.Context, caseID, note string) (*types.ScreeningCase, error) {
+	return r.closeScreeningCase(ctx, caseID, note, types.ScreeningCaseCleared)
+}
+
+// ConfirmScreeningCase closes a case as a true match; the wallet stays frozen.
+// The reviewer is the caller stored in ctx, who must be allowed
+// types.ActionFreeze on the wallet.
+func (r *WalletRepository) ConfirmScreeningCase(ctx context.Context, caseID, note string) (*types.ScreeningCase, error) {
+	return r.closeScreeningCase(ctx, caseID, note, types.ScreeningCaseConfirmed)
+}
+
+func (r *WalletRepository) closeScreeningCase(
+	ctx context.Context,
+	caseID string,
+	note string,
+	status types.ScreeningCaseStatus,
+) (*types.ScreeningCase, error) {
+	reviewer, ok := types.PrincipalFromContext(ctx)
+	if !ok || reviewer.ID == "" {
+		return nil, fmt.Errorf("%w: screening reviews need an authenticated reviewer", types.ErrUnauthorizedAccess)
+	}
+
+	hit, err := r.FindScreeningCase(ctx, caseID)
+	if err != nil {
+		return nil, err
+	}
+	if hit.Status != types.ScreeningCaseOpen {
+		return nil, fmt.Errorf("%w: case is %s", types.ErrInvalidStatusTransition, hit.Status)
+	}
+
+	wallet, err := r.FindWalletByID(ctx, hit.WalletID)
+	if err != nil {
+		return nil, err
+	}
+	action := types.ActionFreeze
+	if status == types.ScreeningCaseCleared {
+		action = types.ActionUnfreeze
+	}
+	if err := r.authorize(ctx, action, "", wallet); err != nil {
+		return nil, err
+	}
+
+	hit.Status = status
+	hit.ReviewerID = reviewer.ID
+	hit.ReviewNote = note
+	hit.ReviewedAt = time.Now().UTC()
+
+	err = r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		repo := r.NewWithTx(tx)
+
+		res, err := tx.NewUpdate().
+			Model(hit).
+			WherePK().
+			Where("status = ?", types.ScreeningCaseOpen).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to close screening case: %w", err)
+		}
+		if rows, _ := res.RowsAffected(); rows == 0 {
+			return fmt.Errorf("%w: case was closed concurrently", types.ErrInvalidStatusTransition)
+		}
+
+		if status != types.ScreeningCaseCleared || wallet.FreezeInitiatedBy != types.FreezeInitiatedByScreening {
+			return nil
+		}
+		open, err := tx.NewSelect().
+			Model((*types.ScreeningCase)(nil)).
+			Where("wallet_id = ?", wallet.ID).
+			Where("status = ?", types.ScreeningCaseOpen).
+			Count(ctx)
+		if err != nil || open > 0 {
+			return err
+		}
+
+		// Lifting the freeze is subject to the same approval as any unfreeze
+		err = repo.requireApproval(ctx, types.ApprovalUnfreeze, "", wallet.CurrencyCode, decimal.Zero)
+		if errors.Is(err, types.ErrApprovalRequired) {
+			_, err = repo.SubmitApproval(ctx, types.ApprovalUnfreeze, wallet.ID, "", nil, "screening case "+hit.ID+" cleared: "+note)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := wallet.Unfreeze(); err != nil {
+			return err
+		}
+		_, err = repo.updateStatus(ctx, wallet, types.StatusChangeUnfreeze, note, reviewer.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return hit, nil
+}
@@ -0,0 +1,231 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/uptrace/bun"
+)
+
+// SplitFunds debits one wallet once and credits each share's wallet, writing
+// every row under one group ID in a single DB transaction. Shares are rounded
+// to the currency's catalogue precision, or types.DefaultSplitPrecision when
+// the currency is not in the catalogue.
+//
+// Approval rules for transfers apply to the split amount; a split that needs
+// approval is refused, as approved requests cannot carry one.
+//
+// Parameters:
+//   - sourceWalletID: Wallet debited
+//   - req: Split request details, one share per destination wallet
+//
+// Returns:
+//   - sourceTx: Debit record for the source wallet
+//   - destTxs: Credit records, in share order
+//   - error: Validation or processing error
+func (r *WalletRepository) SplitFunds(
+	ctx context.Context,
+	sourceWalletID string,
+	req types.SplitRequest,
+) (*types.TransactionHistory, []*types.TransactionHistory, error) {
+	// Validate basic request parameters
+	if len(req.Shares) == 0 {
+		return nil, nil, types.ErrNoSplitShares
+	}
+	if req.Amount.LessThanOrEqual(decimal.Zero) {
+		return nil, nil, types.ErrInvalidAmount
+	}
+	if req.Fee.LessThan(decimal.Zero) {
+		return nil, nil, types.ErrInvalidFee
+	}
+
+	// Authenticate the request when signatures are enforced
+	err := r.verifyRequest(ctx, req.InitiatorID, req.Signature, func(sig *types.RequestSignature) []byte {
+		return req.SigningPayload(sourceWalletID, sig)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Screen every customer; a hit freezes the wallet and blocks the split
+	parties := []string{sourceWalletID}
+	for _, share := range req.Shares {
+		parties = append(parties, share.DestinationWalletID)
+	}
+	if err := r.screenTransferParties(ctx, parties...); err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		sourceTx     *types.TransactionHistory
+		destTxs      []*types.TransactionHistory
+		sourceWallet *types.Wallet
+		destWallets  []*types.Wallet
+	)
+
+	// Execute in transaction
+	var blocked []*types.RiskDecision
+	err = r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		// Get repositories with transaction
+		theRepo := r.NewWithTx(tx)
+
+		// 1. Retrieve every wallet
+		var err error
+		sourceWallet, err = theRepo.FindWalletByID(ctx, sourceWalletID)
+		if err != nil {
+			return fmt.Errorf("failed to get source wallet: %w", err)
+		}
+
+		destWallets = make([]*types.Wallet, len(req.Shares))
+		for i, share := range req.Shares {
+			destWallets[i], err = theRepo.FindWalletByID(ctx, share.DestinationWalletID)
+			if err != nil {
+				return fmt.Errorf("failed to get destination wallet %s: %w", share.DestinationWalletID, err)
+			}
+		}
+		// Funds may be sent to any wallet, so only the source needs authorizing
+		if err := r.authorize(ctx, types.ActionTransfer, req.InitiatorID, sourceWallet); err != nil {
+			return err
+		}
+		if err := r.requireApproval(ctx, types.ApprovalTransfer, req.TransactionCategory, sourceWallet.CurrencyCode, req.Amount); err != nil {
+			return err
+		}
+
+		// 2. Perform the split at the currency's precision
+		precision, err := theRepo.splitPrecision(ctx, sourceWallet.CurrencyCode)
+		if err != nil {
+			return err
+		}
+		sourceTx, destTxs, err = sourceWallet.Split(destWallets, req, precision)
+		if err != nil {
+			return fmt.Errorf("split validation failed: %w", err)
+		}
+
+		// 3. Check limits and screen every wallet against usage before this split
+		if err := theRepo.checkDebitLimits(ctx, sourceWallet, req.Amount); err != nil {
+			return err
+		}
+		inputs := []types.RiskInput{{
+			Operation: types.OperationSplit,
+			Wallet:    sourceWallet,
+			Type:      types.TypeDebit,
+			Amount:    req.Amount,
+			Category:  req.TransactionCategory,
+		}}
+		for i, destWallet := range destWallets {
+			if err := theRepo.checkCreditLimits(ctx, destWallet, destTxs[i]); err != nil {
+				return err
+			}
+			inputs = append(inputs, types.RiskInput{
+				Operation:            types.OperationSplit,
+				Wallet:               destWallet,
+				Type:                 types.TypeCredit,
+				Amount:               destTxs[i].Amount,
+				Category:             req.TransactionCategory,
+				CounterpartyWalletID: sourceWallet.ID,
+			})
+		}
+		decisions, outcome, err := theRepo.screenRisk(ctx, inputs...)
+		if err != nil {
+			return err
+		}
+		if outcome == types.RiskBlock {
+			blocked = decisions
+			return types.ErrTransactionBlocked
+		}
+
+		rows := append([]*types.TransactionHistory{sourceTx}, destTxs...)
+		if outcome == types.RiskReview {
+			for _, row := range rows {
+				row.Status = types.StatusPending
+			}
+		}
+
+		// 4. Update every wallet
+		if _, err := theRepo.UpdateWallet(ctx, sourceWallet); err != nil {
+			return fmt.Errorf("failed to update source wallet: %w", err)
+		}
+		for _, destWallet := range destWallets {
+			if _, err := theRepo.UpdateWallet(ctx, destWallet); err != nil {
+				return fmt.Errorf("failed to update destination wallet %s: %w", destWallet.ID, err)
+			}
+		}
+
+		// 5. Record every transaction
+		ids := make([]string, len(rows))
+		for i, row := range rows {
+			if _, err := theRepo.CreateTransaction(ctx, row); err != nil {
+				return fmt.Errorf("failed to record transaction for wallet %s: %w", row.WalletID, err)
+			}
+			ids[i] = row.ID
+		}
+		for i, destWallet := range destWallets {
+			if err := theRepo.holdForReview(ctx, destWallet, destTxs[i]); err != nil {
+				return err
+			}
+		}
+
+		if err := theRepo.recordRiskDecisions(ctx, decisions, ids...); err != nil {
+			return err
+		}
+
+		return theRepo.emitSplit(ctx, sourceWallet, destWallets, sourceTx, destTxs)
+	})
+	if blocked != nil {
+		return nil, nil, fmt.Errorf("split failed: %w", r.riskBlocked(ctx, blocked))
+	}
+	if err != nil {
+		// Return the transaction records even if failed (they contain failure status)
+		if sourceTx != nil && destTxs != nil {
+			return sourceTx, destTxs, fmt.Errorf("split failed: %w", err)
+		}
+		return nil, nil, fmt.Errorf("split failed before execution: %w", err)
+	}
+
+	return sourceTx, destTxs, nil
+}
+
+// splitPrecision returns the decimal places a currency's shares are rounded to
+func (r *WalletRepository) splitPrecision(ctx context.Context, currencyCode string) (int32, error) {
+	currency, err := r.FindCurrency(ctx, currencyCode)
+	if errors.Is(err, types.ErrCurrencyNotFound) {
+		return types.DefaultSplitPrecision, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get currency: %w", err)
+	}
+	return int32(currency.Precision), nil
+}
+
+// emitSplit writes an event for the source with every row, and one for each
+// destination with the debit and its own credit
+func (r *WalletRepository) emitSplit(
+	ctx context.Context,
+	source *types.Wallet,
+	dests []*types.Wallet,
+	sourceTx *types.TransactionHistory,
+	destTxs []*types.TransactionHistory,
+) error {
+	err := r.emit(ctx, types.EventSplitCompleted, &types.WalletEvent{
+		Wallet:       source,
+		Transactions: append([]*types.TransactionHistory{sourceTx}, destTxs...),
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, dest := range dests {
+		err := r.emit(ctx, types.EventSplitCompleted, &types.WalletEvent{
+			Wallet:       dest,
+			Counterparty: source,
+			Transactions: []*types.TransactionHistory{sourceTx, destTxs[i]},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
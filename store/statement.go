@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/uptrace/bun"
+)
+
+// StatementOptions contains optional settings for GenerateStatement
+type StatementOptions struct {
+	Precision int32                       // Decimal places for formatted amounts (default 2)
+	PageSize  int                         // Rows loaded per query (default 500)
+	Profiles  types.CustomerProfileSource // Source of the account holder address (optional)
+}
+
+// GenerateStatement builds an account statement for a wallet over [from, to].
+// Transactions that moved the balance are read in pages ordered by creation
+// time, so large periods are never held in memory at once. Completed and
+// reversed rows make up the statement; rows held for review are listed as
+// pending (see types.StatementBuilder). The opening balance sums the booked
+// rows before from.
+func (r *WalletRepository) GenerateStatement(
+	ctx context.Context,
+	walletID string,
+	from, to time.Time,
+	opts StatementOptions,
+) (*types.AccountStatement, error) {
+	if to.Before(from) {
+		return nil, types.ErrInvalidReportWindow
+	}
+	if opts.Precision <= 0 {
+		opts.Precision = 2
+	}
+	if opts.PageSize < 1 {
+		opts.PageSize = 500
+	}
+
+	wallet, err := r.FindWalletByID(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	openingBalance, err := r.bookedBalanceBefore(ctx, walletID, from, opts.PageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := types.NewStatementBuilder(wallet, openingBalance, from, to, opts.Precision)
+
+	if opts.Profiles != nil {
+		profile, err := opts.Profiles.FindCustomerProfile(ctx, wallet.CustomerID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load customer profile: %w", err)
+		}
+		if profile != nil {
+			builder.SetAccountHolderAddress(profile.Address)
+		}
+	}
+
+	period := func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("created_at >= ?", from).Where("created_at <= ?", to)
+	}
+	err = r.streamLedgerTransactions(ctx, walletID, period, opts.PageSize, func(tx *types.TransactionHistory) error {
+		builder.Add(*tx)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	statement := builder.Build()
+	return &statement, nil
+}
+
+// bookedBalanceBefore sums the balance effect of the wallet's booked rows
+// created before the given time. A row's BalanceAfter cannot be used, as it
+// misses holds for review placed or released after it was written.
+func (r *WalletRepository) bookedBalanceBefore(ctx context.Context, walletID string, before time.Time, pageSize int) (decimal.Decimal, error) {
+	balance := decimal.Zero
+
+	booked := func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.
+			Where("created_at < ?", before).
+			Where("status IN (?)", bun.In([]types.TransactionStatus{types.StatusCompleted, types.StatusReversed}))
+	}
+	err := r.streamLedgerTransactions(ctx, walletID, booked, pageSize, func(tx *types.TransactionHistory) error {
+		balance = balance.Add(types.BalanceEffect(tx))
+		return nil
+	})
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to load opening balance: %w", err)
+	}
+
+	return balance, nil
+}
+
+// streamLedgerTransactions calls fn for each transaction of the wallet that
+// moved its balance (every row but failed ones, see
+// types.TransactionHistory.MovesBalance) and matches scope (all when nil),
+// oldest first, loading pageSize rows per query
+func (r *WalletRepository) streamLedgerTransactions(
+	ctx context.Context,
+	walletID string,
+	scope func(q *bun.SelectQuery) *bun.SelectQuery,
+	pageSize int,
+	fn func(tx *types.TransactionHistory) error,
+) error {
+	var (
+		cursorTime time.Time
+		cursorID   string
+	)
+
+	for {
+		query := r.db.NewSelect().
+			Model((*types.TransactionHistory)(nil)).
+			Where("wallet_id = ?", walletID).
+			Where("status != ?", types.StatusFailed).
+			Apply(scope).
+			OrderExpr("created_at ASC, id ASC").
+			Limit(pageSize)
+
+		// Keyset pagination on (created_at, id)
+		if cursorID != "" {
+			query = query.Where("(created_at > ? OR (created_at = ? AND id > ?))", cursorTime, cursorTime, cursorID)
+		}
+
+		var page []*types.TransactionHistory
+		if err := query.Scan(ctx, &page); err != nil {
+			return fmt.Errorf("failed to load statement transactions: %w", err)
+		}
+
+		for _, tx := range page {
+			if err := fn(tx); err != nil {
+				return err
+			}
+		}
+
+		if len(page) < pageSize {
+			return nil
+		}
+
+		last := page[len(page)-1]
+		cursorTime, cursorID = last.CreatedAt, last.ID
+	}
+}
@@ -13,6 +13,9 @@ import (
 	"github.com/uptrace/bun"
 )
 
+// ErrTransactionNotFound is returned for an unknown transaction ID
+var ErrTransactionNotFound = errors.New("transaction not found")
+
 // FindTransactionByID retrieves a transaction by its ID
 func (r *WalletRepository) FindTransactionByID(ctx context.Context, id string) (*types.TransactionHistory, error) {
 	tx := types.TransactionHistory{ID: id}
@@ -24,7 +27,7 @@ func (r *WalletRepository) FindTransactionByID(ctx context.Context, id string) (
 		Scan(ctx)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("transaction not found")
+			return nil, ErrTransactionNotFound
 		}
 		return nil, err
 	}
@@ -55,10 +58,33 @@ func (r *WalletRepository) UpdateTransaction(ctx context.Context, tx *types.Tran
 
 	tx.UpdatedAt = time.Now().UTC()
 
-	_, err = r.db.NewUpdate().
-		Model(tx).
-		WherePK().
-		Exec(ctx)
+	// Chain fields are owned by the store and never change after insert
+	tx.PrevHash, tx.Hash = existing.PrevHash, existing.Hash
+
+	// The row's hash covers the status it was written with, so the change is
+	// sealed into the wallet's chain alongside the update
+	err = r.db.RunInTx(ctx, nil, func(ctx context.Context, dbTx bun.Tx) error {
+		repo := r.NewWithTx(dbTx)
+
+		if _, err := dbTx.NewUpdate().Model(tx).WherePK().Exec(ctx); err != nil {
+			return err
+		}
+		if existing.Hash == "" {
+			return nil // Rows written before chaining have no chain to extend
+		}
+
+		head, err := repo.findChainHead(ctx, existing.WalletID)
+		if err != nil {
+			return err
+		}
+		change := types.NewTransactionStatusChange(existing, tx.Status)
+		change.SealChain(head.HeadHash)
+
+		if _, err := dbTx.NewInsert().Model(change).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to record status change: %w", err)
+		}
+		return repo.advanceChainHead(ctx, head, existing.WalletID, change.Hash)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -74,24 +100,49 @@ func (r *WalletRepository) CreateTransaction(ctx context.Context, txData *types.
 	}
 
 	// Set default values if not provided
-	txData.CreatedAt = time.Now().UTC()
+	txData.CreatedAt = time.Now().UTC().Truncate(types.ChainTimePrecision)
 	txData.UpdatedAt = txData.CreatedAt
 
+	if err := validateTransaction(txData); err != nil {
+		return nil, err
+	}
+
+	// Link the row into the wallet's hash chain and insert it atomically
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		repo := r.NewWithTx(tx)
+
+		head, err := repo.findChainHead(ctx, txData.WalletID)
+		if err != nil {
+			return err
+		}
+		txData.SealChain(head.HeadHash)
+
+		if _, err := tx.NewInsert().Model(txData).Exec(ctx); err != nil {
+			return err
+		}
+		return repo.advanceChainHead(ctx, head, txData.WalletID, txData.Hash)
+	})
+
+	return txData, err
+}
+
+// validateTransaction checks the fields a new transaction row must have
+func validateTransaction(txData *types.TransactionHistory) error {
 	// Validate required fields
 	if txData.WalletID == "" {
-		return nil, errors.New("wallet ID is required")
+		return errors.New("wallet ID is required")
 	}
 	if txData.Amount.LessThanOrEqual(decimal.Zero) {
-		return nil, errors.New("transaction amount must be positive")
+		return errors.New("transaction amount must be positive")
 	}
 
 	// Validate category
 	switch txData.Category {
 	case types.CategoryDeposit, types.CategoryTransfer, types.CategoryRefund,
-		types.CategoryAdjustment, types.CategoryFee:
+		types.CategoryAdjustment, types.CategoryFee, types.CategoryReversal:
 		// Valid category
 	default:
-		return nil, errors.New("invalid transaction category")
+		return errors.New("invalid transaction category")
 	}
 
 	// Validate type
@@ -99,7 +150,7 @@ func (r *WalletRepository) CreateTransaction(ctx context.Context, txData *types.
 	case types.TypeCredit, types.TypeDebit:
 		// Valid type
 	default:
-		return nil, errors.New("invalid transaction type")
+		return errors.New("invalid transaction type")
 	}
 
 	// Validate status
@@ -107,15 +158,10 @@ func (r *WalletRepository) CreateTransaction(ctx context.Context, txData *types.
 	case types.StatusPending, types.StatusCompleted, types.StatusFailed:
 		// Valid status
 	default:
-		return nil, errors.New("invalid transaction status")
+		return errors.New("invalid transaction status")
 	}
 
-	// Insert into database
-	_, err := r.db.NewInsert().
-		Model(txData).
-		Exec(ctx)
-
-	return txData, err
+	return nil
 }
 
 // ListTransactionsParams contains parameters for listing transactions
@@ -126,6 +172,7 @@ type ListTransactionsParams struct {
 	CurrencyCode string                    // Filter by currency code
 	Category     types.TransactionCategory // Filter by transaction category
 	Status       types.TransactionStatus   // Filter by transaction status
+	GroupID      string                    // Filter by the group of a multi-leg movement
 	StartTime    time.Time                 // Filter transactions after this time
 	EndTime      time.Time                 // Filter transactions before this time
 	SortBy       string                    // Field to sort by ("id", "created_at", "amount")
@@ -178,6 +225,9 @@ func (r *WalletRepository) ListTransactions(ctx context.Context, params ListTran
 	if params.Status != "" {
 		query = query.Where("status = ?", params.Status)
 	}
+	if params.GroupID != "" {
+		query = query.Where("group_id = ?", params.GroupID)
+	}
 	if !params.StartTime.IsZero() {
 		query = query.Where("created_at >= ?", params.StartTime)
 	}
@@ -24,13 +24,27 @@ func (r *WalletRepository) TransferFunds(
 		return nil, nil, types.ErrInvalidFee
 	}
 
+	// Authenticate the request when signatures are enforced
+	err := r.verifyRequest(ctx, req.InitiatorID, req.Signature, func(sig *types.RequestSignature) []byte {
+		return req.SigningPayload(sourceWalletID, destWalletID, sig)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Screen both customers; a hit freezes the wallet and blocks the transfer
+	if err := r.screenTransferParties(ctx, sourceWalletID, destWalletID); err != nil {
+		return nil, nil, err
+	}
+
 	var (
 		sourceTx, destTx         *types.TransactionHistory
 		sourceWallet, destWallet *types.Wallet
 	)
 
 	// Execute in transaction
-	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+	var blocked []*types.RiskDecision
+	err = r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
 		// Get repositories with transaction
 		theRepo := r.NewWithTx(tx)
 
@@ -45,6 +59,13 @@ func (r *WalletRepository) TransferFunds(
 		if err != nil {
 			return fmt.Errorf("failed to get destination wallet: %w", err)
 		}
+		// Funds may be sent to any wallet, so only the source needs authorizing
+		if err := r.authorize(ctx, types.ActionTransfer, req.InitiatorID, sourceWallet); err != nil {
+			return err
+		}
+		if err := r.requireApproval(ctx, types.ApprovalTransfer, req.TransactionCategory, sourceWallet.CurrencyCode, req.Amount); err != nil {
+			return err
+		}
 
 		// 2. Perform the transfer
 		sourceTx, destTx, err = sourceWallet.Transfer(destWallet, req)
@@ -52,7 +73,44 @@ func (r *WalletRepository) TransferFunds(
 			return fmt.Errorf("transfer validation failed: %w", err)
 		}
 
-		// 3. Update both wallets
+		// 3. Check limits and screen both sides against usage before this transfer
+		if err := theRepo.checkDebitLimits(ctx, sourceWallet, req.Amount); err != nil {
+			return err
+		}
+		if err := theRepo.checkCreditLimits(ctx, destWallet, destTx); err != nil {
+			return err
+		}
+		decisions, outcome, err := theRepo.screenRisk(ctx,
+			types.RiskInput{
+				Operation:            types.OperationTransfer,
+				Wallet:               sourceWallet,
+				Type:                 types.TypeDebit,
+				Amount:               req.Amount,
+				Category:             req.TransactionCategory,
+				CounterpartyWalletID: destWallet.ID,
+			},
+			types.RiskInput{
+				Operation:            types.OperationTransfer,
+				Wallet:               destWallet,
+				Type:                 types.TypeCredit,
+				Amount:               req.Amount,
+				Category:             req.TransactionCategory,
+				CounterpartyWalletID: sourceWallet.ID,
+			},
+		)
+		if err != nil {
+			return err
+		}
+		if outcome == types.RiskBlock {
+			blocked = decisions
+			return types.ErrTransactionBlocked
+		}
+		if outcome == types.RiskReview {
+			sourceTx.Status = types.StatusPending
+			destTx.Status = types.StatusPending
+		}
+
+		// 4. Update both wallets
 		if _, err := theRepo.UpdateWallet(ctx, sourceWallet); err != nil {
 			return fmt.Errorf("failed to update source wallet: %w", err)
 		}
@@ -60,16 +118,26 @@ func (r *WalletRepository) TransferFunds(
 			return fmt.Errorf("failed to update destination wallet: %w", err)
 		}
 
-		// 4. Record both transactions
+		// 5. Record both transactions
 		if _, err := theRepo.CreateTransaction(ctx, sourceTx); err != nil {
 			return fmt.Errorf("failed to record source transaction: %w", err)
 		}
 		if _, err := theRepo.CreateTransaction(ctx, destTx); err != nil {
 			return fmt.Errorf("failed to record destination transaction: %w", err)
 		}
+		if err := theRepo.holdForReview(ctx, destWallet, destTx); err != nil {
+			return err
+		}
 
-		return nil
+		if err := theRepo.recordRiskDecisions(ctx, decisions, sourceTx.ID, destTx.ID); err != nil {
+			return err
+		}
+
+		return theRepo.emitPair(ctx, types.EventTransferCompleted, sourceWallet, destWallet, sourceTx, destTx)
 	})
+	if blocked != nil {
+		return nil, nil, fmt.Errorf("transfer failed: %w", r.riskBlocked(ctx, blocked))
+	}
 	if err != nil {
 		// Return the transaction records even if failed (they contain failure status)
 		if sourceTx != nil && destTx != nil {
@@ -102,13 +170,22 @@ func (r *WalletRepository) SwapFunds(
 		return nil, nil, types.ErrInvalidExchangeRate
 	}
 
+	// Authenticate the request when signatures are enforced
+	err := r.verifyRequest(ctx, req.InitiatorID, req.Signature, func(sig *types.RequestSignature) []byte {
+		return req.SigningPayload(sourceWalletID, destWalletID, sig)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
 	var (
 		sourceTx, destTx         *types.TransactionHistory
 		sourceWallet, destWallet *types.Wallet
 	)
 
 	// Execute in transaction
-	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+	var blocked []*types.RiskDecision
+	err = r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
 		// Get repositories with transaction
 		theRepo := r.NewWithTx(tx)
 
@@ -123,6 +200,11 @@ func (r *WalletRepository) SwapFunds(
 		if err != nil {
 			return fmt.Errorf("failed to get destination wallet: %w", err)
 		}
+		for _, wallet := range []*types.Wallet{sourceWallet, destWallet} {
+			if err := r.authorize(ctx, types.ActionSwap, req.InitiatorID, wallet); err != nil {
+				return err
+			}
+		}
 
 		// 2. Verify exchange rate matches the amounts
 		expectedDestAmount := req.SourceAmount.Mul(req.ExchangeRate)
@@ -140,7 +222,44 @@ func (r *WalletRepository) SwapFunds(
 			return fmt.Errorf("swap validation failed: %w", err)
 		}
 
-		// 4. Update both wallets
+		// 4. Check limits and screen both sides against usage before this swap
+		if err := theRepo.checkDebitLimits(ctx, sourceWallet, req.SourceAmount); err != nil {
+			return err
+		}
+		if err := theRepo.checkCreditLimits(ctx, destWallet, destTx); err != nil {
+			return err
+		}
+		decisions, outcome, err := theRepo.screenRisk(ctx,
+			types.RiskInput{
+				Operation:            types.OperationSwap,
+				Wallet:               sourceWallet,
+				Type:                 types.TypeDebit,
+				Amount:               req.SourceAmount,
+				Category:             req.TransactionCategory,
+				CounterpartyWalletID: destWallet.ID,
+			},
+			types.RiskInput{
+				Operation:            types.OperationSwap,
+				Wallet:               destWallet,
+				Type:                 types.TypeCredit,
+				Amount:               req.DestinationAmount,
+				Category:             req.TransactionCategory,
+				CounterpartyWalletID: sourceWallet.ID,
+			},
+		)
+		if err != nil {
+			return err
+		}
+		if outcome == types.RiskBlock {
+			blocked = decisions
+			return types.ErrTransactionBlocked
+		}
+		if outcome == types.RiskReview {
+			sourceTx.Status = types.StatusPending
+			destTx.Status = types.StatusPending
+		}
+
+		// 5. Update both wallets
 		if _, err := theRepo.UpdateWallet(ctx, sourceWallet); err != nil {
 			return fmt.Errorf("failed to update source wallet: %w", err)
 		}
@@ -148,16 +267,31 @@ func (r *WalletRepository) SwapFunds(
 			return fmt.Errorf("failed to update destination wallet: %w", err)
 		}
 
-		// 5. Record both transactions
+		// 6. Record both transactions
 		if _, err := theRepo.CreateTransaction(ctx, sourceTx); err != nil {
 			return fmt.Errorf("failed to record source transaction: %w", err)
 		}
 		if _, err := theRepo.CreateTransaction(ctx, destTx); err != nil {
 			return fmt.Errorf("failed to record destination transaction: %w", err)
 		}
+		if err := theRepo.holdForReview(ctx, destWallet, destTx); err != nil {
+			return err
+		}
 
-		return nil
+		// 7. Record the FX trade for position reporting
+		if _, err := theRepo.CreateFXTrade(ctx, types.NewFXTrade(sourceWallet.CustomerID, req, sourceTx, destTx)); err != nil {
+			return fmt.Errorf("failed to record fx trade: %w", err)
+		}
+
+		if err := theRepo.recordRiskDecisions(ctx, decisions, sourceTx.ID, destTx.ID); err != nil {
+			return err
+		}
+
+		return theRepo.emitPair(ctx, types.EventSwapCompleted, sourceWallet, destWallet, sourceTx, destTx)
 	})
+	if blocked != nil {
+		return nil, nil, fmt.Errorf("swap failed: %w", r.riskBlocked(ctx, blocked))
+	}
 	if err != nil {
 		// Return the transaction records even if failed (they contain failure status)
 		if sourceTx != nil && destTx != nil {
@@ -21,43 +21,78 @@ var (
 	ErrInvalidCurrencyCode    = errors.New("invalid currency code")
 )
 
-// CreateWallet creates a new wallet or returns existing one
+// CreateWallet creates a new wallet or returns the customer's existing wallet
+// in that currency.
+//
+// The unique (customer_id, currency_code) constraint added by the migrations
+// decides which of several concurrent calls creates the wallet: the insert
+// that loses the race does nothing, and that call returns the stored wallet
+// instead. Only the call that created the wallet announces and screens it.
 func (c *WalletRepository) CreateWallet(ctx context.Context, wallet *types.Wallet) (*types.Wallet, error) {
-	if wallet == nil {
-		return nil, errors.New("wallet cannot be nil")
+	if err := prepareWallet(wallet); err != nil {
+		return nil, err
 	}
 
-	// Normalize currency code
-	wallet.CurrencyCode = strings.ToUpper(strings.TrimSpace(wallet.CurrencyCode))
+	// Insert new wallet, announcing it only if this call created it
+	var created bool
+	err := c.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		res, err := tx.NewInsert().
+			Model(wallet).
+			On("CONFLICT (customer_id, currency_code) DO NOTHING").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if rows, _ := res.RowsAffected(); rows == 0 {
+			return nil
+		}
+		created = true
+		return c.NewWithTx(tx).emit(ctx, types.EventWalletCreated, &types.WalletEvent{Wallet: wallet})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wallet: %w", err)
+	}
+	if !created {
+		return c.FindWalletByCurrency(ctx, wallet.CustomerID, wallet.CurrencyCode)
+	}
 
-	// Check for existing wallet
-	existing, err := c.FindWalletByCurrency(ctx, wallet.CustomerID, wallet.CurrencyCode)
+	// A hit freezes the new wallet for compliance review rather than failing creation
+	if _, err := c.screenCustomer(ctx, wallet, types.ScreeningOnWalletCreation); err != nil {
+		return wallet, err
+	}
+
+	return wallet, nil
+}
 
-	if err == nil {
-		return existing, nil
+// prepareWallet validates a wallet about to be created and fills in the
+// fields left empty
+func prepareWallet(wallet *types.Wallet) error {
+	if wallet == nil {
+		return errors.New("wallet cannot be nil")
+	}
+	if strings.TrimSpace(wallet.CustomerID) == "" {
+		return ErrCustomerIDRequired
 	}
 
-	if err == sql.ErrNoRows {
-		// Initialize wallet fields if empty
-		if wallet.ID == "" {
-			wallet.ID = types.GenerateID("wt_", 12)
-		}
-		if wallet.VersionId == "" {
-			wallet.VersionId = types.GenerateID("ver_", 8)
-		}
-		if wallet.CreatedAt.IsZero() {
-			wallet.CreatedAt = time.Now().UTC()
-		}
-		wallet.UpdatedAt = time.Now().UTC()
+	// Normalize currency code
+	wallet.CurrencyCode = strings.ToUpper(strings.TrimSpace(wallet.CurrencyCode))
+	if wallet.CurrencyCode == "" {
+		return ErrInvalidCurrencyCode
 	}
 
-	// Insert new wallet
-	_, err = c.db.NewInsert().
-		Model(wallet).
-		Ignore().
-		Exec(ctx)
+	// Initialize wallet fields if empty
+	if wallet.ID == "" {
+		wallet.ID = types.GenerateID("wt_", 12)
+	}
+	if wallet.VersionId == "" {
+		wallet.VersionId = types.GenerateID("ver_", 8)
+	}
+	if wallet.CreatedAt.IsZero() {
+		wallet.CreatedAt = time.Now().UTC()
+	}
+	wallet.UpdatedAt = time.Now().UTC()
 
-	return wallet, err
+	return nil
 }
 
 // CreateSimplified creates a new wallet with minimal parameters
@@ -0,0 +1,229 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/uptrace/bun"
+)
+
+// CreateWebhookSubscription saves a new subscription
+func (r *WalletRepository) CreateWebhookSubscription(ctx context.Context, sub *types.WebhookSubscription) (*types.WebhookSubscription, error) {
+	if _, err := r.db.NewInsert().Model(sub).Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+	return sub, nil
+}
+
+// FindWebhookSubscription retrieves a subscription by ID
+func (r *WalletRepository) FindWebhookSubscription(ctx context.Context, id string) (*types.WebhookSubscription, error) {
+	sub := &types.WebhookSubscription{ID: id}
+
+	err := r.db.NewSelect().
+		Model(sub).
+		WherePK().
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.ErrWebhookSubscriptionNotFound
+		}
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// ListWebhookSubscriptions returns a customer's subscriptions, oldest first
+func (r *WalletRepository) ListWebhookSubscriptions(ctx context.Context, customerID string) ([]*types.WebhookSubscription, error) {
+	var subs []*types.WebhookSubscription
+
+	err := r.db.NewSelect().
+		Model(&subs).
+		Where("customer_id = ?", customerID).
+		OrderExpr("created_at ASC, id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+// UpdateWebhookSubscription saves a subscription's URL, event filter and active flag
+func (r *WalletRepository) UpdateWebhookSubscription(ctx context.Context, sub *types.WebhookSubscription) (*types.WebhookSubscription, error) {
+	sub.UpdatedAt = time.Now().UTC()
+
+	res, err := r.db.NewUpdate().
+		Model(sub).
+		Column("url", "event_types", "active", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update webhook subscription: %w", err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return nil, types.ErrWebhookSubscriptionNotFound
+	}
+
+	return sub, nil
+}
+
+// EnqueueWebhookDeliveries queues an event for every active subscription of the
+// customer that wants it. Queuing the same event twice is a no-op, so the
+// outbox relay's redeliveries do not cause duplicate webhooks.
+func (r *WalletRepository) EnqueueWebhookDeliveries(ctx context.Context, event *types.OutboxEvent, customerID string) ([]*types.WebhookDelivery, error) {
+	var subs []*types.WebhookSubscription
+	err := r.db.NewSelect().
+		Model(&subs).
+		Where("customer_id = ?", customerID).
+		Where("active = ?", true).
+		OrderExpr("id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load webhook subscriptions: %w", err)
+	}
+
+	var queued []*types.WebhookDelivery
+	err = r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, sub := range subs {
+			if !sub.Matches(event.Type) {
+				continue
+			}
+			delivery, err := types.NewWebhookDelivery(sub, event)
+			if err != nil {
+				return err
+			}
+			res, err := tx.NewInsert().
+				Model(delivery).
+				On("CONFLICT (subscription_id, event_id) DO NOTHING").
+				Exec(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to queue webhook delivery: %w", err)
+			}
+			if rows, _ := res.RowsAffected(); rows > 0 {
+				queued = append(queued, delivery)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return queued, nil
+}
+
+// ListDueWebhookDeliveries returns up to limit pending deliveries whose next
+// attempt is due, oldest first
+func (r *WalletRepository) ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]*types.WebhookDelivery, error) {
+	var deliveries []*types.WebhookDelivery
+
+	err := r.db.NewSelect().
+		Model(&deliveries).
+		Where("status = ?", types.WebhookPending).
+		Where("next_attempt_at <= ?", now).
+		OrderExpr("next_attempt_at ASC, id ASC").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// RecordWebhookAttempt logs an attempt and saves the delivery state it led to
+func (r *WalletRepository) RecordWebhookAttempt(ctx context.Context, delivery *types.WebhookDelivery, attempt *types.WebhookAttempt) error {
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(attempt).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to log webhook attempt: %w", err)
+		}
+		_, err := tx.NewUpdate().
+			Model(delivery).
+			Column("status", "attempts", "next_attempt_at", "last_status_code", "last_error", "delivered_at").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to update webhook delivery: %w", err)
+		}
+		return nil
+	})
+}
+
+// FindWebhookDelivery retrieves a delivery by ID
+func (r *WalletRepository) FindWebhookDelivery(ctx context.Context, id string) (*types.WebhookDelivery, error) {
+	delivery := &types.WebhookDelivery{ID: id}
+
+	err := r.db.NewSelect().
+		Model(delivery).
+		WherePK().
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.ErrWebhookDeliveryNotFound
+		}
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// ListWebhookDeliveries returns a subscription's deliveries with the given
+// status (all when empty), newest first
+func (r *WalletRepository) ListWebhookDeliveries(ctx context.Context, subscriptionID string, status types.WebhookDeliveryStatus) ([]*types.WebhookDelivery, error) {
+	var deliveries []*types.WebhookDelivery
+
+	query := r.db.NewSelect().
+		Model(&deliveries).
+		Where("subscription_id = ?", subscriptionID).
+		OrderExpr("created_at DESC, id DESC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// ListWebhookAttempts returns a delivery's attempt log, oldest first
+func (r *WalletRepository) ListWebhookAttempts(ctx context.Context, deliveryID string) ([]*types.WebhookAttempt, error) {
+	var attempts []*types.WebhookAttempt
+
+	err := r.db.NewSelect().
+		Model(&attempts).
+		Where("delivery_id = ?", deliveryID).
+		OrderExpr("attempted_at ASC, id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return attempts, nil
+}
+
+// RedeliverWebhook queues a delivery again with a fresh retry budget, whether
+// it was delivered or dead-lettered. Its attempt log is kept.
+func (r *WalletRepository) RedeliverWebhook(ctx context.Context, deliveryID string) (*types.WebhookDelivery, error) {
+	delivery, err := r.FindWebhookDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Redeliver(time.Now().UTC())
+	_, err = r.db.NewUpdate().
+		Model(delivery).
+		Column("status", "attempts", "next_attempt_at", "delivered_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeliver webhook: %w", err)
+	}
+
+	return delivery, nil
+}
@@ -0,0 +1,222 @@
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/otyang/waas-go/store"
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// amlFixture is a monitored week and the customers active around it
+type amlFixture struct {
+	repo     *store.WalletRepository
+	from, to time.Time
+	rules    types.AMLRules
+	spike    *types.Wallet // Volume well above its baseline
+	dormant  *types.Wallet // Active again after a year
+}
+
+// newAMLFixture stores completed rows before and during the week, along with
+// rows monitoring must ignore: failed rows, rows outside the baseline window
+// and other customers' history
+func newAMLFixture(t *testing.T) *amlFixture {
+	t.Helper()
+	db := newSQLiteDB(t)
+	f := &amlFixture{
+		repo: store.NewWalletRepository(db),
+		from: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		rules: types.AMLRules{
+			SpikeMultiplier:  decimal.NewFromInt(2),
+			SpikeMinVolume:   decimal.NewFromInt(100),
+			BaselineWindow:   30 * 24 * time.Hour,
+			DormantPeriod:    180 * 24 * time.Hour,
+			DormantMinAmount: decimal.NewFromInt(100),
+		},
+	}
+	f.to = f.from.AddDate(0, 0, 7)
+
+	row := func(wallet *types.Wallet, at time.Time, amount int64, status types.TransactionStatus) {
+		tx := newTransaction(types.NewTransactionID(), wallet.ID, amount)
+		tx.CurrencyCode = wallet.CurrencyCode
+		tx.CreatedAt, tx.UpdatedAt = at, at
+		tx.Status = status
+		_, err := db.NewInsert().Model(tx).Exec(context.Background())
+		require.NoError(t, err)
+	}
+	day := func(days int) time.Time { return f.from.AddDate(0, 0, days) }
+
+	// Baseline of 300 over 30 days expects 70 in the week
+	f.spike = newWallet(t, f.repo, "cus_spike", "USD")
+	row(f.spike, day(-40), 10000, types.StatusCompleted)
+	row(f.spike, day(-10), 300, types.StatusCompleted)
+	row(f.spike, day(-5), 10000, types.StatusFailed)
+	row(f.spike, day(1), 200, types.StatusCompleted)
+	row(f.spike, day(2), 300, types.StatusCompleted)
+
+	// Other customers' baselines are not pooled with it
+	big := newWallet(t, f.repo, "cus_big", "USD")
+	row(big, day(-3), 100000, types.StatusCompleted)
+	row(big, day(3), 100, types.StatusCompleted)
+
+	// Failed rows are not activity
+	f.dormant = newWallet(t, f.repo, "cus_dormant", "USD")
+	row(f.dormant, day(-365), 50, types.StatusCompleted)
+	row(f.dormant, day(-10), 50, types.StatusFailed)
+	row(f.dormant, day(1), 200, types.StatusCompleted)
+
+	// Recent activity in another currency keeps a customer active
+	active := newWallet(t, f.repo, "cus_active", "USD")
+	activeEUR := newWallet(t, f.repo, "cus_active", "EUR")
+	row(active, day(-365), 50, types.StatusCompleted)
+	row(activeEUR, day(-20), 50, types.StatusCompleted)
+	row(active, day(1), 200, types.StatusCompleted)
+
+	// Customers never active before are new, not dormant
+	fresh := newWallet(t, f.repo, "cus_new", "USD")
+	row(fresh, day(1), 200, types.StatusCompleted)
+
+	return f
+}
+
+func TestRunAMLMonitoring(t *testing.T) {
+	ctx := context.Background()
+	f := newAMLFixture(t)
+
+	opened, err := f.repo.RunAMLMonitoring(ctx, f.from, f.to, f.rules)
+	require.NoError(t, err)
+	require.Len(t, opened, 2)
+
+	byCustomer := make(map[string]*types.AMLCase)
+	for _, found := range opened {
+		byCustomer[found.CustomerID] = found
+	}
+	require.Contains(t, byCustomer, "cus_spike")
+	assert.Equal(t, types.PatternVolumeSpike, byCustomer["cus_spike"].Pattern)
+	assert.Equal(t, "500", byCustomer["cus_spike"].Amount.String())
+	assert.Equal(t, []string{f.spike.ID}, byCustomer["cus_spike"].WalletIDs)
+	require.Contains(t, byCustomer, "cus_dormant")
+	assert.Equal(t, types.PatternDormantReactivation, byCustomer["cus_dormant"].Pattern)
+	assert.Equal(t, "200", byCustomer["cus_dormant"].Amount.String())
+	assert.Equal(t, []string{f.dormant.ID}, byCustomer["cus_dormant"].WalletIDs)
+
+	// An overlapping run finds the same activity and opens nothing new
+	opened, err = f.repo.RunAMLMonitoring(ctx, f.from.AddDate(0, 0, 1), f.to, f.rules)
+	require.NoError(t, err)
+	assert.Empty(t, opened)
+
+	cases, err := f.repo.ListAMLCases(ctx, types.AMLCaseOpen)
+	require.NoError(t, err)
+	assert.Len(t, cases, 2)
+
+	_, err = f.repo.RunAMLMonitoring(ctx, f.to, f.from, f.rules)
+	assert.Error(t, err)
+}
+
+func TestBuildSARReport(t *testing.T) {
+	ctx := context.Background()
+	f := newAMLFixture(t)
+
+	opened, err := f.repo.RunAMLMonitoring(ctx, f.from, f.to, f.rules)
+	require.NoError(t, err)
+	ids := make([]string, 0, len(opened))
+	for _, found := range opened {
+		ids = append(ids, found.ID)
+	}
+
+	report, err := f.repo.BuildSARReport(ctx, "Example Bank", ids...)
+	require.NoError(t, err)
+	assert.Equal(t, "Example Bank", report.Institution)
+	require.Len(t, report.Cases, len(opened))
+	for i, sar := range report.Cases {
+		assert.Equal(t, opened[i].ID, sar.ID)
+		require.Len(t, sar.Transactions, len(sar.TransactionIDs))
+
+		total := decimal.Zero
+		for j, tx := range sar.Transactions {
+			assert.Contains(t, sar.TransactionIDs, tx.ID)
+			total = total.Add(tx.Amount)
+			if j > 0 {
+				assert.False(t, tx.CreatedAt.Before(sar.Transactions[j-1].CreatedAt), "oldest first")
+			}
+		}
+		assert.Equal(t, sar.Amount.String(), total.String())
+	}
+
+	_, err = f.repo.BuildSARReport(ctx, "Example Bank", "aml_missing")
+	assert.ErrorIs(t, err, types.ErrAMLCaseNotFound)
+}
+
+func TestCloseAMLCase(t *testing.T) {
+	ctx := context.Background()
+	f := newAMLFixture(t)
+	f.repo.EnforceAuthorization(types.DefaultPolicy())
+
+	_, err := f.repo.RunAMLMonitoring(ctx, f.from, f.to, f.rules)
+	require.NoError(t, err)
+	cases, err := f.repo.ListAMLCases(ctx, types.AMLCaseOpen)
+	require.NoError(t, err)
+	require.Len(t, cases, 2)
+	reported, dismissed := cases[0], cases[1]
+
+	_, err = f.repo.ReportAMLCase(ctx, reported.ID, "")
+	assert.ErrorIs(t, err, types.ErrUnauthorizedAccess, "no reviewer")
+
+	owner := types.WithPrincipal(ctx, &types.Principal{ID: reported.CustomerID, Roles: []types.Role{types.RoleCustomer}})
+	_, err = f.repo.DismissAMLCase(owner, reported.ID, "")
+	assert.ErrorIs(t, err, types.ErrUnauthorizedAccess, "customers cannot dismiss their own case")
+
+	closed, err := f.repo.ReportAMLCase(asReviewer(ctx), reported.ID, "filed")
+	require.NoError(t, err)
+	assert.Equal(t, types.AMLCaseReported, closed.Status)
+	assert.Equal(t, "cmp_1", closed.ReviewerID)
+
+	closed, err = f.repo.DismissAMLCase(asReviewer(ctx), dismissed.ID, "payroll")
+	require.NoError(t, err)
+	assert.Equal(t, types.AMLCaseDismissed, closed.Status)
+
+	stored, err := f.repo.FindAMLCase(ctx, dismissed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "cmp_1", stored.ReviewerID)
+	assert.Equal(t, "payroll", stored.ReviewNote)
+
+	_, err = f.repo.DismissAMLCase(asReviewer(ctx), reported.ID, "")
+	assert.ErrorIs(t, err, types.ErrInvalidStatusTransition)
+}
+
+func TestRunAMLMonitoringPairsTransfers(t *testing.T) {
+	ctx := context.Background()
+	repo := newSQLiteRepository(t)
+	hub := newWallet(t, repo, "cus_hub", "USD")
+
+	// Equal transfers without a reference, each from a different customer
+	for i := 0; i < 3; i++ {
+		sender := newWallet(t, repo, fmt.Sprintf("cus_s%d", i), "USD")
+		_, _, err := repo.CreditWallet(ctx, sender.ID, types.CreditTransaction{
+			Amount:              decimal.NewFromInt(100),
+			TransactionCategory: types.CategoryDeposit,
+		})
+		require.NoError(t, err)
+		debit, credit, err := repo.TransferFunds(ctx, sender.ID, hub.ID, types.TransferRequest{
+			Amount:              decimal.NewFromInt(100),
+			TransactionCategory: types.CategoryTransfer,
+		})
+		require.NoError(t, err)
+		assert.NotEmpty(t, debit.GroupID)
+		assert.Equal(t, debit.GroupID, credit.GroupID)
+	}
+
+	rules := types.AMLRules{FanMinCounterparties: 3}
+	now := time.Now().UTC()
+	opened, err := repo.RunAMLMonitoring(ctx, now.Add(-time.Hour), now.Add(time.Hour), rules)
+	require.NoError(t, err)
+	require.Len(t, opened, 1)
+	assert.Equal(t, types.PatternFanIn, opened[0].Pattern)
+	assert.Equal(t, "cus_hub", opened[0].CustomerID)
+	assert.Len(t, opened[0].TransactionIDs, 3)
+}
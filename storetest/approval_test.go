@@ -0,0 +1,223 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/otyang/waas-go/store"
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+// asOperator returns ctx carrying a back-office operator
+func asOperator(ctx context.Context, id string) context.Context {
+	return types.WithPrincipal(ctx, &types.Principal{ID: id, Roles: []types.Role{types.RoleOperator}})
+}
+
+// approvalRepository returns a repository gating USD transfers of 100 or more,
+// a source wallet funded with 150 and an empty destination wallet
+func approvalRepository(t *testing.T) (*bun.DB, *store.WalletRepository, *types.Wallet, *types.Wallet) {
+	t.Helper()
+	db := newSQLiteDB(t)
+	repo := store.NewWalletRepository(db)
+	source := newWallet(t, repo, "cus_1", "USD")
+	dest := newWallet(t, repo, "cus_2", "USD")
+
+	_, _, err := repo.CreditWallet(context.Background(), source.ID, types.CreditTransaction{
+		Amount:              decimal.NewFromInt(150),
+		TransactionCategory: types.CategoryDeposit,
+	})
+	require.NoError(t, err)
+
+	repo.RequireApprovals(types.DefaultApprovalPolicy().SetTransferThreshold("USD", decimal.NewFromInt(100)))
+	return db, repo, source, dest
+}
+
+// submitTransfer submits a transfer of amount from source to dest as op_maker
+func submitTransfer(t *testing.T, repo *store.WalletRepository, source, dest *types.Wallet, amount int64) *types.ApprovalRequest {
+	t.Helper()
+
+	req, err := repo.SubmitApproval(asOperator(context.Background(), "op_maker"), types.ApprovalTransfer, source.ID, dest.ID,
+		types.TransferRequest{
+			Amount:              decimal.NewFromInt(amount),
+			InitiatorID:         "op_maker",
+			TransactionCategory: types.CategoryTransfer,
+		}, "supplier payment")
+	require.NoError(t, err)
+	assert.Equal(t, types.ApprovalPending, req.Status)
+	return req
+}
+
+// approvalEvents returns the event types recorded for a request, oldest first
+func approvalEvents(t *testing.T, repo *store.WalletRepository, requestID string) []types.ApprovalEventType {
+	t.Helper()
+
+	events, err := repo.ListApprovalEvents(context.Background(), requestID)
+	require.NoError(t, err)
+	kinds := make([]types.ApprovalEventType, 0, len(events))
+	for _, event := range events {
+		kinds = append(kinds, event.Type)
+	}
+	return kinds
+}
+
+func TestApproveRequest(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("submitted transfer runs once approved", func(t *testing.T) {
+		_, repo, source, dest := approvalRepository(t)
+
+		_, _, err := repo.TransferFunds(ctx, source.ID, dest.ID, types.TransferRequest{
+			Amount:              decimal.NewFromInt(120),
+			TransactionCategory: types.CategoryTransfer,
+		})
+		assert.ErrorIs(t, err, types.ErrApprovalRequired)
+
+		req := submitTransfer(t, repo, source, dest, 120)
+		assertLedger(t, repo, source.ID, 150, 0)
+
+		approved, err := repo.ApproveRequest(asOperator(ctx, "op_checker"), req.ID, "invoice checked")
+		require.NoError(t, err)
+		assert.Equal(t, types.ApprovalExecuted, approved.Status)
+		assert.Equal(t, "op_checker", approved.CheckerID)
+		require.NotEmpty(t, approved.TransactionID)
+
+		row, err := repo.FindTransactionByID(ctx, approved.TransactionID)
+		require.NoError(t, err)
+		assert.Equal(t, source.ID, row.WalletID)
+		assertLedger(t, repo, source.ID, 30, 0)
+		assertLedger(t, repo, dest.ID, 120, 0)
+
+		assert.Equal(t, []types.ApprovalEventType{
+			types.ApprovalEventSubmitted, types.ApprovalEventApproved, types.ApprovalEventExecuted,
+		}, approvalEvents(t, repo, req.ID))
+	})
+
+	t.Run("operation rolls back with the decision", func(t *testing.T) {
+		db, repo, source, dest := approvalRepository(t)
+		req := submitTransfer(t, repo, source, dest, 120)
+
+		// Writing the EXECUTED audit entry is the last step of the decision
+		_, err := db.ExecContext(ctx, `CREATE TRIGGER fail_executed BEFORE INSERT ON approval_events
+			WHEN NEW.type = 'EXECUTED' BEGIN SELECT RAISE(ABORT, 'audit unavailable'); END`)
+		require.NoError(t, err)
+
+		failed, err := repo.ApproveRequest(asOperator(ctx, "op_checker"), req.ID, "")
+		require.Error(t, err)
+		assert.Equal(t, types.ApprovalFailed, failed.Status)
+		assert.Empty(t, failed.TransactionID)
+
+		// The transfer did not commit without its decision
+		assertLedger(t, repo, source.ID, 150, 0)
+		assertLedger(t, repo, dest.ID, 0, 0)
+	})
+
+	t.Run("maker cannot decide their own request", func(t *testing.T) {
+		_, repo, source, dest := approvalRepository(t)
+		req := submitTransfer(t, repo, source, dest, 120)
+
+		_, err := repo.ApproveRequest(asOperator(ctx, "op_maker"), req.ID, "")
+		assert.ErrorIs(t, err, types.ErrSelfApproval)
+		_, err = repo.RejectRequest(asOperator(ctx, "op_maker"), req.ID, "")
+		assert.ErrorIs(t, err, types.ErrSelfApproval)
+
+		customer := types.WithPrincipal(ctx, &types.Principal{ID: "cus_2", Roles: []types.Role{types.RoleCustomer}})
+		_, err = repo.ApproveRequest(customer, req.ID, "")
+		assert.ErrorIs(t, err, types.ErrUnauthorizedAccess)
+
+		stored, err := repo.FindApprovalRequest(ctx, req.ID)
+		require.NoError(t, err)
+		assert.Equal(t, types.ApprovalPending, stored.Status)
+		assertLedger(t, repo, source.ID, 150, 0)
+	})
+
+	t.Run("failed operation is recorded", func(t *testing.T) {
+		_, repo, source, dest := approvalRepository(t)
+		req := submitTransfer(t, repo, source, dest, 200)
+
+		failed, err := repo.ApproveRequest(asOperator(ctx, "op_checker"), req.ID, "")
+		assert.ErrorIs(t, err, types.ErrInsufficientFunds)
+		assert.Equal(t, types.ApprovalFailed, failed.Status)
+		assert.Contains(t, failed.DecisionNote, types.ErrInsufficientFunds.Error())
+
+		stored, err := repo.FindApprovalRequest(ctx, req.ID)
+		require.NoError(t, err)
+		assert.Equal(t, types.ApprovalFailed, stored.Status)
+		assert.Equal(t, "op_checker", stored.CheckerID)
+		assert.Empty(t, stored.TransactionID)
+
+		assertLedger(t, repo, source.ID, 150, 0)
+		assertLedger(t, repo, dest.ID, 0, 0)
+		assert.Equal(t, []types.ApprovalEventType{
+			types.ApprovalEventSubmitted, types.ApprovalEventApproved, types.ApprovalEventFailed,
+		}, approvalEvents(t, repo, req.ID))
+	})
+
+	t.Run("decided request is refused", func(t *testing.T) {
+		_, repo, source, dest := approvalRepository(t)
+		rejected := submitTransfer(t, repo, source, dest, 120)
+		executed := submitTransfer(t, repo, source, dest, 100)
+
+		_, err := repo.RejectRequest(asOperator(ctx, "op_checker"), rejected.ID, "no invoice")
+		require.NoError(t, err)
+		_, err = repo.ApproveRequest(asOperator(ctx, "op_checker"), rejected.ID, "")
+		assert.ErrorIs(t, err, types.ErrApprovalNotPending)
+
+		_, err = repo.ApproveRequest(asOperator(ctx, "op_checker"), executed.ID, "")
+		require.NoError(t, err)
+		_, err = repo.ApproveRequest(asOperator(ctx, "op_other"), executed.ID, "")
+		assert.ErrorIs(t, err, types.ErrApprovalNotPending)
+		_, err = repo.RejectRequest(asOperator(ctx, "op_other"), executed.ID, "")
+		assert.ErrorIs(t, err, types.ErrApprovalNotPending)
+
+		// Executed once
+		assertLedger(t, repo, source.ID, 50, 0)
+		assertLedger(t, repo, dest.ID, 100, 0)
+	})
+}
+
+func TestExpireApprovals(t *testing.T) {
+	ctx := context.Background()
+	db, repo, source, dest := approvalRepository(t)
+
+	stale := submitTransfer(t, repo, source, dest, 120)
+	fresh := submitTransfer(t, repo, source, dest, 120)
+	decided := submitTransfer(t, repo, source, dest, 120)
+	_, err := repo.RejectRequest(asOperator(ctx, "op_checker"), decided.ID, "")
+	require.NoError(t, err)
+
+	_, err = db.NewUpdate().
+		Model((*types.ApprovalRequest)(nil)).
+		Set("expires_at = ?", time.Now().UTC().Add(-time.Minute)).
+		Where("id IN (?)", bun.In([]string{stale.ID, decided.ID})).
+		Exec(ctx)
+	require.NoError(t, err)
+
+	expired, err := repo.ExpireApprovals(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+
+	for id, status := range map[string]types.ApprovalStatus{
+		stale.ID:   types.ApprovalExpired,
+		fresh.ID:   types.ApprovalPending,
+		decided.ID: types.ApprovalRejected,
+	} {
+		stored, err := repo.FindApprovalRequest(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, status, stored.Status, id)
+	}
+	assert.Equal(t, []types.ApprovalEventType{
+		types.ApprovalEventSubmitted, types.ApprovalEventExpired,
+	}, approvalEvents(t, repo, stale.ID))
+
+	_, err = repo.ApproveRequest(asOperator(ctx, "op_checker"), stale.ID, "")
+	assert.ErrorIs(t, err, types.ErrApprovalNotPending)
+
+	expired, err = repo.ExpireApprovals(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, expired)
+}
@@ -0,0 +1,69 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFXPositionReport(t *testing.T) {
+	ctx := context.Background()
+	repo := newSQLiteRepository(t)
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	trade := func(id string, at time.Time, fromAmount, toAmount int64) {
+		_, err := repo.CreateFXTrade(ctx, &types.FXTrade{
+			ID:                  id,
+			CustomerID:          "cus_1",
+			SourceWalletID:      "wt_usd",
+			DestWalletID:        "wt_eur",
+			SourceTransactionID: "tx_src_" + id,
+			DestTransactionID:   "tx_dst_" + id,
+			FromCurrency:        "USD",
+			ToCurrency:          "EUR",
+			FromAmount:          decimal.NewFromInt(fromAmount),
+			ToAmount:            decimal.NewFromInt(toAmount),
+			Fee:                 decimal.Zero,
+			AppliedRate:         decimal.NewFromFloat(0.79),
+			MidRate:             decimal.NewFromFloat(0.8),
+			SourceBaseRate:      decimal.NewFromInt(1),
+			TradedAt:            at,
+		})
+		require.NoError(t, err)
+	}
+	trade("fxt_1", day.Add(time.Hour), 100, 79)
+	trade("fxt_2", day.Add(23*time.Hour+30*time.Minute), 200, 158)
+	trade("fxt_3", day.AddDate(0, 0, 1).Add(time.Hour), 1000, 790)
+
+	rates := map[string]float64{"EUR": 0.8}
+
+	report, err := repo.FXPositionReport(ctx, "USD", rates, day, day.AddDate(0, 0, 2))
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.TradeCount)
+	require.Len(t, report.Daily, 2)
+	assert.Equal(t, "2024-03-01", report.Daily[0].Date)
+
+	_, err = repo.FXPositionReport(ctx, "USD", rates, day, day)
+	assert.ErrorIs(t, err, types.ErrInvalidReportWindow)
+
+	t.Run("daily report uses the UTC day", func(t *testing.T) {
+		// 20:00 on 29 Feb in UTC-5 is 01:00 on 1 March in UTC
+		local := time.Date(2024, 2, 29, 20, 0, 0, 0, time.FixedZone("EST", -5*60*60))
+
+		report, err := repo.DailyFXPositionReport(ctx, "USD", rates, local)
+		require.NoError(t, err)
+		assert.Equal(t, day, report.From)
+		assert.Equal(t, 2, report.TradeCount)
+		require.Len(t, report.Positions, 2)
+
+		eur, usd := report.Positions[0], report.Positions[1]
+		assert.Equal(t, "-237", eur.NetPosition.String())
+		assert.Equal(t, "300", usd.NetPosition.String())
+		assert.Equal(t, "3", eur.SpreadRevenue.String())
+	})
+}
@@ -0,0 +1,87 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/otyang/waas-go/store"
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepairBalanceDrift(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLiteDB(t)
+	repo := store.NewWalletRepository(db)
+	wallet := newWallet(t, repo, "cus_1", "USD")
+
+	_, _, err := repo.CreditWallet(ctx, wallet.ID, types.CreditTransaction{
+		Amount:              decimal.NewFromInt(100),
+		TransactionCategory: types.CategoryDeposit,
+	})
+	require.NoError(t, err)
+
+	// A credit of 50 whose row and wallet only moved by 40
+	row := newTransaction("tx_short", wallet.ID, 50)
+	row.Status = types.StatusCompleted
+	row.BalanceBefore = decimal.NewFromInt(100)
+	row.BalanceAfter = decimal.NewFromInt(140)
+	_, err = repo.CreateTransaction(ctx, row)
+	require.NoError(t, err)
+	_, err = db.NewUpdate().
+		Model((*types.Wallet)(nil)).
+		Set("available_balance = ?", decimal.NewFromInt(140)).
+		Where("id = ?", wallet.ID).
+		Exec(ctx)
+	require.NoError(t, err)
+
+	report, err := repo.CheckBalanceIntegrity(ctx, store.IntegrityOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []types.IntegrityIssueKind{types.IssueRowArithmetic, types.IssueBalanceDrift}, issueKinds(report.Issues))
+	assert.Equal(t, "tx_short", report.Issues[0].TransactionID)
+	assert.Equal(t, "150", report.Issues[0].Expected.String())
+	assert.Empty(t, report.Adjustments)
+
+	report, err = repo.CheckBalanceIntegrity(ctx, store.IntegrityOptions{Repair: true, InitiatorID: "ops"})
+	require.NoError(t, err)
+	require.Len(t, report.Adjustments, 1)
+
+	adjustment := report.Adjustments[0]
+	assert.Equal(t, types.CategoryAdjustment, adjustment.Category)
+	assert.Equal(t, types.TypeDebit, adjustment.Type)
+	assert.Equal(t, "10", adjustment.Amount.String())
+	assert.Equal(t, "150", adjustment.BalanceBefore.String(), "starts from the replayed balance")
+	assert.Equal(t, "140", adjustment.BalanceAfter.String())
+	assert.Equal(t, "ops", adjustment.InitiatorID)
+
+	// The drift is gone; the short row stays on record as it was written
+	report, err = repo.CheckBalanceIntegrity(ctx, store.IntegrityOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []types.IntegrityIssueKind{types.IssueRowArithmetic}, issueKinds(report.Issues))
+	assert.Equal(t, "tx_short", report.Issues[0].TransactionID)
+
+	stored, err := repo.FindWalletByID(ctx, wallet.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "140", stored.AvailableBalance.String())
+	result, err := repo.ListTransactions(ctx, store.ListTransactionsParams{WalletID: wallet.ID, SortOrder: "asc"})
+	require.NoError(t, err)
+	require.Len(t, result.Transactions, 3)
+	replay := types.ReplayWallet(stored, result.Transactions, nil)
+	assert.Equal(t, []types.IntegrityIssueKind{types.IssueRowArithmetic}, issueKinds(replay.Issues))
+	assert.False(t, replay.HasDrift())
+
+	chain, err := repo.VerifyLedgerChain(ctx, wallet.ID)
+	require.NoError(t, err)
+	assert.True(t, chain.Valid(), chain.Violations)
+}
+
+// issueKinds lists the kinds of issues in order
+func issueKinds(issues []*types.IntegrityIssue) []types.IntegrityIssueKind {
+	kinds := make([]types.IntegrityIssueKind, 0, len(issues))
+	for _, issue := range issues {
+		kinds = append(kinds, issue.Kind)
+	}
+	return kinds
+}
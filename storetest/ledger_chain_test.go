@@ -0,0 +1,46 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/otyang/waas-go/store"
+	"github.com/otyang/waas-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLedgerChainCoversStatus(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLiteDB(t)
+	repo := store.NewWalletRepository(db)
+
+	_, err := repo.CreateTransaction(ctx, newTransaction("tx_1", "wt_1", 100))
+	require.NoError(t, err)
+	pending, err := repo.CreateTransaction(ctx, newTransaction("tx_2", "wt_1", 50))
+	require.NoError(t, err)
+
+	// A status change through the store is sealed into the chain
+	pending.Status = types.StatusFailed
+	_, err = repo.UpdateTransaction(ctx, pending)
+	require.NoError(t, err)
+
+	verification, err := repo.VerifyLedgerChain(ctx, "wt_1")
+	require.NoError(t, err)
+	assert.True(t, verification.Valid(), verification.Violations)
+	assert.Equal(t, int64(3), verification.Length)
+
+	// A status edited in the database is caught
+	_, err = db.NewUpdate().
+		Model((*types.TransactionHistory)(nil)).
+		Set("status = ?", types.StatusCompleted).
+		Where("id = ?", "tx_2").
+		Exec(ctx)
+	require.NoError(t, err)
+
+	verification, err = repo.VerifyLedgerChain(ctx, "wt_1")
+	require.NoError(t, err)
+	require.Len(t, verification.Violations, 1)
+	assert.Equal(t, types.ChainModified, verification.Violations[0].Kind)
+	assert.Equal(t, "tx_2", verification.Violations[0].TransactionID)
+}
@@ -0,0 +1,133 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebitLimits(t *testing.T) {
+	ctx := context.Background()
+	repo := newSQLiteRepository(t)
+	repo.EnforceLimits(types.NewLimitSchedule(
+		&types.LimitRule{
+			Tier:         types.KYCTierBasic,
+			CurrencyCode: "usd",
+			Windows:      map[types.LimitWindow]types.WindowLimit{types.WindowDaily: {Volume: decimal.NewFromInt(100)}},
+		},
+		&types.LimitRule{
+			Tier:         types.KYCTierStandard,
+			CurrencyCode: "USD",
+			Windows:      map[types.LimitWindow]types.WindowLimit{types.WindowDaily: {Volume: decimal.NewFromInt(1000)}},
+		},
+	))
+	require.NoError(t, repo.SetCustomerTier(ctx, "cus_1", types.KYCTierBasic))
+
+	debit := func(walletID string, amount int64) error {
+		_, _, err := repo.DebitWallet(ctx, walletID, types.DebitTransaction{
+			Amount:              decimal.NewFromInt(amount),
+			TransactionCategory: types.CategoryTransfer,
+		})
+		return err
+	}
+
+	own := newWallet(t, repo, "cus_1", "USD")
+	other := newWallet(t, repo, "cus_2", "USD")
+	for _, wallet := range []*types.Wallet{own, other} {
+		_, _, err := repo.CreditWallet(ctx, wallet.ID, types.CreditTransaction{
+			Amount:              decimal.NewFromInt(500),
+			TransactionCategory: types.CategoryDeposit,
+		})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, debit(own.ID, 60))
+	// Another customer's debits and a failed debit do not count
+	require.NoError(t, debit(other.ID, 300))
+	failed := newTransaction("tx_failed", own.ID, 80)
+	failed.Type = types.TypeDebit
+	failed, err := repo.CreateTransaction(ctx, failed)
+	require.NoError(t, err)
+	failed.Status = types.StatusFailed
+	_, err = repo.UpdateTransaction(ctx, failed)
+	require.NoError(t, err)
+
+	usage, err := repo.GetLimitUsage(ctx, "cus_1", "usd")
+	require.NoError(t, err)
+	assert.Equal(t, types.KYCTierBasic, usage.Tier)
+	assert.Equal(t, "USD", usage.CurrencyCode)
+	assert.Equal(t, "60", usage.Windows[types.WindowDaily].Volume.String())
+	assert.Equal(t, 1, usage.Windows[types.WindowDaily].Count)
+	assert.Equal(t, "440", usage.Balance.String())
+	headroom, _, ok := usage.Headroom(types.WindowDaily)
+	require.True(t, ok)
+	assert.Equal(t, "40", headroom.String())
+
+	// Over the rolling limit
+	err = debit(own.ID, 50)
+	require.ErrorIs(t, err, types.ErrLimitExceeded)
+	var limitErr *types.LimitError
+	require.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, "DAILY_VOLUME", limitErr.Limit)
+	assert.Equal(t, types.KYCTierBasic, limitErr.Tier)
+	assert.Equal(t, "60", limitErr.Used.String())
+	assert.Equal(t, "50", limitErr.Requested.String())
+	assert.Equal(t, "40", limitErr.Headroom.String())
+
+	// Up to the headroom is allowed
+	require.NoError(t, debit(own.ID, 40))
+	assert.ErrorIs(t, debit(own.ID, 1), types.ErrLimitExceeded)
+
+	// A higher tier raises the limit
+	require.NoError(t, repo.SetCustomerTier(ctx, "cus_1", types.KYCTierStandard))
+	require.NoError(t, debit(own.ID, 50))
+
+	usage, err = repo.GetLimitUsage(ctx, "cus_1", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, types.KYCTierStandard, usage.Tier)
+	assert.Equal(t, "150", usage.Windows[types.WindowDaily].Volume.String())
+
+	// Customers without a rule are unlimited
+	usage, err = repo.GetLimitUsage(ctx, "cus_2", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, types.KYCTierNone, usage.Tier)
+	assert.Nil(t, usage.Rule)
+	_, _, ok = usage.Headroom(types.WindowDaily)
+	assert.False(t, ok)
+}
+
+func TestRejectedReviewFreesLimit(t *testing.T) {
+	ctx := context.Background()
+
+	for _, direction := range []types.TransactionType{types.TypeDebit, types.TypeCredit} {
+		t.Run(string(direction), func(t *testing.T) {
+			repo, wallet, _, review := heldMovement(t, direction)
+			repo.EnforceLimits(types.NewLimitSchedule(&types.LimitRule{
+				Tier:         types.KYCTierBasic,
+				CurrencyCode: "USD",
+				Windows:      map[types.LimitWindow]types.WindowLimit{types.WindowDaily: {Volume: decimal.NewFromInt(120)}},
+			}))
+			require.NoError(t, repo.SetCustomerTier(ctx, wallet.CustomerID, types.KYCTierBasic))
+
+			_, err := repo.ResolveRiskReview(asReviewer(ctx), review.ID, false)
+			require.NoError(t, err)
+
+			// Neither the reversed row nor its reversal is spending
+			usage, err := repo.GetLimitUsage(ctx, wallet.CustomerID, "USD")
+			require.NoError(t, err)
+			assert.Equal(t, "0", usage.Windows[types.WindowDaily].Volume.String())
+			assert.Equal(t, 0, usage.Windows[types.WindowDaily].Count)
+
+			_, _, err = repo.DebitWallet(ctx, wallet.ID, types.DebitTransaction{
+				Amount:              decimal.NewFromInt(90),
+				TransactionCategory: types.CategoryTransfer,
+			})
+			require.NoError(t, err)
+		})
+	}
+}
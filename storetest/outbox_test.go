@@ -0,0 +1,56 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/otyang/waas-go/outbox"
+	"github.com/otyang/waas-go/store"
+	"github.com/otyang/waas-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutboxResumeFollowsPublishOrder(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLiteDB(t)
+	repo := store.NewWalletRepository(db)
+	wallet := newWallet(t, repo, "cus_1", "USD")
+	publisher := outbox.NewMemoryPublisher()
+	relay := outbox.NewRelay(repo, publisher)
+
+	write := func(id int64) {
+		t.Helper()
+		event, err := types.NewOutboxEvent(types.EventWalletCredited, wallet.ID, &types.WalletEvent{Wallet: wallet})
+		require.NoError(t, err)
+		event.ID = id
+		_, err = db.NewInsert().Model(event).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	// Event 5 commits and is published; event 3 was inserted first but
+	// commits later, so its ID is below the client's last seen position
+	write(5)
+	_, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	seen, err := repo.ListCustomerEvents(ctx, "cus_1", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, seen, 1)
+	last := seen[0].PublishSequence
+
+	write(3)
+	_, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+
+	missed, err := repo.ListCustomerEvents(ctx, "cus_1", last, 10)
+	require.NoError(t, err)
+	require.Len(t, missed, 1)
+	assert.Equal(t, int64(3), missed[0].ID)
+	assert.Greater(t, missed[0].PublishSequence, last)
+
+	// The relay numbered events in the order it published them
+	published := publisher.Events()
+	require.Len(t, published, 2)
+	assert.Equal(t, []int64{5, 3}, []int64{published[0].ID, published[1].ID})
+	assert.Equal(t, published[0].PublishSequence+1, published[1].PublishSequence)
+}
@@ -0,0 +1,303 @@
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/otyang/waas-go/store"
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+// fundingFixture is a funded wallet and recipients to pay from it
+type fundingFixture struct {
+	repo       *store.WalletRepository
+	source     *types.Wallet
+	recipients []*types.Wallet
+}
+
+func newFundingFixture(t *testing.T, repo *store.WalletRepository, funds int64, recipients int) *fundingFixture {
+	t.Helper()
+	ctx := context.Background()
+
+	f := &fundingFixture{repo: repo, source: newWallet(t, repo, "cus_payer", "USD")}
+	f.source.AvailableBalance = decimal.NewFromInt(funds)
+	_, err := repo.UpdateWallet(ctx, f.source)
+	require.NoError(t, err)
+
+	for i := 1; i <= recipients; i++ {
+		f.recipients = append(f.recipients, newWallet(t, repo, fmt.Sprintf("cus_%d", i), "USD"))
+	}
+	return f
+}
+
+func (f *fundingFixture) request(mode types.PayoutMode, amount int64) types.PayoutRequest {
+	req := types.PayoutRequest{
+		SourceWalletID: f.source.ID,
+		Mode:           mode,
+		Description:    "payroll",
+		InitiatorID:    "op_1",
+	}
+	for _, recipient := range f.recipients {
+		req.Items = append(req.Items, types.PayoutItemRequest{
+			DestinationWalletID: recipient.ID,
+			Amount:              decimal.NewFromInt(amount),
+			Fee:                 decimal.NewFromInt(1),
+		})
+	}
+	return req
+}
+
+func (f *fundingFixture) balance(t *testing.T, walletID string) string {
+	t.Helper()
+	wallet, err := f.repo.FindWalletByID(context.Background(), walletID)
+	require.NoError(t, err)
+	return wallet.AvailableBalance.String()
+}
+
+func TestPayoutBatchBestEffort(t *testing.T) {
+	ctx := context.Background()
+	repo := newSQLiteRepository(t)
+	repo.SetPayoutChunkSize(2)
+	f := newFundingFixture(t, repo, 1000, 5)
+
+	req := f.request(types.PayoutBestEffort, 10)
+	req.Reference = "payroll-2024-11"
+	req.Items = append(req.Items, types.PayoutItemRequest{DestinationWalletID: "wt_missing", Amount: decimal.NewFromInt(10)})
+
+	batch, err := repo.SubmitPayoutBatch(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, types.PayoutBatchPartial, batch.Status)
+	assert.Equal(t, 5, batch.CompletedCount)
+	assert.Equal(t, 1, batch.FailedCount)
+	assert.Equal(t, "945", f.balance(t, f.source.ID))
+
+	for i, item := range batch.Items[:5] {
+		assert.Equal(t, types.PayoutItemCompleted, item.Status)
+		assert.Equal(t, "10", f.balance(t, f.recipients[i].ID))
+
+		debit, err := repo.FindTransactionByID(ctx, item.SourceTransactionID)
+		require.NoError(t, err)
+		assert.Equal(t, item.ID, debit.ExternalReference)
+		_, err = repo.FindTransactionByID(ctx, item.DestinationTransactionID)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, types.PayoutItemFailed, batch.Items[5].Status)
+	assert.Contains(t, batch.Items[5].Error, types.ErrRecipientNotFound.Error())
+
+	// The stored batch matches the returned one
+	stored, err := repo.FindPayoutBatch(ctx, batch.ID)
+	require.NoError(t, err)
+	assert.Equal(t, batch.Status, stored.Status)
+	require.Len(t, stored.Items, 6)
+	assert.Equal(t, batch.Items[0].SourceTransactionID, stored.Items[0].SourceTransactionID)
+
+	// Submitting the same reference again pays nothing
+	again, err := repo.SubmitPayoutBatch(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, batch.ID, again.ID)
+	assert.Equal(t, "945", f.balance(t, f.source.ID))
+}
+
+func TestPayoutBatchRepeatedReference(t *testing.T) {
+	ctx := context.Background()
+	repo := newSQLiteRepository(t)
+	f := newFundingFixture(t, repo, 1000, 2)
+	repo.EnforceAuthorization(types.DefaultPolicy())
+	payer := types.WithPrincipal(ctx, &types.Principal{ID: "cus_payer", Roles: []types.Role{types.RoleCustomer}})
+
+	req := f.request(types.PayoutBestEffort, 10)
+	req.InitiatorID = "cus_payer"
+	req.Reference = "payroll-2024-12"
+	batch, err := repo.SubmitPayoutBatch(payer, req)
+	require.NoError(t, err)
+	assert.Equal(t, "978", f.balance(t, f.source.ID))
+
+	again, err := repo.SubmitPayoutBatch(payer, req)
+	require.NoError(t, err)
+	assert.Equal(t, batch.ID, again.ID)
+
+	// Another customer cannot read the batch back through its reference
+	other := newWallet(t, repo, "cus_other", "USD")
+	stranger := types.WithPrincipal(ctx, &types.Principal{ID: "cus_other", Roles: []types.Role{types.RoleCustomer}})
+	stolen := req
+	stolen.SourceWalletID = other.ID
+	stolen.InitiatorID = "cus_other"
+	_, err = repo.SubmitPayoutBatch(stranger, stolen)
+	assert.ErrorIs(t, err, types.ErrUnauthorizedAccess)
+
+	// A different payout under the same reference is a conflict, not a repeat
+	changed := req
+	changed.Mode = types.PayoutAllOrNothing
+	_, err = repo.SubmitPayoutBatch(payer, changed)
+	assert.ErrorIs(t, err, types.ErrPayoutReferenceUsed)
+
+	changed = req
+	changed.Items = append([]types.PayoutItemRequest{}, req.Items...)
+	changed.Items[1].Amount = decimal.NewFromInt(500)
+	_, err = repo.SubmitPayoutBatch(payer, changed)
+	assert.ErrorIs(t, err, types.ErrPayoutReferenceUsed)
+
+	changed = req
+	changed.Items = req.Items[:1]
+	_, err = repo.SubmitPayoutBatch(payer, changed)
+	assert.ErrorIs(t, err, types.ErrPayoutReferenceUsed)
+
+	assert.Equal(t, "978", f.balance(t, f.source.ID))
+}
+
+func TestPayoutBatchFailsAtPayTime(t *testing.T) {
+	// Two debits a day: the third item passes validation but fails when paid
+	limited := func(repo *store.WalletRepository) {
+		repo.EnforceLimits(types.NewLimitSchedule(&types.LimitRule{
+			Tier:         types.KYCTierNone,
+			CurrencyCode: "USD",
+			Windows:      map[types.LimitWindow]types.WindowLimit{types.WindowDaily: {Count: 2}},
+		}))
+	}
+
+	t.Run("best effort pays the rest", func(t *testing.T) {
+		repo := newSQLiteRepository(t)
+		f := newFundingFixture(t, repo, 100, 3)
+		limited(repo)
+
+		batch, err := repo.SubmitPayoutBatch(context.Background(), f.request(types.PayoutBestEffort, 10))
+		require.NoError(t, err)
+		assert.Equal(t, types.PayoutBatchPartial, batch.Status)
+		assert.Equal(t, types.PayoutItemFailed, batch.Items[2].Status)
+		assert.Contains(t, batch.Items[2].Error, "DAILY_COUNT")
+		assert.Equal(t, "78", f.balance(t, f.source.ID))
+	})
+
+	t.Run("all or nothing pays nobody", func(t *testing.T) {
+		repo := newSQLiteRepository(t)
+		f := newFundingFixture(t, repo, 100, 3)
+		limited(repo)
+
+		batch, err := repo.SubmitPayoutBatch(context.Background(), f.request(types.PayoutAllOrNothing, 10))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "item 3")
+		assert.Equal(t, types.PayoutBatchFailed, batch.Status)
+		assert.Equal(t, types.PayoutItemSkipped, batch.Items[0].Status)
+		assert.Empty(t, batch.Items[0].SourceTransactionID)
+		assert.Equal(t, types.PayoutItemFailed, batch.Items[2].Status)
+		assert.Equal(t, "100", f.balance(t, f.source.ID))
+		assert.Equal(t, "0", f.balance(t, f.recipients[0].ID))
+
+		stored, err := repo.FindPayoutBatch(context.Background(), batch.ID)
+		require.NoError(t, err)
+		assert.Equal(t, types.PayoutBatchFailed, stored.Status)
+		assert.Equal(t, types.PayoutItemSkipped, stored.Items[1].Status)
+	})
+}
+
+func TestPayoutBatchAllOrNothing(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("pays every item", func(t *testing.T) {
+		repo := newSQLiteRepository(t)
+		f := newFundingFixture(t, repo, 100, 3)
+
+		batch, err := repo.SubmitPayoutBatch(ctx, f.request(types.PayoutAllOrNothing, 20))
+		require.NoError(t, err)
+		assert.Equal(t, types.PayoutBatchCompleted, batch.Status)
+		assert.Equal(t, 3, batch.CompletedCount)
+		assert.Equal(t, "37", f.balance(t, f.source.ID))
+	})
+
+	t.Run("rejects the batch when a recipient cannot be paid", func(t *testing.T) {
+		repo := newSQLiteRepository(t)
+		f := newFundingFixture(t, repo, 100, 3)
+		eur := newWallet(t, repo, "cus_eur", "EUR")
+
+		req := f.request(types.PayoutAllOrNothing, 20)
+		req.Items[1].DestinationWalletID = eur.ID
+		batch, err := repo.SubmitPayoutBatch(ctx, req)
+		assert.ErrorIs(t, err, types.ErrPayoutRejected)
+		assert.ErrorIs(t, err, types.ErrCurrencyMismatch)
+		assert.Equal(t, types.PayoutBatchFailed, batch.Status)
+		assert.Equal(t, "100", f.balance(t, f.source.ID))
+
+		stored, err := repo.FindPayoutBatch(ctx, batch.ID)
+		require.NoError(t, err)
+		assert.Equal(t, types.PayoutItemFailed, stored.Items[1].Status)
+	})
+
+	t.Run("rejects a batch the wallet cannot fund", func(t *testing.T) {
+		repo := newSQLiteRepository(t)
+		f := newFundingFixture(t, repo, 100, 3)
+
+		_, err := repo.SubmitPayoutBatch(ctx, f.request(types.PayoutBestEffort, 33))
+		assert.ErrorIs(t, err, types.ErrInsufficientFunds)
+		assert.Equal(t, "100", f.balance(t, f.source.ID))
+	})
+}
+
+// cancelAfter cancels a context once a query matching pattern has run n times
+type cancelAfter struct {
+	pattern string
+	n       int32
+	seen    atomic.Int32
+	cancel  context.CancelFunc
+}
+
+func (h *cancelAfter) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *cancelAfter) AfterQuery(_ context.Context, event *bun.QueryEvent) {
+	if strings.HasPrefix(event.Query, h.pattern) && h.seen.Add(1) == h.n {
+		h.cancel()
+	}
+}
+
+func TestPayoutBatchResume(t *testing.T) {
+	db := newSQLiteDB(t)
+	repo := store.NewWalletRepository(db)
+	repo.SetPayoutChunkSize(2)
+	f := newFundingFixture(t, repo, 1000, 5)
+
+	// Stop the process part way through the second chunk
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db.AddQueryHook(&cancelAfter{pattern: `UPDATE "payout_items"`, n: 3, cancel: cancel})
+
+	batch, err := repo.SubmitPayoutBatch(ctx, f.request(types.PayoutBestEffort, 10))
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, types.PayoutBatchProcessing, batch.Status)
+	assert.Equal(t, 2, batch.CompletedCount)
+	assert.Equal(t, types.PayoutItemPending, batch.Items[2].Status)
+	assert.Equal(t, "978", f.balance(t, f.source.ID))
+	assert.Equal(t, "0", f.balance(t, f.recipients[2].ID))
+
+	unfinished, err := repo.ListUnfinishedPayoutBatches(context.Background())
+	require.NoError(t, err)
+	require.Len(t, unfinished, 1)
+	assert.Equal(t, batch.ID, unfinished[0].ID)
+
+	// Resuming pays only what was not paid
+	batch, err = repo.ResumePayoutBatch(context.Background(), batch.ID)
+	require.NoError(t, err)
+	assert.Equal(t, types.PayoutBatchCompleted, batch.Status)
+	assert.Equal(t, 5, batch.CompletedCount)
+	assert.Equal(t, "945", f.balance(t, f.source.ID))
+	for _, recipient := range f.recipients {
+		assert.Equal(t, "10", f.balance(t, recipient.ID))
+	}
+
+	unfinished, err = repo.ListUnfinishedPayoutBatches(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, unfinished)
+
+	// A finished batch is left alone
+	again, err := repo.ResumePayoutBatch(context.Background(), batch.ID)
+	require.NoError(t, err)
+	assert.Equal(t, types.PayoutBatchCompleted, again.Status)
+	assert.Equal(t, "945", f.balance(t, f.source.ID))
+}
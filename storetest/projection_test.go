@@ -0,0 +1,130 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/otyang/waas-go/store"
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebuildProjections(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLiteDB(t)
+	repo := store.NewWalletRepository(db)
+
+	// Funded, partly held and frozen
+	held := newWallet(t, repo, "cus_1", "USD")
+	_, _, err := repo.CreditWallet(ctx, held.ID, types.CreditTransaction{
+		Amount:              decimal.NewFromInt(100),
+		TransactionCategory: types.CategoryDeposit,
+	})
+	require.NoError(t, err)
+	_, _, err = repo.ProcessLien(ctx, held.ID, types.LienOrUnlienRequest{
+		ID:     "lien_1",
+		Amount: decimal.NewFromInt(30),
+	}, "lien")
+	require.NoError(t, err)
+	_, err = repo.FreezeWallet(ctx, held.ID, types.FreezeRequest{Reason: "chargeback", InitiatedBy: "ops"})
+	require.NoError(t, err)
+
+	closed := newWallet(t, repo, "cus_2", "USD")
+	_, _, err = repo.CloseWallet(ctx, closed.ID, types.CloseOrOpenRequest{Reason: "customer request", InitiatedBy: "ops"})
+	require.NoError(t, err)
+
+	intact := newWallet(t, repo, "cus_3", "USD")
+
+	// Frozen and closed before status changes were recorded
+	legacy := newWallet(t, repo, "cus_4", "USD")
+	_, err = db.NewUpdate().
+		Model((*types.Wallet)(nil)).
+		Set("frozen = ?", true).
+		Set("freeze_reason = ?", "legacy hold").
+		Set("is_closed = ?", true).
+		Where("id = ?", legacy.ID).
+		Exec(ctx)
+	require.NoError(t, err)
+	legacy, err = repo.FindWalletByID(ctx, legacy.ID)
+	require.NoError(t, err)
+
+	// Corrupt the wallets table behind the repository's back
+	_, err = db.NewUpdate().
+		Model((*types.Wallet)(nil)).
+		Set("available_balance = ?", decimal.NewFromInt(999)).
+		Set("lien_balance = ?", decimal.Zero).
+		Set("frozen = ?", false).
+		Where("id = ?", held.ID).
+		Exec(ctx)
+	require.NoError(t, err)
+	_, err = db.NewUpdate().
+		Model((*types.Wallet)(nil)).
+		Set("is_closed = ?", false).
+		Where("id = ?", closed.ID).
+		Exec(ctx)
+	require.NoError(t, err)
+
+	corrupted := make(map[string]*types.Wallet)
+	for _, id := range []string{held.ID, closed.ID} {
+		corrupted[id], err = repo.FindWalletByID(ctx, id)
+		require.NoError(t, err)
+	}
+
+	// A dry run only reports
+	report, err := repo.RebuildProjections(ctx, store.ProjectionOptions{BatchSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.WalletsRebuilt)
+	assert.Empty(t, report.WalletsSkipped)
+	assert.Empty(t, report.Applied)
+	assert.ElementsMatch(t, []types.ProjectionDiff{
+		{WalletID: held.ID, Field: "available_balance", Current: "999", Rebuilt: "70"},
+		{WalletID: held.ID, Field: "lien_balance", Current: "0", Rebuilt: "30"},
+		{WalletID: held.ID, Field: "frozen", Current: "false", Rebuilt: "true"},
+		{WalletID: closed.ID, Field: "is_closed", Current: "false", Rebuilt: "true"},
+	}, report.Diffs)
+
+	wallet, err := repo.FindWalletByID(ctx, held.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "999", wallet.AvailableBalance.String())
+
+	_, err = repo.RebuildProjections(ctx, store.ProjectionOptions{AsOf: time.Now(), Apply: true})
+	assert.ErrorIs(t, err, store.ErrProjectionNotCurrent)
+
+	// Applying swaps the rebuilt state in and moves each wallet to a new version
+	report, err = repo.RebuildProjections(ctx, store.ProjectionOptions{Apply: true})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{held.ID, closed.ID}, report.Applied)
+
+	wallet, err = repo.FindWalletByID(ctx, held.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "70", wallet.AvailableBalance.String())
+	assert.Equal(t, "30", wallet.LienBalance.String())
+	assert.True(t, wallet.IsFrozen())
+	assert.Equal(t, "chargeback", wallet.FreezeReason)
+	assert.NotEqual(t, corrupted[held.ID].VersionId, wallet.VersionId)
+	assertLedger(t, repo, held.ID, 70, 30)
+
+	wallet, err = repo.FindWalletByID(ctx, closed.ID)
+	require.NoError(t, err)
+	assert.True(t, wallet.IsClosed)
+	assert.NotEqual(t, corrupted[closed.ID].VersionId, wallet.VersionId)
+
+	wallet, err = repo.FindWalletByID(ctx, intact.ID)
+	require.NoError(t, err)
+	assert.Equal(t, intact.VersionId, wallet.VersionId)
+
+	// Without status history the stored flags stand
+	wallet, err = repo.FindWalletByID(ctx, legacy.ID)
+	require.NoError(t, err)
+	assert.True(t, wallet.IsFrozen())
+	assert.Equal(t, "legacy hold", wallet.FreezeReason)
+	assert.True(t, wallet.IsClosed)
+	assert.Equal(t, legacy.VersionId, wallet.VersionId)
+
+	report, err = repo.RebuildProjections(ctx, store.ProjectionOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, report.Diffs)
+}
@@ -0,0 +1,92 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcileSettlementScopesToSource(t *testing.T) {
+	ctx := context.Background()
+	repo := newSQLiteRepository(t)
+	wallet := newWallet(t, repo, "cus_1", "USD")
+
+	// Two processors settle into the same wallet and currency
+	for _, ref := range []string{"acme:ch_1", "acme:ch_2", "globex:ch_1", "globex:po_7"} {
+		_, _, err := repo.CreditWallet(ctx, wallet.ID, types.CreditTransaction{
+			Amount:                decimal.NewFromInt(100),
+			ExternalTransactionID: ref,
+			TransactionCategory:   types.CategoryDeposit,
+		})
+		require.NoError(t, err)
+	}
+
+	now := time.Now().UTC()
+	records := []types.SettlementRecord{
+		{ExternalReference: "ch_1", Amount: decimal.NewFromInt(100), CurrencyCode: "USD", SettledAt: now, Line: 1},
+		{ExternalReference: "ch_3", Amount: decimal.NewFromInt(40), CurrencyCode: "USD", SettledAt: now, Line: 2},
+	}
+	opts := types.ReconciliationOptions{
+		DateTolerance: 24 * time.Hour,
+		PeriodStart:   now.Add(-time.Hour),
+		PeriodEnd:     now.Add(time.Hour),
+	}
+
+	opts.ReferencePrefix = types.SourceReferencePrefix("acme")
+	run, err := repo.ReconcileSettlement(ctx, "acme", records, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 2, run.InternalCount)
+	assert.Equal(t, 1, run.MatchedCount)
+	assert.Equal(t, 1, run.MissingInternalCount)
+	assert.Equal(t, 1, run.MissingExternalCount)
+	for _, item := range run.Exceptions() {
+		if item.Status == types.ReconMissingExternal {
+			assert.Equal(t, "acme:ch_2", item.ExternalReference)
+		}
+	}
+
+	// The other processor's file sees only its own rows
+	opts.ReferencePrefix = types.SourceReferencePrefix("globex")
+	run, err = repo.ReconcileSettlement(ctx, "globex", records[:1], opts)
+	require.NoError(t, err)
+	assert.Equal(t, 2, run.InternalCount)
+	assert.Equal(t, 1, run.MatchedCount)
+	assert.Equal(t, 1, run.MissingExternalCount)
+	assert.Equal(t, "globex:po_7", run.Exceptions()[0].ExternalReference)
+}
+
+func TestReconcileSettlementBareReferences(t *testing.T) {
+	ctx := context.Background()
+	repo := newSQLiteRepository(t)
+	wallet := newWallet(t, repo, "cus_1", "USD")
+
+	// A single processor settles rows booked under its own references
+	for _, ref := range []string{"ch_1", "ch_2"} {
+		_, _, err := repo.CreditWallet(ctx, wallet.ID, types.CreditTransaction{
+			Amount:                decimal.NewFromInt(100),
+			ExternalTransactionID: ref,
+			TransactionCategory:   types.CategoryDeposit,
+		})
+		require.NoError(t, err)
+	}
+
+	// A date-only file settles at midnight of the day the rows were booked
+	year, month, day := time.Now().UTC().Date()
+	settled := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	records := []types.SettlementRecord{
+		{ExternalReference: "ch_1", Amount: decimal.NewFromInt(100), CurrencyCode: "USD", SettledAt: settled, Line: 1},
+	}
+
+	run, err := repo.ReconcileSettlement(ctx, "acme", records, types.ReconciliationOptions{DateTolerance: 24 * time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, settled.Add(24*time.Hour-time.Nanosecond), run.PeriodEnd)
+	assert.Equal(t, 2, run.InternalCount)
+	assert.Equal(t, 1, run.MatchedCount)
+	require.Equal(t, 1, run.MissingExternalCount, "rows booked later on the last day are in the period")
+	assert.Equal(t, "ch_2", run.Exceptions()[0].ExternalReference)
+}
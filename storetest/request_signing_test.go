@@ -0,0 +1,125 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/otyang/waas-go/store"
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signedDebit returns a debit of amount from walletID signed by signer
+func signedDebit(t *testing.T, signer *types.RequestSigner, walletID string, amount int64) types.DebitTransaction {
+	t.Helper()
+
+	debit := types.DebitTransaction{
+		Amount:              decimal.NewFromInt(amount),
+		InitiatorID:         "ops",
+		TransactionCategory: types.CategoryTransfer,
+	}
+	sig, err := signer.Sign(func(sig *types.RequestSignature) []byte {
+		return debit.SigningPayload(walletID, sig)
+	})
+	require.NoError(t, err)
+	debit.Signature = sig
+	return debit
+}
+
+// signingRepository returns a repository enforcing signatures against its own
+// keys and nonces, and a funded wallet
+func signingRepository(t *testing.T) (*store.WalletRepository, *types.Wallet) {
+	t.Helper()
+	ctx := context.Background()
+	repo := newSQLiteRepository(t)
+	wallet := newWallet(t, repo, "cus_1", "USD")
+
+	_, _, err := repo.CreditWallet(ctx, wallet.ID, types.CreditTransaction{
+		Amount:              decimal.NewFromInt(100),
+		TransactionCategory: types.CategoryDeposit,
+	})
+	require.NoError(t, err)
+
+	_, err = repo.RegisterInitiatorKey(ctx, &types.InitiatorKey{
+		ID:          "key_1",
+		InitiatorID: "ops",
+		Algorithm:   types.SignatureHMACSHA256,
+		Secret:      []byte("secret-1"),
+	})
+	require.NoError(t, err)
+
+	repo.EnforceRequestSignatures(types.NewRequestVerifier(repo, repo))
+	return repo, wallet
+}
+
+func TestRequestNonceReplay(t *testing.T) {
+	ctx := context.Background()
+	repo, wallet := signingRepository(t)
+
+	debit := signedDebit(t, types.NewHMACSigner("key_1", []byte("secret-1")), wallet.ID, 10)
+	_, _, err := repo.DebitWallet(ctx, wallet.ID, debit)
+	require.NoError(t, err)
+
+	// The same signed request cannot be sent twice
+	_, _, err = repo.DebitWallet(ctx, wallet.ID, debit)
+	assert.ErrorIs(t, err, types.ErrReplayedRequest)
+	assertLedger(t, repo, wallet.ID, 90, 0)
+
+	// The (key, nonce) primary key refuses a second use of the nonce
+	err = repo.UseNonce(ctx, "key_1", debit.Signature.Nonce, time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, types.ErrReplayedRequest)
+	require.NoError(t, repo.UseNonce(ctx, "key_2", debit.Signature.Nonce, time.Now().Add(time.Hour)))
+
+	// Only nonces whose signatures can no longer pass the timestamp check are purged
+	require.NoError(t, repo.UseNonce(ctx, "key_1", "stale", time.Now().Add(-time.Minute)))
+	purged, err := repo.PurgeExpiredNonces(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	_, _, err = repo.DebitWallet(ctx, wallet.ID, debit)
+	assert.ErrorIs(t, err, types.ErrReplayedRequest)
+}
+
+func TestRotatedKeyGraceWindow(t *testing.T) {
+	ctx := context.Background()
+	repo, wallet := signingRepository(t)
+
+	_, err := repo.RotateInitiatorKey(ctx, &types.InitiatorKey{
+		ID:          "key_2",
+		InitiatorID: "ops",
+		Algorithm:   types.SignatureHMACSHA256,
+		Secret:      []byte("secret-2"),
+	}, time.Minute)
+	require.NoError(t, err)
+
+	previous, err := repo.FindInitiatorKey(ctx, "key_1")
+	require.NoError(t, err)
+	assert.False(t, previous.ValidUntil.IsZero())
+	assert.True(t, previous.ValidUntil.After(time.Now()))
+
+	oldSigner := types.NewHMACSigner("key_1", []byte("secret-1"))
+	newSigner := types.NewHMACSigner("key_2", []byte("secret-2"))
+
+	// Both keys are accepted during the overlap
+	_, _, err = repo.DebitWallet(ctx, wallet.ID, signedDebit(t, oldSigner, wallet.ID, 10))
+	require.NoError(t, err)
+	_, _, err = repo.DebitWallet(ctx, wallet.ID, signedDebit(t, newSigner, wallet.ID, 10))
+	require.NoError(t, err)
+
+	// Two minutes later the previous key has expired
+	later := func() time.Time { return time.Now().Add(2 * time.Minute) }
+	verifier := types.NewRequestVerifier(repo, repo)
+	verifier.Now = later
+	repo.EnforceRequestSignatures(verifier)
+	oldSigner.Now, newSigner.Now = later, later
+
+	_, _, err = repo.DebitWallet(ctx, wallet.ID, signedDebit(t, oldSigner, wallet.ID, 10))
+	assert.ErrorIs(t, err, types.ErrUnknownSigningKey)
+	_, _, err = repo.DebitWallet(ctx, wallet.ID, signedDebit(t, newSigner, wallet.ID, 10))
+	require.NoError(t, err)
+
+	assertLedger(t, repo, wallet.ID, 70, 0)
+}
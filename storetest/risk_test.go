@@ -0,0 +1,212 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/otyang/waas-go/store"
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reviewLarge holds movements of at least threshold in one direction for review
+type reviewLarge struct {
+	direction types.TransactionType
+	threshold decimal.Decimal
+}
+
+func (r reviewLarge) Name() string { return "review_large" }
+
+func (r reviewLarge) Evaluate(in types.RiskInput, _ []*types.TransactionHistory, _ time.Time) *types.RiskHit {
+	if in.Type != r.direction || in.Amount.LessThan(r.threshold) {
+		return nil
+	}
+	return &types.RiskHit{Outcome: types.RiskReview, Reason: "large movement"}
+}
+
+// heldMovement funds a wallet with 150, then moves 100 in direction under review
+func heldMovement(t *testing.T, direction types.TransactionType) (*store.WalletRepository, *types.Wallet, *types.TransactionHistory, *types.RiskDecision) {
+	t.Helper()
+	ctx := context.Background()
+	repo := newSQLiteRepository(t)
+	wallet := newWallet(t, repo, "cus_1", "USD")
+
+	_, _, err := repo.CreditWallet(ctx, wallet.ID, types.CreditTransaction{
+		Amount:              decimal.NewFromInt(150),
+		TransactionCategory: types.CategoryDeposit,
+	})
+	require.NoError(t, err)
+	repo.UseRiskEngine(types.NewRiskEngine(reviewLarge{direction: direction, threshold: decimal.NewFromInt(100)}))
+
+	var held *types.TransactionHistory
+	if direction == types.TypeCredit {
+		held, _, err = repo.CreditWallet(ctx, wallet.ID, types.CreditTransaction{
+			Amount:              decimal.NewFromInt(100),
+			TransactionCategory: types.CategoryDeposit,
+		})
+	} else {
+		held, _, err = repo.DebitWallet(ctx, wallet.ID, types.DebitTransaction{
+			Amount:              decimal.NewFromInt(100),
+			TransactionCategory: types.CategoryTransfer,
+		})
+	}
+	require.NoError(t, err)
+	assert.Equal(t, types.StatusPending, held.Status)
+
+	reviews, err := repo.ListOpenRiskReviews(ctx)
+	require.NoError(t, err)
+	require.Len(t, reviews, 1)
+	return repo, wallet, held, reviews[0]
+}
+
+// asReviewer returns ctx carrying a compliance officer
+func asReviewer(ctx context.Context) context.Context {
+	return types.WithPrincipal(ctx, &types.Principal{ID: "cmp_1", Roles: []types.Role{types.RoleCompliance}})
+}
+
+// assertLedger checks the wallet's balances and that replay, projection,
+// statement and hash chain all agree with them
+func assertLedger(t *testing.T, repo *store.WalletRepository, walletID string, available, lien int64) {
+	t.Helper()
+	ctx := context.Background()
+
+	wallet, err := repo.FindWalletByID(ctx, walletID)
+	require.NoError(t, err)
+	assert.Equal(t, decimal.NewFromInt(available).String(), wallet.AvailableBalance.String(), "available")
+	assert.Equal(t, decimal.NewFromInt(lien).String(), wallet.LienBalance.String(), "lien")
+
+	report, err := repo.CheckBalanceIntegrity(ctx, store.IntegrityOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, report.Issues)
+
+	projection, err := repo.ProjectWallet(ctx, walletID, time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, projection.Diff(wallet))
+
+	chain, err := repo.VerifyLedgerChain(ctx, walletID)
+	require.NoError(t, err)
+	assert.True(t, chain.Valid(), chain.Violations)
+}
+
+func TestRiskReviewHoldsCredit(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("held credit cannot be spent", func(t *testing.T) {
+		repo, wallet, _, _ := heldMovement(t, types.TypeCredit)
+		assertLedger(t, repo, wallet.ID, 150, 100)
+
+		_, _, err := repo.DebitWallet(ctx, wallet.ID, types.DebitTransaction{
+			Amount:              decimal.NewFromInt(200),
+			TransactionCategory: types.CategoryTransfer,
+		})
+		assert.ErrorIs(t, err, types.ErrInsufficientFunds)
+	})
+
+	t.Run("release frees the credit", func(t *testing.T) {
+		repo, wallet, held, review := heldMovement(t, types.TypeCredit)
+
+		_, err := repo.ResolveRiskReview(asReviewer(ctx), review.ID, true)
+		require.NoError(t, err)
+		assertLedger(t, repo, wallet.ID, 250, 0)
+
+		row, err := repo.FindTransactionByID(ctx, held.ID)
+		require.NoError(t, err)
+		assert.Equal(t, types.StatusCompleted, row.Status)
+	})
+
+	t.Run("rejection reverses the credit", func(t *testing.T) {
+		repo, wallet, held, review := heldMovement(t, types.TypeCredit)
+
+		_, err := repo.ResolveRiskReview(asReviewer(ctx), review.ID, false)
+		require.NoError(t, err)
+		assertLedger(t, repo, wallet.ID, 150, 0)
+
+		row, err := repo.FindTransactionByID(ctx, held.ID)
+		require.NoError(t, err)
+		assert.Equal(t, types.StatusReversed, row.Status)
+
+		statement, err := repo.GenerateStatement(ctx, wallet.ID, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), store.StatementOptions{})
+		require.NoError(t, err)
+		require.Len(t, statement.Transactions, 3)
+		assert.Equal(t, held.ID, statement.Transactions[2].ExternalRef)
+		assert.Equal(t, "150", statement.Summary.ClosingBalance.String())
+	})
+}
+
+func TestStatementWithHeldCredit(t *testing.T) {
+	ctx := context.Background()
+	repo, wallet, held, review := heldMovement(t, types.TypeCredit)
+	_, _, err := repo.DebitWallet(ctx, wallet.ID, types.DebitTransaction{
+		Amount:              decimal.NewFromInt(50),
+		TransactionCategory: types.CategoryTransfer,
+	})
+	require.NoError(t, err)
+
+	statement := func(from time.Time) *types.AccountStatement {
+		stmt, err := repo.GenerateStatement(ctx, wallet.ID, from, time.Now().Add(time.Hour), store.StatementOptions{})
+		require.NoError(t, err)
+		return stmt
+	}
+	balances := func(stmt *types.AccountStatement) []string {
+		var got []string
+		for _, line := range stmt.Transactions {
+			got = append(got, line.Balance)
+		}
+		return got
+	}
+
+	// The held credit is pending and not part of the balance
+	stmt := statement(time.Now().Add(-time.Hour))
+	assert.Equal(t, []string{"150.00", "100.00"}, balances(stmt))
+	require.Len(t, stmt.PendingTransactions, 1)
+	assert.Equal(t, held.ID, stmt.PendingTransactions[0].Reference)
+	assert.Equal(t, "100", stmt.Summary.ClosingBalance.String())
+	assert.Equal(t, "150", stmt.Summary.TotalCreditAmount.String())
+
+	// Released, it is booked when it was written and the later rows follow it
+	_, err = repo.ResolveRiskReview(asReviewer(ctx), review.ID, true)
+	require.NoError(t, err)
+	stmt = statement(time.Now().Add(-time.Hour))
+	assert.Equal(t, []string{"150.00", "250.00", "200.00"}, balances(stmt))
+	assert.Empty(t, stmt.PendingTransactions)
+	assert.Equal(t, "200", stmt.Summary.ClosingBalance.String())
+	assertLedger(t, repo, wallet.ID, 200, 0)
+
+	stmt = statement(time.Now().Add(time.Minute))
+	assert.Equal(t, "200", stmt.Summary.OpeningBalance.String())
+}
+
+func TestRiskReviewRejectsDebit(t *testing.T) {
+	ctx := context.Background()
+	repo, wallet, held, review := heldMovement(t, types.TypeDebit)
+	assertLedger(t, repo, wallet.ID, 50, 0)
+
+	_, err := repo.ResolveRiskReview(asReviewer(ctx), review.ID, false)
+	require.NoError(t, err)
+	assertLedger(t, repo, wallet.ID, 150, 0)
+
+	row, err := repo.FindTransactionByID(ctx, held.ID)
+	require.NoError(t, err)
+	assert.Equal(t, types.StatusReversed, row.Status)
+}
+
+func TestResolveRiskReviewAuthorization(t *testing.T) {
+	ctx := context.Background()
+	repo, wallet, _, review := heldMovement(t, types.TypeCredit)
+	repo.EnforceAuthorization(types.DefaultPolicy())
+
+	_, err := repo.ResolveRiskReview(ctx, review.ID, true)
+	assert.ErrorIs(t, err, types.ErrUnauthorizedAccess, "no reviewer")
+
+	owner := types.WithPrincipal(ctx, &types.Principal{ID: wallet.CustomerID, Roles: []types.Role{types.RoleCustomer}})
+	_, err = repo.ResolveRiskReview(owner, review.ID, true)
+	assert.ErrorIs(t, err, types.ErrUnauthorizedAccess, "customers cannot release their own reviews")
+
+	decision, err := repo.ResolveRiskReview(asReviewer(ctx), review.ID, true)
+	require.NoError(t, err)
+	assert.Equal(t, types.RiskReviewReleased, decision.ReviewStatus)
+	assert.Equal(t, "cmp_1", decision.ReviewerID)
+}
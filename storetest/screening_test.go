@@ -0,0 +1,247 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/otyang/waas-go/store"
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// watchList hits the names it lists
+type watchList map[string]types.ScreeningMatch
+
+func (w watchList) ScreenName(name string) []types.ScreeningMatch {
+	if match, ok := w[name]; ok {
+		return []types.ScreeningMatch{match}
+	}
+	return nil
+}
+
+// customerNames screens each customer under their ID in capitals
+type customerNames struct{}
+
+func (customerNames) FindScreeningSubject(_ context.Context, customerID string) (*types.ScreeningSubject, error) {
+	return &types.ScreeningSubject{CustomerID: customerID, Name: "NAME " + customerID}, nil
+}
+
+// listing returns a watch list hitting the customers given
+func listing(customerIDs ...string) watchList {
+	list := make(watchList)
+	for _, id := range customerIDs {
+		list["NAME "+id] = types.ScreeningMatch{
+			ListName: "OFAC-SDN", EntryID: "sdn_" + id, EntryName: "NAME " + id, MatchedName: "NAME " + id, Score: 1,
+		}
+	}
+	return list
+}
+
+// openCase returns the only open screening case, which must be for walletID
+func openCase(t *testing.T, repo *store.WalletRepository, walletID string) *types.ScreeningCase {
+	t.Helper()
+
+	cases, err := repo.ListScreeningCases(context.Background(), types.ScreeningCaseOpen)
+	require.NoError(t, err)
+	require.Len(t, cases, 1)
+	assert.Equal(t, walletID, cases[0].WalletID)
+	return cases[0]
+}
+
+// assertFrozen checks whether the wallet is frozen
+func assertFrozen(t *testing.T, repo *store.WalletRepository, walletID string, frozen bool) {
+	t.Helper()
+
+	wallet, err := repo.FindWalletByID(context.Background(), walletID)
+	require.NoError(t, err)
+	assert.Equal(t, frozen, wallet.IsFrozen())
+	if frozen {
+		assert.Equal(t, types.FreezeInitiatedByScreening, wallet.FreezeInitiatedBy)
+	}
+}
+
+func TestScreeningOnWalletCreation(t *testing.T) {
+	ctx := context.Background()
+	repo := newSQLiteRepository(t)
+	repo.UseScreening(listing("cus_hit"), customerNames{})
+
+	clear := newWallet(t, repo, "cus_1", "USD")
+	assertFrozen(t, repo, clear.ID, false)
+
+	hit := newWallet(t, repo, "cus_hit", "USD")
+	assertFrozen(t, repo, hit.ID, true)
+	screening := openCase(t, repo, hit.ID)
+	assert.Equal(t, types.ScreeningOnWalletCreation, screening.Trigger)
+	assert.Equal(t, "cus_hit", screening.CustomerID)
+
+	// A second wallet of the same customer gets its own case
+	other := newWallet(t, repo, "cus_hit", "EUR")
+	assertFrozen(t, repo, other.ID, true)
+	cases, err := repo.ListScreeningCases(ctx, types.ScreeningCaseOpen)
+	require.NoError(t, err)
+	assert.Len(t, cases, 2)
+	assertLedger(t, repo, hit.ID, 0, 0)
+}
+
+func TestScreeningOnTransfer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("hit blocks the transfer", func(t *testing.T) {
+		repo := newSQLiteRepository(t)
+		source := newWallet(t, repo, "cus_1", "USD")
+		dest := newWallet(t, repo, "cus_2", "USD")
+		_, _, err := repo.CreditWallet(ctx, source.ID, types.CreditTransaction{
+			Amount:              decimal.NewFromInt(100),
+			TransactionCategory: types.CategoryDeposit,
+		})
+		require.NoError(t, err)
+
+		// The receiver was listed after their wallet was opened
+		repo.UseScreening(listing("cus_2"), customerNames{})
+		_, _, err = repo.TransferFunds(ctx, source.ID, dest.ID, types.TransferRequest{
+			Amount:              decimal.NewFromInt(40),
+			TransactionCategory: types.CategoryTransfer,
+		})
+		assert.ErrorIs(t, err, types.ErrScreeningHit)
+
+		assertFrozen(t, repo, source.ID, false)
+		assertFrozen(t, repo, dest.ID, true)
+		assert.Equal(t, types.ScreeningOnTransfer, openCase(t, repo, dest.ID).Trigger)
+		assertLedger(t, repo, source.ID, 100, 0)
+		assertLedger(t, repo, dest.ID, 0, 0)
+
+		// The open case is reused rather than duplicated
+		_, _, err = repo.TransferFunds(ctx, source.ID, dest.ID, types.TransferRequest{
+			Amount:              decimal.NewFromInt(40),
+			TransactionCategory: types.CategoryTransfer,
+		})
+		assert.ErrorIs(t, err, types.ErrScreeningHit)
+		openCase(t, repo, dest.ID)
+	})
+
+	t.Run("hit outlives the rolled back unit of work", func(t *testing.T) {
+		repo := newSQLiteRepository(t)
+		source := newWallet(t, repo, "cus_1", "USD")
+		dest := newWallet(t, repo, "cus_2", "USD")
+		repo.UseScreening(listing("cus_2"), customerNames{})
+
+		err := repo.RunInTx(ctx, func(ctx context.Context, tx store.Repository) error {
+			_, _, err := tx.(*store.WalletRepository).TransferFunds(ctx, source.ID, dest.ID, types.TransferRequest{
+				Amount:              decimal.NewFromInt(40),
+				TransactionCategory: types.CategoryTransfer,
+			})
+			return err
+		})
+		assert.ErrorIs(t, err, types.ErrScreeningHit)
+
+		assertFrozen(t, repo, dest.ID, true)
+		openCase(t, repo, dest.ID)
+		assertLedger(t, repo, dest.ID, 0, 0)
+	})
+
+	t.Run("hit outlives the rolled back approval", func(t *testing.T) {
+		_, repo, source, dest := approvalRepository(t)
+		req := submitTransfer(t, repo, source, dest, 120)
+		repo.UseScreening(listing("cus_2"), customerNames{})
+
+		failed, err := repo.ApproveRequest(asOperator(ctx, "op_checker"), req.ID, "")
+		assert.ErrorIs(t, err, types.ErrScreeningHit)
+		assert.Equal(t, types.ApprovalFailed, failed.Status)
+
+		assertFrozen(t, repo, dest.ID, true)
+		openCase(t, repo, dest.ID)
+		assertLedger(t, repo, source.ID, 150, 0)
+		assertLedger(t, repo, dest.ID, 0, 0)
+	})
+}
+
+func TestCloseScreeningCase(t *testing.T) {
+	ctx := context.Background()
+
+	// screened returns a repository enforcing the default policy and a wallet
+	// frozen by a screening hit, with its case
+	screened := func(t *testing.T) (*store.WalletRepository, *types.Wallet, *types.ScreeningCase) {
+		repo := newSQLiteRepository(t)
+		repo.UseScreening(listing("cus_hit"), customerNames{})
+		wallet := newWallet(t, repo, "cus_hit", "USD")
+		repo.EnforceAuthorization(types.DefaultPolicy())
+		return repo, wallet, openCase(t, repo, wallet.ID)
+	}
+
+	t.Run("reviewer must be authorized", func(t *testing.T) {
+		repo, wallet, screening := screened(t)
+
+		_, err := repo.ClearScreeningCase(ctx, screening.ID, "")
+		assert.ErrorIs(t, err, types.ErrUnauthorizedAccess, "no reviewer")
+		_, err = repo.ConfirmScreeningCase(asOperator(ctx, "op_1"), screening.ID, "")
+		assert.ErrorIs(t, err, types.ErrUnauthorizedAccess, "operators cannot review screening")
+
+		owner := types.WithPrincipal(ctx, &types.Principal{ID: "cus_hit", Roles: []types.Role{types.RoleCustomer}})
+		_, err = repo.ClearScreeningCase(owner, screening.ID, "")
+		assert.ErrorIs(t, err, types.ErrUnauthorizedAccess, "customers cannot clear their own case")
+
+		openCase(t, repo, wallet.ID)
+		assertFrozen(t, repo, wallet.ID, true)
+	})
+
+	t.Run("confirm keeps the wallet frozen", func(t *testing.T) {
+		repo, wallet, screening := screened(t)
+
+		confirmed, err := repo.ConfirmScreeningCase(asReviewer(ctx), screening.ID, "true match")
+		require.NoError(t, err)
+		assert.Equal(t, types.ScreeningCaseConfirmed, confirmed.Status)
+		assert.Equal(t, "cmp_1", confirmed.ReviewerID)
+		assertFrozen(t, repo, wallet.ID, true)
+
+		_, err = repo.ClearScreeningCase(asReviewer(ctx), screening.ID, "")
+		assert.ErrorIs(t, err, types.ErrInvalidStatusTransition)
+	})
+
+	t.Run("clear unfreezes the wallet", func(t *testing.T) {
+		repo, wallet, screening := screened(t)
+
+		cleared, err := repo.ClearScreeningCase(asReviewer(ctx), screening.ID, "different date of birth")
+		require.NoError(t, err)
+		assert.Equal(t, types.ScreeningCaseCleared, cleared.Status)
+		assert.Equal(t, "cmp_1", cleared.ReviewerID)
+
+		stored, err := repo.FindScreeningCase(ctx, screening.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "cmp_1", stored.ReviewerID)
+		assert.Equal(t, "different date of birth", stored.ReviewNote)
+		assertFrozen(t, repo, wallet.ID, false)
+		assertLedger(t, repo, wallet.ID, 0, 0)
+
+		// The cleared entry no longer hits the customer
+		other := newWallet(t, repo, "cus_hit", "EUR")
+		assertFrozen(t, repo, other.ID, false)
+	})
+
+	t.Run("clear submits the unfreeze for approval", func(t *testing.T) {
+		repo, wallet, screening := screened(t)
+		repo.RequireApprovals(types.DefaultApprovalPolicy())
+
+		_, err := repo.ClearScreeningCase(asReviewer(ctx), screening.ID, "different date of birth")
+		require.NoError(t, err)
+		assertFrozen(t, repo, wallet.ID, true)
+
+		pending, err := repo.ListApprovalRequests(ctx, types.ApprovalPending)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, types.ApprovalUnfreeze, pending[0].Operation)
+		assert.Equal(t, wallet.ID, pending[0].WalletID)
+		assert.Equal(t, "cmp_1", pending[0].MakerID)
+
+		// The reviewer who cleared the case cannot also lift the freeze
+		_, err = repo.ApproveRequest(asReviewer(ctx), pending[0].ID, "")
+		assert.ErrorIs(t, err, types.ErrSelfApproval)
+
+		checker := types.WithPrincipal(ctx, &types.Principal{ID: "cmp_2", Roles: []types.Role{types.RoleCompliance}})
+		executed, err := repo.ApproveRequest(checker, pending[0].ID, "")
+		require.NoError(t, err)
+		assert.Equal(t, types.ApprovalExecuted, executed.Status)
+		assertFrozen(t, repo, wallet.ID, false)
+	})
+}
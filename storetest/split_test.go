@@ -0,0 +1,143 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/otyang/waas-go/store"
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checkoutSplit(seller, platform, delivery *types.Wallet) types.SplitRequest {
+	return types.SplitRequest{
+		Amount:                decimal.NewFromInt(100),
+		Fee:                   decimal.NewFromInt(1),
+		Description:           "order 42",
+		InitiatorID:           "cus_buyer",
+		ExternalTransactionID: "order_42",
+		TransactionCategory:   types.CategoryTransfer,
+		Shares: []types.SplitShare{
+			{DestinationWalletID: seller.ID, Percent: decimal.NewFromInt(90)},
+			{DestinationWalletID: platform.ID, Percent: decimal.NewFromInt(10)},
+			{DestinationWalletID: delivery.ID, Amount: decimal.NewFromInt(5)},
+		},
+	}
+}
+
+func TestSplitFunds(t *testing.T) {
+	ctx := context.Background()
+	repo := newSQLiteRepository(t)
+	repo.EnableOutbox(true)
+	f := newFundingFixture(t, repo, 150, 3)
+	seller, platform, delivery := f.recipients[0], f.recipients[1], f.recipients[2]
+
+	debit, credits, err := repo.SplitFunds(ctx, f.source.ID, checkoutSplit(seller, platform, delivery))
+	require.NoError(t, err)
+	assert.Equal(t, "49", f.balance(t, f.source.ID))
+	assert.Equal(t, "85.5", f.balance(t, seller.ID))
+	assert.Equal(t, "9.5", f.balance(t, platform.ID))
+	assert.Equal(t, "5", f.balance(t, delivery.ID))
+	require.Len(t, credits, 3)
+
+	// Every row of the split is found through its group
+	group, err := repo.ListTransactions(ctx, store.ListTransactionsParams{GroupID: debit.GroupID, SortBy: "amount", SortOrder: "desc"})
+	require.NoError(t, err)
+	require.Len(t, group.Transactions, 4)
+	assert.Equal(t, debit.ID, group.Transactions[0].ID)
+	assert.Equal(t, types.TypeDebit, group.Transactions[0].Type)
+	for _, row := range group.Transactions {
+		assert.Equal(t, types.StatusCompleted, row.Status)
+		assert.Equal(t, "order_42", row.ExternalReference)
+	}
+
+	// The grouped rows still verify against the ledger chain
+	verification, err := repo.VerifyLedgerChain(ctx, seller.ID)
+	require.NoError(t, err)
+	assert.True(t, verification.Valid(), verification.Violations)
+
+	events, err := repo.ListUnpublishedEvents(ctx, 100)
+	require.NoError(t, err)
+	split := 0
+	for _, event := range events {
+		if event.Type == types.EventSplitCompleted {
+			split++
+		}
+	}
+	assert.Equal(t, 4, split)
+}
+
+func TestSplitFundsIsAtomic(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("an unpayable share pays nobody", func(t *testing.T) {
+		repo := newSQLiteRepository(t)
+		f := newFundingFixture(t, repo, 150, 3)
+		delivery := f.recipients[2]
+		delivery.IsClosed = true
+		_, err := repo.UpdateWallet(ctx, delivery)
+		require.NoError(t, err)
+
+		_, _, err = repo.SplitFunds(ctx, f.source.ID, checkoutSplit(f.recipients[0], f.recipients[1], delivery))
+		assert.ErrorIs(t, err, types.ErrWalletClosed)
+		assert.Equal(t, "150", f.balance(t, f.source.ID))
+		assert.Equal(t, "0", f.balance(t, f.recipients[0].ID))
+
+		rows, err := repo.ListTransactions(ctx, store.ListTransactionsParams{})
+		require.NoError(t, err)
+		assert.Empty(t, rows.Transactions)
+	})
+
+	t.Run("a missing wallet", func(t *testing.T) {
+		repo := newSQLiteRepository(t)
+		f := newFundingFixture(t, repo, 150, 2)
+
+		req := checkoutSplit(f.recipients[0], f.recipients[1], &types.Wallet{ID: "wt_missing"})
+		_, _, err := repo.SplitFunds(ctx, f.source.ID, req)
+		assert.ErrorIs(t, err, store.ErrWalletNotFound)
+		assert.Equal(t, "150", f.balance(t, f.source.ID))
+	})
+
+	t.Run("a limit on one credit", func(t *testing.T) {
+		repo := newSQLiteRepository(t)
+		f := newFundingFixture(t, repo, 150, 3)
+		repo.EnforceLimits(types.NewLimitSchedule(&types.LimitRule{
+			Tier:         types.KYCTierNone,
+			CurrencyCode: "USD",
+			MaxBalance:   decimal.NewFromInt(50),
+		}))
+
+		_, _, err := repo.SplitFunds(ctx, f.source.ID, checkoutSplit(f.recipients[0], f.recipients[1], f.recipients[2]))
+		assert.ErrorIs(t, err, types.ErrLimitExceeded)
+		assert.Equal(t, "150", f.balance(t, f.source.ID))
+		assert.Equal(t, "0", f.balance(t, f.recipients[1].ID))
+	})
+}
+
+func TestSplitFundsPrecision(t *testing.T) {
+	ctx := context.Background()
+	repo := newSQLiteRepository(t)
+	_, err := repo.CreateCurrency(ctx, &types.CurrencyInfo{Code: "USD", Precision: 0})
+	require.NoError(t, err)
+	f := newFundingFixture(t, repo, 100, 3)
+
+	// The catalogue has USD without decimals, so 10 splits 4/3/3
+	req := types.SplitRequest{
+		Amount:              decimal.NewFromInt(10),
+		TransactionCategory: types.CategoryTransfer,
+		Remainder:           types.RemainderToFirst,
+	}
+	for _, recipient := range f.recipients {
+		req.Shares = append(req.Shares, types.SplitShare{DestinationWalletID: recipient.ID, Percent: decimal.RequireFromString("33.3333")})
+	}
+	req.Shares[2].Percent = decimal.RequireFromString("33.3334")
+
+	_, credits, err := repo.SplitFunds(ctx, f.source.ID, req)
+	require.NoError(t, err)
+	assert.Equal(t, "4", credits[0].Amount.String())
+	assert.Equal(t, "3", credits[1].Amount.String())
+	assert.Equal(t, "3", credits[2].Amount.String())
+	assert.Equal(t, "90", f.balance(t, f.source.ID))
+}
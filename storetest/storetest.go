@@ -0,0 +1,529 @@
+// Package storetest is a conformance suite for store.Repository
+// implementations. Each backend runs the same tests so code written against
+// the interfaces behaves the same in unit tests and in production.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/otyang/waas-go/store"
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns an empty repository for a single test
+type Factory func(t *testing.T) store.Repository
+
+// Run runs the conformance suite against repositories from newRepo
+func Run(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, repo store.Repository)
+	}{
+		{"Wallets", testWallets},
+		{"OptimisticVersioning", testOptimisticVersioning},
+		{"UnitOfWork", testUnitOfWork},
+		{"ConcurrentUpdates", testConcurrentUpdates},
+		{"ConcurrentCreates", testConcurrentCreates},
+		{"Transactions", testTransactions},
+		{"ListTransactions", testListTransactions},
+		{"Liens", testLiens},
+		{"Currencies", testCurrencies},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newRepo(t))
+		})
+	}
+}
+
+func newWallet(t *testing.T, repo store.Repository, customerID, currency string) *types.Wallet {
+	t.Helper()
+
+	wallet, err := types.NewWallet(customerID, currency)
+	require.NoError(t, err)
+	wallet, err = repo.CreateWallet(context.Background(), wallet)
+	require.NoError(t, err)
+	return wallet
+}
+
+func newTransaction(id, walletID string, amount int64) *types.TransactionHistory {
+	return &types.TransactionHistory{
+		ID:                id,
+		WalletID:          walletID,
+		CurrencyCode:      "USD",
+		InitiatorID:       "ops",
+		ExternalReference: "ref_" + id,
+		Category:          types.CategoryDeposit,
+		Description:       "deposit",
+		Amount:            decimal.NewFromInt(amount),
+		Fee:               decimal.Zero,
+		Type:              types.TypeCredit,
+		BalanceBefore:     decimal.Zero,
+		BalanceAfter:      decimal.NewFromInt(amount),
+		Status:            types.StatusPending,
+	}
+}
+
+func testWallets(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+
+	wallet := newWallet(t, repo, "cus_1", "usd")
+	assert.Equal(t, "USD", wallet.CurrencyCode)
+
+	found, err := repo.FindWalletByID(ctx, wallet.ID)
+	require.NoError(t, err)
+	assert.Equal(t, wallet.ID, found.ID)
+	assert.Equal(t, "cus_1", found.CustomerID)
+	assert.Equal(t, wallet.VersionId, found.VersionId)
+	assert.True(t, found.AvailableBalance.IsZero())
+
+	found, err = repo.FindWalletByCurrency(ctx, "cus_1", "usd")
+	require.NoError(t, err)
+	assert.Equal(t, wallet.ID, found.ID)
+
+	// A second wallet in the same currency returns the first
+	again := newWallet(t, repo, "cus_1", "USD")
+	assert.Equal(t, wallet.ID, again.ID)
+
+	// Missing fields are filled in
+	created, err := repo.CreateWallet(ctx, &types.Wallet{CustomerID: "cus_2", CurrencyCode: "EUR"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+	assert.NotEmpty(t, created.VersionId)
+	assert.False(t, created.CreatedAt.IsZero())
+
+	_, err = repo.FindWalletByID(ctx, "wt_missing")
+	assert.ErrorIs(t, err, store.ErrWalletNotFound)
+	_, err = repo.FindWalletByCurrency(ctx, "cus_1", "GBP")
+	assert.ErrorIs(t, err, store.ErrWalletNotFound)
+
+	// Changing a returned wallet does not change the stored one
+	found.AvailableBalance = decimal.NewFromInt(500)
+	found, err = repo.FindWalletByID(ctx, wallet.ID)
+	require.NoError(t, err)
+	assert.True(t, found.AvailableBalance.IsZero())
+}
+
+func testOptimisticVersioning(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	wallet := newWallet(t, repo, "cus_1", "USD")
+
+	first, err := repo.FindWalletByID(ctx, wallet.ID)
+	require.NoError(t, err)
+	second, err := repo.FindWalletByID(ctx, wallet.ID)
+	require.NoError(t, err)
+
+	version := first.VersionId
+	first.AvailableBalance = decimal.NewFromInt(10)
+	_, err = repo.UpdateWallet(ctx, first)
+	require.NoError(t, err)
+	assert.NotEqual(t, version, first.VersionId)
+
+	// The second copy is stale
+	second.AvailableBalance = decimal.NewFromInt(20)
+	_, err = repo.UpdateWallet(ctx, second)
+	assert.ErrorIs(t, err, store.ErrConcurrentModification)
+
+	stored, err := repo.FindWalletByID(ctx, wallet.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "10", stored.AvailableBalance.String())
+	assert.Equal(t, first.VersionId, stored.VersionId)
+
+	// So is a wallet that was never stored
+	_, err = repo.UpdateWallet(ctx, &types.Wallet{ID: "wt_missing", VersionId: "ver_1"})
+	assert.ErrorIs(t, err, store.ErrConcurrentModification)
+}
+
+func testUnitOfWork(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	a := newWallet(t, repo, "cus_1", "USD")
+	b := newWallet(t, repo, "cus_2", "USD")
+
+	a.AvailableBalance = decimal.NewFromInt(100)
+	a, err := repo.UpdateWallet(ctx, a)
+	require.NoError(t, err)
+
+	move := func(amount int64, fail error) error {
+		return repo.RunInTx(ctx, func(ctx context.Context, tx store.Repository) error {
+			from, err := tx.FindWalletByID(ctx, a.ID)
+			if err != nil {
+				return err
+			}
+			to, err := tx.FindWalletByID(ctx, b.ID)
+			if err != nil {
+				return err
+			}
+			from.AvailableBalance = from.AvailableBalance.Sub(decimal.NewFromInt(amount))
+			to.AvailableBalance = to.AvailableBalance.Add(decimal.NewFromInt(amount))
+
+			if _, err := tx.UpdateWallet(ctx, from); err != nil {
+				return err
+			}
+			if _, err := tx.CreateTransaction(ctx, newTransaction(fmt.Sprintf("tx_move_%d", amount), a.ID, amount)); err != nil {
+				return err
+			}
+			if fail != nil {
+				return fail
+			}
+			_, err = tx.UpdateWallet(ctx, to)
+			return err
+		})
+	}
+	balances := func() (string, string) {
+		from, err := repo.FindWalletByID(ctx, a.ID)
+		require.NoError(t, err)
+		to, err := repo.FindWalletByID(ctx, b.ID)
+		require.NoError(t, err)
+		return from.AvailableBalance.String(), to.AvailableBalance.String()
+	}
+
+	require.NoError(t, move(30, nil))
+	from, to := balances()
+	assert.Equal(t, "70", from)
+	assert.Equal(t, "30", to)
+
+	// A failure part way through leaves nothing behind
+	boom := errors.New("boom")
+	assert.ErrorIs(t, move(5, boom), boom)
+	from, to = balances()
+	assert.Equal(t, "70", from)
+	assert.Equal(t, "30", to)
+	_, err = repo.FindTransactionByID(ctx, "tx_move_5")
+	assert.ErrorIs(t, err, store.ErrTransactionNotFound)
+
+	// A failed inner unit discards only its own work
+	err = repo.RunInTx(ctx, func(ctx context.Context, tx store.Repository) error {
+		if _, err := tx.CreateTransaction(ctx, newTransaction("tx_outer", a.ID, 1)); err != nil {
+			return err
+		}
+		inner := tx.RunInTx(ctx, func(ctx context.Context, tx store.Repository) error {
+			if _, err := tx.CreateTransaction(ctx, newTransaction("tx_inner", a.ID, 1)); err != nil {
+				return err
+			}
+			return boom
+		})
+		assert.ErrorIs(t, inner, boom)
+		return nil
+	})
+	require.NoError(t, err)
+
+	_, err = repo.FindTransactionByID(ctx, "tx_outer")
+	assert.NoError(t, err)
+	_, err = repo.FindTransactionByID(ctx, "tx_inner")
+	assert.ErrorIs(t, err, store.ErrTransactionNotFound)
+}
+
+func testConcurrentUpdates(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	wallet := newWallet(t, repo, "cus_1", "USD")
+
+	// Each writer retries on conflict, so every increment lands exactly once
+	const writers, increments = 4, 5
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < increments; {
+				err := repo.RunInTx(ctx, func(ctx context.Context, tx store.Repository) error {
+					current, err := tx.FindWalletByID(ctx, wallet.ID)
+					if err != nil {
+						return err
+					}
+					current.AvailableBalance = current.AvailableBalance.Add(decimal.NewFromInt(1))
+					_, err = tx.UpdateWallet(ctx, current)
+					return err
+				})
+				if errors.Is(err, store.ErrConcurrentModification) {
+					continue
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+				n++
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	stored, err := repo.FindWalletByID(ctx, wallet.ID)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprint(writers*increments), stored.AvailableBalance.String())
+}
+
+func testConcurrentCreates(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+
+	// Every caller gets back the one wallet that was stored
+	const callers = 8
+	var wg sync.WaitGroup
+	candidates := make([]*types.Wallet, callers)
+	results := make([]*types.Wallet, callers)
+	errs := make([]error, callers)
+	for i := range candidates {
+		wallet, err := types.NewWallet("cus_1", "usd")
+		require.NoError(t, err)
+		candidates[i] = wallet
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = repo.CreateWallet(ctx, candidates[i])
+		}(i)
+	}
+	wg.Wait()
+
+	stored, err := repo.FindWalletByCurrency(ctx, "cus_1", "USD")
+	require.NoError(t, err)
+	for i := range results {
+		require.NoError(t, errs[i])
+		assert.Equal(t, stored.ID, results[i].ID)
+		assert.Equal(t, stored.VersionId, results[i].VersionId)
+	}
+
+	// Only the winning candidate was inserted
+	inserted := 0
+	for _, candidate := range candidates {
+		_, err := repo.FindWalletByID(ctx, candidate.ID)
+		if err == nil {
+			inserted++
+			continue
+		}
+		assert.ErrorIs(t, err, store.ErrWalletNotFound)
+	}
+	assert.Equal(t, 1, inserted)
+
+	// Different customers and currencies do not collide
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = repo.CreateWallet(ctx, &types.Wallet{
+				CustomerID:   fmt.Sprintf("cus_%d", i%2+2),
+				CurrencyCode: []string{"EUR", "GBP"}[i/2%2],
+			})
+		}(i)
+	}
+	wg.Wait()
+	ids := map[string]bool{}
+	for i := range results {
+		require.NoError(t, errs[i])
+		ids[results[i].ID] = true
+	}
+	assert.Len(t, ids, 4)
+
+	// Reusing a stored wallet's ID for another customer fails rather than
+	// returning a wallet that was never stored
+	_, err = repo.CreateWallet(ctx, &types.Wallet{ID: stored.ID, CustomerID: "cus_9", CurrencyCode: "USD"})
+	assert.Error(t, err)
+	_, err = repo.FindWalletByCurrency(ctx, "cus_9", "USD")
+	assert.ErrorIs(t, err, store.ErrWalletNotFound)
+
+	_, err = repo.CreateWallet(ctx, &types.Wallet{CurrencyCode: "USD"})
+	assert.ErrorIs(t, err, store.ErrCustomerIDRequired)
+	_, err = repo.CreateWallet(ctx, &types.Wallet{CustomerID: "cus_1", CurrencyCode: " "})
+	assert.ErrorIs(t, err, store.ErrInvalidCurrencyCode)
+}
+
+func testTransactions(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+
+	first, err := repo.CreateTransaction(ctx, newTransaction("tx_1", "wt_1", 100))
+	require.NoError(t, err)
+	assert.False(t, first.CreatedAt.IsZero())
+	assert.Empty(t, first.PrevHash)
+	assert.NotEmpty(t, first.Hash)
+
+	second, err := repo.CreateTransaction(ctx, newTransaction("tx_2", "wt_1", 50))
+	require.NoError(t, err)
+	assert.Equal(t, first.Hash, second.PrevHash)
+
+	// Each wallet has its own chain
+	other, err := repo.CreateTransaction(ctx, newTransaction("tx_3", "wt_2", 50))
+	require.NoError(t, err)
+	assert.Empty(t, other.PrevHash)
+
+	_, err = repo.CreateTransaction(ctx, newTransaction("tx_1", "wt_1", 100))
+	assert.Error(t, err, "duplicate ID")
+
+	invalid := newTransaction("tx_4", "wt_1", 0)
+	_, err = repo.CreateTransaction(ctx, invalid)
+	assert.Error(t, err, "zero amount")
+	invalid = newTransaction("tx_4", "", 10)
+	_, err = repo.CreateTransaction(ctx, invalid)
+	assert.Error(t, err, "missing wallet")
+
+	found, err := repo.FindTransactionByID(ctx, "tx_1")
+	require.NoError(t, err)
+	assert.Equal(t, first.Hash, found.Hash)
+	assert.True(t, found.Amount.Equal(decimal.NewFromInt(100)))
+
+	_, err = repo.FindTransactionByID(ctx, "tx_missing")
+	assert.ErrorIs(t, err, store.ErrTransactionNotFound)
+
+	// Pending moves to completed, which is final; hashes never change
+	found.Status = types.StatusCompleted
+	found.Hash = "tampered"
+	updated, err := repo.UpdateTransaction(ctx, found)
+	require.NoError(t, err)
+	assert.Equal(t, first.Hash, updated.Hash)
+
+	found, err = repo.FindTransactionByID(ctx, "tx_1")
+	require.NoError(t, err)
+	assert.Equal(t, types.StatusCompleted, found.Status)
+	assert.Equal(t, first.Hash, found.Hash)
+
+	found.Status = types.StatusFailed
+	_, err = repo.UpdateTransaction(ctx, found)
+	assert.ErrorIs(t, err, types.ErrTransactionCompleted)
+
+	second.Status = types.StatusPending
+	_, err = repo.UpdateTransaction(ctx, second)
+	assert.ErrorIs(t, err, types.ErrInvalidStatusTransition)
+}
+
+func testListTransactions(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+
+	for i, walletID := range []string{"wt_1", "wt_1", "wt_2", "wt_1"} {
+		_, err := repo.CreateTransaction(ctx, newTransaction(fmt.Sprintf("tx_%d", i+1), walletID, int64(10*(i+1))))
+		require.NoError(t, err)
+	}
+	failed := newTransaction("tx_5", "wt_1", 50)
+	failed.Status = types.StatusFailed
+	failed.GroupID = "grp_1"
+	_, err := repo.CreateTransaction(ctx, failed)
+	require.NoError(t, err)
+
+	ids := func(result *store.ListTransactionsResult) []string {
+		var out []string
+		for _, tx := range result.Transactions {
+			out = append(out, tx.ID)
+		}
+		return out
+	}
+
+	params := store.ListTransactionsParams{WalletID: "wt_1", Status: types.StatusPending, PageSize: 2, SortBy: "id", SortOrder: "asc"}
+	page, err := repo.ListTransactions(ctx, params)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tx_1", "tx_2"}, ids(page))
+	assert.True(t, page.HasNext)
+	assert.Equal(t, "tx_2", page.NextCursor)
+
+	params.Cursor = page.NextCursor
+	page, err = repo.ListTransactions(ctx, params)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tx_4"}, ids(page))
+	assert.False(t, page.HasNext)
+
+	page, err = repo.ListTransactions(ctx, store.ListTransactionsParams{WalletID: "wt_1", SortBy: "id", SortOrder: "desc"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tx_5", "tx_4", "tx_2", "tx_1"}, ids(page))
+
+	page, err = repo.ListTransactions(ctx, store.ListTransactionsParams{SortBy: "amount", SortOrder: "desc", PageSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tx_5", "tx_4"}, ids(page))
+	page, err = repo.ListTransactions(ctx, store.ListTransactionsParams{SortBy: "amount", SortOrder: "desc", PageSize: 2, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tx_3", "tx_2"}, ids(page))
+
+	page, err = repo.ListTransactions(ctx, store.ListTransactionsParams{GroupID: "grp_1"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tx_5"}, ids(page))
+	assert.Equal(t, "grp_1", page.Transactions[0].GroupID)
+}
+
+func testLiens(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	wallet := newWallet(t, repo, "cus_1", "USD")
+
+	wallet.AvailableBalance = decimal.NewFromInt(100)
+	lien, err := wallet.AddLien(types.LienOrUnlienRequest{Amount: decimal.NewFromInt(40), Description: "hold"})
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateLien(ctx, lien))
+
+	release, err := wallet.ReleaseLien(types.LienOrUnlienRequest{ID: lien.ID, Amount: decimal.NewFromInt(15)})
+	require.NoError(t, err)
+	require.NoError(t, repo.RecordLienRelease(ctx, release))
+
+	// Releases must name a lien of the same wallet
+	missing := *release
+	missing.ID = "lien_missing"
+	assert.ErrorIs(t, repo.RecordLienRelease(ctx, &missing), types.ErrLienNotFound)
+	foreign := *release
+	foreign.WalletID = "wt_other"
+	assert.ErrorIs(t, repo.RecordLienRelease(ctx, &foreign), types.ErrLienNotFound)
+
+	liens, err := repo.ListLiens(ctx, wallet.ID)
+	require.NoError(t, err)
+	require.Len(t, liens, 2)
+	assert.Equal(t, lien.ID, liens[0].ID)
+	assert.False(t, liens[0].IsRelease())
+	assert.False(t, liens[0].ReleasedAt.IsZero())
+	assert.True(t, liens[1].IsRelease())
+	assert.Equal(t, lien.ID, liens[1].LienID)
+	assert.True(t, liens[1].Amount.Equal(decimal.NewFromInt(15)))
+
+	projection := types.ProjectWallet(wallet.ID, nil, liens, nil, time.Time{})
+	assert.Equal(t, "25", projection.LienBalance.String())
+
+	liens, err = repo.ListLiens(ctx, "wt_other")
+	require.NoError(t, err)
+	assert.Empty(t, liens)
+}
+
+func testCurrencies(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+
+	usd, err := repo.CreateCurrency(ctx, &types.CurrencyInfo{Code: "usd", Name: "US Dollar", Precision: 2, IsFiat: true})
+	require.NoError(t, err)
+	assert.Equal(t, "USD", usd.Code)
+	_, err = repo.CreateCurrency(ctx, &types.CurrencyInfo{Code: "EUR", Name: "Euro", Precision: 2, IsFiat: true})
+	require.NoError(t, err)
+
+	_, err = repo.CreateCurrency(ctx, &types.CurrencyInfo{Code: "USD", Name: "Duplicate"})
+	assert.ErrorIs(t, err, store.ErrCurrencyExists)
+	_, err = repo.CreateCurrency(ctx, &types.CurrencyInfo{Code: " "})
+	assert.ErrorIs(t, err, store.ErrInvalidCurrencyCode)
+
+	found, err := repo.FindCurrency(ctx, "usd")
+	require.NoError(t, err)
+	assert.Equal(t, "US Dollar", found.Name)
+	assert.Equal(t, 2, found.Precision)
+
+	_, err = repo.FindCurrency(ctx, "GBP")
+	assert.ErrorIs(t, err, types.ErrCurrencyNotFound)
+
+	found.Disabled = true
+	found.FeeWithdrawal = decimal.RequireFromString("1.5")
+	_, err = repo.UpdateCurrency(ctx, found)
+	require.NoError(t, err)
+	found, err = repo.FindCurrency(ctx, "USD")
+	require.NoError(t, err)
+	assert.True(t, found.Disabled)
+	assert.True(t, found.FeeWithdrawal.Equal(decimal.RequireFromString("1.5")))
+
+	_, err = repo.UpdateCurrency(ctx, &types.CurrencyInfo{Code: "GBP"})
+	assert.ErrorIs(t, err, types.ErrCurrencyNotFound)
+
+	currencies, err := repo.ListCurrencies(ctx)
+	require.NoError(t, err)
+	require.Len(t, currencies, 2)
+	assert.Equal(t, "EUR", currencies[0].Code)
+	assert.Equal(t, "USD", currencies[1].Code)
+}
@@ -0,0 +1,53 @@
+package storetest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/otyang/waas-go/migrations"
+	"github.com/otyang/waas-go/store"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+)
+
+func TestMemoryStore(t *testing.T) {
+	Run(t, func(t *testing.T) store.Repository {
+		return store.NewMemoryStore()
+	})
+}
+
+func TestWalletRepositorySQLite(t *testing.T) {
+	Run(t, func(t *testing.T) store.Repository {
+		return newSQLiteRepository(t)
+	})
+}
+
+// newSQLiteRepository returns a repository on an in-memory SQLite database
+// private to the test, with every migration applied
+func newSQLiteRepository(t *testing.T) *store.WalletRepository {
+	return store.NewWalletRepository(newSQLiteDB(t))
+}
+
+// newSQLiteDB opens an in-memory SQLite database private to the test and
+// applies every migration
+func newSQLiteDB(t *testing.T) *bun.DB {
+	t.Helper()
+
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	sqldb, err := sql.Open(sqliteshim.ShimName, fmt.Sprintf("file:%s?mode=memory&cache=shared", name))
+	require.NoError(t, err)
+	sqldb.SetMaxOpenConns(1)
+
+	db := bun.NewDB(sqldb, sqlitedialect.New())
+	t.Cleanup(func() { db.Close() })
+
+	_, err = migrations.Up(context.Background(), db)
+	require.NoError(t, err)
+
+	return db
+}
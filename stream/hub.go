@@ -0,0 +1,171 @@
+// Package stream pushes committed wallet changes to connected clients over
+// Server-Sent Events. A Hub receives events from the outbox relay and fans
+// them out to subscribers by customer; a Handler serves a customer's stream,
+// replaying missed events when a client resumes with its last-seen event ID.
+package stream
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+)
+
+// DefaultBuffer is the number of messages a subscriber may fall behind by
+const DefaultBuffer = 64
+
+// Message is a wallet change sent to clients
+type Message struct {
+	Sequence         int64                       `json:"sequence"`               // Outbox publish sequence, used as the resume ID
+	EventID          string                      `json:"eventId"`                // Unique event ID
+	Type             types.EventType             `json:"type"`                   // What happened
+	WalletID         string                      `json:"walletId"`               // Wallet that changed
+	CurrencyCode     string                      `json:"currencyCode"`           // Wallet currency
+	AvailableBalance decimal.Decimal             `json:"availableBalance"`       // Balance after the change
+	LienBalance      decimal.Decimal             `json:"lienBalance"`            // Held funds after the change
+	Frozen           bool                        `json:"frozen"`                 // Whether debits are blocked
+	Closed           bool                        `json:"closed"`                 // Whether the wallet is closed
+	Transactions     []*types.TransactionHistory `json:"transactions,omitempty"` // Rows the change wrote to this wallet
+	OccurredAt       time.Time                   `json:"occurredAt"`             // When the change happened
+
+	customerID string
+}
+
+// NewMessage converts an outbox event into the message clients receive. Only
+// the transaction rows of the event's own wallet are included.
+func NewMessage(event *types.OutboxEvent) (*Message, error) {
+	payload, err := event.DecodeWalletEvent()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{
+		Sequence:   event.PublishSequence,
+		EventID:    event.EventID,
+		Type:       event.Type,
+		WalletID:   event.AggregateID,
+		OccurredAt: event.OccurredAt,
+	}
+	if wallet := payload.Wallet; wallet != nil {
+		msg.customerID = wallet.CustomerID
+		msg.CurrencyCode = wallet.CurrencyCode
+		msg.AvailableBalance = wallet.AvailableBalance
+		msg.LienBalance = wallet.LienBalance
+		msg.Frozen = wallet.Frozen
+		msg.Closed = wallet.IsClosed
+	}
+	for _, tx := range payload.Transactions {
+		if tx != nil && tx.WalletID == event.AggregateID {
+			msg.Transactions = append(msg.Transactions, tx)
+		}
+	}
+
+	return msg, nil
+}
+
+// Hub fans committed events out to live subscribers. It implements
+// outbox.Publisher; combine it with other publishers using outbox.Fanout.
+type Hub struct {
+	mu     sync.Mutex
+	subs   map[string]map[*Subscription]struct{}
+	Buffer int // Per-subscriber buffer (DefaultBuffer when zero)
+}
+
+// NewHub creates a Hub with no subscribers
+func NewHub() *Hub {
+	return &Hub{subs: make(map[string]map[*Subscription]struct{})}
+}
+
+// Subscription receives a customer's messages until it is closed or lags
+type Subscription struct {
+	hub        *Hub
+	customerID string
+	messages   chan *Message
+	lagged     chan struct{}
+	once       sync.Once
+}
+
+// Subscribe starts receiving messages for a customer's wallets
+func (h *Hub) Subscribe(customerID string) *Subscription {
+	buffer := h.Buffer
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+
+	sub := &Subscription{
+		hub:        h,
+		customerID: customerID,
+		messages:   make(chan *Message, buffer),
+		lagged:     make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[customerID] == nil {
+		h.subs[customerID] = make(map[*Subscription]struct{})
+	}
+	h.subs[customerID][sub] = struct{}{}
+	return sub
+}
+
+// Publish delivers an event to the owner's subscribers. It never blocks: a
+// subscriber whose buffer is full is dropped and its Lagged channel closed,
+// so one slow client cannot hold up the relay or other clients.
+func (h *Hub) Publish(_ context.Context, event *types.OutboxEvent) error {
+	msg, err := NewMessage(event)
+	if err != nil {
+		return err
+	}
+	if msg.customerID == "" {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs[msg.customerID] {
+		select {
+		case sub.messages <- msg:
+		default:
+			h.drop(sub)
+			close(sub.lagged)
+		}
+	}
+	return nil
+}
+
+// Subscribers returns the number of live subscriptions for a customer
+func (h *Hub) Subscribers(customerID string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs[customerID])
+}
+
+// drop removes a subscription; the caller holds h.mu
+func (h *Hub) drop(sub *Subscription) {
+	delete(h.subs[sub.customerID], sub)
+	if len(h.subs[sub.customerID]) == 0 {
+		delete(h.subs, sub.customerID)
+	}
+}
+
+// Messages returns the channel messages arrive on
+func (s *Subscription) Messages() <-chan *Message {
+	return s.messages
+}
+
+// Lagged is closed when the subscriber fell too far behind and was dropped.
+// The client should reconnect and resume from its last-seen event.
+func (s *Subscription) Lagged() <-chan struct{} {
+	return s.lagged
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.mu.Lock()
+		defer s.hub.mu.Unlock()
+		s.hub.drop(s)
+	})
+}
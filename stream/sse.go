@@ -0,0 +1,242 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/otyang/waas-go/pkg/response"
+	"github.com/otyang/waas-go/types"
+)
+
+// Handler defaults
+const (
+	DefaultHeartbeat    = 15 * time.Second
+	DefaultWriteTimeout = 10 * time.Second
+	DefaultBacklogPage  = 500
+)
+
+// Backlog loads events a resuming client missed.
+// store.WalletRepository implements it.
+type Backlog interface {
+	ListCustomerEvents(ctx context.Context, customerID string, afterSequence int64, limit int) ([]*types.OutboxEvent, error)
+}
+
+// Authenticator identifies the caller of a streaming request
+type Authenticator func(r *http.Request) (*types.Principal, error)
+
+// Handler serves a customer's wallet changes as Server-Sent Events.
+//
+// The customer is taken from the customer_id query parameter, defaulting to
+// the caller. The caller needs ActionViewWallet on the customer's wallets.
+// A client resumes by sending the Last-Event-ID header (or last_event_id
+// query parameter); events after it are replayed before live ones. Without
+// it the stream starts with live events only.
+//
+// A client that falls behind is sent a "lagged" event and disconnected, and
+// is expected to reconnect with its last-seen ID.
+type Handler struct {
+	hub          *Hub
+	backlog      Backlog
+	authenticate Authenticator
+	policy       *types.Policy
+	Heartbeat    time.Duration // Interval of keep-alive comments (DefaultHeartbeat when zero)
+	WriteTimeout time.Duration // Deadline for writing one event (DefaultWriteTimeout when zero)
+	BacklogPage  int           // Events loaded per backlog query (DefaultBacklogPage when zero)
+}
+
+// NewHandler creates a Handler. A nil policy uses types.DefaultPolicy.
+func NewHandler(hub *Hub, backlog Backlog, authenticate Authenticator, policy *types.Policy) *Handler {
+	if policy == nil {
+		policy = types.DefaultPolicy()
+	}
+	return &Handler{hub: hub, backlog: backlog, authenticate: authenticate, policy: policy}
+}
+
+// ServeHTTP streams events until the client disconnects or lags
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	principal, err := h.authenticate(r)
+	if err != nil || principal == nil {
+		writeError(w, response.NewAPIError(http.StatusUnauthorized, "Unauthorized").Code("unauthorized"))
+		return
+	}
+
+	customerID := r.URL.Query().Get("customer_id")
+	if customerID == "" {
+		customerID = principal.ID
+	}
+	if err := h.policy.Authorize(principal, types.ActionViewWallet, &types.Wallet{CustomerID: customerID}); err != nil {
+		writeError(w, response.NewAPIError(http.StatusForbidden, "Forbidden").Code("forbidden"))
+		return
+	}
+
+	lastID, resume, err := lastEventID(r)
+	if err != nil {
+		writeError(w, response.NewAPIError(http.StatusBadRequest, "Invalid last event ID").Code("bad_request"))
+		return
+	}
+
+	// Subscribe before loading the backlog so nothing committed in between is missed
+	sub := h.hub.Subscribe(customerID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	out := &eventWriter{w: w, rc: http.NewResponseController(w), timeout: h.WriteTimeout}
+	if out.timeout <= 0 {
+		out.timeout = DefaultWriteTimeout
+	}
+	if err := out.flush(); err != nil {
+		return
+	}
+
+	// Replayed events may also arrive live; each is sent once. The resume ID
+	// is the publish sequence, which the relay assigns in commit order, so no
+	// event after it can still be waiting to commit, and a live message at or
+	// below the last replayed sequence has already been sent.
+	var lastReplayed int64
+	if resume {
+		if lastReplayed, err = h.replay(r.Context(), out, customerID, lastID); err != nil {
+			return
+		}
+	}
+
+	heartbeat := h.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = DefaultHeartbeat
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.Lagged():
+			_ = out.event("lagged", "", []byte(`{}`))
+			return
+		case msg := <-sub.Messages():
+			if msg.Sequence <= lastReplayed {
+				continue
+			}
+			if err := out.message(msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := out.comment("ping"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// replay sends the events after lastID and returns the sequence of the last one sent
+func (h *Handler) replay(ctx context.Context, out *eventWriter, customerID string, lastID int64) (int64, error) {
+	page := h.BacklogPage
+	if page <= 0 {
+		page = DefaultBacklogPage
+	}
+
+	for {
+		events, err := h.backlog.ListCustomerEvents(ctx, customerID, lastID, page)
+		if err != nil {
+			_ = out.event("error", "", []byte(`{"message":"failed to load missed events"}`))
+			return lastID, err
+		}
+
+		for _, event := range events {
+			msg, err := NewMessage(event)
+			if err != nil {
+				return lastID, err
+			}
+			if err := out.message(msg); err != nil {
+				return lastID, err
+			}
+			lastID = msg.Sequence
+		}
+
+		if len(events) < page {
+			return lastID, nil
+		}
+	}
+}
+
+// lastEventID reads the resume position from the request
+func lastEventID(r *http.Request) (int64, bool, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return 0, false, nil
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, false, errors.New("invalid last event ID")
+	}
+	return id, true, nil
+}
+
+// eventWriter writes SSE frames, flushing each one under a write deadline
+type eventWriter struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	timeout time.Duration
+}
+
+func (e *eventWriter) message(msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return e.event(string(msg.Type), strconv.FormatInt(msg.Sequence, 10), data)
+}
+
+func (e *eventWriter) event(name, id string, data []byte) error {
+	e.deadline()
+	if id != "" {
+		if _, err := fmt.Fprintf(e.w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return err
+	}
+	return e.flush()
+}
+
+func (e *eventWriter) comment(text string) error {
+	e.deadline()
+	if _, err := fmt.Fprintf(e.w, ": %s\n\n", text); err != nil {
+		return err
+	}
+	return e.flush()
+}
+
+// deadline bounds the next write so a stalled client is disconnected
+func (e *eventWriter) deadline() {
+	// Not every ResponseWriter supports deadlines; those just block
+	_ = e.rc.SetWriteDeadline(time.Now().Add(e.timeout))
+}
+
+// flush pushes buffered frames to the client; a writer that cannot flush
+// cannot stream, so that ends the connection too
+func (e *eventWriter) flush() error {
+	return e.rc.Flush()
+}
+
+// writeError sends an API error as JSON
+func writeError(w http.ResponseWriter, apiErr *response.APIError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.HTTPStatusCode)
+	_ = json.NewEncoder(w).Encode(apiErr)
+}
@@ -0,0 +1,256 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryBacklog is an outbox table held in memory
+type memoryBacklog struct {
+	mu     sync.Mutex
+	events []*types.OutboxEvent
+}
+
+func (b *memoryBacklog) add(t *testing.T, customerID, walletID string, balance int64) *types.OutboxEvent {
+	wallet := &types.Wallet{ID: walletID, CustomerID: customerID, CurrencyCode: "USD", AvailableBalance: decimal.NewFromInt(balance)}
+	event, err := types.NewOutboxEvent(types.EventWalletCredited, walletID, &types.WalletEvent{
+		Wallet:       wallet,
+		Transactions: []*types.TransactionHistory{{ID: "txn_" + walletID, WalletID: walletID}, {ID: "txn_other", WalletID: "wt_other"}},
+	})
+	require.NoError(t, err)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	event.ID = int64(len(b.events) + 1)
+	event.PublishSequence = event.ID
+	b.events = append(b.events, event)
+	return event
+}
+
+func (b *memoryBacklog) ListCustomerEvents(_ context.Context, customerID string, afterSequence int64, limit int) ([]*types.OutboxEvent, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var events []*types.OutboxEvent
+	for _, event := range b.events {
+		msg, err := NewMessage(event)
+		if err != nil {
+			return nil, err
+		}
+		if event.PublishSequence > afterSequence && msg.customerID == customerID && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// bearer authenticates "Bearer <role>:<id>"
+func bearer(r *http.Request) (*types.Principal, error) {
+	role, id, ok := strings.Cut(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), ":")
+	if !ok {
+		return nil, errors.New("missing credentials")
+	}
+	return &types.Principal{ID: id, Roles: []types.Role{types.Role(role)}}, nil
+}
+
+type sseEvent struct {
+	id, name, data string
+}
+
+// connect opens a stream and returns a function reading the next event
+func connect(t *testing.T, url, token, lastEventID string) (*http.Response, func() sseEvent) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var current sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if current.name != "" {
+					events <- current
+				}
+				current = sseEvent{}
+			case strings.HasPrefix(line, "id: "):
+				current.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				current.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				current.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+
+	return resp, func() sseEvent {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return sseEvent{}
+			}
+			return event
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for event")
+			return sseEvent{}
+		}
+	}
+}
+
+func waitForSubscriber(t *testing.T, hub *Hub, customerID string) {
+	require.Eventually(t, func() bool { return hub.Subscribers(customerID) > 0 }, time.Second, time.Millisecond)
+}
+
+func TestHandlerAuthorization(t *testing.T) {
+	hub := NewHub()
+	server := httptest.NewServer(NewHandler(hub, &memoryBacklog{}, bearer, nil))
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, _ = connect(t, server.URL+"?customer_id=cus_2", "customer:cus_1", "")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, _ = connect(t, server.URL, "customer:cus_1", "bogus")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = connect(t, server.URL+"?customer_id=cus_2", "operator:op_1", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+}
+
+func TestHandlerStreamsLiveEvents(t *testing.T) {
+	ctx := context.Background()
+	hub := NewHub()
+	backlog := &memoryBacklog{}
+	server := httptest.NewServer(NewHandler(hub, backlog, bearer, nil))
+	t.Cleanup(server.Close)
+
+	_, next := connect(t, server.URL, "customer:cus_1", "")
+	waitForSubscriber(t, hub, "cus_1")
+
+	require.NoError(t, hub.Publish(ctx, backlog.add(t, "cus_2", "wt_2", 50)))
+	require.NoError(t, hub.Publish(ctx, backlog.add(t, "cus_1", "wt_1", 100)))
+
+	event := next()
+	assert.Equal(t, "2", event.id, "other customers' events are not streamed")
+	assert.Equal(t, string(types.EventWalletCredited), event.name)
+
+	var msg Message
+	require.NoError(t, json.Unmarshal([]byte(event.data), &msg))
+	assert.Equal(t, "wt_1", msg.WalletID)
+	assert.True(t, msg.AvailableBalance.Equal(decimal.NewFromInt(100)))
+	require.Len(t, msg.Transactions, 1)
+	assert.Equal(t, "txn_wt_1", msg.Transactions[0].ID)
+}
+
+func TestHandlerResumes(t *testing.T) {
+	ctx := context.Background()
+	hub := NewHub()
+	backlog := &memoryBacklog{}
+	handler := NewHandler(hub, backlog, bearer, nil)
+	handler.BacklogPage = 2
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	for i := 0; i < 5; i++ {
+		backlog.add(t, "cus_1", "wt_1", int64(i))
+	}
+
+	_, next := connect(t, server.URL, "customer:cus_1", "2")
+	for _, id := range []string{"3", "4", "5"} {
+		assert.Equal(t, id, next().id)
+	}
+	waitForSubscriber(t, hub, "cus_1")
+
+	// Replayed events are skipped when published live, however often they arrive
+	for _, i := range []int{4, 2, 4} {
+		require.NoError(t, hub.Publish(ctx, backlog.events[i]))
+	}
+	require.NoError(t, hub.Publish(ctx, backlog.add(t, "cus_1", "wt_1", 6)))
+	assert.Equal(t, "6", next().id)
+}
+
+func TestHandlerDisconnectsLaggingClient(t *testing.T) {
+	ctx := context.Background()
+	hub := NewHub()
+	hub.Buffer = 1
+	backlog := &memoryBacklog{}
+
+	sub := hub.Subscribe("cus_1")
+	require.NoError(t, hub.Publish(ctx, backlog.add(t, "cus_1", "wt_1", 1)))
+	require.NoError(t, hub.Publish(ctx, backlog.add(t, "cus_1", "wt_1", 2)))
+
+	select {
+	case <-sub.Lagged():
+	default:
+		t.Fatal("subscriber should be dropped once its buffer is full")
+	}
+	assert.Zero(t, hub.Subscribers("cus_1"))
+
+	// Publishing after the drop does not block or panic
+	require.NoError(t, hub.Publish(ctx, backlog.add(t, "cus_1", "wt_1", 3)))
+	sub.Close()
+
+	// Over HTTP the client is told it lagged
+	server := httptest.NewServer(NewHandler(hub, backlog, bearer, nil))
+	t.Cleanup(server.Close)
+
+	_, next := connect(t, server.URL, "customer:cus_1", "")
+	waitForSubscriber(t, hub, "cus_1")
+
+	// The handler drains its buffer too quickly to overflow it reliably, so
+	// drop it the way Publish does
+	hub.mu.Lock()
+	for s := range hub.subs["cus_1"] {
+		hub.drop(s)
+		close(s.lagged)
+	}
+	hub.mu.Unlock()
+	assert.Equal(t, "lagged", next().name)
+}
+
+func TestHandlerHeartbeat(t *testing.T) {
+	hub := NewHub()
+	handler := NewHandler(hub, &memoryBacklog{}, bearer, nil)
+	handler.Heartbeat = 10 * time.Millisecond
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer customer:cus_1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, ": ping\n", line)
+}
@@ -0,0 +1,374 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// AML monitoring errors
+var ErrAMLCaseNotFound = errors.New("aml case not found")
+
+// AMLPattern is a suspicious activity pattern found by batch monitoring
+type AMLPattern string
+
+const (
+	PatternStructuring         AMLPattern = "STRUCTURING"          // Repeated amounts just below a reporting threshold
+	PatternVolumeSpike         AMLPattern = "VOLUME_SPIKE"         // Volume far above the customer's baseline
+	PatternFanIn               AMLPattern = "FAN_IN"               // Many different senders into one customer
+	PatternFanOut              AMLPattern = "FAN_OUT"              // One customer sending to many different receivers
+	PatternDormantReactivation AMLPattern = "DORMANT_REACTIVATION" // Long-inactive customer suddenly moving funds
+)
+
+// AMLCaseStatus tracks an AML case through investigation
+type AMLCaseStatus string
+
+const (
+	AMLCaseOpen      AMLCaseStatus = "OPEN"      // Awaiting investigation
+	AMLCaseReported  AMLCaseStatus = "REPORTED"  // Filed as a suspicious activity report
+	AMLCaseDismissed AMLCaseStatus = "DISMISSED" // Investigated and found legitimate
+)
+
+// AMLCase is a pattern found for a customer, with the transactions supporting it
+type AMLCase struct {
+	ID             string          `json:"id" bun:",pk"`                            // Unique case ID
+	Fingerprint    string          `json:"fingerprint" bun:",unique,notnull"`       // Identifies the same finding across runs
+	Pattern        AMLPattern      `json:"pattern" bun:",notnull"`                  // What was found
+	CustomerID     string          `json:"customerId" bun:",notnull"`               // Customer the pattern belongs to
+	CurrencyCode   string          `json:"currencyCode" bun:",notnull"`             // Currency of the activity
+	Amount         decimal.Decimal `json:"amount" bun:"type:decimal(24,8),notnull"` // Total of the supporting transactions
+	Summary        string          `json:"summary" bun:",notnull"`                  // Human-readable explanation
+	WalletIDs      []string        `json:"walletIds" bun:",notnull"`                // Customer wallets involved
+	TransactionIDs []string        `json:"transactionIds" bun:",notnull"`           // Supporting transactions
+	PeriodStart    time.Time       `json:"periodStart" bun:",notnull"`              // Monitored period start
+	PeriodEnd      time.Time       `json:"periodEnd" bun:",notnull"`                // Monitored period end
+	Status         AMLCaseStatus   `json:"status" bun:",notnull"`                   // Investigation state
+	ReviewerID     string          `json:"reviewerId" bun:",nullzero"`              // Who closed the case
+	ReviewNote     string          `json:"reviewNote" bun:",nullzero"`              // Investigation notes
+	CreatedAt      time.Time       `json:"createdAt" bun:",notnull"`                // When it was found
+	ReviewedAt     time.Time       `json:"reviewedAt" bun:",nullzero"`              // When it was closed
+}
+
+// AMLRules configures batch monitoring. Zero values disable the pattern.
+type AMLRules struct {
+	StructuringThreshold decimal.Decimal // Reporting threshold amounts are kept under
+	StructuringMargin    decimal.Decimal // Fraction below the threshold that counts (e.g. 0.1)
+	StructuringMinCount  int             // Near-threshold transactions needed within the window
+	StructuringWindow    time.Duration   // Window the transactions must fall in
+
+	SpikeMultiplier decimal.Decimal // Period volume above baseline times this is a spike
+	SpikeMinVolume  decimal.Decimal // Ignore spikes smaller than this
+	BaselineWindow  time.Duration   // History before the period used as the baseline
+
+	FanMinCounterparties int // Distinct counterparties that make a fan-in or fan-out
+
+	DormantPeriod    time.Duration   // Inactivity after which a customer is dormant
+	DormantMinAmount decimal.Decimal // Reactivation volume that makes a case
+}
+
+// DefaultAMLRules returns conservative monitoring rules
+func DefaultAMLRules() AMLRules {
+	return AMLRules{
+		StructuringThreshold: decimal.NewFromInt(10000),
+		StructuringMargin:    decimal.RequireFromString("0.1"),
+		StructuringMinCount:  3,
+		StructuringWindow:    7 * 24 * time.Hour,
+		SpikeMultiplier:      decimal.NewFromInt(5),
+		SpikeMinVolume:       decimal.NewFromInt(5000),
+		BaselineWindow:       90 * 24 * time.Hour,
+		FanMinCounterparties: 10,
+		DormantPeriod:        180 * 24 * time.Hour,
+		DormantMinAmount:     decimal.NewFromInt(1000),
+	}
+}
+
+// AMLInput is the data batch monitoring runs over
+type AMLInput struct {
+	PeriodStart  time.Time                  // Monitored period start (inclusive)
+	PeriodEnd    time.Time                  // Monitored period end (exclusive)
+	Transactions []*TransactionHistory      // Completed rows created in the period
+	WalletOwners map[string]string          // Wallet ID to customer ID for every wallet in Transactions
+	Baseline     map[string]decimal.Decimal // AMLKey(customer, currency) to volume in BaselineWindow before the period
+	LastActivity map[string]time.Time       // Customer ID to their last transaction before the period
+}
+
+// AMLKey keys per-customer, per-currency aggregates
+func AMLKey(customerID, currencyCode string) string {
+	return customerID + "|" + currencyCode
+}
+
+// amlGroup is a customer's activity in one currency during the period
+type amlGroup struct {
+	customerID string
+	currency   string
+	rows       []*TransactionHistory
+}
+
+// DetectSuspiciousActivity runs every enabled pattern over the period and
+// returns a case per finding, ordered by customer and pattern.
+//
+// Transfers are paired into counterparties through the GroupID shared by the
+// debit and credit rows a transfer writes.
+func DetectSuspiciousActivity(rules AMLRules, in AMLInput) []*AMLCase {
+	groups := make(map[string]*amlGroup)
+	for _, tx := range in.Transactions {
+		if tx == nil || tx.Status != StatusCompleted {
+			continue
+		}
+		customer := in.WalletOwners[tx.WalletID]
+		if customer == "" {
+			continue
+		}
+		key := AMLKey(customer, tx.CurrencyCode)
+		if groups[key] == nil {
+			groups[key] = &amlGroup{customerID: customer, currency: tx.CurrencyCode}
+		}
+		groups[key].rows = append(groups[key].rows, tx)
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key, g := range groups {
+		sort.Slice(g.rows, func(i, j int) bool {
+			if !g.rows[i].CreatedAt.Equal(g.rows[j].CreatedAt) {
+				return g.rows[i].CreatedAt.Before(g.rows[j].CreatedAt)
+			}
+			return g.rows[i].ID < g.rows[j].ID
+		})
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	counterparties := pairTransfers(in.Transactions, in.WalletOwners)
+
+	var cases []*AMLCase
+	for _, key := range keys {
+		g := groups[key]
+		for _, found := range []*AMLCase{
+			detectStructuring(rules, g),
+			detectVolumeSpike(rules, in, g),
+			detectFan(rules, g, counterparties, TypeCredit),
+			detectFan(rules, g, counterparties, TypeDebit),
+			detectDormant(rules, in, g),
+		} {
+			if found != nil {
+				found.seal(in.PeriodStart, in.PeriodEnd)
+				cases = append(cases, found)
+			}
+		}
+	}
+
+	return cases
+}
+
+// pairTransfers maps each transfer row's ID to the customer on the other side.
+// Rows without a GroupID, and groups other than one debit and one credit, are
+// left unpaired.
+func pairTransfers(rows []*TransactionHistory, owners map[string]string) map[string]string {
+	type side struct{ debits, credits []*TransactionHistory }
+	legs := make(map[string]*side)
+	for _, tx := range rows {
+		if tx == nil || tx.Category != CategoryTransfer || tx.Status != StatusCompleted || tx.GroupID == "" {
+			continue
+		}
+		if legs[tx.GroupID] == nil {
+			legs[tx.GroupID] = &side{}
+		}
+		if tx.Type == TypeDebit {
+			legs[tx.GroupID].debits = append(legs[tx.GroupID].debits, tx)
+		} else {
+			legs[tx.GroupID].credits = append(legs[tx.GroupID].credits, tx)
+		}
+	}
+
+	counterparty := make(map[string]string)
+	for _, leg := range legs {
+		if len(leg.debits) != 1 || len(leg.credits) != 1 {
+			continue
+		}
+		debit, credit := leg.debits[0], leg.credits[0]
+		counterparty[debit.ID] = owners[credit.WalletID]
+		counterparty[credit.ID] = owners[debit.WalletID]
+	}
+	return counterparty
+}
+
+// newAMLCase builds an open case from supporting rows
+func newAMLCase(pattern AMLPattern, g *amlGroup, rows []*TransactionHistory, summary string) *AMLCase {
+	c := &AMLCase{
+		ID:           GenerateID("aml_", 15),
+		Pattern:      pattern,
+		CustomerID:   g.customerID,
+		CurrencyCode: g.currency,
+		Amount:       decimal.Zero,
+		Summary:      summary,
+		Status:       AMLCaseOpen,
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	wallets := make(map[string]bool)
+	for _, tx := range rows {
+		c.Amount = c.Amount.Add(tx.Amount)
+		c.TransactionIDs = append(c.TransactionIDs, tx.ID)
+		if !wallets[tx.WalletID] {
+			wallets[tx.WalletID] = true
+			c.WalletIDs = append(c.WalletIDs, tx.WalletID)
+		}
+	}
+	sort.Strings(c.WalletIDs)
+	return c
+}
+
+// seal sets the period and a fingerprint that is stable across runs over
+// overlapping periods, so the same finding is only stored once
+func (c *AMLCase) seal(start, end time.Time) {
+	c.PeriodStart, c.PeriodEnd = start.UTC(), end.UTC()
+
+	ids := append([]string(nil), c.TransactionIDs...)
+	sort.Strings(ids)
+	sum := sha256.Sum256([]byte(strings.Join(append([]string{string(c.Pattern), c.CustomerID, c.CurrencyCode}, ids...), "|")))
+	c.Fingerprint = hex.EncodeToString(sum[:])
+}
+
+// detectStructuring finds the largest cluster of near-threshold amounts inside
+// the window whose total reaches the threshold
+func detectStructuring(rules AMLRules, g *amlGroup) *AMLCase {
+	if rules.StructuringThreshold.IsZero() || rules.StructuringMinCount <= 0 {
+		return nil
+	}
+
+	floor := rules.StructuringThreshold.Sub(rules.StructuringThreshold.Mul(rules.StructuringMargin))
+	var near []*TransactionHistory
+	for _, tx := range g.rows {
+		if tx.Amount.LessThan(rules.StructuringThreshold) && tx.Amount.GreaterThanOrEqual(floor) {
+			near = append(near, tx)
+		}
+	}
+
+	var best []*TransactionHistory
+	start := 0
+	for end := range near {
+		for near[end].CreatedAt.Sub(near[start].CreatedAt) > rules.StructuringWindow {
+			start++
+		}
+		if end-start+1 > len(best) {
+			best = near[start : end+1]
+		}
+	}
+	if len(best) < rules.StructuringMinCount {
+		return nil
+	}
+
+	total := decimal.Zero
+	for _, tx := range best {
+		total = total.Add(tx.Amount)
+	}
+	if total.LessThan(rules.StructuringThreshold) {
+		return nil
+	}
+
+	return newAMLCase(PatternStructuring, g, best, fmt.Sprintf(
+		"%d transactions between %s and %s %s totalling %s within %s",
+		len(best), floor, rules.StructuringThreshold, g.currency, total, rules.StructuringWindow))
+}
+
+// detectVolumeSpike compares period volume with the baseline scaled to the period length
+func detectVolumeSpike(rules AMLRules, in AMLInput, g *amlGroup) *AMLCase {
+	if rules.SpikeMultiplier.IsZero() || rules.BaselineWindow <= 0 {
+		return nil
+	}
+	baseline, ok := in.Baseline[AMLKey(g.customerID, g.currency)]
+	if !ok || !baseline.IsPositive() {
+		return nil // New customers have no baseline to spike against
+	}
+
+	volume := decimal.Zero
+	for _, tx := range g.rows {
+		volume = volume.Add(tx.Amount)
+	}
+
+	ratio := decimal.NewFromInt(int64(in.PeriodEnd.Sub(in.PeriodStart))).Div(decimal.NewFromInt(int64(rules.BaselineWindow)))
+	expected := baseline.Mul(ratio)
+	if volume.LessThan(rules.SpikeMinVolume) || volume.LessThanOrEqual(expected.Mul(rules.SpikeMultiplier)) {
+		return nil
+	}
+
+	return newAMLCase(PatternVolumeSpike, g, g.rows, fmt.Sprintf(
+		"volume %s %s against an expected %s from the baseline",
+		volume, g.currency, expected.Round(2)))
+}
+
+// detectFan finds customers receiving from (TypeCredit) or sending to
+// (TypeDebit) many distinct counterparties
+func detectFan(rules AMLRules, g *amlGroup, counterparties map[string]string, direction TransactionType) *AMLCase {
+	if rules.FanMinCounterparties <= 0 {
+		return nil
+	}
+
+	distinct := make(map[string]bool)
+	var rows []*TransactionHistory
+	for _, tx := range g.rows {
+		other, ok := counterparties[tx.ID]
+		if !ok || tx.Type != direction || other == "" || other == g.customerID {
+			continue
+		}
+		distinct[other] = true
+		rows = append(rows, tx)
+	}
+	if len(distinct) < rules.FanMinCounterparties {
+		return nil
+	}
+
+	pattern, verb := PatternFanIn, "received from"
+	if direction == TypeDebit {
+		pattern, verb = PatternFanOut, "sent to"
+	}
+	return newAMLCase(pattern, g, rows, fmt.Sprintf("%s %d distinct customers in %d transfers", verb, len(distinct), len(rows)))
+}
+
+// detectDormant finds customers active again after a long gap
+func detectDormant(rules AMLRules, in AMLInput, g *amlGroup) *AMLCase {
+	if rules.DormantPeriod <= 0 || len(g.rows) == 0 {
+		return nil
+	}
+	last, ok := in.LastActivity[g.customerID]
+	if !ok {
+		return nil // Never active before: a new customer, not a dormant one
+	}
+
+	first := g.rows[0].CreatedAt
+	gap := first.Sub(last)
+	if gap < rules.DormantPeriod {
+		return nil
+	}
+
+	volume := decimal.Zero
+	for _, tx := range g.rows {
+		volume = volume.Add(tx.Amount)
+	}
+	if volume.LessThan(rules.DormantMinAmount) {
+		return nil
+	}
+
+	return newAMLCase(PatternDormantReactivation, g, g.rows, fmt.Sprintf(
+		"moved %s %s after %d days without activity", volume, g.currency, int(gap.Hours()/24)))
+}
+
+// SARReport is a suspicious activity report covering one or more cases
+type SARReport struct {
+	ReportID    string     `json:"reportId"`    // Unique report ID
+	Institution string     `json:"institution"` // Filing institution
+	GeneratedAt time.Time  `json:"generatedAt"` // When the report was produced
+	Cases       []*SARCase `json:"cases"`       // Reported cases
+}
+
+// SARCase is a case together with its supporting transactions
+type SARCase struct {
+	*AMLCase
+	Transactions []*TransactionHistory `json:"transactions"` // Supporting transactions
+}
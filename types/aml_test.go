@@ -0,0 +1,210 @@
+package types
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func amlRow(walletID string, txType TransactionType, amount int64, at time.Time) *TransactionHistory {
+	return &TransactionHistory{
+		ID:           GenerateID("txn_", 8),
+		WalletID:     walletID,
+		CurrencyCode: "USD",
+		Category:     CategoryDeposit,
+		Type:         txType,
+		Amount:       decimal.NewFromInt(amount),
+		CreatedAt:    at,
+		Status:       StatusCompleted,
+	}
+}
+
+// amlTransfer returns the debit and credit rows a transfer writes
+func amlTransfer(from, to string, amount int64, at time.Time) []*TransactionHistory {
+	group := GenerateID("grp_", 8)
+	debit := amlRow(from, TypeDebit, amount, at)
+	credit := amlRow(to, TypeCredit, amount, at.Add(time.Microsecond))
+	for _, row := range []*TransactionHistory{debit, credit} {
+		row.Category = CategoryTransfer
+		row.GroupID = group
+	}
+	return []*TransactionHistory{debit, credit}
+}
+
+func patterns(cases []*AMLCase) map[AMLPattern]*AMLCase {
+	found := make(map[AMLPattern]*AMLCase)
+	for _, c := range cases {
+		found[c.Pattern] = c
+	}
+	return found
+}
+
+func TestDetectSuspiciousActivity(t *testing.T) {
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 7)
+	rules := DefaultAMLRules()
+	rules.FanMinCounterparties = 3
+
+	input := func(rows []*TransactionHistory, owners map[string]string) AMLInput {
+		return AMLInput{
+			PeriodStart:  start,
+			PeriodEnd:    end,
+			Transactions: rows,
+			WalletOwners: owners,
+			Baseline:     map[string]decimal.Decimal{},
+			LastActivity: map[string]time.Time{},
+		}
+	}
+
+	t.Run("structuring", func(t *testing.T) {
+		rows := []*TransactionHistory{
+			amlRow("wt_a", TypeCredit, 9500, start.Add(time.Hour)),
+			amlRow("wt_a", TypeCredit, 9800, start.Add(2*time.Hour)),
+			amlRow("wt_a", TypeCredit, 9900, start.Add(3*time.Hour)),
+			amlRow("wt_a", TypeCredit, 100, start.Add(4*time.Hour)),
+		}
+		found := patterns(DetectSuspiciousActivity(rules, input(rows, map[string]string{"wt_a": "cus_a"})))
+
+		require.Contains(t, found, PatternStructuring)
+		c := found[PatternStructuring]
+		assert.Len(t, c.TransactionIDs, 3)
+		assert.Equal(t, "29200", c.Amount.String())
+		assert.Equal(t, "cus_a", c.CustomerID)
+		assert.Equal(t, AMLCaseOpen, c.Status)
+		assert.NotEmpty(t, c.Fingerprint)
+	})
+
+	t.Run("structuring spread too thin", func(t *testing.T) {
+		rows := []*TransactionHistory{
+			amlRow("wt_a", TypeCredit, 9500, start),
+			amlRow("wt_a", TypeCredit, 9800, start.AddDate(0, 0, 8)),
+			amlRow("wt_a", TypeCredit, 9900, start.AddDate(0, 0, 16)),
+		}
+		found := patterns(DetectSuspiciousActivity(rules, input(rows, map[string]string{"wt_a": "cus_a"})))
+		assert.NotContains(t, found, PatternStructuring)
+	})
+
+	t.Run("volume spike", func(t *testing.T) {
+		rows := []*TransactionHistory{amlRow("wt_a", TypeCredit, 20000, start.Add(time.Hour))}
+		in := input(rows, map[string]string{"wt_a": "cus_a"})
+
+		// 90 days of 9000 is about 700 a week, so 20000 is a spike
+		in.Baseline[AMLKey("cus_a", "USD")] = decimal.NewFromInt(9000)
+		assert.Contains(t, patterns(DetectSuspiciousActivity(rules, in)), PatternVolumeSpike)
+
+		// 90 days of 90000 is 7000 a week, under the 5x multiplier
+		in.Baseline[AMLKey("cus_a", "USD")] = decimal.NewFromInt(90000)
+		assert.NotContains(t, patterns(DetectSuspiciousActivity(rules, in)), PatternVolumeSpike)
+
+		// No baseline means a new customer, not a spike
+		delete(in.Baseline, AMLKey("cus_a", "USD"))
+		assert.NotContains(t, patterns(DetectSuspiciousActivity(rules, in)), PatternVolumeSpike)
+	})
+
+	t.Run("fan in and fan out", func(t *testing.T) {
+		owners := map[string]string{"wt_hub": "cus_hub", "wt_out": "cus_out"}
+		var rows []*TransactionHistory
+		for i := 0; i < 3; i++ {
+			sender := fmt.Sprintf("wt_s%d", i)
+			receiver := fmt.Sprintf("wt_r%d", i)
+			owners[sender] = fmt.Sprintf("cus_s%d", i)
+			owners[receiver] = fmt.Sprintf("cus_r%d", i)
+			rows = append(rows, amlTransfer(sender, "wt_hub", 100, start.Add(time.Duration(i)*time.Hour))...)
+			rows = append(rows, amlTransfer("wt_out", receiver, 100, start.Add(time.Duration(i)*time.Hour))...)
+		}
+
+		cases := DetectSuspiciousActivity(rules, input(rows, owners))
+		var fanIn, fanOut *AMLCase
+		for _, c := range cases {
+			if c.Pattern == PatternFanIn && c.CustomerID == "cus_hub" {
+				fanIn = c
+			}
+			if c.Pattern == PatternFanOut && c.CustomerID == "cus_out" {
+				fanOut = c
+			}
+		}
+		require.NotNil(t, fanIn)
+		require.NotNil(t, fanOut)
+		assert.Len(t, fanIn.TransactionIDs, 3)
+		assert.Equal(t, []string{"wt_hub"}, fanIn.WalletIDs)
+		assert.Len(t, fanOut.TransactionIDs, 3)
+	})
+
+	t.Run("equal transfers without references pair by group", func(t *testing.T) {
+		// Same amount, same instant, no reference: only the group tells them apart
+		owners := map[string]string{"wt_hub": "cus_hub"}
+		var rows []*TransactionHistory
+		senders := make(map[string]string)
+		for i := 0; i < 3; i++ {
+			sender := fmt.Sprintf("wt_s%d", i)
+			owners[sender] = fmt.Sprintf("cus_s%d", i)
+			legs := amlTransfer(sender, "wt_hub", 100, start)
+			legs[1].CreatedAt = start
+			senders[legs[1].ID] = owners[sender]
+			rows = append(rows, legs...)
+		}
+
+		counterparties := pairTransfers(rows, owners)
+		for creditID, sender := range senders {
+			assert.Equal(t, sender, counterparties[creditID])
+		}
+
+		fanIn := patterns(DetectSuspiciousActivity(rules, input(rows, owners)))[PatternFanIn]
+		require.NotNil(t, fanIn)
+		assert.Equal(t, "cus_hub", fanIn.CustomerID)
+		assert.Len(t, fanIn.TransactionIDs, 3)
+
+		// Rows written without a group are not guessed at
+		for _, row := range rows {
+			row.GroupID = ""
+		}
+		assert.Empty(t, pairTransfers(rows, owners))
+	})
+
+	t.Run("dormant reactivation", func(t *testing.T) {
+		rows := []*TransactionHistory{amlRow("wt_a", TypeDebit, 5000, start.Add(time.Hour))}
+		in := input(rows, map[string]string{"wt_a": "cus_a"})
+
+		in.LastActivity["cus_a"] = start.AddDate(-1, 0, 0)
+		assert.Contains(t, patterns(DetectSuspiciousActivity(rules, in)), PatternDormantReactivation)
+
+		in.LastActivity["cus_a"] = start.AddDate(0, -1, 0)
+		assert.NotContains(t, patterns(DetectSuspiciousActivity(rules, in)), PatternDormantReactivation)
+
+		delete(in.LastActivity, "cus_a")
+		assert.NotContains(t, patterns(DetectSuspiciousActivity(rules, in)), PatternDormantReactivation)
+	})
+
+	t.Run("fingerprint is stable across runs", func(t *testing.T) {
+		rows := []*TransactionHistory{
+			amlRow("wt_a", TypeCredit, 9500, start.Add(time.Hour)),
+			amlRow("wt_a", TypeCredit, 9800, start.Add(2*time.Hour)),
+			amlRow("wt_a", TypeCredit, 9900, start.Add(3*time.Hour)),
+		}
+		owners := map[string]string{"wt_a": "cus_a"}
+		first := patterns(DetectSuspiciousActivity(rules, input(rows, owners)))[PatternStructuring]
+		second := patterns(DetectSuspiciousActivity(rules, input(rows, owners)))[PatternStructuring]
+
+		require.NotNil(t, first)
+		require.NotNil(t, second)
+		assert.NotEqual(t, first.ID, second.ID)
+		assert.Equal(t, first.Fingerprint, second.Fingerprint)
+	})
+
+	t.Run("failed and unowned rows are ignored", func(t *testing.T) {
+		failed := amlRow("wt_a", TypeCredit, 9500, start.Add(time.Hour))
+		failed.Status = StatusFailed
+		rows := []*TransactionHistory{
+			failed,
+			amlRow("wt_a", TypeCredit, 9800, start.Add(2*time.Hour)),
+			amlRow("wt_a", TypeCredit, 9900, start.Add(3*time.Hour)),
+			amlRow("wt_x", TypeCredit, 9900, start.Add(3*time.Hour)),
+		}
+		found := patterns(DetectSuspiciousActivity(rules, input(rows, map[string]string{"wt_a": "cus_a"})))
+		assert.NotContains(t, found, PatternStructuring)
+	})
+}
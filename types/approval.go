@@ -0,0 +1,234 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Approval workflow errors
+var (
+	ErrApprovalRequired    = errors.New("operation requires a second approval")
+	ErrApprovalNotFound    = errors.New("approval request not found")
+	ErrApprovalNotPending  = errors.New("approval request is no longer pending")
+	ErrApprovalExpired     = errors.New("approval request has expired")
+	ErrSelfApproval        = errors.New("approval request cannot be decided by its maker")
+	ErrUnsupportedApproval = errors.New("operation does not support approval")
+)
+
+// ApprovalOperation is the repository call an approval request executes
+type ApprovalOperation string
+
+const (
+	ApprovalCredit   ApprovalOperation = "CREDIT"   // CreditWallet with a CreditTransaction payload
+	ApprovalDebit    ApprovalOperation = "DEBIT"    // DebitWallet with a DebitTransaction payload
+	ApprovalTransfer ApprovalOperation = "TRANSFER" // TransferFunds with a TransferRequest payload
+	ApprovalUnfreeze ApprovalOperation = "UNFREEZE" // UnfreezeWallet, no payload
+	ApprovalReopen   ApprovalOperation = "REOPEN"   // ReopenWallet with a CloseOrOpenRequest payload
+)
+
+// ApprovalStatus tracks an approval request through its lifecycle
+type ApprovalStatus string
+
+const (
+	ApprovalPending  ApprovalStatus = "PENDING"  // Waiting for a checker
+	ApprovalExecuted ApprovalStatus = "EXECUTED" // Approved and the operation succeeded
+	ApprovalRejected ApprovalStatus = "REJECTED" // Declined by a checker
+	ApprovalExpired  ApprovalStatus = "EXPIRED"  // Not decided in time
+	ApprovalFailed   ApprovalStatus = "FAILED"   // Approved but the operation failed
+)
+
+// ApprovalRequest is an operation submitted by a maker awaiting a checker
+type ApprovalRequest struct {
+	ID                  string            `json:"id" bun:",pk"`                            // Unique request ID
+	Operation           ApprovalOperation `json:"operation" bun:",notnull"`                // Repository call to execute
+	WalletID            string            `json:"walletId" bun:",notnull"`                 // Wallet operated on (source for transfers)
+	DestinationWalletID string            `json:"destinationWalletId" bun:",nullzero"`     // Destination wallet for transfers
+	CurrencyCode        string            `json:"currencyCode" bun:",nullzero"`            // Currency of Amount
+	Amount              decimal.Decimal   `json:"amount" bun:"type:decimal(24,8),notnull"` // Amount moved, zero for state changes
+	Payload             json.RawMessage   `json:"payload" bun:",nullzero"`                 // Full request passed to the repository call
+	MakerID             string            `json:"makerId" bun:",notnull"`                  // Who submitted the request
+	CheckerID           string            `json:"checkerId" bun:",nullzero"`               // Who decided the request
+	Status              ApprovalStatus    `json:"status" bun:",notnull"`                   // Current state
+	Reason              string            `json:"reason" bun:",nullzero"`                  // Maker's justification
+	DecisionNote        string            `json:"decisionNote" bun:",nullzero"`            // Checker's note or the execution error
+	TransactionID       string            `json:"transactionId" bun:",nullzero"`           // Transaction written on execution, if any
+	ExpiresAt           time.Time         `json:"expiresAt" bun:",notnull"`                // Pending requests expire after this
+	CreatedAt           time.Time         `json:"createdAt" bun:",notnull"`                // Submission time
+	DecidedAt           time.Time         `json:"decidedAt" bun:",nullzero"`               // Approval, rejection or expiry time
+	VersionId           string            `json:"-" bun:",notnull"`                        // For concurrency control
+}
+
+// ApprovalEventType is an entry kind in an approval request's audit history
+type ApprovalEventType string
+
+const (
+	ApprovalEventSubmitted ApprovalEventType = "SUBMITTED"
+	ApprovalEventApproved  ApprovalEventType = "APPROVED"
+	ApprovalEventRejected  ApprovalEventType = "REJECTED"
+	ApprovalEventExpired   ApprovalEventType = "EXPIRED"
+	ApprovalEventExecuted  ApprovalEventType = "EXECUTED"
+	ApprovalEventFailed    ApprovalEventType = "FAILED"
+)
+
+// ApprovalEvent is an append-only audit entry for an approval request
+type ApprovalEvent struct {
+	ID        string            `json:"id" bun:",pk"`             // Unique event ID
+	RequestID string            `json:"requestId" bun:",notnull"` // Approval request
+	Type      ApprovalEventType `json:"type" bun:",notnull"`      // What happened
+	ActorID   string            `json:"actorId" bun:",notnull"`   // Who did it ("system" for expiry)
+	Status    ApprovalStatus    `json:"status" bun:",notnull"`    // Request status after the event
+	Note      string            `json:"note" bun:",nullzero"`     // Free-form detail
+	CreatedAt time.Time         `json:"createdAt" bun:",notnull"` // When it happened
+}
+
+// NewApprovalEvent creates an audit entry reflecting the request's current status
+func NewApprovalEvent(req *ApprovalRequest, eventType ApprovalEventType, actorID, note string) *ApprovalEvent {
+	return &ApprovalEvent{
+		ID:        GenerateID("ape_", 15),
+		RequestID: req.ID,
+		Type:      eventType,
+		ActorID:   actorID,
+		Status:    req.Status,
+		Note:      note,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// ApprovalPolicy decides which operations need a checker and who may check them
+type ApprovalPolicy struct {
+	Adjustments        bool                         // CategoryAdjustment credits and debits need approval
+	Unfreezes          bool                         // Unfreezes need approval
+	Reopens            bool                         // Reopening a closed wallet needs approval
+	TransferThresholds map[string]decimal.Decimal   // Per-currency amount at or above which transfers need approval
+	Expiry             time.Duration                // How long requests stay pending
+	CheckerRoles       map[ApprovalOperation][]Role // Roles allowed to decide each operation
+}
+
+// DefaultApprovalPolicy requires approval for adjustments, unfreezes and
+// reopens. Transfers are not gated until thresholds are configured.
+func DefaultApprovalPolicy() *ApprovalPolicy {
+	return &ApprovalPolicy{
+		Adjustments:        true,
+		Unfreezes:          true,
+		Reopens:            true,
+		TransferThresholds: make(map[string]decimal.Decimal),
+		Expiry:             24 * time.Hour,
+		CheckerRoles: map[ApprovalOperation][]Role{
+			ApprovalCredit:   {RoleOperator},
+			ApprovalDebit:    {RoleOperator},
+			ApprovalTransfer: {RoleOperator},
+			ApprovalUnfreeze: {RoleCompliance},
+			ApprovalReopen:   {RoleOperator},
+		},
+	}
+}
+
+// SetTransferThreshold gates transfers of amount or more in the currency
+func (p *ApprovalPolicy) SetTransferThreshold(currencyCode string, amount decimal.Decimal) *ApprovalPolicy {
+	if p.TransferThresholds == nil {
+		p.TransferThresholds = make(map[string]decimal.Decimal)
+	}
+	p.TransferThresholds[strings.ToUpper(currencyCode)] = amount
+	return p
+}
+
+// RequiresApproval reports whether an operation needs a checker
+func (p *ApprovalPolicy) RequiresApproval(op ApprovalOperation, category TransactionCategory, currencyCode string, amount decimal.Decimal) bool {
+	switch op {
+	case ApprovalCredit, ApprovalDebit:
+		return p.Adjustments && category == CategoryAdjustment
+	case ApprovalTransfer:
+		threshold, ok := p.TransferThresholds[strings.ToUpper(currencyCode)]
+		return ok && amount.GreaterThanOrEqual(threshold)
+	case ApprovalUnfreeze:
+		return p.Unfreezes
+	case ApprovalReopen:
+		return p.Reopens
+	default:
+		return false
+	}
+}
+
+// CanCheck reports whether principal holds a role allowed to decide op
+func (p *ApprovalPolicy) CanCheck(principal *Principal, op ApprovalOperation) bool {
+	if principal == nil {
+		return false
+	}
+	for _, role := range p.CheckerRoles[op] {
+		if principal.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewApprovalRequest creates a pending request carrying payload as JSON
+func NewApprovalRequest(
+	op ApprovalOperation,
+	makerID string,
+	walletID string,
+	destinationWalletID string,
+	payload any,
+	reason string,
+	expiry time.Duration,
+) (*ApprovalRequest, error) {
+	req := &ApprovalRequest{
+		ID:                  GenerateID("apr_", 15),
+		Operation:           op,
+		WalletID:            walletID,
+		DestinationWalletID: destinationWalletID,
+		Amount:              decimal.Zero,
+		MakerID:             makerID,
+		Status:              ApprovalPending,
+		Reason:              reason,
+		CreatedAt:           time.Now().UTC(),
+		VersionId:           GenerateID("ver_", 8),
+	}
+	req.ExpiresAt = req.CreatedAt.Add(expiry)
+
+	switch p := payload.(type) {
+	case CreditTransaction:
+		req.Amount = p.Amount
+	case DebitTransaction:
+		req.Amount = p.Amount
+	case TransferRequest:
+		req.Amount = p.Amount
+	}
+
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode approval payload: %w", err)
+		}
+		req.Payload = data
+	}
+
+	return req, nil
+}
+
+// DecodePayload unmarshals the stored payload into v
+func (a *ApprovalRequest) DecodePayload(v any) error {
+	if len(a.Payload) == 0 {
+		return nil
+	}
+	return json.Unmarshal(a.Payload, v)
+}
+
+// CanBeDecidedBy checks the request is still pending and that checkerID is not the maker
+func (a *ApprovalRequest) CanBeDecidedBy(checkerID string, at time.Time) error {
+	if a.Status != ApprovalPending {
+		return fmt.Errorf("%w: status is %s", ErrApprovalNotPending, a.Status)
+	}
+	if !at.Before(a.ExpiresAt) {
+		return ErrApprovalExpired
+	}
+	if checkerID == "" || checkerID == a.MakerID {
+		return ErrSelfApproval
+	}
+	return nil
+}
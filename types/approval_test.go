@@ -0,0 +1,54 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApprovalPolicyRequiresApproval(t *testing.T) {
+	policy := DefaultApprovalPolicy().SetTransferThreshold("usd", decimal.NewFromInt(10000))
+
+	assert.True(t, policy.RequiresApproval(ApprovalCredit, CategoryAdjustment, "USD", decimal.NewFromInt(1)))
+	assert.False(t, policy.RequiresApproval(ApprovalCredit, CategoryDeposit, "USD", decimal.NewFromInt(1)))
+	assert.True(t, policy.RequiresApproval(ApprovalTransfer, CategoryTransfer, "USD", decimal.NewFromInt(10000)))
+	assert.False(t, policy.RequiresApproval(ApprovalTransfer, CategoryTransfer, "USD", decimal.NewFromInt(9999)))
+	assert.False(t, policy.RequiresApproval(ApprovalTransfer, CategoryTransfer, "EUR", decimal.NewFromInt(50000)))
+	assert.True(t, policy.RequiresApproval(ApprovalUnfreeze, "", "", decimal.Zero))
+	assert.True(t, policy.RequiresApproval(ApprovalReopen, "", "", decimal.Zero))
+
+	assert.True(t, policy.CanCheck(&Principal{ID: "cmp_1", Roles: []Role{RoleCompliance}}, ApprovalUnfreeze))
+	assert.False(t, policy.CanCheck(&Principal{ID: "op_1", Roles: []Role{RoleOperator}}, ApprovalUnfreeze))
+}
+
+func TestApprovalRequestPayload(t *testing.T) {
+	transfer := TransferRequest{Amount: decimal.NewFromInt(25000), Fee: decimal.NewFromInt(5), InitiatorID: "op_1"}
+
+	req, err := NewApprovalRequest(ApprovalTransfer, "op_1", "wt_a", "wt_b", transfer, "payroll", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, ApprovalPending, req.Status)
+	assert.True(t, req.Amount.Equal(transfer.Amount))
+	assert.WithinDuration(t, req.CreatedAt.Add(time.Hour), req.ExpiresAt, time.Second)
+
+	var decoded TransferRequest
+	require.NoError(t, req.DecodePayload(&decoded))
+	assert.True(t, decoded.Amount.Equal(transfer.Amount))
+	assert.Equal(t, "op_1", decoded.InitiatorID)
+}
+
+func TestApprovalRequestCanBeDecidedBy(t *testing.T) {
+	req, err := NewApprovalRequest(ApprovalUnfreeze, "op_1", "wt_a", "", nil, "", time.Hour)
+	require.NoError(t, err)
+	now := time.Now()
+
+	assert.ErrorIs(t, req.CanBeDecidedBy("op_1", now), ErrSelfApproval)
+	assert.ErrorIs(t, req.CanBeDecidedBy("", now), ErrSelfApproval)
+	assert.ErrorIs(t, req.CanBeDecidedBy("cmp_1", now.Add(2*time.Hour)), ErrApprovalExpired)
+	assert.NoError(t, req.CanBeDecidedBy("cmp_1", now))
+
+	req.Status = ApprovalRejected
+	assert.ErrorIs(t, req.CanBeDecidedBy("cmp_1", now), ErrApprovalNotPending)
+}
@@ -0,0 +1,154 @@
+package types
+
+import (
+	"context"
+	"fmt"
+)
+
+// Role groups the operations a caller may perform
+type Role string
+
+const (
+	RoleCustomer   Role = "customer"   // Wallet owner acting on their own wallets
+	RoleOperator   Role = "operator"   // Back-office staff
+	RoleCompliance Role = "compliance" // Compliance officers
+	RoleSystem     Role = "system"     // Internal services and jobs
+)
+
+// Action is an operation subject to authorization
+type Action string
+
+const (
+	ActionViewWallet Action = "wallet:view"
+	ActionCredit     Action = "wallet:credit"
+	ActionDebit      Action = "wallet:debit"
+	ActionTransfer   Action = "wallet:transfer"
+	ActionSwap       Action = "wallet:swap"
+	ActionLien       Action = "wallet:lien"
+	ActionFreeze     Action = "wallet:freeze"
+	ActionUnfreeze   Action = "wallet:unfreeze"
+	ActionClose      Action = "wallet:close"
+	ActionReopen     Action = "wallet:reopen"
+	ActionAdjustment Action = "wallet:adjustment"
+	ActionReviewRisk Action = "wallet:review"
+)
+
+// Scope limits which wallets a grant applies to
+type Scope int
+
+const (
+	ScopeNone Scope = iota // Not allowed
+	ScopeOwn               // Only wallets whose CustomerID is the caller
+	ScopeAny               // Any wallet
+)
+
+// Principal is the authenticated caller of an operation
+type Principal struct {
+	ID    string `json:"id"`    // Customer or staff identifier
+	Roles []Role `json:"roles"` // Granted roles
+}
+
+// HasRole reports whether the principal holds the role
+func (p *Principal) HasRole(role Role) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy maps roles to the actions they may perform and on which wallets
+type Policy struct {
+	grants map[Role]map[Action]Scope
+}
+
+// NewPolicy creates an empty policy that denies everything
+func NewPolicy() *Policy {
+	return &Policy{grants: make(map[Role]map[Action]Scope)}
+}
+
+// DefaultPolicy returns the standard policy:
+//   - customers may view, debit, transfer, swap and close their own wallets
+//   - operators may run any balance operation, post adjustments and resolve
+//     risk reviews
+//   - compliance may view, freeze and unfreeze any wallet and resolve risk reviews
+//   - system may view, credit, debit, transfer, swap and lien any wallet
+func DefaultPolicy() *Policy {
+	return NewPolicy().
+		Grant(RoleCustomer, ScopeOwn, ActionViewWallet, ActionDebit, ActionTransfer, ActionSwap, ActionClose).
+		Grant(RoleOperator, ScopeAny, ActionViewWallet, ActionCredit, ActionDebit, ActionTransfer, ActionSwap,
+			ActionLien, ActionClose, ActionReopen, ActionAdjustment, ActionReviewRisk).
+		Grant(RoleCompliance, ScopeAny, ActionViewWallet, ActionFreeze, ActionUnfreeze, ActionReviewRisk).
+		Grant(RoleSystem, ScopeAny, ActionViewWallet, ActionCredit, ActionDebit, ActionTransfer, ActionSwap, ActionLien)
+}
+
+// Grant allows role to perform actions within scope
+func (p *Policy) Grant(role Role, scope Scope, actions ...Action) *Policy {
+	if p.grants[role] == nil {
+		p.grants[role] = make(map[Action]Scope)
+	}
+	for _, action := range actions {
+		p.grants[role][action] = scope
+	}
+	return p
+}
+
+// ScopeFor returns the widest scope any of the principal's roles grants for action
+func (p *Policy) ScopeFor(principal *Principal, action Action) Scope {
+	scope := ScopeNone
+	if principal == nil {
+		return scope
+	}
+	for _, role := range principal.Roles {
+		if s := p.grants[role][action]; s > scope {
+			scope = s
+		}
+	}
+	return scope
+}
+
+// Authorize checks that principal may perform action on wallet.
+// A ScopeOwn grant only covers wallets owned by the principal.
+func (p *Policy) Authorize(principal *Principal, action Action, wallet *Wallet) error {
+	if principal == nil || principal.ID == "" {
+		return fmt.Errorf("%w: no authenticated caller", ErrUnauthorizedAccess)
+	}
+
+	switch p.ScopeFor(principal, action) {
+	case ScopeAny:
+		return nil
+	case ScopeOwn:
+		if wallet != nil && wallet.CustomerID == principal.ID {
+			return nil
+		}
+	}
+
+	walletID := ""
+	if wallet != nil {
+		walletID = wallet.ID
+	}
+	return fmt.Errorf("%w: %s may not perform %s on wallet %s", ErrUnauthorizedAccess, principal.ID, action, walletID)
+}
+
+// ActionForCategory returns the action a balance change of the given category
+// requires. Adjustments need ActionAdjustment whatever their direction.
+func ActionForCategory(category TransactionCategory, action Action) Action {
+	if category == CategoryAdjustment {
+		return ActionAdjustment
+	}
+	return action
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the authenticated caller
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the caller stored by WithPrincipal
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
@@ -0,0 +1,79 @@
+package types
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultPolicy(t *testing.T) {
+	policy := DefaultPolicy()
+	own := &Wallet{ID: "wt_own", CustomerID: "cus_1"}
+	other := &Wallet{ID: "wt_other", CustomerID: "cus_2"}
+
+	customer := &Principal{ID: "cus_1", Roles: []Role{RoleCustomer}}
+	operator := &Principal{ID: "op_1", Roles: []Role{RoleOperator}}
+	compliance := &Principal{ID: "cmp_1", Roles: []Role{RoleCompliance}}
+	system := &Principal{ID: "svc_payouts", Roles: []Role{RoleSystem}}
+
+	tests := []struct {
+		name      string
+		principal *Principal
+		action    Action
+		wallet    *Wallet
+		allowed   bool
+	}{
+		{"customer debits own wallet", customer, ActionDebit, own, true},
+		{"customer debits other wallet", customer, ActionDebit, other, false},
+		{"customer credits own wallet", customer, ActionCredit, own, false},
+		{"customer freezes own wallet", customer, ActionFreeze, own, false},
+		{"operator debits any wallet", operator, ActionDebit, other, true},
+		{"operator posts adjustment", operator, ActionAdjustment, other, true},
+		{"operator freezes wallet", operator, ActionFreeze, other, false},
+		{"compliance freezes wallet", compliance, ActionFreeze, other, true},
+		{"compliance unfreezes wallet", compliance, ActionUnfreeze, other, true},
+		{"compliance debits wallet", compliance, ActionDebit, other, false},
+		{"system credits wallet", system, ActionCredit, other, true},
+		{"system posts adjustment", system, ActionAdjustment, other, false},
+		{"compliance resolves review", compliance, ActionReviewRisk, other, true},
+		{"operator resolves review", operator, ActionReviewRisk, other, true},
+		{"customer resolves review", customer, ActionReviewRisk, own, false},
+		{"anonymous caller", nil, ActionViewWallet, own, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Authorize(tt.principal, tt.action, tt.wallet)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrUnauthorizedAccess)
+			}
+		})
+	}
+}
+
+func TestPolicyWidestScopeWins(t *testing.T) {
+	policy := DefaultPolicy()
+	p := &Principal{ID: "cus_1", Roles: []Role{RoleCustomer, RoleOperator}}
+
+	assert.Equal(t, ScopeAny, policy.ScopeFor(p, ActionDebit))
+	assert.Equal(t, ScopeNone, policy.ScopeFor(p, ActionFreeze))
+	assert.NoError(t, policy.Authorize(p, ActionDebit, &Wallet{ID: "wt_x", CustomerID: "cus_2"}))
+}
+
+func TestActionForCategory(t *testing.T) {
+	assert.Equal(t, ActionAdjustment, ActionForCategory(CategoryAdjustment, ActionCredit))
+	assert.Equal(t, ActionDebit, ActionForCategory(CategoryTransfer, ActionDebit))
+}
+
+func TestPrincipalContext(t *testing.T) {
+	_, ok := PrincipalFromContext(context.Background())
+	assert.False(t, ok)
+
+	p := &Principal{ID: "cus_1", Roles: []Role{RoleCustomer}}
+	got, ok := PrincipalFromContext(WithPrincipal(context.Background(), p))
+	assert.True(t, ok)
+	assert.Same(t, p, got)
+}
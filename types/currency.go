@@ -21,26 +21,26 @@ var (
 
 // CurrencyInfo represents a financial currency with all its properties
 type CurrencyInfo struct {
-	Code             string          `json:"code" bun:",pk"`   // ISO currency code (e.g., "USD")
-	Name             string          `json:"name"`             // Full currency name
-	Symbol           string          `json:"symbol"`           // Currency symbol (e.g., "$")
-	IsFiat           bool            `json:"isFiat"`           // Whether it's a fiat currency
-	IsStableCoin     bool            `json:"isStableCoin"`     // Whether it's a stablecoin
-	IconURL          string          `json:"iconUrl"`          // URL to currency icon
-	Precision        int             `json:"precision"`        // Decimal precision for calculations
-	Disabled         bool            `json:"disabled"`         // Whether currency is disabled
-	CanSell          bool            `json:"canSell"`          // Whether selling is allowed
-	CanBuy           bool            `json:"canBuy"`           // Whether buying is allowed
-	CanSwap          bool            `json:"canSwap"`          // Whether swapping is allowed
-	CanDeposit       bool            `json:"canDeposit"`       // Whether deposits are allowed
-	CanWithdraw      bool            `json:"canWithdraw"`      // Whether withdrawals are allowed
-	FeeDeposit       decimal.Decimal `json:"depositFee"`       // Deposit fee amount
-	FeeWithdrawal    decimal.Decimal `json:"withdrawalFee"`    // Withdrawal fee amount
-	SpreadMarginBuy  decimal.Decimal `json:"spreadMarginBuy"`  // Buy spread margin
-	SpreadMarginSell decimal.Decimal `json:"spreadMarginSell"` // Sell spread margin
-	AutomaticUpdate  bool            `json:"automaticUpdate"`  // Whether rates update automatically
-	CreatedAt        time.Time       `json:"createdAt"`        // When currency was added
-	UpdatedAt        time.Time       `json:"updatedAt"`        // Last update timestamp
+	Code             string          `json:"code" bun:",pk"`                             // ISO currency code (e.g., "USD")
+	Name             string          `json:"name"`                                       // Full currency name
+	Symbol           string          `json:"symbol"`                                     // Currency symbol (e.g., "$")
+	IsFiat           bool            `json:"isFiat"`                                     // Whether it's a fiat currency
+	IsStableCoin     bool            `json:"isStableCoin"`                               // Whether it's a stablecoin
+	IconURL          string          `json:"iconUrl"`                                    // URL to currency icon
+	Precision        int             `json:"precision"`                                  // Decimal precision for calculations
+	Disabled         bool            `json:"disabled"`                                   // Whether currency is disabled
+	CanSell          bool            `json:"canSell"`                                    // Whether selling is allowed
+	CanBuy           bool            `json:"canBuy"`                                     // Whether buying is allowed
+	CanSwap          bool            `json:"canSwap"`                                    // Whether swapping is allowed
+	CanDeposit       bool            `json:"canDeposit"`                                 // Whether deposits are allowed
+	CanWithdraw      bool            `json:"canWithdraw"`                                // Whether withdrawals are allowed
+	FeeDeposit       decimal.Decimal `json:"depositFee" bun:",type:decimal(24,8)"`       // Deposit fee amount
+	FeeWithdrawal    decimal.Decimal `json:"withdrawalFee" bun:",type:decimal(24,8)"`    // Withdrawal fee amount
+	SpreadMarginBuy  decimal.Decimal `json:"spreadMarginBuy" bun:",type:decimal(24,8)"`  // Buy spread margin
+	SpreadMarginSell decimal.Decimal `json:"spreadMarginSell" bun:",type:decimal(24,8)"` // Sell spread margin
+	AutomaticUpdate  bool            `json:"automaticUpdate"`                            // Whether rates update automatically
+	CreatedAt        time.Time       `json:"createdAt"`                                  // When currency was added
+	UpdatedAt        time.Time       `json:"updatedAt"`                                  // Last update timestamp
 }
 
 // FindCurrencyInfo searches for a currency in the given list by its code (case-insensitive)
@@ -74,29 +74,40 @@ type ExchangeRate struct {
 	FromCurrency string          `json:"fromCurrency"` // Source currency code
 	ToCurrency   string          `json:"toCurrency"`   // Target currency code
 	RateType     string          `json:"rateType"`     // Rate type (e.g., "spot")
+	MidRate      decimal.Decimal `json:"midRate"`      // Mid market rate before spreads
 	BuyRate      decimal.Decimal `json:"buyRate"`      // Rate for buying the target currency
 	SellRate     decimal.Decimal `json:"sellRate"`     // Rate for selling the source currency
 	UpdatedAt    time.Time       `json:"updatedAt"`    // When rate was last updated
 	Source       string          `json:"source"`       // Rate source (e.g., "ECB")
+	PricingRule  string          `json:"pricingRule"`  // Rule that priced the rate (e.g., "spread:vip-usd")
 }
 
+// Rate types reported on ExchangeRate and Quote
+const (
+	RateTypeSpot     = "spot"     // Mid rate with spread applied
+	RateTypeOverride = "override" // Manually pinned rate
+)
+
 // Quote represents a currency conversion quote
 type Quote struct {
-	BaseCurrency     string            `json:"baseCurrency"`        // System's base currency
-	FromCurrency     string            `json:"fromCurrency"`        // Currency to convert from
-	FromAmount       decimal.Decimal   `json:"fromAmount"`          // Original amount to convert
-	ToCurrency       string            `json:"toCurrency"`          // Currency to convert to
-	ToAmount         decimal.Decimal   `json:"toAmount"`            // Converted amount
-	NetAmount        decimal.Decimal   `json:"netAmount"`           // Amount after fees
-	Fee              decimal.Decimal   `json:"fee"`                 // Applied fee amount
-	Rate             decimal.Decimal   `json:"rate"`                // Exchange rate used
-	Date             time.Time         `json:"date"`                // Quote generation time
-	FromCurrencyInfo CurrencyInfo      `json:"fromCurrencyInfo"`    // Source currency details
-	ToCurrencyInfo   CurrencyInfo      `json:"toCurrencyInfo"`      // Target currency details
-	Metadata         map[string]string `json:"metadata,omitempty"`  // Additional data
-	ExpiresAt        *time.Time        `json:"expiresAt,omitempty"` // Quote expiration
-	QuoteID          string            `json:"quoteId,omitempty"`   // Unique identifier
-	RateType         string            `json:"rateType,omitempty"`  // Rate type used
+	BaseCurrency     string            `json:"baseCurrency"`           // System's base currency
+	FromCurrency     string            `json:"fromCurrency"`           // Currency to convert from
+	FromAmount       decimal.Decimal   `json:"fromAmount"`             // Original amount to convert
+	ToCurrency       string            `json:"toCurrency"`             // Currency to convert to
+	ToAmount         decimal.Decimal   `json:"toAmount"`               // Converted amount
+	NetAmount        decimal.Decimal   `json:"netAmount"`              // Amount after fees
+	Fee              decimal.Decimal   `json:"fee"`                    // Applied fee amount
+	Rate             decimal.Decimal   `json:"rate"`                   // Exchange rate used
+	MidRate          decimal.Decimal   `json:"midRate"`                // Mid market rate before spreads
+	Date             time.Time         `json:"date"`                   // Quote generation time
+	FromCurrencyInfo CurrencyInfo      `json:"fromCurrencyInfo"`       // Source currency details
+	ToCurrencyInfo   CurrencyInfo      `json:"toCurrencyInfo"`         // Target currency details
+	Metadata         map[string]string `json:"metadata,omitempty"`     // Additional data
+	ExpiresAt        *time.Time        `json:"expiresAt,omitempty"`    // Quote expiration
+	QuoteID          string            `json:"quoteId,omitempty"`      // Unique identifier
+	RateType         string            `json:"rateType,omitempty"`     // Rate type used
+	CustomerTier     string            `json:"customerTier,omitempty"` // Tier the quote was priced for
+	PricingRule      string            `json:"pricingRule,omitempty"`  // Rule that priced the quote
 }
 
 // RateCalculator handles currency rate calculations with spreads and margins
@@ -104,6 +115,7 @@ type RateCalculator struct {
 	baseCurrency string             // System's base currency code (e.g., "USD")
 	currencies   []CurrencyInfo     // List of supported currencies
 	rates        map[string]float64 // Current exchange rates
+	pricing      *PricingSchedule   // Optional tier spreads and rate overrides
 }
 
 // NewRateCalculator creates a new RateCalculator instance
@@ -133,6 +145,12 @@ func NewRateCalculator(baseCurrency string, currencies []CurrencyInfo, rates map
 	}, nil
 }
 
+// SetPricingSchedule attaches tier spreads and rate overrides to the calculator.
+// A nil schedule restores pricing from CurrencyInfo margins only.
+func (rc *RateCalculator) SetPricingSchedule(pricing *PricingSchedule) {
+	rc.pricing = pricing
+}
+
 // CalculateExchangeRate calculates the exchange rate between two currencies including spreads
 // Parameters:
 //   - fromCurrency: Source currency code
@@ -142,6 +160,25 @@ func NewRateCalculator(baseCurrency string, currencies []CurrencyInfo, rates map
 //   - *ExchangeRate containing buy/sell rates
 //   - error if calculation fails
 func (rc *RateCalculator) CalculateExchangeRate(fromCurrency, toCurrency string) (*ExchangeRate, error) {
+	return rc.CalculateCustomerExchangeRate(fromCurrency, toCurrency, "", decimal.Zero)
+}
+
+// CalculateCustomerExchangeRate calculates the exchange rate for a customer tier and amount.
+// Resolution order is: active rate override for the pair, most specific spread rule,
+// then the CurrencyInfo spread margins.
+// Parameters:
+//   - fromCurrency: Source currency code
+//   - toCurrency: Target currency code
+//   - customerTier: Customer pricing tier (empty for untiered pricing)
+//   - amount: Amount in source currency used to select the amount band
+//
+// Returns:
+//   - *ExchangeRate containing buy/sell rates and the pricing rule used
+//   - error if calculation fails
+func (rc *RateCalculator) CalculateCustomerExchangeRate(
+	fromCurrency, toCurrency, customerTier string,
+	amount decimal.Decimal,
+) (*ExchangeRate, error) {
 	// Normalize and validate input
 	fromCurrency = strings.ToUpper(strings.TrimSpace(fromCurrency))
 	toCurrency = strings.ToUpper(strings.TrimSpace(toCurrency))
@@ -163,46 +200,71 @@ func (rc *RateCalculator) CalculateExchangeRate(fromCurrency, toCurrency string)
 		return nil, fmt.Errorf("%w: %s", ErrCurrencyNotFound, toCurrency)
 	}
 
+	midRate, err := rc.midRate(fromCurrency, toCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	rate := &ExchangeRate{
+		CurrencyPair: fmt.Sprintf("%s/%s", fromCurrency, toCurrency),
+		FromCurrency: fromCurrency,
+		ToCurrency:   toCurrency,
+		RateType:     RateTypeSpot,
+		MidRate:      midRate,
+		UpdatedAt:    now,
+	}
+
+	// Manual overrides take precedence over any spread
+	if override, ok := rc.pricing.FindOverride(fromCurrency, toCurrency, now); ok {
+		rate.RateType = RateTypeOverride
+		rate.BuyRate = override.BuyRate
+		rate.SellRate = override.SellRate
+		rate.PricingRule = "override:" + override.ID
+		return rate, nil
+	}
+
+	// Apply spreads to get buy/sell rates
+	spreadBuy, spreadSell := toInfo.SpreadMarginBuy, fromInfo.SpreadMarginSell
+	rate.PricingRule = PricingRuleDefault
+	if rule, ok := rc.pricing.FindSpread(customerTier, fromCurrency, toCurrency, amount); ok {
+		spreadBuy, spreadSell = rule.SpreadMarginBuy, rule.SpreadMarginSell
+		rate.PricingRule = "spread:" + rule.ID
+	}
+
+	rate.BuyRate = midRate.Mul(decimal.NewFromInt(1).Add(spreadBuy))
+	rate.SellRate = midRate.Mul(decimal.NewFromInt(1).Sub(spreadSell))
+
+	return rate, nil
+}
+
+// midRate calculates the mid market rate (without spreads) between two currencies
+func (rc *RateCalculator) midRate(fromCurrency, toCurrency string) (decimal.Decimal, error) {
 	// Get current rates
 	fromRate, ok := rc.rates[fromCurrency]
 	if !ok {
-		return nil, fmt.Errorf("%w: rate for %s not found", ErrCurrencyNotFound, fromCurrency)
+		return decimal.Zero, fmt.Errorf("%w: rate for %s not found", ErrCurrencyNotFound, fromCurrency)
 	}
 	toRate, ok := rc.rates[toCurrency]
 	if !ok {
-		return nil, fmt.Errorf("%w: rate for %s not found", ErrCurrencyNotFound, toCurrency)
+		return decimal.Zero, fmt.Errorf("%w: rate for %s not found", ErrCurrencyNotFound, toCurrency)
 	}
 
 	// Convert to decimal for precise calculations
 	fromRateDec := decimal.NewFromFloat(fromRate)
 	toRateDec := decimal.NewFromFloat(toRate)
 
-	// Calculate mid market rate (without spreads)
-	var midRate decimal.Decimal
 	switch {
 	case fromCurrency == rc.baseCurrency:
 		// Direct conversion from base currency
-		midRate = toRateDec
+		return toRateDec, nil
 	case toCurrency == rc.baseCurrency:
 		// Inverse conversion to base currency
-		midRate = decimal.NewFromInt(1).Div(fromRateDec)
+		return decimal.NewFromInt(1).Div(fromRateDec), nil
 	default:
 		// Cross-currency conversion (neither is base)
-		midRate = decimal.NewFromInt(1).Div(fromRateDec).Mul(toRateDec)
+		return decimal.NewFromInt(1).Div(fromRateDec).Mul(toRateDec), nil
 	}
-
-	// Apply spreads to get buy/sell rates
-	buyRate := midRate.Mul(decimal.NewFromInt(1).Add(toInfo.SpreadMarginBuy))
-	sellRate := midRate.Mul(decimal.NewFromInt(1).Sub(fromInfo.SpreadMarginSell))
-
-	return &ExchangeRate{
-		CurrencyPair: fmt.Sprintf("%s/%s", fromCurrency, toCurrency),
-		FromCurrency: fromCurrency,
-		ToCurrency:   toCurrency,
-		BuyRate:      buyRate,
-		SellRate:     sellRate,
-		UpdatedAt:    time.Now(),
-	}, nil
 }
 
 // findCurrencyInfo finds currency info by code (case-insensitive)
@@ -256,6 +318,59 @@ func NewQuote(
 	fromAmount, fee decimal.Decimal,
 	feeType string,
 ) (*Quote, error) {
+	return NewCustomerQuote(QuoteRequest{
+		Currencies:   ci,
+		Rates:        rates,
+		BaseCurrency: baseCurrency,
+		FromCurrency: fromCurrency,
+		ToCurrency:   toCurrency,
+		FromAmount:   fromAmount,
+		Fee:          fee,
+		FeeType:      feeType,
+	})
+}
+
+// QuoteRequest represents a currency conversion to be priced for a customer
+type QuoteRequest struct {
+	// Currencies lists the available currencies
+	Currencies []CurrencyInfo `json:"currencies"`
+
+	// Rates holds the current exchange rates against BaseCurrency
+	Rates map[string]float64 `json:"rates"`
+
+	// BaseCurrency is the system's base currency
+	BaseCurrency string `json:"baseCurrency"`
+
+	// FromCurrency is the source currency
+	FromCurrency string `json:"fromCurrency"`
+
+	// ToCurrency is the target currency
+	ToCurrency string `json:"toCurrency"`
+
+	// FromAmount is the amount to convert (must be positive)
+	FromAmount decimal.Decimal `json:"fromAmount"`
+
+	// Fee is the conversion fee, either a fixed amount or a percentage
+	Fee decimal.Decimal `json:"fee"`
+
+	// FeeType is NewQuoteFeeTypeFixed or NewQuoteFeeTypePercentage
+	FeeType string `json:"feeType"`
+
+	// Pricing holds tier spreads and rate overrides (nil for CurrencyInfo margins only)
+	Pricing *PricingSchedule `json:"pricing,omitempty"`
+
+	// CustomerTier is the customer's pricing tier
+	CustomerTier string `json:"customerTier"`
+}
+
+// NewCustomerQuote creates a currency conversion quote priced for a customer tier.
+// The returned quote reports the rule that priced it in PricingRule.
+func NewCustomerQuote(req QuoteRequest) (*Quote, error) {
+	ci, rates := req.Currencies, req.Rates
+	baseCurrency, fromCurrency, toCurrency := req.BaseCurrency, req.FromCurrency, req.ToCurrency
+	fromAmount, fee, feeType := req.FromAmount, req.Fee, req.FeeType
+	pricing, customerTier := req.Pricing, req.CustomerTier
+
 	// Validate inputs
 	if len(ci) == 0 {
 		return nil, ErrEmptyCurrencySource
@@ -324,9 +439,10 @@ func NewQuote(
 	if err != nil {
 		return nil, fmt.Errorf("failed to create rate calculator: %w", err)
 	}
+	calculator.SetPricingSchedule(pricing)
 
 	// Calculate exchange rate
-	exchangeRate, err := calculator.CalculateExchangeRate(fromCurrency, toCurrency)
+	exchangeRate, err := calculator.CalculateCustomerExchangeRate(fromCurrency, toCurrency, customerTier, fromAmount)
 	if err != nil {
 		return nil, fmt.Errorf("failed to calculate exchange rate: %w", err)
 	}
@@ -361,11 +477,16 @@ func NewQuote(
 		NetAmount:        toAmount,
 		Fee:              actualFee,
 		Rate:             rate,
+		MidRate:          exchangeRate.MidRate,
 		Date:             time.Now(),
 		FromCurrencyInfo: *fromInfo,
 		ToCurrencyInfo:   *toInfo,
 		Metadata: map[string]string{
-			"feeType": feeType,
+			"feeType":     feeType,
+			"pricingRule": exchangeRate.PricingRule,
 		},
+		RateType:     exchangeRate.RateType,
+		CustomerTier: customerTier,
+		PricingRule:  exchangeRate.PricingRule,
 	}, nil
 }
@@ -0,0 +1,199 @@
+package types
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Pricing rule identifiers reported when no schedule entry matched
+const (
+	PricingRuleDefault = "default" // Spread taken from CurrencyInfo margins
+)
+
+// Error definitions for pricing rules
+var (
+	ErrInvalidSpreadRule   = errors.New("invalid spread rule")
+	ErrInvalidRateOverride = errors.New("invalid rate override")
+)
+
+// SpreadRule defines a spread applied to a customer tier, currency pair and amount band.
+// Empty Tier, FromCurrency or ToCurrency act as wildcards.
+type SpreadRule struct {
+	ID               string          `json:"id"`               // Unique rule identifier
+	CustomerTier     string          `json:"customerTier"`     // Customer tier (e.g., "retail", "vip")
+	FromCurrency     string          `json:"fromCurrency"`     // Source currency code
+	ToCurrency       string          `json:"toCurrency"`       // Target currency code
+	MinAmount        decimal.Decimal `json:"minAmount"`        // Inclusive lower bound (source currency)
+	MaxAmount        decimal.Decimal `json:"maxAmount"`        // Exclusive upper bound, zero for no limit
+	SpreadMarginBuy  decimal.Decimal `json:"spreadMarginBuy"`  // Buy spread margin
+	SpreadMarginSell decimal.Decimal `json:"spreadMarginSell"` // Sell spread margin
+	Priority         int             `json:"priority"`         // Tie breaker, higher wins
+}
+
+// Validate checks the rule for consistency
+func (r SpreadRule) Validate() error {
+	if strings.TrimSpace(r.ID) == "" {
+		return errors.Join(ErrInvalidSpreadRule, errors.New("rule ID is required"))
+	}
+	if r.MinAmount.IsNegative() || r.MaxAmount.IsNegative() {
+		return errors.Join(ErrInvalidSpreadRule, errors.New("amount band cannot be negative"))
+	}
+	if !r.MaxAmount.IsZero() && r.MaxAmount.LessThanOrEqual(r.MinAmount) {
+		return errors.Join(ErrInvalidSpreadRule, errors.New("max amount must exceed min amount"))
+	}
+	if r.SpreadMarginBuy.IsNegative() || r.SpreadMarginSell.IsNegative() {
+		return errors.Join(ErrInvalidSpreadRule, errors.New("spread margins cannot be negative"))
+	}
+	return nil
+}
+
+// matches reports whether the rule applies to the tier, pair and amount
+func (r SpreadRule) matches(tier, fromCurrency, toCurrency string, amount decimal.Decimal) bool {
+	if r.CustomerTier != "" && !strings.EqualFold(r.CustomerTier, tier) {
+		return false
+	}
+	if r.FromCurrency != "" && !strings.EqualFold(r.FromCurrency, fromCurrency) {
+		return false
+	}
+	if r.ToCurrency != "" && !strings.EqualFold(r.ToCurrency, toCurrency) {
+		return false
+	}
+	if amount.LessThan(r.MinAmount) {
+		return false
+	}
+	if !r.MaxAmount.IsZero() && amount.GreaterThanOrEqual(r.MaxAmount) {
+		return false
+	}
+	return true
+}
+
+// specificity scores how narrowly the rule is scoped; a tier match outranks any
+// pair or band match and more specific rules win
+func (r SpreadRule) specificity() int {
+	score := 0
+	if r.CustomerTier != "" {
+		score += 8
+	}
+	if r.FromCurrency != "" {
+		score += 2
+	}
+	if r.ToCurrency != "" {
+		score += 2
+	}
+	if !r.MinAmount.IsZero() || !r.MaxAmount.IsZero() {
+		score++
+	}
+	return score
+}
+
+// RateOverride pins the buy/sell rates of a currency pair for a validity window
+type RateOverride struct {
+	ID           string          `json:"id"`           // Unique override identifier
+	FromCurrency string          `json:"fromCurrency"` // Source currency code
+	ToCurrency   string          `json:"toCurrency"`   // Target currency code
+	BuyRate      decimal.Decimal `json:"buyRate"`      // Rate for buying the target currency
+	SellRate     decimal.Decimal `json:"sellRate"`     // Rate for selling the source currency
+	ValidFrom    time.Time       `json:"validFrom"`    // Start of validity (inclusive)
+	ValidUntil   time.Time       `json:"validUntil"`   // End of validity (exclusive), zero for open-ended
+	Reason       string          `json:"reason"`       // Why the override was set
+	CreatedBy    string          `json:"createdBy"`    // Who set the override
+}
+
+// Validate checks the override for consistency
+func (o RateOverride) Validate() error {
+	if strings.TrimSpace(o.ID) == "" {
+		return errors.Join(ErrInvalidRateOverride, errors.New("override ID is required"))
+	}
+	if o.FromCurrency == "" || o.ToCurrency == "" {
+		return errors.Join(ErrInvalidRateOverride, ErrInvalidCurrencyPair)
+	}
+	if !o.BuyRate.IsPositive() || !o.SellRate.IsPositive() {
+		return errors.Join(ErrInvalidRateOverride, ErrInvalidExchangeRate)
+	}
+	if !o.ValidUntil.IsZero() && !o.ValidUntil.After(o.ValidFrom) {
+		return errors.Join(ErrInvalidRateOverride, errors.New("validity window is empty"))
+	}
+	return nil
+}
+
+// IsActive reports whether the override applies at the given time
+func (o RateOverride) IsActive(at time.Time) bool {
+	if at.Before(o.ValidFrom) {
+		return false
+	}
+	if !o.ValidUntil.IsZero() && !at.Before(o.ValidUntil) {
+		return false
+	}
+	return true
+}
+
+// PricingSchedule holds the spread rules and rate overrides used to price quotes
+type PricingSchedule struct {
+	Spreads   []SpreadRule   `json:"spreads"`   // Tier/pair/amount spread rules
+	Overrides []RateOverride `json:"overrides"` // Manual pair-level rate overrides
+}
+
+// NewPricingSchedule validates and creates a PricingSchedule
+func NewPricingSchedule(spreads []SpreadRule, overrides []RateOverride) (*PricingSchedule, error) {
+	for _, rule := range spreads {
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	for _, override := range overrides {
+		if err := override.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &PricingSchedule{Spreads: spreads, Overrides: overrides}, nil
+}
+
+// FindOverride returns the active override for the pair at the given time.
+// When several are active, the one that started most recently wins.
+func (ps *PricingSchedule) FindOverride(fromCurrency, toCurrency string, at time.Time) (*RateOverride, bool) {
+	if ps == nil {
+		return nil, false
+	}
+
+	var found *RateOverride
+	for i := range ps.Overrides {
+		override := ps.Overrides[i]
+		if !strings.EqualFold(override.FromCurrency, fromCurrency) ||
+			!strings.EqualFold(override.ToCurrency, toCurrency) ||
+			!override.IsActive(at) {
+			continue
+		}
+		if found == nil || override.ValidFrom.After(found.ValidFrom) {
+			found = &override
+		}
+	}
+
+	return found, found != nil
+}
+
+// FindSpread returns the most specific spread rule for the tier, pair and amount.
+// Ties on specificity are broken by Priority.
+func (ps *PricingSchedule) FindSpread(tier, fromCurrency, toCurrency string, amount decimal.Decimal) (*SpreadRule, bool) {
+	if ps == nil {
+		return nil, false
+	}
+
+	var found *SpreadRule
+	for i := range ps.Spreads {
+		rule := ps.Spreads[i]
+		if !rule.matches(tier, fromCurrency, toCurrency, amount) {
+			continue
+		}
+		if found == nil ||
+			rule.specificity() > found.specificity() ||
+			(rule.specificity() == found.specificity() && rule.Priority > found.Priority) {
+			found = &rule
+		}
+	}
+
+	return found, found != nil
+}
@@ -0,0 +1,100 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculateCustomerExchangeRate(t *testing.T) {
+	currencies := []CurrencyInfo{
+		{Code: "USD", Precision: 2, SpreadMarginBuy: decimal.NewFromFloat(0.01), SpreadMarginSell: decimal.NewFromFloat(0.01)},
+		{Code: "EUR", Precision: 2, SpreadMarginBuy: decimal.NewFromFloat(0.02), SpreadMarginSell: decimal.NewFromFloat(0.02)},
+	}
+	rates := map[string]float64{"USD": 1.0, "EUR": 0.8}
+
+	pricing, err := NewPricingSchedule(
+		[]SpreadRule{
+			{ID: "all-usd-eur", FromCurrency: "USD", ToCurrency: "EUR", SpreadMarginBuy: decimal.NewFromFloat(0.015)},
+			{ID: "vip", CustomerTier: "vip", SpreadMarginBuy: decimal.NewFromFloat(0.005)},
+			{
+				ID: "vip-large", CustomerTier: "vip", FromCurrency: "USD", ToCurrency: "EUR",
+				MinAmount: decimal.NewFromInt(10000), SpreadMarginBuy: decimal.NewFromFloat(0.001),
+			},
+		},
+		nil,
+	)
+	assert.NoError(t, err)
+
+	rc, err := NewRateCalculator("USD", currencies, rates)
+	assert.NoError(t, err)
+	rc.SetPricingSchedule(pricing)
+
+	t.Run("default margins without matching rule", func(t *testing.T) {
+		rate, err := rc.CalculateCustomerExchangeRate("EUR", "USD", "retail", decimal.NewFromInt(100))
+		assert.NoError(t, err)
+		assert.Equal(t, PricingRuleDefault, rate.PricingRule)
+	})
+
+	t.Run("pair rule for untiered customer", func(t *testing.T) {
+		rate, err := rc.CalculateCustomerExchangeRate("USD", "EUR", "", decimal.NewFromInt(100))
+		assert.NoError(t, err)
+		assert.Equal(t, "spread:all-usd-eur", rate.PricingRule)
+		assert.True(t, rate.BuyRate.Equal(decimal.NewFromFloat(0.812)), "got %s", rate.BuyRate)
+	})
+
+	t.Run("most specific tier rule wins", func(t *testing.T) {
+		rate, err := rc.CalculateCustomerExchangeRate("USD", "EUR", "vip", decimal.NewFromInt(100))
+		assert.NoError(t, err)
+		assert.Equal(t, "spread:vip", rate.PricingRule)
+
+		rate, err = rc.CalculateCustomerExchangeRate("USD", "EUR", "VIP", decimal.NewFromInt(20000))
+		assert.NoError(t, err)
+		assert.Equal(t, "spread:vip-large", rate.PricingRule)
+		assert.True(t, rate.BuyRate.Equal(decimal.NewFromFloat(0.8008)), "got %s", rate.BuyRate)
+	})
+
+	t.Run("active override takes precedence", func(t *testing.T) {
+		now := time.Now()
+		withOverride, err := NewPricingSchedule(pricing.Spreads, []RateOverride{
+			{
+				ID: "expired", FromCurrency: "USD", ToCurrency: "EUR",
+				BuyRate: decimal.NewFromFloat(0.7), SellRate: decimal.NewFromFloat(0.7),
+				ValidFrom: now.Add(-2 * time.Hour), ValidUntil: now.Add(-time.Hour),
+			},
+			{
+				ID: "desk", FromCurrency: "USD", ToCurrency: "EUR",
+				BuyRate: decimal.NewFromFloat(0.9), SellRate: decimal.NewFromFloat(0.88),
+				ValidFrom: now.Add(-time.Minute),
+			},
+		})
+		assert.NoError(t, err)
+
+		quote, err := NewCustomerQuote(QuoteRequest{
+			Currencies:   currencies,
+			Rates:        rates,
+			BaseCurrency: "USD",
+			FromCurrency: "USD",
+			ToCurrency:   "EUR",
+			FromAmount:   decimal.NewFromInt(100),
+			Fee:          decimal.Zero,
+			FeeType:      NewQuoteFeeTypeFixed,
+			Pricing:      withOverride,
+			CustomerTier: "vip",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "override:desk", quote.PricingRule)
+		assert.Equal(t, RateTypeOverride, quote.RateType)
+		assert.True(t, quote.ToAmount.Equal(decimal.NewFromInt(90)), "got %s", quote.ToAmount)
+	})
+
+	t.Run("invalid rules rejected", func(t *testing.T) {
+		_, err := NewPricingSchedule([]SpreadRule{{ID: "bad", MinAmount: decimal.NewFromInt(10), MaxAmount: decimal.NewFromInt(5)}}, nil)
+		assert.ErrorIs(t, err, ErrInvalidSpreadRule)
+
+		_, err = NewPricingSchedule(nil, []RateOverride{{ID: "bad", FromCurrency: "USD", ToCurrency: "EUR"}})
+		assert.ErrorIs(t, err, ErrInvalidRateOverride)
+	})
+}
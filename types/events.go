@@ -0,0 +1,82 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// EventType names a domain event written to the outbox
+type EventType string
+
+const (
+	EventWalletCreated       EventType = "WalletCreated"       // A wallet was opened
+	EventWalletCredited      EventType = "WalletCredited"      // Funds were added to a wallet
+	EventWalletDebited       EventType = "WalletDebited"       // Funds were taken from a wallet
+	EventTransferCompleted   EventType = "TransferCompleted"   // Funds moved between two wallets
+	EventSwapCompleted       EventType = "SwapCompleted"       // Funds were exchanged between currencies
+	EventSplitCompleted      EventType = "SplitCompleted"      // Funds were split from one wallet among several
+	EventLienPlaced          EventType = "LienPlaced"          // Funds were held on a wallet
+	EventLienReleased        EventType = "LienReleased"        // Held funds were released
+	EventWalletFrozen        EventType = "WalletFrozen"        // Debits were blocked on a wallet
+	EventWalletUnfrozen      EventType = "WalletUnfrozen"      // A freeze was lifted
+	EventWalletClosed        EventType = "WalletClosed"        // A wallet was closed
+	EventWalletReopened      EventType = "WalletReopened"      // A closed wallet was reopened
+	EventTransactionReleased EventType = "TransactionReleased" // A held transaction was completed after review
+	EventTransactionReversed EventType = "TransactionReversed" // A held transaction was rejected after review
+)
+
+// OutboxEvent is a domain event stored in the same database transaction as the
+// change it describes, waiting to be published.
+//
+// ID is assigned when the event is inserted, so an event with a lower ID can
+// commit after one with a higher ID. PublishSequence is assigned by the relay
+// as it publishes, one event at a time, so it grows in commit order and is
+// the position readers resume from.
+type OutboxEvent struct {
+	ID              int64           `json:"id" bun:",pk,autoincrement"`                       // Insertion order
+	EventID         string          `json:"eventId" bun:",unique,notnull"`                    // Unique event ID consumers deduplicate on
+	Type            EventType       `json:"type" bun:",notnull"`                              // What happened
+	AggregateID     string          `json:"aggregateId" bun:",notnull"`                       // Wallet the event belongs to
+	Payload         json.RawMessage `json:"payload" bun:"type:json,notnull"`                  // Event body
+	OccurredAt      time.Time       `json:"occurredAt" bun:",notnull"`                        // When the change happened
+	PublishSequence int64           `json:"publishSequence,omitempty" bun:",nullzero,unique"` // Publish order, assigned by the relay
+	PublishedAt     time.Time       `json:"publishedAt,omitempty" bun:",nullzero"`            // When the relay delivered it
+	Attempts        int             `json:"attempts" bun:",notnull,default:0"`                // Failed delivery attempts
+	LastError       string          `json:"lastError,omitempty" bun:",nullzero"`              // Error of the last failed attempt
+}
+
+// WalletEvent is the payload of every wallet event. Fields that do not apply
+// to an event type are left empty.
+type WalletEvent struct {
+	Wallet       *Wallet               `json:"wallet"`                 // Wallet state after the change
+	Counterparty *Wallet               `json:"counterparty,omitempty"` // Other wallet of a transfer or swap, or the source of a split
+	Transactions []*TransactionHistory `json:"transactions,omitempty"` // Rows the change wrote
+	Lien         *LienRecord           `json:"lien,omitempty"`         // Lien placed or released
+	Reason       string                `json:"reason,omitempty"`       // Why the change was made
+}
+
+// NewOutboxEvent builds an unpublished event with a JSON payload
+func NewOutboxEvent(eventType EventType, aggregateID string, payload any) (*OutboxEvent, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	return &OutboxEvent{
+		EventID:     GenerateID("evt_", 15),
+		Type:        eventType,
+		AggregateID: aggregateID,
+		Payload:     body,
+		OccurredAt:  time.Now().UTC(),
+	}, nil
+}
+
+// DecodeWalletEvent decodes the payload of a wallet event
+func (e *OutboxEvent) DecodeWalletEvent() (*WalletEvent, error) {
+	payload := new(WalletEvent)
+	if err := json.Unmarshal(e.Payload, payload); err != nil {
+		return nil, fmt.Errorf("failed to decode %s event: %w", e.Type, err)
+	}
+	return payload, nil
+}
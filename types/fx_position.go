@@ -0,0 +1,266 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Error definitions for FX reporting
+var (
+	ErrInvalidReportWindow = errors.New("report window end must be after start")
+)
+
+// FXTrade records both legs of a swap from the house's point of view.
+// The house receives the source leg and pays out the destination leg.
+type FXTrade struct {
+	ID                  string          `json:"id" bun:",pk"`                                      // Unique trade ID
+	CustomerID          string          `json:"customerId" bun:",notnull"`                         // Customer who swapped
+	SourceWalletID      string          `json:"sourceWalletId" bun:",notnull"`                     // Debited wallet
+	DestWalletID        string          `json:"destWalletId" bun:",notnull"`                       // Credited wallet
+	SourceTransactionID string          `json:"sourceTransactionId" bun:",notnull"`                // Debit leg transaction
+	DestTransactionID   string          `json:"destTransactionId" bun:",notnull"`                  // Credit leg transaction
+	FromCurrency        string          `json:"fromCurrency" bun:",notnull"`                       // Currency received by the house
+	ToCurrency          string          `json:"toCurrency" bun:",notnull"`                         // Currency paid out by the house
+	FromAmount          decimal.Decimal `json:"fromAmount" bun:",type:decimal(24,8),notnull"`      // Amount received
+	ToAmount            decimal.Decimal `json:"toAmount" bun:",type:decimal(24,8),notnull"`        // Amount paid out
+	Fee                 decimal.Decimal `json:"fee" bun:",type:decimal(24,8),notnull"`             // Fee charged in FromCurrency
+	AppliedRate         decimal.Decimal `json:"appliedRate" bun:",type:decimal(24,12),notnull"`    // Rate given to the customer
+	MidRate             decimal.Decimal `json:"midRate" bun:",type:decimal(24,12),notnull"`        // Mid market rate at pricing time
+	SourceBaseRate      decimal.Decimal `json:"sourceBaseRate" bun:",type:decimal(24,12),notnull"` // Base value of one FromCurrency unit
+	TradedAt            time.Time       `json:"tradedAt" bun:",notnull"`                           // Execution time
+}
+
+// NewFXTrade builds an FXTrade from a completed swap and its transaction legs
+func NewFXTrade(customerID string, req SwapRequest, sourceTx, destTx *TransactionHistory) *FXTrade {
+	midRate := req.MidRate
+	if midRate.IsZero() {
+		midRate = req.ExchangeRate // No mid rate supplied, no spread can be attributed
+	}
+
+	return &FXTrade{
+		ID:                  GenerateID("fxt_", 15),
+		CustomerID:          customerID,
+		SourceWalletID:      sourceTx.WalletID,
+		DestWalletID:        destTx.WalletID,
+		SourceTransactionID: sourceTx.ID,
+		DestTransactionID:   destTx.ID,
+		FromCurrency:        sourceTx.CurrencyCode,
+		ToCurrency:          destTx.CurrencyCode,
+		FromAmount:          req.SourceAmount,
+		ToAmount:            req.DestinationAmount,
+		Fee:                 req.Fee,
+		AppliedRate:         req.ExchangeRate,
+		MidRate:             midRate,
+		SourceBaseRate:      req.SourceBaseRate,
+		TradedAt:            sourceTx.CreatedAt,
+	}
+}
+
+// SpreadRevenue returns the realised spread earned on the trade in ToCurrency
+func (t *FXTrade) SpreadRevenue() decimal.Decimal {
+	return t.FromAmount.Mul(t.MidRate).Sub(t.ToAmount)
+}
+
+// CurrencyPosition is the house's net position in a single currency
+type CurrencyPosition struct {
+	CurrencyCode      string          `json:"currencyCode"`      // Currency of the position
+	Bought            decimal.Decimal `json:"bought"`            // Total received from customers
+	Sold              decimal.Decimal `json:"sold"`              // Total paid out to customers
+	NetPosition       decimal.Decimal `json:"netPosition"`       // Bought minus sold (long when positive)
+	FeeRevenue        decimal.Decimal `json:"feeRevenue"`        // Swap fees collected in this currency
+	SpreadRevenue     decimal.Decimal `json:"spreadRevenue"`     // Realised spread earned in this currency
+	CostBasis         decimal.Decimal `json:"costBasis"`         // Position value in base at trade-time rates
+	MarketValue       decimal.Decimal `json:"marketValue"`       // Position value in base at current rates
+	UnrealisedPnL     decimal.Decimal `json:"unrealisedPnl"`     // MarketValue minus CostBasis
+	TradeCount        int             `json:"tradeCount"`        // Number of trades touching this currency
+	RealisedPnLInBase decimal.Decimal `json:"realisedPnlInBase"` // Spread and fee revenue valued in base
+}
+
+// FXDailyPositions holds the positions opened during one calendar day (UTC)
+type FXDailyPositions struct {
+	Date      string              `json:"date"`      // Day in YYYY-MM-DD format
+	Positions []*CurrencyPosition `json:"positions"` // Net positions for that day
+}
+
+// FXPositionReport summarises FX exposure and P&L for a reporting window
+type FXPositionReport struct {
+	BaseCurrency       string              `json:"baseCurrency"`       // Currency P&L is expressed in
+	From               time.Time           `json:"from"`               // Window start (inclusive)
+	To                 time.Time           `json:"to"`                 // Window end (exclusive)
+	TradeCount         int                 `json:"tradeCount"`         // Trades in the window
+	Positions          []*CurrencyPosition `json:"positions"`          // Net positions for the window
+	Daily              []FXDailyPositions  `json:"daily"`              // Per-day breakdown
+	TotalRealisedPnL   decimal.Decimal     `json:"totalRealisedPnl"`   // Realised P&L in base
+	TotalUnrealisedPnL decimal.Decimal     `json:"totalUnrealisedPnl"` // Unrealised P&L in base
+	GeneratedAt        time.Time           `json:"generatedAt"`        // When the report was built
+}
+
+// GenerateFXPositionReport aggregates swap trades into per-currency net positions,
+// realised spread and fee revenue, and revalues open positions at current rates.
+//
+// Parameters:
+//   - trades: Swap trades to aggregate (trades outside the window are ignored)
+//   - baseCurrency: Currency to express P&L in
+//   - currentRates: Current rates, base currency -> currency (same convention as RateCalculator)
+//   - from: Window start (inclusive)
+//   - to: Window end (exclusive)
+//
+// Returns:
+//   - *FXPositionReport with window totals and a per-day breakdown
+//   - error if the window is invalid or a rate is missing
+func GenerateFXPositionReport(
+	trades []*FXTrade,
+	baseCurrency string,
+	currentRates map[string]float64,
+	from, to time.Time,
+) (*FXPositionReport, error) {
+	if !to.After(from) {
+		return nil, ErrInvalidReportWindow
+	}
+	baseCurrency = strings.ToUpper(strings.TrimSpace(baseCurrency))
+	if baseCurrency == "" {
+		return nil, ErrBaseCurrencyNotFound
+	}
+
+	// Keep trades inside the window, oldest first
+	var inWindow []*FXTrade
+	for _, trade := range trades {
+		if trade == nil || trade.TradedAt.Before(from) || !trade.TradedAt.Before(to) {
+			continue
+		}
+		inWindow = append(inWindow, trade)
+	}
+	sort.Slice(inWindow, func(i, j int) bool {
+		return inWindow[i].TradedAt.Before(inWindow[j].TradedAt)
+	})
+
+	report := &FXPositionReport{
+		BaseCurrency:       baseCurrency,
+		From:               from,
+		To:                 to,
+		TradeCount:         len(inWindow),
+		TotalRealisedPnL:   decimal.Zero,
+		TotalUnrealisedPnL: decimal.Zero,
+		GeneratedAt:        time.Now().UTC(),
+	}
+
+	positions, err := aggregatePositions(inWindow, baseCurrency, currentRates)
+	if err != nil {
+		return nil, err
+	}
+	report.Positions = positions
+	for _, pos := range positions {
+		report.TotalRealisedPnL = report.TotalRealisedPnL.Add(pos.RealisedPnLInBase)
+		report.TotalUnrealisedPnL = report.TotalUnrealisedPnL.Add(pos.UnrealisedPnL)
+	}
+
+	// Per-day breakdown
+	byDay := make(map[string][]*FXTrade)
+	var days []string
+	for _, trade := range inWindow {
+		day := trade.TradedAt.UTC().Format(time.DateOnly)
+		if _, ok := byDay[day]; !ok {
+			days = append(days, day)
+		}
+		byDay[day] = append(byDay[day], trade)
+	}
+	for _, day := range days {
+		dayPositions, err := aggregatePositions(byDay[day], baseCurrency, currentRates)
+		if err != nil {
+			return nil, err
+		}
+		report.Daily = append(report.Daily, FXDailyPositions{Date: day, Positions: dayPositions})
+	}
+
+	return report, nil
+}
+
+// aggregatePositions folds trades into per-currency positions sorted by currency code
+func aggregatePositions(trades []*FXTrade, baseCurrency string, currentRates map[string]float64) ([]*CurrencyPosition, error) {
+	positions := make(map[string]*CurrencyPosition)
+	get := func(code string) *CurrencyPosition {
+		pos, ok := positions[code]
+		if !ok {
+			pos = &CurrencyPosition{
+				CurrencyCode:      code,
+				Bought:            decimal.Zero,
+				Sold:              decimal.Zero,
+				NetPosition:       decimal.Zero,
+				FeeRevenue:        decimal.Zero,
+				SpreadRevenue:     decimal.Zero,
+				CostBasis:         decimal.Zero,
+				MarketValue:       decimal.Zero,
+				UnrealisedPnL:     decimal.Zero,
+				RealisedPnLInBase: decimal.Zero,
+			}
+			positions[code] = pos
+		}
+		return pos
+	}
+
+	for _, trade := range trades {
+		// Trade-time base value of one FromCurrency unit; fall back to the
+		// current rate when it was not captured, which leaves no unrealised P&L.
+		// The destination leg is valued at the mid rate so the spread is realised.
+		sourceBaseRate := trade.SourceBaseRate
+		if sourceBaseRate.IsZero() {
+			rate, err := baseValueRate(trade.FromCurrency, baseCurrency, currentRates)
+			if err != nil {
+				return nil, err
+			}
+			sourceBaseRate = rate
+		}
+		destBaseRate := sourceBaseRate.Div(trade.MidRate)
+
+		received := trade.FromAmount.Add(trade.Fee)
+		src := get(trade.FromCurrency)
+		src.Bought = src.Bought.Add(received)
+		src.NetPosition = src.NetPosition.Add(received)
+		src.FeeRevenue = src.FeeRevenue.Add(trade.Fee)
+		src.CostBasis = src.CostBasis.Add(received.Mul(sourceBaseRate))
+		src.RealisedPnLInBase = src.RealisedPnLInBase.Add(trade.Fee.Mul(sourceBaseRate))
+		src.TradeCount++
+
+		spread := trade.SpreadRevenue()
+		dst := get(trade.ToCurrency)
+		dst.Sold = dst.Sold.Add(trade.ToAmount)
+		dst.NetPosition = dst.NetPosition.Sub(trade.ToAmount)
+		dst.SpreadRevenue = dst.SpreadRevenue.Add(spread)
+		dst.CostBasis = dst.CostBasis.Sub(trade.ToAmount.Mul(destBaseRate))
+		dst.RealisedPnLInBase = dst.RealisedPnLInBase.Add(spread.Mul(destBaseRate))
+		dst.TradeCount++
+	}
+
+	result := make([]*CurrencyPosition, 0, len(positions))
+	for _, pos := range positions {
+		rate, err := baseValueRate(pos.CurrencyCode, baseCurrency, currentRates)
+		if err != nil {
+			return nil, err
+		}
+		pos.MarketValue = pos.NetPosition.Mul(rate)
+		pos.UnrealisedPnL = pos.MarketValue.Sub(pos.CostBasis)
+		result = append(result, pos)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CurrencyCode < result[j].CurrencyCode
+	})
+
+	return result, nil
+}
+
+// baseValueRate returns the base currency value of one unit of currencyCode
+func baseValueRate(currencyCode, baseCurrency string, rates map[string]float64) (decimal.Decimal, error) {
+	if strings.EqualFold(currencyCode, baseCurrency) {
+		return decimal.NewFromInt(1), nil
+	}
+	rate, ok := rates[strings.ToUpper(currencyCode)]
+	if !ok || rate <= 0 {
+		return decimal.Zero, fmt.Errorf("%w: rate for %s not found", ErrCurrencyNotFound, currencyCode)
+	}
+	return decimal.NewFromInt(1).Div(decimal.NewFromFloat(rate)), nil
+}
@@ -0,0 +1,80 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateFXPositionReport(t *testing.T) {
+	day1 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+
+	trades := []*FXTrade{
+		{
+			// Customer sells 100 USD for EUR at 0.79, mid 0.80
+			FromCurrency: "USD", ToCurrency: "EUR",
+			FromAmount: decimal.NewFromInt(100), ToAmount: decimal.NewFromInt(79),
+			Fee: decimal.NewFromInt(1), AppliedRate: decimal.NewFromFloat(0.79),
+			MidRate: decimal.NewFromFloat(0.8), SourceBaseRate: decimal.NewFromInt(1),
+			TradedAt: day1,
+		},
+		{
+			// Customer sells 40 EUR for USD at 1.2, mid 1.25
+			FromCurrency: "EUR", ToCurrency: "USD",
+			FromAmount: decimal.NewFromInt(40), ToAmount: decimal.NewFromInt(48),
+			Fee: decimal.Zero, AppliedRate: decimal.NewFromFloat(1.2),
+			MidRate: decimal.NewFromFloat(1.25), SourceBaseRate: decimal.NewFromFloat(1.25),
+			TradedAt: day2,
+		},
+		{
+			// Outside the window
+			FromCurrency: "USD", ToCurrency: "EUR",
+			FromAmount: decimal.NewFromInt(1000), ToAmount: decimal.NewFromInt(800),
+			AppliedRate: decimal.NewFromFloat(0.8), MidRate: decimal.NewFromFloat(0.8),
+			TradedAt: day2.AddDate(0, 0, 5),
+		},
+	}
+
+	t.Run("positions and realised pnl", func(t *testing.T) {
+		// Rates unchanged since the trades, so nothing is unrealised
+		report, err := GenerateFXPositionReport(trades, "usd", map[string]float64{"EUR": 0.8}, day1, day2.Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, report.TradeCount)
+		assert.Len(t, report.Daily, 2)
+		assert.Len(t, report.Positions, 2)
+
+		eur, usd := report.Positions[0], report.Positions[1]
+		assert.Equal(t, "EUR", eur.CurrencyCode)
+		assert.True(t, eur.NetPosition.Equal(decimal.NewFromInt(-39)), "got %s", eur.NetPosition)
+		assert.True(t, eur.SpreadRevenue.Equal(decimal.NewFromInt(1)), "got %s", eur.SpreadRevenue)
+
+		assert.Equal(t, "USD", usd.CurrencyCode)
+		assert.True(t, usd.NetPosition.Equal(decimal.NewFromInt(53)), "got %s", usd.NetPosition)
+		assert.True(t, usd.FeeRevenue.Equal(decimal.NewFromInt(1)), "got %s", usd.FeeRevenue)
+		assert.True(t, usd.SpreadRevenue.Equal(decimal.NewFromInt(2)), "got %s", usd.SpreadRevenue)
+
+		// 1 EUR spread (1.25 USD) + 1 USD fee + 2 USD spread
+		assert.True(t, report.TotalRealisedPnL.Equal(decimal.NewFromFloat(4.25)), "got %s", report.TotalRealisedPnL)
+		assert.True(t, report.TotalUnrealisedPnL.IsZero(), "got %s", report.TotalUnrealisedPnL)
+	})
+
+	t.Run("revaluation of open positions", func(t *testing.T) {
+		// EUR strengthens to 1 EUR = 1.6 USD; the house is short 39 EUR
+		report, err := GenerateFXPositionReport(trades, "USD", map[string]float64{"EUR": 0.625}, day1, day2.Add(time.Hour))
+		assert.NoError(t, err)
+		assert.True(t, report.TotalUnrealisedPnL.Equal(decimal.NewFromFloat(-13.65)), "got %s", report.TotalUnrealisedPnL)
+	})
+
+	t.Run("invalid window", func(t *testing.T) {
+		_, err := GenerateFXPositionReport(trades, "USD", nil, day2, day1)
+		assert.ErrorIs(t, err, ErrInvalidReportWindow)
+	})
+
+	t.Run("missing rate", func(t *testing.T) {
+		_, err := GenerateFXPositionReport(trades, "USD", map[string]float64{}, day1, day2.Add(time.Hour))
+		assert.ErrorIs(t, err, ErrCurrencyNotFound)
+	})
+}
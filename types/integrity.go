@@ -0,0 +1,274 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// IntegrityIssueKind classifies a ledger integrity problem
+type IntegrityIssueKind string
+
+const (
+	IssueBalanceDrift      IntegrityIssueKind = "BALANCE_DRIFT"      // Wallet balance differs from the replayed ledger
+	IssueBrokenChain       IntegrityIssueKind = "BROKEN_CHAIN"       // Row's BalanceBefore differs from the previous BalanceAfter
+	IssueRowArithmetic     IntegrityIssueKind = "ROW_ARITHMETIC"     // Row's BalanceAfter differs from BalanceBefore plus its effect
+	IssueNegativeBalance   IntegrityIssueKind = "NEGATIVE_BALANCE"   // A balance went below zero
+	IssueOrphanTransaction IntegrityIssueKind = "ORPHAN_TRANSACTION" // Transaction for a wallet that does not exist
+)
+
+// IntegrityIssue describes a single problem found while replaying a wallet
+type IntegrityIssue struct {
+	Kind          IntegrityIssueKind `json:"kind"`                    // Problem type
+	WalletID      string             `json:"walletId"`                // Affected wallet
+	TransactionID string             `json:"transactionId,omitempty"` // Offending row, if any
+	Expected      decimal.Decimal    `json:"expected"`                // Value implied by the ledger
+	Actual        decimal.Decimal    `json:"actual"`                  // Value found
+	Detail        string             `json:"detail"`                  // Human-readable explanation
+}
+
+// WalletReplay is the result of replaying a single wallet's ledger
+type WalletReplay struct {
+	WalletID          string            `json:"walletId"`          // Replayed wallet
+	TransactionCount  int               `json:"transactionCount"`  // Rows replayed
+	ReplayedAvailable decimal.Decimal   `json:"replayedAvailable"` // Available balance implied by rows and liens
+	ReplayedLien      decimal.Decimal   `json:"replayedLien"`      // Lien balance implied by lien records
+	AvailableDrift    decimal.Decimal   `json:"availableDrift"`    // Wallet available minus replayed available
+	LienDrift         decimal.Decimal   `json:"lienDrift"`         // Wallet lien minus replayed lien
+	LastBalanceAfter  decimal.Decimal   `json:"lastBalanceAfter"`  // BalanceAfter of the last replayed row
+	Issues            []*IntegrityIssue `json:"issues,omitempty"`  // Problems found
+}
+
+// HasDrift reports whether the wallet balances disagree with the ledger
+func (r *WalletReplay) HasDrift() bool {
+	return !r.AvailableDrift.IsZero() || !r.LienDrift.IsZero()
+}
+
+// ledgerEvent is a balance-affecting event in a wallet's history
+type ledgerEvent struct {
+	at   time.Time
+	id   string
+	tx   *TransactionHistory // Set for transaction rows
+	lien decimal.Decimal     // Available balance moved into (positive) or out of (negative) liens
+}
+
+// lienEvents turns lien records into balance movements. Releases are taken
+// from release rows; a lien with none that has ReleasedAt set predates them
+// and is treated as released in full at that time.
+func lienEvents(liens []*LienRecord) []ledgerEvent {
+	released := make(map[string]bool)
+	for _, lien := range liens {
+		if lien != nil && lien.IsRelease() {
+			released[lien.LienID] = true
+		}
+	}
+
+	var events []ledgerEvent
+	for _, lien := range liens {
+		switch {
+		case lien == nil:
+		case lien.IsRelease():
+			events = append(events, ledgerEvent{at: lien.CreatedAt, id: lien.ID, lien: lien.Amount.Neg()})
+		default:
+			events = append(events, ledgerEvent{at: lien.CreatedAt, id: lien.ID, lien: lien.Amount})
+			if !lien.ReleasedAt.IsZero() && !released[lien.ID] {
+				events = append(events, ledgerEvent{at: lien.ReleasedAt, id: lien.ID, lien: lien.Amount.Neg()})
+			}
+		}
+	}
+	return events
+}
+
+// sortLedgerEvents orders events by time, then ID
+func sortLedgerEvents(events []ledgerEvent) {
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].at.Equal(events[j].at) {
+			return events[i].at.Before(events[j].at)
+		}
+		return events[i].id < events[j].id
+	})
+}
+
+// ReplayWallet replays a wallet's transactions and lien records in time order
+// and checks them against the wallet's stored balances. Every row that moved
+// the balance is replayed (see TransactionHistory.MovesBalance).
+//
+// The chain check compares each row's BalanceBefore with the previous row's
+// BalanceAfter, adjusted for liens placed or released in between, since liens
+// move available balance without writing a transaction row. Adjustment rows
+// are the exception: they restate the ledger, so their BalanceBefore is
+// checked against the replayed balance instead. Each row's BalanceAfter must
+// also equal its BalanceBefore plus its BalanceEffect.
+//
+// Parameters:
+//   - wallet: Wallet as currently stored
+//   - transactions: Transactions of the wallet (failed rows are ignored)
+//   - liens: Lien records of the wallet, including release rows
+//
+// Returns:
+//   - *WalletReplay with replayed balances, drift and issues
+func ReplayWallet(wallet *Wallet, transactions []*TransactionHistory, liens []*LienRecord) *WalletReplay {
+	replay := &WalletReplay{WalletID: wallet.ID}
+
+	var events []ledgerEvent
+	for _, tx := range transactions {
+		if tx != nil && tx.MovesBalance() {
+			events = append(events, ledgerEvent{at: tx.CreatedAt, id: tx.ID, tx: tx})
+		}
+	}
+	events = append(events, lienEvents(liens)...)
+	sortLedgerEvents(events)
+
+	available, lien := decimal.Zero, decimal.Zero
+	chain := decimal.Zero // Previous BalanceAfter adjusted for liens since
+	for _, ev := range events {
+		if ev.tx == nil {
+			available = available.Sub(ev.lien)
+			lien = lien.Add(ev.lien)
+			chain = chain.Sub(ev.lien)
+			continue
+		}
+
+		tx := ev.tx
+		replay.TransactionCount++
+		expected, detail := chain, "balance before does not follow the previous row"
+		if tx.Category == CategoryAdjustment {
+			expected, detail = available, "adjustment does not start from the replayed balance"
+		}
+		if !tx.BalanceBefore.Equal(expected) {
+			replay.Issues = append(replay.Issues, &IntegrityIssue{
+				Kind:          IssueBrokenChain,
+				WalletID:      wallet.ID,
+				TransactionID: tx.ID,
+				Expected:      expected,
+				Actual:        tx.BalanceBefore,
+				Detail:        detail,
+			})
+		}
+
+		effect := BalanceEffect(tx)
+		if after := tx.BalanceBefore.Add(effect); !tx.BalanceAfter.Equal(after) {
+			replay.Issues = append(replay.Issues, &IntegrityIssue{
+				Kind:          IssueRowArithmetic,
+				WalletID:      wallet.ID,
+				TransactionID: tx.ID,
+				Expected:      after,
+				Actual:        tx.BalanceAfter,
+				Detail:        fmt.Sprintf("balance after is not balance before %s", effectString(effect)),
+			})
+		}
+
+		available = available.Add(effect)
+		chain = tx.BalanceAfter
+		replay.LastBalanceAfter = tx.BalanceAfter
+
+		if tx.BalanceAfter.IsNegative() {
+			replay.Issues = append(replay.Issues, &IntegrityIssue{
+				Kind:          IssueNegativeBalance,
+				WalletID:      wallet.ID,
+				TransactionID: tx.ID,
+				Expected:      decimal.Zero,
+				Actual:        tx.BalanceAfter,
+				Detail:        "row left the wallet with a negative balance",
+			})
+		}
+	}
+
+	replay.ReplayedAvailable = available
+	replay.ReplayedLien = lien
+	replay.AvailableDrift = wallet.AvailableBalance.Sub(available)
+	replay.LienDrift = wallet.LienBalance.Sub(lien)
+
+	if !replay.AvailableDrift.IsZero() {
+		replay.Issues = append(replay.Issues, &IntegrityIssue{
+			Kind:     IssueBalanceDrift,
+			WalletID: wallet.ID,
+			Expected: available,
+			Actual:   wallet.AvailableBalance,
+			Detail:   fmt.Sprintf("available balance drifted by %s", replay.AvailableDrift.String()),
+		})
+	}
+	if !replay.LienDrift.IsZero() {
+		replay.Issues = append(replay.Issues, &IntegrityIssue{
+			Kind:     IssueBalanceDrift,
+			WalletID: wallet.ID,
+			Expected: lien,
+			Actual:   wallet.LienBalance,
+			Detail:   fmt.Sprintf("lien balance drifted by %s", replay.LienDrift.String()),
+		})
+	}
+	for _, balance := range []struct {
+		name  string
+		value decimal.Decimal
+	}{{"available", wallet.AvailableBalance}, {"lien", wallet.LienBalance}} {
+		if balance.value.IsNegative() {
+			replay.Issues = append(replay.Issues, &IntegrityIssue{
+				Kind:     IssueNegativeBalance,
+				WalletID: wallet.ID,
+				Expected: decimal.Zero,
+				Actual:   balance.value,
+				Detail:   balance.name + " balance is negative",
+			})
+		}
+	}
+
+	return replay
+}
+
+// BalanceEffect returns how a row changes the available balance.
+// Credits add the amount net of fee (the full amount when the fee exceeds it);
+// debits remove the amount plus fee.
+func BalanceEffect(tx *TransactionHistory) decimal.Decimal {
+	if tx.Type == TypeDebit {
+		return tx.Amount.Add(tx.Fee).Neg()
+	}
+	net := tx.Amount.Sub(tx.Fee)
+	if net.IsNegative() {
+		return tx.Amount
+	}
+	return net
+}
+
+// effectString formats a balance effect as a signed term, e.g. "+ 40" or "- 10"
+func effectString(effect decimal.Decimal) string {
+	if effect.IsNegative() {
+		return "- " + effect.Neg().String()
+	}
+	return "+ " + effect.String()
+}
+
+// NewAdjustmentTransaction builds a completed CategoryAdjustment row that
+// brings the replayed ledger in line with the wallet's stored available balance.
+// The row moves the replayed balance to the stored one, so its BalanceAfter
+// is its BalanceBefore plus its effect.
+func NewAdjustmentTransaction(wallet *Wallet, replay *WalletReplay, initiatorID string) *TransactionHistory {
+	if replay.AvailableDrift.IsZero() {
+		return nil
+	}
+
+	txType := TypeCredit
+	if replay.AvailableDrift.IsNegative() {
+		txType = TypeDebit
+	}
+
+	now := time.Now().UTC()
+	return &TransactionHistory{
+		ID:                NewTransactionID(),
+		WalletID:          wallet.ID,
+		CurrencyCode:      wallet.CurrencyCode,
+		InitiatorID:       initiatorID,
+		ExternalReference: "integrity-" + now.Format("20060102"),
+		Category:          CategoryAdjustment,
+		Description:       "Ledger integrity adjustment",
+		Amount:            replay.AvailableDrift.Abs(),
+		Fee:               decimal.Zero,
+		Type:              txType,
+		BalanceBefore:     replay.ReplayedAvailable,
+		BalanceAfter:      replay.ReplayedAvailable.Add(replay.AvailableDrift),
+		CreatedAt:         now,
+		UpdatedAt:         now,
+		Status:            StatusCompleted,
+	}
+}
@@ -0,0 +1,157 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayWallet(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := decimal.NewFromInt
+
+	row := func(id string, offset int, typ TransactionType, amount, fee, before, after int64) *TransactionHistory {
+		return &TransactionHistory{
+			ID:            id,
+			WalletID:      "wt_1",
+			Type:          typ,
+			Amount:        d(amount),
+			Fee:           d(fee),
+			BalanceBefore: d(before),
+			BalanceAfter:  d(after),
+			CreatedAt:     start.Add(time.Duration(offset) * time.Hour),
+			Status:        StatusCompleted,
+		}
+	}
+
+	transactions := []*TransactionHistory{
+		row("t1", 1, TypeCredit, 100, 0, 0, 100),
+		row("t2", 2, TypeDebit, 20, 1, 100, 79),
+		// Lien of 30 placed at hour 3, so the next row starts from 49
+		row("t3", 4, TypeCredit, 10, 2, 49, 57),
+		// Lien released at hour 5, back to 87
+		row("t4", 6, TypeDebit, 7, 0, 87, 80),
+	}
+	failed := row("t5", 7, TypeDebit, 500, 0, 80, -420)
+	failed.Status = StatusFailed
+	transactions = append(transactions, failed)
+
+	liens := []*LienRecord{{
+		ID:         "lien_1",
+		WalletID:   "wt_1",
+		Amount:     d(30),
+		CreatedAt:  start.Add(3 * time.Hour),
+		ReleasedAt: start.Add(5 * time.Hour),
+	}}
+
+	t.Run("consistent ledger", func(t *testing.T) {
+		wallet := &Wallet{ID: "wt_1", CurrencyCode: "USD", AvailableBalance: d(80), LienBalance: d(0)}
+
+		replay := ReplayWallet(wallet, transactions, liens)
+		assert.Empty(t, replay.Issues)
+		assert.Equal(t, 4, replay.TransactionCount)
+		assert.Equal(t, "80", replay.ReplayedAvailable.String())
+		assert.False(t, replay.HasDrift())
+		assert.Nil(t, NewAdjustmentTransaction(wallet, replay, "ops"))
+	})
+
+	t.Run("drift and broken chain", func(t *testing.T) {
+		wallet := &Wallet{ID: "wt_1", CurrencyCode: "USD", AvailableBalance: d(95), LienBalance: d(-5)}
+
+		broken := append([]*TransactionHistory{}, transactions[:3]...)
+		broken = append(broken, row("t4", 6, TypeDebit, 7, 0, 90, -3))
+
+		replay := ReplayWallet(wallet, broken, liens)
+		assert.True(t, replay.HasDrift())
+		assert.Equal(t, "15", replay.AvailableDrift.String())
+		assert.Equal(t, "-5", replay.LienDrift.String())
+
+		kinds := make(map[IntegrityIssueKind]int)
+		for _, issue := range replay.Issues {
+			kinds[issue.Kind]++
+		}
+		assert.Equal(t, 1, kinds[IssueBrokenChain])
+		assert.Equal(t, 1, kinds[IssueRowArithmetic]) // 90 - 7 is not -3
+		assert.Equal(t, 2, kinds[IssueBalanceDrift])
+		assert.Equal(t, 2, kinds[IssueNegativeBalance]) // Row t4 and the lien balance
+
+		adjustment := NewAdjustmentTransaction(wallet, replay, "ops")
+		assert.Equal(t, CategoryAdjustment, adjustment.Category)
+		assert.Equal(t, TypeCredit, adjustment.Type)
+		assert.Equal(t, "15", adjustment.Amount.String())
+		assert.Equal(t, "80", adjustment.BalanceBefore.String(), "starts from the replayed balance")
+		assert.Equal(t, "95", adjustment.BalanceAfter.String())
+
+		// Replaying with the adjustment clears the available drift without
+		// adding issues of its own
+		wallet.LienBalance = d(0)
+		adjustment.CreatedAt = start.Add(8 * time.Hour)
+		replay = ReplayWallet(wallet, append(broken, adjustment), liens)
+		assert.False(t, replay.HasDrift())
+		for _, issue := range replay.Issues {
+			assert.Equal(t, "t4", issue.TransactionID, issue.Detail)
+		}
+		assert.Equal(t, "95", replay.LastBalanceAfter.String())
+	})
+}
+
+func TestReplayWalletHeldForReview(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := decimal.NewFromInt
+	wallet := &Wallet{ID: "wt_1", CurrencyCode: "USD", AvailableBalance: d(50), LienBalance: d(0)}
+
+	held := &TransactionHistory{
+		ID:            "t1",
+		WalletID:      wallet.ID,
+		Type:          TypeCredit,
+		Amount:        d(100),
+		Fee:           d(0),
+		BalanceBefore: d(50),
+		BalanceAfter:  d(150),
+		CreatedAt:     start,
+		Status:        StatusPending,
+	}
+	wallet.AvailableBalance = held.BalanceAfter
+	hold := wallet.HoldForReview(held)
+	hold.CreatedAt = start.Add(time.Minute)
+	prior := &TransactionHistory{
+		ID: "t0", WalletID: wallet.ID, Type: TypeCredit, Amount: d(50), Fee: d(0),
+		BalanceBefore: d(0), BalanceAfter: d(50), CreatedAt: start.Add(-time.Hour), Status: StatusCompleted,
+	}
+	transactions := []*TransactionHistory{prior, held}
+	liens := []*LienRecord{hold}
+
+	t.Run("held credit sits in a lien", func(t *testing.T) {
+		assert.Equal(t, "50", wallet.AvailableBalance.String())
+		assert.Equal(t, "100", wallet.LienBalance.String())
+		assert.Equal(t, held.ID, hold.ExternalTransactionID)
+
+		replay := ReplayWallet(wallet, transactions, liens)
+		assert.Empty(t, replay.Issues)
+		assert.Empty(t, ProjectWallet(wallet.ID, transactions, liens, nil, time.Time{}).Diff(wallet))
+	})
+
+	t.Run("rejection is a reversal row", func(t *testing.T) {
+		rejected := &Wallet{ID: wallet.ID, CurrencyCode: "USD", AvailableBalance: d(150), LienBalance: d(0)}
+		hold.ReleasedAt = start.Add(2 * time.Minute)
+
+		reversal := NewReversalTransaction(rejected, held, "ops_1")
+		reversal.CreatedAt = start.Add(3 * time.Minute)
+		assert.Equal(t, TypeDebit, reversal.Type)
+		assert.Equal(t, "100", reversal.Amount.String())
+		assert.Equal(t, held.ID, reversal.ExternalReference)
+		rejected.AvailableBalance = reversal.BalanceAfter
+
+		reversedHeld := *held
+		require.NoError(t, reversedHeld.MarkAsReversed())
+		rows := []*TransactionHistory{prior, &reversedHeld, reversal}
+
+		replay := ReplayWallet(rejected, rows, liens)
+		assert.Empty(t, replay.Issues)
+		assert.Equal(t, "50", replay.ReplayedAvailable.String())
+		assert.Empty(t, ProjectWallet(wallet.ID, rows, liens, nil, time.Time{}).Diff(rejected))
+	})
+}
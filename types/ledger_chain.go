@@ -0,0 +1,312 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Error definitions for the ledger hash chain
+var (
+	ErrChainHeadMismatch = errors.New("ledger chain head changed concurrently")
+	ErrChainBroken       = errors.New("ledger chain verification failed")
+)
+
+// ChainTimePrecision is the precision timestamps are hashed at. It matches
+// what every supported database stores, so hashes survive a round trip.
+const ChainTimePrecision = time.Microsecond
+
+// ChainViolationKind classifies a hash chain problem
+type ChainViolationKind string
+
+const (
+	ChainModified  ChainViolationKind = "MODIFIED"  // Row contents no longer match its hash
+	ChainUnlinked  ChainViolationKind = "UNLINKED"  // Row's predecessor is missing (deleted or relinked)
+	ChainForked    ChainViolationKind = "FORKED"    // Several rows claim the same predecessor
+	ChainReordered ChainViolationKind = "REORDERED" // Row is dated before its predecessor
+	ChainTruncated ChainViolationKind = "TRUNCATED" // Chain is shorter than or diverges from its recorded head
+)
+
+// LedgerChainHead tracks the latest hash and length of a wallet's chain
+type LedgerChainHead struct {
+	WalletID  string    `json:"walletId" bun:",pk"`              // Wallet the chain belongs to
+	HeadHash  string    `json:"headHash" bun:",notnull"`         // Hash of the latest entry
+	Length    int64     `json:"length" bun:",notnull,default:0"` // Number of sealed rows and status changes
+	UpdatedAt time.Time `json:"updatedAt" bun:",notnull"`        // Last append
+}
+
+// ChainViolation describes a single hash chain problem
+type ChainViolation struct {
+	Kind          ChainViolationKind `json:"kind"`                    // Problem type
+	WalletID      string             `json:"walletId"`                // Affected wallet
+	TransactionID string             `json:"transactionId,omitempty"` // Offending row, if any
+	Detail        string             `json:"detail"`                  // Human-readable explanation
+}
+
+// ChainVerification is the result of verifying one wallet's chain
+type ChainVerification struct {
+	WalletID   string            `json:"walletId"`             // Verified wallet
+	Length     int64             `json:"length"`               // Entries reachable from the genesis row
+	HeadHash   string            `json:"headHash"`             // Hash of the last reachable entry
+	Unsealed   int               `json:"unsealed"`             // Rows written before chaining was enabled
+	Violations []*ChainViolation `json:"violations,omitempty"` // Problems found
+	hashes     []string          // Reachable entry hashes in chain order
+}
+
+// Valid reports whether the chain has no violations
+func (v *ChainVerification) Valid() bool {
+	return len(v.Violations) == 0
+}
+
+// HashAt returns the hash of the entry at the given 1-based chain position
+func (v *ChainVerification) HashAt(position int64) (string, bool) {
+	if position < 1 || position > int64(len(v.hashes)) {
+		return "", false
+	}
+	return v.hashes[position-1], true
+}
+
+// TransactionStatusChange records a row moving out of pending. It is sealed
+// into the wallet's chain after the row, so a status edited in place no
+// longer matches what the chain says happened to the row.
+type TransactionStatusChange struct {
+	ID            string            `json:"id" bun:",pk"`                 // Unique change ID
+	TransactionID string            `json:"transactionId" bun:",notnull"` // Row whose status changed
+	WalletID      string            `json:"walletId" bun:",notnull"`      // Wallet whose chain holds the change
+	FromStatus    TransactionStatus `json:"fromStatus" bun:",notnull"`    // Status before the change
+	ToStatus      TransactionStatus `json:"toStatus" bun:",notnull"`      // Status after the change
+	CreatedAt     time.Time         `json:"createdAt" bun:",notnull"`     // When the change was made
+	PrevHash      string            `json:"prevHash" bun:",nullzero"`     // Hash of the previous entry in the wallet's chain
+	Hash          string            `json:"hash" bun:",notnull"`          // Hash of this change's contents and PrevHash
+}
+
+// NewTransactionStatusChange records row moving from its stored status to status
+func NewTransactionStatusChange(row *TransactionHistory, status TransactionStatus) *TransactionStatusChange {
+	return &TransactionStatusChange{
+		ID:            GenerateID("tsc_", 15),
+		TransactionID: row.ID,
+		WalletID:      row.WalletID,
+		FromStatus:    row.Status,
+		ToStatus:      status,
+		CreatedAt:     time.Now().UTC(),
+	}
+}
+
+// SealChain links the change to the previous entry's hash and sets its own hash
+func (c *TransactionStatusChange) SealChain(prevHash string) {
+	c.CreatedAt = c.CreatedAt.UTC().Truncate(ChainTimePrecision)
+	c.PrevHash = prevHash
+	c.Hash = HashStatusChange(c)
+}
+
+// HashStatusChange computes the chain hash of a status change
+func HashStatusChange(c *TransactionStatusChange) string {
+	data, _ := json.Marshal([]string{
+		c.ID,
+		c.TransactionID,
+		c.WalletID,
+		string(c.FromStatus),
+		string(c.ToStatus),
+		c.CreatedAt.UTC().Truncate(ChainTimePrecision).Format(time.RFC3339Nano),
+		c.PrevHash,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// canonicalTransaction returns the bytes covered by a row's hash. The status
+// is the one the row was written with; later changes are sealed separately as
+// TransactionStatusChange entries. UpdatedAt is excluded as it moves with them.
+func canonicalTransaction(tx *TransactionHistory, status TransactionStatus) []byte {
+	fields := []string{
+		tx.ID,
+		tx.WalletID,
+		tx.CurrencyCode,
+		tx.InitiatorID,
+		tx.ExternalReference,
+		string(tx.Category),
+		tx.Description,
+		tx.Amount.StringFixed(8),
+		tx.Fee.StringFixed(8),
+		string(tx.Type),
+		tx.BalanceBefore.StringFixed(8),
+		tx.BalanceAfter.StringFixed(8),
+		tx.CreatedAt.UTC().Truncate(ChainTimePrecision).Format(time.RFC3339Nano),
+		tx.PrevHash,
+		string(status),
+		tx.GroupID,
+	}
+
+	// A JSON array of strings is unambiguous and stable across Go versions
+	data, _ := json.Marshal(fields)
+	return data
+}
+
+// HashTransaction computes the chain hash of a row from its contents, current
+// status and PrevHash
+func HashTransaction(tx *TransactionHistory) string {
+	return hashTransaction(tx, tx.Status)
+}
+
+// hashTransaction computes the chain hash of a row as sealed with status
+func hashTransaction(tx *TransactionHistory, status TransactionStatus) string {
+	sum := sha256.Sum256(canonicalTransaction(tx, status))
+	return hex.EncodeToString(sum[:])
+}
+
+// SealChain links the row to the previous row's hash and sets its own hash.
+// CreatedAt is truncated to ChainTimePrecision first so the stored value is
+// exactly the value that was hashed.
+func (t *TransactionHistory) SealChain(prevHash string) {
+	t.CreatedAt = t.CreatedAt.UTC().Truncate(ChainTimePrecision)
+	t.PrevHash = prevHash
+	t.Hash = HashTransaction(t)
+}
+
+// chainEntry is a row or a status change as a link in a wallet's chain
+type chainEntry struct {
+	id        string
+	prevHash  string
+	hash      string
+	createdAt time.Time
+}
+
+// VerifyWalletChain checks a wallet's rows and status changes against their
+// hashes and links, and each row's status against its recorded changes.
+//
+// The chain is walked from the genesis entry (empty PrevHash) by following
+// PrevHash links, so the physical order entries are returned in does not
+// matter. Rows without a hash predate chaining and are only counted.
+//
+// Parameters:
+//   - walletID: Wallet whose rows are being verified
+//   - transactions: All rows of the wallet, in any order
+//   - changes: All status changes of the wallet, in any order
+//   - head: The wallet's recorded chain head (nil when the wallet has none)
+//
+// Returns:
+//   - *ChainVerification listing every violation found
+func VerifyWalletChain(
+	walletID string,
+	transactions []*TransactionHistory,
+	changes []*TransactionStatusChange,
+	head *LedgerChainHead,
+) *ChainVerification {
+	result := &ChainVerification{WalletID: walletID}
+	violation := func(kind ChainViolationKind, txID, detail string) {
+		result.Violations = append(result.Violations, &ChainViolation{
+			Kind: kind, WalletID: walletID, TransactionID: txID, Detail: detail,
+		})
+	}
+
+	// Changes of each row, oldest first
+	rowChanges := make(map[string][]*TransactionStatusChange)
+	for _, change := range changes {
+		if change != nil {
+			rowChanges[change.TransactionID] = append(rowChanges[change.TransactionID], change)
+		}
+	}
+	for _, list := range rowChanges {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	}
+
+	children := make(map[string][]*chainEntry)
+	var entries []*chainEntry
+	rows := make(map[string]bool)
+	for _, tx := range transactions {
+		if tx == nil {
+			continue
+		}
+		rows[tx.ID] = true
+
+		// The row was sealed with the status its first change moved it from
+		sealedStatus := tx.Status
+		if list := rowChanges[tx.ID]; len(list) > 0 {
+			sealedStatus = list[0].FromStatus
+		}
+		status := sealedStatus
+		for _, change := range rowChanges[tx.ID] {
+			if change.FromStatus != status || !(&TransactionHistory{Status: status}).CanTransitionTo(change.ToStatus) {
+				violation(ChainModified, tx.ID, fmt.Sprintf("status change %s does not follow from %s", change.ID, status))
+			}
+			status = change.ToStatus
+		}
+		if status != tx.Status {
+			violation(ChainModified, tx.ID, fmt.Sprintf("row status is %s, its recorded changes end at %s", tx.Status, status))
+		}
+
+		if tx.Hash == "" {
+			result.Unsealed++
+			continue
+		}
+		if hashTransaction(tx, sealedStatus) != tx.Hash {
+			violation(ChainModified, tx.ID, "row contents do not match the stored hash")
+		}
+		entries = append(entries, &chainEntry{id: tx.ID, prevHash: tx.PrevHash, hash: tx.Hash, createdAt: tx.CreatedAt})
+	}
+
+	for _, change := range changes {
+		if change == nil {
+			continue
+		}
+		if !rows[change.TransactionID] {
+			violation(ChainUnlinked, change.TransactionID, fmt.Sprintf("status change %s refers to a missing row", change.ID))
+		}
+		if HashStatusChange(change) != change.Hash {
+			violation(ChainModified, change.TransactionID, fmt.Sprintf("status change %s does not match its stored hash", change.ID))
+		}
+		entries = append(entries, &chainEntry{
+			id: change.ID, prevHash: change.PrevHash, hash: change.Hash, createdAt: change.CreatedAt,
+		})
+	}
+	for _, entry := range entries {
+		children[entry.prevHash] = append(children[entry.prevHash], entry)
+	}
+
+	// Walk from genesis following the links
+	visited := make(map[string]bool)
+	var prev *chainEntry
+	current := ""
+	for {
+		next := children[current]
+		if len(next) == 0 {
+			break
+		}
+		if len(next) > 1 {
+			violation(ChainForked, next[1].id, fmt.Sprintf("%d entries follow the same predecessor", len(next)))
+		}
+
+		entry := next[0]
+		if visited[entry.id] {
+			break // A relinked entry formed a cycle
+		}
+		visited[entry.id] = true
+		if prev != nil && entry.createdAt.Before(prev.createdAt) {
+			violation(ChainReordered, entry.id, "entry is dated before its predecessor")
+		}
+
+		result.hashes = append(result.hashes, entry.hash)
+		prev, current = entry, entry.hash
+	}
+	result.Length = int64(len(result.hashes))
+	result.HeadHash = current
+
+	if int(result.Length) < len(entries) {
+		for _, entry := range entries {
+			if !visited[entry.id] {
+				violation(ChainUnlinked, entry.id, "entry is not reachable from the start of the chain")
+			}
+		}
+	}
+
+	if head != nil && (head.HeadHash != result.HeadHash || head.Length != result.Length) {
+		violation(ChainTruncated, "", fmt.Sprintf("recorded head is %d entries at %s, chain has %d entries at %s",
+			head.Length, head.HeadHash, result.Length, result.HeadHash))
+	}
+
+	return result
+}
@@ -0,0 +1,221 @@
+package types
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// sealedChain builds n sealed rows for a wallet, as the store would write them
+func sealedChain(walletID string, n int) ([]*TransactionHistory, *LedgerChainHead) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	head := &LedgerChainHead{WalletID: walletID}
+
+	var rows []*TransactionHistory
+	balance := decimal.Zero
+	for i := 0; i < n; i++ {
+		tx := &TransactionHistory{
+			ID:            GenerateID("txn_", 8),
+			WalletID:      walletID,
+			CurrencyCode:  "USD",
+			Category:      CategoryDeposit,
+			Type:          TypeCredit,
+			Amount:        decimal.NewFromInt(int64(10 + i)),
+			Fee:           decimal.Zero,
+			BalanceBefore: balance,
+			CreatedAt:     start.Add(time.Duration(i) * time.Minute).Add(123 * time.Nanosecond),
+			Status:        StatusCompleted,
+		}
+		balance = balance.Add(tx.Amount)
+		tx.BalanceAfter = balance
+		tx.SealChain(head.HeadHash)
+
+		head.HeadHash = tx.Hash
+		head.Length++
+		rows = append(rows, tx)
+	}
+	return rows, head
+}
+
+// sealedPendingChain is sealedChain with the last row still pending
+func sealedPendingChain(walletID string, n int) ([]*TransactionHistory, *LedgerChainHead) {
+	rows, head := sealedChain(walletID, n)
+	last := rows[n-1]
+	last.Status = StatusPending
+	last.SealChain(last.PrevHash)
+	head.HeadHash = last.Hash
+	return rows, head
+}
+
+// recordStatusChange moves a row to status and seals the change onto head
+func recordStatusChange(row *TransactionHistory, head *LedgerChainHead, status TransactionStatus) *TransactionStatusChange {
+	change := NewTransactionStatusChange(row, status)
+	change.SealChain(head.HeadHash)
+	row.Status = status
+	head.HeadHash, head.Length = change.Hash, head.Length+1
+	return change
+}
+
+func violationKinds(v *ChainVerification) []ChainViolationKind {
+	var kinds []ChainViolationKind
+	for _, violation := range v.Violations {
+		kinds = append(kinds, violation.Kind)
+	}
+	return kinds
+}
+
+func TestVerifyWalletChain(t *testing.T) {
+	t.Run("intact chain in any order", func(t *testing.T) {
+		rows, head := sealedChain("wt_1", 4)
+		shuffled := []*TransactionHistory{rows[2], rows[0], rows[3], rows[1]}
+
+		v := VerifyWalletChain("wt_1", shuffled, nil, head)
+		assert.True(t, v.Valid())
+		assert.Equal(t, int64(4), v.Length)
+		assert.Equal(t, head.HeadHash, v.HeadHash)
+
+		hash, ok := v.HashAt(2)
+		assert.True(t, ok)
+		assert.Equal(t, rows[1].Hash, hash)
+		assert.Equal(t, 0, rows[0].CreatedAt.Nanosecond()%1000) // Truncated before hashing
+	})
+
+	t.Run("modified row", func(t *testing.T) {
+		rows, head := sealedChain("wt_1", 3)
+		rows[1].Amount = decimal.NewFromInt(1000)
+
+		v := VerifyWalletChain("wt_1", rows, nil, head)
+		assert.Equal(t, []ChainViolationKind{ChainModified}, violationKinds(v))
+		assert.Equal(t, rows[1].ID, v.Violations[0].TransactionID)
+	})
+
+	t.Run("recorded status change", func(t *testing.T) {
+		rows, head := sealedPendingChain("wt_1", 2)
+		change := recordStatusChange(rows[1], head, StatusFailed)
+
+		v := VerifyWalletChain("wt_1", rows, []*TransactionStatusChange{change}, head)
+		assert.True(t, v.Valid(), v.Violations)
+		assert.Equal(t, int64(3), v.Length)
+		assert.Equal(t, change.Hash, v.HeadHash)
+	})
+
+	t.Run("status edited in place", func(t *testing.T) {
+		rows, head := sealedPendingChain("wt_1", 2)
+		rows[1].Status = StatusFailed
+		rows[1].UpdatedAt = time.Now()
+
+		v := VerifyWalletChain("wt_1", rows, nil, head)
+		assert.Equal(t, []ChainViolationKind{ChainModified}, violationKinds(v))
+		assert.Equal(t, rows[1].ID, v.Violations[0].TransactionID)
+	})
+
+	t.Run("status edited after a recorded change", func(t *testing.T) {
+		rows, head := sealedPendingChain("wt_1", 2)
+		change := recordStatusChange(rows[1], head, StatusFailed)
+		rows[1].Status = StatusCompleted
+
+		v := VerifyWalletChain("wt_1", rows, []*TransactionStatusChange{change}, head)
+		assert.Equal(t, []ChainViolationKind{ChainModified}, violationKinds(v))
+	})
+
+	t.Run("modified status change", func(t *testing.T) {
+		rows, head := sealedPendingChain("wt_1", 2)
+		change := recordStatusChange(rows[1], head, StatusFailed)
+		change.ToStatus, rows[1].Status = StatusCompleted, StatusCompleted
+
+		v := VerifyWalletChain("wt_1", rows, []*TransactionStatusChange{change}, head)
+		assert.Equal(t, []ChainViolationKind{ChainModified}, violationKinds(v))
+	})
+
+	t.Run("deleted status change", func(t *testing.T) {
+		rows, head := sealedPendingChain("wt_1", 2)
+		recordStatusChange(rows[1], head, StatusFailed)
+
+		v := VerifyWalletChain("wt_1", rows, nil, head)
+		assert.ElementsMatch(t, []ChainViolationKind{ChainModified, ChainTruncated}, violationKinds(v))
+	})
+
+	t.Run("status edited without a change", func(t *testing.T) {
+		rows, head := sealedChain("wt_1", 1)
+		rows[0].Status = StatusFailed
+
+		v := VerifyWalletChain("wt_1", rows, nil, head)
+		assert.Equal(t, []ChainViolationKind{ChainModified}, violationKinds(v))
+	})
+
+	t.Run("deleted row", func(t *testing.T) {
+		rows, head := sealedChain("wt_1", 4)
+		remaining := []*TransactionHistory{rows[0], rows[2], rows[3]}
+
+		v := VerifyWalletChain("wt_1", remaining, nil, head)
+		assert.ElementsMatch(t, []ChainViolationKind{ChainUnlinked, ChainUnlinked, ChainTruncated}, violationKinds(v))
+		assert.Equal(t, int64(1), v.Length)
+	})
+
+	t.Run("deleted tail", func(t *testing.T) {
+		rows, head := sealedChain("wt_1", 3)
+
+		v := VerifyWalletChain("wt_1", rows[:2], nil, head)
+		assert.Equal(t, []ChainViolationKind{ChainTruncated}, violationKinds(v))
+	})
+
+	t.Run("reordered rows", func(t *testing.T) {
+		rows, head := sealedChain("wt_1", 3)
+		rows[1].CreatedAt, rows[2].CreatedAt = rows[2].CreatedAt, rows[1].CreatedAt
+
+		v := VerifyWalletChain("wt_1", rows, nil, head)
+		assert.Contains(t, violationKinds(v), ChainModified)
+		assert.Contains(t, violationKinds(v), ChainReordered)
+	})
+
+	t.Run("forked chain", func(t *testing.T) {
+		rows, head := sealedChain("wt_1", 2)
+		extra := *rows[1]
+		extra.ID = "txn_forged"
+		extra.SealChain(rows[0].Hash)
+
+		v := VerifyWalletChain("wt_1", append(rows, &extra), nil, head)
+		assert.Contains(t, violationKinds(v), ChainForked)
+	})
+
+	t.Run("unsealed legacy rows", func(t *testing.T) {
+		rows, head := sealedChain("wt_1", 2)
+		legacy := &TransactionHistory{ID: "txn_legacy", WalletID: "wt_1"}
+
+		v := VerifyWalletChain("wt_1", append(rows, legacy), nil, head)
+		assert.True(t, v.Valid())
+		assert.Equal(t, 1, v.Unsealed)
+	})
+}
+
+func TestChainCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.pem")
+
+	signer, err := LoadOrCreateCheckpointKey(path, "key-1")
+	assert.NoError(t, err)
+
+	reloaded, err := LoadOrCreateCheckpointKey(path, "key-1")
+	assert.NoError(t, err)
+	assert.Equal(t, signer.PrivateKey, reloaded.PrivateKey)
+
+	_, headA := sealedChain("wt_b", 2)
+	_, headB := sealedChain("wt_a", 3)
+
+	cp, err := NewChainCheckpoint([]*LedgerChainHead{headA, headB}, signer)
+	assert.NoError(t, err)
+	assert.Equal(t, "wt_a", cp.Heads[0].WalletID)
+	assert.NoError(t, cp.Verify(signer.PublicKey()))
+
+	other, err := LoadOrCreateCheckpointKey(filepath.Join(t.TempDir(), "other.pem"), "key-2")
+	assert.NoError(t, err)
+	assert.ErrorIs(t, cp.Verify(other.PublicKey()), ErrCheckpointSignature)
+
+	cp.Heads[0].Length = 2
+	assert.ErrorIs(t, cp.Verify(signer.PublicKey()), ErrCheckpointSignature)
+
+	_, err = NewChainCheckpoint(nil, &CheckpointSigner{})
+	assert.ErrorIs(t, err, ErrInvalidSigningKey)
+}
@@ -0,0 +1,146 @@
+package types
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// Error definitions for chain checkpoints
+var (
+	ErrCheckpointNotFound  = errors.New("chain checkpoint not found")
+	ErrCheckpointSignature = errors.New("chain checkpoint signature is invalid")
+	ErrInvalidSigningKey   = errors.New("invalid checkpoint signing key")
+)
+
+// CheckpointHead is a wallet's chain head as captured by a checkpoint
+type CheckpointHead struct {
+	WalletID string `json:"walletId"` // Wallet the chain belongs to
+	HeadHash string `json:"headHash"` // Hash of the latest row at checkpoint time
+	Length   int64  `json:"length"`   // Chain length at checkpoint time
+}
+
+// ChainCheckpoint is a signed snapshot of every wallet's chain head.
+// Once a checkpoint exists, rewriting any row it covers is detectable even if
+// every hash after it is recomputed, because the signature cannot be forged.
+type ChainCheckpoint struct {
+	ID        string           `json:"id" bun:",pk"`             // Unique checkpoint ID
+	Heads     []CheckpointHead `json:"heads" bun:",notnull"`     // Captured chain heads, sorted by wallet
+	Digest    string           `json:"digest" bun:",notnull"`    // SHA-256 of the canonical heads
+	KeyID     string           `json:"keyId" bun:",notnull"`     // Identifies the signing key
+	Signature string           `json:"signature" bun:",notnull"` // Base64 Ed25519 signature over the digest payload
+	CreatedAt time.Time        `json:"createdAt" bun:",notnull"` // When the checkpoint was taken
+}
+
+// CheckpointSigner signs checkpoints with a local Ed25519 key
+type CheckpointSigner struct {
+	KeyID      string             // Published identifier of the key
+	PrivateKey ed25519.PrivateKey // Signing key
+}
+
+// PublicKey returns the verification key for the signer
+func (s *CheckpointSigner) PublicKey() ed25519.PublicKey {
+	return s.PrivateKey.Public().(ed25519.PublicKey)
+}
+
+// NewChainCheckpoint captures and signs the given chain heads
+func NewChainCheckpoint(heads []*LedgerChainHead, signer *CheckpointSigner) (*ChainCheckpoint, error) {
+	if signer == nil || len(signer.PrivateKey) != ed25519.PrivateKeySize {
+		return nil, ErrInvalidSigningKey
+	}
+
+	cp := &ChainCheckpoint{
+		ID:        GenerateID("ckpt_", 15),
+		KeyID:     signer.KeyID,
+		CreatedAt: time.Now().UTC().Truncate(ChainTimePrecision),
+	}
+	for _, head := range heads {
+		if head != nil {
+			cp.Heads = append(cp.Heads, CheckpointHead{WalletID: head.WalletID, HeadHash: head.HeadHash, Length: head.Length})
+		}
+	}
+	sort.Slice(cp.Heads, func(i, j int) bool { return cp.Heads[i].WalletID < cp.Heads[j].WalletID })
+
+	cp.Digest = cp.computeDigest()
+	cp.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(signer.PrivateKey, cp.signedPayload()))
+
+	return cp, nil
+}
+
+// Verify checks the digest and signature against the given public key
+func (c *ChainCheckpoint) Verify(publicKey ed25519.PublicKey) error {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return ErrInvalidSigningKey
+	}
+	if c.computeDigest() != c.Digest {
+		return fmt.Errorf("%w: digest does not match heads", ErrCheckpointSignature)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(c.Signature)
+	if err != nil || !ed25519.Verify(publicKey, c.signedPayload(), sig) {
+		return ErrCheckpointSignature
+	}
+	return nil
+}
+
+// computeDigest hashes the canonical JSON encoding of the heads
+func (c *ChainCheckpoint) computeDigest() string {
+	data, _ := json.Marshal(c.Heads)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// signedPayload binds the digest to the checkpoint identity and time
+func (c *ChainCheckpoint) signedPayload() []byte {
+	return []byte(fmt.Sprintf("waas-chain-checkpoint:v1:%s:%s:%s:%s",
+		c.ID, c.KeyID, c.CreatedAt.UTC().Format(time.RFC3339Nano), c.Digest))
+}
+
+// LoadOrCreateCheckpointKey reads a PEM encoded PKCS#8 Ed25519 key from path,
+// generating and saving a new one (mode 0600) when the file does not exist
+func LoadOrCreateCheckpointKey(path, keyID string) (*CheckpointSigner, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(priv)
+		if err != nil {
+			return nil, err
+		}
+		block := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		if err := os.WriteFile(path, block, 0o600); err != nil {
+			return nil, fmt.Errorf("failed to save checkpoint key: %w", err)
+		}
+		return &CheckpointSigner{KeyID: keyID, PrivateKey: priv}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM block in %s", ErrInvalidSigningKey, path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSigningKey, err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: not an Ed25519 key", ErrInvalidSigningKey)
+	}
+
+	return &CheckpointSigner{KeyID: keyID, PrivateKey: priv}, nil
+}
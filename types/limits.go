@@ -0,0 +1,206 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ErrLimitExceeded is wrapped by every *LimitError
+var ErrLimitExceeded = errors.New("transaction limit exceeded")
+
+// KYCTier is a customer's verification level, which selects their limits
+type KYCTier string
+
+const (
+	KYCTierNone     KYCTier = "TIER_0" // Unverified
+	KYCTierBasic    KYCTier = "TIER_1" // Identity verified
+	KYCTierStandard KYCTier = "TIER_2" // Identity and address verified
+	KYCTierEnhanced KYCTier = "TIER_3" // Enhanced due diligence
+)
+
+// CustomerKYCTier records the tier a customer has been verified to
+type CustomerKYCTier struct {
+	CustomerID string    `json:"customerId" bun:",pk"`     // Customer
+	Tier       KYCTier   `json:"tier" bun:",notnull"`      // Verified tier
+	UpdatedAt  time.Time `json:"updatedAt" bun:",notnull"` // Last tier change
+}
+
+// LimitWindow is a rolling period over which usage is accumulated
+type LimitWindow string
+
+const (
+	WindowDaily   LimitWindow = "DAILY"   // Last 24 hours
+	WindowWeekly  LimitWindow = "WEEKLY"  // Last 7 days
+	WindowMonthly LimitWindow = "MONTHLY" // Last 30 days
+)
+
+// LimitWindows lists the windows in increasing length
+var LimitWindows = []LimitWindow{WindowDaily, WindowWeekly, WindowMonthly}
+
+// Duration returns the length of the rolling window
+func (w LimitWindow) Duration() time.Duration {
+	switch w {
+	case WindowDaily:
+		return 24 * time.Hour
+	case WindowWeekly:
+		return 7 * 24 * time.Hour
+	case WindowMonthly:
+		return 30 * 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+// WindowLimit caps debits within a window. Zero values mean no cap.
+type WindowLimit struct {
+	Volume decimal.Decimal `json:"volume"` // Maximum debited amount
+	Count  int             `json:"count"`  // Maximum number of debits
+}
+
+// LimitRule holds the limits of one KYC tier in one currency.
+// Zero values mean no cap.
+type LimitRule struct {
+	Tier           KYCTier                     `json:"tier"`           // Tier the rule applies to
+	CurrencyCode   string                      `json:"currencyCode"`   // Currency the rule applies to
+	MaxTransaction decimal.Decimal             `json:"maxTransaction"` // Largest single debit
+	MaxBalance     decimal.Decimal             `json:"maxBalance"`     // Largest total wallet balance
+	Windows        map[LimitWindow]WindowLimit `json:"windows"`        // Rolling debit caps
+}
+
+// WindowUsage is the debit activity within a window
+type WindowUsage struct {
+	Volume decimal.Decimal `json:"volume"` // Amount debited
+	Count  int             `json:"count"`  // Number of debits
+}
+
+// LimitUsage is a customer's current usage against their limits in a currency
+type LimitUsage struct {
+	CustomerID   string                      `json:"customerId"`     // Customer
+	CurrencyCode string                      `json:"currencyCode"`   // Currency
+	Tier         KYCTier                     `json:"tier"`           // Customer's tier
+	Balance      decimal.Decimal             `json:"balance"`        // Total balance across the customer's wallets in the currency
+	Windows      map[LimitWindow]WindowUsage `json:"windows"`        // Debit usage per window
+	Rule         *LimitRule                  `json:"rule,omitempty"` // Applicable rule, nil when unlimited
+}
+
+// Headroom returns how much more may be debited, and how many more debits made,
+// within the window. ok is false when the window has no cap.
+func (u *LimitUsage) Headroom(window LimitWindow) (volume decimal.Decimal, count int, ok bool) {
+	if u.Rule == nil {
+		return decimal.Zero, 0, false
+	}
+	limit, capped := u.Rule.Windows[window]
+	if !capped || (limit.Volume.IsZero() && limit.Count == 0) {
+		return decimal.Zero, 0, false
+	}
+	used := u.Windows[window]
+	if !limit.Volume.IsZero() {
+		volume = decimal.Max(limit.Volume.Sub(used.Volume), decimal.Zero)
+	}
+	if limit.Count > 0 {
+		count = max(limit.Count-used.Count, 0)
+	}
+	return volume, count, true
+}
+
+// LimitError reports which limit a request hit and the headroom left under it
+type LimitError struct {
+	Limit     string          `json:"limit"`     // Limit hit, e.g. DAILY_VOLUME or MAX_BALANCE
+	Tier      KYCTier         `json:"tier"`      // Customer's tier
+	Currency  string          `json:"currency"`  // Currency of the limit
+	Max       decimal.Decimal `json:"max"`       // Configured limit
+	Used      decimal.Decimal `json:"used"`      // Usage before the request
+	Requested decimal.Decimal `json:"requested"` // Amount (or count) the request adds
+	Headroom  decimal.Decimal `json:"headroom"`  // What is still available
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s limit of %s %s for %s (used %s, requested %s, headroom %s)",
+		ErrLimitExceeded, e.Limit, e.Max, e.Currency, e.Tier, e.Used, e.Requested, e.Headroom)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+func (r *LimitRule) limitError(limit string, max, used, requested decimal.Decimal) *LimitError {
+	return &LimitError{
+		Limit:     limit,
+		Tier:      r.Tier,
+		Currency:  r.CurrencyCode,
+		Max:       max,
+		Used:      used,
+		Requested: requested,
+		Headroom:  decimal.Max(max.Sub(used), decimal.Zero),
+	}
+}
+
+// CheckDebit checks a debit of amount against the per-transaction maximum and
+// every window, given the usage before the debit
+func (r *LimitRule) CheckDebit(amount decimal.Decimal, usage map[LimitWindow]WindowUsage) error {
+	if !r.MaxTransaction.IsZero() && amount.GreaterThan(r.MaxTransaction) {
+		return r.limitError("MAX_TRANSACTION", r.MaxTransaction, decimal.Zero, amount)
+	}
+
+	for _, window := range LimitWindows {
+		limit, ok := r.Windows[window]
+		if !ok {
+			continue
+		}
+		used := usage[window]
+		if !limit.Volume.IsZero() && used.Volume.Add(amount).GreaterThan(limit.Volume) {
+			return r.limitError(string(window)+"_VOLUME", limit.Volume, used.Volume, amount)
+		}
+		if limit.Count > 0 && used.Count+1 > limit.Count {
+			return r.limitError(string(window)+"_COUNT",
+				decimal.NewFromInt(int64(limit.Count)), decimal.NewFromInt(int64(used.Count)), decimal.NewFromInt(1))
+		}
+	}
+
+	return nil
+}
+
+// CheckBalance checks that a credit of amount keeps the balance within MaxBalance
+func (r *LimitRule) CheckBalance(balanceBefore, amount decimal.Decimal) error {
+	if !r.MaxBalance.IsZero() && balanceBefore.Add(amount).GreaterThan(r.MaxBalance) {
+		return r.limitError("MAX_BALANCE", r.MaxBalance, balanceBefore, amount)
+	}
+	return nil
+}
+
+// LimitSchedule holds the limit rules of every tier and currency
+type LimitSchedule struct {
+	DefaultTier KYCTier // Tier of customers without a recorded tier
+	rules       map[string]*LimitRule
+}
+
+// NewLimitSchedule creates a schedule from rules. Customers without a recorded
+// tier are treated as KYCTierNone.
+func NewLimitSchedule(rules ...*LimitRule) *LimitSchedule {
+	s := &LimitSchedule{DefaultTier: KYCTierNone, rules: make(map[string]*LimitRule)}
+	for _, rule := range rules {
+		s.Set(rule)
+	}
+	return s
+}
+
+// Set adds or replaces the rule for its tier and currency
+func (s *LimitSchedule) Set(rule *LimitRule) {
+	rule.CurrencyCode = strings.ToUpper(rule.CurrencyCode)
+	s.rules[limitKey(rule.Tier, rule.CurrencyCode)] = rule
+}
+
+// Rule returns the rule for a tier and currency. ok is false when the
+// combination is unlimited.
+func (s *LimitSchedule) Rule(tier KYCTier, currencyCode string) (*LimitRule, bool) {
+	rule, ok := s.rules[limitKey(tier, strings.ToUpper(currencyCode))]
+	return rule, ok
+}
+
+func limitKey(tier KYCTier, currencyCode string) string {
+	return string(tier) + ":" + currencyCode
+}
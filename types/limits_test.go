@@ -0,0 +1,99 @@
+package types
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func basicUSDRule() *LimitRule {
+	return &LimitRule{
+		Tier:           KYCTierBasic,
+		CurrencyCode:   "usd",
+		MaxTransaction: decimal.NewFromInt(500),
+		MaxBalance:     decimal.NewFromInt(2000),
+		Windows: map[LimitWindow]WindowLimit{
+			WindowDaily:   {Volume: decimal.NewFromInt(1000), Count: 3},
+			WindowMonthly: {Volume: decimal.NewFromInt(5000)},
+		},
+	}
+}
+
+func TestLimitRuleCheckDebit(t *testing.T) {
+	rule := basicUSDRule()
+
+	tests := []struct {
+		name   string
+		amount int64
+		usage  map[LimitWindow]WindowUsage
+		limit  string
+	}{
+		{"within limits", 400, map[LimitWindow]WindowUsage{WindowDaily: {Volume: decimal.NewFromInt(500), Count: 1}}, ""},
+		{"single transaction too large", 600, nil, "MAX_TRANSACTION"},
+		{"daily volume", 400, map[LimitWindow]WindowUsage{WindowDaily: {Volume: decimal.NewFromInt(700), Count: 1}}, "DAILY_VOLUME"},
+		{"daily count", 10, map[LimitWindow]WindowUsage{WindowDaily: {Volume: decimal.NewFromInt(30), Count: 3}}, "DAILY_COUNT"},
+		{"monthly volume", 400, map[LimitWindow]WindowUsage{WindowMonthly: {Volume: decimal.NewFromInt(4800)}}, "MONTHLY_VOLUME"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := rule.CheckDebit(decimal.NewFromInt(tt.amount), tt.usage)
+			if tt.limit == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			require.ErrorIs(t, err, ErrLimitExceeded)
+			var limitErr *LimitError
+			require.True(t, errors.As(err, &limitErr))
+			assert.Equal(t, tt.limit, limitErr.Limit)
+			assert.Equal(t, KYCTierBasic, limitErr.Tier)
+		})
+	}
+}
+
+func TestLimitErrorHeadroom(t *testing.T) {
+	rule := basicUSDRule()
+	usage := map[LimitWindow]WindowUsage{WindowDaily: {Volume: decimal.NewFromInt(850), Count: 1}}
+
+	var limitErr *LimitError
+	require.True(t, errors.As(rule.CheckDebit(decimal.NewFromInt(200), usage), &limitErr))
+	assert.True(t, limitErr.Headroom.Equal(decimal.NewFromInt(150)))
+	assert.Contains(t, limitErr.Error(), "DAILY_VOLUME")
+	assert.Contains(t, limitErr.Error(), "headroom 150")
+}
+
+func TestLimitRuleCheckBalance(t *testing.T) {
+	rule := basicUSDRule()
+
+	assert.NoError(t, rule.CheckBalance(decimal.NewFromInt(1500), decimal.NewFromInt(500)))
+
+	var limitErr *LimitError
+	require.True(t, errors.As(rule.CheckBalance(decimal.NewFromInt(1500), decimal.NewFromInt(501)), &limitErr))
+	assert.Equal(t, "MAX_BALANCE", limitErr.Limit)
+	assert.True(t, limitErr.Headroom.Equal(decimal.NewFromInt(500)))
+}
+
+func TestLimitScheduleAndUsage(t *testing.T) {
+	schedule := NewLimitSchedule(basicUSDRule())
+
+	rule, ok := schedule.Rule(KYCTierBasic, "USD")
+	require.True(t, ok)
+	_, ok = schedule.Rule(KYCTierEnhanced, "USD")
+	assert.False(t, ok)
+
+	usage := &LimitUsage{
+		Rule:    rule,
+		Windows: map[LimitWindow]WindowUsage{WindowDaily: {Volume: decimal.NewFromInt(1200), Count: 2}},
+	}
+	volume, count, capped := usage.Headroom(WindowDaily)
+	assert.True(t, capped)
+	assert.True(t, volume.IsZero())
+	assert.Equal(t, 1, count)
+
+	_, _, capped = usage.Headroom(WindowWeekly)
+	assert.False(t, capped)
+}
@@ -0,0 +1,410 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Payout batch errors
+var (
+	ErrPayoutBatchNotFound = errors.New("payout batch not found")
+	ErrEmptyPayoutBatch    = errors.New("payout batch has no items")
+	ErrInvalidPayoutMode   = errors.New("invalid payout mode")
+	ErrPayoutRejected      = errors.New("payout batch failed validation")
+	ErrSameWalletPayout    = errors.New("payout recipient is the funding wallet")
+	ErrRecipientNotFound   = errors.New("payout recipient wallet not found")
+	ErrPayoutReferenceUsed = errors.New("payout reference was used for a different batch")
+)
+
+// PayoutMode decides what happens to a batch when an item cannot be paid
+type PayoutMode string
+
+const (
+	PayoutAllOrNothing PayoutMode = "ALL_OR_NOTHING" // Every item is paid in one transaction, or none is
+	PayoutBestEffort   PayoutMode = "BEST_EFFORT"    // Items are paid independently and fail on their own
+)
+
+// PayoutBatchStatus tracks a batch from submission to its final result
+type PayoutBatchStatus string
+
+const (
+	PayoutBatchPending    PayoutBatchStatus = "PENDING"             // Validated, no item paid yet
+	PayoutBatchProcessing PayoutBatchStatus = "PROCESSING"          // Items are being paid; resumable after a crash
+	PayoutBatchCompleted  PayoutBatchStatus = "COMPLETED"           // Every item was paid
+	PayoutBatchPartial    PayoutBatchStatus = "PARTIALLY_COMPLETED" // Some items were paid and some failed
+	PayoutBatchFailed     PayoutBatchStatus = "FAILED"              // No item was paid
+)
+
+// IsFinal reports whether the batch has no more items to pay
+func (s PayoutBatchStatus) IsFinal() bool {
+	return s == PayoutBatchCompleted || s == PayoutBatchPartial || s == PayoutBatchFailed
+}
+
+// PayoutItemStatus is the result of paying a single recipient
+type PayoutItemStatus string
+
+const (
+	PayoutItemPending   PayoutItemStatus = "PENDING"   // Not paid yet
+	PayoutItemCompleted PayoutItemStatus = "COMPLETED" // Transfer recorded
+	PayoutItemFailed    PayoutItemStatus = "FAILED"    // Failed validation or the transfer failed
+	PayoutItemSkipped   PayoutItemStatus = "SKIPPED"   // Not paid because the batch failed
+)
+
+// PayoutRequest asks for one funding wallet to pay many recipients
+type PayoutRequest struct {
+	SourceWalletID      string              `json:"sourceWalletId"`
+	Reference           string              `json:"reference"` // Client's idempotency key; a repeat returns the first batch
+	Mode                PayoutMode          `json:"mode"`
+	Description         string              `json:"description"`
+	InitiatorID         string              `json:"initiatorId"`
+	TransactionCategory TransactionCategory `json:"transactionCategory"`
+	Items               []PayoutItemRequest `json:"items"`
+	Signature           *RequestSignature   `json:"signature,omitempty"` // Covers the whole batch
+}
+
+// PayoutItemRequest is a single recipient of a payout
+type PayoutItemRequest struct {
+	DestinationWalletID string          `json:"destinationWalletId"`
+	Amount              decimal.Decimal `json:"amount"`
+	Fee                 decimal.Decimal `json:"fee"`
+	Reference           string          `json:"reference"`   // Client's reference, recorded on the transactions
+	Description         string          `json:"description"` // Defaults to the batch description
+}
+
+// SigningPayload returns the canonical payload of a payout batch. Items are
+// bound in through a digest so the payload stays small for large batches.
+func (p PayoutRequest) SigningPayload(sig *RequestSignature) []byte {
+	digest := sha256.New()
+	for _, item := range p.Items {
+		fmt.Fprintf(digest, "%s\x00%s\x00%s\x00%s\x00%s\n",
+			item.DestinationWalletID,
+			item.Amount.String(),
+			item.Fee.String(),
+			item.Reference,
+			item.Description,
+		)
+	}
+
+	return SigningPayload(OperationPayout, sig,
+		p.SourceWalletID,
+		p.Reference,
+		string(p.Mode),
+		p.Description,
+		p.InitiatorID,
+		string(p.TransactionCategory),
+		fmt.Sprint(len(p.Items)),
+		hex.EncodeToString(digest.Sum(nil)),
+	)
+}
+
+// PayoutBatch is a persisted payout and its running result
+type PayoutBatch struct {
+	ID                  string              `json:"id" bun:",pk"`                                 // Unique batch ID
+	Reference           string              `json:"reference" bun:",nullzero,unique"`             // Client's idempotency key
+	SourceWalletID      string              `json:"sourceWalletId" bun:",notnull"`                // Funding wallet
+	CurrencyCode        string              `json:"currencyCode" bun:",notnull"`                  // Currency of every item
+	Mode                PayoutMode          `json:"mode" bun:",notnull"`                          // All-or-nothing or best effort
+	Status              PayoutBatchStatus   `json:"status" bun:",notnull"`                        // Current state
+	Description         string              `json:"description" bun:",nullzero"`                  // Default item description
+	InitiatorID         string              `json:"initiatorId" bun:",nullzero"`                  // Who submitted the batch
+	TransactionCategory TransactionCategory `json:"transactionCategory" bun:",notnull"`           // Category of every transfer
+	ItemCount           int                 `json:"itemCount" bun:",notnull"`                     // Number of recipients
+	TotalAmount         decimal.Decimal     `json:"totalAmount" bun:"type:decimal(24,8),notnull"` // Sum of item amounts
+	TotalFee            decimal.Decimal     `json:"totalFee" bun:"type:decimal(24,8),notnull"`    // Sum of item fees
+	CompletedCount      int                 `json:"completedCount" bun:",notnull"`                // Items paid
+	FailedCount         int                 `json:"failedCount" bun:",notnull"`                   // Items that failed
+	Error               string              `json:"error" bun:",nullzero"`                        // Why the batch failed
+	CreatedAt           time.Time           `json:"createdAt" bun:",notnull"`                     // Submission time
+	UpdatedAt           time.Time           `json:"updatedAt" bun:",notnull"`                     // Last progress
+	FinishedAt          time.Time           `json:"finishedAt" bun:",nullzero"`                   // When it reached a final status
+	Items               []*PayoutItem       `json:"items" bun:"rel:has-many,join:id=batch_id"`    // Recipients in submission order
+}
+
+// PayoutItem is one recipient of a batch and the result of paying them
+type PayoutItem struct {
+	ID                       string           `json:"id" bun:",pk"`                             // Unique item ID
+	BatchID                  string           `json:"batchId" bun:",notnull"`                   // Owning batch
+	Seq                      int              `json:"seq" bun:",notnull"`                       // Position in the request
+	DestinationWalletID      string           `json:"destinationWalletId" bun:",notnull"`       // Recipient wallet
+	Amount                   decimal.Decimal  `json:"amount" bun:"type:decimal(24,8),notnull"`  // Amount credited
+	Fee                      decimal.Decimal  `json:"fee" bun:"type:decimal(24,8),notnull"`     // Fee charged to the funding wallet
+	Reference                string           `json:"reference" bun:",nullzero"`                // Client's reference
+	Description              string           `json:"description" bun:",nullzero"`              // Transaction description
+	Status                   PayoutItemStatus `json:"status" bun:",notnull"`                    // Result
+	Error                    string           `json:"error" bun:",nullzero"`                    // Why the item failed
+	SourceTransactionID      string           `json:"sourceTransactionId" bun:",nullzero"`      // Debit written when paid
+	DestinationTransactionID string           `json:"destinationTransactionId" bun:",nullzero"` // Credit written when paid
+	ProcessedAt              time.Time        `json:"processedAt" bun:",nullzero"`              // When it was paid or failed
+}
+
+// NewPayoutBatch creates a pending batch from a request. It checks the
+// request's shape; whether the wallets can make each payment is checked by
+// Validate.
+func NewPayoutBatch(req PayoutRequest) (*PayoutBatch, error) {
+	if strings.TrimSpace(req.SourceWalletID) == "" {
+		return nil, ErrInvalidWalletID
+	}
+	if len(req.Items) == 0 {
+		return nil, ErrEmptyPayoutBatch
+	}
+	switch req.Mode {
+	case PayoutAllOrNothing, PayoutBestEffort:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPayoutMode, req.Mode)
+	}
+
+	category := req.TransactionCategory
+	if category == "" {
+		category = CategoryTransfer
+	}
+
+	now := time.Now().UTC()
+	batch := &PayoutBatch{
+		ID:                  GenerateID("pob_", 15),
+		Reference:           strings.TrimSpace(req.Reference),
+		SourceWalletID:      req.SourceWalletID,
+		Mode:                req.Mode,
+		Status:              PayoutBatchPending,
+		Description:         req.Description,
+		InitiatorID:         req.InitiatorID,
+		TransactionCategory: category,
+		ItemCount:           len(req.Items),
+		TotalAmount:         decimal.Zero,
+		TotalFee:            decimal.Zero,
+		CreatedAt:           now,
+		UpdatedAt:           now,
+	}
+
+	for i, itemReq := range req.Items {
+		description := itemReq.Description
+		if description == "" {
+			description = req.Description
+		}
+		item := &PayoutItem{
+			ID:                  GenerateID("poi_", 15),
+			BatchID:             batch.ID,
+			Seq:                 i + 1,
+			DestinationWalletID: itemReq.DestinationWalletID,
+			Amount:              itemReq.Amount,
+			Fee:                 itemReq.Fee,
+			Reference:           itemReq.Reference,
+			Description:         description,
+			Status:              PayoutItemPending,
+		}
+		batch.Items = append(batch.Items, item)
+		batch.TotalAmount = batch.TotalAmount.Add(item.Amount)
+		batch.TotalFee = batch.TotalFee.Add(item.Fee)
+	}
+
+	return batch, nil
+}
+
+// CheckRepeat checks that repeat, built from a request reusing the batch's
+// Reference, asks for the same payout: the same funding wallet, mode and
+// items in the same order. It returns an error wrapping
+// ErrPayoutReferenceUsed naming the first difference.
+func (b *PayoutBatch) CheckRepeat(repeat *PayoutBatch) error {
+	switch {
+	case repeat.SourceWalletID != b.SourceWalletID:
+		return fmt.Errorf("%w: funding wallet differs", ErrPayoutReferenceUsed)
+	case repeat.Mode != b.Mode:
+		return fmt.Errorf("%w: mode differs", ErrPayoutReferenceUsed)
+	case len(repeat.Items) != len(b.Items):
+		return fmt.Errorf("%w: %d items, first batch had %d", ErrPayoutReferenceUsed, len(repeat.Items), len(b.Items))
+	}
+
+	for i, item := range b.Items {
+		other := repeat.Items[i]
+		if other.DestinationWalletID != item.DestinationWalletID ||
+			!other.Amount.Equal(item.Amount) ||
+			!other.Fee.Equal(item.Fee) ||
+			other.Reference != item.Reference {
+			return fmt.Errorf("%w: item %d differs", ErrPayoutReferenceUsed, i+1)
+		}
+	}
+	return nil
+}
+
+// Validate checks the whole batch against the funding wallet and the
+// recipients before anything is paid. Items that cannot be paid are marked
+// failed. A best-effort batch goes on without them; an all-or-nothing batch
+// fails, as does any batch the funding wallet cannot pay in full.
+//
+// Parameters:
+//   - source: The funding wallet
+//   - recipients: Recipient wallets by ID; a missing entry fails its item
+//   - checkItem: Extra per-item check, such as an approval threshold; may be nil
+//
+// Returns:
+//   - nil if the batch can go ahead, otherwise the reason it failed, which
+//     wraps ErrPayoutRejected
+func (b *PayoutBatch) Validate(source *Wallet, recipients map[string]*Wallet, checkItem func(*PayoutItem) error) error {
+	b.CurrencyCode = source.CurrencyCode
+	if err := source.CanBeDebited(); err != nil {
+		return b.reject(err)
+	}
+
+	var firstErr error
+	total := decimal.Zero
+	for _, item := range b.Items {
+		if item.Status != PayoutItemPending {
+			continue
+		}
+
+		err := validatePayoutItem(source, recipients[item.DestinationWalletID], item)
+		if err == nil && checkItem != nil {
+			err = checkItem(item)
+		}
+		if err != nil {
+			item.Fail(err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("item %d: %w", item.Seq, err)
+			}
+			continue
+		}
+		total = total.Add(item.Amount).Add(item.Fee)
+	}
+
+	if firstErr != nil && b.Mode == PayoutAllOrNothing {
+		return b.reject(firstErr)
+	}
+	if source.AvailableBalance.LessThan(total) {
+		return b.reject(fmt.Errorf("%w: batch needs %s %s, wallet has %s",
+			ErrInsufficientFunds, total, source.CurrencyCode, source.AvailableBalance))
+	}
+	if b.Pending() == 0 {
+		return b.reject(firstErr)
+	}
+
+	b.Tally()
+	return nil
+}
+
+// validatePayoutItem checks a single payment, as TransferFunds will
+func validatePayoutItem(source, dest *Wallet, item *PayoutItem) error {
+	if item.Amount.LessThanOrEqual(decimal.Zero) {
+		return ErrInvalidAmount
+	}
+	if item.Fee.LessThan(decimal.Zero) {
+		return ErrInvalidFee
+	}
+	if item.DestinationWalletID == source.ID {
+		return ErrSameWalletPayout
+	}
+	if dest == nil {
+		return fmt.Errorf("%w: %s", ErrRecipientNotFound, item.DestinationWalletID)
+	}
+	if dest.CurrencyCode != source.CurrencyCode {
+		return fmt.Errorf("%w: recipient holds %s", ErrCurrencyMismatch, dest.CurrencyCode)
+	}
+	return dest.CanBeCredited()
+}
+
+// TransferRequest returns the transfer that pays an item
+func (b *PayoutBatch) TransferRequest(item *PayoutItem) TransferRequest {
+	reference := item.Reference
+	if reference == "" {
+		reference = item.ID
+	}
+
+	return TransferRequest{
+		Amount:                item.Amount,
+		Fee:                   item.Fee,
+		Description:           item.Description,
+		InitiatorID:           b.InitiatorID,
+		ExternalTransactionID: reference,
+		TransactionCategory:   b.TransactionCategory,
+	}
+}
+
+// Pending returns the number of items not yet paid or failed
+func (b *PayoutBatch) Pending() int {
+	pending := 0
+	for _, item := range b.Items {
+		if item.Status == PayoutItemPending {
+			pending++
+		}
+	}
+	return pending
+}
+
+// Tally recounts the item results and, once no item is pending, sets the
+// batch's final status
+func (b *PayoutBatch) Tally() {
+	b.CompletedCount, b.FailedCount = 0, 0
+	for _, item := range b.Items {
+		switch item.Status {
+		case PayoutItemCompleted:
+			b.CompletedCount++
+		case PayoutItemFailed, PayoutItemSkipped:
+			b.FailedCount++
+		}
+	}
+
+	now := time.Now().UTC()
+	b.UpdatedAt = now
+	if b.Pending() > 0 || b.Status.IsFinal() {
+		return
+	}
+
+	switch {
+	case b.FailedCount == 0:
+		b.Status = PayoutBatchCompleted
+	case b.CompletedCount == 0:
+		b.Status = PayoutBatchFailed
+	default:
+		b.Status = PayoutBatchPartial
+	}
+	b.FinishedAt = now
+}
+
+// Fail ends the batch without paying its pending items
+func (b *PayoutBatch) Fail(cause error) {
+	for _, item := range b.Items {
+		if item.Status == PayoutItemPending {
+			item.Status = PayoutItemSkipped
+		}
+	}
+	b.Status = PayoutBatchFailed
+	b.Error = cause.Error()
+	b.Tally()
+	b.FinishedAt = b.UpdatedAt
+}
+
+// reject fails the batch during validation
+func (b *PayoutBatch) reject(cause error) error {
+	err := fmt.Errorf("%w: %w", ErrPayoutRejected, cause)
+	b.Fail(err)
+	return err
+}
+
+// Complete records a paid item
+func (i *PayoutItem) Complete(sourceTx, destTx *TransactionHistory) {
+	i.Status = PayoutItemCompleted
+	i.Error = ""
+	i.SourceTransactionID = sourceTx.ID
+	i.DestinationTransactionID = destTx.ID
+	i.ProcessedAt = time.Now().UTC()
+}
+
+// Reset returns an item to pending, discarding a result that was rolled back
+func (i *PayoutItem) Reset() {
+	i.Status = PayoutItemPending
+	i.Error = ""
+	i.SourceTransactionID = ""
+	i.DestinationTransactionID = ""
+	i.ProcessedAt = time.Time{}
+}
+
+// Fail records an item that could not be paid
+func (i *PayoutItem) Fail(cause error) {
+	i.Status = PayoutItemFailed
+	i.Error = cause.Error()
+	i.ProcessedAt = time.Now().UTC()
+}
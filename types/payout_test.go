@@ -0,0 +1,221 @@
+package types
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func payoutWallets(t *testing.T) (*Wallet, map[string]*Wallet) {
+	t.Helper()
+
+	source, err := NewWallet("cus_payer", "USD")
+	require.NoError(t, err)
+	source.AvailableBalance = decimal.NewFromInt(100)
+
+	recipients := map[string]*Wallet{}
+	for _, spec := range []struct{ id, currency string }{{"wt_a", "USD"}, {"wt_b", "USD"}, {"wt_eur", "EUR"}, {"wt_closed", "USD"}} {
+		wallet, err := NewWallet("cus_"+spec.id, spec.currency)
+		require.NoError(t, err)
+		wallet.ID = spec.id
+		recipients[wallet.ID] = wallet
+	}
+	recipients["wt_closed"].IsClosed = true
+
+	return source, recipients
+}
+
+func payoutRequest(mode PayoutMode, source *Wallet, items ...PayoutItemRequest) PayoutRequest {
+	return PayoutRequest{
+		SourceWalletID: source.ID,
+		Mode:           mode,
+		Description:    "payroll",
+		InitiatorID:    "op_1",
+		Items:          items,
+	}
+}
+
+func payTo(walletID string, amount, fee int64) PayoutItemRequest {
+	return PayoutItemRequest{DestinationWalletID: walletID, Amount: decimal.NewFromInt(amount), Fee: decimal.NewFromInt(fee)}
+}
+
+func TestNewPayoutBatch(t *testing.T) {
+	source, _ := payoutWallets(t)
+
+	batch, err := NewPayoutBatch(payoutRequest(PayoutBestEffort, source, payTo("wt_a", 10, 1), payTo("wt_b", 20, 0)))
+	require.NoError(t, err)
+	assert.Equal(t, PayoutBatchPending, batch.Status)
+	assert.Equal(t, CategoryTransfer, batch.TransactionCategory)
+	assert.Equal(t, 2, batch.ItemCount)
+	assert.Equal(t, "30", batch.TotalAmount.String())
+	assert.Equal(t, "1", batch.TotalFee.String())
+	require.Len(t, batch.Items, 2)
+	assert.Equal(t, 2, batch.Items[1].Seq)
+	assert.Equal(t, batch.ID, batch.Items[1].BatchID)
+	assert.Equal(t, "payroll", batch.Items[1].Description)
+
+	// Items are traced by their reference, or their ID when they have none
+	batch.Items[0].Reference = "slip_1"
+	assert.Equal(t, "slip_1", batch.TransferRequest(batch.Items[0]).ExternalTransactionID)
+	assert.Equal(t, batch.Items[1].ID, batch.TransferRequest(batch.Items[1]).ExternalTransactionID)
+
+	_, err = NewPayoutBatch(payoutRequest(PayoutBestEffort, source))
+	assert.ErrorIs(t, err, ErrEmptyPayoutBatch)
+	_, err = NewPayoutBatch(payoutRequest("SOMETIMES", source, payTo("wt_a", 10, 0)))
+	assert.ErrorIs(t, err, ErrInvalidPayoutMode)
+}
+
+func TestPayoutBatchCheckRepeat(t *testing.T) {
+	source, _ := payoutWallets(t)
+	build := func(req PayoutRequest) *PayoutBatch {
+		batch, err := NewPayoutBatch(req)
+		require.NoError(t, err)
+		return batch
+	}
+	req := payoutRequest(PayoutBestEffort, source, payTo("wt_a", 10, 1), payTo("wt_b", 20, 0))
+	first := build(req)
+
+	// Descriptions and the initiator may change on a retry
+	repeat := req
+	repeat.Description = "payroll retry"
+	assert.NoError(t, first.CheckRepeat(build(repeat)))
+
+	repeat = req
+	repeat.SourceWalletID = "wt_other"
+	assert.ErrorIs(t, first.CheckRepeat(build(repeat)), ErrPayoutReferenceUsed)
+
+	repeat = req
+	repeat.Mode = PayoutAllOrNothing
+	assert.ErrorIs(t, first.CheckRepeat(build(repeat)), ErrPayoutReferenceUsed)
+
+	repeat = payoutRequest(PayoutBestEffort, source, payTo("wt_b", 20, 0), payTo("wt_a", 10, 1))
+	assert.ErrorIs(t, first.CheckRepeat(build(repeat)), ErrPayoutReferenceUsed, "order matters")
+
+	repeat = payoutRequest(PayoutBestEffort, source, payTo("wt_a", 10, 2), payTo("wt_b", 20, 0))
+	assert.ErrorIs(t, first.CheckRepeat(build(repeat)), ErrPayoutReferenceUsed)
+}
+
+func TestPayoutBatchValidate(t *testing.T) {
+	t.Run("best effort drops items that cannot be paid", func(t *testing.T) {
+		source, recipients := payoutWallets(t)
+		batch, err := NewPayoutBatch(payoutRequest(PayoutBestEffort, source,
+			payTo("wt_a", 10, 1),
+			payTo("wt_eur", 10, 0),
+			payTo("wt_closed", 10, 0),
+			payTo("wt_missing", 10, 0),
+			payTo(source.ID, 10, 0),
+			payTo("wt_b", 0, 0),
+			payTo("wt_b", 20, 0),
+		))
+		require.NoError(t, err)
+
+		require.NoError(t, batch.Validate(source, recipients, nil))
+		assert.Equal(t, "USD", batch.CurrencyCode)
+		assert.Equal(t, PayoutBatchPending, batch.Status)
+		assert.Equal(t, 2, batch.Pending())
+		assert.Equal(t, 5, batch.FailedCount)
+
+		errs := map[int]string{}
+		for _, item := range batch.Items {
+			errs[item.Seq] = item.Error
+		}
+		assert.Empty(t, errs[1])
+		assert.Contains(t, errs[2], ErrCurrencyMismatch.Error())
+		assert.Equal(t, ErrWalletClosed.Error(), errs[3])
+		assert.Contains(t, errs[4], ErrRecipientNotFound.Error())
+		assert.Equal(t, ErrSameWalletPayout.Error(), errs[5])
+		assert.Equal(t, ErrInvalidAmount.Error(), errs[6])
+	})
+
+	t.Run("all or nothing fails on any item", func(t *testing.T) {
+		source, recipients := payoutWallets(t)
+		batch, err := NewPayoutBatch(payoutRequest(PayoutAllOrNothing, source, payTo("wt_a", 10, 0), payTo("wt_closed", 10, 0)))
+		require.NoError(t, err)
+
+		err = batch.Validate(source, recipients, nil)
+		assert.ErrorIs(t, err, ErrPayoutRejected)
+		assert.ErrorIs(t, err, ErrWalletClosed)
+		assert.Equal(t, PayoutBatchFailed, batch.Status)
+		assert.Equal(t, PayoutItemSkipped, batch.Items[0].Status)
+		assert.Equal(t, PayoutItemFailed, batch.Items[1].Status)
+		assert.Equal(t, 2, batch.FailedCount)
+		assert.False(t, batch.FinishedAt.IsZero())
+	})
+
+	t.Run("the funding wallet must cover every item and fee", func(t *testing.T) {
+		source, recipients := payoutWallets(t)
+		batch, err := NewPayoutBatch(payoutRequest(PayoutBestEffort, source, payTo("wt_a", 50, 1), payTo("wt_b", 49, 1)))
+		require.NoError(t, err)
+
+		err = batch.Validate(source, recipients, nil)
+		assert.ErrorIs(t, err, ErrPayoutRejected)
+		assert.ErrorIs(t, err, ErrInsufficientFunds)
+		assert.Equal(t, PayoutBatchFailed, batch.Status)
+		assert.Zero(t, batch.Pending())
+	})
+
+	t.Run("a frozen funding wallet cannot pay", func(t *testing.T) {
+		source, recipients := payoutWallets(t)
+		source.Frozen = true
+		batch, err := NewPayoutBatch(payoutRequest(PayoutBestEffort, source, payTo("wt_a", 10, 0)))
+		require.NoError(t, err)
+
+		assert.ErrorIs(t, batch.Validate(source, recipients, nil), ErrWalletFrozen)
+	})
+
+	t.Run("extra item checks", func(t *testing.T) {
+		source, recipients := payoutWallets(t)
+		batch, err := NewPayoutBatch(payoutRequest(PayoutBestEffort, source, payTo("wt_a", 10, 0), payTo("wt_b", 60, 0)))
+		require.NoError(t, err)
+
+		err = batch.Validate(source, recipients, func(item *PayoutItem) error {
+			if item.Amount.GreaterThan(decimal.NewFromInt(50)) {
+				return ErrApprovalRequired
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, PayoutItemPending, batch.Items[0].Status)
+		assert.Equal(t, ErrApprovalRequired.Error(), batch.Items[1].Error)
+	})
+}
+
+func TestPayoutBatchTally(t *testing.T) {
+	source, _ := payoutWallets(t)
+	batch, err := NewPayoutBatch(payoutRequest(PayoutBestEffort, source, payTo("wt_a", 10, 0), payTo("wt_b", 10, 0)))
+	require.NoError(t, err)
+
+	paid := &TransactionHistory{ID: "tx_1"}
+	batch.Items[0].Complete(paid, &TransactionHistory{ID: "tx_2"})
+	batch.Tally()
+	assert.Equal(t, PayoutBatchPending, batch.Status)
+	assert.Equal(t, 1, batch.CompletedCount)
+	assert.Equal(t, "tx_1", batch.Items[0].SourceTransactionID)
+
+	batch.Items[1].Fail(ErrWalletClosed)
+	batch.Tally()
+	assert.Equal(t, PayoutBatchPartial, batch.Status)
+	assert.Equal(t, 1, batch.FailedCount)
+	assert.False(t, batch.FinishedAt.IsZero())
+
+	batch.Items[1].Reset()
+	assert.Equal(t, PayoutItemPending, batch.Items[1].Status)
+	assert.Empty(t, batch.Items[1].Error)
+}
+
+func TestPayoutRequestSigningPayload(t *testing.T) {
+	source, _ := payoutWallets(t)
+	req := payoutRequest(PayoutAllOrNothing, source, payTo("wt_a", 10, 0), payTo("wt_b", 20, 0))
+	sig := &RequestSignature{KeyID: "key_1", Algorithm: SignatureHMACSHA256, Timestamp: time.Now(), Nonce: "n1"}
+
+	payload := req.SigningPayload(sig)
+	assert.Equal(t, payload, req.SigningPayload(sig))
+
+	// Changing any item changes the payload
+	req.Items[1].Amount = decimal.NewFromInt(21)
+	assert.False(t, bytes.Equal(payload, req.SigningPayload(sig)))
+}
@@ -0,0 +1,218 @@
+package types
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// WalletStatusKind is a change to a wallet's frozen or closed state
+type WalletStatusKind string
+
+const (
+	StatusChangeFreeze   WalletStatusKind = "FREEZE"   // Debits were blocked
+	StatusChangeUnfreeze WalletStatusKind = "UNFREEZE" // A freeze was lifted
+	StatusChangeClose    WalletStatusKind = "CLOSE"    // The wallet was closed
+	StatusChangeReopen   WalletStatusKind = "REOPEN"   // The wallet was reopened
+)
+
+// WalletStatusChange records a freeze, unfreeze, close or reopen so wallet
+// state can be rebuilt from history
+type WalletStatusChange struct {
+	ID          string           `json:"id" bun:",pk"`                // Unique change ID
+	WalletID    string           `json:"walletId" bun:",notnull"`     // Affected wallet
+	Kind        WalletStatusKind `json:"kind" bun:",notnull"`         // What changed
+	Reason      string           `json:"reason" bun:",nullzero"`      // Why it changed
+	InitiatedBy string           `json:"initiatedBy" bun:",nullzero"` // Who changed it
+	CreatedAt   time.Time        `json:"createdAt" bun:",notnull"`    // When it changed
+}
+
+// NewWalletStatusChange records a change made to wallet. For freezes the
+// reason, initiator and time are taken from the wallet's freeze fields.
+func NewWalletStatusChange(wallet *Wallet, kind WalletStatusKind, reason, initiatedBy string) *WalletStatusChange {
+	change := &WalletStatusChange{
+		ID:          GenerateID("wsc_", 15),
+		WalletID:    wallet.ID,
+		Kind:        kind,
+		Reason:      reason,
+		InitiatedBy: initiatedBy,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if kind == StatusChangeFreeze {
+		change.Reason = wallet.FreezeReason
+		change.InitiatedBy = wallet.FreezeInitiatedBy
+		if !wallet.FrozenAt.IsZero() {
+			change.CreatedAt = wallet.FrozenAt.UTC()
+		}
+	}
+	return change
+}
+
+// WalletProjection is wallet state rebuilt from history alone
+type WalletProjection struct {
+	WalletID          string          `json:"walletId"`          // Rebuilt wallet
+	AsOf              time.Time       `json:"asOf"`              // History up to and including this time was applied
+	AvailableBalance  decimal.Decimal `json:"availableBalance"`  // Rebuilt available balance
+	LienBalance       decimal.Decimal `json:"lienBalance"`       // Rebuilt lien balance
+	Frozen            bool            `json:"frozen"`            // Rebuilt frozen flag
+	FreezeReason      string          `json:"freezeReason"`      // Reason of the freeze in effect
+	FreezeInitiatedBy string          `json:"freezeInitiatedBy"` // Initiator of the freeze in effect
+	FrozenAt          time.Time       `json:"frozenAt"`          // Start of the freeze in effect
+	IsClosed          bool            `json:"isClosed"`          // Rebuilt closed flag
+	FreezeHistory     bool            `json:"freezeHistory"`     // A freeze or unfreeze was applied; without one the frozen fields are not rebuilt
+	CloseHistory      bool            `json:"closeHistory"`      // A close or reopen was applied; without one the closed flag is not rebuilt
+	TransactionCount  int             `json:"transactionCount"`  // Transactions applied
+	LienCount         int             `json:"lienCount"`         // Lien placements and releases applied
+	StatusChangeCount int             `json:"statusChangeCount"` // Status changes applied
+}
+
+// ProjectionDiff is a field whose stored value differs from the rebuilt one
+type ProjectionDiff struct {
+	WalletID string `json:"walletId"` // Affected wallet
+	Field    string `json:"field"`    // Wallet column
+	Current  string `json:"current"`  // Value in the wallets table
+	Rebuilt  string `json:"rebuilt"`  // Value rebuilt from history
+}
+
+// ProjectWallet rebuilds a wallet's state at asOf from its transactions, lien
+// records and status changes. A zero asOf applies the whole history.
+//
+// Every transaction that moved the balance is applied, as in ReplayWallet: a
+// transaction held for review moved it when it was written (a held credit
+// into a lien), and a rejected one is undone by its own reversal row.
+//
+// Parameters:
+//   - walletID: Wallet being rebuilt
+//   - transactions: The wallet's transactions
+//   - liens: The wallet's lien records, including release rows
+//   - changes: The wallet's status changes
+//   - asOf: Point in time to rebuild (zero for now)
+//
+// Returns:
+//   - *WalletProjection with the rebuilt state
+func ProjectWallet(
+	walletID string,
+	transactions []*TransactionHistory,
+	liens []*LienRecord,
+	changes []*WalletStatusChange,
+	asOf time.Time,
+) *WalletProjection {
+	projection := &WalletProjection{WalletID: walletID, AsOf: asOf}
+	included := func(at time.Time) bool { return asOf.IsZero() || !at.After(asOf) }
+
+	var events []ledgerEvent
+	for _, tx := range transactions {
+		if tx != nil && tx.MovesBalance() && included(tx.CreatedAt) {
+			events = append(events, ledgerEvent{at: tx.CreatedAt, id: tx.ID, tx: tx})
+		}
+	}
+	for _, ev := range lienEvents(liens) {
+		if included(ev.at) {
+			events = append(events, ev)
+		}
+	}
+	sortLedgerEvents(events)
+
+	for _, ev := range events {
+		if ev.tx != nil {
+			projection.AvailableBalance = projection.AvailableBalance.Add(BalanceEffect(ev.tx))
+			projection.TransactionCount++
+			continue
+		}
+		projection.AvailableBalance = projection.AvailableBalance.Sub(ev.lien)
+		projection.LienBalance = projection.LienBalance.Add(ev.lien)
+		projection.LienCount++
+	}
+
+	ordered := make([]*WalletStatusChange, 0, len(changes))
+	for _, change := range changes {
+		if change != nil && included(change.CreatedAt) {
+			ordered = append(ordered, change)
+		}
+	}
+	sortStatusChanges(ordered)
+
+	for _, change := range ordered {
+		projection.StatusChangeCount++
+		switch change.Kind {
+		case StatusChangeFreeze:
+			projection.FreezeHistory = true
+			projection.Frozen = true
+			projection.FreezeReason = change.Reason
+			projection.FreezeInitiatedBy = change.InitiatedBy
+			projection.FrozenAt = change.CreatedAt
+		case StatusChangeUnfreeze:
+			projection.FreezeHistory = true
+			projection.Frozen = false
+			projection.FreezeReason = ""
+			projection.FreezeInitiatedBy = ""
+			projection.FrozenAt = time.Time{}
+		case StatusChangeClose:
+			projection.CloseHistory = true
+			projection.IsClosed = true
+		case StatusChangeReopen:
+			projection.CloseHistory = true
+			projection.IsClosed = false
+		}
+	}
+
+	return projection
+}
+
+// sortStatusChanges orders changes by time, then ID
+func sortStatusChanges(changes []*WalletStatusChange) {
+	sort.SliceStable(changes, func(i, j int) bool {
+		if !changes[i].CreatedAt.Equal(changes[j].CreatedAt) {
+			return changes[i].CreatedAt.Before(changes[j].CreatedAt)
+		}
+		return changes[i].ID < changes[j].ID
+	})
+}
+
+// Diff lists the fields of wallet that differ from the projection. The
+// frozen and closed flags are compared only when the projection has history
+// for them: wallets frozen or closed before status changes were recorded have
+// none, and their stored flags are the only record.
+func (p *WalletProjection) Diff(wallet *Wallet) []ProjectionDiff {
+	var diffs []ProjectionDiff
+	add := func(field, current, rebuilt string) {
+		diffs = append(diffs, ProjectionDiff{WalletID: wallet.ID, Field: field, Current: current, Rebuilt: rebuilt})
+	}
+
+	if !wallet.AvailableBalance.Equal(p.AvailableBalance) {
+		add("available_balance", wallet.AvailableBalance.String(), p.AvailableBalance.String())
+	}
+	if !wallet.LienBalance.Equal(p.LienBalance) {
+		add("lien_balance", wallet.LienBalance.String(), p.LienBalance.String())
+	}
+	if p.FreezeHistory && wallet.Frozen != p.Frozen {
+		add("frozen", strconv.FormatBool(wallet.Frozen), strconv.FormatBool(p.Frozen))
+	}
+	if p.CloseHistory && wallet.IsClosed != p.IsClosed {
+		add("is_closed", strconv.FormatBool(wallet.IsClosed), strconv.FormatBool(p.IsClosed))
+	}
+
+	return diffs
+}
+
+// Apply overwrites wallet's balances with the projection, and its frozen and
+// closed state where the projection has history for them (see Diff)
+func (p *WalletProjection) Apply(wallet *Wallet) {
+	wallet.mutex.Lock()
+	defer wallet.mutex.Unlock()
+
+	wallet.AvailableBalance = p.AvailableBalance
+	wallet.LienBalance = p.LienBalance
+	if p.FreezeHistory {
+		wallet.Frozen = p.Frozen
+		wallet.FreezeReason = p.FreezeReason
+		wallet.FreezeInitiatedBy = p.FreezeInitiatedBy
+		wallet.FrozenAt = p.FrozenAt
+	}
+	if p.CloseHistory {
+		wallet.IsClosed = p.IsClosed
+	}
+	wallet.UpdatedAt = time.Now().UTC()
+}
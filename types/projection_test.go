@@ -0,0 +1,133 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectWallet(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return start.Add(time.Duration(hours) * time.Hour) }
+	d := decimal.NewFromInt
+
+	row := func(id string, hour int, typ TransactionType, amount, fee int64, status TransactionStatus) *TransactionHistory {
+		return &TransactionHistory{
+			ID:        id,
+			WalletID:  "wt_1",
+			Type:      typ,
+			Amount:    d(amount),
+			Fee:       d(fee),
+			CreatedAt: at(hour),
+			Status:    status,
+		}
+	}
+
+	transactions := []*TransactionHistory{
+		row("t1", 1, TypeCredit, 100, 0, StatusCompleted),
+		row("t2", 2, TypeDebit, 20, 1, StatusCompleted),
+		row("t3", 6, TypeCredit, 50, 0, StatusPending), // Held for review, balance already moved
+		row("t4", 7, TypeDebit, 500, 0, StatusFailed),
+	}
+
+	// A lien of 30 released in two parts
+	placed := &LienRecord{ID: "lien_1", WalletID: "wt_1", Amount: d(30), CreatedAt: at(3), ReleasedAt: at(5)}
+	first := (&LienRecord{ID: "lien_1", WalletID: "wt_1", Amount: d(10), ReleasedAt: at(4)}).ReleaseRecord()
+	second := (&LienRecord{ID: "lien_1", WalletID: "wt_1", Amount: d(5), ReleasedAt: at(5)}).ReleaseRecord()
+	liens := []*LienRecord{placed, first, second}
+
+	changes := []*WalletStatusChange{
+		{ID: "wsc_1", WalletID: "wt_1", Kind: StatusChangeFreeze, Reason: "fraud", InitiatedBy: "ops", CreatedAt: at(2)},
+		{ID: "wsc_2", WalletID: "wt_1", Kind: StatusChangeUnfreeze, CreatedAt: at(4)},
+		{ID: "wsc_3", WalletID: "wt_1", Kind: StatusChangeClose, CreatedAt: at(8)},
+		{ID: "wsc_4", WalletID: "wt_1", Kind: StatusChangeReopen, CreatedAt: at(9)},
+	}
+
+	t.Run("current state", func(t *testing.T) {
+		p := ProjectWallet("wt_1", transactions, liens, changes, time.Time{})
+		assert.Equal(t, "114", p.AvailableBalance.String())
+		assert.Equal(t, "15", p.LienBalance.String())
+		assert.False(t, p.Frozen)
+		assert.False(t, p.IsClosed)
+		assert.Equal(t, 3, p.TransactionCount)
+		assert.Equal(t, 3, p.LienCount)
+		assert.Equal(t, 4, p.StatusChangeCount)
+	})
+
+	t.Run("point in time", func(t *testing.T) {
+		p := ProjectWallet("wt_1", transactions, liens, changes, at(3))
+		assert.Equal(t, "49", p.AvailableBalance.String())
+		assert.Equal(t, "30", p.LienBalance.String())
+		assert.True(t, p.Frozen)
+		assert.Equal(t, "fraud", p.FreezeReason)
+		assert.Equal(t, "ops", p.FreezeInitiatedBy)
+		assert.Equal(t, at(2), p.FrozenAt)
+
+		p = ProjectWallet("wt_1", transactions, liens, changes, at(4))
+		assert.Equal(t, "59", p.AvailableBalance.String())
+		assert.Equal(t, "20", p.LienBalance.String())
+		assert.False(t, p.Frozen)
+		assert.Empty(t, p.FreezeReason)
+
+		p = ProjectWallet("wt_1", transactions, liens, changes, at(8))
+		assert.True(t, p.IsClosed)
+	})
+
+	t.Run("legacy lien without release rows", func(t *testing.T) {
+		p := ProjectWallet("wt_1", transactions[:2], []*LienRecord{placed}, nil, time.Time{})
+		assert.Equal(t, "79", p.AvailableBalance.String())
+		assert.True(t, p.LienBalance.IsZero())
+
+		p = ProjectWallet("wt_1", transactions[:2], []*LienRecord{placed}, nil, at(4))
+		assert.Equal(t, "49", p.AvailableBalance.String())
+		assert.Equal(t, "30", p.LienBalance.String())
+	})
+
+	t.Run("diff and apply", func(t *testing.T) {
+		p := ProjectWallet("wt_1", transactions, liens, changes[:1], time.Time{})
+
+		wallet := &Wallet{ID: "wt_1", AvailableBalance: d(114), LienBalance: d(15), Frozen: true}
+		assert.Empty(t, p.Diff(wallet))
+
+		wallet.AvailableBalance = d(1000)
+		wallet.Frozen = false
+		diffs := p.Diff(wallet)
+		require.Len(t, diffs, 2)
+		assert.Equal(t, ProjectionDiff{WalletID: "wt_1", Field: "available_balance", Current: "1000", Rebuilt: "114"}, diffs[0])
+		assert.Equal(t, "frozen", diffs[1].Field)
+
+		p.Apply(wallet)
+		assert.Empty(t, p.Diff(wallet))
+		assert.True(t, wallet.Frozen)
+		assert.Equal(t, "fraud", wallet.FreezeReason)
+		assert.Equal(t, at(2), wallet.FrozenAt)
+	})
+
+	t.Run("status without history is left alone", func(t *testing.T) {
+		// Frozen and closed before status changes were recorded
+		p := ProjectWallet("wt_1", transactions, liens, nil, time.Time{})
+		wallet := &Wallet{
+			ID: "wt_1", AvailableBalance: d(1000), LienBalance: d(15),
+			Frozen: true, FreezeReason: "chargeback", IsClosed: true,
+		}
+
+		diffs := p.Diff(wallet)
+		require.Len(t, diffs, 1)
+		assert.Equal(t, "available_balance", diffs[0].Field)
+
+		p.Apply(wallet)
+		assert.Equal(t, "114", wallet.AvailableBalance.String())
+		assert.True(t, wallet.Frozen)
+		assert.Equal(t, "chargeback", wallet.FreezeReason)
+		assert.True(t, wallet.IsClosed)
+
+		// History of one flag does not make the other known
+		p = ProjectWallet("wt_1", transactions, liens, changes[:2], time.Time{})
+		p.Apply(wallet)
+		assert.False(t, wallet.Frozen)
+		assert.True(t, wallet.IsClosed)
+	})
+}
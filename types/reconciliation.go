@@ -0,0 +1,330 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Error definitions for reconciliation
+var (
+	ErrInvalidSettlementRecord = errors.New("invalid settlement record")
+	ErrReconciliationNotFound  = errors.New("reconciliation run not found")
+)
+
+// ReconciliationStatus classifies a reconciled item
+type ReconciliationStatus string
+
+const (
+	ReconMatched         ReconciliationStatus = "MATCHED"          // Both sides agree
+	ReconMissingInternal ReconciliationStatus = "MISSING_INTERNAL" // In the settlement file, not in our ledger
+	ReconMissingExternal ReconciliationStatus = "MISSING_EXTERNAL" // In our ledger, not in the settlement file
+	ReconAmountMismatch  ReconciliationStatus = "AMOUNT_MISMATCH"  // Found on both sides with different amounts
+)
+
+// SettlementRecord is a single line of an external settlement report
+type SettlementRecord struct {
+	ExternalReference string          `json:"externalReference"` // Processor or bank reference
+	Amount            decimal.Decimal `json:"amount"`            // Settled amount (unsigned)
+	CurrencyCode      string          `json:"currencyCode"`      // Settled currency
+	SettledAt         time.Time       `json:"settledAt"`         // Value or settlement date
+	Line              int             `json:"line"`              // Position in the source file (1-based)
+}
+
+// Validate checks that the record can take part in matching
+func (s SettlementRecord) Validate() error {
+	switch {
+	case s.Amount.IsNegative():
+		return fmt.Errorf("%w: line %d: amount must not be negative", ErrInvalidSettlementRecord, s.Line)
+	case s.CurrencyCode == "":
+		return fmt.Errorf("%w: line %d: currency is required", ErrInvalidSettlementRecord, s.Line)
+	case s.SettledAt.IsZero():
+		return fmt.Errorf("%w: line %d: settlement date is required", ErrInvalidSettlementRecord, s.Line)
+	}
+	return nil
+}
+
+// ReconciliationOptions controls how records are matched
+type ReconciliationOptions struct {
+	DateTolerance   time.Duration   // Allowed distance between settlement and transaction time
+	AmountTolerance decimal.Decimal // Allowed absolute amount difference for a match
+	PeriodStart     time.Time       // Start of the settlement period (defaults to earliest record)
+	PeriodEnd       time.Time       // End of the settlement period (defaults to the end of the latest record's day)
+	ReferencePrefix string          // When set, only ledger references carrying it belong to the source; empty matches bare references
+}
+
+// SourceReferencePrefix is the ledger reference prefix of a settlement
+// source. Ledgers that book rows as "<source>:<reference>" set it as
+// ReferencePrefix, so the source's files, where rows appear as "<reference>",
+// are reconciled against that source's rows only.
+func SourceReferencePrefix(source string) string {
+	return source + ":"
+}
+
+// ReconciliationItem is the outcome for a single external record or internal transaction
+type ReconciliationItem struct {
+	ID                string               `json:"id" bun:",pk"`                                     // Unique item ID
+	RunID             string               `json:"runId" bun:",notnull"`                             // Owning run
+	Status            ReconciliationStatus `json:"status" bun:",notnull"`                            // Classification
+	ExternalReference string               `json:"externalReference" bun:",notnull"`                 // Matching key
+	TransactionID     string               `json:"transactionId" bun:",nullzero"`                    // Internal transaction, if any
+	WalletID          string               `json:"walletId" bun:",nullzero"`                         // Internal wallet, if any
+	CurrencyCode      string               `json:"currencyCode" bun:",notnull"`                      // Currency of the item
+	ExternalAmount    decimal.Decimal      `json:"externalAmount" bun:",type:decimal(24,8),notnull"` // Amount in the settlement file
+	InternalAmount    decimal.Decimal      `json:"internalAmount" bun:",type:decimal(24,8),notnull"` // Amount in our ledger
+	Difference        decimal.Decimal      `json:"difference" bun:",type:decimal(24,8),notnull"`     // External minus internal
+	ExternalDate      time.Time            `json:"externalDate" bun:",nullzero"`                     // Settlement date
+	InternalDate      time.Time            `json:"internalDate" bun:",nullzero"`                     // Transaction creation time
+	SourceLine        int                  `json:"sourceLine" bun:",notnull,default:0"`              // Line in the settlement file
+	Reason            string               `json:"reason" bun:",nullzero"`                           // Why the item is an exception
+}
+
+// IsException reports whether the item needs follow-up
+func (i *ReconciliationItem) IsException() bool {
+	return i.Status != ReconMatched
+}
+
+// ReconciliationRun is a persisted reconciliation of one settlement file
+type ReconciliationRun struct {
+	ID                   string                `json:"id" bun:",pk"`                                      // Unique run ID
+	Source               string                `json:"source" bun:",notnull"`                             // Processor or bank the file came from
+	PeriodStart          time.Time             `json:"periodStart" bun:",notnull"`                        // Settlement period start
+	PeriodEnd            time.Time             `json:"periodEnd" bun:",notnull"`                          // Settlement period end
+	ExternalCount        int                   `json:"externalCount" bun:",notnull,default:0"`            // Records in the settlement file
+	InternalCount        int                   `json:"internalCount" bun:",notnull,default:0"`            // Ledger transactions considered
+	MatchedCount         int                   `json:"matchedCount" bun:",notnull,default:0"`             // Items matched
+	MissingInternalCount int                   `json:"missingInternalCount" bun:",notnull,default:0"`     // Items only in the file
+	MissingExternalCount int                   `json:"missingExternalCount" bun:",notnull,default:0"`     // Items only in the ledger
+	AmountMismatchCount  int                   `json:"amountMismatchCount" bun:",notnull,default:0"`      // Items with differing amounts
+	CreatedAt            time.Time             `json:"createdAt" bun:",notnull"`                          // When the run was performed
+	Items                []*ReconciliationItem `json:"items,omitempty" bun:"rel:has-many,join:id=run_id"` // Item outcomes
+}
+
+// Exceptions returns the items that are not matched
+func (r *ReconciliationRun) Exceptions() []*ReconciliationItem {
+	var exceptions []*ReconciliationItem
+	for _, item := range r.Items {
+		if item.IsException() {
+			exceptions = append(exceptions, item)
+		}
+	}
+	return exceptions
+}
+
+// Reconcile matches settlement records against ledger transactions.
+//
+// Records are matched to completed transactions on external reference and
+// currency, within DateTolerance of the settlement date. When ReferencePrefix
+// is set only rows whose reference carries it take part, and they are matched
+// on the reference without it. Each transaction is
+// matched at most once. Transactions created within the settlement period that
+// no record claimed are reported as missing from the external side.
+//
+// Parameters:
+//   - source: Name of the processor or bank that produced the records
+//   - records: Parsed settlement records
+//   - transactions: Ledger transactions covering the period plus tolerance
+//   - opts: Matching options
+//
+// Returns:
+//   - *ReconciliationRun with one item per record and per unmatched transaction
+//   - error if a record is invalid
+func Reconcile(
+	source string,
+	records []SettlementRecord,
+	transactions []*TransactionHistory,
+	opts ReconciliationOptions,
+) (*ReconciliationRun, error) {
+	for _, rec := range records {
+		if err := rec.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	run := &ReconciliationRun{
+		ID:            GenerateID("rcn_", 15),
+		Source:        source,
+		PeriodStart:   opts.PeriodStart,
+		PeriodEnd:     opts.PeriodEnd,
+		ExternalCount: len(records),
+		CreatedAt:     time.Now().UTC(),
+	}
+	if run.PeriodStart.IsZero() || run.PeriodEnd.IsZero() {
+		start, end := SettlementPeriod(records)
+		if run.PeriodStart.IsZero() {
+			run.PeriodStart = start
+		}
+		if run.PeriodEnd.IsZero() {
+			run.PeriodEnd = end
+		}
+	}
+
+	// Index the source's completed ledger rows by reference
+	byRef := make(map[string][]*TransactionHistory)
+	for _, tx := range transactions {
+		if tx == nil || tx.Status != StatusCompleted || tx.ExternalReference == "" {
+			continue
+		}
+		ref, ok := strings.CutPrefix(tx.ExternalReference, opts.ReferencePrefix)
+		if !ok || ref == "" {
+			continue
+		}
+		run.InternalCount++
+		byRef[ref] = append(byRef[ref], tx)
+	}
+
+	used := make(map[string]bool)
+	for _, rec := range records {
+		item := &ReconciliationItem{
+			ID:                GenerateID("rci_", 15),
+			RunID:             run.ID,
+			ExternalReference: rec.ExternalReference,
+			CurrencyCode:      strings.ToUpper(rec.CurrencyCode),
+			ExternalAmount:    rec.Amount,
+			InternalAmount:    decimal.Zero,
+			Difference:        rec.Amount,
+			ExternalDate:      rec.SettledAt,
+			SourceLine:        rec.Line,
+		}
+
+		tx, reason := findSettlementMatch(rec, byRef[rec.ExternalReference], used, opts)
+		if tx == nil {
+			item.Status = ReconMissingInternal
+			item.Reason = reason
+			run.Items = append(run.Items, item)
+			continue
+		}
+
+		used[tx.ID] = true
+		item.TransactionID = tx.ID
+		item.WalletID = tx.WalletID
+		item.InternalAmount = tx.Amount
+		item.InternalDate = tx.CreatedAt
+		item.Difference = rec.Amount.Sub(tx.Amount)
+		item.Status = ReconMatched
+		if item.Difference.Abs().GreaterThan(opts.AmountTolerance) {
+			item.Status = ReconAmountMismatch
+			item.Reason = fmt.Sprintf("settled %s, booked %s", rec.Amount.String(), tx.Amount.String())
+		}
+		run.Items = append(run.Items, item)
+	}
+
+	// Ledger rows in the period that nothing in the file accounted for
+	var unmatched []*TransactionHistory
+	for _, txs := range byRef {
+		for _, tx := range txs {
+			if used[tx.ID] || tx.CreatedAt.Before(run.PeriodStart) || tx.CreatedAt.After(run.PeriodEnd) {
+				continue
+			}
+			unmatched = append(unmatched, tx)
+		}
+	}
+	sort.Slice(unmatched, func(i, j int) bool {
+		if !unmatched[i].CreatedAt.Equal(unmatched[j].CreatedAt) {
+			return unmatched[i].CreatedAt.Before(unmatched[j].CreatedAt)
+		}
+		return unmatched[i].ID < unmatched[j].ID
+	})
+	for _, tx := range unmatched {
+		run.Items = append(run.Items, &ReconciliationItem{
+			ID:                GenerateID("rci_", 15),
+			RunID:             run.ID,
+			Status:            ReconMissingExternal,
+			ExternalReference: tx.ExternalReference,
+			TransactionID:     tx.ID,
+			WalletID:          tx.WalletID,
+			CurrencyCode:      tx.CurrencyCode,
+			ExternalAmount:    decimal.Zero,
+			InternalAmount:    tx.Amount,
+			Difference:        tx.Amount.Neg(),
+			InternalDate:      tx.CreatedAt,
+			Reason:            "not present in settlement file",
+		})
+	}
+
+	for _, item := range run.Items {
+		switch item.Status {
+		case ReconMatched:
+			run.MatchedCount++
+		case ReconMissingInternal:
+			run.MissingInternalCount++
+		case ReconMissingExternal:
+			run.MissingExternalCount++
+		case ReconAmountMismatch:
+			run.AmountMismatchCount++
+		}
+	}
+
+	return run, nil
+}
+
+// findSettlementMatch picks the unused candidate for a record, preferring an
+// exact amount match and then the closest date. When there is no candidate it
+// returns a reason describing why.
+func findSettlementMatch(
+	rec SettlementRecord,
+	candidates []*TransactionHistory,
+	used map[string]bool,
+	opts ReconciliationOptions,
+) (*TransactionHistory, string) {
+	if rec.ExternalReference == "" {
+		return nil, "settlement record has no reference"
+	}
+	if len(candidates) == 0 {
+		return nil, "no transaction with this reference"
+	}
+
+	var best *TransactionHistory
+	var bestExact bool
+	var bestDistance time.Duration
+	var reason = "reference already matched"
+
+	for _, tx := range candidates {
+		if used[tx.ID] {
+			continue
+		}
+		if !strings.EqualFold(tx.CurrencyCode, rec.CurrencyCode) {
+			reason = fmt.Sprintf("currency differs: settled %s, booked %s", rec.CurrencyCode, tx.CurrencyCode)
+			continue
+		}
+		distance := tx.CreatedAt.Sub(rec.SettledAt)
+		if distance < 0 {
+			distance = -distance
+		}
+		if distance > opts.DateTolerance {
+			reason = "transaction outside date tolerance"
+			continue
+		}
+
+		exact := rec.Amount.Sub(tx.Amount).Abs().LessThanOrEqual(opts.AmountTolerance)
+		if best == nil || (exact && !bestExact) || (exact == bestExact && distance < bestDistance) {
+			best, bestExact, bestDistance = tx, exact, distance
+		}
+	}
+
+	return best, reason
+}
+
+// SettlementPeriod returns the earliest settlement date in records and the
+// end of the day of the latest one, so ledger rows booked later on the last
+// day of a date-only file still fall inside the period
+func SettlementPeriod(records []SettlementRecord) (time.Time, time.Time) {
+	var start, end time.Time
+	for _, rec := range records {
+		if start.IsZero() || rec.SettledAt.Before(start) {
+			start = rec.SettledAt
+		}
+		if rec.SettledAt.After(end) {
+			end = rec.SettledAt
+		}
+	}
+	if !end.IsZero() {
+		year, month, day := end.Date()
+		end = time.Date(year, month, day+1, 0, 0, 0, 0, end.Location()).Add(-time.Nanosecond)
+	}
+	return start, end
+}
@@ -0,0 +1,101 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcile(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tx := func(id, ref, ccy string, amount int64, at time.Time) *TransactionHistory {
+		return &TransactionHistory{
+			ID:                id,
+			WalletID:          "wt_1",
+			ExternalReference: ref,
+			CurrencyCode:      ccy,
+			Amount:            decimal.NewFromInt(amount),
+			Status:            StatusCompleted,
+			CreatedAt:         at,
+		}
+	}
+	rec := func(line int, ref, ccy string, amount int64, at time.Time) SettlementRecord {
+		return SettlementRecord{ExternalReference: ref, CurrencyCode: ccy, Amount: decimal.NewFromInt(amount), SettledAt: at, Line: line}
+	}
+
+	failed := tx("txn_failed", "ref-7", "USD", 70, day.Add(time.Hour))
+	failed.Status = StatusFailed
+
+	transactions := []*TransactionHistory{
+		tx("txn_1", "ref-1", "USD", 100, day.Add(2*time.Hour)),
+		tx("txn_2", "ref-2", "USD", 50, day.Add(3*time.Hour)),
+		tx("txn_3", "ref-3", "USD", 25, day.Add(4*time.Hour)),
+		tx("txn_4", "ref-4", "USD", 40, day.AddDate(0, 0, -5)), // Outside tolerance of its record
+		tx("txn_5", "ref-5", "EUR", 10, day.Add(5*time.Hour)),  // Currency differs from the record
+		failed,
+	}
+	records := []SettlementRecord{
+		rec(1, "ref-1", "USD", 100, day),
+		rec(2, "ref-2", "usd", 55, day),
+		rec(3, "ref-4", "USD", 40, day),
+		rec(4, "ref-5", "USD", 10, day),
+		rec(5, "ref-6", "USD", 5, day),
+		rec(6, "ref-7", "USD", 70, day),
+	}
+
+	run, err := Reconcile("processor", records, transactions, ReconciliationOptions{
+		DateTolerance: 24 * time.Hour,
+		PeriodStart:   day,
+		PeriodEnd:     day.Add(24*time.Hour - time.Nanosecond),
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 6, run.ExternalCount)
+	assert.Equal(t, 5, run.InternalCount)
+	assert.Equal(t, 1, run.MatchedCount)
+	assert.Equal(t, 1, run.AmountMismatchCount)
+	assert.Equal(t, 4, run.MissingInternalCount)
+	assert.Equal(t, 2, run.MissingExternalCount)
+	assert.Len(t, run.Exceptions(), 7)
+
+	byLine := make(map[int]*ReconciliationItem)
+	for _, item := range run.Items {
+		if item.SourceLine > 0 {
+			byLine[item.SourceLine] = item
+		}
+	}
+	assert.Equal(t, ReconMatched, byLine[1].Status)
+	assert.Equal(t, "txn_1", byLine[1].TransactionID)
+	assert.Equal(t, ReconAmountMismatch, byLine[2].Status)
+	assert.Equal(t, "5", byLine[2].Difference.String())
+	assert.Equal(t, "transaction outside date tolerance", byLine[3].Reason)
+	assert.Contains(t, byLine[4].Reason, "currency differs")
+	assert.Equal(t, "no transaction with this reference", byLine[5].Reason)
+	assert.Equal(t, ReconMissingInternal, byLine[6].Status) // Failed rows never match
+
+	// txn_3 and txn_5 are in the period but absent from the file; txn_4 is before the period
+	var missing []string
+	for _, item := range run.Items {
+		if item.Status == ReconMissingExternal {
+			missing = append(missing, item.TransactionID)
+		}
+	}
+	assert.Equal(t, []string{"txn_3", "txn_5"}, missing)
+
+	t.Run("amount tolerance", func(t *testing.T) {
+		run, err := Reconcile("processor", records[1:2], transactions[1:2], ReconciliationOptions{
+			DateTolerance:   24 * time.Hour,
+			AmountTolerance: decimal.NewFromInt(5),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, run.MatchedCount)
+	})
+
+	t.Run("invalid record", func(t *testing.T) {
+		_, err := Reconcile("processor", []SettlementRecord{{ExternalReference: "x", Amount: decimal.NewFromInt(1)}}, nil, ReconciliationOptions{})
+		assert.ErrorIs(t, err, ErrInvalidSettlementRecord)
+	})
+}
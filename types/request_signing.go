@@ -0,0 +1,353 @@
+package types
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Signature algorithms accepted for money movement requests
+const (
+	SignatureHMACSHA256 = "HMAC-SHA256"
+	SignatureEd25519    = "ED25519"
+)
+
+// Request signing operations, bound into the signed payload
+const (
+	OperationDebit    = "debit"
+	OperationTransfer = "transfer"
+	OperationSwap     = "swap"
+	OperationPayout   = "payout"
+	OperationSplit    = "split"
+)
+
+// Request signing errors. All of them wrap ErrInvalidSignature.
+var (
+	ErrMissingSignature  = fmt.Errorf("%w: request is not signed", ErrInvalidSignature)
+	ErrUnknownSigningKey = fmt.Errorf("%w: unknown or inactive key", ErrInvalidSignature)
+	ErrSignatureExpired  = fmt.Errorf("%w: timestamp outside allowed window", ErrInvalidSignature)
+	ErrReplayedRequest   = fmt.Errorf("%w: nonce already used", ErrInvalidSignature)
+)
+
+// DefaultSignatureMaxSkew is how far a request timestamp may be from the verifier's clock
+const DefaultSignatureMaxSkew = 5 * time.Minute
+
+// RequestSignature authenticates a money movement request
+type RequestSignature struct {
+	KeyID     string    `json:"keyId"`     // Registered key that produced the signature
+	Algorithm string    `json:"algorithm"` // SignatureHMACSHA256 or SignatureEd25519
+	Timestamp time.Time `json:"timestamp"` // When the request was signed
+	Nonce     string    `json:"nonce"`     // Single-use random value
+	Value     string    `json:"value"`     // Base64 signature over the canonical payload
+}
+
+// SigningPayload builds the canonical bytes signed for a request: a JSON array
+// of the signature metadata followed by the operation's fields in fixed order
+func SigningPayload(operation string, sig *RequestSignature, fields ...string) []byte {
+	parts := append([]string{
+		"waas-request:v1",
+		operation,
+		sig.KeyID,
+		sig.Algorithm,
+		sig.Timestamp.UTC().Format(time.RFC3339Nano),
+		sig.Nonce,
+	}, fields...)
+
+	data, _ := json.Marshal(parts)
+	return data
+}
+
+// SigningPayload returns the canonical payload of a debit from walletID
+func (t DebitTransaction) SigningPayload(walletID string, sig *RequestSignature) []byte {
+	return SigningPayload(OperationDebit, sig,
+		walletID,
+		t.Amount.String(),
+		t.Fee.String(),
+		t.Description,
+		t.InitiatorID,
+		t.ExternalTransactionID,
+		string(t.TransactionCategory),
+	)
+}
+
+// SigningPayload returns the canonical payload of a transfer between two wallets
+func (t TransferRequest) SigningPayload(sourceWalletID, destWalletID string, sig *RequestSignature) []byte {
+	return SigningPayload(OperationTransfer, sig,
+		sourceWalletID,
+		destWalletID,
+		t.Amount.String(),
+		t.Fee.String(),
+		t.Description,
+		t.InitiatorID,
+		t.ExternalTransactionID,
+		string(t.TransactionCategory),
+	)
+}
+
+// SigningPayload returns the canonical payload of a swap between two wallets
+func (s SwapRequest) SigningPayload(sourceWalletID, destWalletID string, sig *RequestSignature) []byte {
+	return SigningPayload(OperationSwap, sig,
+		sourceWalletID,
+		destWalletID,
+		s.SourceAmount.String(),
+		s.DestinationAmount.String(),
+		s.ExchangeRate.String(),
+		s.Fee.String(),
+		s.Description,
+		s.InitiatorID,
+		s.ExternalTransactionID,
+		string(s.TransactionCategory),
+	)
+}
+
+// RequestSigner produces signatures for requests on behalf of an initiator
+type RequestSigner struct {
+	KeyID      string             // Registered key ID
+	Algorithm  string             // SignatureHMACSHA256 or SignatureEd25519
+	Secret     []byte             // HMAC secret
+	PrivateKey ed25519.PrivateKey // Ed25519 signing key
+	Now        func() time.Time   // Clock (time.Now when nil)
+}
+
+// NewHMACSigner creates a RequestSigner using HMAC-SHA256
+func NewHMACSigner(keyID string, secret []byte) *RequestSigner {
+	return &RequestSigner{KeyID: keyID, Algorithm: SignatureHMACSHA256, Secret: secret}
+}
+
+// NewEd25519Signer creates a RequestSigner using Ed25519
+func NewEd25519Signer(keyID string, privateKey ed25519.PrivateKey) *RequestSigner {
+	return &RequestSigner{KeyID: keyID, Algorithm: SignatureEd25519, PrivateKey: privateKey}
+}
+
+// Sign stamps a fresh timestamp and nonce, then signs the payload built by payloadFn
+func (s *RequestSigner) Sign(payloadFn func(sig *RequestSignature) []byte) (*RequestSignature, error) {
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	sig := &RequestSignature{
+		KeyID:     s.KeyID,
+		Algorithm: s.Algorithm,
+		Timestamp: now().UTC(),
+		Nonce:     hex.EncodeToString(nonce),
+	}
+	payload := payloadFn(sig)
+
+	switch s.Algorithm {
+	case SignatureHMACSHA256:
+		mac := hmac.New(sha256.New, s.Secret)
+		mac.Write(payload)
+		sig.Value = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	case SignatureEd25519:
+		if len(s.PrivateKey) != ed25519.PrivateKeySize {
+			return nil, ErrInvalidSigningKey
+		}
+		sig.Value = base64.StdEncoding.EncodeToString(ed25519.Sign(s.PrivateKey, payload))
+	default:
+		return nil, fmt.Errorf("unsupported signature algorithm: %s", s.Algorithm)
+	}
+
+	return sig, nil
+}
+
+// InitiatorKey is a verification key registered for an initiator
+type InitiatorKey struct {
+	ID          string            `json:"id" bun:",pk"`               // Key ID sent with signatures
+	InitiatorID string            `json:"initiatorId" bun:",notnull"` // Initiator the key belongs to
+	Algorithm   string            `json:"algorithm" bun:",notnull"`   // SignatureHMACSHA256 or SignatureEd25519
+	Secret      []byte            `json:"-" bun:",nullzero"`          // HMAC secret
+	PublicKey   ed25519.PublicKey `json:"publicKey" bun:",nullzero"`  // Ed25519 public key
+	ValidFrom   time.Time         `json:"validFrom" bun:",notnull"`   // Key is accepted from this time
+	ValidUntil  time.Time         `json:"validUntil" bun:",nullzero"` // Key stops being accepted (zero for no expiry)
+	CreatedAt   time.Time         `json:"createdAt" bun:",notnull"`   // Registration time
+}
+
+// IsActive reports whether the key accepts signatures at the given time
+func (k *InitiatorKey) IsActive(at time.Time) bool {
+	if at.Before(k.ValidFrom) {
+		return false
+	}
+	return k.ValidUntil.IsZero() || at.Before(k.ValidUntil)
+}
+
+// verify checks a signature value over the payload with this key
+func (k *InitiatorKey) verify(payload []byte, value string) bool {
+	sig, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return false
+	}
+
+	switch k.Algorithm {
+	case SignatureHMACSHA256:
+		mac := hmac.New(sha256.New, k.Secret)
+		mac.Write(payload)
+		return hmac.Equal(mac.Sum(nil), sig)
+	case SignatureEd25519:
+		return len(k.PublicKey) == ed25519.PublicKeySize && ed25519.Verify(k.PublicKey, payload, sig)
+	default:
+		return false
+	}
+}
+
+// InitiatorKeyStore looks up registered initiator keys
+type InitiatorKeyStore interface {
+	FindInitiatorKey(ctx context.Context, keyID string) (*InitiatorKey, error)
+}
+
+// NonceStore records used nonces. UseNonce must fail with ErrReplayedRequest
+// when the nonce was already used for the key.
+type NonceStore interface {
+	UseNonce(ctx context.Context, keyID, nonce string, expiresAt time.Time) error
+}
+
+// RequestVerifier checks request signatures against registered keys
+type RequestVerifier struct {
+	Keys    InitiatorKeyStore // Registered keys
+	Nonces  NonceStore        // Used nonces
+	MaxSkew time.Duration     // Allowed clock difference (DefaultSignatureMaxSkew when zero)
+	Now     func() time.Time  // Clock (time.Now when nil)
+}
+
+// NewRequestVerifier creates a RequestVerifier with the default clock skew
+func NewRequestVerifier(keys InitiatorKeyStore, nonces NonceStore) *RequestVerifier {
+	return &RequestVerifier{Keys: keys, Nonces: nonces, MaxSkew: DefaultSignatureMaxSkew}
+}
+
+// Verify checks that sig is a fresh, valid signature over payload by a key
+// belonging to initiatorID, and consumes its nonce.
+//
+// Returns:
+//   - ErrUnauthorizedAccess if the key belongs to another initiator
+//   - an error wrapping ErrInvalidSignature for any other failure
+func (v *RequestVerifier) Verify(ctx context.Context, initiatorID string, sig *RequestSignature, payload []byte) error {
+	if sig == nil || sig.Value == "" || sig.Nonce == "" {
+		return ErrMissingSignature
+	}
+
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	skew := v.MaxSkew
+	if skew <= 0 {
+		skew = DefaultSignatureMaxSkew
+	}
+	if sig.Timestamp.Before(now.Add(-skew)) || sig.Timestamp.After(now.Add(skew)) {
+		return ErrSignatureExpired
+	}
+
+	key, err := v.Keys.FindInitiatorKey(ctx, sig.KeyID)
+	if err != nil || key == nil || !key.IsActive(now) {
+		return ErrUnknownSigningKey
+	}
+	if key.Algorithm != sig.Algorithm || !key.verify(payload, sig.Value) {
+		return ErrInvalidSignature
+	}
+	if key.InitiatorID != initiatorID {
+		return ErrUnauthorizedAccess
+	}
+
+	// Only consume the nonce once the signature is known to be genuine,
+	// so forged requests cannot burn a legitimate client's nonces
+	return v.Nonces.UseNonce(ctx, sig.KeyID, sig.Nonce, sig.Timestamp.Add(skew))
+}
+
+// KeyRing is an in-memory InitiatorKeyStore with rotation support
+type KeyRing struct {
+	mu   sync.RWMutex
+	keys map[string]*InitiatorKey // Never changed in place; callers read them without the lock
+}
+
+// NewKeyRing creates an empty KeyRing
+func NewKeyRing() *KeyRing {
+	return &KeyRing{keys: make(map[string]*InitiatorKey)}
+}
+
+// Register adds or replaces a key
+func (k *KeyRing) Register(key *InitiatorKey) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[key.ID] = key
+}
+
+// Rotate registers next for its initiator and lets every other active key of
+// that initiator keep working for the overlap period, so clients can switch over
+func (k *KeyRing) Rotate(next *InitiatorKey, overlap time.Duration) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	retireAt := time.Now().Add(overlap)
+	for _, key := range k.keys {
+		if key.InitiatorID == next.InitiatorID && key.ID != next.ID &&
+			(key.ValidUntil.IsZero() || key.ValidUntil.After(retireAt)) {
+			retired := *key
+			retired.ValidUntil = retireAt
+			k.keys[key.ID] = &retired
+		}
+	}
+	k.keys[next.ID] = next
+}
+
+// FindInitiatorKey implements InitiatorKeyStore
+func (k *KeyRing) FindInitiatorKey(_ context.Context, keyID string) (*InitiatorKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownSigningKey
+	}
+	return key, nil
+}
+
+// MemoryNonceStore is an in-memory NonceStore for single-instance deployments
+type MemoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+// NewMemoryNonceStore creates an empty MemoryNonceStore
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: make(map[string]time.Time)}
+}
+
+// UseNonce implements NonceStore, dropping expired nonces as it goes
+func (m *MemoryNonceStore) UseNonce(_ context.Context, keyID, nonce string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for k, exp := range m.nonces {
+		if exp.Before(now) {
+			delete(m.nonces, k)
+		}
+	}
+
+	k := keyID + "\x00" + nonce
+	if _, used := m.nonces[k]; used {
+		return ErrReplayedRequest
+	}
+	m.nonces[k] = expiresAt
+	return nil
+}
+
+// RequestNonce is a used nonce persisted for replay protection
+type RequestNonce struct {
+	KeyID     string    `json:"keyId" bun:",pk"`          // Key the nonce was used with
+	Nonce     string    `json:"nonce" bun:",pk"`          // The nonce
+	ExpiresAt time.Time `json:"expiresAt" bun:",notnull"` // After this the timestamp check rejects it anyway
+}
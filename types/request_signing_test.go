@@ -0,0 +1,148 @@
+package types
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signedDebit(t *testing.T, signer *RequestSigner, walletID string) DebitTransaction {
+	t.Helper()
+	debit := DebitTransaction{
+		Amount:                decimal.NewFromInt(250),
+		Fee:                   decimal.NewFromInt(1),
+		Description:           "payout",
+		InitiatorID:           "usr_1",
+		ExternalTransactionID: "ext_1",
+		TransactionCategory:   CategoryTransfer,
+	}
+	sig, err := signer.Sign(func(sig *RequestSignature) []byte { return debit.SigningPayload(walletID, sig) })
+	require.NoError(t, err)
+	debit.Signature = sig
+	return debit
+}
+
+func TestRequestVerifier(t *testing.T) {
+	ctx := context.Background()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	keys := NewKeyRing()
+	keys.Register(&InitiatorKey{ID: "key_hmac", InitiatorID: "usr_1", Algorithm: SignatureHMACSHA256, Secret: []byte("s3cret")})
+	keys.Register(&InitiatorKey{ID: "key_ed", InitiatorID: "usr_1", Algorithm: SignatureEd25519, PublicKey: pub})
+	keys.Register(&InitiatorKey{ID: "key_other", InitiatorID: "usr_2", Algorithm: SignatureHMACSHA256, Secret: []byte("other")})
+
+	verify := func(v *RequestVerifier, initiatorID string, debit DebitTransaction) error {
+		return v.Verify(ctx, initiatorID, debit.Signature, debit.SigningPayload("wal_1", debit.Signature))
+	}
+
+	t.Run("hmac and ed25519 signatures verify", func(t *testing.T) {
+		v := NewRequestVerifier(keys, NewMemoryNonceStore())
+		assert.NoError(t, verify(v, "usr_1", signedDebit(t, NewHMACSigner("key_hmac", []byte("s3cret")), "wal_1")))
+		assert.NoError(t, verify(v, "usr_1", signedDebit(t, NewEd25519Signer("key_ed", priv), "wal_1")))
+	})
+
+	t.Run("tampered request is rejected", func(t *testing.T) {
+		v := NewRequestVerifier(keys, NewMemoryNonceStore())
+		debit := signedDebit(t, NewHMACSigner("key_hmac", []byte("s3cret")), "wal_1")
+		debit.Amount = decimal.NewFromInt(2500)
+		assert.ErrorIs(t, verify(v, "usr_1", debit), ErrInvalidSignature)
+
+		other := signedDebit(t, NewEd25519Signer("key_ed", priv), "wal_2")
+		assert.ErrorIs(t, verify(v, "usr_1", other), ErrInvalidSignature)
+	})
+
+	t.Run("missing signature", func(t *testing.T) {
+		v := NewRequestVerifier(keys, NewMemoryNonceStore())
+		err := v.Verify(ctx, "usr_1", nil, nil)
+		assert.ErrorIs(t, err, ErrMissingSignature)
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("stale timestamp", func(t *testing.T) {
+		v := NewRequestVerifier(keys, NewMemoryNonceStore())
+		signer := NewHMACSigner("key_hmac", []byte("s3cret"))
+		signer.Now = func() time.Time { return time.Now().Add(-10 * time.Minute) }
+		assert.ErrorIs(t, verify(v, "usr_1", signedDebit(t, signer, "wal_1")), ErrSignatureExpired)
+	})
+
+	t.Run("replayed nonce", func(t *testing.T) {
+		v := NewRequestVerifier(keys, NewMemoryNonceStore())
+		debit := signedDebit(t, NewHMACSigner("key_hmac", []byte("s3cret")), "wal_1")
+		require.NoError(t, verify(v, "usr_1", debit))
+		assert.ErrorIs(t, verify(v, "usr_1", debit), ErrReplayedRequest)
+	})
+
+	t.Run("key of another initiator", func(t *testing.T) {
+		v := NewRequestVerifier(keys, NewMemoryNonceStore())
+		debit := signedDebit(t, NewHMACSigner("key_other", []byte("other")), "wal_1")
+		assert.ErrorIs(t, verify(v, "usr_1", debit), ErrUnauthorizedAccess)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		v := NewRequestVerifier(keys, NewMemoryNonceStore())
+		debit := signedDebit(t, NewHMACSigner("key_missing", []byte("s3cret")), "wal_1")
+		assert.ErrorIs(t, verify(v, "usr_1", debit), ErrUnknownSigningKey)
+	})
+}
+
+func TestKeyRingRotate(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	keys := NewKeyRing()
+	keys.Register(&InitiatorKey{ID: "key_old", InitiatorID: "usr_1", Algorithm: SignatureHMACSHA256, Secret: []byte("old")})
+	keys.Rotate(&InitiatorKey{ID: "key_new", InitiatorID: "usr_1", Algorithm: SignatureHMACSHA256, Secret: []byte("new")}, time.Hour)
+
+	old, err := keys.FindInitiatorKey(ctx, "key_old")
+	require.NoError(t, err)
+	assert.True(t, old.IsActive(now), "old key works during the overlap")
+	assert.False(t, old.IsActive(now.Add(2*time.Hour)), "old key retires after the overlap")
+
+	v := NewRequestVerifier(keys, NewMemoryNonceStore())
+	for _, signer := range []*RequestSigner{NewHMACSigner("key_old", []byte("old")), NewHMACSigner("key_new", []byte("new"))} {
+		debit := signedDebit(t, signer, "wal_1")
+		assert.NoError(t, v.Verify(ctx, "usr_1", debit.Signature, debit.SigningPayload("wal_1", debit.Signature)))
+	}
+
+	v.Now = func() time.Time { return now.Add(2 * time.Hour) }
+	signer := NewHMACSigner("key_old", []byte("old"))
+	signer.Now = v.Now
+	debit := signedDebit(t, signer, "wal_1")
+	assert.ErrorIs(t, v.Verify(ctx, "usr_1", debit.Signature, debit.SigningPayload("wal_1", debit.Signature)), ErrUnknownSigningKey)
+}
+
+func TestKeyRingRotateWhileVerifying(t *testing.T) {
+	ctx := context.Background()
+	keys := NewKeyRing()
+	keys.Register(&InitiatorKey{ID: "key_old", InitiatorID: "usr_1", Algorithm: SignatureHMACSHA256, Secret: []byte("old")})
+	v := NewRequestVerifier(keys, NewMemoryNonceStore())
+	signer := NewHMACSigner("key_old", []byte("old"))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			keys.Rotate(&InitiatorKey{ID: fmt.Sprintf("key_%d", i), InitiatorID: "usr_1", Algorithm: SignatureHMACSHA256, Secret: []byte("new")}, time.Hour)
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		debit := signedDebit(t, signer, "wal_1")
+		assert.NoError(t, v.Verify(ctx, "usr_1", debit.Signature, debit.SigningPayload("wal_1", debit.Signature)))
+	}
+	wg.Wait()
+
+	// The old key was retired by replacing it
+	old, err := keys.FindInitiatorKey(ctx, "key_old")
+	require.NoError(t, err)
+	assert.False(t, old.ValidUntil.IsZero())
+}
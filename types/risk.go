@@ -0,0 +1,469 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Risk screening errors
+var (
+	ErrTransactionBlocked = errors.New("transaction blocked by risk screening")
+	ErrInvalidRiskConfig  = errors.New("invalid risk configuration")
+	ErrRiskReviewNotFound = errors.New("risk review not found")
+)
+
+// OperationCredit identifies credits in risk screening (see OperationDebit)
+const OperationCredit = "credit"
+
+// RiskOutcome is the verdict of risk screening
+type RiskOutcome string
+
+const (
+	RiskAllow  RiskOutcome = "ALLOW"  // Proceed normally
+	RiskReview RiskOutcome = "REVIEW" // Proceed but hold the rows as pending, and credits in a lien, for review
+	RiskBlock  RiskOutcome = "BLOCK"  // Do not proceed
+)
+
+// severity orders outcomes so the strictest wins
+func (o RiskOutcome) severity() int {
+	switch o {
+	case RiskBlock:
+		return 2
+	case RiskReview:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// RiskInput describes the balance movement being screened for one wallet
+type RiskInput struct {
+	Operation            string              // OperationCredit, OperationDebit, OperationTransfer, OperationSwap or OperationSplit
+	Wallet               *Wallet             // Wallet being credited or debited
+	Type                 TransactionType     // Direction for this wallet
+	Amount               decimal.Decimal     // Amount moved
+	Category             TransactionCategory // Transaction category
+	CounterpartyWalletID string              // Other wallet of a transfer or swap, or the source of a split
+}
+
+// RiskHit is a rule that fired
+type RiskHit struct {
+	Rule    string      `json:"rule"`    // Rule name
+	Outcome RiskOutcome `json:"outcome"` // Outcome the rule asks for
+	Reason  string      `json:"reason"`  // Why it fired
+}
+
+// RiskRule is a single check against a movement and the wallet's recent history.
+// Evaluate returns nil when the rule does not fire.
+type RiskRule interface {
+	Name() string
+	Evaluate(in RiskInput, recent []*TransactionHistory, now time.Time) *RiskHit
+}
+
+// RiskReviewStatus tracks the review of a held movement
+type RiskReviewStatus string
+
+const (
+	RiskReviewNone     RiskReviewStatus = ""         // Nothing to review
+	RiskReviewOpen     RiskReviewStatus = "OPEN"     // Waiting for a reviewer
+	RiskReviewReleased RiskReviewStatus = "RELEASED" // Reviewer let the movement complete
+	RiskReviewRejected RiskReviewStatus = "REJECTED" // Reviewer reversed the movement
+)
+
+// RiskDecision records the screening of one wallet's side of a movement
+type RiskDecision struct {
+	ID             string           `json:"id" bun:",pk"`                            // Unique decision ID
+	MovementID     string           `json:"movementId" bun:",notnull"`               // Shared by the decisions of one movement
+	WalletID       string           `json:"walletId" bun:",notnull"`                 // Screened wallet
+	Operation      string           `json:"operation" bun:",notnull"`                // Screened operation
+	Type           TransactionType  `json:"type" bun:",notnull"`                     // Direction for the wallet
+	Amount         decimal.Decimal  `json:"amount" bun:"type:decimal(24,8),notnull"` // Amount moved
+	CurrencyCode   string           `json:"currencyCode" bun:",notnull"`             // Wallet currency
+	Outcome        RiskOutcome      `json:"outcome" bun:",notnull"`                  // Strictest outcome of the hits
+	Rule           string           `json:"rule" bun:",nullzero"`                    // Rule that decided the outcome
+	Hits           []RiskHit        `json:"hits" bun:",nullzero"`                    // Every rule that fired
+	TransactionIDs []string         `json:"transactionIds" bun:",nullzero"`          // Rows written by the movement
+	ReviewStatus   RiskReviewStatus `json:"reviewStatus" bun:",nullzero"`            // Review state of held movements
+	ReviewerID     string           `json:"reviewerId" bun:",nullzero"`              // Who reviewed it
+	ReviewedAt     time.Time        `json:"reviewedAt" bun:",nullzero"`              // When it was reviewed
+	CreatedAt      time.Time        `json:"createdAt" bun:",notnull"`                // Screening time
+}
+
+// Err returns the error for a blocked decision, nil otherwise
+func (d *RiskDecision) Err() error {
+	if d.Outcome != RiskBlock {
+		return nil
+	}
+	return fmt.Errorf("%w: rule %s", ErrTransactionBlocked, d.Rule)
+}
+
+// WorstRiskOutcome returns the strictest outcome among decisions and the
+// decision that carries it
+func WorstRiskOutcome(decisions []*RiskDecision) (RiskOutcome, *RiskDecision) {
+	outcome, worst := RiskAllow, (*RiskDecision)(nil)
+	for _, d := range decisions {
+		if d != nil && (worst == nil || d.Outcome.severity() > outcome.severity()) {
+			outcome, worst = d.Outcome, d
+		}
+	}
+	return outcome, worst
+}
+
+// ReviewHoldDescription describes the liens that keep held credits out of
+// the available balance
+const ReviewHoldDescription = "Held for risk review"
+
+// HoldForReview moves a credit held for review from the available balance
+// into the lien balance, so it cannot be spent before the review is resolved.
+// The lien refers to the row through its ExternalTransactionID.
+func (w *Wallet) HoldForReview(row *TransactionHistory) *LienRecord {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	amount := BalanceEffect(row)
+	w.AvailableBalance = w.AvailableBalance.Sub(amount)
+	w.LienBalance = w.LienBalance.Add(amount)
+	now := time.Now()
+	w.UpdatedAt = now
+
+	return &LienRecord{
+		ID:                    GenerateID("lien_", 15),
+		WalletID:              w.ID,
+		Amount:                amount,
+		Description:           ReviewHoldDescription,
+		ExternalTransactionID: row.ID,
+		CreatedAt:             now,
+	}
+}
+
+// NewReversalTransaction builds a completed row undoing the balance effect of
+// a rejected held row, starting from the wallet's current available balance
+func NewReversalTransaction(wallet *Wallet, held *TransactionHistory, initiatorID string) *TransactionHistory {
+	effect := BalanceEffect(held).Neg()
+	txType := TypeCredit
+	if effect.IsNegative() {
+		txType = TypeDebit
+	}
+
+	now := time.Now().UTC()
+	return &TransactionHistory{
+		ID:                NewTransactionID(),
+		WalletID:          held.WalletID,
+		CurrencyCode:      held.CurrencyCode,
+		InitiatorID:       initiatorID,
+		ExternalReference: held.ID,
+		Category:          CategoryReversal,
+		Description:       "Reversal after risk review",
+		Amount:            effect.Abs(),
+		Fee:               decimal.Zero,
+		Type:              txType,
+		BalanceBefore:     wallet.AvailableBalance,
+		BalanceAfter:      wallet.AvailableBalance.Add(effect),
+		CreatedAt:         now,
+		UpdatedAt:         now,
+		Status:            StatusCompleted,
+		GroupID:           held.GroupID,
+	}
+}
+
+// RiskEngine evaluates every rule against a movement
+type RiskEngine struct {
+	Lookback time.Duration // How much history rules see
+	Rules    []RiskRule    // Rules in evaluation order
+}
+
+// NewRiskEngine creates an engine with the given rules and a 30 day lookback
+func NewRiskEngine(rules ...RiskRule) *RiskEngine {
+	return &RiskEngine{Lookback: 30 * 24 * time.Hour, Rules: rules}
+}
+
+// Evaluate runs every rule and returns a decision whose outcome is the
+// strictest any rule asked for. The first rule with that outcome is recorded
+// as the deciding rule.
+func (e *RiskEngine) Evaluate(in RiskInput, recent []*TransactionHistory, now time.Time) *RiskDecision {
+	decision := &RiskDecision{
+		ID:           GenerateID("rsk_", 15),
+		WalletID:     in.Wallet.ID,
+		Operation:    in.Operation,
+		Type:         in.Type,
+		Amount:       in.Amount,
+		CurrencyCode: in.Wallet.CurrencyCode,
+		Outcome:      RiskAllow,
+		CreatedAt:    now.UTC(),
+	}
+
+	for _, rule := range e.Rules {
+		hit := rule.Evaluate(in, recent, now)
+		if hit == nil {
+			continue
+		}
+		hit.Rule = rule.Name()
+		decision.Hits = append(decision.Hits, *hit)
+		if hit.Outcome.severity() > decision.Outcome.severity() {
+			decision.Outcome = hit.Outcome
+			decision.Rule = hit.Rule
+		}
+	}
+	if decision.Outcome == RiskReview {
+		decision.ReviewStatus = RiskReviewOpen
+	}
+
+	return decision
+}
+
+// recentSince returns the non-failed rows created at or after since
+func recentSince(recent []*TransactionHistory, since time.Time) []*TransactionHistory {
+	var rows []*TransactionHistory
+	for _, tx := range recent {
+		if tx != nil && tx.Status != StatusFailed && !tx.CreatedAt.Before(since) {
+			rows = append(rows, tx)
+		}
+	}
+	return rows
+}
+
+// VelocityRule fires when the wallet has made too many movements in a window
+type VelocityRule struct {
+	RuleName string          // Rule name
+	Window   time.Duration   // Period counted
+	MaxCount int             // Movements allowed in the window, including this one
+	Type     TransactionType // Only count this direction (empty for both)
+	Outcome  RiskOutcome     // Outcome when fired
+}
+
+func (r *VelocityRule) Name() string { return r.RuleName }
+
+func (r *VelocityRule) Evaluate(in RiskInput, recent []*TransactionHistory, now time.Time) *RiskHit {
+	if r.Type != "" && in.Type != r.Type {
+		return nil
+	}
+
+	count := 1
+	for _, tx := range recentSince(recent, now.Add(-r.Window)) {
+		if r.Type == "" || tx.Type == r.Type {
+			count++
+		}
+	}
+	if count <= r.MaxCount {
+		return nil
+	}
+
+	return &RiskHit{Outcome: r.Outcome, Reason: fmt.Sprintf("%d movements in %s exceeds %d", count, r.Window, r.MaxCount)}
+}
+
+// NewWalletLargeDebitRule fires on large debits from recently opened wallets
+type NewWalletLargeDebitRule struct {
+	RuleName     string          // Rule name
+	MaxWalletAge time.Duration   // Wallets younger than this are new
+	Threshold    decimal.Decimal // Debits of at least this amount are large
+	Outcome      RiskOutcome     // Outcome when fired
+}
+
+func (r *NewWalletLargeDebitRule) Name() string { return r.RuleName }
+
+func (r *NewWalletLargeDebitRule) Evaluate(in RiskInput, _ []*TransactionHistory, now time.Time) *RiskHit {
+	if in.Type != TypeDebit || in.Amount.LessThan(r.Threshold) {
+		return nil
+	}
+	age := now.Sub(in.Wallet.CreatedAt)
+	if age >= r.MaxWalletAge {
+		return nil
+	}
+
+	return &RiskHit{Outcome: r.Outcome, Reason: fmt.Sprintf("debit of %s from a wallet opened %s ago", in.Amount, age.Round(time.Minute))}
+}
+
+// RapidInOutRule fires when funds credited within a window are debited straight
+// back out, a common pattern when wallets are used to pass money through
+type RapidInOutRule struct {
+	RuleName  string          // Rule name
+	Window    time.Duration   // Period examined
+	Ratio     decimal.Decimal // Fraction of recent credits that must leave to fire (e.g. 0.9)
+	MinAmount decimal.Decimal // Ignore windows with less credited than this
+	Outcome   RiskOutcome     // Outcome when fired
+}
+
+func (r *RapidInOutRule) Name() string { return r.RuleName }
+
+func (r *RapidInOutRule) Evaluate(in RiskInput, recent []*TransactionHistory, now time.Time) *RiskHit {
+	if in.Type != TypeDebit {
+		return nil
+	}
+
+	credited, debited := decimal.Zero, in.Amount
+	for _, tx := range recentSince(recent, now.Add(-r.Window)) {
+		if tx.Type == TypeCredit {
+			credited = credited.Add(tx.Amount)
+		} else {
+			debited = debited.Add(tx.Amount)
+		}
+	}
+	if credited.IsZero() || credited.LessThan(r.MinAmount) || debited.LessThan(credited.Mul(r.Ratio)) {
+		return nil
+	}
+
+	return &RiskHit{Outcome: r.Outcome, Reason: fmt.Sprintf("%s of %s credited in %s is leaving again", debited, credited, r.Window)}
+}
+
+// StructuringRule fires on repeated round amounts or amounts just under a
+// reporting threshold, which suggests splitting to avoid scrutiny
+type StructuringRule struct {
+	RuleName  string          // Rule name
+	Window    time.Duration   // Period examined
+	RoundUnit decimal.Decimal // Amounts that are multiples of this are round (zero to ignore)
+	Threshold decimal.Decimal // Reporting threshold (zero to ignore)
+	Margin    decimal.Decimal // Amounts within this fraction below Threshold count (e.g. 0.1)
+	MinCount  int             // Suspicious movements in the window, including this one, to fire
+	Outcome   RiskOutcome     // Outcome when fired
+}
+
+func (r *StructuringRule) Name() string { return r.RuleName }
+
+func (r *StructuringRule) suspicious(amount decimal.Decimal) bool {
+	if !r.RoundUnit.IsZero() && amount.Mod(r.RoundUnit).IsZero() {
+		return true
+	}
+	if !r.Threshold.IsZero() {
+		floor := r.Threshold.Sub(r.Threshold.Mul(r.Margin))
+		return amount.LessThan(r.Threshold) && amount.GreaterThanOrEqual(floor)
+	}
+	return false
+}
+
+func (r *StructuringRule) Evaluate(in RiskInput, recent []*TransactionHistory, now time.Time) *RiskHit {
+	if !r.suspicious(in.Amount) {
+		return nil
+	}
+
+	count := 1
+	for _, tx := range recentSince(recent, now.Add(-r.Window)) {
+		if tx.Type == in.Type && r.suspicious(tx.Amount) {
+			count++
+		}
+	}
+	if count < r.MinCount {
+		return nil
+	}
+
+	return &RiskHit{Outcome: r.Outcome, Reason: fmt.Sprintf("%d round or near-threshold movements in %s", count, r.Window)}
+}
+
+// RiskConfig is the file format for configuring a RiskEngine
+type RiskConfig struct {
+	Lookback string           `json:"lookback"` // Go duration of history loaded for rules
+	Rules    []RiskRuleConfig `json:"rules"`    // Rules in evaluation order
+}
+
+// RiskRuleConfig configures one built-in rule. Fields not used by the rule's
+// type are ignored.
+type RiskRuleConfig struct {
+	Type         string          `json:"type"`         // velocity, new_wallet_large_debit, rapid_in_out or structuring
+	Name         string          `json:"name"`         // Rule name recorded on decisions
+	Outcome      RiskOutcome     `json:"outcome"`      // REVIEW or BLOCK
+	Window       string          `json:"window"`       // Go duration
+	MaxCount     int             `json:"maxCount"`     // velocity
+	Direction    TransactionType `json:"direction"`    // velocity: CREDIT, DEBIT or empty
+	MaxWalletAge string          `json:"maxWalletAge"` // new_wallet_large_debit: Go duration
+	Threshold    decimal.Decimal `json:"threshold"`    // new_wallet_large_debit, structuring
+	Ratio        decimal.Decimal `json:"ratio"`        // rapid_in_out
+	MinAmount    decimal.Decimal `json:"minAmount"`    // rapid_in_out
+	RoundUnit    decimal.Decimal `json:"roundUnit"`    // structuring
+	Margin       decimal.Decimal `json:"margin"`       // structuring
+	MinCount     int             `json:"minCount"`     // structuring
+}
+
+// LoadRiskEngine reads a JSON RiskConfig file and builds the engine
+func LoadRiskEngine(path string) (*RiskEngine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read risk config: %w", err)
+	}
+
+	var cfg RiskConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRiskConfig, err)
+	}
+	return cfg.Engine()
+}
+
+// Engine builds the RiskEngine described by the config
+func (c *RiskConfig) Engine() (*RiskEngine, error) {
+	engine := NewRiskEngine()
+	if c.Lookback != "" {
+		lookback, err := time.ParseDuration(c.Lookback)
+		if err != nil {
+			return nil, fmt.Errorf("%w: lookback: %v", ErrInvalidRiskConfig, err)
+		}
+		engine.Lookback = lookback
+	}
+
+	for i, rc := range c.Rules {
+		rule, err := rc.rule()
+		if err != nil {
+			return nil, fmt.Errorf("%w: rule %d (%s): %v", ErrInvalidRiskConfig, i, rc.Name, err)
+		}
+		engine.Rules = append(engine.Rules, rule)
+	}
+
+	return engine, nil
+}
+
+func (rc RiskRuleConfig) rule() (RiskRule, error) {
+	if rc.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if rc.Outcome != RiskReview && rc.Outcome != RiskBlock {
+		return nil, fmt.Errorf("outcome must be %s or %s", RiskReview, RiskBlock)
+	}
+
+	window, err := parseOptionalDuration(rc.Window)
+	if err != nil {
+		return nil, fmt.Errorf("window: %v", err)
+	}
+
+	switch strings.ToLower(rc.Type) {
+	case "velocity":
+		if window <= 0 || rc.MaxCount <= 0 {
+			return nil, errors.New("velocity needs window and maxCount")
+		}
+		return &VelocityRule{RuleName: rc.Name, Window: window, MaxCount: rc.MaxCount, Type: rc.Direction, Outcome: rc.Outcome}, nil
+
+	case "new_wallet_large_debit":
+		age, err := parseOptionalDuration(rc.MaxWalletAge)
+		if err != nil || age <= 0 || !rc.Threshold.IsPositive() {
+			return nil, errors.New("new_wallet_large_debit needs maxWalletAge and threshold")
+		}
+		return &NewWalletLargeDebitRule{RuleName: rc.Name, MaxWalletAge: age, Threshold: rc.Threshold, Outcome: rc.Outcome}, nil
+
+	case "rapid_in_out":
+		if window <= 0 || !rc.Ratio.IsPositive() {
+			return nil, errors.New("rapid_in_out needs window and ratio")
+		}
+		return &RapidInOutRule{RuleName: rc.Name, Window: window, Ratio: rc.Ratio, MinAmount: rc.MinAmount, Outcome: rc.Outcome}, nil
+
+	case "structuring":
+		if window <= 0 || rc.MinCount <= 0 || (rc.RoundUnit.IsZero() && rc.Threshold.IsZero()) {
+			return nil, errors.New("structuring needs window, minCount and roundUnit or threshold")
+		}
+		return &StructuringRule{
+			RuleName: rc.Name, Window: window, RoundUnit: rc.RoundUnit, Threshold: rc.Threshold,
+			Margin: rc.Margin, MinCount: rc.MinCount, Outcome: rc.Outcome,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown rule type %q", rc.Type)
+	}
+}
+
+func parseOptionalDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
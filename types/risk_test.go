@@ -0,0 +1,154 @@
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func riskRow(txType TransactionType, amount int64, at time.Time) *TransactionHistory {
+	return &TransactionHistory{
+		ID:        GenerateID("txn_", 8),
+		Type:      txType,
+		Amount:    decimal.NewFromInt(amount),
+		CreatedAt: at,
+		Status:    StatusCompleted,
+	}
+}
+
+func TestRiskRules(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	oldWallet := &Wallet{ID: "wt_old", CurrencyCode: "USD", CreatedAt: now.AddDate(-1, 0, 0)}
+	newWallet := &Wallet{ID: "wt_new", CurrencyCode: "USD", CreatedAt: now.Add(-2 * time.Hour)}
+	debit := func(w *Wallet, amount int64) RiskInput {
+		return RiskInput{Operation: OperationDebit, Wallet: w, Type: TypeDebit, Amount: decimal.NewFromInt(amount)}
+	}
+
+	tests := []struct {
+		name   string
+		rule   RiskRule
+		in     RiskInput
+		recent []*TransactionHistory
+		fires  bool
+	}{
+		{
+			name:   "velocity under limit",
+			rule:   &VelocityRule{RuleName: "v", Window: time.Hour, MaxCount: 3, Type: TypeDebit, Outcome: RiskBlock},
+			in:     debit(oldWallet, 10),
+			recent: []*TransactionHistory{riskRow(TypeDebit, 10, now.Add(-time.Minute)), riskRow(TypeDebit, 10, now.Add(-2*time.Hour))},
+		},
+		{
+			name: "velocity over limit",
+			rule: &VelocityRule{RuleName: "v", Window: time.Hour, MaxCount: 2, Type: TypeDebit, Outcome: RiskBlock},
+			in:   debit(oldWallet, 10),
+			recent: []*TransactionHistory{
+				riskRow(TypeDebit, 10, now.Add(-time.Minute)),
+				riskRow(TypeDebit, 10, now.Add(-2*time.Minute)),
+				riskRow(TypeCredit, 10, now.Add(-3*time.Minute)),
+			},
+			fires: true,
+		},
+		{
+			name:  "large debit from new wallet",
+			rule:  &NewWalletLargeDebitRule{RuleName: "n", MaxWalletAge: 72 * time.Hour, Threshold: decimal.NewFromInt(500), Outcome: RiskReview},
+			in:    debit(newWallet, 500),
+			fires: true,
+		},
+		{
+			name: "large debit from established wallet",
+			rule: &NewWalletLargeDebitRule{RuleName: "n", MaxWalletAge: 72 * time.Hour, Threshold: decimal.NewFromInt(500), Outcome: RiskReview},
+			in:   debit(oldWallet, 500),
+		},
+		{
+			name:   "funds passed straight through",
+			rule:   &RapidInOutRule{RuleName: "r", Window: time.Hour, Ratio: decimal.RequireFromString("0.9"), MinAmount: decimal.NewFromInt(100), Outcome: RiskReview},
+			in:     debit(oldWallet, 950),
+			recent: []*TransactionHistory{riskRow(TypeCredit, 1000, now.Add(-10*time.Minute))},
+			fires:  true,
+		},
+		{
+			name:   "part of recent credit spent",
+			rule:   &RapidInOutRule{RuleName: "r", Window: time.Hour, Ratio: decimal.RequireFromString("0.9"), MinAmount: decimal.NewFromInt(100), Outcome: RiskReview},
+			in:     debit(oldWallet, 200),
+			recent: []*TransactionHistory{riskRow(TypeCredit, 1000, now.Add(-10*time.Minute))},
+		},
+		{
+			name: "repeated amounts just under threshold",
+			rule: &StructuringRule{RuleName: "s", Window: 24 * time.Hour, Threshold: decimal.NewFromInt(10000),
+				Margin: decimal.RequireFromString("0.1"), MinCount: 3, Outcome: RiskReview},
+			in:     debit(oldWallet, 9500),
+			recent: []*TransactionHistory{riskRow(TypeDebit, 9800, now.Add(-time.Hour)), riskRow(TypeDebit, 9100, now.Add(-2*time.Hour))},
+			fires:  true,
+		},
+		{
+			name:   "round amounts below count",
+			rule:   &StructuringRule{RuleName: "s", Window: 24 * time.Hour, RoundUnit: decimal.NewFromInt(1000), MinCount: 3, Outcome: RiskReview},
+			in:     debit(oldWallet, 3000),
+			recent: []*TransactionHistory{riskRow(TypeDebit, 1234, now.Add(-time.Hour)), riskRow(TypeDebit, 2000, now.Add(-time.Hour))},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hit := tt.rule.Evaluate(tt.in, tt.recent, now)
+			assert.Equal(t, tt.fires, hit != nil)
+		})
+	}
+}
+
+func TestRiskEngineStrictestOutcomeWins(t *testing.T) {
+	now := time.Now()
+	wallet := &Wallet{ID: "wt_1", CurrencyCode: "USD", CreatedAt: now}
+	engine := NewRiskEngine(
+		&NewWalletLargeDebitRule{RuleName: "new-wallet", MaxWalletAge: time.Hour, Threshold: decimal.NewFromInt(100), Outcome: RiskReview},
+		&VelocityRule{RuleName: "velocity", Window: time.Hour, MaxCount: 1, Outcome: RiskBlock},
+	)
+
+	decision := engine.Evaluate(RiskInput{Operation: OperationDebit, Wallet: wallet, Type: TypeDebit, Amount: decimal.NewFromInt(100)},
+		[]*TransactionHistory{riskRow(TypeDebit, 5, now.Add(-time.Minute))}, now)
+
+	assert.Equal(t, RiskBlock, decision.Outcome)
+	assert.Equal(t, "velocity", decision.Rule)
+	assert.Len(t, decision.Hits, 2)
+	assert.ErrorIs(t, decision.Err(), ErrTransactionBlocked)
+
+	allowed := engine.Evaluate(RiskInput{Operation: OperationCredit, Wallet: wallet, Type: TypeCredit, Amount: decimal.NewFromInt(5)}, nil, now)
+	assert.Equal(t, RiskAllow, allowed.Outcome)
+	assert.NoError(t, allowed.Err())
+
+	outcome, worst := WorstRiskOutcome([]*RiskDecision{allowed, decision})
+	assert.Equal(t, RiskBlock, outcome)
+	assert.Same(t, decision, worst)
+}
+
+func TestLoadRiskEngine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "risk.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"lookback": "168h",
+		"rules": [
+			{"type": "velocity", "name": "debits-per-hour", "outcome": "BLOCK", "window": "1h", "maxCount": 10, "direction": "DEBIT"},
+			{"type": "new_wallet_large_debit", "name": "new-wallet", "outcome": "REVIEW", "maxWalletAge": "72h", "threshold": "1000"},
+			{"type": "rapid_in_out", "name": "pass-through", "outcome": "REVIEW", "window": "2h", "ratio": "0.9"},
+			{"type": "structuring", "name": "structuring", "outcome": "REVIEW", "window": "24h", "threshold": "10000", "margin": "0.1", "minCount": 3}
+		]
+	}`), 0o600))
+
+	engine, err := LoadRiskEngine(path)
+	require.NoError(t, err)
+	assert.Equal(t, 168*time.Hour, engine.Lookback)
+	require.Len(t, engine.Rules, 4)
+	assert.Equal(t, "pass-through", engine.Rules[2].Name())
+
+	bad := RiskConfig{Rules: []RiskRuleConfig{{Type: "velocity", Name: "v", Outcome: RiskBlock}}}
+	_, err = bad.Engine()
+	assert.ErrorIs(t, err, ErrInvalidRiskConfig)
+
+	bad = RiskConfig{Rules: []RiskRuleConfig{{Type: "unknown", Name: "u", Outcome: RiskBlock}}}
+	_, err = bad.Engine()
+	assert.ErrorIs(t, err, ErrInvalidRiskConfig)
+}
@@ -0,0 +1,119 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Sanctions screening errors
+var (
+	ErrScreeningHit          = errors.New("customer matches a sanctions or watch list")
+	ErrScreeningCaseNotFound = errors.New("screening case not found")
+)
+
+// FreezeInitiatedByScreening is the FreezeInitiatedBy of wallets frozen by a screening hit
+const FreezeInitiatedByScreening = "screening"
+
+// ScreeningTrigger is the event that caused a customer to be screened
+type ScreeningTrigger string
+
+const (
+	ScreeningOnWalletCreation ScreeningTrigger = "WALLET_CREATION"
+	ScreeningOnTransfer       ScreeningTrigger = "TRANSFER"
+)
+
+// ScreeningCaseStatus tracks the compliance review of a screening hit
+type ScreeningCaseStatus string
+
+const (
+	ScreeningCaseOpen      ScreeningCaseStatus = "OPEN"      // Waiting for compliance
+	ScreeningCaseCleared   ScreeningCaseStatus = "CLEARED"   // False positive, wallet unfrozen
+	ScreeningCaseConfirmed ScreeningCaseStatus = "CONFIRMED" // True match, wallet stays frozen
+)
+
+// ScreeningSubject is the identity a customer is screened under
+type ScreeningSubject struct {
+	CustomerID string `json:"customerId"` // Customer
+	Name       string `json:"name"`       // Full legal name
+}
+
+// ScreeningMatch is a list entry a name matched
+type ScreeningMatch struct {
+	ListName    string  `json:"listName"`    // List the entry is on (e.g. OFAC-SDN)
+	EntryID     string  `json:"entryId"`     // Entry identifier within the list
+	EntryName   string  `json:"entryName"`   // Primary name of the entry
+	MatchedName string  `json:"matchedName"` // Entry name or alias that matched
+	Score       float64 `json:"score"`       // Similarity between 0 and 1
+}
+
+// Key identifies the list entry regardless of which of its names matched
+func (m ScreeningMatch) Key() string {
+	return m.ListName + ":" + m.EntryID
+}
+
+// NameScreener finds list entries similar to a name
+type NameScreener interface {
+	ScreenName(name string) []ScreeningMatch
+}
+
+// CustomerDirectory resolves the identity a customer is screened under.
+// It returns a nil subject when the customer is unknown.
+type CustomerDirectory interface {
+	FindScreeningSubject(ctx context.Context, customerID string) (*ScreeningSubject, error)
+}
+
+// ScreeningCase is a screening hit waiting for, or closed by, compliance review
+type ScreeningCase struct {
+	ID          string              `json:"id" bun:",pk"`               // Unique case ID
+	CustomerID  string              `json:"customerId" bun:",notnull"`  // Screened customer
+	WalletID    string              `json:"walletId" bun:",notnull"`    // Wallet frozen because of the hit
+	SubjectName string              `json:"subjectName" bun:",notnull"` // Name that was screened
+	Trigger     ScreeningTrigger    `json:"trigger" bun:",notnull"`     // What caused the screening
+	Matches     []ScreeningMatch    `json:"matches" bun:",notnull"`     // Entries matched
+	Status      ScreeningCaseStatus `json:"status" bun:",notnull"`      // Review state
+	ReviewerID  string              `json:"reviewerId" bun:",nullzero"` // Compliance officer who closed it
+	ReviewNote  string              `json:"reviewNote" bun:",nullzero"` // Reviewer's reasoning
+	CreatedAt   time.Time           `json:"createdAt" bun:",notnull"`   // When the hit occurred
+	ReviewedAt  time.Time           `json:"reviewedAt" bun:",nullzero"` // When it was closed
+}
+
+// NewScreeningCase opens a case for a screening hit
+func NewScreeningCase(subject *ScreeningSubject, walletID string, trigger ScreeningTrigger, matches []ScreeningMatch) *ScreeningCase {
+	return &ScreeningCase{
+		ID:          GenerateID("scr_", 15),
+		CustomerID:  subject.CustomerID,
+		WalletID:    walletID,
+		SubjectName: subject.Name,
+		Trigger:     trigger,
+		Matches:     matches,
+		Status:      ScreeningCaseOpen,
+		CreatedAt:   time.Now().UTC(),
+	}
+}
+
+// ExcludeClearedMatches drops matches on entries a reviewer already cleared
+// for this customer, so a false positive does not freeze the wallet again
+func ExcludeClearedMatches(matches []ScreeningMatch, cleared []*ScreeningCase) []ScreeningMatch {
+	if len(cleared) == 0 {
+		return matches
+	}
+
+	seen := make(map[string]bool)
+	for _, c := range cleared {
+		if c.Status != ScreeningCaseCleared {
+			continue
+		}
+		for _, m := range c.Matches {
+			seen[m.Key()] = true
+		}
+	}
+
+	var remaining []ScreeningMatch
+	for _, m := range matches {
+		if !seen[m.Key()] {
+			remaining = append(remaining, m)
+		}
+	}
+	return remaining
+}
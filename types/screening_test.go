@@ -0,0 +1,35 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExcludeClearedMatches(t *testing.T) {
+	matches := []ScreeningMatch{
+		{ListName: "sdn", EntryID: "1", MatchedName: "John Smith", Score: 0.97},
+		{ListName: "un", EntryID: "9", MatchedName: "Jon Smith", Score: 0.92},
+	}
+	cleared := []*ScreeningCase{
+		{Status: ScreeningCaseCleared, Matches: []ScreeningMatch{{ListName: "sdn", EntryID: "1", MatchedName: "J. Smith"}}},
+		{Status: ScreeningCaseConfirmed, Matches: []ScreeningMatch{{ListName: "un", EntryID: "9"}}},
+	}
+
+	remaining := ExcludeClearedMatches(matches, cleared)
+	assert.Len(t, remaining, 1)
+	assert.Equal(t, "un:9", remaining[0].Key())
+
+	assert.Equal(t, matches, ExcludeClearedMatches(matches, nil))
+}
+
+func TestNewScreeningCase(t *testing.T) {
+	subject := &ScreeningSubject{CustomerID: "cus_1", Name: "John Smith"}
+	c := NewScreeningCase(subject, "wt_1", ScreeningOnTransfer, []ScreeningMatch{{ListName: "sdn", EntryID: "1"}})
+
+	assert.Equal(t, ScreeningCaseOpen, c.Status)
+	assert.Equal(t, "cus_1", c.CustomerID)
+	assert.Equal(t, "wt_1", c.WalletID)
+	assert.Equal(t, ScreeningOnTransfer, c.Trigger)
+	assert.NotEmpty(t, c.ID)
+}
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"time"
@@ -8,7 +9,8 @@ import (
 	"github.com/shopspring/decimal"
 )
 
-const timeLayout = "02-Jan-2006"
+// StatementDateLayout is the date format used on statement lines and headers
+const StatementDateLayout = "02-Jan-2006"
 
 // AccountStatement represents a comprehensive financial statement for a wallet account
 type AccountStatement struct {
@@ -21,10 +23,13 @@ type AccountStatement struct {
 	IsAccountClosed      bool                   `json:"isClosed"`             // Whether account is closed
 	DatePrinted          string                 `json:"datePrinted"`          // When this statement was generated
 	CurrentBalance       string                 `json:"currentBalance"`       // Formatted current available balance
+	CurrentLienBalance   string                 `json:"currentLienBalance"`   // Formatted current lien (reserved) balance
+	CurrentTotalBalance  string                 `json:"currentTotalBalance"`  // Formatted available plus lien balance
 	StartDate            time.Time              `json:"createdAt"`            // Start of reporting period (raw)
 	EndDate              time.Time              `json:"updatedAt"`            // End of reporting period (raw)
 	Summary              AnalyticsSummary       `json:"summary"`              // Financial summary for period
 	Transactions         []TransactionStatement `json:"transactions"`         // Detailed transaction records
+	PendingTransactions  []TransactionStatement `json:"pendingTransactions"`  // Movements held for review, not yet in the balance
 }
 
 // AnalyticsSummary provides aggregated financial data for the statement period
@@ -42,127 +47,221 @@ type AnalyticsSummary struct {
 // TransactionStatement represents a single transaction line in the account statement
 type TransactionStatement struct {
 	Date        string `json:"date"`        // Formatted transaction date
+	Reference   string `json:"reference"`   // Transaction ID
+	ExternalRef string `json:"externalRef"` // External system reference
 	Description string `json:"description"` // Transaction purpose/memo
 	Credit      string `json:"credit"`      // Formatted credit amount (empty if debit)
 	Debit       string `json:"debit"`       // Formatted debit amount (empty if credit)
 	Fee         string `json:"fee"`         // Formatted transaction fee
-	Balance     string `json:"balance"`     // Formatted balance after transaction
+	Balance     string `json:"balance"`     // Formatted balance after transaction (empty if pending)
+	Status      string `json:"status"`      // Transaction status
 }
 
-// GenerateAccountStatement creates a comprehensive account statement for a given wallet
-// including transaction history and analytics summary within a specified date range.
+// CustomerProfile holds the account holder details printed on statements
+type CustomerProfile struct {
+	CustomerID string `json:"customerId"` // Owner ID
+	Name       string `json:"name"`       // Account holder name
+	Address    string `json:"address"`    // Physical/digital address of account holder
+}
+
+// CustomerProfileSource looks up account holder details for statements
+type CustomerProfileSource interface {
+	FindCustomerProfile(ctx context.Context, customerID string) (*CustomerProfile, error)
+}
+
+// StatementBuilder accumulates transactions into an AccountStatement
+// one row at a time, so callers can stream rows instead of loading a whole period.
+// Rows must be added oldest first.
+//
+// Balances on the statement are booked balances: the opening balance plus the
+// BalanceEffect of each booked row (see TransactionHistory.IsBooked). Funds
+// under a lien are still booked. Rows held for review are listed apart and
+// left out of the totals and balances until the review releases them.
+type StatementBuilder struct {
+	wallet       *Wallet
+	startDate    time.Time
+	endDate      time.Time
+	precision    int32
+	summary      AnalyticsSummary
+	transactions []TransactionStatement
+	pending      []TransactionStatement
+	address      string
+}
+
+// NewStatementBuilder creates a builder for the given wallet and period.
 //
 // Parameters:
 //   - wallet: Pointer to the Wallet struct containing account information
-//   - transactions: Slice of TransactionHistory records for the wallet
+//   - openingBalance: Booked balance at the start of the period (see BookedBalance)
 //   - startDate: Beginning of the reporting period (inclusive)
 //   - endDate: End of the reporting period (inclusive)
 //   - precision: Number of decimal places to round monetary values to
-//
-// Returns:
-//   - AccountStatement containing formatted statement data
-func GenerateAccountStatement(wallet *Wallet, transactions []TransactionHistory,
+func NewStatementBuilder(wallet *Wallet, openingBalance decimal.Decimal,
 	startDate, endDate time.Time, precision int32,
-) AccountStatement {
-	// Helper function to format decimal values with consistent precision
-	formatDecimal := func(d decimal.Decimal) string {
-		return d.Round(precision).StringFixed(precision)
+) *StatementBuilder {
+	return &StatementBuilder{
+		wallet:    wallet,
+		startDate: startDate,
+		endDate:   endDate,
+		precision: precision,
+		address:   "Not specified", // Default value
+		summary: AnalyticsSummary{
+			OpeningBalance:    openingBalance,
+			ClosingBalance:    openingBalance,
+			TotalCreditAmount: decimal.Zero,
+			TotalDebitAmount:  decimal.Zero,
+			TotalFee:          decimal.Zero,
+		},
 	}
+}
 
-	// Initialize summary with zero values properly formatted
-	summary := AnalyticsSummary{
-		OpeningBalance:    decimal.Zero,
-		ClosingBalance:    decimal.Zero,
-		TotalCreditAmount: decimal.Zero,
-		TotalDebitAmount:  decimal.Zero,
-		TotalFee:          decimal.Zero,
+// SetAccountHolderAddress sets the address printed on the statement
+func (b *StatementBuilder) SetAccountHolderAddress(address string) {
+	if address != "" {
+		b.address = address
 	}
+}
 
-	// Filter and sort transactions chronologically
-	var filteredTx []TransactionHistory
-	for _, tx := range transactions {
-		// Include transactions within date range (inclusive)
-		if (tx.CreatedAt.Equal(startDate) || tx.CreatedAt.After(startDate)) &&
-			(tx.CreatedAt.Equal(endDate) || tx.CreatedAt.Before(endDate)) {
-			filteredTx = append(filteredTx, tx)
-		}
+// Add appends a transaction to the statement. Rows that did not move the
+// balance or fall outside the period are ignored.
+func (b *StatementBuilder) Add(tx TransactionHistory) {
+	if !tx.MovesBalance() {
+		return
+	}
+	if tx.CreatedAt.Before(b.startDate) || tx.CreatedAt.After(b.endDate) {
+		return
 	}
 
-	// Sort by initiation time (oldest first)
-	sort.Slice(filteredTx, func(i, j int) bool {
-		return filteredTx[i].CreatedAt.Before(filteredTx[j].CreatedAt)
-	})
-
-	// Process transactions to build statement lines and summary
-	var transactionStatements []TransactionStatement
-	for i, tx := range filteredTx {
-		// Set opening balance from first transaction's balance before
-		if i == 0 {
-			summary.OpeningBalance = tx.BalanceBefore
-		}
+	// Prepare statement line
+	stmt := TransactionStatement{
+		Date:        tx.CreatedAt.Format(StatementDateLayout), // Use consistent date format
+		Reference:   tx.ID,
+		ExternalRef: tx.ExternalReference,
+		Description: tx.Description,
+		Fee:         b.formatDecimal(tx.Fee),
+		Status:      string(tx.Status),
+	}
 
-		// Update transaction counters
-		summary.TotalTransactionCount++
-
-		// Update credit/debit totals
-		switch tx.Type {
-		case TypeCredit:
-			summary.TotalCreditCount++
-			summary.TotalCreditAmount = summary.TotalCreditAmount.Add(tx.Amount)
-		case TypeDebit:
-			summary.TotalDebitCount++
-			summary.TotalDebitAmount = summary.TotalDebitAmount.Add(tx.Amount)
-		}
+	// Set credit/debit amounts (show zero for opposite type)
+	if tx.Type == TypeCredit {
+		stmt.Credit = b.formatDecimal(tx.Amount)
+		stmt.Debit = b.formatDecimal(decimal.Zero)
+	} else {
+		stmt.Credit = b.formatDecimal(decimal.Zero)
+		stmt.Debit = b.formatDecimal(tx.Amount)
+	}
 
-		// Accumulate fees
-		summary.TotalFee = summary.TotalFee.Add(tx.Fee)
+	if !tx.IsBooked() {
+		b.pending = append(b.pending, stmt)
+		return
+	}
 
-		// Always update closing balance to current transaction's balance after
-		summary.ClosingBalance = tx.BalanceAfter
+	// Update transaction counters
+	b.summary.TotalTransactionCount++
 
-		// Prepare statement line
-		stmt := TransactionStatement{
-			Date:        tx.CreatedAt.Format(timeLayout), // Use consistent date format
-			Description: tx.Description,
-			Balance:     formatDecimal(tx.BalanceAfter),
-			Fee:         formatDecimal(tx.Fee),
-		}
+	// Update credit/debit totals
+	switch tx.Type {
+	case TypeCredit:
+		b.summary.TotalCreditCount++
+		b.summary.TotalCreditAmount = b.summary.TotalCreditAmount.Add(tx.Amount)
+	case TypeDebit:
+		b.summary.TotalDebitCount++
+		b.summary.TotalDebitAmount = b.summary.TotalDebitAmount.Add(tx.Amount)
+	}
 
-		// Set credit/debit amounts (show zero for opposite type)
-		if tx.Type == TypeCredit {
-			stmt.Credit = formatDecimal(tx.Amount)
-			stmt.Debit = formatDecimal(decimal.Zero)
-		} else {
-			stmt.Credit = formatDecimal(decimal.Zero)
-			stmt.Debit = formatDecimal(tx.Amount)
-		}
+	// Accumulate fees
+	b.summary.TotalFee = b.summary.TotalFee.Add(tx.Fee)
 
-		transactionStatements = append(transactionStatements, stmt)
-	}
+	// Running balance; a row's own BalanceAfter misses holds and releases
+	// that happened after it was written
+	b.summary.ClosingBalance = b.summary.ClosingBalance.Add(BalanceEffect(&tx))
+	stmt.Balance = b.formatDecimal(b.summary.ClosingBalance)
 
-	// Handle case with no transactions in period
-	if len(filteredTx) == 0 {
-		summary.OpeningBalance = wallet.AvailableBalance
-		summary.ClosingBalance = wallet.AvailableBalance
-	}
+	b.transactions = append(b.transactions, stmt)
+}
 
-	// Format dates for display
-	datePrinted := time.Now().Format(timeLayout)
-	accountOpened := wallet.CreatedAt.Format(timeLayout)
+// Build returns the accumulated AccountStatement
+func (b *StatementBuilder) Build() AccountStatement {
+	wallet := b.wallet
 
 	return AccountStatement{
 		AccountName:          fmt.Sprintf("%s Wallet", wallet.CurrencyCode), // More descriptive name
 		AccountNumber:        wallet.ID,
-		AccountHolderAddress: "Not specified", // Default value
+		AccountHolderAddress: b.address,
 		AccountCurrency:      wallet.CurrencyCode,
-		AccountOpened:        accountOpened,
+		AccountOpened:        wallet.CreatedAt.Format(StatementDateLayout),
 		IsAccountFrozen:      wallet.Frozen,
 		IsAccountClosed:      wallet.IsClosed,
-		DatePrinted:          datePrinted,
-		CurrentBalance:       formatDecimal(wallet.AvailableBalance),
-		StartDate:            startDate,
-		EndDate:              endDate,
-		Summary:              summary,
-		Transactions:         transactionStatements,
+		DatePrinted:          time.Now().Format(StatementDateLayout),
+		CurrentBalance:       b.formatDecimal(wallet.AvailableBalance),
+		CurrentLienBalance:   b.formatDecimal(wallet.LienBalance),
+		CurrentTotalBalance:  b.formatDecimal(wallet.TotalBalance()),
+		StartDate:            b.startDate,
+		EndDate:              b.endDate,
+		Summary:              b.summary,
+		Transactions:         b.transactions,
+		PendingTransactions:  b.pending,
+	}
+}
+
+// formatDecimal formats decimal values with consistent precision
+func (b *StatementBuilder) formatDecimal(d decimal.Decimal) string {
+	return d.Round(b.precision).StringFixed(b.precision)
+}
+
+// GenerateAccountStatement creates a comprehensive account statement for a given wallet
+// including transaction history and analytics summary within a specified date range.
+// Failed transactions are left out, as they never moved the balance, and rows
+// held for review are listed as pending. The opening balance is the
+// BookedBalance of the transactions before startDate.
+//
+// Parameters:
+//   - wallet: Pointer to the Wallet struct containing account information
+//   - transactions: Slice of TransactionHistory records for the wallet
+//   - startDate: Beginning of the reporting period (inclusive)
+//   - endDate: End of the reporting period (inclusive)
+//   - precision: Number of decimal places to round monetary values to
+//
+// Returns:
+//   - AccountStatement containing formatted statement data
+func GenerateAccountStatement(wallet *Wallet, transactions []TransactionHistory,
+	startDate, endDate time.Time, precision int32,
+) AccountStatement {
+	// Keep transactions that moved the balance and sort them chronologically
+	var posted []TransactionHistory
+	for _, tx := range transactions {
+		if tx.MovesBalance() {
+			posted = append(posted, tx)
+		}
+	}
+	sort.SliceStable(posted, func(i, j int) bool {
+		return posted[i].CreatedAt.Before(posted[j].CreatedAt)
+	})
+
+	var before []TransactionHistory
+	for _, tx := range posted {
+		if !tx.CreatedAt.Before(startDate) {
+			break
+		}
+		before = append(before, tx)
+	}
+	openingBalance := BookedBalance(before)
+
+	builder := NewStatementBuilder(wallet, openingBalance, startDate, endDate, precision)
+	for _, tx := range posted {
+		builder.Add(tx)
+	}
+
+	return builder.Build()
+}
+
+// BookedBalance sums the balance effect of the booked rows among transactions
+func BookedBalance(transactions []TransactionHistory) decimal.Decimal {
+	balance := decimal.Zero
+	for i := range transactions {
+		if transactions[i].IsBooked() {
+			balance = balance.Add(BalanceEffect(&transactions[i]))
+		}
 	}
+	return balance
 }
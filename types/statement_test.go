@@ -0,0 +1,71 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateAccountStatement(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 23, 59, 59, 0, time.UTC)
+
+	wallet := &Wallet{
+		ID:               "wt_1",
+		CurrencyCode:     "USD",
+		AvailableBalance: decimal.NewFromInt(130),
+		LienBalance:      decimal.NewFromInt(20),
+		CreatedAt:        start.AddDate(0, -1, 0),
+	}
+
+	row := func(at time.Time, typ TransactionType, amount, before, after int64, status TransactionStatus) TransactionHistory {
+		return TransactionHistory{
+			WalletID:      wallet.ID,
+			Type:          typ,
+			Amount:        decimal.NewFromInt(amount),
+			Fee:           decimal.Zero,
+			BalanceBefore: decimal.NewFromInt(before),
+			BalanceAfter:  decimal.NewFromInt(after),
+			CreatedAt:     at,
+			Status:        status,
+		}
+	}
+
+	transactions := []TransactionHistory{
+		row(start.AddDate(0, 0, 3), TypeDebit, 500, 150, 0, StatusFailed),
+		row(start.AddDate(0, 0, 2), TypeCredit, 50, 100, 150, StatusCompleted),
+		row(start.AddDate(0, 0, -5), TypeCredit, 100, 0, 100, StatusCompleted),
+		row(start.AddDate(0, 0, 4), TypeDebit, 20, 150, 130, StatusCompleted),
+		row(start.AddDate(0, 0, 5), TypeCredit, 10, 130, 140, StatusPending), // Held for review, balance already moved
+	}
+
+	t.Run("opening balance from last row before the period", func(t *testing.T) {
+		stmt := GenerateAccountStatement(wallet, transactions, start, end, 2)
+		assert.True(t, stmt.Summary.OpeningBalance.Equal(decimal.NewFromInt(100)), "got %s", stmt.Summary.OpeningBalance)
+		assert.True(t, stmt.Summary.ClosingBalance.Equal(decimal.NewFromInt(130)), "got %s", stmt.Summary.ClosingBalance)
+		assert.Equal(t, 2, stmt.Summary.TotalTransactionCount)
+		assert.Len(t, stmt.Transactions, 2)
+		assert.Equal(t, "130.00", stmt.Transactions[1].Balance)
+		assert.Equal(t, "130.00", stmt.CurrentBalance)
+		assert.Equal(t, "20.00", stmt.CurrentLienBalance)
+		assert.Equal(t, "150.00", stmt.CurrentTotalBalance)
+	})
+
+	t.Run("held rows are pending, outside the balance", func(t *testing.T) {
+		stmt := GenerateAccountStatement(wallet, transactions, start, end, 2)
+		assert.Len(t, stmt.PendingTransactions, 1)
+		assert.Equal(t, "10.00", stmt.PendingTransactions[0].Credit)
+		assert.Equal(t, string(StatusPending), stmt.PendingTransactions[0].Status)
+		assert.Empty(t, stmt.PendingTransactions[0].Balance)
+		assert.True(t, stmt.Summary.TotalCreditAmount.Equal(decimal.NewFromInt(50)))
+	})
+
+	t.Run("empty period keeps historical balance", func(t *testing.T) {
+		stmt := GenerateAccountStatement(wallet, transactions, end.AddDate(0, 1, 0), end.AddDate(0, 2, 0), 2)
+		assert.Equal(t, 0, stmt.Summary.TotalTransactionCount)
+		assert.True(t, stmt.Summary.OpeningBalance.Equal(decimal.NewFromInt(130)))
+		assert.True(t, stmt.Summary.ClosingBalance.Equal(decimal.NewFromInt(130)))
+	})
+}
@@ -16,6 +16,7 @@ const (
 	CategoryRefund     TransactionCategory = "REFUND"     // Funds being returned
 	CategoryAdjustment TransactionCategory = "ADJUSTMENT" // Manual balance adjustment
 	CategoryFee        TransactionCategory = "FEE"        // Transaction fee deduction
+	CategoryReversal   TransactionCategory = "REVERSAL"   // Undoes a held movement rejected on review
 )
 
 // TransactionType indicates the direction of funds movement
@@ -33,6 +34,7 @@ const (
 	StatusCompleted TransactionStatus = "COMPLETED" // Successfully processed
 	StatusFailed    TransactionStatus = "FAILED"    // Processing failed
 	StatusPending   TransactionStatus = "PENDING"   // Awaiting processing
+	StatusReversed  TransactionStatus = "REVERSED"  // Held for review, then undone by a reversal row
 )
 
 // TransactionHistory contains a wallet transaction record
@@ -52,6 +54,9 @@ type TransactionHistory struct {
 	CreatedAt         time.Time           `json:"initiatedAt" bun:",notnull"`                      // Creation timestamp
 	UpdatedAt         time.Time           `json:"completedAt" bun:",notnull"`                      // Completion timestamp
 	Status            TransactionStatus   `json:"status" bun:",notnull"`                           // Transaction status
+	PrevHash          string              `json:"prevHash" bun:",nullzero"`                        // Hash of the previous row in the wallet's chain
+	Hash              string              `json:"hash" bun:",nullzero"`                            // Hash of this row's canonical contents and PrevHash
+	GroupID           string              `json:"groupId,omitempty" bun:",nullzero"`               // Shared by the rows of a multi-leg movement such as a split
 }
 
 // Transaction status transition errors
@@ -95,6 +100,31 @@ func (t *TransactionHistory) MarkAsFailed() error {
 	return nil
 }
 
+// MarkAsReversed marks a held transaction as undone by a reversal row
+func (t *TransactionHistory) MarkAsReversed() error {
+	if t.Status != StatusPending {
+		return ErrInvalidStatusTransition
+	}
+
+	t.Status = StatusReversed
+	t.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+// MovesBalance reports whether the row counts towards its wallet's balance.
+// Every row that did not fail does, from the time it was written: a pending
+// row moved the balance when it was held (a held credit's amount sits in a
+// lien until release) and a reversed one is undone by its reversal row.
+func (t *TransactionHistory) MovesBalance() bool {
+	return t.Status != StatusFailed
+}
+
+// IsBooked reports whether a row is on the books: completed, or reversed and
+// undone by its own reversal row. Rows held for review are booked once released.
+func (t *TransactionHistory) IsBooked() bool {
+	return t.Status == StatusCompleted || t.Status == StatusReversed
+}
+
 // Revert marks a completed transaction as failed (for refunds/reversals)
 func (t *TransactionHistory) Revert() error {
 	if t.Status != StatusCompleted {
@@ -110,7 +140,7 @@ func (t *TransactionHistory) Revert() error {
 func (t *TransactionHistory) CanTransitionTo(newStatus TransactionStatus) bool {
 	switch t.Status {
 	case StatusPending:
-		return newStatus == StatusCompleted || newStatus == StatusFailed
+		return newStatus == StatusCompleted || newStatus == StatusFailed || newStatus == StatusReversed
 	case "":
 		return newStatus == StatusPending || newStatus == StatusFailed
 	default:
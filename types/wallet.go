@@ -11,20 +11,20 @@ import (
 
 // Wallet holds funds for a customer with thread-safe operations
 type Wallet struct {
-	ID                string          `json:"id" bun:"id,pk"`                                      // Unique ID
-	CustomerID        string          `json:"customerId" bun:",notnull"`                           // Owner ID
-	AvailableBalance  decimal.Decimal `json:"availableBalance" bun:"type:decimal(24,8),notnull"  ` // Spendable amount
-	LienBalance       decimal.Decimal `json:"lienBalance" bun:"type:decimal(24,8),notnull"`        // Reserved amount
-	CurrencyCode      string          `json:"currencyCode" bun:",notnull"`                         // Currency type (USD, EUR etc.)
-	IsClosed          bool            `json:"isClosed" bun:",default:false"`                       // Closed flag
-	Frozen            bool            `json:"frozen" bun:",default:false"`                         // Frozen flag
-	FreezeReason      string          `json:"freezeReason" bun:",nullzero"`                        // Freeze Reason
-	FreezeInitiatedBy string          `json:"freezeInitiatedBy" bun:",nullzero"`                   // Who initiated freeze
-	FrozenAt          time.Time       `json:"frozenAt" bun:",notnull"`                             // Freeze timestamp
-	CreatedAt         time.Time       `json:"createdAt" bun:",notnull"`                            // Creation time
-	UpdatedAt         time.Time       `json:"updatedAt" bun:",notnull"`                            // Last update time
-	VersionId         string          `json:"-" bun:",notnull"`                                    // For concurrency control
-	mutex             sync.RWMutex    `json:"-" bun:"-"`                                           // Thread safety (ignored by bun)
+	ID                string          `json:"id" bun:"id,pk"`                                                   // Unique ID
+	CustomerID        string          `json:"customerId" bun:",notnull,unique:wallets_customer_currency_key"`   // Owner ID
+	AvailableBalance  decimal.Decimal `json:"availableBalance" bun:"type:decimal(24,8),notnull"  `              // Spendable amount
+	LienBalance       decimal.Decimal `json:"lienBalance" bun:"type:decimal(24,8),notnull"`                     // Reserved amount
+	CurrencyCode      string          `json:"currencyCode" bun:",notnull,unique:wallets_customer_currency_key"` // Currency type (USD, EUR etc.)
+	IsClosed          bool            `json:"isClosed" bun:",default:false"`                                    // Closed flag
+	Frozen            bool            `json:"frozen" bun:",default:false"`                                      // Frozen flag
+	FreezeReason      string          `json:"freezeReason" bun:",nullzero"`                                     // Freeze Reason
+	FreezeInitiatedBy string          `json:"freezeInitiatedBy" bun:",nullzero"`                                // Who initiated freeze
+	FrozenAt          time.Time       `json:"frozenAt" bun:",notnull"`                                          // Freeze timestamp
+	CreatedAt         time.Time       `json:"createdAt" bun:",notnull"`                                         // Creation time
+	UpdatedAt         time.Time       `json:"updatedAt" bun:",notnull"`                                         // Last update time
+	VersionId         string          `json:"-" bun:",notnull"`                                                 // For concurrency control
+	mutex             sync.RWMutex    `json:"-" bun:"-"`                                                        // Thread safety (ignored by bun)
 }
 
 // NewWallet creates and initializes a new Wallet instance
@@ -59,6 +59,28 @@ func NewWallet(customerID, currencyCode string) (*Wallet, error) {
 	return wallet, nil
 }
 
+// Clone returns a copy of the wallet with its own lock
+func (w *Wallet) Clone() *Wallet {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	return &Wallet{
+		ID:                w.ID,
+		CustomerID:        w.CustomerID,
+		AvailableBalance:  w.AvailableBalance,
+		LienBalance:       w.LienBalance,
+		CurrencyCode:      w.CurrencyCode,
+		IsClosed:          w.IsClosed,
+		Frozen:            w.Frozen,
+		FreezeReason:      w.FreezeReason,
+		FreezeInitiatedBy: w.FreezeInitiatedBy,
+		FrozenAt:          w.FrozenAt,
+		CreatedAt:         w.CreatedAt,
+		UpdatedAt:         w.UpdatedAt,
+		VersionId:         w.VersionId,
+	}
+}
+
 // CanBeDebited checks if wallet is in a state that allows debits
 func (w *Wallet) CanBeDebited() error {
 	if w.IsClosed {
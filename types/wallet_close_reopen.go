@@ -42,7 +42,7 @@ func (w *Wallet) CloseWallet(req CloseOrOpenRequest) (*CloseOrOpenResult, error)
 		WalletID:    w.ID,
 		OperationID: uuid.New().String(),
 		ExecutedAt:  time.Now(),
-		Balance:     w.TotalBalance().String(),
+		Balance:     w.AvailableBalance.Add(w.LienBalance).String(), // TotalBalance would relock the mutex
 		Reason:      req.Reason,
 	}, nil
 }
@@ -66,7 +66,7 @@ func (w *Wallet) ReopenWallet(req CloseOrOpenRequest) (*CloseOrOpenResult, error
 		WalletID:    w.ID,
 		OperationID: uuid.New().String(),
 		ExecutedAt:  time.Now(),
-		Balance:     w.TotalBalance().String(),
+		Balance:     w.AvailableBalance.Add(w.LienBalance).String(), // TotalBalance would relock the mutex
 		Reason:      req.Reason,
 	}, nil
 }
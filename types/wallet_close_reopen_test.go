@@ -0,0 +1,31 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalletCloseReopen(t *testing.T) {
+	wallet, err := NewWallet("cus_1", "USD")
+	require.NoError(t, err)
+
+	result, err := wallet.CloseWallet(CloseOrOpenRequest{Reason: "customer request"})
+	require.NoError(t, err)
+	assert.True(t, wallet.IsClosed)
+	assert.Equal(t, "0", result.Balance)
+
+	_, err = wallet.CloseWallet(CloseOrOpenRequest{})
+	assert.ErrorIs(t, err, ErrWalletAlreadyClosed)
+
+	result, err = wallet.ReopenWallet(CloseOrOpenRequest{Reason: "returning customer"})
+	require.NoError(t, err)
+	assert.False(t, wallet.IsClosed)
+	assert.Equal(t, "returning customer", result.Reason)
+
+	wallet.AvailableBalance = decimal.NewFromInt(1)
+	_, err = wallet.CloseWallet(CloseOrOpenRequest{})
+	assert.ErrorIs(t, err, ErrWalletNotEmpty)
+}
@@ -25,6 +25,7 @@ type DebitTransaction struct {
 	InitiatorID           string              `json:"initiatorId"`
 	ExternalTransactionID string              `json:"externalTransactionID"`
 	TransactionCategory   TransactionCategory `json:"transactionCategory"`
+	Signature             *RequestSignature   `json:"signature,omitempty"`
 }
 
 // Credit adds funds to the wallet and returns a detailed transaction record
@@ -23,13 +23,33 @@ type LienOrUnlienRequest struct {
 
 // LienRecord contains the complete record of a lien operation
 type LienRecord struct {
-	ID                    string          `json:"id"`                    // Unique reference ID
-	WalletID              string          `json:"walletId"`              // Affected wallet ID
-	Amount                decimal.Decimal `json:"amount"`                // Amount liened/released
-	Description           string          `json:"description"`           // Operation context
-	ExternalTransactionID string          `json:"externalTransactionId"` // Reference from external system
-	CreatedAt             time.Time       `json:"createdAt"`             // When lien was placed
-	ReleasedAt            time.Time       `json:"releasedAt"`            // When lien was released (if applicable)
+	ID                    string          `json:"id" bun:",pk"`                             // Unique reference ID
+	WalletID              string          `json:"walletId" bun:",notnull"`                  // Affected wallet ID
+	Amount                decimal.Decimal `json:"amount" bun:",type:decimal(24,8),notnull"` // Amount liened/released
+	Description           string          `json:"description"`                              // Operation context
+	ExternalTransactionID string          `json:"externalTransactionId"`                    // Reference from external system
+	CreatedAt             time.Time       `json:"createdAt" bun:",notnull"`                 // When lien was placed
+	ReleasedAt            time.Time       `json:"releasedAt" bun:",nullzero"`               // When lien was released (if applicable)
+	LienID                string          `json:"lienId,omitempty" bun:",nullzero"`         // Lien this row releases; empty for placements
+}
+
+// IsRelease reports whether the row records a release rather than a placement
+func (l *LienRecord) IsRelease() bool {
+	return l.LienID != ""
+}
+
+// ReleaseRecord returns a row recording this release on its own, so partial
+// and repeated releases of a lien can be replayed with their amounts and times
+func (l *LienRecord) ReleaseRecord() *LienRecord {
+	return &LienRecord{
+		ID:                    GenerateID("lien_", 15),
+		WalletID:              l.WalletID,
+		Amount:                l.Amount,
+		Description:           l.Description,
+		ExternalTransactionID: l.ExternalTransactionID,
+		CreatedAt:             l.ReleasedAt,
+		LienID:                l.ID,
+	}
 }
 
 // AddLien places a lien on the specified amount from available balance
@@ -0,0 +1,334 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Split payment errors
+var (
+	ErrNoSplitShares         = errors.New("split has no shares")
+	ErrInvalidSplitShare     = errors.New("split share must have either a positive amount or a positive percentage")
+	ErrSplitSharesMismatch   = errors.New("split shares do not add up to the amount")
+	ErrInvalidSplitRemainder = errors.New("invalid split remainder rule")
+	ErrDuplicateSplitShare   = errors.New("split pays the same wallet twice")
+	ErrSplitToSource         = errors.New("split pays the source wallet")
+)
+
+// DefaultSplitPrecision is the number of decimal places shares are rounded
+// to when the currency is not in the catalogue
+const DefaultSplitPrecision int32 = 2
+
+// SplitRemainder decides which percentage share receives the units lost
+// when the shares are rounded down to the currency's precision
+type SplitRemainder string
+
+const (
+	RemainderToFirst    SplitRemainder = "FIRST"    // The first percentage share takes the remainder (default)
+	RemainderToLast     SplitRemainder = "LAST"     // The last percentage share takes the remainder
+	RemainderByFraction SplitRemainder = "FRACTION" // One unit each to the shares that lost the most to rounding
+)
+
+// SplitRequest debits one wallet once and credits several wallets with
+// shares of the amount. Fixed shares are paid first; percentage shares
+// divide what is left and must total 100.
+type SplitRequest struct {
+	Amount                decimal.Decimal     `json:"amount"` // Total credited to the shares
+	Fee                   decimal.Decimal     `json:"fee"`    // Charged to the source on top of the amount
+	Description           string              `json:"description"`
+	InitiatorID           string              `json:"initiatorId"`
+	ExternalTransactionID string              `json:"externalTransactionID"`
+	TransactionCategory   TransactionCategory `json:"transactionCategory"`
+	Shares                []SplitShare        `json:"shares"`
+	Remainder             SplitRemainder      `json:"remainder"`
+	Signature             *RequestSignature   `json:"signature,omitempty"`
+}
+
+// SplitShare is one destination of a split. Exactly one of Amount and
+// Percent is set.
+type SplitShare struct {
+	DestinationWalletID string          `json:"destinationWalletId"`
+	Amount              decimal.Decimal `json:"amount"`      // Fixed amount
+	Percent             decimal.Decimal `json:"percent"`     // Percentage of the amount left after fixed shares
+	Description         string          `json:"description"` // Defaults to the split description
+}
+
+// SigningPayload returns the canonical payload of a split from sourceWalletID
+func (s SplitRequest) SigningPayload(sourceWalletID string, sig *RequestSignature) []byte {
+	digest := sha256.New()
+	for _, share := range s.Shares {
+		fmt.Fprintf(digest, "%s\x00%s\x00%s\x00%s\n",
+			share.DestinationWalletID,
+			share.Amount.String(),
+			share.Percent.String(),
+			share.Description,
+		)
+	}
+
+	return SigningPayload(OperationSplit, sig,
+		sourceWalletID,
+		s.Amount.String(),
+		s.Fee.String(),
+		s.Description,
+		s.InitiatorID,
+		s.ExternalTransactionID,
+		string(s.TransactionCategory),
+		string(s.Remainder),
+		fmt.Sprint(len(s.Shares)),
+		hex.EncodeToString(digest.Sum(nil)),
+	)
+}
+
+// Allocate returns the amount credited to each share, in share order. The
+// amounts are rounded down to precision decimal places and always add up
+// to the request amount; the units lost to rounding are handed out by the
+// remainder rule.
+//
+// Parameters:
+//   - precision: Decimal places of the currency
+//
+// Returns:
+//   - amounts: Credit for each share
+//   - error: ErrSplitSharesMismatch if the shares do not cover the amount
+//     exactly, or another validation error
+func (s SplitRequest) Allocate(precision int32) ([]decimal.Decimal, error) {
+	if len(s.Shares) == 0 {
+		return nil, ErrNoSplitShares
+	}
+	if s.Amount.LessThanOrEqual(decimal.Zero) || !s.Amount.Equal(s.Amount.Truncate(precision)) {
+		return nil, ErrInvalidAmount
+	}
+	if s.Fee.LessThan(decimal.Zero) {
+		return nil, ErrInvalidFee
+	}
+	switch s.Remainder {
+	case "", RemainderToFirst, RemainderToLast, RemainderByFraction:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSplitRemainder, s.Remainder)
+	}
+
+	amounts := make([]decimal.Decimal, len(s.Shares))
+	rest := s.Amount
+	percent := decimal.Zero
+	var proportional []int
+	for i, share := range s.Shares {
+		fixed, pct := share.Amount.IsPositive(), share.Percent.IsPositive()
+		switch {
+		case share.Amount.IsNegative(), share.Percent.IsNegative(), fixed == pct:
+			return nil, fmt.Errorf("%w: share %d", ErrInvalidSplitShare, i+1)
+		case fixed:
+			if !share.Amount.Equal(share.Amount.Truncate(precision)) {
+				return nil, fmt.Errorf("%w: share %d has more than %d decimal places", ErrInvalidSplitShare, i+1, precision)
+			}
+			amounts[i] = share.Amount
+			rest = rest.Sub(share.Amount)
+		default:
+			percent = percent.Add(share.Percent)
+			proportional = append(proportional, i)
+		}
+	}
+
+	if rest.IsNegative() {
+		return nil, fmt.Errorf("%w: fixed shares exceed %s", ErrSplitSharesMismatch, s.Amount)
+	}
+	if len(proportional) == 0 {
+		if !rest.IsZero() {
+			return nil, fmt.Errorf("%w: %s is not allocated", ErrSplitSharesMismatch, rest)
+		}
+		return amounts, nil
+	}
+	if !percent.Equal(decimal.NewFromInt(100)) {
+		return nil, fmt.Errorf("%w: percentages total %s, not 100", ErrSplitSharesMismatch, percent)
+	}
+
+	// Round every percentage share down, then hand out what rounding lost
+	exact := make(map[int]decimal.Decimal, len(proportional))
+	allocated := decimal.Zero
+	for _, i := range proportional {
+		exact[i] = rest.Mul(s.Shares[i].Percent).Div(decimal.NewFromInt(100))
+		amounts[i] = exact[i].Truncate(precision)
+		allocated = allocated.Add(amounts[i])
+	}
+	unit := decimal.New(1, -precision)
+	units := int(rest.Sub(allocated).Div(unit).IntPart())
+
+	switch s.Remainder {
+	case RemainderToLast:
+		last := proportional[len(proportional)-1]
+		amounts[last] = amounts[last].Add(unit.Mul(decimal.NewFromInt(int64(units))))
+	case RemainderByFraction:
+		order := append([]int(nil), proportional...)
+		for n := 0; n < units; n++ {
+			// Largest lost fraction first, earlier shares first on a tie
+			best := 0
+			for k := 1; k < len(order); k++ {
+				if lost(exact, amounts, order[k]).GreaterThan(lost(exact, amounts, order[best])) {
+					best = k
+				}
+			}
+			amounts[order[best]] = amounts[order[best]].Add(unit)
+			order = append(order[:best], order[best+1:]...)
+		}
+	default:
+		first := proportional[0]
+		amounts[first] = amounts[first].Add(unit.Mul(decimal.NewFromInt(int64(units))))
+	}
+
+	for _, i := range proportional {
+		if !amounts[i].IsPositive() {
+			return nil, fmt.Errorf("%w: share %d rounds to zero", ErrInvalidSplitShare, i+1)
+		}
+	}
+	return amounts, nil
+}
+
+// lost returns how much rounding took from a share
+func lost(exact map[int]decimal.Decimal, amounts []decimal.Decimal, i int) decimal.Decimal {
+	return exact[i].Sub(amounts[i])
+}
+
+// Split debits this wallet once and credits each destination with its share.
+// All rows carry the same GroupID. On a validation error the rows are
+// returned marked failed and no balance is changed.
+//
+// Parameters:
+//   - dests: Destination wallets, one per share and in share order
+//   - req: Split request details
+//   - precision: Decimal places shares are rounded to
+//
+// Returns:
+//   - sourceHistory: Debit record for this wallet
+//   - destHistories: Credit records, one per share
+//   - error: Validation or processing error
+func (w *Wallet) Split(dests []*Wallet, req SplitRequest, precision int32) (*TransactionHistory, []*TransactionHistory, error) {
+	amounts, err := req.Allocate(precision)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(dests) != len(req.Shares) {
+		return nil, nil, fmt.Errorf("%w: %d wallets for %d shares", ErrSplitSharesMismatch, len(dests), len(req.Shares))
+	}
+
+	// Each wallet is locked once, so every wallet must be distinct
+	seen := map[string]bool{w.ID: true}
+	for _, dest := range dests {
+		if dest.ID == w.ID {
+			return nil, nil, ErrSplitToSource
+		}
+		if seen[dest.ID] {
+			return nil, nil, fmt.Errorf("%w: %s", ErrDuplicateSplitShare, dest.ID)
+		}
+		seen[dest.ID] = true
+	}
+
+	// Lock every wallet for atomic operation
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for _, dest := range dests {
+		dest.mutex.Lock()
+		defer dest.mutex.Unlock()
+	}
+
+	// Prepare transaction histories
+	now := time.Now()
+	groupID := GenerateID("grp_", 15)
+	sourceHistory := &TransactionHistory{
+		ID:                uuid.New().String(),
+		WalletID:          w.ID,
+		CurrencyCode:      w.CurrencyCode,
+		InitiatorID:       req.InitiatorID,
+		ExternalReference: req.ExternalTransactionID,
+		Category:          req.TransactionCategory,
+		Description:       req.Description,
+		Amount:            req.Amount,
+		Fee:               req.Fee,
+		Type:              TypeDebit,
+		BalanceBefore:     w.AvailableBalance,
+		CreatedAt:         now,
+		Status:            StatusPending,
+		GroupID:           groupID,
+	}
+
+	destHistories := make([]*TransactionHistory, len(dests))
+	for i, dest := range dests {
+		description := req.Shares[i].Description
+		if description == "" {
+			description = req.Description
+		}
+		destHistories[i] = &TransactionHistory{
+			ID:                uuid.New().String(),
+			WalletID:          dest.ID,
+			CurrencyCode:      dest.CurrencyCode,
+			InitiatorID:       req.InitiatorID,
+			ExternalReference: req.ExternalTransactionID,
+			Category:          req.TransactionCategory,
+			Description:       description,
+			Amount:            amounts[i],
+			Fee:               decimal.Zero, // Fees only apply to source
+			Type:              TypeCredit,
+			BalanceBefore:     dest.AvailableBalance,
+			CreatedAt:         now,
+			Status:            StatusPending,
+			GroupID:           groupID,
+		}
+	}
+
+	// Validate split
+	if err := validateSplit(w, dests, req); err != nil {
+		markGroupFailed(sourceHistory, destHistories)
+		return sourceHistory, destHistories, err
+	}
+
+	// Execute split
+	w.AvailableBalance = w.AvailableBalance.Sub(req.Amount.Add(req.Fee))
+	for i, dest := range dests {
+		dest.AvailableBalance = dest.AvailableBalance.Add(amounts[i])
+	}
+
+	// Finalize histories
+	completedAt := time.Now()
+	sourceHistory.BalanceAfter = w.AvailableBalance
+	for i, dest := range dests {
+		destHistories[i].BalanceAfter = dest.AvailableBalance
+	}
+	for _, history := range append([]*TransactionHistory{sourceHistory}, destHistories...) {
+		history.Status = StatusCompleted
+		history.UpdatedAt = completedAt
+	}
+
+	return sourceHistory, destHistories, nil
+}
+
+// validateSplit checks the wallets can take part in the split
+func validateSplit(source *Wallet, dests []*Wallet, req SplitRequest) error {
+	if err := source.CanBeDebited(); err != nil {
+		return err
+	}
+	for _, dest := range dests {
+		if dest.CurrencyCode != source.CurrencyCode {
+			return fmt.Errorf("%w: %s", ErrCurrencyMismatch, dest.ID)
+		}
+		if err := dest.CanBeCredited(); err != nil {
+			return fmt.Errorf("%w: %s", err, dest.ID)
+		}
+	}
+	if source.AvailableBalance.LessThan(req.Amount.Add(req.Fee)) {
+		return ErrInsufficientFunds
+	}
+	return nil
+}
+
+// markGroupFailed updates every row of a split to failed status
+func markGroupFailed(source *TransactionHistory, dests []*TransactionHistory) {
+	now := time.Now()
+	for _, history := range append([]*TransactionHistory{source}, dests...) {
+		history.Status = StatusFailed
+		history.UpdatedAt = now
+	}
+}
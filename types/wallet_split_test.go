@@ -0,0 +1,245 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fixedShare(walletID, amount string) SplitShare {
+	return SplitShare{DestinationWalletID: walletID, Amount: decimal.RequireFromString(amount)}
+}
+
+func percentShare(walletID, percent string) SplitShare {
+	return SplitShare{DestinationWalletID: walletID, Percent: decimal.RequireFromString(percent)}
+}
+
+func TestSplitRequestAllocate(t *testing.T) {
+	thirds := []SplitShare{percentShare("a", "33.3333"), percentShare("b", "33.3333"), percentShare("c", "33.3334")}
+
+	tests := []struct {
+		name      string
+		amount    string
+		precision int32
+		shares    []SplitShare
+		remainder SplitRemainder
+		want      []string
+		err       error
+	}{
+		{
+			name:   "fixed shares first, percentages of the rest",
+			amount: "100", precision: 2,
+			shares: []SplitShare{percentShare("seller", "90"), percentShare("platform", "10"), fixedShare("delivery", "5")},
+			want:   []string{"85.5", "9.5", "5"},
+		},
+		{
+			name:   "fixed shares only",
+			amount: "30", precision: 2,
+			shares: []SplitShare{fixedShare("a", "10"), fixedShare("b", "20")},
+			want:   []string{"10", "20"},
+		},
+		{
+			name:   "remainder to the first percentage share by default",
+			amount: "0.1", precision: 2,
+			shares: thirds,
+			want:   []string{"0.04", "0.03", "0.03"},
+		},
+		{
+			name:   "remainder to the last percentage share",
+			amount: "0.1", precision: 2, remainder: RemainderToLast,
+			shares: thirds,
+			want:   []string{"0.03", "0.03", "0.04"},
+		},
+		{
+			name:   "remainder to the largest lost fractions",
+			amount: "10", precision: 0, remainder: RemainderByFraction,
+			shares: []SplitShare{percentShare("a", "15"), percentShare("b", "17"), percentShare("c", "68")},
+			want:   []string{"1", "2", "7"},
+		},
+		{
+			name:   "no decimal places",
+			amount: "7", precision: 0,
+			shares: []SplitShare{percentShare("a", "50"), percentShare("b", "50")},
+			want:   []string{"4", "3"},
+		},
+		{
+			name:   "percentages must total 100",
+			amount: "100", precision: 2,
+			shares: []SplitShare{percentShare("a", "60"), percentShare("b", "30")},
+			err:    ErrSplitSharesMismatch,
+		},
+		{
+			name:   "fixed shares must cover the amount",
+			amount: "100", precision: 2,
+			shares: []SplitShare{fixedShare("a", "60")},
+			err:    ErrSplitSharesMismatch,
+		},
+		{
+			name:   "fixed shares cannot exceed the amount",
+			amount: "100", precision: 2,
+			shares: []SplitShare{fixedShare("a", "60"), fixedShare("b", "50"), percentShare("c", "100")},
+			err:    ErrSplitSharesMismatch,
+		},
+		{
+			name:   "a share is either fixed or a percentage",
+			amount: "100", precision: 2,
+			shares: []SplitShare{{DestinationWalletID: "a", Amount: decimal.NewFromInt(50), Percent: decimal.NewFromInt(100)}},
+			err:    ErrInvalidSplitShare,
+		},
+		{
+			name:   "a share that rounds to zero",
+			amount: "0.01", precision: 2,
+			shares: []SplitShare{percentShare("a", "50"), percentShare("b", "50")},
+			err:    ErrInvalidSplitShare,
+		},
+		{
+			name:   "a fixed share finer than the currency",
+			amount: "10", precision: 2,
+			shares: []SplitShare{fixedShare("a", "9.995"), fixedShare("b", "0.005")},
+			err:    ErrInvalidSplitShare,
+		},
+		{
+			name:   "an amount finer than the currency",
+			amount: "10.005", precision: 2,
+			shares: []SplitShare{percentShare("a", "100")},
+			err:    ErrInvalidAmount,
+		},
+		{
+			name:      "no shares",
+			amount:    "10",
+			precision: 2,
+			err:       ErrNoSplitShares,
+		},
+		{
+			name:   "unknown remainder rule",
+			amount: "10", precision: 2, remainder: "RANDOM",
+			shares: []SplitShare{percentShare("a", "100")},
+			err:    ErrInvalidSplitRemainder,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := SplitRequest{Amount: decimal.RequireFromString(tt.amount), Shares: tt.shares, Remainder: tt.remainder}
+			amounts, err := req.Allocate(tt.precision)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+
+			got := make([]string, len(amounts))
+			total := decimal.Zero
+			for i, amount := range amounts {
+				got[i] = amount.String()
+				total = total.Add(amount)
+			}
+			assert.Equal(t, tt.want, got)
+			assert.True(t, total.Equal(req.Amount), "shares add up to the amount")
+		})
+	}
+}
+
+func TestWalletSplit(t *testing.T) {
+	newWallets := func(t *testing.T) (*Wallet, []*Wallet) {
+		source, err := NewWallet("cus_buyer", "USD")
+		require.NoError(t, err)
+		source.AvailableBalance = decimal.NewFromInt(200)
+
+		var dests []*Wallet
+		for _, customer := range []string{"cus_seller", "cus_platform", "cus_delivery"} {
+			dest, err := NewWallet(customer, "USD")
+			require.NoError(t, err)
+			dests = append(dests, dest)
+		}
+		return source, dests
+	}
+	request := func(dests []*Wallet) SplitRequest {
+		return SplitRequest{
+			Amount:              decimal.NewFromInt(100),
+			Fee:                 decimal.NewFromInt(2),
+			Description:         "order 42",
+			InitiatorID:         "cus_buyer",
+			TransactionCategory: CategoryTransfer,
+			Shares: []SplitShare{
+				percentShare(dests[0].ID, "90"),
+				{DestinationWalletID: dests[1].ID, Percent: decimal.NewFromInt(10), Description: "commission"},
+				fixedShare(dests[2].ID, "5"),
+			},
+		}
+	}
+
+	t.Run("one debit, a credit per share", func(t *testing.T) {
+		source, dests := newWallets(t)
+
+		debit, credits, err := source.Split(dests, request(dests), 2)
+		require.NoError(t, err)
+		assert.Equal(t, "98", source.AvailableBalance.String())
+		assert.Equal(t, "100", debit.Amount.String())
+		assert.Equal(t, "2", debit.Fee.String())
+		assert.Equal(t, StatusCompleted, debit.Status)
+		assert.NotEmpty(t, debit.GroupID)
+
+		require.Len(t, credits, 3)
+		for i, want := range []string{"85.5", "9.5", "5"} {
+			assert.Equal(t, want, credits[i].Amount.String())
+			assert.Equal(t, want, dests[i].AvailableBalance.String())
+			assert.Equal(t, dests[i].ID, credits[i].WalletID)
+			assert.Equal(t, debit.GroupID, credits[i].GroupID)
+			assert.Equal(t, StatusCompleted, credits[i].Status)
+		}
+		assert.Equal(t, "order 42", credits[0].Description)
+		assert.Equal(t, "commission", credits[1].Description)
+	})
+
+	t.Run("a failed check changes no balance", func(t *testing.T) {
+		source, dests := newWallets(t)
+		dests[2].IsClosed = true
+
+		debit, credits, err := source.Split(dests, request(dests), 2)
+		assert.ErrorIs(t, err, ErrWalletClosed)
+		assert.Equal(t, StatusFailed, debit.Status)
+		assert.Equal(t, StatusFailed, credits[0].Status)
+		assert.Equal(t, "200", source.AvailableBalance.String())
+		assert.True(t, dests[0].AvailableBalance.IsZero())
+	})
+
+	t.Run("the source must cover the amount and fee", func(t *testing.T) {
+		source, dests := newWallets(t)
+		source.AvailableBalance = decimal.NewFromInt(101)
+
+		_, _, err := source.Split(dests, request(dests), 2)
+		assert.ErrorIs(t, err, ErrInsufficientFunds)
+	})
+
+	t.Run("every wallet is distinct", func(t *testing.T) {
+		source, dests := newWallets(t)
+
+		_, _, err := source.Split([]*Wallet{dests[0], dests[0], dests[2]}, request(dests), 2)
+		assert.ErrorIs(t, err, ErrDuplicateSplitShare)
+		_, _, err = source.Split([]*Wallet{dests[0], source, dests[2]}, request(dests), 2)
+		assert.ErrorIs(t, err, ErrSplitToSource)
+	})
+
+	t.Run("every wallet shares the currency", func(t *testing.T) {
+		source, dests := newWallets(t)
+		dests[1].CurrencyCode = "EUR"
+
+		_, _, err := source.Split(dests, request(dests), 2)
+		assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	})
+}
+
+func TestHashTransactionCoversGroup(t *testing.T) {
+	tx := &TransactionHistory{ID: "tx_1", WalletID: "wt_1", Amount: decimal.NewFromInt(10)}
+	ungrouped := HashTransaction(tx)
+
+	tx.GroupID = "grp_1"
+	grouped := HashTransaction(tx)
+	assert.NotEqual(t, ungrouped, grouped)
+
+	tx.GroupID = "grp_2"
+	assert.NotEqual(t, grouped, HashTransaction(tx))
+}
@@ -15,6 +15,7 @@ type TransferRequest struct {
 	InitiatorID           string              `json:"initiatorId"`
 	ExternalTransactionID string              `json:"externalTransactionID"`
 	TransactionCategory   TransactionCategory `json:"transactionCategory"`
+	Signature             *RequestSignature   `json:"signature,omitempty"`
 }
 
 // Transfer moves funds from this wallet to a destination wallet.
@@ -25,6 +26,8 @@ type TransferRequest struct {
 //   - dest: The destination wallet receiving funds
 //   - req: Transfer request details
 //
+// Both records carry the same GroupID, linking the two legs of the transfer.
+//
 // Returns:
 //   - sourceHistory: Debit record for this wallet
 //   - destHistory: Credit record for destination wallet
@@ -43,6 +46,7 @@ func (w *Wallet) Transfer(dest *Wallet, req TransferRequest) (*TransactionHistor
 
 	// Prepare transaction histories
 	now := time.Now()
+	groupID := GenerateID("grp_", 15)
 	sourceHistory := &TransactionHistory{
 		ID:                uuid.New().String(),
 		WalletID:          w.ID,
@@ -57,6 +61,7 @@ func (w *Wallet) Transfer(dest *Wallet, req TransferRequest) (*TransactionHistor
 		BalanceBefore:     w.AvailableBalance,
 		CreatedAt:         now,
 		Status:            StatusPending,
+		GroupID:           groupID,
 	}
 
 	destHistory := &TransactionHistory{
@@ -73,6 +78,7 @@ func (w *Wallet) Transfer(dest *Wallet, req TransferRequest) (*TransactionHistor
 		BalanceBefore:     dest.AvailableBalance,
 		CreatedAt:         now,
 		Status:            StatusPending,
+		GroupID:           groupID,
 	}
 
 	// Validate transfer
@@ -160,6 +166,16 @@ type SwapRequest struct {
 
 	// TransactionCategory classifies the type of transaction
 	TransactionCategory TransactionCategory `json:"transactionCategory"`
+
+	// MidRate is the mid market rate when the swap was priced (optional, used for FX reporting)
+	MidRate decimal.Decimal `json:"midRate"`
+
+	// SourceBaseRate is the base currency value of one source currency unit when the
+	// swap was priced (optional, used for FX reporting)
+	SourceBaseRate decimal.Decimal `json:"sourceBaseRate"`
+
+	// Signature authenticates the request (optional unless the store enforces signing)
+	Signature *RequestSignature `json:"signature,omitempty"`
 }
 
 // Swap exchanges funds between wallets of different currencies at a specified rate.
@@ -0,0 +1,282 @@
+package types
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook errors
+var (
+	ErrWebhookSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrWebhookDeliveryNotFound     = errors.New("webhook delivery not found")
+	ErrInvalidWebhookURL           = errors.New("webhook URL must be an absolute http or https URL")
+	ErrInvalidWebhookSignature     = errors.New("invalid webhook signature")
+)
+
+// Webhook request headers
+const (
+	WebhookHeaderID        = "X-Webhook-Id"        // Delivery ID, stable across retries
+	WebhookHeaderEvent     = "X-Webhook-Event"     // Event type
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp" // Unix seconds the request was signed at
+	WebhookHeaderSignature = "X-Webhook-Signature" // "v1=" and the hex HMAC-SHA256 of "<timestamp>.<body>"
+)
+
+// webhookSignatureVersion prefixes signatures so the scheme can change later
+const webhookSignatureVersion = "v1="
+
+// WebhookSubscription sends a customer's wallet events to a URL
+type WebhookSubscription struct {
+	ID         string      `json:"id" bun:",pk"`              // Unique subscription ID
+	CustomerID string      `json:"customerId" bun:",notnull"` // Customer whose wallet events are sent
+	URL        string      `json:"url" bun:",notnull"`        // Receiving endpoint
+	Secret     string      `json:"-" bun:",notnull"`          // HMAC key deliveries are signed with
+	EventTypes []EventType `json:"eventTypes" bun:",notnull"` // Events sent; empty means all
+	Active     bool        `json:"active" bun:",notnull"`     // Whether new events are delivered
+	CreatedAt  time.Time   `json:"createdAt" bun:",notnull"`  // Creation time
+	UpdatedAt  time.Time   `json:"updatedAt" bun:",notnull"`  // Last change
+}
+
+// NewWebhookSubscription creates an active subscription with a fresh secret
+func NewWebhookSubscription(customerID, endpoint string, eventTypes ...EventType) (*WebhookSubscription, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || !parsed.IsAbs() || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, ErrInvalidWebhookURL
+	}
+
+	now := time.Now().UTC()
+	return &WebhookSubscription{
+		ID:         GenerateID("whs_", 15),
+		CustomerID: customerID,
+		URL:        endpoint,
+		Secret:     GenerateID("whsec_", 32),
+		EventTypes: eventTypes,
+		Active:     true,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}, nil
+}
+
+// Matches reports whether the subscription wants events of the given type
+func (s *WebhookSubscription) Matches(eventType EventType) bool {
+	if !s.Active {
+		return false
+	}
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDeliveryStatus tracks a delivery through its retries
+type WebhookDeliveryStatus string
+
+const (
+	WebhookPending   WebhookDeliveryStatus = "PENDING"   // Waiting for its next attempt
+	WebhookDelivered WebhookDeliveryStatus = "DELIVERED" // The receiver accepted it
+	WebhookDead      WebhookDeliveryStatus = "DEAD"      // Retries exhausted; needs a redelivery
+)
+
+// WebhookDelivery is one event sent to one subscription
+type WebhookDelivery struct {
+	ID             string                `json:"id" bun:",pk"`                                       // Unique delivery ID
+	SubscriptionID string                `json:"subscriptionId" bun:",notnull,unique:webhook_event"` // Target subscription
+	EventID        string                `json:"eventId" bun:",notnull,unique:webhook_event"`        // Outbox event delivered
+	EventType      EventType             `json:"eventType" bun:",notnull"`                           // Outbox event type
+	Body           json.RawMessage       `json:"body" bun:"type:json,notnull"`                       // Request body, identical on every attempt
+	Status         WebhookDeliveryStatus `json:"status" bun:",notnull"`                              // Delivery state
+	Attempts       int                   `json:"attempts" bun:",notnull,default:0"`                  // Attempts since the delivery (or redelivery) started
+	NextAttemptAt  time.Time             `json:"nextAttemptAt" bun:",nullzero"`                      // When the next attempt is due
+	LastStatusCode int                   `json:"lastStatusCode" bun:",nullzero"`                     // HTTP status of the last attempt
+	LastError      string                `json:"lastError" bun:",nullzero"`                          // Error of the last failed attempt
+	CreatedAt      time.Time             `json:"createdAt" bun:",notnull"`                           // When the event was queued
+	DeliveredAt    time.Time             `json:"deliveredAt" bun:",nullzero"`                        // When the receiver accepted it
+}
+
+// webhookBody is the JSON sent to receivers
+type webhookBody struct {
+	ID         string       `json:"id"`
+	Type       EventType    `json:"type"`
+	OccurredAt time.Time    `json:"occurredAt"`
+	Data       *WebhookData `json:"data"`
+}
+
+// WebhookData is what a receiver learns about an event: the state of the
+// event's wallet and the rows the change wrote to it. The other wallet of a
+// transfer, swap or split and its rows belong to another customer and are
+// left out.
+type WebhookData struct {
+	Wallet       *Wallet               `json:"wallet"`                 // Wallet state after the change
+	Transactions []*TransactionHistory `json:"transactions,omitempty"` // The wallet's rows the change wrote
+	Lien         *LienRecord           `json:"lien,omitempty"`         // Lien placed or released
+	Reason       string                `json:"reason,omitempty"`       // Why the change was made
+}
+
+// NewWebhookData builds the webhook data of an outbox event
+func NewWebhookData(event *OutboxEvent) (*WebhookData, error) {
+	payload, err := event.DecodeWalletEvent()
+	if err != nil {
+		return nil, err
+	}
+
+	data := &WebhookData{Reason: payload.Reason}
+	if payload.Wallet != nil && payload.Wallet.ID == event.AggregateID {
+		data.Wallet = payload.Wallet
+	}
+	if payload.Lien != nil && payload.Lien.WalletID == event.AggregateID {
+		data.Lien = payload.Lien
+	}
+	for _, tx := range payload.Transactions {
+		if tx != nil && tx.WalletID == event.AggregateID {
+			data.Transactions = append(data.Transactions, tx)
+		}
+	}
+	return data, nil
+}
+
+// NewWebhookDelivery queues an event for a subscription
+func NewWebhookDelivery(sub *WebhookSubscription, event *OutboxEvent) (*WebhookDelivery, error) {
+	data, err := NewWebhookData(event)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(webhookBody{
+		ID:         event.EventID,
+		Type:       event.Type,
+		OccurredAt: event.OccurredAt,
+		Data:       data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode webhook body: %w", err)
+	}
+
+	now := time.Now().UTC()
+	return &WebhookDelivery{
+		ID:             GenerateID("whd_", 15),
+		SubscriptionID: sub.ID,
+		EventID:        event.EventID,
+		EventType:      event.Type,
+		Body:           body,
+		Status:         WebhookPending,
+		NextAttemptAt:  now,
+		CreatedAt:      now,
+	}, nil
+}
+
+// WebhookAttempt is the log entry of one HTTP attempt
+type WebhookAttempt struct {
+	ID          string    `json:"id" bun:",pk"`               // Unique attempt ID
+	DeliveryID  string    `json:"deliveryId" bun:",notnull"`  // Delivery attempted
+	StatusCode  int       `json:"statusCode" bun:",nullzero"` // HTTP status, zero when no response
+	Error       string    `json:"error" bun:",nullzero"`      // Transport error or non-2xx reason
+	Response    string    `json:"response" bun:",nullzero"`   // Start of the response body
+	DurationMS  int64     `json:"durationMs" bun:",notnull"`  // Round-trip time
+	AttemptedAt time.Time `json:"attemptedAt" bun:",notnull"` // When the request was sent
+}
+
+// Succeeded reports whether the receiver accepted the delivery
+func (a *WebhookAttempt) Succeeded() bool {
+	return a.Error == "" && a.StatusCode >= 200 && a.StatusCode < 300
+}
+
+// WebhookRetryPolicy spaces out retries of failed deliveries
+type WebhookRetryPolicy struct {
+	MaxAttempts int           // Attempts before the delivery is dead-lettered
+	BaseDelay   time.Duration // Wait after the first failure; doubled after each further one
+	MaxDelay    time.Duration // Cap on the wait between attempts
+}
+
+// DefaultWebhookRetryPolicy retries for roughly a day
+func DefaultWebhookRetryPolicy() WebhookRetryPolicy {
+	return WebhookRetryPolicy{
+		MaxAttempts: 10,
+		BaseDelay:   30 * time.Second,
+		MaxDelay:    6 * time.Hour,
+	}
+}
+
+// Delay returns the wait after the given number of failed attempts
+func (p WebhookRetryPolicy) Delay(failures int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// RecordAttempt applies an attempt's outcome: delivered on success, otherwise
+// rescheduled with backoff or dead-lettered once attempts run out
+func (d *WebhookDelivery) RecordAttempt(attempt *WebhookAttempt, policy WebhookRetryPolicy) {
+	d.Attempts++
+	d.LastStatusCode = attempt.StatusCode
+	d.LastError = attempt.Error
+
+	switch {
+	case attempt.Succeeded():
+		d.Status = WebhookDelivered
+		d.DeliveredAt = attempt.AttemptedAt
+		d.NextAttemptAt = time.Time{}
+	case d.Attempts >= policy.MaxAttempts:
+		d.Status = WebhookDead
+		d.NextAttemptAt = time.Time{}
+	default:
+		d.Status = WebhookPending
+		d.NextAttemptAt = attempt.AttemptedAt.Add(policy.Delay(d.Attempts))
+	}
+}
+
+// Redeliver restarts a delivery with a fresh retry budget
+func (d *WebhookDelivery) Redeliver(now time.Time) {
+	d.Status = WebhookPending
+	d.Attempts = 0
+	d.NextAttemptAt = now
+	d.DeliveredAt = time.Time{}
+}
+
+// SignWebhook returns the signature header value for a body sent at timestamp
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return webhookSignatureVersion + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks a received webhook's timestamp and signature headers.
+// Receivers should reject requests whose timestamp is more than maxSkew away
+// from now to limit replays.
+func VerifyWebhook(secret, timestampHeader, signatureHeader string, body []byte, maxSkew time.Duration, now time.Time) error {
+	unix, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: bad timestamp", ErrInvalidWebhookSignature)
+	}
+	timestamp := time.Unix(unix, 0)
+	if skew := now.Sub(timestamp); skew > maxSkew || skew < -maxSkew {
+		return fmt.Errorf("%w: timestamp outside allowed window", ErrInvalidWebhookSignature)
+	}
+
+	expected := SignWebhook(secret, timestamp, body)
+	if !strings.HasPrefix(signatureHeader, webhookSignatureVersion) || !hmac.Equal([]byte(expected), []byte(signatureHeader)) {
+		return ErrInvalidWebhookSignature
+	}
+	return nil
+}
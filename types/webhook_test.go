@@ -0,0 +1,126 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWebhookSubscription(t *testing.T) {
+	sub, err := NewWebhookSubscription("cus_1", "https://merchant.example/hooks", EventWalletCredited)
+	require.NoError(t, err)
+	assert.True(t, sub.Active)
+	assert.NotEmpty(t, sub.Secret)
+	assert.True(t, sub.Matches(EventWalletCredited))
+	assert.False(t, sub.Matches(EventWalletDebited))
+
+	all, err := NewWebhookSubscription("cus_1", "http://localhost:8080/hooks")
+	require.NoError(t, err)
+	assert.True(t, all.Matches(EventWalletDebited))
+	all.Active = false
+	assert.False(t, all.Matches(EventWalletDebited))
+
+	for _, endpoint := range []string{"", "/hooks", "ftp://merchant.example", "https://"} {
+		_, err := NewWebhookSubscription("cus_1", endpoint)
+		assert.ErrorIs(t, err, ErrInvalidWebhookURL, endpoint)
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	now := time.Unix(1717236000, 0)
+	body := []byte(`{"id":"evt_1"}`)
+	signature := SignWebhook("secret", now, body)
+	timestamp := fmt.Sprint(now.Unix())
+
+	assert.NoError(t, VerifyWebhook("secret", timestamp, signature, body, 5*time.Minute, now.Add(time.Minute)))
+	assert.ErrorIs(t, VerifyWebhook("other", timestamp, signature, body, 5*time.Minute, now), ErrInvalidWebhookSignature)
+	assert.ErrorIs(t, VerifyWebhook("secret", timestamp, signature, []byte(`{"id":"evt_2"}`), 5*time.Minute, now), ErrInvalidWebhookSignature)
+	assert.ErrorIs(t, VerifyWebhook("secret", timestamp, signature, body, 5*time.Minute, now.Add(10*time.Minute)), ErrInvalidWebhookSignature)
+	assert.ErrorIs(t, VerifyWebhook("secret", "yesterday", signature, body, 5*time.Minute, now), ErrInvalidWebhookSignature)
+	assert.ErrorIs(t, VerifyWebhook("secret", timestamp, signature[3:], body, 5*time.Minute, now), ErrInvalidWebhookSignature)
+}
+
+func TestWebhookRetryPolicyDelay(t *testing.T) {
+	policy := WebhookRetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	assert.Equal(t, time.Second, policy.Delay(1))
+	assert.Equal(t, 2*time.Second, policy.Delay(2))
+	assert.Equal(t, 4*time.Second, policy.Delay(3))
+	assert.Equal(t, 5*time.Second, policy.Delay(4))
+	assert.Equal(t, 5*time.Second, policy.Delay(40))
+}
+
+func TestWebhookDeliveryRecordAttempt(t *testing.T) {
+	sub, err := NewWebhookSubscription("cus_1", "https://merchant.example/hooks")
+	require.NoError(t, err)
+	event, err := NewOutboxEvent(EventWalletCredited, "wt_1", &WalletEvent{Wallet: &Wallet{ID: "wt_1"}})
+	require.NoError(t, err)
+
+	delivery, err := NewWebhookDelivery(sub, event)
+	require.NoError(t, err)
+	assert.Equal(t, WebhookPending, delivery.Status)
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(delivery.Body, &body))
+	assert.Equal(t, event.EventID, body["id"])
+	assert.Equal(t, string(EventWalletCredited), body["type"])
+
+	policy := WebhookRetryPolicy{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour}
+	at := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	delivery.RecordAttempt(&WebhookAttempt{StatusCode: 500, Error: "receiver responded 500", AttemptedAt: at}, policy)
+	assert.Equal(t, WebhookPending, delivery.Status)
+	assert.Equal(t, at.Add(time.Minute), delivery.NextAttemptAt)
+
+	delivery.RecordAttempt(&WebhookAttempt{Error: "connection refused", AttemptedAt: at}, policy)
+	assert.Equal(t, at.Add(2*time.Minute), delivery.NextAttemptAt)
+
+	delivery.RecordAttempt(&WebhookAttempt{StatusCode: 503, Error: "receiver responded 503", AttemptedAt: at}, policy)
+	assert.Equal(t, WebhookDead, delivery.Status)
+	assert.True(t, delivery.NextAttemptAt.IsZero())
+	assert.Equal(t, 3, delivery.Attempts)
+
+	delivery.Redeliver(at)
+	assert.Equal(t, WebhookPending, delivery.Status)
+	assert.Zero(t, delivery.Attempts)
+
+	delivery.RecordAttempt(&WebhookAttempt{StatusCode: 204, AttemptedAt: at}, policy)
+	assert.Equal(t, WebhookDelivered, delivery.Status)
+	assert.Equal(t, at, delivery.DeliveredAt)
+	assert.Empty(t, delivery.LastError)
+}
+
+func TestWebhookDeliveryOmitsCounterparty(t *testing.T) {
+	sub, err := NewWebhookSubscription("cus_2", "https://merchant.example/hooks")
+	require.NoError(t, err)
+
+	source := &Wallet{ID: "wt_1", CustomerID: "cus_1", CurrencyCode: "USD"}
+	dest := &Wallet{ID: "wt_2", CustomerID: "cus_2", CurrencyCode: "USD"}
+	debit := &TransactionHistory{ID: "tx_debit", WalletID: source.ID, Type: TypeDebit}
+	credit := &TransactionHistory{ID: "tx_credit", WalletID: dest.ID, Type: TypeCredit}
+	event, err := NewOutboxEvent(EventTransferCompleted, dest.ID, &WalletEvent{
+		Wallet:       dest,
+		Counterparty: source,
+		Transactions: []*TransactionHistory{debit, credit},
+	})
+	require.NoError(t, err)
+
+	delivery, err := NewWebhookDelivery(sub, event)
+	require.NoError(t, err)
+	assert.NotContains(t, string(delivery.Body), source.ID)
+	assert.NotContains(t, string(delivery.Body), source.CustomerID)
+	assert.NotContains(t, string(delivery.Body), debit.ID)
+
+	var body struct {
+		Data WebhookData `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(delivery.Body, &body))
+	require.NotNil(t, body.Data.Wallet)
+	assert.Equal(t, dest.ID, body.Data.Wallet.ID)
+	require.Len(t, body.Data.Transactions, 1)
+	assert.Equal(t, credit.ID, body.Data.Transactions[0].ID)
+}
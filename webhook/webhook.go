@@ -0,0 +1,193 @@
+// Package webhook delivers wallet events to merchant endpoints. A Dispatcher
+// plugs into the outbox relay and queues a delivery per matching subscription;
+// a Sender posts due deliveries, signing each request and retrying failures
+// with exponential backoff until they are delivered or dead-lettered.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/otyang/waas-go/types"
+)
+
+// Sender defaults
+const (
+	DefaultBatchSize  = 50
+	DefaultInterval   = time.Second
+	DefaultTimeout    = 10 * time.Second
+	maxLoggedResponse = 1024
+	userAgent         = "waas-webhooks/1"
+)
+
+// Store holds subscriptions, deliveries and the attempt log.
+// store.WalletRepository implements it.
+type Store interface {
+	EnqueueWebhookDeliveries(ctx context.Context, event *types.OutboxEvent, customerID string) ([]*types.WebhookDelivery, error)
+	ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]*types.WebhookDelivery, error)
+	FindWebhookSubscription(ctx context.Context, id string) (*types.WebhookSubscription, error)
+	RecordWebhookAttempt(ctx context.Context, delivery *types.WebhookDelivery, attempt *types.WebhookAttempt) error
+}
+
+// Dispatcher queues webhook deliveries for outbox events. It implements
+// outbox.Publisher, so it can be handed to an outbox relay directly.
+type Dispatcher struct {
+	store Store
+}
+
+// NewDispatcher creates a Dispatcher queuing deliveries in store
+func NewDispatcher(store Store) *Dispatcher {
+	return &Dispatcher{store: store}
+}
+
+// Publish queues the event for the subscriptions of the wallet's owner
+func (d *Dispatcher) Publish(ctx context.Context, event *types.OutboxEvent) error {
+	payload, err := event.DecodeWalletEvent()
+	if err != nil {
+		return err
+	}
+	if payload.Wallet == nil || payload.Wallet.CustomerID == "" {
+		return nil
+	}
+
+	_, err = d.store.EnqueueWebhookDeliveries(ctx, event, payload.Wallet.CustomerID)
+	return err
+}
+
+// Sender posts due deliveries. Run a single sender per store; two would pick
+// up the same due deliveries.
+type Sender struct {
+	store     Store
+	Client    *http.Client             // HTTP client (DefaultTimeout when nil)
+	Retry     types.WebhookRetryPolicy // Backoff and dead-lettering
+	BatchSize int                      // Deliveries attempted per batch (DefaultBatchSize when zero)
+	Interval  time.Duration            // Wait between polls once nothing is due (DefaultInterval when zero)
+	Now       func() time.Time         // Clock (time.Now when nil)
+}
+
+// NewSender creates a Sender with the default retry policy
+func NewSender(store Store) *Sender {
+	return &Sender{store: store, Retry: types.DefaultWebhookRetryPolicy()}
+}
+
+func (s *Sender) now() time.Time {
+	if s.Now != nil {
+		return s.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+// SendDue attempts every delivery that is due and returns how many were
+// attempted. Failed attempts are rescheduled, not returned as errors; an
+// error means the store could not be read or written.
+func (s *Sender) SendDue(ctx context.Context) (int, error) {
+	batch := s.BatchSize
+	if batch <= 0 {
+		batch = DefaultBatchSize
+	}
+
+	deliveries, err := s.store.ListDueWebhookDeliveries(ctx, s.now(), batch)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, delivery := range deliveries {
+		if err := s.Send(ctx, delivery); err != nil {
+			return i, err
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+// Send makes one attempt at a delivery and records the outcome
+func (s *Sender) Send(ctx context.Context, delivery *types.WebhookDelivery) error {
+	sub, err := s.store.FindWebhookSubscription(ctx, delivery.SubscriptionID)
+	if err != nil {
+		return err
+	}
+
+	attempt := s.attempt(ctx, sub, delivery)
+	delivery.RecordAttempt(attempt, s.Retry)
+	return s.store.RecordWebhookAttempt(ctx, delivery, attempt)
+}
+
+// attempt posts the delivery body to the subscription URL
+func (s *Sender) attempt(ctx context.Context, sub *types.WebhookSubscription, delivery *types.WebhookDelivery) *types.WebhookAttempt {
+	started := s.now()
+	attempt := &types.WebhookAttempt{
+		ID:          types.GenerateID("wha_", 15),
+		DeliveryID:  delivery.ID,
+		AttemptedAt: started,
+	}
+	defer func() { attempt.DurationMS = time.Since(started).Milliseconds() }()
+
+	if !sub.Active {
+		attempt.Error = "subscription is disabled"
+		return attempt
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(types.WebhookHeaderID, delivery.ID)
+	req.Header.Set(types.WebhookHeaderEvent, string(delivery.EventType))
+	req.Header.Set(types.WebhookHeaderTimestamp, fmt.Sprint(started.Unix()))
+	req.Header.Set(types.WebhookHeaderSignature, types.SignWebhook(sub.Secret, started, delivery.Body))
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxLoggedResponse))
+	attempt.StatusCode = resp.StatusCode
+	attempt.Response = string(body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		attempt.Error = "receiver responded " + resp.Status
+	}
+	return attempt
+}
+
+// Run sends due deliveries until ctx is cancelled, polling at Interval once
+// nothing is due. onError, if set, is told about store failures.
+func (s *Sender) Run(ctx context.Context, onError func(error)) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	batch := s.BatchSize
+	if batch <= 0 {
+		batch = DefaultBatchSize
+	}
+
+	for {
+		sent, err := s.SendDue(ctx)
+		if err != nil && onError != nil {
+			onError(err)
+		}
+		if err == nil && sent == batch {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/otyang/waas-go/outbox"
+	"github.com/otyang/waas-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore keeps subscriptions, deliveries and attempts in memory
+type memoryStore struct {
+	mu         sync.Mutex
+	subs       map[string]*types.WebhookSubscription
+	deliveries []*types.WebhookDelivery
+	attempts   []*types.WebhookAttempt
+}
+
+func newMemoryStore(subs ...*types.WebhookSubscription) *memoryStore {
+	s := &memoryStore{subs: make(map[string]*types.WebhookSubscription)}
+	for _, sub := range subs {
+		s.subs[sub.ID] = sub
+	}
+	return s
+}
+
+func (s *memoryStore) EnqueueWebhookDeliveries(_ context.Context, event *types.OutboxEvent, customerID string) ([]*types.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var queued []*types.WebhookDelivery
+	for _, sub := range s.subs {
+		if sub.CustomerID != customerID || !sub.Matches(event.Type) {
+			continue
+		}
+		duplicate := false
+		for _, d := range s.deliveries {
+			duplicate = duplicate || (d.SubscriptionID == sub.ID && d.EventID == event.EventID)
+		}
+		if duplicate {
+			continue
+		}
+		delivery, err := types.NewWebhookDelivery(sub, event)
+		if err != nil {
+			return nil, err
+		}
+		s.deliveries = append(s.deliveries, delivery)
+		queued = append(queued, delivery)
+	}
+	return queued, nil
+}
+
+func (s *memoryStore) ListDueWebhookDeliveries(_ context.Context, now time.Time, limit int) ([]*types.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []*types.WebhookDelivery
+	for _, d := range s.deliveries {
+		if d.Status == types.WebhookPending && !d.NextAttemptAt.After(now) && len(due) < limit {
+			due = append(due, d)
+		}
+	}
+	return due, nil
+}
+
+func (s *memoryStore) FindWebhookSubscription(_ context.Context, id string) (*types.WebhookSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sub, ok := s.subs[id]; ok {
+		return sub, nil
+	}
+	return nil, types.ErrWebhookSubscriptionNotFound
+}
+
+func (s *memoryStore) RecordWebhookAttempt(_ context.Context, _ *types.WebhookDelivery, attempt *types.WebhookAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts = append(s.attempts, attempt)
+	return nil
+}
+
+// receiver is an httptest endpoint that verifies signatures and can be told to fail
+type receiver struct {
+	mu       sync.Mutex
+	secret   string
+	failures int // Requests to fail with 500 before accepting
+	received []*http.Request
+	bodies   [][]byte
+	verified []error
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.received = append(rc.received, r)
+	rc.bodies = append(rc.bodies, body)
+	rc.verified = append(rc.verified, types.VerifyWebhook(
+		rc.secret,
+		r.Header.Get(types.WebhookHeaderTimestamp),
+		r.Header.Get(types.WebhookHeaderSignature),
+		body,
+		5*time.Minute,
+		time.Now(),
+	))
+
+	if rc.failures > 0 {
+		rc.failures--
+		http.Error(w, "try later", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func walletEvent(t *testing.T, eventType types.EventType, customerID string) *types.OutboxEvent {
+	event, err := types.NewOutboxEvent(eventType, "wt_1", &types.WalletEvent{
+		Wallet: &types.Wallet{ID: "wt_1", CustomerID: customerID},
+	})
+	require.NoError(t, err)
+	return event
+}
+
+func TestDispatcherFiltersEvents(t *testing.T) {
+	ctx := context.Background()
+	credits, err := types.NewWebhookSubscription("cus_1", "https://merchant.example/credits", types.EventWalletCredited)
+	require.NoError(t, err)
+	everything, err := types.NewWebhookSubscription("cus_1", "https://merchant.example/all")
+	require.NoError(t, err)
+	other, err := types.NewWebhookSubscription("cus_2", "https://other.example/all")
+	require.NoError(t, err)
+	store := newMemoryStore(credits, everything, other)
+	dispatcher := NewDispatcher(store)
+
+	credited := walletEvent(t, types.EventWalletCredited, "cus_1")
+	require.NoError(t, dispatcher.Publish(ctx, credited))
+	require.NoError(t, dispatcher.Publish(ctx, walletEvent(t, types.EventWalletDebited, "cus_1")))
+	assert.Len(t, store.deliveries, 3)
+
+	// The outbox relay may publish an event twice
+	require.NoError(t, dispatcher.Publish(ctx, credited))
+	assert.Len(t, store.deliveries, 3)
+
+	var _ outbox.Publisher = dispatcher
+}
+
+func TestSenderDeliversSignedRequests(t *testing.T) {
+	ctx := context.Background()
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	sub, err := types.NewWebhookSubscription("cus_1", server.URL)
+	require.NoError(t, err)
+	rc.secret = sub.Secret
+	store := newMemoryStore(sub)
+	require.NoError(t, NewDispatcher(store).Publish(ctx, walletEvent(t, types.EventTransferCompleted, "cus_1")))
+
+	sent, err := NewSender(store).SendDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	require.Len(t, rc.received, 1)
+	assert.NoError(t, rc.verified[0])
+	assert.Equal(t, string(types.EventTransferCompleted), rc.received[0].Header.Get(types.WebhookHeaderEvent))
+	assert.Equal(t, store.deliveries[0].ID, rc.received[0].Header.Get(types.WebhookHeaderID))
+	assert.JSONEq(t, string(store.deliveries[0].Body), string(rc.bodies[0]))
+
+	assert.Equal(t, types.WebhookDelivered, store.deliveries[0].Status)
+	require.Len(t, store.attempts, 1)
+	assert.Equal(t, http.StatusNoContent, store.attempts[0].StatusCode)
+}
+
+func TestSenderRetriesWithBackoff(t *testing.T) {
+	ctx := context.Background()
+	rc := &receiver{failures: 2}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	sub, err := types.NewWebhookSubscription("cus_1", server.URL)
+	require.NoError(t, err)
+	rc.secret = sub.Secret
+	store := newMemoryStore(sub)
+	require.NoError(t, NewDispatcher(store).Publish(ctx, walletEvent(t, types.EventWalletCredited, "cus_1")))
+
+	now := time.Now()
+	sender := NewSender(store)
+	sender.Retry = types.WebhookRetryPolicy{MaxAttempts: 5, BaseDelay: time.Minute, MaxDelay: time.Hour}
+	sender.Now = func() time.Time { return now }
+	delivery := store.deliveries[0]
+
+	_, err = sender.SendDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, types.WebhookPending, delivery.Status)
+	assert.Equal(t, http.StatusInternalServerError, delivery.LastStatusCode)
+	assert.WithinDuration(t, now.Add(time.Minute), delivery.NextAttemptAt, time.Second)
+
+	// Not due yet
+	sent, err := sender.SendDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+
+	now = now.Add(time.Minute)
+	_, err = sender.SendDue(ctx)
+	require.NoError(t, err)
+	assert.WithinDuration(t, now.Add(2*time.Minute), delivery.NextAttemptAt, time.Second)
+
+	now = now.Add(2 * time.Minute)
+	_, err = sender.SendDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, types.WebhookDelivered, delivery.Status)
+	assert.Equal(t, 3, delivery.Attempts)
+	assert.Len(t, store.attempts, 3)
+
+	// Every retry carried a valid signature over the same body
+	for i := range rc.received {
+		assert.NoError(t, rc.verified[i])
+		assert.Equal(t, rc.bodies[0], rc.bodies[i])
+	}
+}
+
+func TestSenderDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	}))
+	defer server.Close()
+
+	sub, err := types.NewWebhookSubscription("cus_1", server.URL)
+	require.NoError(t, err)
+	store := newMemoryStore(sub)
+	require.NoError(t, NewDispatcher(store).Publish(ctx, walletEvent(t, types.EventWalletCredited, "cus_1")))
+
+	now := time.Now()
+	sender := NewSender(store)
+	sender.Retry = types.WebhookRetryPolicy{MaxAttempts: 2, BaseDelay: time.Second, MaxDelay: time.Second}
+	sender.Now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		_, err := sender.SendDue(ctx)
+		require.NoError(t, err)
+		now = now.Add(time.Second)
+	}
+
+	delivery := store.deliveries[0]
+	assert.Equal(t, types.WebhookDead, delivery.Status)
+	assert.Equal(t, "receiver responded 410 Gone", delivery.LastError)
+	assert.Contains(t, store.attempts[1].Response, "gone")
+
+	sent, err := sender.SendDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, sent, "dead deliveries are not retried")
+
+	delivery.Redeliver(now)
+	sent, err = sender.SendDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+}
+
+func TestSenderUnreachableReceiver(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	sub, err := types.NewWebhookSubscription("cus_1", url)
+	require.NoError(t, err)
+	store := newMemoryStore(sub)
+	require.NoError(t, NewDispatcher(store).Publish(ctx, walletEvent(t, types.EventWalletCredited, "cus_1")))
+
+	_, err = NewSender(store).SendDue(ctx)
+	require.NoError(t, err)
+
+	require.Len(t, store.attempts, 1)
+	assert.Zero(t, store.attempts[0].StatusCode)
+	assert.NotEmpty(t, store.attempts[0].Error)
+	assert.Equal(t, types.WebhookPending, store.deliveries[0].Status)
+}